@@ -2,12 +2,11 @@ package main
 
 import (
 	"bytes"
-	"crypto/rand" // Used for secure ID generation
-	"encoding/binary"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io" // <--- THIS LINE MUST BE HERE
+	"math/rand"
 	"mime/multipart"
 	"model_loop_sensor/testutils"
 	"net"
@@ -29,6 +28,7 @@ import (
 // TestConfig holds comprehensive test configuration settings
 type TestConfig struct {
 	TestID          string
+	Run             *testutils.TestRun
 	BaseURL         string
 	TestDataDir     string
 	Environment     string
@@ -114,6 +114,16 @@ var (
 	serverMgr  *ServerManager
 	testLogger *TestLogger
 	initOnce   sync.Once
+
+	// defaultHarness backs appConfig and httpClient. The globals remain for
+	// the existing tests; new tests should take what they need from a
+	// testutils.Harness (or testutils.ConfigFromContext) instead.
+	defaultHarness *testutils.Harness
+
+	// jitterRand drives retry jitter from the run seed so backoff timing
+	// replays with TESTUTILS_SEED.
+	jitterRand   *rand.Rand
+	jitterRandMu sync.Mutex
 )
 
 // ------------------- INITIALIZATION -------------------
@@ -129,15 +139,18 @@ func initializeTestConfig() error {
 			appConfig = testutils.DefaultConfig()
 		}
 
-		// Generate unique test identifier
-		testID, err := generateTestID()
+		// The run ID and every random stream derive from one seed
+		run, err := testutils.TestRunFromEnv()
 		if err != nil {
-			initErr = fmt.Errorf("failed to generate test ID: %w", err)
+			initErr = fmt.Errorf("failed to create test run: %w", err)
 			return
 		}
+		testID := run.ID
+		jitterRand = run.Rand("retry-jitter")
 
 		testConfig = &TestConfig{
 			TestID:          testID,
+			Run:             run,
 			BaseURL:         getEnvOrDefault("TEST_BASE_URL", "http://localhost:3000/api"),
 			TestDataDir:     filepath.Join(os.TempDir(), "integration-test-"+testID),
 			Environment:     getEnvOrDefault("TEST_ENV", "integration"),
@@ -210,9 +223,10 @@ func initializeTestConfig() error {
 	return initErr
 }
 
-// initializeHTTPClient creates and configures the HTTP client
+// initializeHTTPClient creates and configures the HTTP client and the default
+// harness around it.
 func initializeHTTPClient() {
-	httpClient = &http.Client{
+	hc := &http.Client{
 		Timeout: testConfig.HTTPConfig.Timeout,
 		Transport: &http.Transport{
 			MaxIdleConns:          testConfig.HTTPConfig.MaxIdleConns,
@@ -224,6 +238,12 @@ func initializeHTTPClient() {
 			ExpectContinueTimeout: testConfig.HTTPConfig.ExpectContinueTimeout,
 		},
 	}
+	defaultHarness = testutils.NewHarness(appConfig,
+		testutils.WithBaseURL(testConfig.BaseURL),
+		testutils.WithClientOptions(testutils.WithTransport(hc)),
+	)
+	appConfig = defaultHarness.Config
+	httpClient = defaultHarness.Client.HTTPClient()
 }
 
 // initializeLogger sets up the test logger
@@ -233,15 +253,6 @@ func initializeLogger() {
 
 // ------------------- UTILITY FUNCTIONS -------------------
 
-// generateTestID creates a unique identifier for the test run
-func generateTestID() (string, error) {
-	randomBytes := make([]byte, 8)
-	if _, err := rand.Read(randomBytes); err != nil {
-		return "", fmt.Errorf("failed to generate random bytes: %w", err)
-	}
-	return hex.EncodeToString(randomBytes), nil
-}
-
 // getEnvOrDefault retrieves environment variable or returns default value
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -433,8 +444,11 @@ func (sm *ServerManager) Start() error {
 		return fmt.Errorf("failed to start server: %w", err)
 	}
 
-	healthURL := testConfig.BaseURL + sm.config.HealthEndpoint
-	return waitForHealthEndpoint(healthURL, sm.config.StartupTimeout)
+	// Only a 2xx counts as healthy, so a wrong HealthEndpoint fails with
+	// "endpoint returned 404" instead of passing.
+	check := testutils.HealthCheck{URL: testConfig.BaseURL + sm.config.HealthEndpoint}
+	_, err := check.WaitHealthy(context.Background(), sm.config.StartupTimeout)
+	return err
 }
 
 // Stop gracefully terminates the server
@@ -475,33 +489,6 @@ func (sm *ServerManager) getEnvironmentVariables() []string {
 
 // ------------------- HEALTH CHECK FUNCTIONS -------------------
 
-// waitForHealthEndpoint repeatedly checks a URL until it responds successfully
-func waitForHealthEndpoint(url string, timeout time.Duration) error {
-	client := &http.Client{Timeout: 5 * time.Second}
-	deadline := time.Now().Add(timeout)
-
-	for attempt := 0; time.Now().Before(deadline); attempt++ {
-		response, err := client.Get(url)
-		if err == nil && response.StatusCode < 500 {
-			response.Body.Close()
-			testLogger.Debug("Health check successful", "url", url, "attempt", attempt+1)
-			return nil
-		}
-
-		if response != nil {
-			response.Body.Close()
-		}
-
-		if attempt%10 == 0 {
-			testLogger.Debug("Waiting for service health", "url", url, "attempt", attempt+1, "error", err)
-		}
-
-		time.Sleep(testConfig.PollInterval)
-	}
-
-	return fmt.Errorf("timeout waiting for %s after %v", url, timeout)
-}
-
 // waitForServicePort verifies TCP connectivity to a service
 func waitForServicePort(service string, timeout time.Duration) error {
 	parts := strings.Split(service, ":")
@@ -510,25 +497,18 @@ func waitForServicePort(service string, timeout time.Duration) error {
 	}
 
 	host, port := parts[0], parts[1]
-	deadline := time.Now().Add(timeout)
 	address := net.JoinHostPort(host, port)
 
-	for attempt := 0; time.Now().Before(deadline); attempt++ {
-		conn, err := net.DialTimeout("tcp", address, 2*time.Second)
-		if err == nil {
+	return testutils.WaitFor(context.Background(), "service "+service, testConfig.PollInterval,
+		func(ctx context.Context) (bool, string, error) {
+			conn, err := net.DialTimeout("tcp", address, 2*time.Second)
+			if err != nil {
+				return false, err.Error(), nil
+			}
 			conn.Close()
-			testLogger.Debug("Service port accessible", "service", service, "attempt", attempt+1)
-			return nil
-		}
-
-		if attempt%5 == 0 {
-			testLogger.Debug("Checking service port", "service", service, "attempt", attempt+1, "error", err)
-		}
-
-		time.Sleep(testConfig.PollInterval)
-	}
-
-	return fmt.Errorf("service %s not accessible after %v", service, timeout)
+			testLogger.Debug("Service port accessible", "service", service)
+			return true, "", nil
+		}, testutils.WithWaitTimeout(timeout))
 }
 
 // ------------------- TEST LOGGER -------------------
@@ -549,11 +529,27 @@ func NewTestLogger(config *TestConfig) *TestLogger {
 	}
 }
 
-// SetTest associates a test instance with the logger
+// SetTest associates a test instance with the logger. The logger is
+// shared, so parallel tests should use ForTest instead.
 func (tl *TestLogger) SetTest(t *testing.T) {
+	tl.logMutex.Lock()
+	defer tl.logMutex.Unlock()
 	tl.test = t
 }
 
+// ForTest returns a logger bound to t alone, so parallel subtests each log
+// under their own name. It is unbound when t completes and then prints to
+// stdout like the shared logger does outside tests.
+func (tl *TestLogger) ForTest(t *testing.T) *TestLogger {
+	child := &TestLogger{
+		test:         t,
+		debugEnabled: tl.debugEnabled,
+		logLevel:     tl.logLevel,
+	}
+	t.Cleanup(func() { child.SetTest(nil) })
+	return child
+}
+
 // Info logs informational messages
 func (tl *TestLogger) Info(message string, args ...interface{}) {
 	tl.log("INFO", message, args...)
@@ -676,12 +672,11 @@ func calculateExponentialBackoff(attempt int) time.Duration {
 	// Apply jitter for randomization
 	if testConfig.RetryConfig.JitterFactor > 0 {
 		jitter := delay * testConfig.RetryConfig.JitterFactor
-		// Read a random int64 from crypto/rand
-		var n int64
-		binary.Read(rand.Reader, binary.BigEndian, &n) // Use crypto/rand for secure randomness
-	
-		delay += (float64(n) / float64(1<<63)) * jitter - (jitter / 2) // Center jitter around the base delay
-		
+		jitterRandMu.Lock()
+		f := jitterRand.Float64()
+		jitterRandMu.Unlock()
+
+		delay += f*jitter - (jitter / 2) // Center jitter around the base delay
 	}
 
 	// Enforce maximum delay
@@ -717,14 +712,23 @@ func TestMain(m *testing.M) {
 
 	testLogger.Info("Starting test suite execution",
 		"testID", testConfig.TestID,
+		"seed", testConfig.Run.Seed,
+		"rerun", testConfig.Run.RerunHint(),
 		"environment", testConfig.Environment,
 		"baseURL", testConfig.BaseURL)
 
+	// Teardown steps run in reverse order of pushing, and a failing step
+	// does not hide the errors of the others.
+	teardown := &testutils.Cleanup{}
+
 	// Create test data directory
 	if err := os.MkdirAll(testConfig.TestDataDir, 0755); err != nil {
 		testLogger.Error("Failed to create test data directory", "error", err)
 		os.Exit(1)
 	}
+	teardown.Push("cleanup test directory", func(context.Context) error {
+		return cleanupTestDirectory()
+	})
 
 	// Setup test environment with retry capability
 	setupError := retryWithBackoff(func() error {
@@ -733,24 +737,26 @@ func TestMain(m *testing.M) {
 
 	if setupError != nil {
 		testLogger.Error("Failed to setup test environment", "error", setupError)
-		cleanupTestDirectory()
+		teardown.Run(context.Background())
 		os.Exit(1)
 	}
+	teardown.Push("stop docker containers", func(context.Context) error {
+		testLogger.Info("Stopping Docker containers...")
+		return dockerMgr.Stop()
+	})
+	teardown.Push("stop application server", func(context.Context) error {
+		testLogger.Info("Terminating application server...")
+		return serverMgr.Stop()
+	})
 
 	// Execute test cases
 	exitCode := m.Run()
 
-	// Teardown test environment
-	if err := teardownTestEnvironment(); err != nil {
+	if err := teardown.Run(context.Background()); err != nil {
 		testLogger.Error("Failed to teardown test environment", "error", err)
 		exitCode = 1
 	}
 
-	// Cleanup test resources
-	if err := cleanupTestDirectory(); err != nil {
-		testLogger.Error("Failed to cleanup test directory", "error", err)
-	}
-
 	os.Exit(exitCode)
 }
 
@@ -784,31 +790,6 @@ func setupTestEnvironment() error {
 	return nil
 }
 
-// teardownTestEnvironment cleans up test environment
-func teardownTestEnvironment() error {
-	var errors []error
-
-	testLogger.Info("Terminating application server...")
-	if serverMgr != nil {
-		if err := serverMgr.Stop(); err != nil {
-			errors = append(errors, fmt.Errorf("failed to stop server: %w", err))
-		}
-	}
-
-	testLogger.Info("Stopping Docker containers...")
-	if dockerMgr != nil {
-		if err := dockerMgr.Stop(); err != nil {
-			errors = append(errors, fmt.Errorf("failed to stop Docker: %w", err))
-		}
-	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf("teardown completed with errors: %v", errors)
-	}
-
-	return nil
-}
-
 // ------------------- TEST CASES -------------------
 
 // TestHealthCheck verifies the health endpoint functionality
@@ -1148,46 +1129,20 @@ func TestConcurrentRequests(t *testing.T) {
 		concurrencyLevel = 50 // Safety limit
 	}
 
-	errorChannel := make(chan error, concurrencyLevel)
-	completionChannel := make(chan bool, concurrencyLevel)
-	var waitGroup sync.WaitGroup
-
-	for workerID := 0; workerID < concurrencyLevel; workerID++ {
-		waitGroup.Add(1)
-		go func(workerID int) {
-			defer waitGroup.Done()
-			defer func() { completionChannel <- true }()
-
-			response, err := httpClient.Get(fmt.Sprintf("%s/users", testConfig.BaseURL))
-			if err != nil {
-				errorChannel <- fmt.Errorf("worker %d: %w", workerID, err)
-				return
-			}
-			defer response.Body.Close()
-
-			if response.StatusCode != http.StatusOK {
-				responseBody, _ := io.ReadAll(response.Body)
-				errorChannel <- fmt.Errorf("worker %d: expected 200, received %d\nResponse: %s",
-					workerID, response.StatusCode, string(responseBody))
-			}
-		}(workerID)
-	}
-
-	// Wait for all workers to complete
-	waitGroup.Wait()
-	close(errorChannel)
-	close(completionChannel)
-
-	// Collect and report errors
-	var errorMessages []string
-	for err := range errorChannel {
-		errorMessages = append(errorMessages, err.Error())
-	}
+	testutils.RunConcurrently(t, concurrencyLevel, 1, func(workerID, _ int) error {
+		response, err := httpClient.Get(fmt.Sprintf("%s/users", testConfig.BaseURL))
+		if err != nil {
+			return fmt.Errorf("worker %d: %w", workerID, err)
+		}
+		defer response.Body.Close()
 
-	if len(errorMessages) > 0 {
-		t.Errorf("%d concurrent requests failed:\n%s",
-			len(errorMessages), strings.Join(errorMessages, "\n"))
-	}
+		if response.StatusCode != http.StatusOK {
+			responseBody, _ := io.ReadAll(response.Body)
+			return fmt.Errorf("worker %d: expected 200, received %d\nResponse: %s",
+				workerID, response.StatusCode, string(responseBody))
+		}
+		return nil
+	})
 }
 
 // ------------------- ASSERTION HELPERS -------------------
@@ -17,11 +17,15 @@
 package testutils
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -42,6 +46,13 @@ type Request struct {
 	*http.Request
 	PathParams map[string]string
 	RequestID  string
+
+	rw              *responseWriter // for handlers that take over the connection
+	multipartMemory int64           // see App.SetMultipartMemory
+	route           string          // normalized pattern that matched, e.g. "/users/:id"
+	paramNames      []string        // parameter names of route, in path order
+	start           time.Time       // when ServeHTTP received the request, before any middleware
+	onError         ErrorHandler    // see App.SetErrorHandler; nil means DefaultErrorHandler
 }
 
 // Response is the structured return value of a handler.
@@ -63,12 +74,18 @@ type Middleware func(Handler) Handler
 
 // App is the top‑level server container.
 type App struct {
-	mux         *http.ServeMux
+	raw         *http.ServeMux // passthrough handlers, see HandleRaw
 	middlewares []Middleware
 	groups      []*Group
 	server      *http.Server
 	addr        string
 	mu          sync.RWMutex
+	root        *node     // routing trie, guarded by mu
+	websockets  wsTracker // see api_websocket.go
+	onError     ErrorHandler
+
+	maxBodyBytes    int64 // 0 means unlimited
+	multipartMemory int64 // 0 means DefaultMultipartMemory
 }
 
 // Group represents a route prefix with its own middleware chain.
@@ -85,7 +102,7 @@ type Group struct {
 // NewApp creates a new App with default settings.
 func NewApp() *App {
 	return &App{
-		mux:    http.NewServeMux(),
+		raw:    http.NewServeMux(),
 		server: &http.Server{},
 	}
 }
@@ -97,20 +114,47 @@ func (a *App) Use(mw Middleware) {
 	a.middlewares = append(a.middlewares, mw)
 }
 
+// SetMaxBodyBytes caps request bodies at n bytes; reading past the cap
+// fails, and BindForm reports it as 413. Zero or less removes the cap.
+func (a *App) SetMaxBodyBytes(n int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.maxBodyBytes = n
+}
+
+// SetMultipartMemory sets how many bytes of a multipart form BindForm keeps
+// in memory before writing file parts to temporary files. Zero or less
+// restores DefaultMultipartMemory.
+func (a *App) SetMultipartMemory(n int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.multipartMemory = n
+}
+
 // Group creates a new route group with the given prefix.
 // All routes added inside the group will have the prefix and inherit
 // the app's global middlewares plus any group‑specific ones.
 func (a *App) Group(prefix string, fn func(*Group)) *Group {
+	a.mu.RLock()
+	mws := a.middlewares
+	a.mu.RUnlock()
+	return a.newGroup(joinRoutePath("", prefix), mws, fn)
+}
+
+// newGroup registers a group whose chain starts with a snapshot of mws
+// and runs fn on it.
+func (a *App) newGroup(prefix string, mws []Middleware, fn func(*Group)) *Group {
 	g := &Group{
 		prefix:      prefix,
 		parent:      a,
-		middlewares: make([]Middleware, len(a.middlewares)),
+		middlewares: append([]Middleware(nil), mws...),
 	}
-	copy(g.middlewares, a.middlewares)
 	a.mu.Lock()
 	a.groups = append(a.groups, g)
 	a.mu.Unlock()
-	fn(g)
+	if fn != nil {
+		fn(g)
+	}
 	return g
 }
 
@@ -136,11 +180,16 @@ func (a *App) Run(addr string) error {
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	return a.server.Shutdown(shutdownCtx)
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	return a.DrainWebSockets(shutdownCtx)
 }
 
 // ServeHTTP implements http.Handler, routing requests to registered handlers.
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
 	// Generate request ID
 	reqID, _ := unique.NewNanoID(12)
 	r = r.WithContext(context.WithValue(r.Context(), requestIDKey, reqID))
@@ -149,27 +198,65 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
 
 	// Find and execute handler
-	handler, params := a.lookup(r.Method, r.URL.Path)
+	handler, params, route := a.lookup(r.Method, r.URL.Path)
 	if handler == nil {
-		http.NotFound(rw, r)
-		return
+		if raw, pattern := a.raw.Handler(r); pattern != "" {
+			raw.ServeHTTP(rw, r)
+			return
+		}
+		// Global middlewares still see the request, with an empty route.
+		a.mu.RLock()
+		handler = a.applyMiddlewares(notFound, a.middlewares)
+		a.mu.RUnlock()
+	}
+
+	a.mu.RLock()
+	maxBody, multipartMem, onError := a.maxBodyBytes, a.multipartMemory, a.onError
+	a.mu.RUnlock()
+	if maxBody > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(rw, r.Body, maxBody)
 	}
 
 	req := &Request{
-		Request:    r,
-		PathParams: params,
-		RequestID:  reqID,
+		Request:         r,
+		PathParams:      params,
+		RequestID:       reqID,
+		rw:              rw,
+		multipartMemory: multipartMem,
+		onError:         onError,
+		start:           start,
+	}
+	if route != nil {
+		req.route, req.paramNames = route.pattern, route.params
 	}
+	defer func() {
+		// BindForm parses into req's copy of the request, so the server
+		// never sees these temporary files.
+		if req.MultipartForm != nil {
+			req.MultipartForm.RemoveAll()
+		}
+	}()
 
 	ctx := req.Context()
 	ctx = context.WithValue(ctx, requestIDKey, reqID)
+	ctx = context.WithValue(ctx, routePatternKey, req.route)
 	req.Request = req.WithContext(ctx)
 
 	// Execute handler
 	resp, err := handler(ctx, req)
+	if rw.hijacked || rw.streaming {
+		// The handler owns the connection (e.g. a WebSocket) or has
+		// already streamed its response (e.g. SSE).
+		return
+	}
+	if errors.Is(err, context.Canceled) || IsClientGone(ctx) {
+		// Nobody is left to read a response.
+		rw.status = StatusClientClosedRequest
+		verbose.Printf(1, "api: %s %s: client closed request %s", r.Method, r.URL.Path, reqID)
+		return
+	}
 	if err != nil {
-		// Convert error to response using default error handler
-		resp = a.errorHandler(err)
+		resp = req.errorResponse(ctx, err)
 	}
 
 	// Write response
@@ -180,22 +267,71 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // Route registration
 // --------------------------------------------------------------------
 
-// Handle registers a handler for the given pattern and method.
+// Handle registers a handler for the given pattern and method. It panics
+// if the method is already registered for an equivalent pattern, one that
+// differs only in slashes or parameter names.
 func (a *App) Handle(method, pattern string, handler Handler) {
-	fullPattern := pattern
 	// Apply all middlewares (global + route‑specific) to the handler
 	final := a.applyMiddlewares(handler, a.middlewares)
-	a.mux.HandleFunc(fullPattern, func(w http.ResponseWriter, r *http.Request) {
-		// This is a bridge: we already route via a.ServeHTTP,
-		// so this should rarely be called directly.
-		a.ServeHTTP(w, r)
-	})
-	// Actually, we need to register the pattern with method matching.
-	// We'll use a custom router implementation below.
-	// For now, we store routes in a map for lookup.
 	a.registerRoute(method, pattern, final)
 }
 
+// HandleRaw registers a plain http.Handler for an http.ServeMux pattern
+// ("/metrics", "GET /static/"). Raw handlers only see requests that no
+// route registered with Handle matches, and bypass the App's middlewares,
+// request IDs and body limits.
+func (a *App) HandleRaw(pattern string, handler http.Handler) {
+	a.raw.Handle(pattern, handler)
+}
+
+// RouteInfo describes a route registered with Handle or a Group.
+type RouteInfo struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+}
+
+// Routes returns the registered routes sorted by pattern, then method.
+// Raw handlers are not included.
+func (a *App) Routes() []RouteInfo {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	var routes []RouteInfo
+	var walk func(n *node)
+	walk = func(n *node) {
+		if n == nil {
+			return
+		}
+		for method, route := range n.routes {
+			routes = append(routes, RouteInfo{Method: method, Pattern: route.pattern})
+		}
+		for _, child := range n.children {
+			walk(child)
+		}
+		walk(n.paramChild)
+		walk(n.wildcardChild)
+	}
+	walk(a.root)
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// notFound answers requests that match no route, as http.NotFound does.
+func notFound(ctx context.Context, req *Request) (*Response, error) {
+	return &Response{
+		Status: http.StatusNotFound,
+		Headers: http.Header{
+			"Content-Type":           {"text/plain; charset=utf-8"},
+			"X-Content-Type-Options": {"nosniff"},
+		},
+		RawBody: []byte("404 page not found\n"),
+	}, nil
+}
+
 // Get is a shortcut for Handle with http.MethodGet.
 func (a *App) Get(pattern string, handler Handler) { a.Handle(http.MethodGet, pattern, handler) }
 
@@ -215,19 +351,37 @@ func (a *App) Delete(pattern string, handler Handler) { a.Handle(http.MethodDele
 // Group methods
 // --------------------------------------------------------------------
 
-// Use appends a middleware to the group's chain.
+// Use appends a middleware to the group's chain. Routes and sub-groups
+// take a snapshot of the chain when they are added, so a middleware only
+// applies to what is registered after it.
 func (g *Group) Use(mw Middleware) {
 	g.middlewares = append(g.middlewares, mw)
 }
 
+// Group creates a sub-group whose prefix is appended to g's. It inherits
+// g's middlewares as they are now plus any it adds itself. Path
+// parameters may appear in any prefix ("/tenants/:tenantID") and reach
+// handlers through Request.PathParams like those in the route pattern.
+func (g *Group) Group(prefix string, fn func(*Group)) *Group {
+	return g.parent.newGroup(joinRoutePath(g.prefix, prefix), g.middlewares, fn)
+}
+
 // Handle registers a route under the group's prefix.
 func (g *Group) Handle(method, pattern string, handler Handler) {
-	fullPattern := strings.TrimRight(g.prefix, "/") + "/" + strings.TrimLeft(pattern, "/")
+	fullPattern := joinRoutePath(g.prefix, pattern)
 	// Apply group middlewares (which already include app's)
 	final := g.parent.applyMiddlewares(handler, g.middlewares)
 	g.parent.registerRoute(method, fullPattern, final)
 }
 
+// joinRoutePath joins a group prefix and a pattern into a pattern with a
+// single leading slash and no empty segments. Either may be empty, so a
+// group can register its own root with "" or "/".
+func joinRoutePath(prefix, pattern string) string {
+	segments := append(splitPath(prefix), splitPath(pattern)...)
+	return "/" + strings.Join(segments, "/")
+}
+
 // Get is a shortcut for Handle with http.MethodGet.
 func (g *Group) Get(pattern string, handler Handler)   { g.Handle(http.MethodGet, pattern, handler) }
 func (g *Group) Post(pattern string, handler Handler)  { g.Handle(http.MethodPost, pattern, handler) }
@@ -243,60 +397,57 @@ func (g *Group) Delete(pattern string, handler Handler) {
 
 type routeEntry struct {
 	method  string
-	pattern string
+	pattern string // normalized: one leading slash, nothing after "*"
 	handler Handler
-	params  []string // parameter names extracted from pattern
+	params  []string // parameter names extracted from pattern, "*" last for a wildcard
 }
 
+// Routes registered for the same method on equivalent patterns conflict,
+// but different methods may name a parameter differently ("/users/:id"
+// and "/users/:userID"), so the names are kept per route, not per node.
 type node struct {
 	children      map[string]*node
 	paramChild    *node
 	wildcardChild *node
-	handler       map[string]Handler // method -> handler
-	paramName     string
+	routes        map[string]*routeEntry // method -> route
 }
 
 var pathParamKey = struct{}{}
 
+type routePatternKeyType struct{}
+
+var routePatternKey = routePatternKeyType{}
+
+// initRouter creates the routing trie on first use. The caller holds a.mu.
 func (a *App) initRouter() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
 	if a.root == nil {
 		a.root = &node{children: make(map[string]*node)}
 	}
 }
 
-// root node for routing (stored in App)
+// registerRoute adds handler to the trie under the normalized form of
+// pattern. The caller has already applied the middlewares.
 func (a *App) registerRoute(method, pattern string, handler Handler) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.initRouter()
 
-	parts := splitPath(pattern)
 	current := a.root
-	paramNames := []string{}
-
-	for _, part := range parts {
-		if part == "" {
-			continue
-		}
+	var segments, paramNames []string
+	for _, part := range splitPath(pattern) {
+		segments = append(segments, part)
 		if strings.HasPrefix(part, ":") {
 			// Parameter, e.g., :id
-			name := part[1:]
-			paramNames = append(paramNames, name)
+			paramNames = append(paramNames, part[1:])
 			if current.paramChild == nil {
-				current.paramChild = &node{
-					children:  make(map[string]*node),
-					paramName: name,
-				}
+				current.paramChild = &node{children: make(map[string]*node)}
 			}
 			current = current.paramChild
 		} else if part == "*" {
 			// Wildcard (catch‑all)
+			paramNames = append(paramNames, "*")
 			if current.wildcardChild == nil {
-				current.wildcardChild = &node{
-					children: make(map[string]*node),
-				}
+				current.wildcardChild = &node{children: make(map[string]*node)}
 			}
 			current = current.wildcardChild
 			break // wildcard consumes rest
@@ -308,27 +459,34 @@ func (a *App) registerRoute(method, pattern string, handler Handler) {
 			current = current.children[part]
 		}
 	}
-	if current.handler == nil {
-		current.handler = make(map[string]Handler)
+	if current.routes == nil {
+		current.routes = make(map[string]*routeEntry)
+	}
+	normalized := "/" + strings.Join(segments, "/")
+	if existing, ok := current.routes[method]; ok {
+		panic(fmt.Sprintf("App.Handle: %s %s conflicts with %s %s", method, pattern, method, existing.pattern))
+	}
+	current.routes[method] = &routeEntry{
+		method:  method,
+		pattern: normalized,
+		handler: handler,
+		params:  paramNames,
 	}
-	current.handler[method] = handler
 }
 
-func (a *App) lookup(method, path string) (Handler, map[string]string) {
+// lookup returns the handler for method and path, the path parameters and
+// the route that matched, or nils if none did.
+func (a *App) lookup(method, path string) (Handler, map[string]string, *routeEntry) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 	if a.root == nil {
-		return nil, nil
+		return nil, nil, nil
 	}
 	parts := splitPath(path)
 	current := a.root
-	params := make(map[string]string)
-	var paramIdx int
+	var values []string // parameter values in path order
 
 	for i, part := range parts {
-		if part == "" {
-			continue
-		}
 		// 1. Try static match
 		if next, ok := current.children[part]; ok {
 			current = next
@@ -336,27 +494,29 @@ func (a *App) lookup(method, path string) (Handler, map[string]string) {
 		}
 		// 2. Try param match
 		if current.paramChild != nil {
-			params[current.paramChild.paramName] = part
+			values = append(values, part)
 			current = current.paramChild
-			paramIdx++
 			continue
 		}
 		// 3. Try wildcard (catch‑all)
 		if current.wildcardChild != nil {
 			// Collect remaining parts
-			remain := strings.Join(parts[i:], "/")
-			params["*"] = remain
+			values = append(values, strings.Join(parts[i:], "/"))
 			current = current.wildcardChild
 			break
 		}
 		// No match
-		return nil, nil
+		return nil, nil, nil
 	}
-	handler, ok := current.handler[method]
+	route, ok := current.routes[method]
 	if !ok {
-		return nil, nil
+		return nil, nil, nil
+	}
+	params := make(map[string]string, len(values))
+	for i, name := range route.params {
+		params[name] = values[i]
 	}
-	return handler, params
+	return route.handler, params, route
 }
 
 // splitPath splits a URL path into segments, ignoring empty ones.
@@ -395,28 +555,62 @@ func Recovery() Middleware {
 	}
 }
 
-// Logger middleware logs requests and responses.
+// Logger middleware logs requests and responses by route pattern, with
+// the path parameters as key=value fields, so requests to the same route
+// read alike:
+//
+//	GET /users/:id 200 1.2ms Xb3kP0aQz1Lm id=42
+//
+// Unmatched requests are logged by path. Requests whose client went away
+// are logged with StatusClientClosedRequest. The duration runs from
+// Request.StartTime, so it includes the middlewares ahead of Logger.
 func Logger() Middleware {
 	return func(next Handler) Handler {
 		return func(ctx context.Context, req *Request) (*Response, error) {
-			start := time.Now()
+			start := req.StartTime()
+			if start.IsZero() {
+				start = time.Now() // a Request built outside App.ServeHTTP
+			}
 			resp, err := next(ctx, req)
 			duration := time.Since(start)
 			if verbose.V(1) {
-				status := http.StatusOK
-				if err != nil {
+				status, note := http.StatusOK, ""
+				switch {
+				case errors.Is(err, context.Canceled) || IsClientGone(ctx):
+					status, note = StatusClientClosedRequest, " (client closed request)"
+				case err != nil:
 					status = http.StatusInternalServerError
-				} else if resp != nil {
+				case resp != nil:
 					status = resp.Status
 				}
-				verbose.Printf(1, "%s %s %d %v %s",
-					req.Method, req.URL.Path, status, duration, req.RequestID)
+				route := req.RoutePattern()
+				if route == "" {
+					route = req.URL.Path
+				}
+				var fields strings.Builder
+				for _, name := range req.ParamNames() {
+					fmt.Fprintf(&fields, " %s=%s", name, req.PathParams[name])
+				}
+				verbose.Printf(1, "%s %s %d %v %s%s%s",
+					req.Method, route, status, duration, req.RequestID, fields.String(), note)
 			}
 			return resp, err
 		}
 	}
 }
 
+// StatusClientClosedRequest is the status recorded, but never written,
+// for requests whose client disconnected before the handler finished.
+// The code is nginx's.
+const StatusClientClosedRequest = 499
+
+// IsClientGone reports whether ctx, a handler's context, was cancelled
+// because the client disconnected. Long handlers can poll it to stop
+// early; deadlines set by the handler itself do not count.
+func IsClientGone(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.Canceled)
+}
+
 // RequestID ensures each request has a unique ID (already set in App.ServeHTTP).
 func RequestID() Middleware {
 	return func(next Handler) Handler {
@@ -491,6 +685,14 @@ type Error struct {
 	Message string `json:"message"`
 	Cause   error  `json:"-"`
 	Stack   []byte `json:"-"`
+
+	// Type is a URI identifying the problem, for ProblemJSON.
+	Type string `json:"-"`
+	// Violations lists what was wrong with a request or response, e.g.
+	// the schema failures found by Contract.
+	Violations []string `json:"-"`
+	// Extensions are extra members ProblemJSON adds to the body.
+	Extensions map[string]any `json:"-"`
 }
 
 func (e *Error) Error() string {
@@ -511,12 +713,42 @@ func ErrorResponse(code int, message string) *Response {
 	}
 }
 
-// default error handler converts any error to a Response.
-func (a *App) errorHandler(err error) *Response {
-	if e, ok := err.(*Error); ok {
+// ErrorHandler converts an error returned by a handler into the response
+// sent to the client. See App.SetErrorHandler and ProblemJSON.
+type ErrorHandler func(ctx context.Context, req *Request, err error) *Response
+
+// SetErrorHandler replaces how handler errors are rendered. A nil h, or a
+// handler that returns nil, falls back to DefaultErrorHandler.
+func (a *App) SetErrorHandler(h ErrorHandler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onError = h
+}
+
+// DefaultErrorHandler renders an *Error as {"error": message}, adding its
+// violations under "violations", and any other error as a 500 that hides
+// the cause.
+func DefaultErrorHandler(ctx context.Context, req *Request, err error) *Response {
+	var e *Error
+	if !errors.As(err, &e) {
+		return ErrorResponse(http.StatusInternalServerError, "internal server error")
+	}
+	if len(e.Violations) == 0 {
 		return ErrorResponse(e.Code, e.Message)
 	}
-	return ErrorResponse(http.StatusInternalServerError, "internal server error")
+	resp := ErrorResponse(e.Code, e.Message)
+	resp.Body = map[string]any{"error": e.Message, "violations": e.Violations}
+	return resp
+}
+
+// errorResponse renders err with the App's error handler.
+func (r *Request) errorResponse(ctx context.Context, err error) *Response {
+	if r.onError != nil {
+		if resp := r.onError(ctx, r, err); resp != nil {
+			return resp
+		}
+	}
+	return DefaultErrorHandler(ctx, r, err)
 }
 
 // --------------------------------------------------------------------
@@ -562,6 +794,26 @@ func (r *Request) BindJSON(v any) error {
 	return json.NewDecoder(r.Body).Decode(v)
 }
 
+// RoutePattern returns the normalized pattern of the route that matched,
+// e.g. "/api/users/:id" for a route registered as "users/:id/" in the
+// "/api" group, or "" if none did.
+func (r *Request) RoutePattern() string { return r.route }
+
+// ParamNames returns the parameter names of the matched route in path
+// order, "*" standing for a wildcard. The slice must not be modified.
+func (r *Request) ParamNames() []string { return r.paramNames }
+
+// StartTime returns when the App received the request, before any
+// middleware ran. It carries a monotonic reading, so time.Since is safe.
+func (r *Request) StartTime() time.Time { return r.start }
+
+// RoutePatternFromContext returns the pattern of the route handling the
+// request ctx belongs to, or "" if no route matched.
+func RoutePatternFromContext(ctx context.Context) string {
+	pattern, _ := ctx.Value(routePatternKey).(string)
+	return pattern
+}
+
 // RequestIDKey is the context key for the request ID.
 type requestIDKey struct{}
 
@@ -581,10 +833,35 @@ func GetRequestID(ctx context.Context) string {
 
 type responseWriter struct {
 	http.ResponseWriter
-	status int
+	status    int
+	hijacked  bool
+	streaming bool // headers and body already written by a streaming handler
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.status = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Hijack hands the connection to the caller; ServeHTTP then writes nothing.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("api: response writer does not support hijacking")
+	}
+	conn, brw, err := h.Hijack()
+	if err == nil {
+		rw.hijacked = true
+	}
+	return conn, brw, err
+}
+
+// Flush sends buffered data to the client if the underlying writer can.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rw *responseWriter) Unwrap() http.ResponseWriter { return rw.ResponseWriter }
@@ -0,0 +1,229 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// Contract validation middleware
+// --------------------------------------------------------------------
+
+// RouteSchema documents the JSON bodies of one route. Either schema may be
+// nil to leave that side unchecked.
+type RouteSchema struct {
+	Request  *ContractSchema
+	Response *ContractSchema
+	// Skip disables validation for the route, e.g. for streaming
+	// endpoints whose body is not a single JSON document.
+	Skip bool
+}
+
+// ContractOption configures Contract.
+type ContractOption func(*contractConfig)
+
+type contractConfig struct {
+	strict bool
+	logger *TestLogger
+}
+
+// WithContractStrict makes violations fail the request: a request body
+// that breaks its schema is answered with 400 before the handler runs, and
+// a response that breaks its schema is replaced with a 500 listing the
+// violations.
+func WithContractStrict() ContractOption {
+	return func(c *contractConfig) { c.strict = true }
+}
+
+// WithContractLogger sets the logger violations are reported to at ERROR.
+// Defaults to the package Default logger.
+func WithContractLogger(l *TestLogger) ContractOption {
+	return func(c *contractConfig) { c.logger = l }
+}
+
+// Contract validates JSON request and response bodies against the schemas
+// documented for each route. Keys are "METHOD pattern" using the router's
+// pattern syntax; a request matching several patterns uses the one with
+// the most static segments:
+//
+//	app.Use(Contract(map[string]RouteSchema{
+//		"GET /users/:id": {Response: userSchema},
+//		"POST /users":    {Request: newUserSchema, Response: userSchema},
+//		"GET /events":    {Skip: true},
+//	}, WithContractStrict()))
+//
+// Violations are always logged; only WithContractStrict changes responses.
+func Contract(routeSchemas map[string]RouteSchema, opts ...ContractOption) Middleware {
+	cfg := contractConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.logger == nil {
+		cfg.logger = Default()
+	}
+	routes := make([]contractRoute, 0, len(routeSchemas))
+	for key, schema := range routeSchemas {
+		method, pattern, ok := strings.Cut(strings.TrimSpace(key), " ")
+		if !ok {
+			panic(fmt.Sprintf("Contract: route key %q is not \"METHOD pattern\"", key))
+		}
+		routes = append(routes, contractRoute{
+			key:    key,
+			method: strings.ToUpper(method),
+			parts:  splitPath(strings.TrimSpace(pattern)),
+			schema: schema,
+		})
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			route := matchContractRoute(routes, req.Method, req.URL.Path)
+			if route == nil || route.schema.Skip {
+				return next(ctx, req)
+			}
+
+			if s := route.schema.Request; s != nil {
+				violations, err := validateRequestBody(req, s)
+				if err != nil {
+					return nil, err
+				}
+				if len(violations) > 0 {
+					cfg.report(req, route.key, "request", violations)
+					if cfg.strict {
+						return nil, contractViolationError(http.StatusBadRequest, "request", violations)
+					}
+				}
+			}
+
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil || route.schema.Response == nil {
+				return resp, err
+			}
+			if violations := validateResponseBody(resp, route.schema.Response); len(violations) > 0 {
+				cfg.report(req, route.key, "response", violations)
+				if cfg.strict {
+					return nil, contractViolationError(http.StatusInternalServerError, "response", violations)
+				}
+			}
+			return resp, nil
+		}
+	}
+}
+
+type contractRoute struct {
+	key    string
+	method string
+	parts  []string
+	schema RouteSchema
+}
+
+// matchContractRoute finds the most specific route for method and path.
+func matchContractRoute(routes []contractRoute, method, path string) *contractRoute {
+	parts := splitPath(path)
+	var best *contractRoute
+	bestStatic := -1
+	for i := range routes {
+		r := &routes[i]
+		if r.method != method {
+			continue
+		}
+		if static, ok := matchPatternParts(r.parts, parts); ok && static > bestStatic {
+			best, bestStatic = r, static
+		}
+	}
+	return best
+}
+
+// matchPatternParts matches path segments against pattern segments and
+// returns how many static segments matched.
+func matchPatternParts(pattern, path []string) (int, bool) {
+	static := 0
+	for i, p := range pattern {
+		if p == "*" {
+			return static, true
+		}
+		if i >= len(path) {
+			return 0, false
+		}
+		switch {
+		case strings.HasPrefix(p, ":"):
+		case p == path[i]:
+			static++
+		default:
+			return 0, false
+		}
+	}
+	return static, len(pattern) == len(path)
+}
+
+func (c *contractConfig) report(req *Request, route, side string, violations []string) {
+	c.logger.Error("contract violation", map[string]any{
+		"route":      route,
+		"side":       side,
+		"path":       req.URL.Path,
+		"request_id": req.RequestID,
+		"violations": violations,
+	})
+}
+
+// validateRequestBody checks the request body and puts it back for the
+// handler.
+func validateRequestBody(req *Request, s *ContractSchema) ([]string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []string{"$: request body is empty"}, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return s.ValidateJSON(data), nil
+}
+
+// validateResponseBody checks resp as it will be sent: RawBody as is, or
+// Body after JSON encoding, so struct tags are honoured.
+func validateResponseBody(resp *Response, s *ContractSchema) []string {
+	if resp.RawBody != nil {
+		return s.ValidateJSON(resp.RawBody)
+	}
+	data, err := json.Marshal(resp.Body)
+	if err != nil {
+		return []string{fmt.Sprintf("$: response body cannot be encoded: %v", err)}
+	}
+	return s.ValidateJSON(data)
+}
+
+// contractViolationError is rendered by the App's error handler, which
+// lists the violations ("violations", or "errors" with ProblemJSON).
+func contractViolationError(status int, side string, violations []string) error {
+	return &Error{
+		Code:       status,
+		Message:    side + " contract violation",
+		Cause:      ErrValidation,
+		Violations: violations,
+	}
+}
+
+// LoadContractSchema parses a JSON Schema file from the test directory.
+func (tdm *TestDataManager) LoadContractSchema(filename string) (*ContractSchema, error) {
+	path, err := tdm.resolvePath(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema %q: %w", filename, err)
+	}
+	s, err := ParseContractSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("schema %q: %w", filename, err)
+	}
+	return s, nil
+}
@@ -0,0 +1,149 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var userSchema = MustParseContractSchema(`{
+  "type": "object",
+  "required": ["id", "name", "tags"],
+  "additionalProperties": false,
+  "properties": {
+    "id":    {"type": "integer", "minimum": 1},
+    "name":  {"type": "string", "pattern": "^[a-z]+$"},
+    "email": {"type": ["string", "null"]},
+    "role":  {"type": "string", "enum": ["admin", "user"], "nullable": true},
+    "tags":  {"type": "array", "items": {"type": "string"}},
+    "meta":  {"type": "object", "additionalProperties": {"type": "number"}},
+    "address": {
+      "type": "object",
+      "required": ["city"],
+      "properties": {"city": {"type": "string"}}
+    }
+  }
+}`)
+
+func TestContractSchema_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{"valid", `{"id": 1, "name": "ann", "email": null, "role": null, "tags": ["a"], "meta": {"score": 1.5}}`, nil},
+		{"wrong types", `{"id": 1.5, "name": 7, "tags": "a"}`, []string{
+			"$.id: expected integer, got number",
+			"$.name: expected string, got integer",
+			"$.tags: expected array, got string",
+		}},
+		{"nested", `{"id": 1, "name": "ann", "tags": ["a", 2], "address": {"zip": "1"}, "meta": {"n": "x"}}`, []string{
+			"$.address: missing required property \"city\"",
+			"$.meta.n: expected number, got string",
+			"$.tags[1]: expected string, got integer",
+		}},
+		{"constraints", `{"id": 0, "name": "Ann", "role": "root", "tags": [], "extra": true}`, []string{
+			"$.extra: additional property not allowed",
+			"$.id: 0 is less than the minimum 1",
+			"$.name: \"Ann\" does not match pattern \"^[a-z]+$\"",
+			"$.role: value root is not one of [admin user]",
+		}},
+		{"null root", `null`, []string{"$: expected object, got null"}},
+	} {
+		got := userSchema.ValidateJSON([]byte(tc.doc))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n got %q\nwant %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := ParseContractSchema([]byte(`{"pattern": "("}`)); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+type contractUser struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+func contractApp(body any, opts ...ContractOption) (*App, *int) {
+	calls := 0
+	app := NewApp()
+	app.Use(Contract(map[string]RouteSchema{
+		"GET /users/:id": {Response: userSchema},
+		"GET /users/me":  {Skip: true},
+		"POST /users":    {Request: userSchema, Response: userSchema},
+	}, opts...))
+	handler := func(ctx context.Context, req *Request) (*Response, error) {
+		calls++
+		return JSON(http.StatusOK, body)
+	}
+	app.Get("/users/:id", handler)
+	app.Get("/users/me", handler)
+	app.Post("/users", handler)
+	return app, &calls
+}
+
+func serveContract(app *App, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func TestContract_ResponseViolations(t *testing.T) {
+	bad := map[string]any{"id": 1, "name": "ann"} // no tags
+	var logs bytes.Buffer
+	logger := NewTestLogger("contract", &logs)
+
+	app, _ := contractApp(bad, WithContractLogger(logger))
+	if rec := serveContract(app, http.MethodGet, "/users/1", ""); rec.Code != http.StatusOK {
+		t.Errorf("lenient mode changed the response: %d", rec.Code)
+	}
+	if !strings.Contains(logs.String(), "contract violation") || !strings.Contains(logs.String(), "ERROR") {
+		t.Errorf("violation not logged at ERROR:\n%s", logs.String())
+	}
+
+	app, _ = contractApp(bad, WithContractLogger(logger), WithContractStrict())
+	rec := serveContract(app, http.MethodGet, "/users/1", "")
+	var body struct {
+		Error      string   `json:"error"`
+		Violations []string `json:"violations"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusInternalServerError || body.Error != "response contract violation" ||
+		len(body.Violations) != 1 || body.Violations[0] != `$: missing required property "tags"` {
+		t.Errorf("strict: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// The static route is more specific than /users/:id and skips checks.
+	if rec := serveContract(app, http.MethodGet, "/users/me", ""); rec.Code != http.StatusOK {
+		t.Errorf("skipped route: %d", rec.Code)
+	}
+
+	good := contractUser{ID: 2, Name: "bob", Tags: []string{}}
+	app, _ = contractApp(good, WithContractLogger(logger), WithContractStrict())
+	if rec := serveContract(app, http.MethodGet, "/users/2", ""); rec.Code != http.StatusOK {
+		t.Errorf("valid struct body rejected: %s", rec.Body.String())
+	}
+}
+
+func TestContract_RequestViolations(t *testing.T) {
+	good := contractUser{ID: 3, Name: "cy", Tags: []string{"x"}}
+	app, calls := contractApp(good, WithContractLogger(NewTestLogger("contract", &bytes.Buffer{})), WithContractStrict())
+
+	rec := serveContract(app, http.MethodPost, "/users", `{"id": "3"}`)
+	if rec.Code != http.StatusBadRequest || *calls != 0 || !strings.Contains(rec.Body.String(), "request contract violation") {
+		t.Errorf("bad request: %d after %d calls: %s", rec.Code, *calls, rec.Body.String())
+	}
+
+	rec = serveContract(app, http.MethodPost, "/users", `{"id": 3, "name": "cy", "tags": []}`)
+	if rec.Code != http.StatusOK || *calls != 1 {
+		t.Errorf("good request: %d after %d calls: %s", rec.Code, *calls, rec.Body.String())
+	}
+}
@@ -0,0 +1,161 @@
+package testutils
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// Debug endpoints
+// --------------------------------------------------------------------
+
+// DebugOptions selects what App.MountDebug exposes. Endpoints whose source
+// is nil answer 404.
+type DebugOptions struct {
+	// Token, when set, must be sent as "Authorization: Bearer <token>" or
+	// in the X-Debug-Token header; other requests get 401.
+	Token string
+	// PortChecker backs /stats.
+	PortChecker *PortChecker
+	// Config backs /config; it is served redacted.
+	Config *Config
+	// Logger backs /ports with its port check history.
+	Logger *TestLogger
+	// Modes backs /mode.
+	Modes ModeManager
+	// AllowModeChange lets POST /mode switch the current mode.
+	AllowModeChange bool
+}
+
+// MountDebug registers introspection endpoints under prefix for operating
+// long-lived test environments:
+//
+//	GET  {prefix}/stats       PortChecker statistics and concurrency pool usage
+//	GET  {prefix}/config      the Config, with secrets redacted
+//	GET  {prefix}/ports       port check history, newest last (?limit=N)
+//	GET  {prefix}/goroutines  stack dump of every goroutine
+//	GET  {prefix}/mode        the current mode
+//	POST {prefix}/mode        {"mode": "degraded"}, if AllowModeChange is set
+func (a *App) MountDebug(prefix string, opts DebugOptions) *Group {
+	return a.Group(prefix, func(g *Group) {
+		if opts.Token != "" {
+			g.Use(debugAuth(opts.Token))
+		}
+		g.Get("/stats", opts.stats)
+		g.Get("/config", opts.config)
+		g.Get("/ports", opts.ports)
+		g.Get("/goroutines", debugGoroutines)
+		g.Get("/mode", opts.mode)
+		g.Post("/mode", opts.setMode)
+	})
+}
+
+// debugAuth rejects requests that do not carry token.
+func debugAuth(token string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			got := req.Request.Header.Get("X-Debug-Token")
+			if auth := req.Request.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+				got = strings.TrimPrefix(auth, "Bearer ")
+			}
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				return nil, &Error{Code: http.StatusUnauthorized, Message: "unauthorized"}
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+func debugNotConfigured(what string) error {
+	return &Error{Code: http.StatusNotFound, Message: what + " not configured"}
+}
+
+func (o DebugOptions) stats(ctx context.Context, req *Request) (*Response, error) {
+	if o.PortChecker == nil {
+		return nil, debugNotConfigured("port checker")
+	}
+	return JSON(http.StatusOK, map[string]any{
+		"port_checker": o.PortChecker.GetStats(),
+		"latency":      o.PortChecker.GetStats().LatencyStats(),
+		"pool":         o.PortChecker.PoolStats(),
+	})
+}
+
+func (o DebugOptions) config(ctx context.Context, req *Request) (*Response, error) {
+	if o.Config == nil {
+		return nil, debugNotConfigured("config")
+	}
+	return JSON(http.StatusOK, o.Config.Redacted())
+}
+
+func (o DebugOptions) ports(ctx context.Context, req *Request) (*Response, error) {
+	if o.Logger == nil {
+		return nil, debugNotConfigured("logger")
+	}
+	checks := o.Logger.GetPortCheckHistory()
+	ranges := o.Logger.GetPortRangeCheckHistory()
+	if s := req.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 0 {
+			return nil, &Error{Code: http.StatusBadRequest, Message: fmt.Sprintf("invalid limit %q", s)}
+		}
+		if len(checks) > limit {
+			checks = checks[len(checks)-limit:]
+		}
+		if len(ranges) > limit {
+			ranges = ranges[len(ranges)-limit:]
+		}
+	}
+	return JSON(http.StatusOK, map[string]any{
+		"checks":       checks,
+		"range_checks": ranges,
+	})
+}
+
+func debugGoroutines(ctx context.Context, req *Request) (*Response, error) {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return Text(http.StatusOK, fmt.Sprintf("goroutines: %d\n\n%s", runtime.NumGoroutine(), buf))
+}
+
+func (o DebugOptions) mode(ctx context.Context, req *Request) (*Response, error) {
+	if o.Modes == nil {
+		return nil, debugNotConfigured("mode manager")
+	}
+	return JSON(http.StatusOK, map[string]Mode{"mode": o.Modes.CurrentMode()})
+}
+
+func (o DebugOptions) setMode(ctx context.Context, req *Request) (*Response, error) {
+	if o.Modes == nil {
+		return nil, debugNotConfigured("mode manager")
+	}
+	if !o.AllowModeChange {
+		return nil, &Error{Code: http.StatusForbidden, Message: "mode changes are disabled"}
+	}
+	var body struct {
+		Mode Mode `json:"mode"`
+	}
+	if err := req.BindJSON(&body); err != nil {
+		return nil, &Error{Code: http.StatusBadRequest, Message: "invalid mode request", Cause: err}
+	}
+	switch body.Mode {
+	case ModeNormal, ModeDegraded, ModeReadOnly, ModeOffline, ModeFlaky, ModeMaintenance:
+	default:
+		return nil, &Error{Code: http.StatusBadRequest, Message: fmt.Sprintf("unknown mode %q", body.Mode)}
+	}
+	previous := o.Modes.CurrentMode()
+	o.Modes.SetMode(body.Mode)
+	return JSON(http.StatusOK, map[string]Mode{"mode": body.Mode, "previous": previous})
+}
@@ -0,0 +1,174 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func debugRequest(t *testing.T, app *App, method, target, token, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeDebug(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body, err)
+	}
+}
+
+func TestApp_MountDebugEndpoints(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{}, WithPortCheckerDialer(
+		func(ctx context.Context, network, address string) (net.Conn, error) {
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}))
+	if _, err := pc.IsPortOpen(context.Background(), "127.0.0.1", 8080, TCP); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Metrics.DefaultLabels["api_key"] = "s3cret"
+	cfg.Metrics.StatsDAddress = "statsd.internal:8125"
+
+	logger := NewTestLogger("debug", io.Discard)
+	for _, port := range []int{8080, 8081, 8082} {
+		logger.logPortCheck(PortCheckResult{Port: port, Protocol: "tcp", Success: port != 8081}, 0)
+	}
+
+	modes := NewMockModeManager(ModeNormal)
+	app := NewApp()
+	app.MountDebug("/debug", DebugOptions{
+		PortChecker:     pc,
+		Config:          cfg,
+		Logger:          logger,
+		Modes:           modes,
+		AllowModeChange: true,
+	})
+
+	var stats struct {
+		PortChecker struct {
+			ChecksCompleted int64 `json:"checks_completed"`
+		} `json:"port_checker"`
+		Pool PoolStats `json:"pool"`
+	}
+	decodeDebug(t, debugRequest(t, app, http.MethodGet, "/debug/stats", "", ""), &stats)
+	if stats.PortChecker.ChecksCompleted != 1 || stats.Pool.Capacity == 0 || stats.Pool.InUse != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	var redacted struct {
+		Metrics struct {
+			StatsDAddress string            `json:"statsd_address"`
+			DefaultLabels map[string]string `json:"default_labels"`
+		} `json:"metrics"`
+	}
+	decodeDebug(t, debugRequest(t, app, http.MethodGet, "/debug/config", "", ""), &redacted)
+	if got := redacted.Metrics.StatsDAddress; got != redactedValue {
+		t.Errorf("statsd_address served as %v, want %q", got, redactedValue)
+	}
+	if got := redacted.Metrics.DefaultLabels["api_key"]; got != redactedValue {
+		t.Errorf("api_key label served as %v, want %q", got, redactedValue)
+	}
+
+	var ports struct {
+		Checks []PortCheckResult `json:"checks"`
+	}
+	decodeDebug(t, debugRequest(t, app, http.MethodGet, "/debug/ports?limit=2", "", ""), &ports)
+	if len(ports.Checks) != 2 || ports.Checks[0].Port != 8081 || ports.Checks[1].Port != 8082 {
+		t.Errorf("ports?limit=2 = %+v, want the last two checks", ports.Checks)
+	}
+	if rec := debugRequest(t, app, http.MethodGet, "/debug/ports?limit=x", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d, want 400", rec.Code)
+	}
+
+	rec := debugRequest(t, app, http.MethodGet, "/debug/goroutines", "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "goroutine ") {
+		t.Errorf("goroutines: status %d, body %.200s", rec.Code, rec.Body)
+	}
+
+	var mode map[string]Mode
+	decodeDebug(t, debugRequest(t, app, http.MethodPost, "/debug/mode", "", `{"mode": "degraded"}`), &mode)
+	if mode["mode"] != ModeDegraded || mode["previous"] != ModeNormal || modes.CurrentMode() != ModeDegraded {
+		t.Errorf("POST /mode = %v, manager in %s", mode, modes.CurrentMode())
+	}
+	decodeDebug(t, debugRequest(t, app, http.MethodGet, "/debug/mode", "", ""), &mode)
+	if mode["mode"] != ModeDegraded {
+		t.Errorf("GET /mode = %v", mode)
+	}
+	if rec := debugRequest(t, app, http.MethodPost, "/debug/mode", "", `{"mode": "sideways"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode: status %d, want 400", rec.Code)
+	}
+}
+
+func TestApp_MountDebugRejectsMissingToken(t *testing.T) {
+	modes := NewMockModeManager(ModeNormal)
+	app := NewApp()
+	app.MountDebug("/debug", DebugOptions{Token: "s3cret", Modes: modes})
+
+	for _, token := range []string{"", "wrong"} {
+		for _, path := range []string{"/debug/stats", "/debug/goroutines", "/debug/mode"} {
+			if rec := debugRequest(t, app, http.MethodGet, path, token, ""); rec.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s with token %q: status %d, want 401", path, token, rec.Code)
+			}
+		}
+	}
+
+	if rec := debugRequest(t, app, http.MethodGet, "/debug/mode", "s3cret", ""); rec.Code != http.StatusOK {
+		t.Errorf("bearer token: status %d, want 200", rec.Code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/mode", nil)
+	req.Header.Set("X-Debug-Token", "s3cret")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("X-Debug-Token: status %d, want 200", rec.Code)
+	}
+
+	// Mode changes stay off unless allowed, and unset sources answer 404.
+	if rec := debugRequest(t, app, http.MethodPost, "/debug/mode", "s3cret", `{"mode": "offline"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /mode without AllowModeChange: status %d, want 403", rec.Code)
+	}
+	if modes.CurrentMode() != ModeNormal {
+		t.Errorf("mode changed to %s", modes.CurrentMode())
+	}
+	if rec := debugRequest(t, app, http.MethodGet, "/debug/config", "s3cret", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unset config: status %d, want 404", rec.Code)
+	}
+}
+
+func TestPortCheckerStats_MarshalJSON(t *testing.T) {
+	s := NewPortCheckerStats()
+	s.Record(&ConnectionResult{Open: true, Protocol: TCP})
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["checks_succeeded"] != float64(1) {
+		t.Errorf("marshalled %s", data)
+	}
+}
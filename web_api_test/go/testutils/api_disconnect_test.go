@@ -0,0 +1,123 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// writeSpy records whether anything was written to the response.
+type writeSpy struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *writeSpy) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *writeSpy) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(p)
+}
+
+// disconnectServer serves app and reports on done, once ServeHTTP has
+// returned without panicking, whether it wrote a response.
+func disconnectServer(t *testing.T, app *App) (*httptest.Server, <-chan bool) {
+	t.Helper()
+	done := make(chan bool, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Errorf("ServeHTTP panicked: %v", p)
+			}
+		}()
+		spy := &writeSpy{ResponseWriter: w}
+		app.ServeHTTP(spy, r)
+		done <- spy.wrote
+	}))
+	t.Cleanup(srv.Close)
+	return srv, done
+}
+
+func TestApp_ClientDisconnectCancelsHandler(t *testing.T) {
+	started := make(chan struct{})
+	observed := make(chan bool, 1)
+	app := NewApp()
+	app.Get("/slow", func(ctx context.Context, req *Request) (*Response, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			observed <- IsClientGone(ctx)
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			observed <- false
+			return Text(http.StatusOK, "too late")
+		}
+	})
+	srv, done := disconnectServer(t, app)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := NewHTTPTestClient(srv.URL).Get(ctx, "/slow")
+		errc <- err
+	}()
+	<-started
+	cancel()
+
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the client to see its own cancellation, got %v", err)
+	}
+	if !<-observed {
+		t.Error("handler did not observe the client going away")
+	}
+	if wrote := <-done; wrote {
+		t.Error("expected nothing to be written for a cancelled request")
+	}
+}
+
+func TestApp_ResponseAfterDisconnectIsDropped(t *testing.T) {
+	started := make(chan struct{})
+	gone := make(chan struct{})
+	app := NewApp()
+	app.Get("/ignores-cancel", func(ctx context.Context, req *Request) (*Response, error) {
+		close(started)
+		<-ctx.Done()
+		close(gone)
+		// A handler that carries on regardless still returns a response.
+		return JSON(http.StatusOK, map[string]string{"status": "done"})
+	})
+	srv, done := disconnectServer(t, app)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go NewHTTPTestClient(srv.URL).Get(ctx, "/ignores-cancel")
+	<-started
+	cancel()
+	<-gone
+
+	if wrote := <-done; wrote {
+		t.Error("expected the response to a departed client to be dropped")
+	}
+}
+
+func TestIsClientGone(t *testing.T) {
+	if IsClientGone(context.Background()) {
+		t.Error("live context reported as gone")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if IsClientGone(ctx) {
+		t.Error("an expired deadline is not a disconnect")
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if !IsClientGone(ctx) {
+		t.Error("cancelled context not reported as gone")
+	}
+}
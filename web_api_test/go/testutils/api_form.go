@@ -0,0 +1,247 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Form binding
+// --------------------------------------------------------------------
+
+// DefaultMultipartMemory is how much of a multipart form BindForm keeps in
+// memory before spilling file parts to temporary files. App.SetMultipartMemory
+// overrides it.
+const DefaultMultipartMemory = 32 << 20 // 32 MB
+
+// UploadedFile is a file part bound from a multipart form.
+type UploadedFile struct {
+	Header *multipart.FileHeader
+	Size   int64
+}
+
+// Filename returns the client-supplied file name.
+func (f *UploadedFile) Filename() string { return f.Header.Filename }
+
+// ContentType returns the Content-Type the client sent for the part.
+func (f *UploadedFile) ContentType() string { return f.Header.Header.Get("Content-Type") }
+
+// Open opens the file contents. The caller closes it.
+func (f *UploadedFile) Open() (multipart.File, error) { return f.Header.Open() }
+
+var (
+	uploadedFileType = reflect.TypeOf(UploadedFile{})
+	timeType         = reflect.TypeOf(time.Time{})
+)
+
+// BindForm parses an application/x-www-form-urlencoded or multipart/form-data
+// body (plus the query string) into the struct v points to. Fields are
+// matched by their `form:"name"` tag, or by field name when untagged; a tag
+// of "-" skips the field. Supported field types are strings, bools, ints,
+// uints, floats, time.Duration, time.Time (parsed with the `layout` tag,
+// RFC 3339 by default), pointers to those, slices of them (from repeated
+// fields), and UploadedFile, *UploadedFile or []*UploadedFile for file parts.
+// Fields with no value in the form are left untouched.
+//
+// Malformed values fail with a 400 *Error and bodies over
+// App.SetMaxBodyBytes with a 413 *Error, so handlers can return the error
+// as is.
+func (r *Request) BindForm(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("api: BindForm needs a non-nil pointer to a struct, got %T", v)
+	}
+	if err := r.parseForm(); err != nil {
+		return err
+	}
+	var files map[string][]*multipart.FileHeader
+	if r.MultipartForm != nil {
+		files = r.MultipartForm.File
+	}
+	return bindFormStruct(rv.Elem(), r.Form, files)
+}
+
+// parseForm parses the body according to its Content-Type.
+func (r *Request) parseForm() error {
+	var err error
+	ct, _, _ := mime.ParseMediaType(r.Request.Header.Get("Content-Type"))
+	if ct == "multipart/form-data" {
+		mem := r.multipartMemory
+		if mem <= 0 {
+			mem = DefaultMultipartMemory
+		}
+		err = r.ParseMultipartForm(mem)
+	} else {
+		err = r.ParseForm()
+	}
+	if err == nil {
+		return nil
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &Error{Code: http.StatusRequestEntityTooLarge, Message: "request body too large", Cause: err}
+	}
+	return &Error{Code: http.StatusBadRequest, Message: "malformed form body", Cause: err}
+}
+
+func bindFormStruct(sv reflect.Value, values map[string][]string, files map[string][]*multipart.FileHeader) error {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		fv := sv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if err := bindFormStruct(fv, values, files); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name := sf.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if isUploadField(sf.Type) {
+			bindFiles(fv, files[name])
+			continue
+		}
+		vals := values[name]
+		if len(vals) == 0 {
+			continue
+		}
+		if err := setFormField(fv, vals, sf.Tag.Get("layout")); err != nil {
+			return &Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("invalid value for form field %q", name),
+				Cause:   err,
+			}
+		}
+	}
+	return nil
+}
+
+func isUploadField(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t == uploadedFileType
+}
+
+// bindFiles sets an UploadedFile, *UploadedFile, []UploadedFile or
+// []*UploadedFile field from the parts sent under its name.
+func bindFiles(fv reflect.Value, headers []*multipart.FileHeader) {
+	if len(headers) == 0 {
+		return
+	}
+	upload := func(h *multipart.FileHeader) *UploadedFile {
+		return &UploadedFile{Header: h, Size: h.Size}
+	}
+	switch t := fv.Type(); {
+	case t == uploadedFileType:
+		fv.Set(reflect.ValueOf(*upload(headers[0])))
+	case t.Kind() == reflect.Pointer:
+		fv.Set(reflect.ValueOf(upload(headers[0])))
+	case t.Kind() == reflect.Slice:
+		out := reflect.MakeSlice(t, len(headers), len(headers))
+		for i, h := range headers {
+			if t.Elem().Kind() == reflect.Pointer {
+				out.Index(i).Set(reflect.ValueOf(upload(h)))
+			} else {
+				out.Index(i).Set(reflect.ValueOf(*upload(h)))
+			}
+		}
+		fv.Set(out)
+	}
+}
+
+// setFormField coerces vals into fv. Slices take every value; other kinds
+// take the first.
+func setFormField(fv reflect.Value, vals []string, layout string) error {
+	if fv.Kind() == reflect.Slice {
+		out := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setFormScalar(out.Index(i), s, layout); err != nil {
+				return err
+			}
+		}
+		fv.Set(out)
+		return nil
+	}
+	if fv.Kind() == reflect.Pointer {
+		p := reflect.New(fv.Type().Elem())
+		if err := setFormScalar(p.Elem(), vals[0], layout); err != nil {
+			return err
+		}
+		fv.Set(p)
+		return nil
+	}
+	return setFormScalar(fv, vals[0], layout)
+}
+
+func setFormScalar(fv reflect.Value, s string, layout string) error {
+	switch fv.Type() {
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+		return nil
+	case timeType:
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return err
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			// HTML checkboxes post "on" when ticked.
+			if s != "on" {
+				return err
+			}
+			b = true
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(s), 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type searchForm struct {
+	Query    string        `form:"q"`
+	Page     int           `form:"page"`
+	Limit    *uint16       `form:"limit"`
+	Exact    bool          `form:"exact"`
+	Tags     []string      `form:"tag"`
+	IDs      []int64       `form:"id"`
+	Timeout  time.Duration `form:"timeout"`
+	Since    time.Time     `form:"since" layout:"2006-01-02"`
+	Ratio    float64
+	Internal string `form:"-"`
+}
+
+func postForm(t *testing.T, app *App, target string, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRequest_BindFormURLEncoded(t *testing.T) {
+	var got searchForm
+	app := NewApp()
+	app.Post("/search", func(ctx context.Context, req *Request) (*Response, error) {
+		got = searchForm{Internal: "kept"}
+		if err := req.BindForm(&got); err != nil {
+			return nil, err
+		}
+		return NoContent()
+	})
+
+	rec := postForm(t, app, "/search?page=3", url.Values{
+		"q":        {"go"},
+		"limit":    {"50"},
+		"exact":    {"on"},
+		"tag":      {"a", "b"},
+		"id":       {"1", "2", "3"},
+		"timeout":  {"1.5s"},
+		"since":    {"2024-02-29"},
+		"Ratio":    {"0.25"},
+		"Internal": {"overwritten"},
+	})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	limit := uint16(50)
+	want := searchForm{
+		Query:    "go",
+		Page:     3,
+		Limit:    &limit,
+		Exact:    true,
+		Tags:     []string{"a", "b"},
+		IDs:      []int64{1, 2, 3},
+		Timeout:  1500 * time.Millisecond,
+		Since:    time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		Ratio:    0.25,
+		Internal: "kept",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bound %+v\nwant  %+v", got, want)
+	}
+
+	rec = postForm(t, app, "/search", url.Values{"page": {"two"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("bad int: status %d, want 400", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `form field \"page\"`) {
+		t.Errorf("bad int: body %s does not name the field", rec.Body)
+	}
+}
+
+func TestRequest_BindFormRejectsNonStruct(t *testing.T) {
+	req := &Request{Request: httptest.NewRequest(http.MethodPost, "/", nil)}
+	var s string
+	if err := req.BindForm(&s); err == nil {
+		t.Error("expected an error binding into a *string")
+	}
+}
+
+type uploadForm struct {
+	Description string        `form:"description"`
+	File        *UploadedFile `form:"file"`
+}
+
+var allowedUploadExt = map[string]bool{".txt": true, ".json": true, ".csv": true}
+
+// uploadApp mirrors the /upload endpoint the integration tests exercise.
+func uploadApp() *App {
+	app := NewApp()
+	app.Post("/upload", func(ctx context.Context, req *Request) (*Response, error) {
+		var form uploadForm
+		if err := req.BindForm(&form); err != nil {
+			return nil, err
+		}
+		switch {
+		case form.File == nil:
+			return nil, &Error{Code: http.StatusBadRequest, Message: "no file uploaded"}
+		case form.File.Size == 0:
+			return nil, &Error{Code: http.StatusBadRequest, Message: "empty file"}
+		case !allowedUploadExt[strings.ToLower(filepath.Ext(form.File.Filename()))]:
+			return nil, &Error{Code: http.StatusUnsupportedMediaType, Message: "unsupported file type"}
+		}
+		f, err := form.File.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return JSON(http.StatusOK, map[string]any{
+			"filename":    form.File.Filename(),
+			"size":        form.File.Size,
+			"content":     string(data),
+			"description": form.Description,
+		})
+	})
+	return app
+}
+
+func postMultipart(t *testing.T, app *App, prepare func(*multipart.Writer) error) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if err := prepare(w); err != nil {
+		t.Fatalf("prepare form: %v", err)
+	}
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec
+}
+
+func formFile(name, content string) func(*multipart.Writer) error {
+	return func(w *multipart.Writer) error {
+		part, err := w.CreateFormFile("file", name)
+		if err != nil {
+			return err
+		}
+		_, err = part.Write([]byte(content))
+		return err
+	}
+}
+
+func TestRequest_BindFormUploadScenarios(t *testing.T) {
+	app := uploadApp()
+	for _, tc := range []struct {
+		name    string
+		prepare func(*multipart.Writer) error
+		status  int
+	}{
+		{"valid file", formFile("notes.txt", "hello"), http.StatusOK},
+		{"missing file", func(w *multipart.Writer) error {
+			return w.WriteField("description", "Upload without file")
+		}, http.StatusBadRequest},
+		{"empty file", formFile("empty.txt", ""), http.StatusBadRequest},
+		{"unsupported file type", formFile("script.exe", "executable content"), http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postMultipart(t, app, tc.prepare)
+			if rec.Code != tc.status {
+				t.Errorf("status %d, want %d: %s", rec.Code, tc.status, rec.Body)
+			}
+		})
+	}
+
+	rec := postMultipart(t, app, formFile("notes.txt", "hello"))
+	if got := rec.Body.String(); !strings.Contains(got, `"content":"hello"`) || !strings.Contains(got, `"size":5`) {
+		t.Errorf("valid upload body = %s", got)
+	}
+}
+
+func TestRequest_BindFormMultipleFiles(t *testing.T) {
+	var got struct {
+		Files []*UploadedFile `form:"file"`
+		Note  string          `form:"note"`
+	}
+	app := NewApp()
+	app.SetMultipartMemory(1) // force file parts to disk
+	var second []byte
+	app.Post("/upload", func(ctx context.Context, req *Request) (*Response, error) {
+		if err := req.BindForm(&got); err != nil {
+			return nil, err
+		}
+		// Spilled parts are removed once the handler returns.
+		f, err := got.Files[len(got.Files)-1].Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		second, err = io.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		return NoContent()
+	})
+	rec := postMultipart(t, app, func(w *multipart.Writer) error {
+		for _, f := range []func(*multipart.Writer) error{formFile("a.txt", "aa"), formFile("b.txt", "bbb")} {
+			if err := f(w); err != nil {
+				return err
+			}
+		}
+		return w.WriteField("note", "two")
+	})
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(got.Files) != 2 || got.Files[0].Filename() != "a.txt" || got.Files[1].Size != 3 || got.Note != "two" {
+		t.Fatalf("bound %+v", got)
+	}
+	if string(second) != "bbb" {
+		t.Errorf("second file contents %q, want bbb", second)
+	}
+}
+
+func TestRequest_BindFormRespectsMaxBodyBytes(t *testing.T) {
+	app := uploadApp()
+	app.SetMaxBodyBytes(64)
+	rec := postMultipart(t, app, formFile("big.txt", strings.Repeat("x", 1024)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want 413: %s", rec.Code, rec.Body)
+	}
+
+	var apiErr *Error
+	req := &Request{Request: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("a=1&a=2"))}
+	req.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Body = http.MaxBytesReader(nil, req.Body, 3)
+	if err := req.BindForm(&struct{ A []int }{}); !errors.As(err, &apiErr) || apiErr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("urlencoded over limit: err = %v, want 413 *Error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute labels requests that matched no route, so probing random
+// paths cannot grow the number of series.
+const unmatchedRoute = "unmatched"
+
+// Metrics records per-route request counts, error counts, latencies and
+// in-flight requests into registry:
+//
+//	http_requests_total{method,route,status}      status is the class, "2xx"
+//	http_request_errors_total{method,route}       5xx, including handler errors
+//	http_request_duration_seconds{method,route}   histogram
+//	http_requests_in_flight                       gauge
+//
+// Routes are labelled by their registered pattern ("/users/:id"), never
+// the raw path. Use it as a global middleware to see requests that match
+// no route too; they share the route label "unmatched". Mount
+// registry.Handler() with HandleRaw to expose the series.
+func Metrics(registry *MetricsServer) Middleware {
+	requests := registry.Counter("http_requests_total", "HTTP requests by route and status class.", "method", "route", "status")
+	failures := registry.Counter("http_request_errors_total", "HTTP requests answered with a 5xx status.", "method", "route")
+	durations := registry.Histogram("http_request_duration_seconds", "HTTP request latency by route.", "method", "route")
+	inFlight := registry.Gauge("http_requests_in_flight", "HTTP requests being handled.")
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			route := req.route
+			if route == "" {
+				route = unmatchedRoute
+			}
+			inFlight.Inc()
+			defer inFlight.Dec()
+			start := time.Now()
+			resp, err := next(ctx, req)
+			elapsed := time.Since(start)
+
+			status := responseStatus(resp, err)
+			if errors.Is(err, context.Canceled) || IsClientGone(ctx) {
+				status = StatusClientClosedRequest
+			}
+			requests.Inc(req.Method, route, strconv.Itoa(status/100)+"xx")
+			if status >= 500 {
+				failures.Inc(req.Method, route)
+			}
+			durations.Observe(elapsed.Seconds(), req.Method, route)
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_PerRouteSeries(t *testing.T) {
+	cfg := DefaultConfig().Metrics
+	cfg.DefaultLabels = map[string]string{"app": "metrics-test"}
+	cfg.HistogramBuckets = []float64{0.5, 10}
+	registry := NewMetricsServer(cfg)
+
+	app := NewApp()
+	app.Use(Metrics(registry))
+	app.HandleRaw("/metrics", registry.Handler())
+	app.Get("/users/:id", func(ctx context.Context, req *Request) (*Response, error) {
+		if req.PathParams["id"] == "missing" {
+			return nil, &Error{Code: http.StatusNotFound, Message: "no such user"}
+		}
+		return JSON(http.StatusOK, map[string]string{"id": req.PathParams["id"]})
+	})
+	app.Post("/users", func(ctx context.Context, req *Request) (*Response, error) {
+		return JSON(http.StatusCreated, nil)
+	})
+	app.Get("/boom", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, errors.New("database unavailable")
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	client := NewHTTPTestClient(srv.URL)
+	ctx := context.Background()
+	script := []struct{ method, path string }{
+		{http.MethodGet, "/users/1"},
+		{http.MethodGet, "/users/2"},
+		{http.MethodGet, "/users/3"},
+		{http.MethodGet, "/users/missing"},
+		{http.MethodPost, "/users"},
+		{http.MethodGet, "/boom"},
+		{http.MethodGet, "/boom"},
+		{http.MethodGet, "/probe/1"},
+		{http.MethodGet, "/probe/2"},
+	}
+	for _, step := range script {
+		resp, err := client.Do(ctx, step.method, step.path, nil)
+		if err != nil {
+			t.Fatalf("%s %s: %v", step.method, step.path, err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := client.Get(ctx, "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	scrape := string(data)
+
+	for _, want := range []string{
+		`# TYPE http_requests_total counter`,
+		`http_requests_total{app="metrics-test",method="GET",route="/users/:id",status="2xx"} 3`,
+		`http_requests_total{app="metrics-test",method="GET",route="/users/:id",status="4xx"} 1`,
+		`http_requests_total{app="metrics-test",method="POST",route="/users",status="2xx"} 1`,
+		`http_requests_total{app="metrics-test",method="GET",route="/boom",status="5xx"} 2`,
+		`http_requests_total{app="metrics-test",method="GET",route="unmatched",status="4xx"} 2`,
+		`http_request_errors_total{app="metrics-test",method="GET",route="/boom"} 2`,
+		`# TYPE http_request_duration_seconds histogram`,
+		`http_request_duration_seconds_bucket{app="metrics-test",method="GET",route="/users/:id",le="10"} 4`,
+		`http_request_duration_seconds_bucket{app="metrics-test",method="GET",route="/users/:id",le="+Inf"} 4`,
+		`http_request_duration_seconds_count{app="metrics-test",method="GET",route="/users/:id"} 4`,
+		`http_requests_in_flight{app="metrics-test"} 0`,
+	} {
+		if !strings.Contains(scrape, want+"\n") {
+			t.Errorf("scrape is missing %q", want)
+		}
+	}
+	for _, raw := range []string{"/users/1", "/probe/1", `route="/metrics"`} {
+		if strings.Contains(scrape, raw) {
+			t.Errorf("scrape should not mention %s:\n%s", raw, scrape)
+		}
+	}
+	if strings.Contains(scrape, `http_request_errors_total{app="metrics-test",method="GET",route="/users/:id"}`) {
+		t.Error("a 404 must not count as an error")
+	}
+}
+
+func TestMetricsServer_RegistrationAndFormat(t *testing.T) {
+	registry := NewMetricsServer(MetricsConfig{HistogramBuckets: []float64{1, 0.1}})
+	jobs := registry.Counter("jobs_total", "Jobs run.", "queue")
+	if again := registry.Counter("jobs_total", "", "queue"); again.family != jobs.family {
+		t.Error("re-registering a counter should return the same metric")
+	}
+	jobs.Add(2, `say "hi"`)
+	jobs.Inc(`say "hi"`)
+	if got := jobs.Value(`say "hi"`); got != 3 {
+		t.Errorf("expected 3, got %v", got)
+	}
+	latency := registry.Histogram("latency_seconds", "")
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(2)
+
+	want := `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+jobs_total{queue="say \"hi\""} 3
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 2.55
+latency_seconds_count 3
+`
+	if got := registry.String(); got != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected re-registering jobs_total as a gauge to panic")
+		}
+	}()
+	registry.Gauge("jobs_total", "", "queue")
+}
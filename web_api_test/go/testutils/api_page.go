@@ -0,0 +1,166 @@
+package testutils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// Pagination
+// --------------------------------------------------------------------
+
+// PageDefaults configures Paginate for one endpoint.
+type PageDefaults struct {
+	// Limit is used when the request has no limit parameter. Default 20.
+	Limit int
+	// MaxLimit is the largest limit a client may ask for. Default 100.
+	MaxLimit int
+	// CursorKey signs cursors. When empty, cursor mode is disabled: the
+	// cursor parameter is rejected and ListResponse emits no next_cursor.
+	CursorKey []byte
+}
+
+func (d PageDefaults) withDefaults() PageDefaults {
+	if d.Limit <= 0 {
+		d.Limit = 20
+	}
+	if d.MaxLimit <= 0 {
+		d.MaxLimit = 100
+	}
+	if d.Limit > d.MaxLimit {
+		d.Limit = d.MaxLimit
+	}
+	return d
+}
+
+// Page is the window a list request asks for. In cursor mode Offset is
+// decoded from Cursor, so handlers can always slice by Limit and Offset.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor string // as sent by the client; empty in offset mode
+
+	key []byte // signs next_cursor in ListResponse
+}
+
+// Paginate reads the limit, offset and cursor query parameters. A cursor
+// replaces offset; sending both, a limit outside 1..MaxLimit, a negative
+// offset or a cursor that fails its signature check yields a 400 *Error.
+func Paginate(req *Request, defaults PageDefaults) (Page, error) {
+	d := defaults.withDefaults()
+	q := req.URL.Query()
+	page := Page{Limit: d.Limit, key: d.CursorKey}
+
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > d.MaxLimit {
+			return Page{}, pageError("limit must be an integer between 1 and %d, got %q", d.MaxLimit, s)
+		}
+		page.Limit = n
+	}
+
+	offset, cursor := q.Get("offset"), q.Get("cursor")
+	switch {
+	case offset != "" && cursor != "":
+		return Page{}, pageError("offset and cursor cannot be combined")
+	case cursor != "":
+		if len(d.CursorKey) == 0 {
+			return Page{}, pageError("cursor pagination is not supported here")
+		}
+		n, err := decodeCursor(d.CursorKey, cursor)
+		if err != nil {
+			return Page{}, pageError("invalid cursor")
+		}
+		page.Offset, page.Cursor = n, cursor
+	case offset != "":
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return Page{}, pageError("offset must be a non-negative integer, got %q", offset)
+		}
+		page.Offset = n
+	}
+	return page, nil
+}
+
+func pageError(format string, args ...any) error {
+	return &Error{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// ListEnvelope is the body ListResponse produces.
+type ListEnvelope struct {
+	Items      any    `json:"items"`
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListResponse returns a 200 JSON envelope for one page of a list of total
+// items. A nil slice is sent as []. next_cursor is set when cursor mode is
+// enabled and more items follow.
+func ListResponse(items any, page Page, total int64) (*Response, error) {
+	if v := reflect.ValueOf(items); !v.IsValid() || (v.Kind() == reflect.Slice && v.IsNil()) {
+		items = []any{}
+	}
+	env := ListEnvelope{Items: items, Total: total, Limit: page.Limit, Offset: page.Offset}
+	if next := page.Offset + page.Limit; len(page.key) > 0 && int64(next) < total {
+		env.NextCursor = encodeCursor(page.key, next)
+	}
+	return JSON(http.StatusOK, env)
+}
+
+// PageItems returns the part of all that page covers.
+func PageItems[T any](all []T, page Page) []T {
+	if page.Offset >= len(all) {
+		return []T{}
+	}
+	end := page.Offset + page.Limit
+	if end > len(all) || end < page.Offset {
+		end = len(all)
+	}
+	return all[page.Offset:end]
+}
+
+// Cursors are base64url(payload) "." base64url(HMAC-SHA256(key, payload)),
+// with payload "o:<offset>". The signature stops clients from forging
+// positions; the payload is not secret.
+func encodeCursor(key []byte, offset int) string {
+	payload := []byte("o:" + strconv.Itoa(offset))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(cursorMAC(key, payload))
+}
+
+func decodeCursor(key []byte, cursor string) (int, error) {
+	p, s, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return 0, fmt.Errorf("malformed cursor")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(p)
+	if err != nil {
+		return 0, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	if !hmac.Equal(sig, cursorMAC(key, payload)) {
+		return 0, fmt.Errorf("cursor signature mismatch")
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(string(payload), "o:"))
+	if err != nil || n < 0 || !strings.HasPrefix(string(payload), "o:") {
+		return 0, fmt.Errorf("malformed cursor payload")
+	}
+	return n, nil
+}
+
+func cursorMAC(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
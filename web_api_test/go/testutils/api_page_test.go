@@ -0,0 +1,138 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type pageUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type userList struct {
+	Items      []pageUser `json:"items"`
+	Total      int64      `json:"total"`
+	Limit      int        `json:"limit"`
+	Offset     int        `json:"offset"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+// usersApp serves /users over n users, the way a list endpoint would use
+// Paginate and ListResponse.
+func usersApp(n int, defaults PageDefaults) *App {
+	users := make([]pageUser, n)
+	for i := range users {
+		users[i] = pageUser{ID: i + 1, Name: fmt.Sprintf("user%d", i+1)}
+	}
+	app := NewApp()
+	app.Get("/users", func(ctx context.Context, req *Request) (*Response, error) {
+		page, err := Paginate(req, defaults)
+		if err != nil {
+			return nil, err
+		}
+		return ListResponse(PageItems(users, page), page, int64(len(users)))
+	})
+	return app
+}
+
+func getUsers(t *testing.T, app *App, query url.Values) (int, userList, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users?"+query.Encode(), nil))
+	var list userList
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+			t.Fatalf("decode %s: %v", rec.Body, err)
+		}
+	}
+	return rec.Code, list, rec.Body.String()
+}
+
+func TestPaginate_OffsetMode(t *testing.T) {
+	app := usersApp(45, PageDefaults{Limit: 10, MaxLimit: 20})
+
+	code, list, body := getUsers(t, app, nil)
+	if code != http.StatusOK || len(list.Items) != 10 || list.Limit != 10 || list.Offset != 0 || list.Total != 45 {
+		t.Fatalf("defaults: %d %s", code, body)
+	}
+	if list.NextCursor != "" || strings.Contains(body, "next_cursor") {
+		t.Errorf("next_cursor sent without a cursor key: %s", body)
+	}
+
+	code, list, body = getUsers(t, app, url.Values{"limit": {"20"}, "offset": {"40"}})
+	if code != http.StatusOK || len(list.Items) != 5 || list.Items[0].ID != 41 || list.Offset != 40 {
+		t.Fatalf("last page: %d %s", code, body)
+	}
+
+	code, list, body = getUsers(t, app, url.Values{"offset": {"100"}})
+	if code != http.StatusOK || list.Items == nil || len(list.Items) != 0 || !strings.Contains(body, `"items":[]`) {
+		t.Errorf("past the end: %d %s", code, body)
+	}
+
+	for _, q := range []url.Values{
+		{"limit": {"0"}},
+		{"limit": {"21"}},
+		{"limit": {"ten"}},
+		{"offset": {"-1"}},
+		{"offset": {"x"}},
+		{"cursor": {"abc"}},
+	} {
+		if code, _, body := getUsers(t, app, q); code != http.StatusBadRequest {
+			t.Errorf("%v: status %d, want 400: %s", q, code, body)
+		}
+	}
+}
+
+func TestPaginate_CursorMode(t *testing.T) {
+	app := usersApp(45, PageDefaults{Limit: 20, CursorKey: []byte("page-secret")})
+
+	var ids []int
+	query := url.Values{}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("cursor never ran out")
+		}
+		code, list, body := getUsers(t, app, query)
+		if code != http.StatusOK {
+			t.Fatalf("page %d: %d %s", pages, code, body)
+		}
+		for _, u := range list.Items {
+			ids = append(ids, u.ID)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		query = url.Values{"cursor": {list.NextCursor}}
+	}
+	if len(ids) != 45 || ids[0] != 1 || ids[44] != 45 {
+		t.Fatalf("walked %d users: %v", len(ids), ids)
+	}
+
+	_, first, _ := getUsers(t, app, nil)
+	cursor := first.NextCursor
+	payload, sig, _ := strings.Cut(cursor, ".")
+	forged := encodeCursor([]byte("other-key"), 40)
+	for name, q := range map[string]url.Values{
+		"tampered payload":  {"cursor": {strings.Replace(cursor, payload, "bzo0MA", 1)}}, // "o:40"
+		"tampered sig":      {"cursor": {payload + "." + strings.ToUpper(sig)}},
+		"wrong key":         {"cursor": {forged}},
+		"garbage":           {"cursor": {"not-a-cursor"}},
+		"cursor and offset": {"cursor": {cursor}, "offset": {"5"}},
+	} {
+		if code, _, body := getUsers(t, app, q); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400: %s", name, code, body)
+		}
+	}
+
+	// Offset mode still works alongside cursors.
+	if code, list, _ := getUsers(t, app, url.Values{"offset": {"20"}, "limit": {"5"}}); code != http.StatusOK || list.Items[0].ID != 21 || list.NextCursor == "" {
+		t.Errorf("offset with cursor key: %d %+v", code, list)
+	}
+}
@@ -0,0 +1,225 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// --------------------------------------------------------------------
+// RFC 7807 problem details
+// --------------------------------------------------------------------
+
+// ProblemContentType is the media type of a Problem body.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Extensions are written as
+// top-level members next to the standard ones, which they cannot replace.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// MarshalJSON flattens Extensions into the object.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+	out["type"] = p.Type
+	out["title"] = p.Title
+	out["status"] = p.Status
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads the standard members and keeps the rest as
+// Extensions.
+func (p *Problem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*p = Problem{}
+	for key, dst := range map[string]any{
+		"type": &p.Type, "title": &p.Title, "status": &p.Status,
+		"detail": &p.Detail, "instance": &p.Instance,
+	} {
+		if v, ok := raw[key]; ok {
+			if err := json.Unmarshal(v, dst); err != nil {
+				return err
+			}
+			delete(raw, key)
+		}
+	}
+	for k, v := range raw {
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]any, len(raw))
+		}
+		var ext any
+		if err := json.Unmarshal(v, &ext); err != nil {
+			return err
+		}
+		p.Extensions[k] = ext
+	}
+	return nil
+}
+
+// ProblemOption configures ProblemJSON.
+type ProblemOption func(*problemConfig)
+
+type problemConfig struct {
+	typeBase string
+}
+
+// WithProblemTypeBase sets the URI prefix of the type of errors rendered
+// from their kind: with "https://errors.example.com/", a validation error
+// has type "https://errors.example.com/validation". Without it they use
+// "about:blank".
+func WithProblemTypeBase(base string) ProblemOption {
+	return func(c *problemConfig) { c.typeBase = base }
+}
+
+// kindStatus is the status an error of each kind is answered with.
+var kindStatus = map[ErrorKind]int{
+	KindValidation:    http.StatusBadRequest,
+	KindPathTraversal: http.StatusBadRequest,
+	KindQuotaExceeded: http.StatusTooManyRequests,
+	KindTimeout:       http.StatusGatewayTimeout,
+	KindUnavailable:   http.StatusServiceUnavailable,
+	KindCancelled:     StatusClientClosedRequest,
+}
+
+// ProblemJSON is an ErrorHandler that answers with RFC 7807 bodies:
+//
+//	app.SetErrorHandler(ProblemJSON())
+//
+// An *Error gives the status, detail (Message), type and extensions, and
+// its Violations are listed under "errors". Other errors are classified
+// with Kind: client errors keep their message as detail, while server
+// errors show only the status text. The instance is the request ID. A
+// recovered panic gets a "correlation_id" extension to find it in the
+// logs; the stack is never sent.
+//
+// Clients that ask for application/json and not, or less, for
+// application/problem+json get DefaultErrorHandler's body instead.
+func ProblemJSON(opts ...ProblemOption) ErrorHandler {
+	cfg := problemConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return func(ctx context.Context, req *Request, err error) *Response {
+		if req != nil && !acceptsProblem(req.Request.Header.Get("Accept")) {
+			return DefaultErrorHandler(ctx, req, err)
+		}
+		p := cfg.problem(err)
+		if req != nil {
+			p.Instance = req.RequestID
+		}
+		var e *Error
+		if errors.As(err, &e) && e.Stack != nil && p.Instance != "" {
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]any, 1)
+			}
+			p.Extensions["correlation_id"] = p.Instance
+		}
+		return &Response{
+			Status:  p.Status,
+			Headers: http.Header{"Content-Type": []string{ProblemContentType}},
+			Body:    p,
+		}
+	}
+}
+
+// problem builds the Problem for err, without an instance.
+func (c problemConfig) problem(err error) Problem {
+	var e *Error
+	if errors.As(err, &e) {
+		p := Problem{Type: e.Type, Status: e.Code, Detail: e.Message}
+		if p.Type == "" {
+			p.Type = c.kindType(Kind(e.Cause))
+		}
+		if len(e.Extensions) > 0 || len(e.Violations) > 0 {
+			p.Extensions = make(map[string]any, len(e.Extensions)+1)
+			for k, v := range e.Extensions {
+				p.Extensions[k] = v
+			}
+			if len(e.Violations) > 0 {
+				p.Extensions["errors"] = e.Violations
+			}
+		}
+		p.Title = problemTitle(p.Status)
+		return p
+	}
+
+	kind := Kind(err)
+	p := Problem{Type: c.kindType(kind), Status: http.StatusInternalServerError}
+	if status, ok := kindStatus[kind]; ok {
+		p.Status = status
+	}
+	p.Title = problemTitle(p.Status)
+	if p.Status < 500 {
+		p.Detail = err.Error()
+	}
+	return p
+}
+
+func problemTitle(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	if text := http.StatusText(status); text != "" {
+		return text
+	}
+	return "Error"
+}
+
+func (c problemConfig) kindType(kind ErrorKind) string {
+	if c.typeBase == "" || kind == KindNone || kind == KindUnknown {
+		return "about:blank"
+	}
+	return c.typeBase + string(kind)
+}
+
+// acceptsProblem reports whether an Accept header prefers problem+json, or
+// at least does not ask for plain JSON. Without a header, it does.
+func acceptsProblem(header string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+	problemQ, jsonQ := -1.0, -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case ProblemContentType:
+			problemQ = q
+		case "application/json":
+			jsonQ = q
+		}
+	}
+	if problemQ >= 0 {
+		return problemQ > 0 && problemQ >= jsonQ
+	}
+	// Wildcards are less specific than an explicit application/json.
+	return jsonQ <= 0
+}
@@ -0,0 +1,147 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveProblem(t *testing.T, app *App, method, target, accept string) (*httptest.ResponseRecorder, Problem) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(`{"id": 1}`))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	var p Problem
+	if strings.HasPrefix(rec.Header().Get("Content-Type"), ProblemContentType) {
+		if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+			t.Fatalf("bad problem body %s: %v", rec.Body, err)
+		}
+	}
+	return rec, p
+}
+
+func TestProblemJSON(t *testing.T) {
+	app := NewApp()
+	app.Use(Recovery())
+	app.SetErrorHandler(ProblemJSON(WithProblemTypeBase("https://errors.example.com/")))
+	app.Get("/orders/:id", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, &Error{
+			Code:       http.StatusConflict,
+			Message:    "order already shipped",
+			Type:       "https://errors.example.com/order-state",
+			Extensions: map[string]any{"order_id": req.PathParams["id"], "status": "ignored"},
+		}
+	})
+	app.Get("/uploads", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, kindErrorf(ErrQuotaExceeded, "upload quota of 3 files used")
+	})
+	app.Get("/db", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, errors.New("dial postgres://admin:secret@db: refused")
+	})
+	app.Get("/panic", func(ctx context.Context, req *Request) (*Response, error) {
+		panic("nil pointer in handler")
+	})
+
+	rec, p := serveProblem(t, app, http.MethodGet, "/orders/7", "")
+	if rec.Code != http.StatusConflict || p.Status != http.StatusConflict || p.Title != "Conflict" ||
+		p.Detail != "order already shipped" || p.Type != "https://errors.example.com/order-state" {
+		t.Errorf("unexpected problem %d %+v", rec.Code, p)
+	}
+	if p.Instance == "" || p.Extensions["order_id"] != "7" {
+		t.Errorf("expected the request ID and extension members, got %+v", p)
+	}
+
+	rec, p = serveProblem(t, app, http.MethodGet, "/uploads", "")
+	if rec.Code != http.StatusTooManyRequests || p.Detail != "upload quota of 3 files used" ||
+		p.Type != "https://errors.example.com/quota_exceeded" {
+		t.Errorf("kind not mapped: %d %+v", rec.Code, p)
+	}
+
+	rec, p = serveProblem(t, app, http.MethodGet, "/db", "")
+	if rec.Code != http.StatusInternalServerError || p.Detail != "" || p.Type != "about:blank" ||
+		strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("server errors must not leak their cause: %s", rec.Body)
+	}
+
+	rec, p = serveProblem(t, app, http.MethodGet, "/panic", "")
+	if rec.Code != http.StatusInternalServerError || p.Extensions["correlation_id"] != p.Instance || p.Instance == "" {
+		t.Errorf("expected a correlation ID for the panic: %s", rec.Body)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "goroutine") || strings.Contains(body, "nil pointer") {
+		t.Errorf("panic details leaked: %s", body)
+	}
+}
+
+func TestProblemJSON_Negotiation(t *testing.T) {
+	app := NewApp()
+	app.SetErrorHandler(ProblemJSON())
+	app.Get("/missing", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, &Error{Code: http.StatusNotFound, Message: "no such item"}
+	})
+
+	for accept, wantProblem := range map[string]bool{
+		"":                                  true,
+		"*/*":                               true,
+		"application/problem+json":          true,
+		"application/json":                  false,
+		"application/json, text/plain, */*": false,
+		"application/json;q=0.5, application/problem+json": true,
+		"application/json, application/problem+json;q=0.9": false,
+		"application/problem+json;q=0, application/json":   false,
+	} {
+		rec, _ := serveProblem(t, app, http.MethodGet, "/missing", accept)
+		ct := rec.Header().Get("Content-Type")
+		if got := strings.HasPrefix(ct, ProblemContentType); got != wantProblem || rec.Code != http.StatusNotFound {
+			t.Errorf("Accept %q: %d %s", accept, rec.Code, ct)
+		}
+		if !wantProblem && !strings.Contains(rec.Body.String(), `"error":"no such item"`) {
+			t.Errorf("Accept %q: expected the plain JSON body, got %s", accept, rec.Body)
+		}
+	}
+}
+
+func TestProblemJSON_ContractViolations(t *testing.T) {
+	app, calls := contractApp(nil, WithContractLogger(NewTestLogger("contract", io.Discard)), WithContractStrict())
+	app.SetErrorHandler(ProblemJSON())
+
+	rec, p := serveProblem(t, app, http.MethodPost, "/users", "")
+	errs, _ := p.Extensions["errors"].([]any)
+	if rec.Code != http.StatusBadRequest || *calls != 0 || p.Detail != "request contract violation" || len(errs) != 2 {
+		t.Fatalf("unexpected problem %d %+v", rec.Code, p)
+	}
+	if errs[0] != `$: missing required property "name"` {
+		t.Errorf("unexpected violations %q", errs)
+	}
+}
+
+func TestSetErrorHandler_FallsBackToDefault(t *testing.T) {
+	app := NewApp()
+	app.SetErrorHandler(func(ctx context.Context, req *Request, err error) *Response {
+		if errors.Is(err, ErrTimeout) {
+			return ErrorResponse(http.StatusGatewayTimeout, "upstream timed out")
+		}
+		return nil
+	})
+	app.Get("/slow", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, kindErrorf(ErrTimeout, "inventory service")
+	})
+	app.Get("/teapot", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, &Error{Code: http.StatusTeapot, Message: "short and stout"}
+	})
+
+	if rec, _ := serveProblem(t, app, http.MethodGet, "/slow", ""); rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("custom handler not used: %d", rec.Code)
+	}
+	rec, _ := serveProblem(t, app, http.MethodGet, "/teapot", "")
+	if rec.Code != http.StatusTeapot || !strings.Contains(rec.Body.String(), `"error":"short and stout"`) {
+		t.Errorf("expected the default rendering, got %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,221 @@
+package testutils
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Rate limiting middleware
+// --------------------------------------------------------------------
+
+// RateLimitKeyFunc selects the bucket a request is counted against.
+type RateLimitKeyFunc func(req *Request) string
+
+// RateLimitByIP keys requests by the client IP from RemoteAddr.
+func RateLimitByIP() RateLimitKeyFunc {
+	return func(req *Request) string {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			return req.RemoteAddr
+		}
+		return host
+	}
+}
+
+// RateLimitByHeader keys requests by the value of header name, such as an
+// API key. Requests without the header are keyed by client IP.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	byIP := RateLimitByIP()
+	return func(req *Request) string {
+		if v := req.Request.Header.Get(name); v != "" {
+			return "header:" + v
+		}
+		return "ip:" + byIP(req)
+	}
+}
+
+// RateLimitByPath keys requests by URL path, limiting each endpoint as a
+// whole regardless of caller.
+func RateLimitByPath() RateLimitKeyFunc {
+	return func(req *Request) string { return req.URL.Path }
+}
+
+// RateLimitOptions configures a KeyedRateLimiter.
+type RateLimitOptions struct {
+	// Rate is the number of tokens added to each bucket per second.
+	Rate float64
+	// Burst is the bucket capacity: how many requests a key may make at
+	// once after being idle.
+	Burst int
+	// Key selects the bucket. Defaults to RateLimitByIP.
+	Key RateLimitKeyFunc
+	// Clock is the time source. Defaults to RealClock; tests pass a
+	// MockClock and Advance it instead of sleeping.
+	Clock Clock
+	// IdleTTL is how long an unused bucket is kept. Defaults to the time a
+	// bucket takes to refill completely, after which a fresh bucket is
+	// indistinguishable from the old one.
+	IdleTTL time.Duration
+}
+
+// RateLimitDecision is the outcome of taking a token.
+type RateLimitDecision struct {
+	Allowed    bool
+	Limit      int           // bucket capacity
+	Remaining  int           // whole tokens left after this request
+	RetryAfter time.Duration // until the next token, when not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// KeyedRateLimiter keeps one token bucket per key. Buckets idle for longer
+// than IdleTTL are swept on later calls, so memory stays bounded by the
+// number of keys active within IdleTTL.
+type KeyedRateLimiter struct {
+	opts RateLimitOptions
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewKeyedRateLimiter creates a KeyedRateLimiter. It panics if Rate or
+// Burst is not positive.
+func NewKeyedRateLimiter(opts RateLimitOptions) *KeyedRateLimiter {
+	if opts.Rate <= 0 || opts.Burst <= 0 {
+		panic("NewKeyedRateLimiter: Rate and Burst must be positive")
+	}
+	if opts.Key == nil {
+		opts.Key = RateLimitByIP()
+	}
+	if opts.Clock == nil {
+		opts.Clock = RealClock{}
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = time.Duration(float64(opts.Burst) / opts.Rate * float64(time.Second))
+	}
+	return &KeyedRateLimiter{
+		opts:      opts,
+		buckets:   make(map[string]*rateBucket),
+		lastSweep: opts.Clock.Now(),
+	}
+}
+
+// RateLimit returns a middleware backed by a new KeyedRateLimiter.
+//
+//	app.Use(RateLimit(RateLimitOptions{Rate: 5, Burst: 10, Key: RateLimitByHeader("X-API-Key")}))
+func RateLimit(opts RateLimitOptions) Middleware {
+	return NewKeyedRateLimiter(opts).Middleware()
+}
+
+// Allow takes a token from key's bucket if one is available.
+func (l *KeyedRateLimiter) Allow(key string) RateLimitDecision {
+	now := l.opts.Clock.Now()
+	burst := float64(l.opts.Burst)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed.Seconds()*l.opts.Rate)
+	}
+	b.last = now
+
+	d := RateLimitDecision{Limit: l.opts.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = l.refillTime(1 - b.tokens)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = l.refillTime(burst - b.tokens)
+	return d
+}
+
+// Len returns the number of buckets currently held.
+func (l *KeyedRateLimiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+func (l *KeyedRateLimiter) refillTime(tokens float64) time.Duration {
+	return time.Duration(math.Ceil(tokens / l.opts.Rate * float64(time.Second)))
+}
+
+// sweep drops idle buckets at most once per IdleTTL. The caller holds l.mu.
+func (l *KeyedRateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.opts.IdleTTL {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= l.opts.IdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Middleware counts every request against its key's bucket. Allowed
+// responses carry X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset (seconds until full); exhausted keys get 429 with
+// Retry-After and the same headers without calling the handler.
+func (l *KeyedRateLimiter) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			d := l.Allow(l.opts.Key(req))
+			if !d.Allowed {
+				return rateLimitedResponse(d), nil
+			}
+			resp, err := next(ctx, req)
+			if resp != nil {
+				if resp.Headers == nil {
+					resp.Headers = make(http.Header)
+				}
+				setRateLimitHeaders(resp.Headers, d)
+			}
+			return resp, err
+		}
+	}
+}
+
+// allowRequest is Allow for handlers outside App, such as MockServer.
+func (l *KeyedRateLimiter) allowRequest(r *http.Request) RateLimitDecision {
+	return l.Allow(l.opts.Key(&Request{Request: r}))
+}
+
+// rateLimitedResponse is the 429 sent once a key's bucket is empty.
+func rateLimitedResponse(d RateLimitDecision) *Response {
+	resp := ErrorResponse(http.StatusTooManyRequests, "rate limit exceeded")
+	setRateLimitHeaders(resp.Headers, d)
+	resp.Headers.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+	return resp
+}
+
+func setRateLimitHeaders(h http.Header, d RateLimitDecision) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+}
+
+// ceilSeconds rounds d up to whole seconds, as HTTP headers require.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,160 @@
+package testutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimit_BurstRefillAndHeaders(t *testing.T) {
+	clock := NewMockClock(time.Time{})
+	app := NewApp()
+	app.Use(RateLimit(RateLimitOptions{Rate: 0.5, Burst: 2, Clock: clock}))
+	app.Get("/", okHandler)
+
+	get := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec
+	}
+	for i, wantRemaining := range []string{"1", "0"} {
+		rec := get()
+		if rec.Code != http.StatusOK || rec.Header().Get("X-RateLimit-Remaining") != wantRemaining {
+			t.Fatalf("request %d: %d remaining=%q", i, rec.Code, rec.Header().Get("X-RateLimit-Remaining"))
+		}
+	}
+	rec := get()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: %d", rec.Code)
+	}
+	for h, want := range map[string]string{
+		"Retry-After":           "2", // one token at 0.5/s
+		"X-RateLimit-Limit":     "2",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "4",
+	} {
+		if got := rec.Header().Get(h); got != want {
+			t.Errorf("%s = %q, want %q", h, got, want)
+		}
+	}
+
+	clock.Advance(time.Second)
+	if rec := get(); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("after 1s: %d Retry-After=%q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	clock.Advance(time.Second)
+	if rec := get(); rec.Code != http.StatusOK {
+		t.Errorf("after refill: %d", rec.Code)
+	}
+}
+
+func TestRateLimit_KeysAreIndependent(t *testing.T) {
+	l := NewKeyedRateLimiter(RateLimitOptions{
+		Rate: 1, Burst: 1, Clock: NewMockClock(time.Time{}), Key: RateLimitByHeader("X-API-Key"),
+	})
+	app := NewApp()
+	app.Use(l.Middleware())
+	app.Get("/", okHandler)
+
+	status := func(apiKey string) int {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	got := []int{status("a"), status("a"), status("b"), status(""), status("")}
+	want := []int{200, 429, 200, 200, 429}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRateLimit_IdleBucketsAreSwept(t *testing.T) {
+	clock := NewMockClock(time.Time{})
+	l := NewKeyedRateLimiter(RateLimitOptions{Rate: 10, Burst: 10, Clock: clock}) // IdleTTL 1s
+	for _, key := range []string{"a", "b", "c"} {
+		l.Allow(key)
+	}
+	clock.Advance(500 * time.Millisecond)
+	l.Allow("c")
+	if n := l.Len(); n != 3 {
+		t.Fatalf("buckets = %d before TTL", n)
+	}
+	clock.Advance(600 * time.Millisecond)
+	l.Allow("d")
+	if n := l.Len(); n != 2 { // c was used 600ms ago, d is new
+		t.Errorf("buckets = %d after TTL, want 2", n)
+	}
+}
+
+func TestRateLimit_ParallelRequestsCountExactly(t *testing.T) {
+	const burst, clients = 50, 200
+	app := NewApp()
+	app.Use(RateLimit(RateLimitOptions{Rate: 1e-6, Burst: burst, Key: RateLimitByPath()}))
+	app.Get("/", okHandler)
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	var ok, limited atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL + "/")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			switch resp.StatusCode {
+			case http.StatusOK:
+				ok.Add(1)
+			case http.StatusTooManyRequests:
+				limited.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok.Load() != burst || limited.Load() != clients-burst {
+		t.Errorf("ok=%d limited=%d, want %d/%d", ok.Load(), limited.Load(), burst, clients-burst)
+	}
+}
+
+func TestMockServer_WithRateLimit(t *testing.T) {
+	clock := NewMockClock(time.Time{})
+	ms := NewMockServerT(t)
+	route := ms.On(http.MethodGet, "/upstream").
+		Respond(Resp(http.StatusOK)).
+		WithRateLimit(NewKeyedRateLimiter(RateLimitOptions{Rate: 1, Burst: 1, Clock: clock}))
+
+	codes := func() (int, string) {
+		resp, err := http.Get(ms.URL + "/upstream")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get("Retry-After")
+	}
+	if code, _ := codes(); code != http.StatusOK {
+		t.Fatalf("first call: %d", code)
+	}
+	if code, retry := codes(); code != http.StatusTooManyRequests || retry != "1" {
+		t.Fatalf("second call: %d Retry-After=%q", code, retry)
+	}
+	clock.Advance(time.Second)
+	if code, _ := codes(); code != http.StatusOK {
+		t.Fatalf("after refill: %d", code)
+	}
+	if route.Calls() != 2 || len(ms.RequestsFor(http.MethodGet, "/upstream")) != 3 {
+		t.Errorf("calls=%d recorded=%d", route.Calls(), len(ms.RequestsFor(http.MethodGet, "/upstream")))
+	}
+}
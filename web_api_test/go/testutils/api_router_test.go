@@ -0,0 +1,262 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okRoute(body string) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		return Text(http.StatusOK, body)
+	}
+}
+
+func serveRoute(app *App, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestApp_RoutesAndNotFound(t *testing.T) {
+	app := NewApp()
+	app.Get("/users/:id", okRoute("user"))
+	app.Delete("/users/:id", okRoute("deleted"))
+	app.Group("/api", func(g *Group) {
+		g.Get("/files/*", okRoute("file"))
+	})
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Pattern: "/api/files/*"},
+		{Method: http.MethodDelete, Pattern: "/users/:id"},
+		{Method: http.MethodGet, Pattern: "/users/:id"},
+	}
+	if got := app.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() = %+v, want %+v", got, want)
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+		body           string
+	}{
+		{http.MethodGet, "/users/7", http.StatusOK, "user"},
+		{http.MethodDelete, "/users/7", http.StatusOK, "deleted"},
+		{http.MethodGet, "/api/files/a/b.txt", http.StatusOK, "file"},
+		{http.MethodGet, "/users", http.StatusNotFound, ""},
+		{http.MethodGet, "/nowhere", http.StatusNotFound, ""},
+		{http.MethodPost, "/users/7", http.StatusNotFound, ""},
+	} {
+		rec := serveRoute(app, tc.method, tc.target)
+		if rec.Code != tc.status || !strings.Contains(rec.Body.String(), tc.body) {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.target, rec.Code, rec.Body, tc.status, tc.body)
+		}
+	}
+}
+
+func TestApp_HandleRawIsPassthroughOnly(t *testing.T) {
+	app := NewApp()
+	calls := 0
+	app.HandleRaw("/metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte("raw"))
+	}))
+	app.Get("/health", okRoute("ok"))
+
+	if rec := serveRoute(app, http.MethodGet, "/metrics"); rec.Body.String() != "raw" || calls != 1 {
+		t.Errorf("expected the raw handler once, got %q after %d calls", rec.Body, calls)
+	}
+	// Routed paths never reach the mux, and unknown ones 404 without
+	// looping back through ServeHTTP.
+	if rec := serveRoute(app, http.MethodGet, "/health"); !strings.Contains(rec.Body.String(), "ok") {
+		t.Errorf("expected the routed handler, got %q", rec.Body)
+	}
+	if rec := serveRoute(app, http.MethodGet, "/missing"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+	if calls != 1 {
+		t.Errorf("raw handler called %d times", calls)
+	}
+}
+
+func TestApp_DuplicateRoutePanics(t *testing.T) {
+	for _, tc := range []struct{ first, second string }{
+		{"/users/:id", "/users/:id"},
+		{"/users/:id", "/users/:name/"},
+		{"/api/items", "api/items"},
+	} {
+		app := NewApp()
+		app.Get(tc.first, okRoute("first"))
+		app.Post(tc.second, okRoute("other method"))
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), "conflicts with GET "+tc.first) {
+					t.Errorf("registering GET %s after GET %s: expected a conflict panic, got %v", tc.second, tc.first, r)
+				}
+			}()
+			app.Get(tc.second, okRoute("second"))
+		}()
+		if rec := serveRoute(app, http.MethodGet, "/"+strings.Trim(strings.ReplaceAll(tc.first, ":id", "1"), "/")); !strings.Contains(rec.Body.String(), "first") {
+			t.Errorf("expected the first registration to stay, got %q", rec.Body)
+		}
+	}
+
+	app := NewApp()
+	app.Group("/v1", func(g *Group) { g.Get("/ping", okRoute("pong")) })
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a group route colliding with an app route to panic")
+		}
+	}()
+	app.Get("/v1/ping", okRoute("pong"))
+}
+
+// tagMiddleware appends name to the X-Chain response header.
+func tagMiddleware(name string) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			resp, err := next(ctx, req)
+			if resp != nil {
+				if resp.Headers == nil {
+					resp.Headers = http.Header{}
+				}
+				resp.Headers.Add("X-Chain", name)
+			}
+			return resp, err
+		}
+	}
+}
+
+func TestGroup_NestedWithParams(t *testing.T) {
+	app := NewApp()
+	app.Use(tagMiddleware("app"))
+	echoParams := func(ctx context.Context, req *Request) (*Response, error) {
+		return JSON(http.StatusOK, req.PathParams)
+	}
+
+	var tenant *Group
+	app.Group("/api/v1/", func(v1 *Group) {
+		v1.Use(tagMiddleware("v1"))
+		tenant = v1.Group("tenants/:tenantID", func(tg *Group) {
+			tg.Use(tagMiddleware("tenant"))
+			tg.Get("", echoParams)
+			tg.Group("/projects/:projectID/", func(pg *Group) {
+				pg.Use(tagMiddleware("project"))
+				pg.Get("/builds/:buildID", echoParams)
+			})
+		})
+		// Added after the tenant group was created: must not reach it.
+		v1.Use(tagMiddleware("late"))
+		v1.Get("/status", okRoute("up"))
+	})
+	tenant.Get("/settings", echoParams)
+
+	for _, tc := range []struct {
+		target, params string
+		chain          []string
+	}{
+		{"/api/v1/tenants/acme/projects/web/builds/42", `{"buildID":"42","projectID":"web","tenantID":"acme"}`, []string{"project", "tenant", "v1", "app"}},
+		{"/api/v1/tenants/acme", `{"tenantID":"acme"}`, []string{"tenant", "v1", "app"}},
+		{"/api/v1/tenants/acme/settings", `{"tenantID":"acme"}`, []string{"tenant", "v1", "app"}},
+		{"/api/v1/status", `up`, []string{"late", "v1", "app"}},
+	} {
+		rec := serveRoute(app, http.MethodGet, tc.target)
+		if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != tc.params {
+			t.Errorf("GET %s: got %d %s, want %s", tc.target, rec.Code, rec.Body, tc.params)
+		}
+		if got := rec.Header().Values("X-Chain"); !reflect.DeepEqual(got, tc.chain) {
+			t.Errorf("GET %s: middleware chain %v, want %v", tc.target, got, tc.chain)
+		}
+	}
+
+	want := []string{"/api/v1/status", "/api/v1/tenants/:tenantID", "/api/v1/tenants/:tenantID/projects/:projectID/builds/:buildID", "/api/v1/tenants/:tenantID/settings"}
+	var got []string
+	for _, r := range app.Routes() {
+		got = append(got, r.Pattern)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes() = %v, want %v", got, want)
+	}
+}
+
+func TestJoinRoutePath(t *testing.T) {
+	for _, tc := range []struct{ prefix, pattern, want string }{
+		{"", "", "/"},
+		{"/", "/", "/"},
+		{"/api/", "", "/api"},
+		{"api", "users", "/api/users"},
+		{"/api//v1/", "//users/:id/", "/api/v1/users/:id"},
+		{"", "/files/*", "/files/*"},
+	} {
+		if got := joinRoutePath(tc.prefix, tc.pattern); got != tc.want {
+			t.Errorf("joinRoutePath(%q, %q) = %q, want %q", tc.prefix, tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestRequest_RoutePatternAndStartTime(t *testing.T) {
+	app := NewApp()
+	type seen struct {
+		pattern, fromCtx string
+		names            []string
+		params           map[string]string
+		started          bool
+	}
+	var got seen
+	var mwStart time.Time
+	app.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			mwStart = time.Now()
+			return next(ctx, req)
+		}
+	})
+	record := func(ctx context.Context, req *Request) (*Response, error) {
+		got = seen{req.RoutePattern(), RoutePatternFromContext(ctx), req.ParamNames(), req.PathParams,
+			!req.StartTime().IsZero() && !req.StartTime().After(mwStart)}
+		return Text(http.StatusOK, "ok")
+	}
+	app.Get("users//:id/", record)
+	app.Put("/users/:userID", record)
+	app.Group("/api/", func(g *Group) {
+		g.Group("v1/tenants/:tenant", func(tg *Group) {
+			tg.Get("files/*", record)
+		})
+	})
+	app.Get("/static/*/ignored", record)
+
+	for _, tc := range []struct {
+		method, target string
+		want           seen
+	}{
+		{http.MethodGet, "/users/42", seen{"/users/:id", "/users/:id", []string{"id"}, map[string]string{"id": "42"}, true}},
+		{http.MethodPut, "/users/42", seen{"/users/:userID", "/users/:userID", []string{"userID"}, map[string]string{"userID": "42"}, true}},
+		{http.MethodGet, "/api/v1/tenants/acme/files/a/b.txt", seen{"/api/v1/tenants/:tenant/files/*", "/api/v1/tenants/:tenant/files/*",
+			[]string{"tenant", "*"}, map[string]string{"tenant": "acme", "*": "a/b.txt"}, true}},
+		{http.MethodGet, "/static/css/site.css", seen{"/static/*", "/static/*", []string{"*"}, map[string]string{"*": "css/site.css"}, true}},
+	} {
+		got = seen{}
+		if rec := serveRoute(app, tc.method, tc.target); rec.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d", tc.method, tc.target, rec.Code)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s %s: got %+v, want %+v", tc.method, tc.target, got, tc.want)
+		}
+	}
+
+	var unmatched string
+	app2 := NewApp()
+	app2.Use(func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			unmatched = req.RoutePattern() + RoutePatternFromContext(ctx)
+			return next(ctx, req)
+		}
+	})
+	if rec := serveRoute(app2, http.MethodGet, "/nowhere"); rec.Code != http.StatusNotFound || unmatched != "" {
+		t.Errorf("unmatched request: status %d, pattern %q", rec.Code, unmatched)
+	}
+}
@@ -0,0 +1,212 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Server-Sent Events
+// --------------------------------------------------------------------
+
+// DefaultSSEHeartbeat is how long a stream may stay idle before SSE writes a
+// comment line, short enough to keep proxies from timing the request out.
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// SSEHandler produces the events of one stream. ctx ends when the client
+// disconnects.
+type SSEHandler func(ctx context.Context, w *SSEWriter) error
+
+// SSEOption configures SSE.
+type SSEOption func(*sseOptions)
+
+type sseOptions struct {
+	heartbeat time.Duration
+	retry     time.Duration
+}
+
+// WithSSEHeartbeat sets the idle interval after which a heartbeat comment is
+// sent. Zero disables heartbeats.
+func WithSSEHeartbeat(d time.Duration) SSEOption {
+	return func(o *sseOptions) { o.heartbeat = d }
+}
+
+// WithSSERetry advertises the delay clients should wait before reconnecting.
+func WithSSERetry(d time.Duration) SSEOption {
+	return func(o *sseOptions) { o.retry = d }
+}
+
+// SSE returns a Handler that streams Server-Sent Events produced by handler.
+// The stream starts with the first event or heartbeat; an error returned
+// before that becomes an ordinary error response, one returned after it is
+// sent as an "error" event carrying {"message": ...}.
+//
+//	app.Get("/jobs/:id/progress", SSE(func(ctx context.Context, w *SSEWriter) error {
+//		for p := range job.Progress() {
+//			if err := w.Send("progress", p); err != nil {
+//				return err // client went away
+//			}
+//		}
+//		return nil
+//	}))
+func SSE(handler SSEHandler, opts ...SSEOption) Handler {
+	o := sseOptions{heartbeat: DefaultSSEHeartbeat}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		if req.rw == nil {
+			return nil, &Error{Code: http.StatusInternalServerError, Message: "sse: request was not served by App"}
+		}
+		if _, ok := req.rw.ResponseWriter.(http.Flusher); !ok {
+			return nil, &Error{Code: http.StatusInternalServerError, Message: "sse: response writer cannot flush"}
+		}
+		w := &SSEWriter{ctx: ctx, rw: req.rw, retry: o.retry}
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		if o.heartbeat > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.heartbeat(o.heartbeat, stop)
+			}()
+		}
+		err := handler(ctx, w)
+		close(stop)
+		wg.Wait()
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.started && err != nil {
+			return nil, err
+		}
+		if err != nil && w.err == nil {
+			data, _ := json.Marshal(map[string]string{"message": err.Error()})
+			w.writeEvent("error", data)
+		} else {
+			w.start()
+		}
+		return &Response{Status: http.StatusOK}, nil
+	}
+}
+
+// SSEWriter sends events to one client. It is safe for concurrent use.
+type SSEWriter struct {
+	ctx   context.Context
+	rw    *responseWriter
+	retry time.Duration
+
+	mu        sync.Mutex
+	started   bool
+	err       error // first write failure; the stream is dead after it
+	lastWrite time.Time
+}
+
+// Send writes one event with data encoded as JSON and flushes it. An empty
+// event name sends an unnamed ("message") event. Once the client has
+// disconnected Send returns an error wrapping the context's error.
+func (w *SSEWriter) Send(event string, data any) error {
+	if strings.ContainsAny(event, "\r\n") {
+		return fmt.Errorf("sse: event name %q contains a line break", event)
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("sse: failed to encode %q event: %w", event, err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeEvent(event, payload)
+}
+
+// writeEvent writes a complete event. The caller holds w.mu.
+func (w *SSEWriter) writeEvent(event string, payload []byte) error {
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	// json.Marshal output never contains a raw newline, so one data line
+	// carries the whole payload.
+	b.WriteString("data: ")
+	b.Write(payload)
+	b.WriteString("\n\n")
+	return w.write(b.String())
+}
+
+// write starts the stream if needed, writes s and flushes. The caller holds
+// w.mu.
+func (w *SSEWriter) write(s string) error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.ctx.Err(); err != nil {
+		w.err = fmt.Errorf("sse: client disconnected: %w", err)
+		return w.err
+	}
+	if !w.start() {
+		return w.err
+	}
+	if _, err := w.rw.Write([]byte(s)); err != nil {
+		w.err = fmt.Errorf("sse: write failed: %w", err)
+		return w.err
+	}
+	w.rw.Flush()
+	w.lastWrite = time.Now()
+	return nil
+}
+
+// start writes the response headers once. The caller holds w.mu.
+func (w *SSEWriter) start() bool {
+	if w.started {
+		return w.err == nil
+	}
+	w.started = true
+	w.rw.streaming = true
+	h := w.rw.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.rw.WriteHeader(http.StatusOK)
+	if w.retry > 0 {
+		if _, err := w.rw.Write([]byte("retry: " + strconv.FormatInt(w.retry.Milliseconds(), 10) + "\n\n")); err != nil {
+			w.err = fmt.Errorf("sse: write failed: %w", err)
+			return false
+		}
+	}
+	w.rw.Flush()
+	w.lastWrite = time.Now()
+	return true
+}
+
+// heartbeat writes a comment whenever the stream has been idle for interval.
+func (w *SSEWriter) heartbeat(interval time.Duration, stop <-chan struct{}) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-w.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		w.mu.Lock()
+		idle := time.Since(w.lastWrite) // huge before the stream starts
+		if idle >= interval {
+			w.write(": heartbeat\n\n")
+			idle = 0
+		}
+		failed := w.err != nil
+		w.mu.Unlock()
+		if failed {
+			return
+		}
+		timer.Reset(interval - idle)
+	}
+}
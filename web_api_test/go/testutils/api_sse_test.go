@@ -0,0 +1,118 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func nextSSE(t *testing.T, s *SSEStream) SSEEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-s.Events:
+		if !ok {
+			t.Fatalf("stream ended: %v", s.Err())
+		}
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event within 2s")
+	}
+	return SSEEvent{}
+}
+
+func TestSSE_StreamsEventsAndEnds(t *testing.T) {
+	app := NewApp()
+	app.Get("/progress", SSE(func(ctx context.Context, w *SSEWriter) error {
+		for step := 1; step <= 3; step++ {
+			if err := w.Send("progress", map[string]int{"step": step}); err != nil {
+				return err
+			}
+		}
+		return errors.New("job failed")
+	}, WithSSERetry(time.Second)))
+	app.Get("/denied", SSE(func(ctx context.Context, w *SSEWriter) error {
+		return &Error{Code: http.StatusForbidden, Message: "no access"}
+	}))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+	c := NewHTTPTestClient(srv.URL)
+
+	s, err := c.StreamSSE(context.Background(), "/progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for want := 1; want <= 3; want++ {
+		ev := nextSSE(t, s)
+		var got struct{ Step int }
+		if err := ev.Decode(&got); err != nil || ev.Event != "progress" || got.Step != want {
+			t.Fatalf("event %d = %+v (%v)", want, ev, err)
+		}
+	}
+	if ev := nextSSE(t, s); ev.Event != "error" || ev.Data != `{"message":"job failed"}` {
+		t.Errorf("error event = %+v", ev)
+	}
+	if _, ok := <-s.Events; ok || s.Err() != nil {
+		t.Errorf("stream should end cleanly, err = %v", s.Err())
+	}
+
+	if _, err := c.StreamSSE(context.Background(), "/denied"); err == nil {
+		t.Error("error before the first event should be a plain 403")
+	}
+}
+
+func TestSSE_HeartbeatWhileIdle(t *testing.T) {
+	app := NewApp()
+	app.Get("/idle", SSE(func(ctx context.Context, w *SSEWriter) error {
+		<-ctx.Done()
+		return nil
+	}, WithSSEHeartbeat(10*time.Millisecond)))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	s, err := NewHTTPTestClient(srv.URL).StreamSSE(context.Background(), "/idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	time.Sleep(100 * time.Millisecond)
+	if n := s.Comments(); n < 3 {
+		t.Errorf("%d heartbeats in 100ms at a 10ms interval", n)
+	}
+}
+
+func TestSSE_ClientDisconnectMidStream(t *testing.T) {
+	sendErr := make(chan error, 1)
+	app := NewApp()
+	app.Get("/ticks", SSE(func(ctx context.Context, w *SSEWriter) error {
+		for i := 0; ; i++ {
+			if err := w.Send("tick", i); err != nil {
+				sendErr <- err
+				return err
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	s, err := NewHTTPTestClient(srv.URL).StreamSSE(context.Background(), "/ticks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextSSE(t, s)
+	nextSSE(t, s)
+	s.Close()
+
+	select {
+	case err := <-sendErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Send after disconnect: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept streaming to a disconnected client")
+	}
+}
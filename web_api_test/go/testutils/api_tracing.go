@@ -0,0 +1,163 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Trace propagation – W3C traceparent between HTTPTestClient and App
+// --------------------------------------------------------------------
+
+// TraceparentHeader carries the caller's span across HTTP, as
+// "00-<32 hex trace ID>-<16 hex span ID>-<flags>".
+const TraceparentHeader = "traceparent"
+
+// InjectTraceparent sets the traceparent header for sc, with the sampled
+// flag cleared for unsampled spans. Hex IDs shorter than the W3C widths are
+// zero-padded; IDs that are not hex cannot be represented, and
+// InjectTraceparent reports false without touching h.
+func InjectTraceparent(h http.Header, sc SpanContext) bool {
+	traceID, ok1 := padHexID(sc.TraceID, 32)
+	spanID, ok2 := padHexID(sc.SpanID, 16)
+	if !ok1 || !ok2 {
+		return false
+	}
+	flags := "01"
+	if sc.Unsampled {
+		flags = "00"
+	}
+	h.Set(TraceparentHeader, "00-"+traceID+"-"+spanID+"-"+flags)
+	return true
+}
+
+// ExtractTraceparent parses the traceparent header into the context of the
+// remote parent span, including its sampling decision.
+func ExtractTraceparent(h http.Header) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(h.Get(TraceparentHeader)), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || !isHex(parts[0]) {
+		return SpanContext{}, false
+	}
+	traceID, spanID := strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	if len(traceID) != 32 || len(spanID) != 16 || !isHex(traceID) || !isHex(spanID) || len(parts[3]) != 2 || !isHex(parts[3]) {
+		return SpanContext{}, false
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return SpanContext{}, false
+	}
+	flags, _ := strconv.ParseUint(parts[3], 16, 8)
+	return SpanContext{TraceID: traceID, SpanID: spanID, Unsampled: flags&1 == 0}, true
+}
+
+// ContextWithRemoteParent returns a context under which tracers that read
+// the parent from the context (InMemoryTracer) start spans as children of
+// the remote span sc.
+func ContextWithRemoteParent(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, &Span{Context: sc})
+}
+
+func padHexID(id string, width int) (string, bool) {
+	id = strings.ToLower(id)
+	if id == "" || len(id) > width || !isHex(id) {
+		return "", false
+	}
+	return strings.Repeat("0", width-len(id)) + id, true
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Tracing starts a server span per request, named after the method and the
+// route pattern ("GET /users/:id"), as a child of the span in the request's
+// traceparent header when there is one. The span is tagged with the status
+// code and duration, and marked as an error for 5xx responses. Handlers see
+// the span in ctx, so spans they start become its children.
+func Tracing(tracer Tracer) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if parent, ok := ExtractTraceparent(req.Request.Header); ok {
+				ctx = ContextWithRemoteParent(ctx, parent)
+			}
+			route := req.route
+			if route == "" {
+				route = req.URL.Path
+			}
+			ctx, span := tracer.StartSpan(ctx, req.Method+" "+route,
+				WithTag("span.kind", "server"),
+				WithTag("http.method", req.Method),
+				WithTag("http.route", route),
+				WithTag("http.target", req.URL.RequestURI()),
+			)
+			req.Request = req.WithContext(ctx)
+
+			start := time.Now()
+			resp, err := next(ctx, req)
+			status := responseStatus(resp, err)
+			opts := []SpanOption{WithTags(map[string]interface{}{
+				"http.status_code": status,
+				"duration":         time.Since(start),
+			})}
+			if status >= 500 {
+				msg := http.StatusText(status)
+				if err != nil {
+					msg = err.Error()
+				}
+				opts = append(opts, WithStatus(StatusError, msg))
+			}
+			tracer.EndSpan(span, opts...)
+			return resp, err
+		}
+	}
+}
+
+// responseStatus is the status App.ServeHTTP will send for a handler result.
+func responseStatus(resp *Response, err error) int {
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			return apiErr.Code
+		}
+		return http.StatusInternalServerError
+	}
+	if resp == nil {
+		return http.StatusOK
+	}
+	return resp.Status
+}
+
+// tracedDo wraps one logical client request in a client span and makes the
+// span available to newRequest for traceparent injection.
+func (c *HTTPTestClient) tracedDo(ctx context.Context, method, path string, do func(context.Context) (*http.Response, error)) (*http.Response, error) {
+	ctx, span := c.tracer.StartSpan(ctx, method+" "+path,
+		WithTag("span.kind", "client"),
+		WithTag("http.method", method),
+		WithTag("http.url", c.url(path)),
+	)
+	if p := spanFromContext(ctx); p == nil || p.Context.SpanID != span.Context.SpanID {
+		ctx = ContextWithRemoteParent(ctx, span.Context)
+	}
+	resp, err := do(ctx)
+	var opts []SpanOption
+	switch {
+	case err != nil && resp == nil:
+		opts = append(opts, WithStatus(StatusError, err.Error()))
+	case resp != nil:
+		opts = append(opts, WithTag("http.status_code", resp.StatusCode))
+		if resp.StatusCode >= 500 {
+			opts = append(opts, WithStatus(StatusError, fmt.Sprintf("server returned %d", resp.StatusCode)))
+		}
+	}
+	c.tracer.EndSpan(span, opts...)
+	return resp, err
+}
@@ -0,0 +1,116 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func spanNamed(t *testing.T, spans []Span, name string) Span {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("no span %q in %d spans", name, len(spans))
+	return Span{}
+}
+
+func TestTracing_ClientSpanParentsServerSpan(t *testing.T) {
+	clientTracer, serverTracer := NewInMemoryTracer(), NewInMemoryTracer()
+
+	app := NewApp()
+	app.Use(Tracing(serverTracer))
+	app.Get("/users/:id", func(ctx context.Context, req *Request) (*Response, error) {
+		_, span := serverTracer.StartSpan(ctx, "db.query")
+		serverTracer.EndSpan(span)
+		return JSON(http.StatusOK, map[string]string{"id": req.PathParams["id"]})
+	})
+	app.Get("/boom", func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, &Error{Code: http.StatusServiceUnavailable, Message: "down"}
+	})
+	srv := httptest.NewServer(app)
+	defer srv.Close()
+
+	client := NewHTTPTestClient(srv.URL, WithTracer(clientTracer))
+	resp, err := client.Get(context.Background(), "/users/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	a := NewTraceAssertions(t)
+	clientSpan := spanNamed(t, clientTracer.Spans(), "GET /users/42")
+	serverSpan := spanNamed(t, serverTracer.Spans(), "GET /users/:id")
+	dbSpan := spanNamed(t, serverTracer.Spans(), "db.query")
+	a.AssertSpanChildOf(serverSpan, clientSpan)
+	a.AssertSpanChildOf(dbSpan, serverSpan)
+	a.AssertSpanHasTag(serverTracer, "GET /users/:id", "http.status_code", http.StatusOK)
+	a.AssertSpanHasTag(serverTracer, "GET /users/:id", "span.kind", "server")
+	a.AssertSpanHasTag(clientTracer, "GET /users/42", "http.status_code", http.StatusOK)
+	if _, ok := serverSpan.Tags["duration"]; !ok {
+		t.Error("server span has no duration tag")
+	}
+
+	resp, err = client.Get(context.Background(), "/boom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	boom := spanNamed(t, serverTracer.Spans(), "GET /boom")
+	if boom.Status.Code != StatusError || boom.Tags["http.status_code"] != http.StatusServiceUnavailable {
+		t.Errorf("5xx server span: status %+v, tags %v", boom.Status, boom.Tags)
+	}
+	if c := spanNamed(t, clientTracer.Spans(), "GET /boom"); c.Status.Code != StatusError {
+		t.Errorf("5xx client span status %+v", c.Status)
+	}
+}
+
+func TestTracing_WithoutTraceparentStartsRoot(t *testing.T) {
+	tracer := NewInMemoryTracer()
+	app := NewApp()
+	app.Use(Tracing(tracer))
+	app.Get("/ping", func(ctx context.Context, req *Request) (*Response, error) {
+		return Text(http.StatusOK, "pong")
+	})
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ping", nil))
+
+	span := spanNamed(t, tracer.Spans(), "GET /ping")
+	if span.Context.ParentID != "" || len(span.Context.TraceID) != 32 {
+		t.Errorf("root span context %+v", span.Context)
+	}
+}
+
+func TestTraceparent_RoundTrip(t *testing.T) {
+	h := http.Header{}
+	if !InjectTraceparent(h, SpanContext{TraceID: "abc", SpanID: "12"}) {
+		t.Fatal("hex IDs should be injectable")
+	}
+	if got := h.Get(TraceparentHeader); got != "00-00000000000000000000000000000abc-0000000000000012-01" {
+		t.Errorf("traceparent = %q", got)
+	}
+	sc, ok := ExtractTraceparent(h)
+	if !ok || sc.TraceID != "00000000000000000000000000000abc" || sc.SpanID != "0000000000000012" {
+		t.Errorf("extracted %+v, %v", sc, ok)
+	}
+
+	if InjectTraceparent(http.Header{}, SpanContext{TraceID: "trace-1", SpanID: "span-1"}) {
+		t.Error("non-hex IDs should not be injected")
+	}
+	for _, bad := range []string{
+		"",
+		"00-abc-0000000000000012-01",
+		"ff-00000000000000000000000000000abc-0000000000000012-01",
+		"00-00000000000000000000000000000000-0000000000000012-01",
+		"00-00000000000000000000000000000abc-0000000000000000-01",
+		"00-0000000000000000000000000000zabc-0000000000000012-01",
+	} {
+		h := http.Header{TraceparentHeader: {bad}}
+		if _, ok := ExtractTraceparent(h); ok {
+			t.Errorf("accepted traceparent %q", bad)
+		}
+	}
+}
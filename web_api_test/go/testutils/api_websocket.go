@@ -0,0 +1,183 @@
+package testutils
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// --------------------------------------------------------------------
+// WebSocket routes
+// --------------------------------------------------------------------
+
+// WSHandler serves one WebSocket connection. ctx ends when the peer goes
+// away or the App drains its WebSockets. Returning closes the connection:
+// normally for nil, with WSCloseInternalError and the message otherwise.
+type WSHandler func(ctx context.Context, conn *WSConn) error
+
+// wsTracker records the open WebSockets of an App so shutdown can drain
+// them; http.Server.Shutdown does not see hijacked connections.
+type wsTracker struct {
+	mu       sync.Mutex
+	conns    map[*WSConn]context.CancelFunc
+	draining bool
+	wg       sync.WaitGroup
+}
+
+// WebSocket registers a WebSocket endpoint at pattern. The upgrade runs as
+// a GET handler, so middlewares see the opening handshake and can reject
+// it before any connection is established.
+//
+//	app.WebSocket("/progress", func(ctx context.Context, conn *WSConn) error {
+//		for step := 1; step <= 3; step++ {
+//			if err := conn.WriteJSON(ctx, map[string]int{"step": step}); err != nil {
+//				return err
+//			}
+//		}
+//		return nil
+//	})
+func (a *App) WebSocket(pattern string, handler WSHandler, opts ...WSOption) {
+	o := defaultWSOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a.Get(pattern, func(ctx context.Context, req *Request) (*Response, error) {
+		return a.serveWebSocket(ctx, req, handler, o)
+	})
+}
+
+func (a *App) serveWebSocket(ctx context.Context, req *Request, handler WSHandler, o wsOptions) (*Response, error) {
+	key, err := checkWSHandshake(req.Request)
+	if err != nil {
+		return nil, err
+	}
+	t := &a.websockets
+	t.mu.Lock()
+	draining := t.draining
+	t.mu.Unlock()
+	if draining {
+		return nil, &Error{Code: http.StatusServiceUnavailable, Message: "server shutting down"}
+	}
+	if req.rw == nil {
+		return nil, &Error{Code: http.StatusInternalServerError, Message: "websocket: request was not served by App"}
+	}
+
+	netConn, brw, err := req.rw.Hijack()
+	if err != nil {
+		return nil, &Error{Code: http.StatusInternalServerError, Message: "websocket: hijack failed", Cause: err}
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		wsAcceptKey(key))
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return &Response{Status: http.StatusSwitchingProtocols}, nil
+	}
+	conn := newWSConn(netConn, brw.Reader, false, o)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if !t.track(conn, cancel) {
+		conn.CloseWithCode(WSCloseGoingAway, "server shutting down")
+		return &Response{Status: http.StatusSwitchingProtocols}, nil
+	}
+	defer t.untrack(conn)
+	go func() {
+		select {
+		case <-conn.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := handler(ctx, conn); err != nil {
+		conn.CloseWithCode(WSCloseInternalError, err.Error())
+	} else {
+		conn.Close()
+	}
+	return &Response{Status: http.StatusSwitchingProtocols}, nil
+}
+
+// checkWSHandshake validates an opening handshake and returns its key.
+func checkWSHandshake(r *http.Request) (string, error) {
+	if !headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return "", &Error{Code: http.StatusBadRequest, Message: "websocket: not an upgrade request"}
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return "", &Error{Code: http.StatusUpgradeRequired, Message: "websocket: unsupported version, want 13"}
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != 16 {
+		return "", &Error{Code: http.StatusBadRequest, Message: "websocket: invalid Sec-WebSocket-Key"}
+	}
+	return key, nil
+}
+
+// headerHasToken reports whether a comma-separated header lists token.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// track registers conn unless the App is draining.
+func (t *wsTracker) track(conn *WSConn, cancel context.CancelFunc) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return false
+	}
+	if t.conns == nil {
+		t.conns = make(map[*WSConn]context.CancelFunc)
+	}
+	t.conns[conn] = cancel
+	t.wg.Add(1)
+	return true
+}
+
+func (t *wsTracker) untrack(conn *WSConn) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+	t.wg.Done()
+}
+
+// DrainWebSockets sends every open WebSocket a WSCloseGoingAway close
+// frame, cancels the handlers' contexts and waits for the handlers to
+// return or ctx to end. Upgrades are refused with 503 from then on. Run
+// calls it during shutdown; tests serving the App themselves call it
+// before closing their server.
+func (a *App) DrainWebSockets(ctx context.Context) error {
+	t := &a.websockets
+	t.mu.Lock()
+	t.draining = true
+	conns := make(map[*WSConn]context.CancelFunc, len(t.conns))
+	for conn, cancel := range t.conns {
+		conns[conn] = cancel
+	}
+	t.mu.Unlock()
+
+	for conn, cancel := range conns {
+		conn.startClose(WSCloseGoingAway, "server shutting down")
+		cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("api: draining websockets: %w", ctx.Err())
+	}
+}
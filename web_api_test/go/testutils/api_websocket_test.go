@@ -0,0 +1,284 @@
+package testutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func serveApp(t *testing.T, app *App) string {
+	t.Helper()
+	srv := httptest.NewServer(app)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		app.DrainWebSockets(ctx)
+		srv.Close()
+	})
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dialWS(t *testing.T, url string, opts ...WSOption) *WSTestClient {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialWebSocket(ctx, url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestApp_WebSocketEchoAndPush(t *testing.T) {
+	app := NewApp()
+	app.WebSocket("/echo", func(ctx context.Context, conn *WSConn) error {
+		for {
+			var msg map[string]any
+			if err := conn.ReadJSON(ctx, &msg); err != nil {
+				return nil
+			}
+			msg["echo"] = true
+			if err := conn.WriteJSON(ctx, msg); err != nil {
+				return err
+			}
+		}
+	})
+	app.WebSocket("/progress", func(ctx context.Context, conn *WSConn) error {
+		for step := 1; step <= 3; step++ {
+			if err := conn.WriteJSON(ctx, map[string]int{"step": step}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	base := serveApp(t, app)
+
+	c := dialWS(t, base+"/echo")
+	if err := c.SendJSON(context.Background(), map[string]any{"id": 7, "pad": strings.Repeat("x", 70000)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ExpectJSON(JSONHas("id", 7), time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	p := dialWS(t, base+"/progress")
+	if _, err := p.ExpectJSON(JSONHas("step", 3), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	_, err := p.ExpectJSON(JSONHas("step", 4), time.Second)
+	var closeErr *WSCloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != WSCloseNormal {
+		t.Errorf("after handler returned: %v", err)
+	}
+}
+
+func TestApp_WebSocketRejectsPlainRequests(t *testing.T) {
+	app := NewApp()
+	app.WebSocket("/ws", func(ctx context.Context, conn *WSConn) error { return nil })
+	base := serveApp(t, app)
+
+	resp, err := http.Get("http" + strings.TrimPrefix(base, "ws") + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+}
+
+func TestApp_WebSocketCloseHandshake(t *testing.T) {
+	handlerErr := make(chan error, 1)
+	app := NewApp()
+	app.WebSocket("/read", func(ctx context.Context, conn *WSConn) error {
+		_, _, err := conn.ReadMessage(ctx)
+		handlerErr <- err
+		return nil
+	})
+	app.WebSocket("/fail", func(ctx context.Context, conn *WSConn) error {
+		return errors.New("database unavailable")
+	})
+	base := serveApp(t, app)
+
+	t.Run("client initiated", func(t *testing.T) {
+		c := dialWS(t, base+"/read")
+		start := time.Now()
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("close waited %v for the server's answer", d)
+		}
+		var closeErr *WSCloseError
+		if err := <-handlerErr; !errors.As(err, &closeErr) || closeErr.Code != WSCloseNormal {
+			t.Errorf("server read: %v", err)
+		}
+		if _, _, err := c.Conn().ReadMessage(context.Background()); !errors.As(err, &closeErr) || closeErr.Code != WSCloseNormal {
+			t.Errorf("client read after close: %v", err)
+		}
+		if err := c.SendJSON(context.Background(), 1); !errors.Is(err, ErrWSClosed) {
+			t.Errorf("write after close: %v", err)
+		}
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		c := dialWS(t, base+"/fail")
+		_, _, err := c.Conn().ReadMessage(context.Background())
+		var closeErr *WSCloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != WSCloseInternalError || closeErr.Reason != "database unavailable" {
+			t.Errorf("read: %v", err)
+		}
+	})
+}
+
+func TestApp_WebSocketKeepalive(t *testing.T) {
+	handlerErr := make(chan error, 1)
+	app := NewApp()
+	app.WebSocket("/ws", func(ctx context.Context, conn *WSConn) error {
+		<-ctx.Done()
+		_, _, err := conn.ReadMessage(context.Background())
+		handlerErr <- err
+		return nil
+	}, WithWSPingInterval(20*time.Millisecond), WithWSPongTimeout(40*time.Millisecond))
+	base := serveApp(t, app)
+
+	t.Run("answered pings keep the connection", func(t *testing.T) {
+		c := dialWS(t, base+"/ws", WithWSPingInterval(0))
+		select {
+		case <-c.Conn().Done():
+			t.Fatal("connection dropped although pongs were sent")
+		case <-time.After(200 * time.Millisecond):
+		}
+		c.Close()
+		<-handlerErr
+	})
+
+	t.Run("silent peer is dropped", func(t *testing.T) {
+		raw, br := rawWSDial(t, base+"/ws")
+		// Read but never answer: the server should ping, then give up.
+		op, _ := readRawFrame(t, br)
+		if op != wsOpPing {
+			t.Fatalf("first frame opcode %d, want ping", op)
+		}
+		select {
+		case err := <-handlerErr:
+			if !errors.Is(err, errWSPongTimeout) {
+				t.Errorf("handler saw %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("unresponsive peer was not dropped")
+		}
+		raw.Close()
+	})
+}
+
+func TestApp_WebSocketDrain(t *testing.T) {
+	returned := make(chan struct{})
+	app := NewApp()
+	app.WebSocket("/ws", func(ctx context.Context, conn *WSConn) error {
+		<-ctx.Done()
+		close(returned)
+		return nil
+	})
+	base := serveApp(t, app)
+	c := dialWS(t, base+"/ws")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := app.DrainWebSockets(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-returned:
+	default:
+		t.Error("drain returned before the handler")
+	}
+	_, _, err := c.Conn().ReadMessage(ctx)
+	var closeErr *WSCloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != WSCloseGoingAway {
+		t.Errorf("client read: %v", err)
+	}
+	if _, err := DialWebSocket(ctx, base+"/ws"); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("dial after drain: %v", err)
+	}
+}
+
+func TestApp_WebSocketRejectsUnmaskedClientFrames(t *testing.T) {
+	app := NewApp()
+	app.WebSocket("/ws", func(ctx context.Context, conn *WSConn) error {
+		_, _, err := conn.ReadMessage(ctx)
+		return err
+	})
+	base := serveApp(t, app)
+
+	raw, br := rawWSDial(t, base+"/ws")
+	raw.Write([]byte{0x81, 0x02, 'h', 'i'}) // text frame without mask
+	op, payload := readRawFrame(t, br)
+	if op != wsOpClose || len(payload) < 2 || binary.BigEndian.Uint16(payload) != WSCloseProtocolError {
+		t.Errorf("got opcode %d payload %q, want close 1002", op, payload)
+	}
+}
+
+func TestWSConn_ReadRespectsContext(t *testing.T) {
+	app := NewApp()
+	app.WebSocket("/ws", func(ctx context.Context, conn *WSConn) error {
+		<-ctx.Done()
+		return nil
+	})
+	c := dialWS(t, serveApp(t, app)+"/ws")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.Conn().ReadMessage(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("read: %v", err)
+	}
+}
+
+// rawWSDial completes the opening handshake by hand and returns the bare
+// connection, for tests that must misbehave at the frame level.
+func rawWSDial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	addr := strings.TrimPrefix(url, "ws://")
+	addr, path, _ := strings.Cut(addr, "/")
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	fmt.Fprintf(conn, "GET /%s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", path, addr, key)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: %s %v", resp.Status, resp.Header)
+	}
+	return conn, br
+}
+
+// readRawFrame reads one small unmasked server frame.
+func readRawFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, head[1]&0x7F)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
@@ -0,0 +1,396 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Failure artifact bundles
+// ------------------------------------------------------------------------
+
+// DefaultArtifactMaxBytes bounds a bundle when no limit is configured.
+const DefaultArtifactMaxBytes = 100 << 20
+
+// ArtifactCollector gathers what is needed to debug a failed run — logs,
+// the redacted config, traces, recorded HTTP traffic, service logs — into
+// one timestamped directory that CI can upload. Each contributor registers
+// a dump function; collection is best-effort, so one failing dumper does
+// not stop the rest, and the bundle is truncated to a total size.
+//
+//	func TestMain(m *testing.M) {
+//		artifacts := NewArtifactCollectorFromConfig(cfg, WithArtifactLogger(logger))
+//		artifacts.Register("logs", logger.DumpArtifacts)
+//		artifacts.Register("har", recorder.DumpArtifacts)
+//		code := m.Run()
+//		artifacts.CollectOnFailure(code)
+//		os.Exit(code)
+//	}
+type ArtifactCollector struct {
+	mu       sync.Mutex
+	baseDir  string
+	maxBytes int64
+	logger   Logger
+	clock    Clock
+	dumpers  []artifactDumper
+}
+
+type artifactDumper struct {
+	name string
+	dump func(dir string) error
+}
+
+// ArtifactCollectorOption configures an ArtifactCollector.
+type ArtifactCollectorOption func(*ArtifactCollector)
+
+// WithArtifactMaxBytes bounds the total size of a bundle. Files past the
+// limit are truncated, or left empty, in registration order.
+func WithArtifactMaxBytes(n int64) ArtifactCollectorOption {
+	return func(c *ArtifactCollector) { c.maxBytes = n }
+}
+
+// WithArtifactLogger logs where bundles are written and what failed.
+func WithArtifactLogger(l Logger) ArtifactCollectorOption {
+	return func(c *ArtifactCollector) {
+		if l != nil {
+			c.logger = l
+		}
+	}
+}
+
+// WithArtifactClock sets the clock used to name bundles.
+func WithArtifactClock(clock Clock) ArtifactCollectorOption {
+	return func(c *ArtifactCollector) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// NewArtifactCollector writes bundles under baseDir.
+func NewArtifactCollector(baseDir string, opts ...ArtifactCollectorOption) *ArtifactCollector {
+	c := &ArtifactCollector{
+		baseDir:  baseDir,
+		maxBytes: DefaultArtifactMaxBytes,
+		logger:   noopLogger{},
+		clock:    RealClock{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// NewArtifactCollectorFromConfig writes bundles under Paths.DataDir (the
+// temp directory when unset) in an "artifacts" directory, bounded by
+// Paths.ArtifactMaxBytes, and registers the redacted config as "config".
+func NewArtifactCollectorFromConfig(cfg *Config, opts ...ArtifactCollectorOption) *ArtifactCollector {
+	base := cfg.Paths.DataDir
+	if base == "" {
+		base = cfg.GetTempDir()
+	}
+	if cfg.Paths.ArtifactMaxBytes > 0 {
+		opts = append([]ArtifactCollectorOption{WithArtifactMaxBytes(cfg.Paths.ArtifactMaxBytes)}, opts...)
+	}
+	c := NewArtifactCollector(filepath.Join(base, "artifacts"), opts...)
+	c.Register("config", cfg.DumpArtifacts)
+	return c
+}
+
+// artifactNamePattern keeps dumper names usable as directory names.
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Register adds a dumper. dump receives its own directory, named after
+// name, and should write its files there. It panics if name is not a
+// plain directory name or is already registered.
+func (c *ArtifactCollector) Register(name string, dump func(dir string) error) {
+	if !artifactNamePattern.MatchString(name) {
+		panic(fmt.Sprintf("ArtifactCollector.Register: invalid name %q", name))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.dumpers {
+		if d.name == name {
+			panic(fmt.Sprintf("ArtifactCollector.Register: %q is already registered", name))
+		}
+	}
+	c.dumpers = append(c.dumpers, artifactDumper{name: name, dump: dump})
+}
+
+// CollectOnFailure collects a bundle when code, the result of m.Run, is
+// non-zero, and returns its directory; it returns "" when code is zero.
+func (c *ArtifactCollector) CollectOnFailure(code int) (string, error) {
+	if code == 0 {
+		return "", nil
+	}
+	return c.CollectNow(fmt.Sprintf("exit code %d", code))
+}
+
+// ArtifactEntry is one dumper's part of a bundle.
+type ArtifactEntry struct {
+	Name      string       `json:"name"`
+	Files     []string     `json:"files,omitempty"` // relative to the bundle
+	Bytes     int64        `json:"bytes"`
+	Duration  DurationJSON `json:"duration"`
+	Truncated []string     `json:"truncated,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// ArtifactBundle describes a collected bundle. It is written to
+// bundle.json in the bundle directory.
+type ArtifactBundle struct {
+	Dir         string          `json:"dir"`
+	Reason      string          `json:"reason"`
+	CollectedAt time.Time       `json:"collected_at"`
+	MaxBytes    int64           `json:"max_bytes"`
+	Bytes       int64           `json:"bytes"`
+	Entries     []ArtifactEntry `json:"entries"`
+}
+
+// CollectNow runs every dumper into a new timestamped directory and
+// returns it. The error lists the dumpers that failed; whatever they and
+// the others wrote is kept.
+func (c *ArtifactCollector) CollectNow(reason string) (string, error) {
+	c.mu.Lock()
+	dumpers := append([]artifactDumper(nil), c.dumpers...)
+	c.mu.Unlock()
+
+	now := c.clock.Now()
+	if err := os.MkdirAll(c.baseDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	dir, err := os.MkdirTemp(c.baseDir, now.UTC().Format("20060102-150405")+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create artifact bundle: %w", err)
+	}
+
+	bundle := ArtifactBundle{Dir: dir, Reason: reason, CollectedAt: now, MaxBytes: c.maxBytes}
+	errs := NewCompositeError("collect artifacts")
+	for _, d := range dumpers {
+		entry := ArtifactEntry{Name: d.name}
+		sub := filepath.Join(dir, d.name)
+		start := time.Now()
+		err := os.MkdirAll(sub, 0o755)
+		if err == nil {
+			err = runArtifactDumper(d.dump, sub)
+		}
+		entry.Duration = DurationJSON(time.Since(start))
+		if err != nil {
+			entry.Error = err.Error()
+			errs.Add(fmt.Errorf("%s: %w", d.name, err))
+		}
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+	bundle.Bytes = c.enforceLimit(dir, bundle.Entries)
+
+	data, _ := json.MarshalIndent(bundle, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "bundle.json"), data, 0o644); err != nil {
+		errs.Add(fmt.Errorf("bundle.json: %w", err))
+	}
+
+	fields := map[string]any{"dir": dir, "reason": reason, "bytes": bundle.Bytes}
+	if errs.HasErrors() {
+		fields["error"] = errs.Error()
+		c.logger.Warn("artifacts collected with errors", fields)
+		return dir, errs
+	}
+	c.logger.Info("artifacts collected", fields)
+	return dir, nil
+}
+
+// runArtifactDumper turns a panicking dumper into an error.
+func runArtifactDumper(dump func(string) error, dir string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("dumper panicked: %v", r)
+		}
+	}()
+	return dump(dir)
+}
+
+// enforceLimit lists each entry's files and truncates those past the
+// size limit, in registration order and then by path. It returns the
+// total size kept.
+func (c *ArtifactCollector) enforceLimit(dir string, entries []ArtifactEntry) int64 {
+	var total int64
+	for i := range entries {
+		e := &entries[i]
+		var files []string
+		filepath.WalkDir(filepath.Join(dir, e.Name), func(path string, d fs.DirEntry, err error) error {
+			if err == nil && d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		sort.Strings(files)
+		for _, path := range files {
+			fi, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			rel, _ := filepath.Rel(dir, path)
+			e.Files = append(e.Files, filepath.ToSlash(rel))
+			size := fi.Size()
+			if c.maxBytes > 0 && total+size > c.maxBytes {
+				size = c.maxBytes - total
+				if size < 0 {
+					size = 0
+				}
+				if os.Truncate(path, size) == nil {
+					e.Truncated = append(e.Truncated, filepath.ToSlash(rel))
+				} else {
+					size = fi.Size()
+				}
+			}
+			e.Bytes += size
+			total += size
+		}
+	}
+	return total
+}
+
+// ------------------------------------------------------------------------
+// Artifact dumpers of the package's own types
+// ------------------------------------------------------------------------
+
+// writeArtifactJSON writes v, indented, to dir/name.
+func writeArtifactJSON(dir, name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), data, 0o644)
+}
+
+// DumpArtifacts writes the redacted configuration to config.json.
+func (c *Config) DumpArtifacts(dir string) error {
+	return writeArtifactJSON(dir, "config.json", c.Redacted())
+}
+
+// DumpArtifacts writes the recorded traffic to requests.har.
+func (h *HARRecorder) DumpArtifacts(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "requests.har"))
+	if err != nil {
+		return err
+	}
+	if err := h.ExportHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// spanArtifact is the JSON form of a Span in a bundle.
+type spanArtifact struct {
+	TraceID  string         `json:"trace_id"`
+	SpanID   string         `json:"span_id"`
+	ParentID string         `json:"parent_id,omitempty"`
+	Name     string         `json:"name"`
+	Start    time.Time      `json:"start"`
+	Duration DurationJSON   `json:"duration"`
+	Status   int            `json:"status"`
+	Message  string         `json:"message,omitempty"`
+	Tags     map[string]any `json:"tags,omitempty"`
+	Logs     []SpanLog      `json:"logs,omitempty"`
+}
+
+func newSpanArtifact(s Span) spanArtifact {
+	a := spanArtifact{
+		TraceID:  s.Context.TraceID,
+		SpanID:   s.Context.SpanID,
+		ParentID: s.Context.ParentID,
+		Name:     s.Name,
+		Start:    s.StartTime,
+		Status:   s.Status.Code,
+		Message:  s.Status.Message,
+		Logs:     s.Logs,
+	}
+	if !s.EndTime.IsZero() {
+		a.Duration = DurationJSON(s.EndTime.Sub(s.StartTime))
+	}
+	if len(s.Tags) > 0 {
+		a.Tags = make(map[string]any, len(s.Tags))
+		for k, v := range s.Tags {
+			a.Tags[k] = artifactValue(v)
+		}
+	}
+	for i, l := range a.Logs {
+		fields := make(map[string]any, len(l.Fields))
+		for k, v := range l.Fields {
+			fields[k] = artifactValue(v)
+		}
+		a.Logs[i].Fields = fields
+	}
+	return a
+}
+
+// artifactValue makes a tag value safe to encode: errors and values that
+// JSON cannot represent are written as text.
+func artifactValue(v any) any {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return FormatDuration(v, 0)
+	}
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
+
+// DumpArtifacts writes the retained spans, orphans and tracer stats to
+// spans.json.
+func (t *InMemoryTracer) DumpArtifacts(dir string) error {
+	spans, orphans := t.Spans(), t.Orphans()
+	out := struct {
+		Stats   TracerStats    `json:"stats"`
+		Spans   []spanArtifact `json:"spans"`
+		Orphans []spanArtifact `json:"orphans,omitempty"`
+	}{Stats: t.Stats(), Spans: make([]spanArtifact, 0, len(spans))}
+	for _, s := range spans {
+		out.Spans = append(out.Spans, newSpanArtifact(s))
+	}
+	for _, s := range orphans {
+		out.Orphans = append(out.Orphans, newSpanArtifact(s))
+	}
+	return writeArtifactJSON(dir, "spans.json", out)
+}
+
+// testFileArtifact is one row of a TestDataManager manifest.
+type testFileArtifact struct {
+	Path    string      `json:"path"` // relative to the test directory
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+// DumpArtifacts writes manifest.json, listing every file in the test
+// directory, without copying their contents.
+func (tdm *TestDataManager) DumpArtifacts(dir string) error {
+	root := tdm.GetTestDir()
+	files := []testFileArtifact{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed mid-walk
+		}
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, testFileArtifact{Path: filepath.ToSlash(rel), Size: fi.Size(), Mode: fi.Mode(), ModTime: fi.ModTime()})
+		return nil
+	})
+	if werr := writeArtifactJSON(dir, "manifest.json", map[string]any{"dir": root, "files": files}); werr != nil {
+		return werr
+	}
+	return err
+}
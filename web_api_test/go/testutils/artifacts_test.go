@@ -0,0 +1,165 @@
+package testutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readBundle(t *testing.T, dir string) ArtifactBundle {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "bundle.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle ArtifactBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func TestArtifactCollector_BestEffortAndBounded(t *testing.T) {
+	base := t.TempDir()
+	clock := NewFakeClock(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC))
+	c := NewArtifactCollector(base, WithArtifactMaxBytes(1500), WithArtifactClock(clock))
+	c.Register("first", func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Repeat("a", 1000)), 0o644)
+	})
+	c.Register("broken", func(dir string) error {
+		os.WriteFile(filepath.Join(dir, "partial.txt"), []byte("half"), 0o644)
+		return errors.New("service unreachable")
+	})
+	c.Register("panicky", func(dir string) error { panic("nil map") })
+	c.Register("big", func(dir string) error {
+		return os.WriteFile(filepath.Join(dir, "b.txt"), []byte(strings.Repeat("b", 1000)), 0o644)
+	})
+
+	if dir, err := c.CollectOnFailure(0); dir != "" || err != nil {
+		t.Fatalf("a passing run should not collect, got %q, %v", dir, err)
+	}
+	dir, err := c.CollectNow("exit code 1")
+	if err == nil || !strings.Contains(err.Error(), "service unreachable") || !strings.Contains(err.Error(), "nil map") {
+		t.Errorf("expected both failures reported, got %v", err)
+	}
+	if filepath.Dir(dir) != base || !strings.HasPrefix(filepath.Base(dir), "20240301-123000-") {
+		t.Errorf("unexpected bundle directory %s", dir)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dir, "broken", "partial.txt")); string(data) != "half" {
+		t.Error("a failing dumper's output should be kept")
+	}
+	if fi, _ := os.Stat(filepath.Join(dir, "big", "b.txt")); fi == nil || fi.Size() != 496 {
+		t.Errorf("expected b.txt truncated to the remaining 496 bytes, got %v", fi)
+	}
+
+	bundle := readBundle(t, dir)
+	if bundle.Reason != "exit code 1" || bundle.Bytes != 1500 || len(bundle.Entries) != 4 {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+	if e := bundle.Entries[3]; e.Name != "big" || len(e.Truncated) != 1 || e.Truncated[0] != "big/b.txt" {
+		t.Errorf("truncation not recorded: %+v", e)
+	}
+	if e := bundle.Entries[2]; !strings.Contains(e.Error, "panicked") {
+		t.Errorf("panic not recorded: %+v", e)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a path as a name to panic")
+		}
+	}()
+	c.Register("../escape", func(string) error { return nil })
+}
+
+func TestArtifactCollector_PackageDumpers(t *testing.T) {
+	logger := NewTestLogger("artifacts", io.Discard, WithEntryCapture(2))
+	logger.Info("one", nil)
+	logger.WithField("user", 7).Info("two", nil)
+	logger.Warn("three", map[string]any{"bad": func() {}})
+	if got := logger.CapturedEntries(); len(got) != 2 || got[0].Message != "two" || got[1].Message != "three" {
+		t.Fatalf("expected the last two entries, got %+v", got)
+	}
+
+	tracer := NewInMemoryTracer()
+	_, span := tracer.StartSpan(context.Background(), "GET /users", WithTag("error", errors.New("boom")))
+	tracer.EndSpan(span)
+
+	cfg := DefaultConfig()
+	cfg.Logger.DefaultFields["api_token"] = "hunter2"
+	cfg.Metrics.StatsDAddress = "statsd.internal:8125"
+
+	c := NewArtifactCollector(t.TempDir())
+	c.Register("logs", logger.DumpArtifacts)
+	c.Register("traces", tracer.DumpArtifacts)
+	c.Register("config", cfg.DumpArtifacts)
+	dir, err := c.CollectNow("manual")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "logs", "log.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []LogEntry
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		var entry LogEntry
+		if err := json.Unmarshal(sc.Bytes(), &entry); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 2 || lines[0].Fields["user"] != float64(7) {
+		t.Errorf("unexpected log lines: %+v", lines)
+	}
+
+	spans, _ := os.ReadFile(filepath.Join(dir, "traces", "spans.json"))
+	for _, want := range []string{`"name": "GET /users"`, `"error": "boom"`} {
+		if !strings.Contains(string(spans), want) {
+			t.Errorf("expected %s in spans.json:\n%s", want, spans)
+		}
+	}
+
+	config, _ := os.ReadFile(filepath.Join(dir, "config", "config.json"))
+	if len(config) == 0 || strings.Contains(string(config), "hunter2") || strings.Contains(string(config), "statsd.internal") {
+		t.Errorf("config.json should be written and redacted:\n%s", config)
+	}
+}
+
+func TestHarness_CloseOnExitCollectsArtifacts(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Paths.DataDir = t.TempDir()
+	cfg.Paths.CollectArtifacts = true
+	h := NewHarness(cfg, WithHarnessLogger(NewTestLogger("exit", io.Discard, WithEntryCapture(10))))
+	h.Logger.Info("about to fail", nil)
+
+	if err := h.CloseOnExit(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(cfg.Paths.DataDir, "artifacts")); len(entries) != 0 {
+		t.Fatalf("a passing run should leave no bundle, found %d", len(entries))
+	}
+
+	if err := h.CloseOnExit(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(cfg.Paths.DataDir, "artifacts"))
+	if len(entries) != 1 {
+		t.Fatalf("expected one bundle, found %d", len(entries))
+	}
+	dir := filepath.Join(cfg.Paths.DataDir, "artifacts", entries[0].Name())
+	for _, name := range []string{"config/config.json", "logs/log.jsonl"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s in the bundle: %v", name, err)
+		}
+	}
+}
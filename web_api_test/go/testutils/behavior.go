@@ -4,6 +4,9 @@
 package testutils
 
 import (
+    "fmt"
+    "reflect"
+    "strings"
     "sync"
     "time"
 )
@@ -35,6 +38,7 @@ type BehaviorCall struct {
     Action    string // for Perform
     Args      []interface{}
     Timestamp time.Time
+    Stub      *PerformStub // matched OnPerform stub, nil if none
 }
 
 // MockBehavior implements Behavior for unit tests.
@@ -57,6 +61,9 @@ type MockBehavior struct {
         val interface{}
         err error
     }
+    stubs          []*PerformStub
+    defaultVal     interface{}
+    defaultErr     error
 }
 
 // NewMockBehavior creates a new mock behavior.
@@ -220,13 +227,176 @@ func (m *MockBehavior) Perform(action string, args ...interface{}) (interface{},
         m.mu.Unlock()
         return res.val, res.err
     }
+    if stub := m.matchStubLocked(action, args); stub != nil {
+        stub.hits++
+        m.calls[len(m.calls)-1].Stub = stub
+        val, err, fn, panicMsg, panics := stub.val, stub.err, stub.fn, stub.panicMsg, stub.panics
+        m.mu.Unlock()
+        if panics {
+            panic(panicMsg)
+        }
+        if fn != nil {
+            return fn(args...)
+        }
+        return val, err
+    }
     if m.performFunc != nil {
         fn := m.performFunc
         m.mu.Unlock()
         return fn(action, args...)
     }
+    val, err := m.defaultVal, m.defaultErr
     m.mu.Unlock()
-    return nil, nil
+    return val, err
+}
+
+// SetDefaultPerformResult sets what Perform returns when no injected result,
+// stub or perform function applies. The default is (nil, nil).
+func (m *MockBehavior) SetDefaultPerformResult(val interface{}, err error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.defaultVal = val
+    m.defaultErr = err
+}
+
+// OnPerform registers a stub for Perform calls with the given action whose
+// leading arguments satisfy matchers (extra arguments are ignored). Stubs are
+// tried in registration order; results injected with InjectPerformResult
+// still take precedence.
+//
+//    m.OnPerform("read", StringPrefix("/tmp")).Return("data", nil)
+func (m *MockBehavior) OnPerform(action string, matchers ...ArgMatcher) *PerformStub {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    stub := &PerformStub{mock: m, action: action, matchers: matchers}
+    m.stubs = append(m.stubs, stub)
+    return stub
+}
+
+// Verify reports every stub marked Required that was never matched.
+func (m *MockBehavior) Verify(t testingT) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    for _, stub := range m.stubs {
+        if stub.required && stub.hits == 0 {
+            t.Errorf("required stub %s was never called", stub)
+        }
+    }
+}
+
+// matchStubLocked returns the first stub matching the call. Must be called
+// with m.mu held.
+func (m *MockBehavior) matchStubLocked(action string, args []interface{}) *PerformStub {
+    for _, stub := range m.stubs {
+        if stub.matches(action, args) {
+            return stub
+        }
+    }
+    return nil
+}
+
+// --------------------------------------------------------------------
+// PerformStub / ArgMatcher – argument-matched Perform expectations.
+// --------------------------------------------------------------------
+
+// ArgMatcher reports whether a single Perform argument matches. Any
+// func(interface{}) bool can be passed where an ArgMatcher is expected.
+type ArgMatcher func(arg interface{}) bool
+
+// AnyArg matches every argument.
+func AnyArg() ArgMatcher {
+    return func(interface{}) bool { return true }
+}
+
+// Eq matches arguments deeply equal to v.
+func Eq(v interface{}) ArgMatcher {
+    return func(arg interface{}) bool { return reflect.DeepEqual(arg, v) }
+}
+
+// StringContains matches string arguments containing sub.
+func StringContains(sub string) ArgMatcher {
+    return func(arg interface{}) bool {
+        s, ok := arg.(string)
+        return ok && strings.Contains(s, sub)
+    }
+}
+
+// StringPrefix matches string arguments starting with prefix.
+func StringPrefix(prefix string) ArgMatcher {
+    return func(arg interface{}) bool {
+        s, ok := arg.(string)
+        return ok && strings.HasPrefix(s, prefix)
+    }
+}
+
+// PerformStub is a programmed Perform response created by OnPerform.
+type PerformStub struct {
+    mock     *MockBehavior
+    action   string
+    matchers []ArgMatcher
+    val      interface{}
+    err      error
+    fn       func(args ...interface{}) (interface{}, error)
+    panicMsg string
+    panics   bool
+    required bool
+    hits     int
+}
+
+// Return makes the stub return val and err.
+func (s *PerformStub) Return(val interface{}, err error) *PerformStub {
+    s.mock.mu.Lock()
+    defer s.mock.mu.Unlock()
+    s.val, s.err, s.fn, s.panics = val, err, nil, false
+    return s
+}
+
+// ReturnFunc makes the stub compute its result from the call arguments.
+func (s *PerformStub) ReturnFunc(fn func(args ...interface{}) (interface{}, error)) *PerformStub {
+    s.mock.mu.Lock()
+    defer s.mock.mu.Unlock()
+    s.fn, s.panics = fn, false
+    return s
+}
+
+// Panic makes the stub panic with msg.
+func (s *PerformStub) Panic(msg string) *PerformStub {
+    s.mock.mu.Lock()
+    defer s.mock.mu.Unlock()
+    s.panicMsg, s.panics = msg, true
+    return s
+}
+
+// Required marks the stub so Verify fails if it is never matched.
+func (s *PerformStub) Required() *PerformStub {
+    s.mock.mu.Lock()
+    defer s.mock.mu.Unlock()
+    s.required = true
+    return s
+}
+
+// Hits returns how many Perform calls matched this stub.
+func (s *PerformStub) Hits() int {
+    s.mock.mu.Lock()
+    defer s.mock.mu.Unlock()
+    return s.hits
+}
+
+// String describes the stub for failure messages.
+func (s *PerformStub) String() string {
+    return fmt.Sprintf("OnPerform(%q, %d matchers)", s.action, len(s.matchers))
+}
+
+func (s *PerformStub) matches(action string, args []interface{}) bool {
+    if s.action != action || len(args) < len(s.matchers) {
+        return false
+    }
+    for i, match := range s.matchers {
+        if match != nil && !match(args[i]) {
+            return false
+        }
+    }
+    return true
 }
 
 // Calls returns a copy of all recorded calls.
@@ -266,6 +436,9 @@ func (m *MockBehavior) Reset() {
     m.stopFunc = nil
     m.statusFunc = nil
     m.performFunc = nil
+    m.stubs = nil
+    m.defaultVal = nil
+    m.defaultErr = nil
 }
 
 // --------------------------------------------------------------------
@@ -0,0 +1,135 @@
+package testutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMockBehavior_OnPerform(t *testing.T) {
+	m := NewMockBehavior()
+	m.SetDefaultPerformResult("default", nil)
+	tmp := m.OnPerform("read", StringPrefix("/tmp")).Return("tmp data", nil)
+	m.OnPerform("read", Eq("/etc/hosts"), AnyArg()).Return(nil, errors.New("permission denied"))
+	m.OnPerform("sum").ReturnFunc(func(args ...interface{}) (interface{}, error) {
+		total := 0
+		for _, a := range args {
+			total += a.(int)
+		}
+		return total, nil
+	})
+	m.OnPerform("write", StringContains("readonly")).Panic("write to a read-only path")
+
+	for _, tc := range []struct {
+		action  string
+		args    []interface{}
+		want    interface{}
+		wantErr string
+	}{
+		{"read", []interface{}{"/tmp/a", "ignored extra"}, "tmp data", ""},
+		{"read", []interface{}{"/etc/hosts", 0}, nil, "permission denied"},
+		{"read", []interface{}{"/etc/hosts"}, "default", ""}, // too few args for the second stub
+		{"read", []interface{}{42}, "default", ""},
+		{"sum", []interface{}{1, 2, 3}, 6, ""},
+		{"write", []interface{}{"/data"}, "default", ""},
+	} {
+		got, err := m.Perform(tc.action, tc.args...)
+		if got != tc.want || (err == nil) != (tc.wantErr == "") || (err != nil && err.Error() != tc.wantErr) {
+			t.Errorf("Perform(%s, %v) = %v, %v; want %v, %q", tc.action, tc.args, got, err, tc.want, tc.wantErr)
+		}
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "write to a read-only path" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+		m.Perform("write", "/readonly/x")
+	}()
+
+	if tmp.Hits() != 1 {
+		t.Errorf("tmp stub hits = %d", tmp.Hits())
+	}
+	calls := m.Calls()
+	if calls[0].Stub != tmp || calls[2].Stub != nil {
+		t.Errorf("calls do not record the matched stub: %+v", calls[:3])
+	}
+}
+
+func TestMockBehavior_PerformPrecedence(t *testing.T) {
+	m := NewMockBehavior()
+	m.OnPerform("ping").Return("stub", nil)
+	m.SetPerformFunc(func(action string, args ...interface{}) (interface{}, error) { return "func", nil })
+	m.InjectPerformResult(2, "injected", nil)
+
+	var got []interface{}
+	for i := 0; i < 3; i++ {
+		v, _ := m.Perform("ping")
+		got = append(got, v)
+	}
+	v, _ := m.Perform("other")
+	got = append(got, v)
+	if want := []interface{}{"stub", "injected", "stub", "func"}; len(got) != len(want) ||
+		got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Errorf("results %v, want %v", got, want)
+	}
+
+	m.Reset()
+	if v, err := m.Perform("ping"); v != nil || err != nil {
+		t.Errorf("Reset kept stubs or functions: %v, %v", v, err)
+	}
+	if _, _, _, perform := m.CallCounts(); perform != 1 {
+		t.Errorf("perform count %d after Reset", perform)
+	}
+}
+
+func TestMockBehavior_Verify(t *testing.T) {
+	m := NewMockBehavior()
+	m.OnPerform("connect").Required()
+	m.OnPerform("query", Eq("SELECT 1")).Return(1, nil).Required()
+	m.OnPerform("close") // optional
+
+	m.Perform("query", "SELECT 1")
+	rec := &recordingT{}
+	m.Verify(rec)
+	if f := rec.failures(); len(f) != 1 || !strings.Contains(f[0], `OnPerform("connect", 0 matchers)`) {
+		t.Errorf("failures %v", f)
+	}
+
+	m.Perform("connect")
+	rec = &recordingT{}
+	m.Verify(rec)
+	if f := rec.failures(); len(f) != 0 {
+		t.Errorf("all required stubs matched, got %v", f)
+	}
+}
+
+func TestMockBehavior_LifecycleInjection(t *testing.T) {
+	m := NewMockBehavior()
+	boom := errors.New("boom")
+	m.InjectStartError(2, boom)
+	m.InjectStatusValue(1, "warming")
+
+	if err := m.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Start(); err != boom {
+		t.Errorf("second Start = %v", err)
+	}
+	if s, _ := m.Status(); s != "warming" {
+		t.Errorf("first Status = %s", s)
+	}
+	if s, _ := m.Status(); s != "unknown" {
+		t.Errorf("second Status = %s", s)
+	}
+
+	rec := &recordingT{}
+	a := NewBehaviorAssertions(rec)
+	a.AssertStartCalled(m)
+	a.AssertStopCalled(m)
+	a.AssertPerformCalled(m, "read")
+	if f := rec.failures(); len(f) != 2 {
+		t.Errorf("failures %v, want Stop and Perform reported", f)
+	}
+}
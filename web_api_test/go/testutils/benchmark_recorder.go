@@ -0,0 +1,275 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// BenchmarkRecorder – named timings, results.json and regression checks
+// --------------------------------------------------------------------
+
+// BenchmarkResultsFile is the name WriteResults gives the results file.
+const BenchmarkResultsFile = "results.json"
+
+// BenchmarkRecorder collects durations per operation name across
+// iterations. Samples come from Record, Start/Stop, Stopwatch laps or
+// RunConcurrently (see WithBenchmarkRecorder). It is safe for concurrent use.
+type BenchmarkRecorder struct {
+	mu      sync.Mutex
+	clock   Clock
+	samples map[string]*DurationCollection
+	errors  map[string]int
+}
+
+// NewBenchmarkRecorder creates an empty recorder. A nil clock uses the real
+// clock.
+func NewBenchmarkRecorder(clock Clock) *BenchmarkRecorder {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &BenchmarkRecorder{
+		clock:   clock,
+		samples: make(map[string]*DurationCollection),
+		errors:  make(map[string]int),
+	}
+}
+
+// Add records one sample for name. A non-nil err is counted; the duration
+// is kept either way.
+func (r *BenchmarkRecorder) Add(name string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.samples[name]
+	if !ok {
+		c = NewDurationCollection()
+		r.samples[name] = c
+	}
+	c.Add(d)
+	if err != nil {
+		r.errors[name]++
+	}
+}
+
+// Record runs fn once, records its duration under name and returns fn's
+// error.
+func (r *BenchmarkRecorder) Record(name string, fn func() error) error {
+	start := r.clock.Now()
+	err := fn()
+	r.Add(name, r.clock.Now().Sub(start), err)
+	return err
+}
+
+// BenchmarkTimer is an operation started with BenchmarkRecorder.Start.
+type BenchmarkTimer struct {
+	rec   *BenchmarkRecorder
+	name  string
+	start time.Time
+	once  sync.Once
+}
+
+// Start begins timing one iteration of name; call Stop when it is done.
+func (r *BenchmarkRecorder) Start(name string) *BenchmarkTimer {
+	return &BenchmarkTimer{rec: r, name: name, start: r.clock.Now()}
+}
+
+// Stop records the time since Start and returns it. Only the first call
+// records a sample.
+func (t *BenchmarkTimer) Stop() time.Duration {
+	d := t.rec.clock.Now().Sub(t.start)
+	t.once.Do(func() { t.rec.Add(t.name, d, nil) })
+	return d
+}
+
+// AddStopwatch records each of sw's laps under the stopwatch's name.
+func (r *BenchmarkRecorder) AddStopwatch(sw *Stopwatch) {
+	sw.mu.Lock()
+	name, laps := sw.name, sw.laps.Values()
+	sw.mu.Unlock()
+	for _, d := range laps {
+		r.Add(name, d, nil)
+	}
+}
+
+// BenchmarkMetric is the summary of one operation.
+type BenchmarkMetric struct {
+	Stats  DurationStats `json:"stats"`
+	Errors int           `json:"errors,omitempty"`
+}
+
+// BenchmarkResults is the content of results.json.
+type BenchmarkResults struct {
+	GeneratedAt time.Time                  `json:"generated_at"`
+	Metrics     map[string]BenchmarkMetric `json:"metrics"`
+}
+
+// Results summarises every operation recorded so far.
+func (r *BenchmarkRecorder) Results() BenchmarkResults {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := BenchmarkResults{
+		GeneratedAt: r.clock.Now().UTC(),
+		Metrics:     make(map[string]BenchmarkMetric, len(r.samples)),
+	}
+	for name, c := range r.samples {
+		res.Metrics[name] = BenchmarkMetric{Stats: NewDurationStats(c), Errors: r.errors[name]}
+	}
+	return res
+}
+
+// WriteResults writes Results as results.json in tdm's test directory and
+// returns its path.
+func (r *BenchmarkRecorder) WriteResults(tdm *TestDataManager) (string, error) {
+	return tdm.CreateJSONFile(BenchmarkResultsFile, r.Results())
+}
+
+// LoadBenchmarkResults reads a file written by WriteResults.
+func LoadBenchmarkResults(path string) (BenchmarkResults, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BenchmarkResults{}, fmt.Errorf("read benchmark results: %w", err)
+	}
+	var res BenchmarkResults
+	if err := json.Unmarshal(data, &res); err != nil {
+		return BenchmarkResults{}, fmt.Errorf("parse benchmark results %s: %w", path, err)
+	}
+	return res, nil
+}
+
+// RegressionThreshold fails a comparison when Metric grows by more than
+// MaxIncrease (0.2 for +20%) over the baseline. Name limits the threshold
+// to one operation; empty applies it to all.
+type RegressionThreshold struct {
+	Name        string
+	Metric      string // min, mean, median, p90, p95, p99 or max
+	MaxIncrease float64
+}
+
+// DefaultRegressionThreshold is used by Compare when no thresholds are
+// given: p95 may grow by at most 20%.
+var DefaultRegressionThreshold = RegressionThreshold{Metric: "p95", MaxIncrease: 0.2}
+
+func durationMetric(s DurationStats, metric string) (time.Duration, bool) {
+	switch strings.ToLower(metric) {
+	case "min":
+		return s.Min, true
+	case "mean":
+		return s.Mean, true
+	case "median", "p50":
+		return s.Median, true
+	case "p90":
+		return s.P90, true
+	case "p95":
+		return s.P95, true
+	case "p99":
+		return s.P99, true
+	case "max":
+		return s.Max, true
+	}
+	return 0, false
+}
+
+// MetricComparison is the outcome of one threshold for one operation.
+type MetricComparison struct {
+	Name     string        `json:"name"`
+	Metric   string        `json:"metric"`
+	Baseline time.Duration `json:"baseline_ns"`
+	Current  time.Duration `json:"current_ns"`
+	Change   float64       `json:"change"` // relative; 0.25 is 25% slower
+	Limit    float64       `json:"limit"`
+	Pass     bool          `json:"pass"`
+}
+
+// BenchmarkComparison lists every checked metric, sorted by name.
+type BenchmarkComparison struct {
+	Metrics []MetricComparison `json:"metrics"`
+}
+
+// Passed reports whether no metric regressed beyond its threshold.
+func (c *BenchmarkComparison) Passed() bool {
+	for _, m := range c.Metrics {
+		if !m.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// Failures returns the metrics that regressed.
+func (c *BenchmarkComparison) Failures() []MetricComparison {
+	var out []MetricComparison
+	for _, m := range c.Metrics {
+		if !m.Pass {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// String renders one line per metric.
+func (c *BenchmarkComparison) String() string {
+	var b strings.Builder
+	for _, m := range c.Metrics {
+		verdict := "ok"
+		if !m.Pass {
+			verdict = "REGRESSED"
+		}
+		fmt.Fprintf(&b, "%s %s: %s -> %s (%+.1f%%, limit +%.0f%%) %s\n",
+			m.Name, m.Metric, HumanDuration(m.Baseline), HumanDuration(m.Current),
+			m.Change*100, m.Limit*100, verdict)
+	}
+	return b.String()
+}
+
+// Compare checks the current results against the baseline results.json at
+// baselinePath. Operations missing from either side are skipped, so adding
+// a benchmark never fails the comparison. Without thresholds
+// DefaultRegressionThreshold applies to every operation.
+func (r *BenchmarkRecorder) Compare(baselinePath string, thresholds ...RegressionThreshold) (*BenchmarkComparison, error) {
+	baseline, err := LoadBenchmarkResults(baselinePath)
+	if err != nil {
+		return nil, err
+	}
+	if len(thresholds) == 0 {
+		thresholds = []RegressionThreshold{DefaultRegressionThreshold}
+	}
+	for _, th := range thresholds {
+		if _, ok := durationMetric(DurationStats{}, th.Metric); !ok {
+			return nil, withKind(ErrValidation, fmt.Errorf("unknown benchmark metric %q", th.Metric))
+		}
+	}
+
+	current := r.Results()
+	names := make([]string, 0, len(current.Metrics))
+	for name := range current.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmp := &BenchmarkComparison{}
+	for _, name := range names {
+		base, ok := baseline.Metrics[name]
+		if !ok || base.Stats.Count == 0 {
+			continue
+		}
+		for _, th := range thresholds {
+			if th.Name != "" && th.Name != name {
+				continue
+			}
+			was, _ := durationMetric(base.Stats, th.Metric)
+			now, _ := durationMetric(current.Metrics[name].Stats, th.Metric)
+			m := MetricComparison{Name: name, Metric: th.Metric, Baseline: was, Current: now, Limit: th.MaxIncrease}
+			if was > 0 {
+				m.Change = float64(now-was) / float64(was)
+			}
+			m.Pass = m.Change <= th.MaxIncrease
+			cmp.Metrics = append(cmp.Metrics, m)
+		}
+	}
+	return cmp, nil
+}
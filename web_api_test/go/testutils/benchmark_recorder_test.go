@@ -0,0 +1,128 @@
+package testutils
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// timedRecorder records ops whose durations are given in milliseconds,
+// advancing a mock clock inside each Record call.
+func timedRecorder(ops map[string][]int) *BenchmarkRecorder {
+	clock := NewMockClock(time.Time{})
+	rec := NewBenchmarkRecorder(clock)
+	for name, ms := range ops {
+		for _, n := range ms {
+			rec.Record(name, func() error {
+				clock.Advance(time.Duration(n) * time.Millisecond)
+				return nil
+			})
+		}
+	}
+	return rec
+}
+
+func writeBaseline(t *testing.T, rec *BenchmarkRecorder) string {
+	t.Helper()
+	data, err := json.Marshal(rec.Results())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), BenchmarkResultsFile)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBenchmarkRecorder_Aggregates(t *testing.T) {
+	clock := NewMockClock(time.Time{})
+	rec := NewBenchmarkRecorder(clock)
+	boom := errors.New("boom")
+	if err := rec.Record("scan", func() error { clock.Advance(10 * time.Millisecond); return boom }); err != boom {
+		t.Errorf("Record returned %v", err)
+	}
+	timer := rec.Start("scan")
+	clock.Advance(30 * time.Millisecond)
+	if d := timer.Stop(); d != 30*time.Millisecond {
+		t.Errorf("Stop = %v", d)
+	}
+	timer.Stop()
+
+	sw := NewStopwatch("copy", clock)
+	clock.Advance(5 * time.Millisecond)
+	sw.Lap()
+	rec.AddStopwatch(sw)
+
+	res := rec.Results()
+	scan := res.Metrics["scan"]
+	if scan.Stats.Count != 2 || scan.Stats.Min != 10*time.Millisecond || scan.Stats.Max != 30*time.Millisecond || scan.Errors != 1 {
+		t.Errorf("scan = %+v", scan)
+	}
+	if res.Metrics["copy"].Stats.Count != 1 {
+		t.Errorf("copy = %+v", res.Metrics["copy"])
+	}
+
+	loaded, err := LoadBenchmarkResults(writeBaseline(t, rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Metrics["scan"].Stats.P95 != scan.Stats.P95 {
+		t.Errorf("round trip p95 %v, want %v", loaded.Metrics["scan"].Stats.P95, scan.Stats.P95)
+	}
+}
+
+func TestBenchmarkRecorder_Compare(t *testing.T) {
+	baseline := writeBaseline(t, timedRecorder(map[string][]int{
+		"scan": {10, 10, 10, 10},
+		"copy": {100, 100},
+		"gone": {1},
+	}))
+	current := timedRecorder(map[string][]int{
+		"scan": {11, 11, 11, 11}, // +10%
+		"copy": {130, 130},       // +30%
+		"new":  {500},            // no baseline
+	})
+
+	cmp, err := current.Compare(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Passed() || len(cmp.Metrics) != 2 {
+		t.Fatalf("comparison:\n%s", cmp)
+	}
+	if f := cmp.Failures(); len(f) != 1 || f[0].Name != "copy" || f[0].Metric != "p95" {
+		t.Errorf("failures = %+v", f)
+	}
+
+	cmp, err = current.Compare(baseline,
+		RegressionThreshold{Metric: "p95", MaxIncrease: 0.2},
+		RegressionThreshold{Name: "copy", Metric: "p95", MaxIncrease: 0.5},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := cmp.Failures(); len(f) != 1 || f[0].Limit != 0.2 {
+		t.Errorf("failures with per-name threshold = %+v", f)
+	}
+
+	if _, err := current.Compare(baseline, RegressionThreshold{Metric: "p42"}); !errors.Is(err, ErrValidation) {
+		t.Errorf("unknown metric: err = %v", err)
+	}
+	if _, err := current.Compare(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing baseline accepted")
+	}
+}
+
+func TestBenchmarkRecorder_RunConcurrently(t *testing.T) {
+	rec := NewBenchmarkRecorder(nil)
+	RunConcurrently(t, 3, 4, func(worker, iter int) error { return nil },
+		WithBenchmarkRecorder(rec, "noop"), WithWarmup(1), WithReportLogger(NewTestLogger("bench", io.Discard)))
+	if n := rec.Results().Metrics["noop"].Stats.Count; n != 9 {
+		t.Errorf("recorded %d calls, want 9", n)
+	}
+}
@@ -0,0 +1,233 @@
+package testutils
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Typed parameter accessors
+// --------------------------------------------------------------------
+
+// QueryInt returns the query parameter as an int, or def if it is absent.
+// A malformed value yields a 400 *Error naming the parameter.
+func (r *Request) QueryInt(name string, def int) (int, error) {
+	raw, ok := r.firstQuery(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, paramError("query", name, "int", raw, err)
+	}
+	return v, nil
+}
+
+// QueryBool returns the query parameter as a bool, or def if it is absent.
+// Accepts the forms understood by strconv.ParseBool.
+func (r *Request) QueryBool(name string, def bool) (bool, error) {
+	raw, ok := r.firstQuery(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return def, paramError("query", name, "bool", raw, err)
+	}
+	return v, nil
+}
+
+// QueryDuration returns the query parameter as a time.Duration (e.g. "1.5s"),
+// or def if it is absent.
+func (r *Request) QueryDuration(name string, def time.Duration) (time.Duration, error) {
+	raw, ok := r.firstQuery(name)
+	if !ok {
+		return def, nil
+	}
+	v, err := time.ParseDuration(raw)
+	if err != nil {
+		return def, paramError("query", name, "duration", raw, err)
+	}
+	return v, nil
+}
+
+// PathInt returns the named path parameter as an int. Unlike the query
+// helpers, a missing path parameter is an error since the route defines it.
+func (r *Request) PathInt(name string) (int, error) {
+	raw, ok := r.PathParams[name]
+	if !ok {
+		return 0, &Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("missing path parameter %q", name),
+		}
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, paramError("path", name, "int", raw, err)
+	}
+	return v, nil
+}
+
+// firstQuery returns the first non-empty value of a query parameter.
+func (r *Request) firstQuery(name string) (string, bool) {
+	vals := r.URL.Query()[name]
+	if len(vals) == 0 || vals[0] == "" {
+		return "", false
+	}
+	return vals[0], true
+}
+
+// paramError builds a 400-compatible error for a parameter conversion failure.
+func paramError(source, name, kind, raw string, cause error) *Error {
+	return &Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("invalid %s parameter %q: expected %s, got %q", source, name, kind, raw),
+		Cause:   cause,
+	}
+}
+
+// --------------------------------------------------------------------
+// Struct binding
+// --------------------------------------------------------------------
+
+// BindQuery fills the struct pointed to by v from the request's query string.
+// Fields are selected with `query:"name"` tags; an optional default is given as
+// `query:"page,default=1"`. Supported kinds follow Config.LoadFromEnv: strings,
+// signed/unsigned integers, floats, bools, time.Duration and slices. Repeated
+// parameters populate slices; for scalars the first value wins. Slice defaults
+// separate elements with ';' because ',' delimits tag options. Untagged
+// fields and fields tagged "-" are skipped.
+func (r *Request) BindQuery(v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("api: BindQuery requires a non-nil pointer to a struct, got %T", v)
+	}
+	return bindQueryStruct(r.URL.Query(), rv.Elem())
+}
+
+// bindQueryStruct walks the struct fields and assigns matching query values.
+func bindQueryStruct(query map[string][]string, v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		fieldType := t.Field(i)
+
+		tag := fieldType.Tag.Get("query")
+		if tag == "" || tag == "-" || !field.CanSet() {
+			continue
+		}
+		name, def, hasDefault := parseQueryTag(tag)
+
+		vals := query[name]
+		if len(vals) == 0 || (len(vals) == 1 && vals[0] == "") {
+			if !hasDefault {
+				continue
+			}
+			vals = []string{def}
+			if field.Kind() == reflect.Slice {
+				vals = strings.Split(def, ";")
+			}
+		}
+
+		if err := setFieldFromQuery(field, vals); err != nil {
+			return paramError("query", name, queryKindName(field.Type()), strings.Join(vals, ","), err)
+		}
+	}
+	return nil
+}
+
+// parseQueryTag splits `name,default=value` into its parts.
+func parseQueryTag(tag string) (name, def string, hasDefault bool) {
+	name, rest, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(rest, ",") {
+		if d, ok := strings.CutPrefix(opt, "default="); ok {
+			return name, d, true
+		}
+	}
+	return name, "", false
+}
+
+// setFieldFromQuery converts query values into the field's type.
+func setFieldFromQuery(field reflect.Value, vals []string) error {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(vals), len(vals))
+		for i, raw := range vals {
+			if err := setScalarFromQuery(slice.Index(i), strings.TrimSpace(raw)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setScalarFromQuery(field, vals[0])
+}
+
+// setScalarFromQuery mirrors Config.setFieldFromEnv for a single value.
+func setScalarFromQuery(field reflect.Value, raw string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		val, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(val))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(val)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(val)
+	case reflect.Float32, reflect.Float64:
+		val, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(val)
+	case reflect.Bool:
+		val, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(val)
+	default:
+		return fmt.Errorf("unsupported field type: %s", field.Type())
+	}
+	return nil
+}
+
+// queryKindName describes a field type for error messages.
+func queryKindName(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "unsigned int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Slice:
+		return "list of " + queryKindName(t.Elem())
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,165 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newBindingRequest(target string, params map[string]string) *Request {
+	return &Request{Request: httptest.NewRequest(http.MethodGet, target, nil), PathParams: params}
+}
+
+// badRequest reports whether err is a 400 *Error mentioning want.
+func badRequest(err error, want string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusBadRequest && strings.Contains(apiErr.Message, want)
+}
+
+func TestRequest_TypedAccessors(t *testing.T) {
+	req := newBindingRequest("/?page=3&all=1&wait=1.5s&empty=&n=x&b=maybe&d=soon", map[string]string{"id": "42", "bad": "4x"})
+
+	for _, tc := range []struct {
+		name    string
+		get     func() (any, error)
+		want    any
+		wantErr string
+	}{
+		{"int", func() (any, error) { return req.QueryInt("page", 1) }, 3, ""},
+		{"int absent", func() (any, error) { return req.QueryInt("limit", 20) }, 20, ""},
+		{"int empty", func() (any, error) { return req.QueryInt("empty", 7) }, 7, ""},
+		{"int malformed", func() (any, error) { return req.QueryInt("n", 5) }, 5, `invalid query parameter "n": expected int, got "x"`},
+		{"bool", func() (any, error) { return req.QueryBool("all", false) }, true, ""},
+		{"bool malformed", func() (any, error) { return req.QueryBool("b", false) }, false, `expected bool, got "maybe"`},
+		{"duration", func() (any, error) { return req.QueryDuration("wait", time.Second) }, 1500 * time.Millisecond, ""},
+		{"duration malformed", func() (any, error) { return req.QueryDuration("d", time.Second) }, time.Second, `expected duration`},
+		{"path int", func() (any, error) { return req.PathInt("id") }, 42, ""},
+		{"path int malformed", func() (any, error) { return req.PathInt("bad") }, 0, `invalid path parameter "bad"`},
+		{"path int missing", func() (any, error) { return req.PathInt("other") }, 0, `missing path parameter "other"`},
+	} {
+		got, err := tc.get()
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+		if tc.wantErr != "" && !badRequest(err, tc.wantErr) {
+			t.Errorf("%s: error %v, want a 400 containing %q", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+type listQuery struct {
+	Page    int           `query:"page,default=1"`
+	Size    uint8         `query:"size"`
+	Ratio   float64       `query:"ratio"`
+	Active  bool          `query:"active"`
+	Timeout time.Duration `query:"timeout,default=2s"`
+	Tags    []string      `query:"tag"`
+	IDs     []int         `query:"id,default=1;2"`
+	Name    string        `query:"name"`
+	Skipped string        `query:"-"`
+	Plain   string
+}
+
+func TestRequest_BindQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		query   string
+		want    listQuery
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			want:  listQuery{Page: 1, Timeout: 2 * time.Second, IDs: []int{1, 2}},
+		},
+		{
+			name:  "all kinds",
+			query: "page=4&size=50&ratio=0.25&active=true&timeout=250ms&tag=a&tag=b&id=9&name=alice&name=bob&Plain=x&-=y",
+			want: listQuery{Page: 4, Size: 50, Ratio: 0.25, Active: true, Timeout: 250 * time.Millisecond,
+				Tags: []string{"a", "b"}, IDs: []int{9}, Name: "alice"},
+		},
+		{name: "int", query: "page=two", wantErr: `invalid query parameter "page": expected int, got "two"`},
+		{name: "overflow", query: "size=300", wantErr: `expected unsigned int, got "300"`},
+		{name: "float", query: "ratio=half", wantErr: `expected float`},
+		{name: "bool", query: "active=sometimes", wantErr: `expected bool`},
+		{name: "duration", query: "timeout=5", wantErr: `expected duration`},
+		{name: "slice element", query: "id=1&id=x", wantErr: `invalid query parameter "id": expected list of int, got "1,x"`},
+	} {
+		var got listQuery
+		err := newBindingRequest("/?"+tc.query, nil).BindQuery(&got)
+		if tc.wantErr != "" {
+			if !badRequest(err, tc.wantErr) {
+				t.Errorf("%s: error %v, want a 400 containing %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, %v\nwant %+v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestRequest_BindQueryRejectsNonStruct(t *testing.T) {
+	req := newBindingRequest("/?page=1", nil)
+	var page int
+	var nilQuery *listQuery
+	unsupported := struct {
+		Ch chan int `query:"ch"`
+	}{}
+	for name, v := range map[string]any{"value": listQuery{}, "non-struct": &page, "nil": nilQuery} {
+		if err := req.BindQuery(v); err == nil || !strings.Contains(err.Error(), "requires a non-nil pointer to a struct") {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	req = newBindingRequest("/?ch=1", nil)
+	if err := req.BindQuery(&unsupported); !badRequest(err, `expected chan int`) {
+		t.Errorf("unsupported field: %v", err)
+	}
+}
+
+func TestRequest_BindingThroughApp(t *testing.T) {
+	type createItem struct {
+		Name string `json:"name"`
+	}
+	app := NewApp()
+	app.Post("/lists/:id/items", func(ctx context.Context, req *Request) (*Response, error) {
+		id, err := req.PathInt("id")
+		if err != nil {
+			return nil, err
+		}
+		var q listQuery
+		if err := req.BindQuery(&q); err != nil {
+			return nil, err
+		}
+		var body createItem
+		if err := req.BindJSON(&body); err != nil {
+			return nil, &Error{Code: http.StatusBadRequest, Message: "invalid JSON body", Cause: err}
+		}
+		return JSON(http.StatusCreated, map[string]any{"list": id, "page": q.Page, "name": body.Name})
+	})
+
+	for _, tc := range []struct {
+		target, body string
+		code         int
+		contains     string
+	}{
+		{"/lists/7/items?page=2", `{"name":"milk"}`, http.StatusCreated, `"name":"milk"`},
+		{"/lists/seven/items", `{"name":"milk"}`, http.StatusBadRequest, `invalid path parameter`},
+		{"/lists/7/items?page=x", `{"name":"milk"}`, http.StatusBadRequest, `invalid query parameter`},
+		{"/lists/7/items", `{"name":`, http.StatusBadRequest, `invalid JSON body`},
+	} {
+		rec := httptest.NewRecorder()
+		app.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body)))
+		if rec.Code != tc.code || !strings.Contains(rec.Body.String(), tc.contains) {
+			t.Errorf("%s %s: %d %s", tc.target, tc.body, rec.Code, rec.Body)
+		}
+	}
+}
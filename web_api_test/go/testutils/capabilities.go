@@ -0,0 +1,365 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Capabilities – skipping tests the environment cannot run
+// --------------------------------------------------------------------
+
+// RequireAllEnv makes Requires fail tests instead of skipping them, so a
+// CI job that is meant to have docker cannot silently skip the docker
+// tests.
+const RequireAllEnv = "TESTUTILS_REQUIRE_ALL"
+
+// Capability is something about the environment a test may need, such as
+// a reachable Docker daemon or a binary in PATH. Name identifies it in
+// messages and in the detection cache.
+type Capability struct {
+	Name string
+	Hint string // how to provide it, shown when it is missing
+
+	probe func(ctx context.Context, d *CapabilityDetector) (detail string, err error)
+}
+
+// CapabilityResult is what detecting a capability found.
+type CapabilityResult struct {
+	Name      string
+	Available bool
+	Detail    string // what was found, or why it is missing
+	Hint      string
+	Duration  time.Duration
+}
+
+func (r CapabilityResult) String() string {
+	if r.Available {
+		return r.Name + ": available (" + r.Detail + ")"
+	}
+	return r.Name + ": unavailable (" + r.Detail + ")"
+}
+
+var (
+	// Docker needs the docker CLI and a daemon that answers `docker info`.
+	Docker = Capability{
+		Name:  "docker",
+		Hint:  installHints["docker"] + ", and start the Docker daemon",
+		probe: probeDocker,
+	}
+	// DockerCompose needs the `docker compose` plugin.
+	DockerCompose = Capability{
+		Name:  "docker-compose",
+		Hint:  "install the Docker Compose plugin (https://docs.docker.com/compose/install/)",
+		probe: probeDockerCompose,
+	}
+	// IPv6Loopback needs a TCP listener on [::1].
+	IPv6Loopback = Capability{
+		Name:  "ipv6",
+		Hint:  "enable IPv6 on the loopback interface",
+		probe: probeIPv6,
+	}
+)
+
+// CommandInPath needs name in PATH. It is named "exec:<name>", like the
+// CheckExecutable preflight check built on it.
+func CommandInPath(name string) Capability {
+	hint := installHints[name]
+	if hint == "" {
+		hint = "install " + name + " or add its directory to PATH"
+	}
+	return Capability{
+		Name: "exec:" + name,
+		Hint: hint,
+		probe: func(ctx context.Context, d *CapabilityDetector) (string, error) {
+			p, err := d.LookPath(name)
+			if err != nil {
+				return "", fmt.Errorf("%s not found in PATH", name)
+			}
+			return "found at " + p, nil
+		},
+	}
+}
+
+// OpenPortRange needs every TCP port from low to high free on the
+// loopback address.
+func OpenPortRange(low, high int) Capability {
+	return Capability{
+		Name: fmt.Sprintf("ports:%d-%d", low, high),
+		Hint: "stop the processes holding the ports or run the tests elsewhere",
+		probe: func(ctx context.Context, d *CapabilityDetector) (string, error) {
+			if low < 1 || high > 65535 || low > high {
+				return "", fmt.Errorf("invalid port range %d-%d", low, high)
+			}
+			var taken []string
+			for port := low; port <= high; port++ {
+				ln, err := d.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+				if err != nil {
+					taken = append(taken, strconv.Itoa(port))
+					continue
+				}
+				ln.Close()
+			}
+			if len(taken) > 0 {
+				return "", fmt.Errorf("port(s) %s already in use", strings.Join(taken, ", "))
+			}
+			return fmt.Sprintf("%d port(s) free", high-low+1), nil
+		},
+	}
+}
+
+// MinCPU needs at least n CPUs.
+func MinCPU(n int) Capability {
+	return Capability{
+		Name: "min-cpu:" + strconv.Itoa(n),
+		Hint: "run on a machine with more CPUs",
+		probe: func(ctx context.Context, d *CapabilityDetector) (string, error) {
+			cpus := d.NumCPU()
+			if cpus < n {
+				return "", fmt.Errorf("%d CPU(s), need %d", cpus, n)
+			}
+			return fmt.Sprintf("%d CPU(s)", cpus), nil
+		},
+	}
+}
+
+// EnvVar needs the environment variable name to be set and non-empty.
+// Like every capability it is read once per process.
+func EnvVar(name string) Capability {
+	return Capability{
+		Name: "env:" + name,
+		Hint: "set " + name,
+		probe: func(ctx context.Context, d *CapabilityDetector) (string, error) {
+			if v, ok := d.LookupEnv(name); !ok || v == "" {
+				return "", fmt.Errorf("%s is not set", name)
+			}
+			return name + " is set", nil
+		},
+	}
+}
+
+func probeDocker(ctx context.Context, d *CapabilityDetector) (string, error) {
+	if _, err := d.LookPath("docker"); err != nil {
+		return "", fmt.Errorf("docker not found in PATH")
+	}
+	res, err := d.Runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"info", "--format", "{{.ServerVersion}}"}})
+	if err != nil {
+		return "", fmt.Errorf("docker daemon not reachable: %s", commandFailure(res, err))
+	}
+	return "server " + strings.TrimSpace(string(res.Stdout)), nil
+}
+
+func probeDockerCompose(ctx context.Context, d *CapabilityDetector) (string, error) {
+	if _, err := d.LookPath("docker"); err != nil {
+		return "", fmt.Errorf("docker not found in PATH")
+	}
+	res, err := d.Runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"compose", "version", "--short"}})
+	if err != nil {
+		return "", fmt.Errorf("docker compose unavailable: %s", commandFailure(res, err))
+	}
+	return "compose " + strings.TrimSpace(string(res.Stdout)), nil
+}
+
+func probeIPv6(ctx context.Context, d *CapabilityDetector) (string, error) {
+	ln, err := d.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		return "", err
+	}
+	ln.Close()
+	return "listening on [::1] works", nil
+}
+
+// commandFailure is the first line of a failed command's stderr, or err.
+func commandFailure(res CommandResult, err error) string {
+	if msg, _, _ := strings.Cut(strings.TrimSpace(string(res.Stderr)), "\n"); msg != "" {
+		return msg
+	}
+	return err.Error()
+}
+
+// CapabilityDetector detects capabilities and caches the results, so each
+// is probed once however many tests require it. The probes reach the
+// environment only through the function fields, which tests of the
+// detector replace.
+type CapabilityDetector struct {
+	LookPath  func(file string) (string, error)
+	Runner    CommandRunner
+	Listen    func(network, address string) (net.Listener, error)
+	NumCPU    func() int
+	LookupEnv func(key string) (string, bool)
+	Timeout   time.Duration // per probe
+
+	mu      sync.Mutex
+	once    map[string]*sync.Once
+	results map[string]CapabilityResult
+}
+
+// NewCapabilityDetector returns a detector probing the real environment.
+func NewCapabilityDetector() *CapabilityDetector {
+	return &CapabilityDetector{
+		LookPath:  exec.LookPath,
+		Runner:    RealRunner{},
+		Listen:    net.Listen,
+		NumCPU:    runtime.NumCPU,
+		LookupEnv: os.LookupEnv,
+		Timeout:   preflightCheckTimeout,
+	}
+}
+
+// defaultCapabilities is the process-wide detector behind Requires and
+// CheckCapability, so a Preflight in TestMain warms the cache for the
+// tests.
+var defaultCapabilities = NewCapabilityDetector()
+
+// Detect returns the result for c, probing it on the first call only.
+// fresh reports whether this call did the probing.
+func (d *CapabilityDetector) Detect(c Capability) (result CapabilityResult, fresh bool) {
+	d.mu.Lock()
+	if d.once == nil {
+		d.once = make(map[string]*sync.Once)
+		d.results = make(map[string]CapabilityResult)
+	}
+	once, ok := d.once[c.Name]
+	if !ok {
+		once = &sync.Once{}
+		d.once[c.Name] = once
+	}
+	d.mu.Unlock()
+
+	once.Do(func() {
+		res := d.probe(c)
+		d.mu.Lock()
+		d.results[c.Name] = res
+		d.mu.Unlock()
+		fresh = true
+	})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.results[c.Name], fresh
+}
+
+func (d *CapabilityDetector) probe(c Capability) (res CapabilityResult) {
+	res = CapabilityResult{Name: c.Name, Hint: c.Hint}
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			res.Available, res.Detail = false, fmt.Sprintf("probe panicked: %v", p)
+		}
+		res.Duration = time.Since(start)
+	}()
+	if c.probe == nil {
+		res.Detail = "no probe defined"
+		return res
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+	detail, err := c.probe(ctx, d)
+	if err != nil {
+		res.Detail = err.Error()
+		return res
+	}
+	res.Available, res.Detail = true, detail
+	return res
+}
+
+// Results returns every result detected so far, sorted by name.
+func (d *CapabilityDetector) Results() []CapabilityResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]CapabilityResult, 0, len(d.results))
+	for _, res := range d.results {
+		out = append(out, res)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// requiresT is the part of testing.TB Require uses.
+type requiresT interface {
+	Helper()
+	Logf(format string, args ...any)
+	Skipf(format string, args ...any)
+	Fatalf(format string, args ...any)
+}
+
+// Require skips t unless every capability is available. The first test to
+// detect a capability logs the result. With TESTUTILS_REQUIRE_ALL set to
+// a true value, t fails instead of skipping.
+func (d *CapabilityDetector) Require(t requiresT, caps ...Capability) {
+	t.Helper()
+	var missing []string
+	for _, c := range caps {
+		res, fresh := d.Detect(c)
+		if fresh {
+			t.Logf("capability %s (detected in %v)", res, res.Duration.Round(time.Millisecond))
+		}
+		if !res.Available {
+			msg := res.Name + ": " + res.Detail
+			if res.Hint != "" {
+				msg += " (" + res.Hint + ")"
+			}
+			missing = append(missing, msg)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	msg := "missing capabilities: " + strings.Join(missing, "; ")
+	if requireAll, _ := strconv.ParseBool(lookupEnvValue(d.LookupEnv, RequireAllEnv)); requireAll {
+		t.Fatalf("%s [%s is set]", msg, RequireAllEnv)
+		return
+	}
+	t.Skipf("%s [set %s=1 to fail instead]", msg, RequireAllEnv)
+}
+
+func lookupEnvValue(lookup func(string) (string, bool), key string) string {
+	v, _ := lookup(key)
+	return v
+}
+
+// Requires skips the test unless every capability is available, or fails
+// it when TESTUTILS_REQUIRE_ALL is set:
+//
+//	func TestOrders_Postgres(t *testing.T) {
+//		testutils.Requires(t, testutils.Docker, testutils.CommandInPath("npm"), testutils.MinCPU(2))
+//		...
+//	}
+//
+// Each capability is detected once per process and the result is logged
+// by the test that detected it.
+func Requires(t testing.TB, caps ...Capability) {
+	t.Helper()
+	defaultCapabilities.Require(t, caps...)
+}
+
+// Check turns c into a preflight check named after it, sharing the
+// detector's cache.
+func (d *CapabilityDetector) Check(c Capability) PreflightCheck {
+	return PreflightCheck{
+		Name: c.Name,
+		Run: func(ctx context.Context, cfg *Config) PreflightResult {
+			res, _ := d.Detect(c)
+			if !res.Available {
+				return PreflightResult{Status: PreflightFail, Message: res.Detail, Hint: res.Hint}
+			}
+			return PreflightResult{Status: PreflightPass, Message: res.Detail}
+		},
+	}
+}
+
+// CheckCapability is a preflight check for c, using the same probes and
+// cache as Requires.
+func CheckCapability(c Capability) PreflightCheck {
+	return defaultCapabilities.Check(c)
+}
@@ -0,0 +1,142 @@
+package testutils
+
+import (
+	"errors"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newFakeDetector has docker installed with a stopped daemon, no IPv6,
+// two CPUs and only CI set.
+func newFakeDetector(env map[string]string) (*CapabilityDetector, *FakeRunner) {
+	runner := NewFakeRunner()
+	runner.On("docker", "info").Stderr("Cannot connect to the Docker daemon at unix:///var/run/docker.sock.\nIs the docker daemon running?").Exit(1)
+	runner.On("docker", "compose", "version").Stdout("2.24.6\n")
+
+	d := NewCapabilityDetector()
+	d.Runner = runner
+	d.LookPath = func(file string) (string, error) {
+		if file == "docker" {
+			return "/usr/bin/docker", nil
+		}
+		return "", exec.ErrNotFound
+	}
+	d.Listen = func(network, address string) (net.Listener, error) {
+		if network == "tcp6" {
+			return nil, errors.New("listen tcp6 [::1]:0: socket: address family not supported by protocol")
+		}
+		return net.Listen(network, address)
+	}
+	d.NumCPU = func() int { return 2 }
+	d.LookupEnv = func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	return d, runner
+}
+
+func TestCapabilityDetector_Probes(t *testing.T) {
+	d, _ := newFakeDetector(map[string]string{"CI": "true"})
+	for _, tc := range []struct {
+		c      Capability
+		ok     bool
+		detail string
+	}{
+		{Docker, false, "docker daemon not reachable: Cannot connect to the Docker daemon at unix:///var/run/docker.sock."},
+		{DockerCompose, true, "compose 2.24.6"},
+		{IPv6Loopback, false, "address family not supported"},
+		{CommandInPath("docker"), true, "found at /usr/bin/docker"},
+		{CommandInPath("npm"), false, "npm not found in PATH"},
+		{MinCPU(2), true, "2 CPU(s)"},
+		{MinCPU(8), false, "2 CPU(s), need 8"},
+		{EnvVar("CI"), true, "CI is set"},
+		{EnvVar("DATABASE_URL"), false, "DATABASE_URL is not set"},
+		{OpenPortRange(2, 1), false, "invalid port range 2-1"},
+	} {
+		res, fresh := d.Detect(tc.c)
+		if !fresh || res.Available != tc.ok || !strings.Contains(res.Detail, tc.detail) {
+			t.Errorf("%s: got %v (fresh %v), want available=%v with %q", tc.c.Name, res, fresh, tc.ok, tc.detail)
+		}
+	}
+	if res, _ := d.Detect(CommandInPath("npm")); !strings.Contains(res.Hint, "nodejs.org") {
+		t.Errorf("npm hint %q", res.Hint)
+	}
+}
+
+func TestCapabilityDetector_RequireCachesAndSkips(t *testing.T) {
+	d, runner := newFakeDetector(nil)
+
+	var wg sync.WaitGroup
+	ts := make([]*recordingT, 8)
+	for i := range ts {
+		ts[i] = &recordingT{}
+		wg.Add(1)
+		go func(rt *recordingT) {
+			defer wg.Done()
+			d.Require(rt, DockerCompose, Docker)
+		}(ts[i])
+	}
+	wg.Wait()
+
+	if n := len(runner.Calls()); n != 2 {
+		t.Errorf("probed docker %d times, want once per capability", n)
+	}
+	logged := 0
+	for _, rt := range ts {
+		logged += len(rt.logs)
+		if rt.fatal != "" || !strings.HasPrefix(rt.skipped, "missing capabilities: docker: docker daemon not reachable") ||
+			!strings.HasSuffix(rt.skipped, "[set TESTUTILS_REQUIRE_ALL=1 to fail instead]") {
+			t.Errorf("unexpected outcome: skipped %q, fatal %q", rt.skipped, rt.fatal)
+		}
+	}
+	if logged != 2 {
+		t.Errorf("%d detection logs, want one per capability", logged)
+	}
+
+	rt := &recordingT{}
+	d.Require(rt, DockerCompose)
+	if rt.skipped != "" || rt.fatal != "" || len(rt.logs) != 0 {
+		t.Errorf("an available cached capability must pass silently: %+v", rt)
+	}
+	if got := d.Results(); len(got) != 2 || got[0].Name != "docker" || got[1].Name != "docker-compose" {
+		t.Errorf("results %v", got)
+	}
+}
+
+func TestCapabilityDetector_RequireAllFails(t *testing.T) {
+	d, _ := newFakeDetector(map[string]string{RequireAllEnv: "1"})
+	rt := &recordingT{}
+	d.Require(rt, MinCPU(4), EnvVar("API_KEY"))
+	if rt.skipped != "" || !strings.Contains(rt.fatal, "min-cpu:4: 2 CPU(s), need 4") ||
+		!strings.Contains(rt.fatal, "env:API_KEY: API_KEY is not set (set API_KEY)") ||
+		!strings.HasSuffix(rt.fatal, "[TESTUTILS_REQUIRE_ALL is set]") {
+		t.Errorf("expected a failure, got skipped %q, fatal %q", rt.skipped, rt.fatal)
+	}
+}
+
+func TestCapabilityDetector_PreflightSharesProbes(t *testing.T) {
+	d, runner := newFakeDetector(nil)
+	report := Preflight(DefaultConfig(), d.Check(Docker), d.Check(CommandInPath("docker")))
+	if res := resultNamed(t, report, "docker"); res.Status != PreflightFail || !strings.Contains(res.Hint, "start the Docker daemon") {
+		t.Errorf("docker check %+v", res)
+	}
+	if res := resultNamed(t, report, "exec:docker"); res.Status != PreflightPass {
+		t.Errorf("exec:docker check %+v", res)
+	}
+
+	rt := &recordingT{}
+	d.Require(rt, Docker)
+	if len(runner.Calls()) != 1 || len(rt.logs) != 0 || rt.skipped == "" {
+		t.Errorf("Require re-probed after Preflight: %d calls, %+v", len(runner.Calls()), rt)
+	}
+}
+
+func TestRequires_Available(t *testing.T) {
+	Requires(t, MinCPU(1))
+	if t.Skipped() {
+		t.Fatal("skipped with an available capability")
+	}
+}
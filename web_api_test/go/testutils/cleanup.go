@@ -2,35 +2,191 @@
 package testutils
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
-	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 )
 
-// Cleanup is a simple registry of cleanup functions to be run later.
+// Cleanup is a stack of teardown steps. Steps run last-pushed first, each
+// under its own timeout; a failing or panicking step is recorded and the
+// remaining steps still run. The zero value is ready to use.
+//
+//	teardown := &Cleanup{}
+//	teardown.BindTo(t)
+//	teardown.Push("remove test data", func(ctx context.Context) error { return tdm.Cleanup() })
+//	teardown.Push("stop server", srv.Shutdown)
 type Cleanup struct {
-	funcs []func()
+	mu          sync.Mutex
+	steps       []cleanupStep
+	stepTimeout time.Duration
+	logger      *TestLogger
+	results     []CleanupResult
 }
 
-// Add registers a cleanup function.
+type cleanupStep struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// CleanupResult records how one step went during Run.
+type CleanupResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// SetStepTimeout bounds each step run by Run. Zero, the default, leaves
+// steps bounded only by the context passed to Run.
+func (c *Cleanup) SetStepTimeout(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stepTimeout = d
+}
+
+// SetLogger makes Run log every step with its duration and error.
+func (c *Cleanup) SetLogger(logger *TestLogger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = logger
+}
+
+// Push adds a named step to the top of the stack.
+func (c *Cleanup) Push(name string, fn func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.steps = append(c.steps, cleanupStep{name: name, fn: fn})
+}
+
+// Add pushes an unnamed step that cannot fail.
 func (c *Cleanup) Add(f func()) {
-	c.funcs = append(c.funcs, f)
+	c.Push(fmt.Sprintf("cleanup #%d", c.Len()+1), func(context.Context) error {
+		f()
+		return nil
+	})
+}
+
+// Len returns the number of steps not yet run.
+func (c *Cleanup) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.steps)
+}
+
+// Run pops and executes every step, last pushed first, including steps
+// pushed by steps while it runs. All failures, timeouts and recovered
+// panics are returned together as a *CompositeError. With a step timeout,
+// a step that outlives it is abandoned and Run moves on; without one,
+// steps run inline on the caller's goroutine. Running an empty stack is a
+// no-op, so Run may be called more than once.
+func (c *Cleanup) Run(ctx context.Context) error {
+	errs := NewCompositeError("cleanup")
+	for {
+		c.mu.Lock()
+		if len(c.steps) == 0 {
+			c.mu.Unlock()
+			break
+		}
+		step := c.steps[len(c.steps)-1]
+		c.steps = c.steps[:len(c.steps)-1]
+		timeout, logger := c.stepTimeout, c.logger
+		c.mu.Unlock()
+
+		start := time.Now()
+		err := runCleanupStep(ctx, step, timeout)
+		result := CleanupResult{Name: step.name, Duration: time.Since(start), Err: err}
+
+		c.mu.Lock()
+		c.results = append(c.results, result)
+		c.mu.Unlock()
+
+		if logger != nil {
+			fields := map[string]any{"step": step.name, "duration": result.Duration.String()}
+			if err != nil {
+				fields["error"] = err.Error()
+				logger.Error("cleanup step failed", fields)
+			} else {
+				logger.Debug("cleanup step done", fields)
+			}
+		}
+		if err != nil {
+			errs.Add(fmt.Errorf("cleanup step %q: %w", step.name, err))
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
 }
 
-// Run executes all registered cleanup functions in reverse order
-// (last added, first executed).
-func (c *Cleanup) Run() {
-	for i := len(c.funcs) - 1; i >= 0; i-- {
-		c.funcs[i]()
+// runCleanupStep runs one step, turning a panic into an error. Without a
+// timeout the step runs on the caller's goroutine, so a step that calls
+// t.FailNow behaves as it would in t.Cleanup. With one, it runs on its own
+// goroutine so Run can abandon it, and a step that exits that goroutine
+// with runtime.Goexit (t.FailNow, t.Fatal) is reported instead of
+// blocking Run.
+func runCleanupStep(ctx context.Context, step cleanupStep, timeout time.Duration) (err error) {
+	if timeout <= 0 {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return step.fn(ctx)
 	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		returned := false
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			} else if !returned {
+				done <- errors.New("step exited its goroutine (t.FailNow or t.Fatal outside the test goroutine?)")
+			}
+		}()
+		err := step.fn(ctx)
+		returned = true
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("did not finish: %w", ctx.Err())
+	}
+}
+
+// Results returns the outcome of every step run so far, in run order.
+func (c *Cleanup) Results() []CleanupResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]CleanupResult, len(c.results))
+	copy(out, c.results)
+	return out
+}
+
+// BindTo makes t's Cleanup run the stack when the test completes and
+// report its errors with t.Error.
+func (c *Cleanup) BindTo(t testing.TB) {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := c.Run(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
 }
 
-// Defer registers the cleanup with testing.TB's Cleanup method,
-// ensuring it runs when the test completes.
+// Defer is BindTo, kept for existing callers.
 func (c *Cleanup) Defer(t testing.TB) {
 	t.Helper()
-	t.Cleanup(c.Run)
+	c.BindTo(t)
 }
 
 // TempDir creates a temporary directory and returns its path along with a
@@ -0,0 +1,134 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanup_RunsLIFOAndCollectsEveryFailure(t *testing.T) {
+	var order []string
+	step := func(name string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			order = append(order, name)
+			return err
+		}
+	}
+	c := &Cleanup{}
+	c.SetStepTimeout(20 * time.Millisecond)
+	c.Push("remove dir", step("remove dir", errors.New("dir busy")))
+	c.Push("stop docker", step("stop docker", nil))
+	c.Push("stop server", func(context.Context) error {
+		order = append(order, "stop server")
+		panic("server already gone")
+	})
+	c.Push("flush logs", func(ctx context.Context) error {
+		order = append(order, "flush logs")
+		c.Push("pushed late", step("pushed late", nil))
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // ignores cancellation for a while
+		return nil
+	})
+
+	err := c.Run(context.Background())
+	if got := strings.Join(order, ","); got != "flush logs,pushed late,stop server,stop docker,remove dir" {
+		t.Errorf("order = %s", got)
+	}
+	var ce *CompositeError
+	if !errors.As(err, &ce) || len(ce.Errors) != 3 {
+		t.Fatalf("err = %v", err)
+	}
+	for _, want := range []string{`"flush logs": did not finish`, `"stop server": panic: server already gone`, `"remove dir": dir busy`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("timed out step should wrap context.DeadlineExceeded")
+	}
+	if n := len(c.Results()); n != 5 || c.Len() != 0 {
+		t.Errorf("%d results, %d steps left", n, c.Len())
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Errorf("second Run: %v", err)
+	}
+}
+
+func TestCleanup_StepCallingGoexit(t *testing.T) {
+	// With a step timeout the step runs on its own goroutine; Goexit there
+	// (what t.FailNow does) must be reported, not hang Run.
+	c := &Cleanup{}
+	c.SetStepTimeout(time.Minute)
+	var ran bool
+	c.Add(func() { ran = true })
+	c.Add(func() { runtime.Goexit() })
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.Run(context.Background()) }()
+	select {
+	case err := <-errc:
+		if err == nil || !strings.Contains(err.Error(), `"cleanup #2": step exited its goroutine`) {
+			t.Errorf("err = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run blocked on a step that called runtime.Goexit")
+	}
+	if !ran {
+		t.Error("the steps after the Goexit were skipped")
+	}
+
+	// Without a timeout the step runs inline, as t.Cleanup would run it:
+	// Goexit ends the goroutine calling Run.
+	c = &Cleanup{}
+	var after bool
+	c.Add(func() { runtime.Goexit() })
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		c.Run(context.Background())
+		after = true
+	}()
+	select {
+	case <-exited:
+		if after {
+			t.Error("Goexit in an inline step did not end the calling goroutine")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run blocked on an inline step that called runtime.Goexit")
+	}
+}
+
+func TestCleanup_BindToAndRegistry(t *testing.T) {
+	reg := NewComponentRegistry()
+	db, api := NewInMemoryComponent("db"), NewInMemoryComponent("api")
+	reg.MustRegister(api)
+	reg.MustRegister(db)
+	if err := reg.DependsOn("api", "db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	var stopped []string
+	t.Run("inner", func(t *testing.T) {
+		c := &Cleanup{}
+		c.BindTo(t)
+		c.Push("record", func(context.Context) error {
+			for _, comp := range []*InMemoryComponent{db, api} {
+				if s, _ := comp.Status(); s == "stopped" {
+					stopped = append(stopped, comp.Name())
+				}
+			}
+			return nil
+		})
+		reg.PushTeardown(c)
+	})
+	// api depends on db, so it is stopped first; both before "record".
+	if got := strings.Join(stopped, ","); got != "db,api" {
+		t.Errorf("stopped before the first pushed step: %q", got)
+	}
+}
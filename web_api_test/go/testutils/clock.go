@@ -255,4 +255,140 @@ func (mt *mockTicker) Stop() {
 			return
 		}
 	}
-}
\ No newline at end of file
+}
+
+// ------------------------------------------------------------------------
+// FakeClock – MockClock whose Sleep blocks until time is advanced
+// ------------------------------------------------------------------------
+
+// FakeClock is a MockClock variant for testing code that sleeps in its own
+// goroutine. Unlike MockClock, Sleep blocks until another goroutine calls
+// Advance past the deadline, and callers can wait for sleepers to arrive with
+// BlockUntil before advancing.
+type FakeClock struct {
+	*MockClock
+	mu      sync.Mutex
+	cond    *sync.Cond
+	waiters int
+}
+
+// NewFakeClock creates a fake clock set to the given start time.
+// If start.IsZero(), time.Unix(0, 0) is used.
+func NewFakeClock(start time.Time) *FakeClock {
+	fc := &FakeClock{MockClock: NewMockClock(start)}
+	fc.cond = sync.NewCond(&fc.mu)
+	return fc
+}
+
+// After returns a channel that receives once the fake time has been advanced
+// by d, or at once if d <= 0. The caller counts as a waiter until the
+// channel fires.
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	return fc.NewTimer(d).C()
+}
+
+// NewTimer creates a timer that fires when the fake time is advanced by d.
+// Like time.NewTimer, it fires immediately if d <= 0.
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	t := fc.MockClock.NewTimer(d)
+	if d <= 0 {
+		fc.MockClock.Advance(0)
+		return &fakeTimer{Timer: t, clock: fc}
+	}
+	fc.mu.Lock()
+	fc.waiters++
+	fc.cond.Broadcast()
+	fc.mu.Unlock()
+	return &fakeTimer{Timer: t, clock: fc}
+}
+
+// Sleep blocks until the fake time has been advanced by at least d.
+func (fc *FakeClock) Sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	<-fc.After(d)
+}
+
+// Advance moves the fake time forward by d, waking any sleepers and timers
+// whose deadline has passed.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.MockClock.Advance(d)
+	fc.mu.Lock()
+	fc.waiters = fc.pending()
+	fc.cond.Broadcast()
+	fc.mu.Unlock()
+}
+
+// Waiters returns the number of timers and sleepers that have not yet fired.
+func (fc *FakeClock) Waiters() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.waiters
+}
+
+// BlockUntil blocks until at least n timers or sleepers are waiting on the
+// clock. Use it before Advance to avoid racing the goroutine under test.
+func (fc *FakeClock) BlockUntil(n int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for fc.waiters < n {
+		fc.cond.Wait()
+	}
+}
+
+// pending counts unfired timers on the underlying MockClock.
+func (fc *FakeClock) pending() int {
+	fc.MockClock.mu.Lock()
+	defer fc.MockClock.mu.Unlock()
+	return len(fc.MockClock.timers)
+}
+
+// fakeTimer keeps the FakeClock waiter count in sync when a timer is stopped
+// or reset.
+type fakeTimer struct {
+	Timer
+	clock *FakeClock
+}
+
+func (ft *fakeTimer) Stop() bool {
+	stopped := ft.Timer.Stop()
+	ft.clock.mu.Lock()
+	ft.clock.waiters = ft.clock.pending()
+	ft.clock.cond.Broadcast()
+	ft.clock.mu.Unlock()
+	return stopped
+}
+
+func (ft *fakeTimer) Reset(d time.Duration) bool {
+	active := ft.Timer.Reset(d)
+	if d <= 0 {
+		ft.clock.MockClock.Advance(0)
+	}
+	ft.clock.mu.Lock()
+	ft.clock.waiters = ft.clock.pending()
+	ft.clock.cond.Broadcast()
+	ft.clock.mu.Unlock()
+	return active
+}
+
+// sleepWith sleeps on c, falling back to the real clock when c is nil so
+// zero-value structs keep working.
+func sleepWith(c Clock, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	if c == nil {
+		time.Sleep(d)
+		return
+	}
+	c.Sleep(d)
+}
+
+// afterWith is the channel form of sleepWith.
+func afterWith(c Clock, d time.Duration) <-chan time.Time {
+	if c == nil {
+		return time.After(d)
+	}
+	return c.After(d)
+}
@@ -0,0 +1,44 @@
+package testutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock_NonPositiveDurationsFireImmediately(t *testing.T) {
+	start := time.Unix(1000, 0)
+	fc := NewFakeClock(start)
+
+	for _, d := range []time.Duration{0, -time.Second} {
+		select {
+		case now := <-fc.After(d):
+			if !now.Equal(start) {
+				t.Errorf("After(%v) fired at %v, want %v", d, now, start)
+			}
+		default:
+			t.Errorf("After(%v) did not fire without an Advance", d)
+		}
+		select {
+		case <-fc.NewTimer(d).C():
+		default:
+			t.Errorf("NewTimer(%v) did not fire without an Advance", d)
+		}
+	}
+
+	timer := fc.NewTimer(time.Minute)
+	if n := fc.Waiters(); n != 1 {
+		t.Errorf("%d waiters, want only the one-minute timer", n)
+	}
+	timer.Reset(0)
+	select {
+	case <-timer.C():
+	default:
+		t.Error("Reset(0) did not fire the timer")
+	}
+	if n := fc.Waiters(); n != 0 {
+		t.Errorf("%d waiters after every timer fired", n)
+	}
+	if !fc.Now().Equal(start) {
+		t.Errorf("firing due timers moved the clock to %v", fc.Now())
+	}
+}
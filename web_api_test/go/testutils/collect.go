@@ -3,7 +3,6 @@
 package testutils
 
 import (
-    "errors"
     "sync"
     "time"
 )
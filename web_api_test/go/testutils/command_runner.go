@@ -0,0 +1,136 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Command runner
+// ------------------------------------------------------------------------
+
+// CommandSpec describes a command for a CommandRunner.
+type CommandSpec struct {
+	Name string
+	Args []string
+	Dir  string
+	Env  []string // "KEY=value" entries; nil inherits the current environment
+
+	Stdin  io.Reader // nil for none
+	Stdout io.Writer // also receives the output Run captures
+	Stderr io.Writer
+}
+
+// CommandResult is the outcome of CommandRunner.Run.
+type CommandResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int // -1 if the command did not exit normally
+	Duration time.Duration
+}
+
+// Process is a command started by CommandRunner.Start.
+type Process interface {
+	Pid() int
+	// Signal sends sig to the process. It returns os.ErrProcessDone once
+	// the process has exited.
+	Signal(sig os.Signal) error
+	// Wait blocks until the process exits and returns its exit error.
+	Wait() error
+	// StdoutPipe returns the process's stdout when the spec left Stdout
+	// nil. It must be read before Wait returns.
+	StdoutPipe() (io.ReadCloser, error)
+}
+
+// CommandRunner runs external commands. DockerManager and ServerManager go
+// through one, so tests can swap RealRunner for a FakeRunner and assert on
+// the commands instead of needing docker or npm installed.
+type CommandRunner interface {
+	// Run runs spec to completion. A non-zero exit is returned as an
+	// error, with the result still filled in.
+	Run(ctx context.Context, spec CommandSpec) (CommandResult, error)
+	// Start starts spec and returns without waiting for it. Cancelling
+	// ctx kills the process.
+	Start(ctx context.Context, spec CommandSpec) (Process, error)
+}
+
+// RealRunner runs commands with package os/exec.
+type RealRunner struct{}
+
+func (spec CommandSpec) command(ctx context.Context) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, spec.Name, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = spec.Env
+	cmd.Stdin = spec.Stdin
+	return cmd
+}
+
+// Run implements CommandRunner.
+func (RealRunner) Run(ctx context.Context, spec CommandSpec) (CommandResult, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := spec.command(ctx)
+	cmd.Stdout = teeOutput(&stdout, spec.Stdout)
+	cmd.Stderr = teeOutput(&stderr, spec.Stderr)
+
+	start := time.Now()
+	err := cmd.Run()
+	res := CommandResult{
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+	}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	} else if err != nil {
+		res.ExitCode = -1
+	}
+	return res, err
+}
+
+// Start implements CommandRunner.
+func (RealRunner) Start(ctx context.Context, spec CommandSpec) (Process, error) {
+	cmd := spec.command(ctx)
+	p := &realProcess{cmd: cmd}
+	if spec.Stdout == nil {
+		pipe, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		p.stdout = pipe
+	} else {
+		cmd.Stdout = spec.Stdout
+	}
+	cmd.Stderr = spec.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func teeOutput(buf *bytes.Buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return buf
+	}
+	return io.MultiWriter(buf, w)
+}
+
+type realProcess struct {
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+}
+
+func (p *realProcess) Pid() int                   { return p.cmd.Process.Pid }
+func (p *realProcess) Signal(sig os.Signal) error { return p.cmd.Process.Signal(sig) }
+func (p *realProcess) Wait() error                { return p.cmd.Wait() }
+
+func (p *realProcess) StdoutPipe() (io.ReadCloser, error) {
+	if p.stdout == nil {
+		return nil, errors.New("stdout is redirected by the command spec")
+	}
+	return p.stdout, nil
+}
@@ -0,0 +1,340 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// FakeRunner
+// ------------------------------------------------------------------------
+
+// FakeRunner is a CommandRunner that runs nothing. Scripts registered with
+// On decide the output, exit code and duration of the commands they match;
+// every spec is recorded for assertions:
+//
+//	runner := NewFakeRunner()
+//	runner.On("docker", "compose", "ps").Stdout(`{"Service":"db","State":"running"}`)
+//	runner.On("docker", "compose", "up").Stderr("no space left on device").Exit(1).Times(1)
+//	dm, _ := NewDockerManager(cfg, logger, WithDockerRunner(runner))
+//
+// Commands no script matches succeed without output.
+type FakeRunner struct {
+	mu      sync.Mutex
+	clock   Clock
+	scripts []*FakeCommand
+	calls   []CommandSpec
+	procs   []*FakeProcess
+	nextPID int
+}
+
+// NewFakeRunner returns a FakeRunner with no scripts.
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{clock: RealClock{}, nextPID: 1000}
+}
+
+// SetClock sets the clock script delays are measured on.
+func (f *FakeRunner) SetClock(clock Clock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.clock = clock
+}
+
+// FakeCommand scripts the commands a FakeRunner matches. Its methods
+// return the script so calls can be chained.
+type FakeCommand struct {
+	name string
+	args []string
+
+	stdout, stderr string
+	exitCode       int
+	delay          time.Duration
+	startErr       error
+	untilSignal    bool
+	stopSignals    []os.Signal
+	times          int // remaining uses; 0 is unlimited
+	used           int
+}
+
+// On scripts the commands called name whose arguments contain args in
+// order, not necessarily adjacent: On("docker", "compose", "up") matches
+// "docker compose --project-name p up -d". Scripts are tried in the order
+// they were added; a script used up by Times no longer matches.
+func (f *FakeRunner) On(name string, args ...string) *FakeCommand {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c := &FakeCommand{name: name, args: args}
+	f.scripts = append(f.scripts, c)
+	return c
+}
+
+// Stdout sets what the command writes to stdout.
+func (c *FakeCommand) Stdout(s string) *FakeCommand { c.stdout = s; return c }
+
+// Stderr sets what the command writes to stderr.
+func (c *FakeCommand) Stderr(s string) *FakeCommand { c.stderr = s; return c }
+
+// Exit sets the exit code.
+func (c *FakeCommand) Exit(code int) *FakeCommand { c.exitCode = code; return c }
+
+// Delay sets how long the command runs before it exits.
+func (c *FakeCommand) Delay(d time.Duration) *FakeCommand { c.delay = d; return c }
+
+// Fail makes the command fail to start with err, as a missing binary does.
+func (c *FakeCommand) Fail(err error) *FakeCommand { c.startErr = err; return c }
+
+// UntilSignal keeps a started command running until it receives one of
+// sigs, or any signal if none are given. os.Kill always stops it. A
+// command without UntilSignal exits after its Delay or on any signal.
+func (c *FakeCommand) UntilSignal(sigs ...os.Signal) *FakeCommand {
+	c.untilSignal = true
+	c.stopSignals = sigs
+	return c
+}
+
+// Times limits the script to the next n matching commands.
+func (c *FakeCommand) Times(n int) *FakeCommand { c.times = n; return c }
+
+func (c *FakeCommand) matches(spec CommandSpec) bool {
+	if c.name != spec.Name || (c.times > 0 && c.used >= c.times) {
+		return false
+	}
+	want := c.args
+	for _, arg := range spec.Args {
+		if len(want) == 0 {
+			break
+		}
+		if arg == want[0] {
+			want = want[1:]
+		}
+	}
+	return len(want) == 0
+}
+
+func (c *FakeCommand) stops(sig os.Signal) bool {
+	if !c.untilSignal || len(c.stopSignals) == 0 || sig == os.Kill {
+		return true
+	}
+	for _, s := range c.stopSignals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// record stores spec and returns the script it matched.
+func (f *FakeRunner) record(spec CommandSpec) (*FakeCommand, Clock) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	spec.Args = append([]string(nil), spec.Args...)
+	f.calls = append(f.calls, spec)
+	for _, c := range f.scripts {
+		if c.matches(spec) {
+			c.used++
+			return c, f.clock
+		}
+	}
+	return &FakeCommand{}, f.clock
+}
+
+// Calls returns every spec run or started so far, in order.
+func (f *FakeRunner) Calls() []CommandSpec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]CommandSpec(nil), f.calls...)
+}
+
+// Args returns the arguments of every call, for comparing whole command
+// lines.
+func (f *FakeRunner) Args() [][]string {
+	var out [][]string
+	for _, spec := range f.Calls() {
+		out = append(out, spec.Args)
+	}
+	return out
+}
+
+// Processes returns the processes started so far, in order.
+func (f *FakeRunner) Processes() []*FakeProcess {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*FakeProcess(nil), f.procs...)
+}
+
+// Run implements CommandRunner.
+func (f *FakeRunner) Run(ctx context.Context, spec CommandSpec) (CommandResult, error) {
+	script, clock := f.record(spec)
+	if script.startErr != nil {
+		return CommandResult{ExitCode: -1}, script.startErr
+	}
+	if spec.Stdin != nil {
+		io.Copy(io.Discard, spec.Stdin)
+	}
+
+	start := clock.Now()
+	if script.delay > 0 {
+		select {
+		case <-clock.After(script.delay):
+		case <-ctx.Done():
+			return CommandResult{ExitCode: -1, Duration: clock.Now().Sub(start)}, fmt.Errorf("signal: killed: %w", ctx.Err())
+		}
+	}
+	writeScripted(spec.Stdout, script.stdout)
+	writeScripted(spec.Stderr, script.stderr)
+	res := CommandResult{
+		Stdout:   []byte(script.stdout),
+		Stderr:   []byte(script.stderr),
+		ExitCode: script.exitCode,
+		Duration: clock.Now().Sub(start),
+	}
+	if script.exitCode != 0 {
+		return res, fmt.Errorf("exit status %d", script.exitCode)
+	}
+	return res, nil
+}
+
+// Start implements CommandRunner. The scripted output is written before
+// Start returns.
+func (f *FakeRunner) Start(ctx context.Context, spec CommandSpec) (Process, error) {
+	script, clock := f.record(spec)
+	if script.startErr != nil {
+		return nil, script.startErr
+	}
+
+	f.mu.Lock()
+	f.nextPID++
+	p := &FakeProcess{
+		pid:     f.nextPID,
+		spec:    spec,
+		script:  script,
+		signals: make(chan os.Signal, 16),
+		exited:  make(chan struct{}),
+	}
+	f.procs = append(f.procs, p)
+	f.mu.Unlock()
+
+	if spec.Stdout == nil {
+		p.stdout = io.NopCloser(strings.NewReader(script.stdout))
+	}
+	writeScripted(spec.Stdout, script.stdout)
+	writeScripted(spec.Stderr, script.stderr)
+	go p.run(ctx, clock)
+	return p, nil
+}
+
+func writeScripted(w io.Writer, s string) {
+	if w != nil && s != "" {
+		io.WriteString(w, s)
+	}
+}
+
+// FakeProcess is a process started by a FakeRunner.
+type FakeProcess struct {
+	pid    int
+	spec   CommandSpec
+	script *FakeCommand
+	stdout io.ReadCloser
+
+	signals chan os.Signal
+	exited  chan struct{}
+
+	mu       sync.Mutex
+	received []os.Signal
+	err      error
+}
+
+func (p *FakeProcess) run(ctx context.Context, clock Clock) {
+	var timeout <-chan time.Time
+	if !p.script.untilSignal {
+		timeout = clock.After(p.script.delay)
+	}
+	var err error
+	for err == nil {
+		select {
+		case <-timeout:
+			if p.script.exitCode != 0 {
+				err = fmt.Errorf("exit status %d", p.script.exitCode)
+			}
+			p.exit(err)
+			return
+		case sig := <-p.signals:
+			if p.script.stops(sig) {
+				err = fmt.Errorf("signal: %v", sig)
+			}
+		case <-ctx.Done():
+			err = fmt.Errorf("signal: killed")
+		}
+	}
+	p.exit(err)
+}
+
+func (p *FakeProcess) exit(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.err = err
+	close(p.exited)
+}
+
+// Pid implements Process.
+func (p *FakeProcess) Pid() int { return p.pid }
+
+// Spec returns the spec the process was started with.
+func (p *FakeProcess) Spec() CommandSpec { return p.spec }
+
+// Signal implements Process. A nil sig only reports whether the process
+// is still running.
+func (p *FakeProcess) Signal(sig os.Signal) error {
+	select {
+	case <-p.exited:
+		return os.ErrProcessDone
+	default:
+	}
+	if sig == nil {
+		return nil
+	}
+	p.mu.Lock()
+	p.received = append(p.received, sig)
+	p.mu.Unlock()
+	p.signals <- sig
+	return nil
+}
+
+// Signals returns the signals sent to the process, in order.
+func (p *FakeProcess) Signals() []os.Signal {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]os.Signal(nil), p.received...)
+}
+
+// Wait implements Process.
+func (p *FakeProcess) Wait() error {
+	<-p.exited
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Exited reports whether the process has exited.
+func (p *FakeProcess) Exited() bool {
+	select {
+	case <-p.exited:
+		return true
+	default:
+		return false
+	}
+}
+
+// StdoutPipe implements Process.
+func (p *FakeProcess) StdoutPipe() (io.ReadCloser, error) {
+	if p.stdout == nil {
+		return nil, errors.New("stdout is redirected by the command spec")
+	}
+	return p.stdout, nil
+}
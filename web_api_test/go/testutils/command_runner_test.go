@@ -0,0 +1,125 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFakeRunner_Scripts(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.On("docker", "compose", "up").Stderr("no space left on device").Exit(1).Times(1)
+	runner.On("docker", "compose", "ps").Stdout("db running\n")
+	ctx := context.Background()
+
+	res, err := runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"compose", "-p", "x", "up", "-d"}})
+	if err == nil || err.Error() != "exit status 1" || res.ExitCode != 1 || string(res.Stderr) != "no space left on device" {
+		t.Errorf("scripted failure: %+v, %v", res, err)
+	}
+	// Used up: the retry succeeds.
+	if _, err := runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"compose", "up"}}); err != nil {
+		t.Errorf("script used past Times: %v", err)
+	}
+
+	var stdout strings.Builder
+	res, err = runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"compose", "ps"}, Stdout: &stdout})
+	if err != nil || string(res.Stdout) != "db running\n" || stdout.String() != "db running\n" {
+		t.Errorf("scripted output: %q, %q, %v", res.Stdout, stdout.String(), err)
+	}
+	// Out of order arguments do not match.
+	if res, _ := runner.Run(ctx, CommandSpec{Name: "docker", Args: []string{"ps", "compose"}}); len(res.Stdout) != 0 {
+		t.Errorf("matched arguments out of order: %q", res.Stdout)
+	}
+
+	want := [][]string{{"compose", "-p", "x", "up", "-d"}, {"compose", "up"}, {"compose", "ps"}, {"ps", "compose"}}
+	if got := runner.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("recorded %q, want %q", got, want)
+	}
+}
+
+func TestFakeRunner_DelayAndCancel(t *testing.T) {
+	clock := NewFakeClock(time.Time{})
+	runner := NewFakeRunner()
+	runner.SetClock(clock)
+	runner.On("pg_dump").Delay(5 * time.Second)
+
+	done := make(chan CommandResult)
+	go func() {
+		res, _ := runner.Run(context.Background(), CommandSpec{Name: "pg_dump"})
+		done <- res
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	if res := <-done; res.Duration != 5*time.Second {
+		t.Errorf("duration %v", res.Duration)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err := runner.Run(ctx, CommandSpec{Name: "pg_dump"}); !errors.Is(err, context.Canceled) || res.ExitCode != -1 {
+		t.Errorf("cancelled run: %+v, %v", res, err)
+	}
+}
+
+func TestFakeRunner_StartAndSignal(t *testing.T) {
+	runner := NewFakeRunner()
+	runner.On("npm", "start").Stdout("listening\n").UntilSignal(os.Interrupt)
+	runner.On("crash").Exit(2)
+	runner.On("missing").Fail(exec.ErrNotFound)
+
+	proc, err := runner.Start(context.Background(), CommandSpec{Name: "npm", Args: []string{"run", "start"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := proc.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(out); string(data) != "listening\n" {
+		t.Errorf("stdout %q", data)
+	}
+	if err := proc.Signal(nil); err != nil {
+		t.Errorf("liveness probe: %v", err)
+	}
+	proc.Signal(os.Interrupt)
+	if err := proc.Wait(); err == nil || err.Error() != "signal: interrupt" {
+		t.Errorf("wait: %v", err)
+	}
+	if err := proc.Signal(os.Interrupt); !errors.Is(err, os.ErrProcessDone) {
+		t.Errorf("signal after exit: %v", err)
+	}
+
+	crash, _ := runner.Start(context.Background(), CommandSpec{Name: "crash"})
+	if err := crash.Wait(); err == nil || err.Error() != "exit status 2" {
+		t.Errorf("crash: %v", err)
+	}
+	if _, err := runner.Start(context.Background(), CommandSpec{Name: "missing"}); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("start failure: %v", err)
+	}
+
+	procs := runner.Processes()
+	if len(procs) != 2 || procs[0].Pid() == procs[1].Pid() || !procs[0].Exited() ||
+		!reflect.DeepEqual(procs[0].Signals(), []os.Signal{os.Interrupt}) {
+		t.Errorf("processes %+v", procs)
+	}
+}
+
+func TestRealRunner_Run(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh in PATH")
+	}
+	res, err := RealRunner{}.Run(context.Background(), CommandSpec{
+		Name:  "sh",
+		Args:  []string{"-c", "cat; echo oops >&2; exit 3"},
+		Stdin: strings.NewReader("hello"),
+	})
+	if err == nil || res.ExitCode != 3 || string(res.Stdout) != "hello" || string(res.Stderr) != "oops\n" {
+		t.Errorf("got %+v, %v", res, err)
+	}
+}
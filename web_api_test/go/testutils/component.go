@@ -5,6 +5,8 @@ package testutils
 
 import (
     "errors"
+    "fmt"
+    "strings"
     "sync"
     "time"
 )
@@ -355,6 +357,46 @@ type InMemoryComponent struct {
     statusErr  error
     healthErr  error
     statsErr   error
+    strict     bool
+    table      TransitionTable
+    history    []StateTransition
+    rejected   []error
+}
+
+// ErrInvalidTransition is wrapped by errors for transitions that the
+// component's transition table does not allow.
+var ErrInvalidTransition = errors.New("invalid state transition")
+
+// TransitionTable maps a state to the states it may move to.
+type TransitionTable map[string][]string
+
+// StateTransition is one applied state change.
+type StateTransition struct {
+    From string
+    To   string
+    At   time.Time
+}
+
+// DefaultTransitionTable returns the lifecycle used in strict mode:
+// stopped→running, running→{degraded,stopped,error},
+// degraded→{running,stopped,error}, error→stopped.
+func DefaultTransitionTable() TransitionTable {
+    return TransitionTable{
+        "stopped":  {"running"},
+        "running":  {"degraded", "stopped", "error"},
+        "degraded": {"running", "stopped", "error"},
+        "error":    {"stopped"},
+    }
+}
+
+// Allows reports whether the table permits from→to.
+func (t TransitionTable) Allows(from, to string) bool {
+    for _, s := range t[from] {
+        if s == to {
+            return true
+        }
+    }
+    return false
 }
 
 // NewInMemoryComponent creates a new component in "stopped" state with default healthy.
@@ -367,11 +409,73 @@ func NewInMemoryComponent(name string) *InMemoryComponent {
     }
 }
 
-// SetState sets the component's state.
+// SetStrict enables or disables transition validation. Strict mode uses
+// DefaultTransitionTable unless a custom table was set.
+func (c *InMemoryComponent) SetStrict(strict bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.strict = strict
+    if strict && c.table == nil {
+        c.table = DefaultTransitionTable()
+    }
+}
+
+// SetTransitionTable installs a custom transition table and enables strict mode.
+func (c *InMemoryComponent) SetTransitionTable(table TransitionTable) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.table = table
+    c.strict = true
+}
+
+// SetState sets the component's state. Setting the current state does
+// nothing. In strict mode an invalid transition leaves the state unchanged and is recorded in TransitionErrors.
 func (c *InMemoryComponent) SetState(state string) {
+    c.TransitionTo(state)
+}
+
+// TransitionTo moves the component to state, returning an error wrapping
+// ErrInvalidTransition when strict mode rejects it.
+func (c *InMemoryComponent) TransitionTo(state string) error {
     c.mu.Lock()
     defer c.mu.Unlock()
-    c.state = state
+    return c.transitionLocked(state)
+}
+
+// TransitionHistory returns the applied transitions in order.
+func (c *InMemoryComponent) TransitionHistory() []StateTransition {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    out := make([]StateTransition, len(c.history))
+    copy(out, c.history)
+    return out
+}
+
+// TransitionErrors returns the transitions rejected in strict mode.
+func (c *InMemoryComponent) TransitionErrors() []error {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    out := make([]error, len(c.rejected))
+    copy(out, c.rejected)
+    return out
+}
+
+// transitionLocked validates and applies a transition. Moving to the current
+// state (Start while running, Stop while stopped) is a no-op: it is neither
+// rejected nor recorded. Must be called with c.mu held.
+func (c *InMemoryComponent) transitionLocked(to string) error {
+    from := c.state
+    if from == to {
+        return nil
+    }
+    if c.strict && !c.table.Allows(from, to) {
+        err := fmt.Errorf("%w: %s: %s -> %s", ErrInvalidTransition, c.name, from, to)
+        c.rejected = append(c.rejected, err)
+        return err
+    }
+    c.state = to
+    c.history = append(c.history, StateTransition{From: from, To: to, At: time.Now()})
+    return nil
 }
 
 // SetHealth sets the health status.
@@ -437,8 +541,7 @@ func (c *InMemoryComponent) Start() error {
     if c.startErr != nil {
         return c.startErr
     }
-    c.state = "running"
-    return nil
+    return c.transitionLocked("running")
 }
 
 // Stop transitions to "stopped" unless error is set.
@@ -448,8 +551,7 @@ func (c *InMemoryComponent) Stop() error {
     if c.stopErr != nil {
         return c.stopErr
     }
-    c.state = "stopped"
-    return nil
+    return c.transitionLocked("stopped")
 }
 
 // Status returns current state unless error is set.
@@ -490,207 +592,114 @@ func (c *InMemoryComponent) Stats() (map[string]interface{}, error) {
 // ComponentConditioner – wraps a Component to add delays and per‑call errors.
 // --------------------------------------------------------------------
 
-// ComponentConditioner adds configurable delays and error injection to any Component.
+// ErrConditionerInjected is returned (wrapped) by probabilistic and mode-driven
+// failures injected by a ComponentConditioner.
+var ErrConditionerInjected = errors.New("component conditioner: injected failure")
+
+// ComponentConditioner adds configurable delays and error injection to any
+// Component. It is a typed front end to a CallConditioner; Conditions
+// exposes the rest of its settings. Its Component methods are generated
+// into component_conditioner_gen.go.
+//
+//go:generate go run conditioner_gen.go -iface Component -type ComponentConditioner -field component -passthrough Name
 type ComponentConditioner struct {
-    mu           sync.Mutex
-    component    Component
-    startDelay   time.Duration
-    stopDelay    time.Duration
-    statusDelay  time.Duration
-    healthDelay  time.Duration
-    statsDelay   time.Duration
-    startErrors  map[int]error
-    stopErrors   map[int]error
-    statusErrors map[int]error
-    healthErrors map[int]error
-    statsErrors  map[int]error
-    startCalls   int
-    stopCalls    int
-    statusCalls  int
-    healthCalls  int
-    statsCalls   int
+    component Component
+    cond      *CallConditioner
 }
 
 // NewComponentConditioner creates a conditioner around an existing Component.
 func NewComponentConditioner(comp Component) *ComponentConditioner {
-    return &ComponentConditioner{
-        component:    comp,
-        startErrors:  make(map[int]error),
-        stopErrors:   make(map[int]error),
-        statusErrors: make(map[int]error),
-        healthErrors: make(map[int]error),
-        statsErrors:  make(map[int]error),
-    }
+    cond := NewCallConditioner(ErrConditionerInjected)
+    cond.SetMutating("Start", "Stop")
+    return &ComponentConditioner{component: comp, cond: cond}
 }
 
-// SetStartDelay adds a fixed delay before Start.
-func (c *ComponentConditioner) SetStartDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.startDelay = d
+// Conditions returns the underlying CallConditioner, keyed by the Component
+// method names, e.g. for its call history.
+func (c *ComponentConditioner) Conditions() *CallConditioner {
+    return c.cond
 }
 
+// SetStartDelay adds a fixed delay before Start.
+func (c *ComponentConditioner) SetStartDelay(d time.Duration) { c.cond.SetDelay("Start", d) }
+
 // SetStopDelay adds a fixed delay before Stop.
-func (c *ComponentConditioner) SetStopDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.stopDelay = d
-}
+func (c *ComponentConditioner) SetStopDelay(d time.Duration) { c.cond.SetDelay("Stop", d) }
 
 // SetStatusDelay adds a fixed delay before Status.
-func (c *ComponentConditioner) SetStatusDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.statusDelay = d
-}
+func (c *ComponentConditioner) SetStatusDelay(d time.Duration) { c.cond.SetDelay("Status", d) }
 
 // SetHealthDelay adds a fixed delay before Health.
-func (c *ComponentConditioner) SetHealthDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.healthDelay = d
-}
+func (c *ComponentConditioner) SetHealthDelay(d time.Duration) { c.cond.SetDelay("Health", d) }
 
 // SetStatsDelay adds a fixed delay before Stats.
-func (c *ComponentConditioner) SetStatsDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.statsDelay = d
-}
+func (c *ComponentConditioner) SetStatsDelay(d time.Duration) { c.cond.SetDelay("Stats", d) }
+
+// SetClock replaces the clock used for delays (e.g. with a FakeClock).
+func (c *ComponentConditioner) SetClock(clock Clock) { c.cond.SetClock(clock) }
 
 // InjectStartError makes the nth call to Start return the given error.
 func (c *ComponentConditioner) InjectStartError(callNumber int, err error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.startErrors[callNumber] = err
+    c.cond.InjectError("Start", callNumber, err)
 }
 
 // InjectStopError makes the nth call to Stop return the given error.
 func (c *ComponentConditioner) InjectStopError(callNumber int, err error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.stopErrors[callNumber] = err
+    c.cond.InjectError("Stop", callNumber, err)
 }
 
 // InjectStatusError makes the nth call to Status return the given error.
 func (c *ComponentConditioner) InjectStatusError(callNumber int, err error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.statusErrors[callNumber] = err
+    c.cond.InjectError("Status", callNumber, err)
 }
 
 // InjectHealthError makes the nth call to Health return the given error.
 func (c *ComponentConditioner) InjectHealthError(callNumber int, err error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.healthErrors[callNumber] = err
+    c.cond.InjectError("Health", callNumber, err)
 }
 
 // InjectStatsError makes the nth call to Stats return the given error.
 func (c *ComponentConditioner) InjectStatsError(callNumber int, err error) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.statsErrors[callNumber] = err
+    c.cond.InjectError("Stats", callNumber, err)
 }
 
-// Name returns the component's name.
-func (c *ComponentConditioner) Name() string {
-    return c.component.Name()
+// SetLatencyDistribution adds a uniformly distributed delay in [min, max] to
+// every call, on top of the fixed per-method delays.
+func (c *ComponentConditioner) SetLatencyDistribution(min, max time.Duration) {
+    c.cond.SetLatencyDistribution(min, max)
 }
 
-// Start adds delay then delegates.
-func (c *ComponentConditioner) Start() error {
-    c.mu.Lock()
-    c.startCalls++
-    call := c.startCalls
-    delay := c.startDelay
-    err, ok := c.startErrors[call]
-    if ok {
-        delete(c.startErrors, call)
-        c.mu.Unlock()
-        return err
-    }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.component.Start()
+// SetErrorRate makes calls to method ("Start", "Stop", "Status", "Health" or
+// "Stats") fail with probability rate (0..1). Use SetSeed for reproducible runs.
+func (c *ComponentConditioner) SetErrorRate(method string, rate float64) {
+    c.cond.SetErrorRate(method, rate)
 }
 
-// Stop adds delay then delegates.
-func (c *ComponentConditioner) Stop() error {
-    c.mu.Lock()
-    c.stopCalls++
-    call := c.stopCalls
-    delay := c.stopDelay
-    err, ok := c.stopErrors[call]
-    if ok {
-        delete(c.stopErrors, call)
-        c.mu.Unlock()
-        return err
-    }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.component.Stop()
-}
+// SetSeed reseeds the random source used for jitter and error rates.
+func (c *ComponentConditioner) SetSeed(seed int64) { c.cond.SetSeed(seed) }
 
-// Status adds delay then delegates.
-func (c *ComponentConditioner) Status() (string, error) {
-    c.mu.Lock()
-    c.statusCalls++
-    call := c.statusCalls
-    delay := c.statusDelay
-    err, ok := c.statusErrors[call]
-    if ok {
-        delete(c.statusErrors, call)
-        c.mu.Unlock()
-        return "", err
+// SetTestRun reseeds the random source from run, using a child seed named
+// after the wrapped component so conditioners in one run stay independent.
+func (c *ComponentConditioner) SetTestRun(run *TestRun) {
+    if run == nil {
+        return
     }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.component.Status()
+    c.SetSeed(run.ChildSeed("flaky-modes/" + c.component.Name()))
 }
 
-// Health adds delay then delegates.
-func (c *ComponentConditioner) Health() (bool, error) {
-    c.mu.Lock()
-    c.healthCalls++
-    call := c.healthCalls
-    delay := c.healthDelay
-    err, ok := c.healthErrors[call]
-    if ok {
-        delete(c.healthErrors, call)
-        c.mu.Unlock()
-        return false, err
-    }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.component.Health()
-}
+// SetMode ties the conditioner to a ModeManager so it degrades in lockstep
+// with the mode-aware wrappers: degraded adds a delay, flaky fails at the
+// flaky rate, read-only rejects Start and Stop, and offline or maintenance
+// rejects every call. Pass nil to detach.
+func (c *ComponentConditioner) SetMode(mgr ModeManager) { c.cond.SetMode(mgr) }
 
-// Stats adds delay then delegates.
-func (c *ComponentConditioner) Stats() (map[string]interface{}, error) {
-    c.mu.Lock()
-    c.statsCalls++
-    call := c.statsCalls
-    delay := c.statsDelay
-    err, ok := c.statsErrors[call]
-    if ok {
-        delete(c.statsErrors, call)
-        c.mu.Unlock()
-        return nil, err
-    }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.component.Stats()
+// SetFlakyRate sets the failure probability used in flaky mode (default 0.5).
+func (c *ComponentConditioner) SetFlakyRate(rate float64) { c.cond.SetFlakyRate(rate) }
+
+// Durations returns a snapshot of the recorded call durations for method, in
+// nanoseconds, e.g. p95, _ := c.Durations("Health").Percentile(95).
+func (c *ComponentConditioner) Durations(method string) *IntCollection {
+    return c.cond.Durations(method)
 }
 
 // --------------------------------------------------------------------
@@ -704,7 +713,9 @@ type testingT interface {
 
 // ComponentAssertions provides convenience methods for verifying component calls.
 type ComponentAssertions struct {
-    t testingT
+    t      testingT
+    clock  Clock
+    logger *TestLogger
 }
 
 // NewComponentAssertions creates a new assertion helper.
@@ -712,6 +723,95 @@ func NewComponentAssertions(t testingT) *ComponentAssertions {
     return &ComponentAssertions{t: t}
 }
 
+// SetClock replaces the clock the Eventually* assertions poll with.
+func (a *ComponentAssertions) SetClock(clock Clock) {
+    a.clock = clock
+}
+
+// SetLogger makes the Eventually* assertions log every observation at DEBUG.
+func (a *ComponentAssertions) SetLogger(logger *TestLogger) {
+    a.logger = logger
+}
+
+// EventuallyHealthy polls c.Health every interval until it reports healthy
+// without error. After timeout it fails with every observation made.
+func (a *ComponentAssertions) EventuallyHealthy(c Component, timeout, interval time.Duration) bool {
+    return a.eventually(c, "healthy", timeout, interval, func() (bool, string) {
+        ok, err := c.Health()
+        if err != nil {
+            return false, "health error: " + err.Error()
+        }
+        return ok, fmt.Sprintf("healthy=%v", ok)
+    })
+}
+
+// EventuallyStatus polls c.Status every interval until it reports want
+// without error. After timeout it fails with every observation made.
+func (a *ComponentAssertions) EventuallyStatus(c Component, want string, timeout, interval time.Duration) bool {
+    return a.eventually(c, fmt.Sprintf("status %q", want), timeout, interval, func() (bool, string) {
+        status, err := c.Status()
+        if err != nil {
+            return false, "status error: " + err.Error()
+        }
+        return status == want, fmt.Sprintf("status=%q", status)
+    })
+}
+
+// eventually runs check immediately and then every interval until it
+// passes or timeout has elapsed on the assertion's clock.
+func (a *ComponentAssertions) eventually(c Component, desc string, timeout, interval time.Duration, check func() (bool, string)) bool {
+    clock := a.clock
+    if clock == nil {
+        clock = RealClock{}
+    }
+    name := c.Name()
+    start := clock.Now()
+    var history []string
+    for {
+        ok, observed := check()
+        elapsed := clock.Now().Sub(start)
+        history = append(history, fmt.Sprintf("+%v %s", elapsed, observed))
+        if a.logger != nil {
+            a.logger.Debug("component observed", map[string]any{
+                "component": name, "observed": observed, "elapsed": elapsed.String(),
+            })
+        }
+        if ok {
+            return true
+        }
+        if elapsed >= timeout {
+            a.t.Errorf("component %s did not become %s within %v (%d observations):\n  %s",
+                name, desc, timeout, len(history), strings.Join(history, "\n  "))
+            return false
+        }
+        wait := interval
+        if left := timeout - elapsed; left < wait {
+            wait = left
+        }
+        <-clock.After(wait)
+    }
+}
+
+// AssertStatsContain asserts that c.Stats has key and that its value
+// satisfies predicate.
+func (a *ComponentAssertions) AssertStatsContain(c Component, key string, predicate func(any) bool) bool {
+    stats, err := c.Stats()
+    if err != nil {
+        a.t.Errorf("component %s: stats failed: %v", c.Name(), err)
+        return false
+    }
+    v, ok := stats[key]
+    if !ok {
+        a.t.Errorf("component %s: stats have no %q: %v", c.Name(), key, stats)
+        return false
+    }
+    if !predicate(v) {
+        a.t.Errorf("component %s: stats[%q] = %v does not satisfy the predicate", c.Name(), key, v)
+        return false
+    }
+    return true
+}
+
 // AssertStartCalled asserts that Start was called at least once.
 func (a *ComponentAssertions) AssertStartCalled(m *MockComponent) {
     if m.startCalls == 0 {
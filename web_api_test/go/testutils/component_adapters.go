@@ -0,0 +1,105 @@
+package testutils
+
+import (
+	"context"
+)
+
+// --------------------------------------------------------------------
+// Component adapters – managers as registry members
+// --------------------------------------------------------------------
+
+// DockerServiceComponent exposes one compose service of a DockerManager as
+// a Component, so it can be registered, depended on and drawn alongside
+// in-process components.
+type DockerServiceComponent struct {
+	dm      *DockerManager
+	service string
+}
+
+// NewDockerServiceComponent adapts the named compose service of dm. The
+// component is named after the service.
+func NewDockerServiceComponent(dm *DockerManager, service string) *DockerServiceComponent {
+	return &DockerServiceComponent{dm: dm, service: service}
+}
+
+// Name returns the compose service name.
+func (c *DockerServiceComponent) Name() string { return c.service }
+
+// Start starts the service and waits for its readiness checks.
+func (c *DockerServiceComponent) Start() error {
+	return c.dm.StartServices(context.Background(), c.service)
+}
+
+// Stop stops the service's containers.
+func (c *DockerServiceComponent) Stop() error {
+	return c.dm.StopServices(context.Background(), c.service)
+}
+
+// Status reports "running" or "stopped".
+func (c *DockerServiceComponent) Status() (string, error) {
+	running, err := c.dm.IsServiceRunning(context.Background(), c.service)
+	if err != nil {
+		return "", err
+	}
+	if running {
+		return "running", nil
+	}
+	return "stopped", nil
+}
+
+// Health reports whether the service has a running container.
+func (c *DockerServiceComponent) Health() (bool, error) {
+	return c.dm.IsServiceRunning(context.Background(), c.service)
+}
+
+// Stats returns the service and compose project names.
+func (c *DockerServiceComponent) Stats() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"service": c.service,
+		"project": c.dm.project,
+	}, nil
+}
+
+// ServerComponent exposes a ServerManager's process as a Component.
+type ServerComponent struct {
+	name string
+	sm   *ServerManager
+}
+
+// NewServerComponent adapts sm under the given component name.
+func NewServerComponent(name string, sm *ServerManager) *ServerComponent {
+	return &ServerComponent{name: name, sm: sm}
+}
+
+// Name returns the name given to NewServerComponent.
+func (c *ServerComponent) Name() string { return c.name }
+
+// Start launches the server and waits for its health check.
+func (c *ServerComponent) Start() error { return c.sm.Start(context.Background()) }
+
+// Stop shuts the server down.
+func (c *ServerComponent) Stop() error { return c.sm.Stop(context.Background()) }
+
+// Status reports "running" or "stopped".
+func (c *ServerComponent) Status() (string, error) {
+	if c.sm.IsRunning() {
+		return "running", nil
+	}
+	return "stopped", nil
+}
+
+// Health reports whether the server process is alive.
+func (c *ServerComponent) Health() (bool, error) { return c.sm.IsRunning(), nil }
+
+// Stats returns the server's PID and base URL.
+func (c *ServerComponent) Stats() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"pid":      c.sm.Pid(),
+		"base_url": c.sm.baseURL,
+	}, nil
+}
+
+var (
+	_ Component = (*DockerServiceComponent)(nil)
+	_ Component = (*ServerComponent)(nil)
+)
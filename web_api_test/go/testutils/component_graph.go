@@ -0,0 +1,150 @@
+package testutils
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Dependency graph export
+// ------------------------------------------------------------------------
+
+// graphNode is a component as drawn: its status and health class at the
+// time of export.
+type graphNode struct {
+	name   string
+	status string
+	class  string // "healthy", "unhealthy" or "unknown"
+}
+
+// graphEdge points from a component to one of its dependencies. label is
+// the dependency's start duration from the last StartAll, if any.
+type graphEdge struct {
+	from, to string
+	label    string
+}
+
+// graphColors are the fill colors for each health class, shared by both
+// formats so a DOT render and a Mermaid render look alike.
+var graphColors = map[string]string{
+	"healthy":   "#b7e4c7",
+	"unhealthy": "#f4a6a6",
+	"unknown":   "#d9d9d9",
+}
+
+// snapshotGraph queries every component's status and health and collects
+// the dependency edges, both sorted by name so exports are deterministic.
+func (r *ComponentRegistry) snapshotGraph() ([]graphNode, []graphEdge) {
+	starts := r.StartDurations()
+	names := r.Names()
+
+	nodes := make([]graphNode, 0, len(names))
+	var edges []graphEdge
+	for _, name := range names {
+		c, _ := r.Get(name)
+		nodes = append(nodes, describeGraphNode(c))
+		for _, dep := range r.Dependencies(name) {
+			e := graphEdge{from: name, to: dep}
+			if d, ok := starts[dep]; ok {
+				e.label = d.Round(time.Millisecond).String()
+			}
+			edges = append(edges, e)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].from != edges[j].from {
+			return edges[i].from < edges[j].from
+		}
+		return edges[i].to < edges[j].to
+	})
+	return nodes, edges
+}
+
+func describeGraphNode(c Component) graphNode {
+	n := graphNode{name: c.Name(), class: "unknown"}
+	status, err := c.Status()
+	if err != nil {
+		status = "error"
+	}
+	n.status = status
+	if healthy, err := c.Health(); err == nil {
+		if healthy {
+			n.class = "healthy"
+		} else {
+			n.class = "unhealthy"
+		}
+	}
+	return n
+}
+
+// ExportDOT writes the registry as a Graphviz digraph. Each component is a
+// box labelled with its current status and filled by health; each edge
+// points from a component to a dependency and is labelled with how long
+// the dependency took to start in the last StartAll.
+//
+//	reg.ExportDOT(f) // then: dot -Tsvg components.dot
+func (r *ComponentRegistry) ExportDOT(w io.Writer) error {
+	nodes, edges := r.snapshotGraph()
+	var b strings.Builder
+	b.WriteString("digraph components {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\"];\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "  %s [label=%s, fillcolor=\"%s\"];\n",
+			dotQuote(n.name), dotQuote(n.name+"\n"+n.status), graphColors[n.class])
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.from), dotQuote(e.to))
+		if e.label != "" {
+			fmt.Fprintf(&b, " [label=%s]", dotQuote(e.label))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ExportMermaid writes the same graph as ExportDOT as a Mermaid flowchart,
+// for embedding in markdown such as a CI job summary inside a ```mermaid
+// block. Node IDs are positional (c0, c1, ...) so any component name is
+// safe.
+func (r *ComponentRegistry) ExportMermaid(w io.Writer) error {
+	nodes, edges := r.snapshotGraph()
+	ids := make(map[string]string, len(nodes))
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, n := range nodes {
+		ids[n.name] = fmt.Sprintf("c%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s<br/>%s\"]:::%s\n",
+			ids[n.name], mermaidEscape(n.name), mermaidEscape(n.status), n.class)
+	}
+	for _, e := range edges {
+		if e.label != "" {
+			fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[e.from], mermaidEscape(e.label), ids[e.to])
+		} else {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[e.from], ids[e.to])
+		}
+	}
+	for _, class := range []string{"healthy", "unhealthy", "unknown"} {
+		fmt.Fprintf(&b, "  classDef %s fill:%s\n", class, graphColors[class])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote renders s as a DOT quoted string; newlines become DOT's \n line
+// breaks.
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// mermaidEscape makes s safe inside a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", "<br/>")
+	return r.Replace(s)
+}
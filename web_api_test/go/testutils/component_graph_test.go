@@ -0,0 +1,120 @@
+package testutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// graphRegistry builds api -> {cache, db}, cache -> db, with db and cache
+// taking 120ms and 5ms to start on a MockClock. Components are registered
+// dependents first to show StartAll reordering them.
+func graphRegistry(t *testing.T) (*ComponentRegistry, *[]string) {
+	t.Helper()
+	clock := NewMockClock(time.Time{})
+	reg := NewComponentRegistry()
+	reg.SetClock(clock)
+
+	var started []string
+	add := func(name string, took time.Duration) *MockComponent {
+		c := NewMockComponent(name)
+		c.SetStartFunc(func() error {
+			started = append(started, name)
+			clock.Advance(took)
+			return nil
+		})
+		reg.MustRegister(c)
+		return c
+	}
+	add("api", 0)
+	cache := add("cache", 5*time.Millisecond)
+	add("db", 120*time.Millisecond)
+	cache.SetStatusFunc(func() (string, error) { return "degraded", nil })
+	cache.SetHealthFunc(func() (bool, error) { return false, nil })
+
+	for name, deps := range map[string][]string{"api": {"db", "cache"}, "cache": {"db"}} {
+		if err := reg.DependsOn(name, deps...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return reg, &started
+}
+
+func TestComponentRegistry_StartAllFollowsDependencies(t *testing.T) {
+	reg, started := graphRegistry(t)
+	if err := reg.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*started, ","); got != "db,cache,api" {
+		t.Errorf("start order = %s", got)
+	}
+	if d := reg.StartDurations()["db"]; d != 120*time.Millisecond {
+		t.Errorf("db start duration = %v", d)
+	}
+
+	err := reg.DependsOn("db", "api")
+	if err == nil || !strings.Contains(err.Error(), "db -> api -> db") {
+		t.Errorf("cycle: %v", err)
+	}
+	if err := reg.DependsOn("api", "queue"); err == nil {
+		t.Error("expected an error for an unregistered dependency")
+	}
+}
+
+func TestComponentRegistry_ExportDOT(t *testing.T) {
+	reg, _ := graphRegistry(t)
+	if err := reg.StartAll(); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err := reg.ExportDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph components {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled"];
+  "api" [label="api\nunknown", fillcolor="#b7e4c7"];
+  "cache" [label="cache\ndegraded", fillcolor="#f4a6a6"];
+  "db" [label="db\nunknown", fillcolor="#b7e4c7"];
+  "api" -> "cache" [label="5ms"];
+  "api" -> "db" [label="120ms"];
+  "cache" -> "db" [label="120ms"];
+}
+`
+	if b.String() != want {
+		t.Errorf("DOT output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestComponentRegistry_ExportMermaid(t *testing.T) {
+	reg, _ := graphRegistry(t)
+	broken := NewMockComponent(`queue "main"`)
+	broken.SetHealthFunc(func() (bool, error) { return false, errors.New("probe failed") })
+	reg.MustRegister(broken)
+	if err := reg.DependsOn("api", broken.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before StartAll there are no durations, so edges are unlabelled.
+	var b strings.Builder
+	if err := reg.ExportMermaid(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `graph LR
+  c0["api<br/>unknown"]:::healthy
+  c1["cache<br/>degraded"]:::unhealthy
+  c2["db<br/>unknown"]:::healthy
+  c3["queue #quot;main#quot;<br/>unknown"]:::unknown
+  c0 --> c1
+  c0 --> c2
+  c0 --> c3
+  c1 --> c2
+  classDef healthy fill:#b7e4c7
+  classDef unhealthy fill:#f4a6a6
+  classDef unknown fill:#d9d9d9
+`
+	if b.String() != want {
+		t.Errorf("Mermaid output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
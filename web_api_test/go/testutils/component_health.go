@@ -0,0 +1,177 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Overall statuses reported by ComponentHealthHandler.
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// ComponentHealthReport is the result of checking one Component.
+type ComponentHealthReport struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status,omitempty"`
+	Healthy   bool    `json:"healthy"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport is the body served by ComponentHealthHandler.
+type HealthReport struct {
+	Status     string                  `json:"status"`
+	Timestamp  time.Time               `json:"timestamp"`
+	Components []ComponentHealthReport `json:"components,omitempty"`
+}
+
+// probeComponent calls Health and then Status on c, giving up after
+// timeout. A panic in either call is reported as unhealthy. A timed-out
+// call keeps running in the background; Component offers no way to stop it.
+func probeComponent(c Component, timeout time.Duration, clock Clock) ComponentHealthReport {
+	name := c.Name()
+	start := clock.Now()
+	done := make(chan ComponentHealthReport, 1)
+
+	go func() {
+		report := ComponentHealthReport{Name: name}
+		defer func() {
+			if r := recover(); r != nil {
+				report.Healthy = false
+				report.Error = fmt.Sprintf("panic during health check: %v", r)
+			}
+			done <- report
+		}()
+
+		healthy, err := c.Health()
+		report.Healthy = healthy && err == nil
+		if err != nil {
+			report.Error = err.Error()
+		}
+		status, err := c.Status()
+		report.Status = status
+		if err != nil && report.Error == "" {
+			report.Healthy = false
+			report.Error = err.Error()
+		}
+	}()
+
+	var report ComponentHealthReport
+	select {
+	case report = <-done:
+	case <-clock.After(timeout):
+		report = ComponentHealthReport{
+			Name:  name,
+			Error: fmt.Sprintf("health check timed out after %v", timeout),
+		}
+	}
+	report.LatencyMS = float64(clock.Now().Sub(start)) / float64(time.Millisecond)
+	return report
+}
+
+// componentHealthHandler holds the settings behind ComponentHealthHandler.
+type componentHealthHandler struct {
+	registry *ComponentRegistry
+	timeout  time.Duration
+	clock    Clock
+}
+
+// HealthHandlerOption configures ComponentHealthHandler.
+type HealthHandlerOption func(*componentHealthHandler)
+
+// WithComponentTimeout bounds each component's check (default 2s).
+func WithComponentTimeout(d time.Duration) HealthHandlerOption {
+	return func(h *componentHealthHandler) {
+		if d > 0 {
+			h.timeout = d
+		}
+	}
+}
+
+// WithHealthClock sets the clock used for timestamps, latency and timeouts.
+func WithHealthClock(c Clock) HealthHandlerOption {
+	return func(h *componentHealthHandler) {
+		if c != nil {
+			h.clock = c
+		}
+	}
+}
+
+// ComponentHealthHandler returns an App handler, typically mounted at
+// GET /health, that checks every component in registry concurrently. It
+// answers 200 when all are healthy and 503 otherwise, with a HealthReport
+// body. Query parameters:
+//
+//	exclude=a,b    skip the named components (may be repeated)
+//	verbose=false  omit the per-component details
+//
+// This is the registry-backed counterpart of HealthHandler in health.go.
+func ComponentHealthHandler(registry *ComponentRegistry, opts ...HealthHandlerOption) Handler {
+	h := &componentHealthHandler{
+		registry: registry,
+		timeout:  2 * time.Second,
+		clock:    RealClock{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.serve
+}
+
+func (h *componentHealthHandler) serve(ctx context.Context, req *Request) (*Response, error) {
+	excluded := make(map[string]bool)
+	for _, v := range req.QueryParams("exclude") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				excluded[name] = true
+			}
+		}
+	}
+
+	var comps []Component
+	for _, c := range h.registry.All() {
+		if !excluded[c.Name()] {
+			comps = append(comps, c)
+		}
+	}
+
+	reports := make([]ComponentHealthReport, len(comps))
+	var wg sync.WaitGroup
+	for i, c := range comps {
+		wg.Add(1)
+		go func(i int, c Component) {
+			defer wg.Done()
+			reports[i] = probeComponent(c, h.timeout, h.clock)
+		}(i, c)
+	}
+	wg.Wait()
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+
+	body := HealthReport{Status: HealthStatusHealthy, Timestamp: h.clock.Now().UTC()}
+	for _, r := range reports {
+		if !r.Healthy {
+			body.Status = HealthStatusUnhealthy
+			break
+		}
+	}
+	if v := req.URL.Query().Get("verbose"); v != "false" && v != "0" {
+		body.Components = reports
+	}
+
+	status := http.StatusOK
+	if body.Status != HealthStatusHealthy {
+		status = http.StatusServiceUnavailable
+	}
+	resp, err := JSON(status, body)
+	if err == nil {
+		resp.Headers.Set("Cache-Control", "no-store")
+	}
+	return resp, err
+}
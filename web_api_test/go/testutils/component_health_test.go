@@ -0,0 +1,76 @@
+package testutils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func healthRequest(t *testing.T, h Handler, target string) (*Response, HealthReport) {
+	t.Helper()
+	req := &Request{Request: httptest.NewRequest(http.MethodGet, target, nil)}
+	resp, err := h(req.Context(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	return resp, resp.Body.(HealthReport)
+}
+
+func TestComponentHealthHandler_AllHealthy(t *testing.T) {
+	reg := NewComponentRegistry()
+	reg.MustRegister(NewMockComponent("db"))
+	reg.MustRegister(NewMockComponent("cache"))
+
+	resp, body := healthRequest(t, ComponentHealthHandler(reg), "/health")
+	if resp.Status != http.StatusOK || body.Status != HealthStatusHealthy {
+		t.Fatalf("got %d %q, want 200 healthy", resp.Status, body.Status)
+	}
+	if len(body.Components) != 2 || body.Components[0].Name != "cache" {
+		t.Errorf("expected both components sorted by name, got %+v", body.Components)
+	}
+	if body.Timestamp.IsZero() {
+		t.Error("timestamp not set")
+	}
+}
+
+func TestComponentHealthHandler_FailuresPanicsAndTimeouts(t *testing.T) {
+	failing := NewMockComponent("db")
+	failing.SetHealthFunc(func() (bool, error) { return false, errors.New("connection refused") })
+	panicking := NewMockComponent("queue")
+	panicking.SetHealthFunc(func() (bool, error) { panic("boom") })
+	slow := NewMockComponent("search")
+	slow.SetHealthFunc(func() (bool, error) { time.Sleep(time.Second); return true, nil })
+
+	reg := NewComponentRegistry()
+	reg.MustRegister(failing)
+	reg.MustRegister(panicking)
+	reg.MustRegister(slow)
+	reg.MustRegister(NewMockComponent("cache"))
+
+	h := ComponentHealthHandler(reg, WithComponentTimeout(50*time.Millisecond))
+	resp, body := healthRequest(t, h, "/health")
+	if resp.Status != http.StatusServiceUnavailable || body.Status != HealthStatusUnhealthy {
+		t.Fatalf("got %d %q, want 503 unhealthy", resp.Status, body.Status)
+	}
+
+	byName := make(map[string]ComponentHealthReport)
+	for _, c := range body.Components {
+		byName[c.Name] = c
+	}
+	for name, want := range map[string]string{"db": "connection refused", "queue": "panic", "search": "timed out"} {
+		if c := byName[name]; c.Healthy || !strings.Contains(c.Error, want) {
+			t.Errorf("%s: got %+v, want unhealthy with %q", name, c, want)
+		}
+	}
+	if !byName["cache"].Healthy {
+		t.Error("cache should stay healthy")
+	}
+
+	resp, body = healthRequest(t, h, "/health?exclude=db,queue&exclude=search&verbose=false")
+	if resp.Status != http.StatusOK || body.Components != nil {
+		t.Errorf("excluding failures with verbose=false: got %d %+v", resp.Status, body)
+	}
+}
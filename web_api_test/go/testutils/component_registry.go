@@ -0,0 +1,260 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComponentRegistry holds named Components so scenarios, health endpoints
+// and teardown code can address them by name. Components are started in
+// registration order, after the components they depend on, and stopped in
+// reverse.
+type ComponentRegistry struct {
+	mu     sync.RWMutex
+	comps  map[string]Component
+	order  []string
+	deps   map[string][]string
+	clock  Clock
+	starts map[string]time.Duration // from the last StartAll
+}
+
+// NewComponentRegistry creates an empty registry.
+func NewComponentRegistry() *ComponentRegistry {
+	return &ComponentRegistry{
+		comps:  make(map[string]Component),
+		deps:   make(map[string][]string),
+		starts: make(map[string]time.Duration),
+	}
+}
+
+// SetClock replaces the clock used to time StartAll (e.g. with a MockClock).
+func (r *ComponentRegistry) SetClock(clock Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clock
+}
+
+// Register adds a component under its Name. Registering the same name twice
+// is an error.
+func (r *ComponentRegistry) Register(c Component) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := c.Name()
+	if _, exists := r.comps[name]; exists {
+		return fmt.Errorf("component %q already registered", name)
+	}
+	r.comps[name] = c
+	r.order = append(r.order, name)
+	return nil
+}
+
+// MustRegister is like Register but panics on error.
+func (r *ComponentRegistry) MustRegister(c Component) {
+	if err := r.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Get returns the named component.
+func (r *ComponentRegistry) Get(name string) (Component, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.comps[name]
+	return c, ok
+}
+
+// Names returns the registered names, sorted.
+func (r *ComponentRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.order))
+	copy(names, r.order)
+	sort.Strings(names)
+	return names
+}
+
+// All returns the components in registration order.
+func (r *ComponentRegistry) All() []Component {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Component, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.comps[name])
+	}
+	return out
+}
+
+// DependsOn records that the named component needs deps running first.
+// All of them must be registered, and a dependency that would close a cycle
+// is rejected.
+func (r *ComponentRegistry) DependsOn(name string, deps ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.comps[name]; !ok {
+		return fmt.Errorf("component %q is not registered", name)
+	}
+	for _, dep := range deps {
+		if _, ok := r.comps[dep]; !ok {
+			return fmt.Errorf("dependency %q of %q is not registered", dep, name)
+		}
+		if path := r.dependencyPath(dep, name); path != nil {
+			return fmt.Errorf("component dependency cycle: %s -> %s", name, strings.Join(path, " -> "))
+		}
+	}
+	for _, dep := range deps {
+		if !slices.Contains(r.deps[name], dep) {
+			r.deps[name] = append(r.deps[name], dep)
+		}
+	}
+	return nil
+}
+
+// Dependencies returns the names the component depends on, sorted.
+func (r *ComponentRegistry) Dependencies(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	deps := append([]string(nil), r.deps[name]...)
+	sort.Strings(deps)
+	return deps
+}
+
+// dependencyPath returns the chain from -> ... -> to through recorded
+// dependencies, or nil if there is none. The caller holds r.mu.
+func (r *ComponentRegistry) dependencyPath(from, to string) []string {
+	if from == to {
+		return []string{to}
+	}
+	for _, dep := range r.deps[from] {
+		if rest := r.dependencyPath(dep, to); rest != nil {
+			return append([]string{from}, rest...)
+		}
+	}
+	return nil
+}
+
+// startOrder returns the components in registration order, except that
+// each one comes after its dependencies.
+func (r *ComponentRegistry) startOrder() []Component {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]Component, 0, len(r.order))
+	placed := make(map[string]bool, len(r.order))
+	var place func(name string)
+	place = func(name string) {
+		if placed[name] {
+			return
+		}
+		placed[name] = true
+		for _, dep := range r.deps[name] {
+			place(dep)
+		}
+		out = append(out, r.comps[name])
+	}
+	for _, name := range r.order {
+		place(name)
+	}
+	return out
+}
+
+// StartAll starts every component in dependency order, stopping at the
+// first failure. How long each Start took is kept for StartDurations and
+// the graph exports.
+func (r *ComponentRegistry) StartAll() error {
+	clock := r.beginStart()
+	for _, c := range r.startOrder() {
+		if _, err := r.startOne(clock, c); err != nil {
+			return fmt.Errorf("failed to start component %s: %w", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// beginStart forgets the durations of the previous start and returns the
+// clock to time this one with.
+func (r *ComponentRegistry) beginStart() Clock {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.starts = make(map[string]time.Duration)
+	if r.clock == nil {
+		return RealClock{}
+	}
+	return r.clock
+}
+
+// startOne starts c and records how long it took.
+func (r *ComponentRegistry) startOne(clock Clock, c Component) (time.Duration, error) {
+	began := clock.Now()
+	err := c.Start()
+	took := clock.Now().Sub(began)
+	r.mu.Lock()
+	r.starts[c.Name()] = took
+	r.mu.Unlock()
+	return took, err
+}
+
+// StartDurations returns how long each component's Start took during the
+// last StartAll.
+func (r *ComponentRegistry) StartDurations() map[string]time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]time.Duration, len(r.starts))
+	for name, d := range r.starts {
+		out[name] = d
+	}
+	return out
+}
+
+// StopOption configures StopAll.
+type StopOption func(*stopConfig)
+
+type stopConfig struct {
+	leakSnapshot *GoroutineSnapshot
+	leakOpts     []LeakOption
+}
+
+// WithLeakCheck makes StopAll report goroutines started since snap that are
+// still running once every component has stopped. Take the snapshot before
+// StartAll.
+func WithLeakCheck(snap *GoroutineSnapshot, opts ...LeakOption) StopOption {
+	return func(c *stopConfig) {
+		c.leakSnapshot = snap
+		c.leakOpts = opts
+	}
+}
+
+// StopAll stops every component in reverse start order and returns
+// all failures, including leaked goroutines when WithLeakCheck is given.
+func (r *ComponentRegistry) StopAll(opts ...StopOption) error {
+	var cfg stopConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	comps := r.startOrder()
+	errs := NewCompositeError("stop components")
+	for i := len(comps) - 1; i >= 0; i-- {
+		if err := comps[i].Stop(); err != nil {
+			errs.Add(fmt.Errorf("failed to stop component %s: %w", comps[i].Name(), err))
+		}
+	}
+	if cfg.leakSnapshot != nil {
+		errs.Add(cfg.leakSnapshot.Check(cfg.leakOpts...))
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// PushTeardown pushes a stop step for every registered component onto c,
+// in start order, so running c stops them in reverse like StopAll does.
+// Components registered afterwards are not included.
+func (r *ComponentRegistry) PushTeardown(c *Cleanup) {
+	for _, comp := range r.startOrder() {
+		c.Push("stop component "+comp.Name(), func(context.Context) error { return comp.Stop() })
+	}
+}
@@ -0,0 +1,146 @@
+package testutils
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComponentAssertions_EventuallyHealthyMock(t *testing.T) {
+	clock := steppingClock{NewMockClock(time.Time{})}
+	rec := &recordingT{}
+	a := NewComponentAssertions(rec)
+	a.SetClock(clock)
+	var logs bytes.Buffer
+	a.SetLogger(NewTestLogger("assert", &logs, WithLevel(DEBUG)))
+
+	m := NewMockComponent("db")
+	m.InjectHealthValue(1, false)
+	m.InjectHealthError(2, errors.New("connection refused"))
+	m.InjectHealthValue(3, false)
+
+	start := clock.Now()
+	if !a.EventuallyHealthy(m, time.Second, 10*time.Millisecond) || len(rec.failures()) != 0 {
+		t.Fatalf("failures: %v", rec.failures())
+	}
+	if elapsed := clock.Now().Sub(start); elapsed != 30*time.Millisecond {
+		t.Errorf("became healthy after %v of polling, want 30ms", elapsed)
+	}
+	if n := strings.Count(logs.String(), "component observed"); n != 4 {
+		t.Errorf("logged %d observations, want 4:\n%s", n, logs.String())
+	}
+}
+
+func TestComponentAssertions_EventuallyHealthyTimesOutThroughConditioner(t *testing.T) {
+	clock := steppingClock{NewMockClock(time.Time{})}
+	inner := NewInMemoryComponent("cache")
+	inner.SetHealth(false)
+	cond := NewComponentConditioner(inner)
+	cond.SetClock(clock)
+	cond.SetHealthDelay(40 * time.Millisecond)
+
+	rec := &recordingT{}
+	a := NewComponentAssertions(rec)
+	a.SetClock(clock)
+
+	// Each poll takes 40ms in Health plus the 10ms interval: observations
+	// at +40ms, +90ms and +140ms, the last one past the 100ms timeout.
+	if a.EventuallyHealthy(cond, 100*time.Millisecond, 10*time.Millisecond) {
+		t.Fatal("expected a timeout")
+	}
+	failures := rec.failures()
+	if len(failures) != 1 {
+		t.Fatalf("failures: %v", failures)
+	}
+	for _, want := range []string{"cache did not become healthy within 100ms (3 observations)",
+		"+40ms healthy=false", "+90ms healthy=false", "+140ms healthy=false"} {
+		if !strings.Contains(failures[0], want) {
+			t.Errorf("failure missing %q:\n%s", want, failures[0])
+		}
+	}
+}
+
+func TestComponentAssertions_EventuallyStatusAndStats(t *testing.T) {
+	clock := steppingClock{NewMockClock(time.Time{})}
+	rec := &recordingT{}
+	a := NewComponentAssertions(rec)
+	a.SetClock(clock)
+
+	m := NewMockComponent("api")
+	m.InjectStatusValue(1, "starting")
+	m.InjectStatusValue(2, "starting")
+	m.SetStatusFunc(func() (string, error) { return "running", nil })
+	if !a.EventuallyStatus(m, "running", time.Second, 5*time.Millisecond) {
+		t.Fatalf("failures: %v", rec.failures())
+	}
+
+	c := NewInMemoryComponent("queue")
+	c.SetStat("depth", 3)
+	positive := func(v any) bool { n, ok := v.(int); return ok && n > 0 }
+	if !a.AssertStatsContain(c, "depth", positive) {
+		t.Fatalf("failures: %v", rec.failures())
+	}
+	a.AssertStatsContain(c, "lag", positive)
+	c.SetStat("depth", 0)
+	a.AssertStatsContain(c, "depth", positive)
+	if failures := rec.failures(); len(failures) != 2 ||
+		!strings.Contains(failures[0], `no "lag"`) || !strings.Contains(failures[1], "does not satisfy") {
+		t.Errorf("failures: %v", failures)
+	}
+}
+
+func TestInMemoryComponent_StrictTransitions(t *testing.T) {
+	c := NewInMemoryComponent("db")
+	c.SetStrict(true)
+
+	if err := c.Stop(); err != nil {
+		t.Errorf("Stop while stopped: %v", err)
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(); err != nil {
+		t.Errorf("Start while running: %v", err)
+	}
+	if err := c.TransitionTo("degraded"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetState("degraded")
+	if err := c.TransitionTo("stopped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.TransitionTo("degraded"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("stopped -> degraded: %v", err)
+	}
+
+	var got []string
+	for _, tr := range c.TransitionHistory() {
+		got = append(got, tr.From+"->"+tr.To)
+	}
+	if want := "stopped->running running->degraded degraded->stopped"; strings.Join(got, " ") != want {
+		t.Errorf("history %v, want %s", got, want)
+	}
+	if errs := c.TransitionErrors(); len(errs) != 1 || !strings.Contains(errs[0].Error(), "db: stopped -> degraded") {
+		t.Errorf("rejected %v", errs)
+	}
+}
+
+func TestInMemoryComponent_CustomTransitionTable(t *testing.T) {
+	c := NewInMemoryComponent("queue")
+	c.SetTransitionTable(TransitionTable{"stopped": {"draining"}, "draining": {"stopped"}})
+
+	if err := c.Start(); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Start outside the custom table: %v", err)
+	}
+	if err := c.TransitionTo("draining"); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := c.Status(); status != "draining" {
+		t.Errorf("status %s", status)
+	}
+	if err := c.Stop(); err != nil || len(c.TransitionHistory()) != 2 {
+		t.Errorf("Stop: %v, history %v", err, c.TransitionHistory())
+	}
+}
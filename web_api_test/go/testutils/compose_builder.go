@@ -0,0 +1,336 @@
+package testutils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------------------------------------------------
+// Programmatic compose files
+// ------------------------------------------------------------------------
+
+// GeneratedComposeFile is the name ComposeBuilder writes its compose file
+// under in the test directory.
+const GeneratedComposeFile = "docker-compose.generated.yml"
+
+// HostPortAuto asks ComposeBuilder.Port for a free host port.
+const HostPortAuto = 0
+
+// composeServiceName is what compose accepts as a service name.
+var composeServiceName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ComposeBuilder assembles a minimal compose file in Go, for containers a
+// test needs without maintaining a compose file for them:
+//
+//	b := NewComposeBuilder()
+//	b.AddService("postgres", "postgres:16").
+//		Env("POSTGRES_PASSWORD", "test").
+//		Port(5432, HostPortAuto).
+//		HealthCmd("pg_isready", "-U", "postgres").
+//		Volume("pgdata", "/var/lib/postgresql/data")
+//	build, err := b.Build(tdm)
+//	dm, err := NewDockerManager(&config.TestConfig{DockerConfig: build.Config, ...}, logger, build.DockerOption())
+//
+// Mistakes are collected as the services are described and reported by
+// Build, before anything is written. The output is deterministic: services,
+// environment variables and ports are sorted, so generated files can be
+// compared against golden files.
+type ComposeBuilder struct {
+	services []*ComposeService
+	freePort func() (int, error)
+}
+
+// NewComposeBuilder returns an empty builder.
+func NewComposeBuilder() *ComposeBuilder {
+	return &ComposeBuilder{freePort: FreePort}
+}
+
+// ComposeService describes one service of a ComposeBuilder. Its methods
+// return the service so calls can be chained.
+type ComposeService struct {
+	name    string
+	image   string
+	env     map[string]string
+	ports   []composePortSpec
+	health  []string
+	volumes []composeVolumeSpec
+}
+
+type composePortSpec struct{ container, host int }
+
+type composeVolumeSpec struct{ tdmPath, containerPath string }
+
+// AddService adds a service running image.
+func (b *ComposeBuilder) AddService(name, image string) *ComposeService {
+	s := &ComposeService{name: name, image: image, env: make(map[string]string)}
+	b.services = append(b.services, s)
+	return s
+}
+
+// Env sets an environment variable. '$' is escaped, so the value reaches
+// the container as given instead of being interpolated by compose.
+func (s *ComposeService) Env(key, value string) *ComposeService {
+	s.env[key] = value
+	return s
+}
+
+// Port publishes containerPort on hostPort, or on a free host port chosen
+// by Build when hostPort is HostPortAuto.
+func (s *ComposeService) Port(containerPort, hostPort int) *ComposeService {
+	s.ports = append(s.ports, composePortSpec{container: containerPort, host: hostPort})
+	return s
+}
+
+// HealthCmd sets the container health check. A single argument runs
+// through the shell (CMD-SHELL), several are executed directly (CMD).
+func (s *ComposeService) HealthCmd(cmd ...string) *ComposeService {
+	s.health = cmd
+	return s
+}
+
+// Volume mounts tdmPath, a directory relative to the test directory, at
+// containerPath. Build creates the directory, so it is removed with the
+// rest of the test data.
+func (s *ComposeService) Volume(tdmPath, containerPath string) *ComposeService {
+	s.volumes = append(s.volumes, composeVolumeSpec{tdmPath: tdmPath, containerPath: containerPath})
+	return s
+}
+
+// ComposeBuild is the result of ComposeBuilder.Build.
+type ComposeBuild struct {
+	// Config runs the generated file from the test directory. Services
+	// holds a "service:containerPort" readiness check per published port.
+	Config config.DockerConfig
+	// Path is the generated compose file.
+	Path string
+	// Ports maps "service/containerPort" to the host port it was
+	// published on.
+	Ports map[string]int
+}
+
+// Port returns the host port containerPort of service was published on.
+func (cb *ComposeBuild) Port(service string, containerPort int) (int, bool) {
+	port, ok := cb.Ports[portKey(service, containerPort)]
+	return port, ok
+}
+
+// DockerOption hands the published ports to a DockerManager, so
+// ResolvedPort answers them and the readiness checks in Config dial them
+// on the loopback address.
+func (cb *ComposeBuild) DockerOption() DockerOption {
+	return func(dm *DockerManager) { dm.presetPorts = cb.Ports }
+}
+
+// composeFileDoc and composeServiceDoc are the generated YAML. Field
+// order is fixed and yaml.v3 sorts map keys.
+type composeFileDoc struct {
+	Services map[string]composeServiceDoc `yaml:"services"`
+}
+
+type composeServiceDoc struct {
+	Image       string            `yaml:"image"`
+	Environment map[string]string `yaml:"environment,omitempty"`
+	Ports       quotedStrings     `yaml:"ports,omitempty"`
+	Volumes     []string          `yaml:"volumes,omitempty"`
+	Healthcheck *composeHealthDoc `yaml:"healthcheck,omitempty"`
+}
+
+// quotedStrings marshals as double-quoted scalars, keeping "HOST:CONTAINER"
+// port bindings away from YAML 1.1 base-60 number parsing.
+type quotedStrings []string
+
+func (q quotedStrings) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.SequenceNode}
+	for _, s := range q {
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Style: yaml.DoubleQuotedStyle, Value: s})
+	}
+	return node, nil
+}
+
+type composeHealthDoc struct {
+	Test     []string `yaml:"test,flow"`
+	Interval string   `yaml:"interval"`
+	Timeout  string   `yaml:"timeout"`
+	Retries  int      `yaml:"retries"`
+}
+
+// validate reports every mistake in the described services.
+func (b *ComposeBuilder) validate() error {
+	errs := NewCompositeError("compose builder")
+	if len(b.services) == 0 {
+		errs.Add(kindErrorf(ErrValidation, "no services added"))
+	}
+	names := make(map[string]bool)
+	hostPorts := make(map[int]string)
+	for _, s := range b.services {
+		switch {
+		case !composeServiceName.MatchString(s.name):
+			errs.Add(kindErrorf(ErrValidation, "invalid service name %q", s.name))
+		case names[s.name]:
+			errs.Add(kindErrorf(ErrValidation, "duplicate service %q", s.name))
+		}
+		names[s.name] = true
+		if strings.TrimSpace(s.image) == "" {
+			errs.Add(kindErrorf(ErrValidation, "service %q: image cannot be empty", s.name))
+		}
+		for key := range s.env {
+			if key == "" || strings.ContainsAny(key, "= \t\n") {
+				errs.Add(kindErrorf(ErrValidation, "service %q: invalid environment variable name %q", s.name, key))
+			}
+		}
+
+		containerPorts := make(map[int]bool)
+		for _, p := range s.ports {
+			if p.container < 1 || p.container > 65535 {
+				errs.Add(kindErrorf(ErrValidation, "service %q: invalid container port %d", s.name, p.container))
+				continue
+			}
+			if containerPorts[p.container] {
+				errs.Add(kindErrorf(ErrValidation, "service %q: container port %d published twice", s.name, p.container))
+			}
+			containerPorts[p.container] = true
+			if p.host < 0 || p.host > 65535 {
+				errs.Add(kindErrorf(ErrValidation, "service %q: invalid host port %d for %d", s.name, p.host, p.container))
+				continue
+			}
+			if p.host == HostPortAuto {
+				continue
+			}
+			if owner, ok := hostPorts[p.host]; ok {
+				errs.Add(kindErrorf(ErrValidation, "service %q: host port %d already published by %s", s.name, p.host, owner))
+			}
+			hostPorts[p.host] = portKey(s.name, p.container)
+		}
+
+		for _, v := range s.volumes {
+			if v.tdmPath == "" {
+				errs.Add(kindErrorf(ErrValidation, "service %q: volume source cannot be empty", s.name))
+			}
+			if !path.IsAbs(v.containerPath) {
+				errs.Add(kindErrorf(ErrValidation, "service %q: volume target %q must be an absolute path", s.name, v.containerPath))
+			}
+		}
+		if len(s.health) > 0 && strings.TrimSpace(s.health[0]) == "" {
+			errs.Add(kindErrorf(ErrValidation, "service %q: empty health command", s.name))
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// Build validates the services, allocates the automatic host ports,
+// creates the volume directories and writes the compose file into tdm's
+// test directory as GeneratedComposeFile. Nothing is written if the
+// services are invalid.
+func (b *ComposeBuilder) Build(tdm *TestDataManager) (*ComposeBuild, error) {
+	if tdm == nil {
+		return nil, kindErrorf(ErrValidation, "test data manager cannot be nil")
+	}
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+
+	build := &ComposeBuild{Ports: make(map[string]int)}
+	doc := composeFileDoc{Services: make(map[string]composeServiceDoc, len(b.services))}
+	for _, s := range b.services {
+		svc, err := b.serviceDoc(tdm, s, build)
+		if err != nil {
+			return nil, err
+		}
+		doc.Services[s.name] = svc
+	}
+
+	content, err := marshalComposeDoc(doc)
+	if err != nil {
+		return nil, err
+	}
+	build.Path, err = tdm.CreateTestFile(GeneratedComposeFile, string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write generated compose file: %w", err)
+	}
+
+	build.Config = config.DockerConfig{
+		ComposePath:   tdm.GetTestDir(),
+		ComposeFile:   GeneratedComposeFile,
+		RemoveOrphans: true,
+		RemoveVolumes: true,
+	}
+	for _, s := range b.services {
+		for _, p := range sortedPortSpecs(s.ports) {
+			build.Config.Services = append(build.Config.Services, s.name+":"+strconv.Itoa(p.container))
+		}
+	}
+	return build, nil
+}
+
+// serviceDoc turns s into its YAML form, recording its host ports in build.
+func (b *ComposeBuilder) serviceDoc(tdm *TestDataManager, s *ComposeService, build *ComposeBuild) (composeServiceDoc, error) {
+	svc := composeServiceDoc{Image: s.image}
+	if len(s.env) > 0 {
+		svc.Environment = make(map[string]string, len(s.env))
+		for k, v := range s.env {
+			svc.Environment[k] = strings.ReplaceAll(v, "$", "$$")
+		}
+	}
+
+	for _, p := range sortedPortSpecs(s.ports) {
+		host := p.host
+		if host == HostPortAuto {
+			var err error
+			if host, err = b.freePort(); err != nil {
+				return svc, fmt.Errorf("no free host port for %s: %w", portKey(s.name, p.container), err)
+			}
+		}
+		build.Ports[portKey(s.name, p.container)] = host
+		svc.Ports = append(svc.Ports, fmt.Sprintf("%d:%d", host, p.container))
+	}
+
+	for _, v := range s.volumes {
+		dir, err := tdm.resolvePath(v.tdmPath)
+		if err != nil {
+			return svc, fmt.Errorf("service %q: %w", s.name, err)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return svc, fmt.Errorf("failed to create volume directory for %s: %w", s.name, err)
+		}
+		svc.Volumes = append(svc.Volumes, dir+":"+v.containerPath)
+	}
+
+	if len(s.health) > 0 {
+		test := append([]string{"CMD"}, s.health...)
+		if len(s.health) == 1 {
+			test = []string{"CMD-SHELL", s.health[0]}
+		}
+		svc.Healthcheck = &composeHealthDoc{Test: test, Interval: "1s", Timeout: "5s", Retries: 30}
+	}
+	return svc, nil
+}
+
+// sortedPortSpecs orders ports by container port.
+func sortedPortSpecs(ports []composePortSpec) []composePortSpec {
+	out := append([]composePortSpec(nil), ports...)
+	sort.Slice(out, func(i, j int) bool { return out[i].container < out[j].container })
+	return out
+}
+
+func marshalComposeDoc(doc composeFileDoc) ([]byte, error) {
+	var b strings.Builder
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode compose file: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode compose file: %w", err)
+	}
+	return []byte(b.String()), nil
+}
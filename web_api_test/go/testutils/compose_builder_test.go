@@ -0,0 +1,154 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newComposeBuilderTest(t *testing.T) (*ComposeBuilder, *TestDataManager) {
+	t.Helper()
+	tdm := newTestManager(t, "compose", nil, nil)
+	b := NewComposeBuilder()
+	next := 41000
+	b.freePort = func() (int, error) { next++; return next, nil }
+
+	b.AddService("redis", "redis:7").Port(6379, HostPortAuto)
+	b.AddService("postgres", "postgres:16").
+		Env("POSTGRES_PASSWORD", "pa$$word").
+		Env("POSTGRES_DB", "app").
+		Port(5432, HostPortAuto).
+		Port(9187, 19187).
+		HealthCmd("pg_isready", "-U", "postgres").
+		Volume("pgdata", "/var/lib/postgresql/data")
+	return b, tdm
+}
+
+func TestComposeBuilder_Golden(t *testing.T) {
+	b, tdm := newComposeBuilderTest(t)
+	build, err := b.Build(tdm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(build.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.ReplaceAll(string(data), tdm.GetTestDir(), "TESTDIR")
+	want, err := os.ReadFile("testdata/compose/builder.golden.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("generated file differs from testdata/compose/builder.golden.yml:\n%s", got)
+	}
+	if info, err := os.Stat(filepath.Join(tdm.GetTestDir(), "pgdata")); err != nil || !info.IsDir() {
+		t.Errorf("volume directory not created: %v", err)
+	}
+
+	if port, ok := build.Port("redis", 6379); !ok || port != 41001 {
+		t.Errorf("redis port %d, %v", port, ok)
+	}
+	if port, ok := build.Port("postgres", 5432); !ok || port != 41002 {
+		t.Errorf("postgres port %d, %v", port, ok)
+	}
+	cfg := build.Config
+	if cfg.ComposePath != tdm.GetTestDir() || cfg.ComposeFile != GeneratedComposeFile {
+		t.Errorf("config points at %s/%s", cfg.ComposePath, cfg.ComposeFile)
+	}
+	if want := []string{"redis:6379", "postgres:5432", "postgres:9187"}; !reflect.DeepEqual(cfg.Services, want) {
+		t.Errorf("services %v, want %v", cfg.Services, want)
+	}
+}
+
+func TestComposeBuilder_Validation(t *testing.T) {
+	tdm := newTestManager(t, "compose", nil, nil)
+	b := NewComposeBuilder()
+	b.AddService("db", "postgres:16").Port(5432, 15432).Port(5432, HostPortAuto)
+	b.AddService("db", "postgres:15")
+	b.AddService("cache", "").Port(70000, HostPortAuto).Port(6379, 15432)
+	b.AddService("-web", "nginx").Volume("../etc", "data")
+
+	_, err := b.Build(tdm)
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	for _, want := range []string{
+		`service "db": container port 5432 published twice`,
+		`duplicate service "db"`,
+		`service "cache": image cannot be empty`,
+		`service "cache": invalid container port 70000`,
+		`service "cache": host port 15432 already published by db/5432`,
+		`invalid service name "-web"`,
+		`service "-web": volume target "data" must be an absolute path`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+	if _, statErr := os.Stat(filepath.Join(tdm.GetTestDir(), GeneratedComposeFile)); !os.IsNotExist(statErr) {
+		t.Error("an invalid builder wrote its file")
+	}
+}
+
+func TestComposeBuilder_StartsViaDockerManager(t *testing.T) {
+	b, tdm := newComposeBuilderTest(t)
+	build, err := b.Build(tdm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No readiness checks: nothing listens on the published ports.
+	build.Config.Services = nil
+
+	cfg := &config.TestConfig{TestID: "Builder", TestDataDir: tdm.GetTestDir(), DockerConfig: build.Config}
+	runner := NewFakeRunner()
+	dm, err := NewDockerManager(cfg, test.NewTestLogger(t), WithDockerDataManager(tdm), build.DockerOption(), WithDockerRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dm.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := filepath.Join(tdm.GetTestDir(), RenderedComposeFile)
+	prefix := []string{"compose", "--project-name", "test-builder", "--project-directory", tdm.GetTestDir(), "-f", rendered}
+	want := [][]string{
+		append(append([]string(nil), prefix...), "up", "-d", "--remove-orphans"),
+		append(append([]string(nil), prefix...), "down", "--remove-orphans", "--volumes"),
+	}
+	if got := runner.Args(); !reflect.DeepEqual(got, want) {
+		t.Errorf("docker calls\n%q\nwant\n%q", got, want)
+	}
+	for _, spec := range runner.Calls() {
+		if spec.Name != "docker" || spec.Dir != tdm.GetTestDir() {
+			t.Errorf("ran %s in %s", spec.Name, spec.Dir)
+		}
+	}
+
+	generated, err := os.ReadFile(build.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(generated) {
+		t.Errorf("the rendered file differs from the generated one:\n%s", got)
+	}
+	if port, err := dm.ResolvedPort("postgres", 5432); err != nil || port != 41002 {
+		t.Errorf("resolved postgres port %d, %v", port, err)
+	}
+	if len(runner.Calls()) != 2 {
+		t.Errorf("ResolvedPort ran docker for a preset port")
+	}
+}
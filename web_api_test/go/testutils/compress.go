@@ -0,0 +1,195 @@
+// Package compress provides response compression middleware for the api
+// framework. Responses are compressed after the handler returns, so both the
+// RawBody and the JSON-encoded Body paths of writeResponse are covered.
+package testutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// brotliWriter is set by compress_brotli.go when built with the "brotli" tag.
+var brotliWriter func(w io.Writer, level int) io.WriteCloser
+
+// compressibleTypes lists the media types (and type prefixes) worth compressing.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/problem+json",
+	"application/x-ndjson",
+	"image/svg+xml",
+}
+
+// Compress returns a middleware that compresses responses with gzip (or
+// brotli, when built with the "brotli" tag and requested by the client).
+// A response is compressed only when the client sends a matching
+// Accept-Encoding, the encoded body is at least minSize bytes, the
+// Content-Type is compressible and no Content-Encoding is already set.
+// level follows compress/gzip semantics; out-of-range values fall back to
+// gzip.DefaultCompression.
+func Compress(level int, minSize int) Middleware {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			resp, err := next(ctx, req)
+			if err != nil || resp == nil {
+				return resp, err
+			}
+			if resp.Headers == nil {
+				resp.Headers = http.Header{}
+			}
+			addVary(resp.Headers, "Accept-Encoding")
+
+			encoding := negotiateEncoding(req.Request.Header.Get("Accept-Encoding"))
+			if encoding == "" || resp.Headers.Get("Content-Encoding") != "" {
+				return resp, nil
+			}
+			if resp.Status == http.StatusNoContent || resp.Status == http.StatusNotModified {
+				return resp, nil
+			}
+
+			body, err := responseBytes(resp)
+			if err != nil {
+				return nil, err
+			}
+			if body == nil || len(body) < minSize {
+				return resp, nil
+			}
+			if !isCompressible(resp.Headers.Get("Content-Type")) {
+				return resp, nil
+			}
+
+			compressed, err := compressBytes(body, encoding, level)
+			if err != nil {
+				return nil, err
+			}
+			resp.RawBody = compressed
+			resp.Body = nil
+			resp.Headers.Set("Content-Encoding", encoding)
+			resp.Headers.Set("Content-Length", strconv.Itoa(len(compressed)))
+			return resp, nil
+		}
+	}
+}
+
+// responseBytes returns the bytes writeResponse would send for resp. A JSON
+// Body is encoded here and the default Content-Type applied, mirroring
+// writeResponse, so the compressed payload is identical once decoded.
+func responseBytes(resp *Response) ([]byte, error) {
+	if resp.RawBody != nil {
+		return resp.RawBody, nil
+	}
+	if resp.Body == nil {
+		return nil, nil
+	}
+	if resp.Headers.Get("Content-Type") == "" {
+		resp.Headers.Set("Content-Type", "application/json; charset=utf-8")
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(resp.Body); err != nil {
+		return nil, &Error{
+			Code:    http.StatusInternalServerError,
+			Message: "failed to encode response",
+			Cause:   err,
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// negotiateEncoding picks the preferred supported encoding from an
+// Accept-Encoding header, honouring q=0 exclusions.
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+		accepted[name] = q > 0
+	}
+	if brotliWriter != nil && accepted["br"] {
+		return "br"
+	}
+	if ok, listed := accepted["gzip"]; ok || (!listed && accepted["*"]) {
+		return "gzip"
+	}
+	return ""
+}
+
+// isCompressible reports whether the Content-Type benefits from compression.
+func isCompressible(contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	for _, t := range compressibleTypes {
+		if strings.HasSuffix(t, "/") {
+			if strings.HasPrefix(mediaType, t) {
+				return true
+			}
+			continue
+		}
+		if mediaType == t {
+			return true
+		}
+	}
+	return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// compressBytes encodes data with the named encoding.
+func compressBytes(data []byte, encoding string, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "br":
+		w = brotliWriter(&buf, level)
+	default:
+		gz, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		w = gz
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addVary appends a token to the Vary header if it is not already present.
+func addVary(h http.Header, token string) {
+	for _, v := range h.Values("Vary") {
+		for _, existing := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), token) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", token)
+}
@@ -0,0 +1,25 @@
+//go:build brotli
+// +build brotli
+
+package testutils
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+func init() {
+	brotliWriter = newBrotliWriter
+}
+
+// newBrotliWriter maps gzip-style levels onto the brotli 0-11 range.
+func newBrotliWriter(w io.Writer, level int) io.WriteCloser {
+	switch {
+	case level < 0:
+		level = brotli.DefaultCompression
+	case level > brotli.BestCompression:
+		level = brotli.BestCompression
+	}
+	return brotli.NewWriterLevel(w, level)
+}
@@ -0,0 +1,169 @@
+package testutils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// compressApp serves a JSON body, a raw text body, an image and a body that
+// cannot be encoded, all behind Compress(level, 64).
+func compressApp(level int) *App {
+	app := NewApp()
+	app.Use(Compress(level, 64))
+	app.Get("/json", func(ctx context.Context, req *Request) (*Response, error) {
+		return JSON(http.StatusOK, map[string]string{"message": strings.Repeat("hello ", 50)})
+	})
+	app.Get("/text", func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{
+			Status:  http.StatusOK,
+			Headers: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			RawBody: []byte(strings.Repeat("lorem ipsum ", 40)),
+		}, nil
+	})
+	app.Get("/small", func(ctx context.Context, req *Request) (*Response, error) {
+		return JSON(http.StatusOK, map[string]string{"ok": "yes"})
+	})
+	app.Get("/png", func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{
+			Status:  http.StatusOK,
+			Headers: http.Header{"Content-Type": {"image/png"}},
+			RawBody: bytes.Repeat([]byte{0x89}, 200),
+		}, nil
+	})
+	app.Get("/encoded", func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{
+			Status:  http.StatusOK,
+			Headers: http.Header{"Content-Type": {"text/plain"}, "Content-Encoding": {"identity"}},
+			RawBody: []byte(strings.Repeat("x", 200)),
+		}, nil
+	})
+	app.Get("/bad", func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{Status: http.StatusOK, Body: map[string]any{"ch": make(chan int)}}, nil
+	})
+	return app
+}
+
+func getCompressed(app *App, path, acceptEncoding string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	return rec
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	return out
+}
+
+func TestCompress_RoundTrip(t *testing.T) {
+	app := compressApp(gzip.BestSpeed)
+
+	rec := getCompressed(app, "/json", "br;q=0, gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("headers %v", rec.Header())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(gunzip(t, rec.Body.Bytes()), &body); err != nil || body["message"] != strings.Repeat("hello ", 50) {
+		t.Errorf("decoded %v, %v", body, err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Errorf("content type %q", ct)
+	}
+
+	rec = getCompressed(app, "/text", "*")
+	if got := string(gunzip(t, rec.Body.Bytes())); got != strings.Repeat("lorem ipsum ", 40) {
+		t.Errorf("raw body round trip: %q", got)
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("content length %q for %d bytes", cl, rec.Body.Len())
+	}
+}
+
+func TestCompress_Skips(t *testing.T) {
+	// An out-of-range level falls back to the default instead of failing.
+	app := compressApp(42)
+	for _, tc := range []struct {
+		name, path, accept string
+	}{
+		{"no accept-encoding", "/json", ""},
+		{"gzip refused", "/json", "gzip;q=0, *"},
+		{"unsupported encoding", "/json", "deflate"},
+		{"below min size", "/small", "gzip"},
+		{"incompressible type", "/png", "gzip"},
+		{"already encoded", "/encoded", "gzip"},
+	} {
+		rec := getCompressed(app, tc.path, tc.accept)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") == "gzip" {
+			t.Errorf("%s: %d, encoding %q", tc.name, rec.Code, rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary %q", tc.name, rec.Header().Get("Vary"))
+		}
+	}
+	if rec := getCompressed(app, "/json", "gzip"); rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("level fallback did not compress: %v", rec.Header())
+	}
+}
+
+func TestCompress_EncodeError(t *testing.T) {
+	rec := getCompressed(compressApp(gzip.DefaultCompression), "/bad", "gzip")
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("unencodable body: %d %v %s", rec.Code, rec.Header(), rec.Body)
+	}
+	if _, err := compressBytes([]byte("x"), "gzip", 99); err == nil {
+		t.Error("compressBytes accepted an invalid gzip level")
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                  "",
+		"gzip":              "gzip",
+		"GZIP;q=0.5":        "gzip",
+		"gzip;q=0":          "",
+		"*":                 "gzip",
+		"*, gzip;q=0":       "",
+		"br":                "",
+		"identity, deflate": "",
+	} {
+		if got := negotiateEncoding(header); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestIsCompressible(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"":                         false,
+		"text/html; charset=utf-8": true,
+		"application/json":         true,
+		"application/vnd.api+json": true,
+		"application/atom+xml":     true,
+		"image/svg+xml":            true,
+		"image/png":                false,
+		"application/octet-stream": false,
+	} {
+		if got := isCompressible(ct); got != want {
+			t.Errorf("isCompressible(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// RunConcurrently – bounded concurrent load with latency stats and an
+// error budget
+// ------------------------------------------------------------------------
+
+// ConcurrencyOption configures RunConcurrently.
+type ConcurrencyOption func(*concurrencyConfig)
+
+type concurrencyConfig struct {
+	errorBudget float64
+	rampUp      time.Duration
+	maxDuration time.Duration
+	warmup      int
+	logger      *TestLogger
+	clock       Clock
+	bench       *BenchmarkRecorder
+	benchName   string
+}
+
+// WithErrorBudget sets the fraction of measured calls allowed to fail
+// before the test is failed, e.g. 0.01 for 1%. The default is 0: any error
+// fails the test.
+func WithErrorBudget(rate float64) ConcurrencyOption {
+	return func(c *concurrencyConfig) { c.errorBudget = rate }
+}
+
+// WithRampUp staggers worker start times evenly across d.
+func WithRampUp(d time.Duration) ConcurrencyOption {
+	return func(c *concurrencyConfig) { c.rampUp = d }
+}
+
+// WithMaxDuration stops issuing new calls once d has elapsed. Calls already
+// running are allowed to finish.
+func WithMaxDuration(d time.Duration) ConcurrencyOption {
+	return func(c *concurrencyConfig) { c.maxDuration = d }
+}
+
+// WithWarmup excludes each worker's first n iterations from the latency
+// stats, the error budget and the throughput figure.
+func WithWarmup(n int) ConcurrencyOption {
+	return func(c *concurrencyConfig) { c.warmup = n }
+}
+
+// WithReportLogger logs the report through l instead of the test log.
+func WithReportLogger(l *TestLogger) ConcurrencyOption {
+	return func(c *concurrencyConfig) { c.logger = l }
+}
+
+// WithBenchmarkRecorder also records every measured call's latency in rec
+// under name, so RunConcurrently results can be saved and compared against
+// a baseline.
+func WithBenchmarkRecorder(rec *BenchmarkRecorder, name string) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		c.bench = rec
+		c.benchName = name
+	}
+}
+
+// WithConcurrencyClock sets the clock used for latencies and ramp-up.
+func WithConcurrencyClock(clock Clock) ConcurrencyOption {
+	return func(c *concurrencyConfig) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// ConcurrencyReport summarises a RunConcurrently run. Figures exclude the
+// warmup iterations.
+type ConcurrencyReport struct {
+	Workers    int           `json:"workers"`
+	Iterations int           `json:"iterations"`
+	Calls      int           `json:"calls"`
+	Errors     int           `json:"errors"`
+	ErrorRate  float64       `json:"error_rate"`
+	Budget     float64       `json:"error_budget"`
+	Duration   time.Duration `json:"duration_ns"`
+	Throughput float64       `json:"throughput_per_sec"`
+	Latency    DurationStats `json:"latency"`
+	// ErrorBreakdown counts measured failures by error message.
+	ErrorBreakdown map[string]int `json:"error_breakdown,omitempty"`
+	// TimedOut is set when WithMaxDuration cut the run short.
+	TimedOut bool `json:"timed_out,omitempty"`
+
+	// Err holds every measured failure with its worker and iteration; nil
+	// when there were none.
+	Err *CompositeError `json:"-"`
+}
+
+// OverBudget reports whether the error rate exceeded the budget.
+func (r *ConcurrencyReport) OverBudget() bool {
+	return r.Errors > 0 && r.ErrorRate > r.Budget
+}
+
+// String renders a one-line summary.
+func (r *ConcurrencyReport) String() string {
+	return fmt.Sprintf("%d calls by %d workers in %s (%.1f/s), errors %d (%.2f%%, budget %.2f%%), latency %s",
+		r.Calls, r.Workers, HumanDuration(r.Duration), r.Throughput,
+		r.Errors, r.ErrorRate*100, r.Budget*100, r.Latency)
+}
+
+// JSON encodes the report with indentation.
+func (r *ConcurrencyReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// RunConcurrently runs fn iterations times on each of workers workers,
+// bounded by a WorkerPool, and returns the aggregated report. The test is
+// failed when the error rate exceeds the budget set with WithErrorBudget.
+//
+//	report := RunConcurrently(t, 20, 50, func(worker, iter int) error {
+//		resp, err := client.Get(ctx, "/users")
+//		if err != nil {
+//			return err
+//		}
+//		defer resp.Body.Close()
+//		if resp.StatusCode != http.StatusOK {
+//			return fmt.Errorf("status %d", resp.StatusCode)
+//		}
+//		return nil
+//	}, WithErrorBudget(0.01), WithWarmup(2))
+func RunConcurrently(t testing.TB, workers, iterations int, fn func(workerID, iter int) error, opts ...ConcurrencyOption) *ConcurrencyReport {
+	t.Helper()
+	cfg := concurrencyConfig{clock: RealClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if cfg.logger == nil {
+		cfg.logger = NewTestLogger(t.Name(), tbWriter{t})
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc = func() {}
+	if cfg.maxDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.maxDuration)
+	}
+	defer cancel()
+
+	var (
+		mu        sync.Mutex
+		latencies = NewDurationCollection()
+		errs      = NewCompositeError("concurrent run")
+		breakdown = make(map[string]int)
+		calls     int
+	)
+	record := func(worker, iter int, d time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		latencies.Add(d)
+		if cfg.bench != nil {
+			cfg.bench.Add(cfg.benchName, d, err)
+		}
+		if err != nil {
+			errs.Add(err, WithContext("worker", worker), WithContext("iteration", iter))
+			breakdown[err.Error()]++
+		}
+	}
+
+	pool := NewWorkerPool(workers, workers)
+	pool.Start()
+	var wg sync.WaitGroup
+	start := cfg.clock.Now()
+	for w := 0; w < workers; w++ {
+		w := w
+		delay := time.Duration(0)
+		if cfg.rampUp > 0 && workers > 1 {
+			delay = cfg.rampUp * time.Duration(w) / time.Duration(workers)
+		}
+		wg.Add(1)
+		pool.SubmitCtx(context.Background(), func() error {
+			defer wg.Done()
+			if delay > 0 {
+				select {
+				case <-cfg.clock.After(delay):
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			for i := 0; i < iterations && ctx.Err() == nil; i++ {
+				callStart := cfg.clock.Now()
+				err := fn(w, i)
+				if i >= cfg.warmup {
+					record(w, i, cfg.clock.Now().Sub(callStart), err)
+				}
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+	pool.Stop()
+
+	report := &ConcurrencyReport{
+		Workers:        workers,
+		Iterations:     iterations,
+		Calls:          calls,
+		Errors:         len(errs.Errors),
+		Budget:         cfg.errorBudget,
+		Duration:       cfg.clock.Now().Sub(start),
+		Latency:        NewDurationStats(latencies),
+		ErrorBreakdown: breakdown,
+		TimedOut:       ctx.Err() == context.DeadlineExceeded,
+	}
+	if calls > 0 {
+		report.ErrorRate = float64(report.Errors) / float64(calls)
+	}
+	if report.Duration > 0 {
+		report.Throughput = float64(calls) / report.Duration.Seconds()
+	}
+	if report.Errors > 0 {
+		report.Err = errs
+	}
+
+	cfg.logger.Info("concurrent run finished", map[string]any{
+		"workers":    report.Workers,
+		"calls":      report.Calls,
+		"throughput": fmt.Sprintf("%.1f/s", report.Throughput),
+		"p50":        HumanDuration(report.Latency.Median),
+		"p95":        HumanDuration(report.Latency.P95),
+		"p99":        HumanDuration(report.Latency.P99),
+		"errors":     report.Errors,
+		"error_rate": report.ErrorRate,
+		"timed_out":  report.TimedOut,
+	})
+	for _, line := range report.topErrors(5) {
+		cfg.logger.Warn("error breakdown", map[string]any{"error": line.msg, "count": line.count})
+	}
+
+	if report.OverBudget() {
+		t.Errorf("error rate %.2f%% exceeds budget %.2f%%: %s",
+			report.ErrorRate*100, report.Budget*100, report)
+	}
+	return report
+}
+
+type errorCount struct {
+	msg   string
+	count int
+}
+
+// topErrors returns the n most frequent error messages.
+func (r *ConcurrencyReport) topErrors(n int) []errorCount {
+	out := make([]errorCount, 0, len(r.ErrorBreakdown))
+	for msg, count := range r.ErrorBreakdown {
+		out = append(out, errorCount{msg, count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].msg < out[j].msg
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// tbWriter routes a TestLogger's output into the test log.
+type tbWriter struct {
+	t testing.TB
+}
+
+func (w tbWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,95 @@
+package testutils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// budgetTB captures Errorf so budget failures can be asserted on.
+type budgetTB struct {
+	testing.TB
+	mu     sync.Mutex
+	failed []string
+}
+
+func (b *budgetTB) Errorf(format string, args ...any) {
+	b.mu.Lock()
+	b.failed = append(b.failed, fmt.Sprintf(format, args...))
+	b.mu.Unlock()
+}
+
+func TestRunConcurrently_CountsAndStats(t *testing.T) {
+	var logs bytes.Buffer
+	var active, peak atomic.Int32
+	report := RunConcurrently(t, 4, 10, func(worker, iter int) error {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		active.Add(-1)
+		return nil
+	}, WithWarmup(2), WithReportLogger(NewTestLogger("load", &logs)))
+
+	if report.Calls != 4*8 {
+		t.Errorf("calls %d, want 32 (warmup excluded)", report.Calls)
+	}
+	if report.Latency.Count != report.Calls || report.Latency.Min < time.Millisecond {
+		t.Errorf("latency %s", report.Latency)
+	}
+	if peak.Load() > 4 {
+		t.Errorf("peak concurrency %d exceeds 4 workers", peak.Load())
+	}
+	if report.Errors != 0 || report.Err != nil || report.Throughput <= 0 {
+		t.Errorf("unexpected report %s", report)
+	}
+	if !strings.Contains(logs.String(), "concurrent run finished") {
+		t.Errorf("report not logged: %s", logs.String())
+	}
+}
+
+func TestRunConcurrently_ErrorBudget(t *testing.T) {
+	fail := func(worker, iter int) error {
+		if iter == 0 {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	quiet := WithReportLogger(NewTestLogger("load", &bytes.Buffer{}))
+
+	// 2 of 20 calls fail: 10%.
+	within := &budgetTB{TB: t}
+	report := RunConcurrently(within, 2, 10, fail, WithErrorBudget(0.10), quiet)
+	if len(within.failed) != 0 {
+		t.Errorf("failed within budget: %v", within.failed)
+	}
+	if report.Errors != 2 || report.ErrorBreakdown["boom"] != 2 || len(report.Err.Errors) != 2 {
+		t.Errorf("errors %d, breakdown %v", report.Errors, report.ErrorBreakdown)
+	}
+
+	over := &budgetTB{TB: t}
+	RunConcurrently(over, 2, 10, fail, WithErrorBudget(0.05), quiet)
+	if len(over.failed) != 1 {
+		t.Errorf("expected one budget failure, got %v", over.failed)
+	}
+}
+
+func TestRunConcurrently_MaxDuration(t *testing.T) {
+	report := RunConcurrently(t, 2, 1000, func(worker, iter int) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}, WithMaxDuration(30*time.Millisecond), WithReportLogger(NewTestLogger("load", &bytes.Buffer{})))
+
+	if !report.TimedOut || report.Calls >= 2000 {
+		t.Errorf("run was not cut short: %s", report)
+	}
+}
@@ -0,0 +1,273 @@
+package testutils
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// CallConditioner – delay and error injection keyed by method name
+// --------------------------------------------------------------------
+
+// CallConditioner is the engine behind the typed conditioners
+// (ComponentConditioner, TracerConditioner). It holds every setting per
+// method name, so wrapping a new interface only takes one line per method:
+//
+//	func (c *StoreConditioner) Get(key string) ([]byte, error) {
+//		return ConditionCall(c.cond, "Get", func() ([]byte, error) { return c.store.Get(key) })
+//	}
+//
+// Fixed delays, latency jitter, exact-call and probabilistic errors, mode
+// hookup, call counts, duration histograms and a call history then come
+// with it. Go cannot synthesise an interface implementation at run time,
+// so the wrapper methods are generated instead: conditioner_gen.go writes
+// them from the interface declaration (see its go:generate line on
+// ComponentConditioner).
+type CallConditioner struct {
+	mu        sync.Mutex
+	sentinel  error // wrapped by injected failures
+	clock     Clock
+	delays    map[string]time.Duration
+	errs      map[string]map[int]error
+	rates     map[string]float64
+	mutating  map[string]bool
+	calls     map[string]int
+	jitterMin time.Duration
+	jitterMax time.Duration
+	flakyRate float64
+	rng       *rand.Rand
+	modeMgr   ModeManager
+	durations map[string]*IntCollection
+	history   []ConditionedCall
+}
+
+// ConditionedCall records one call through a CallConditioner.
+type ConditionedCall struct {
+	Method   string
+	Call     int // 1-based count for Method
+	Start    time.Time
+	Duration time.Duration
+	Err      error
+}
+
+// NewCallConditioner returns a conditioner whose injected failures wrap
+// sentinel, so callers can tell them apart with errors.Is.
+func NewCallConditioner(sentinel error) *CallConditioner {
+	return &CallConditioner{
+		sentinel:  sentinel,
+		clock:     RealClock{},
+		delays:    make(map[string]time.Duration),
+		errs:      make(map[string]map[int]error),
+		rates:     make(map[string]float64),
+		mutating:  make(map[string]bool),
+		calls:     make(map[string]int),
+		flakyRate: 0.5,
+		rng:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		durations: make(map[string]*IntCollection),
+	}
+}
+
+// SetDelay adds a fixed delay before every call to method.
+func (c *CallConditioner) SetDelay(method string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delays[method] = d
+}
+
+// InjectError makes the nth call to method return err, without delay.
+func (c *CallConditioner) InjectError(method string, callNumber int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.errs[method] == nil {
+		c.errs[method] = make(map[int]error)
+	}
+	c.errs[method][callNumber] = err
+}
+
+// SetErrorRate makes calls to method fail with probability rate (0..1).
+// Use SetSeed for reproducible runs.
+func (c *CallConditioner) SetErrorRate(method string, rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rates[method] = rate
+}
+
+// SetMutating marks methods as writes, which read-only mode rejects.
+func (c *CallConditioner) SetMutating(methods ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, m := range methods {
+		c.mutating[m] = true
+	}
+}
+
+// SetLatencyDistribution adds a uniformly distributed delay in [min, max] to
+// every call, on top of the fixed per-method delays.
+func (c *CallConditioner) SetLatencyDistribution(min, max time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if max < min {
+		min, max = max, min
+	}
+	c.jitterMin = min
+	c.jitterMax = max
+}
+
+// SetSeed reseeds the random source used for jitter and error rates.
+func (c *CallConditioner) SetSeed(seed int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetClock replaces the clock used for delays and durations.
+func (c *CallConditioner) SetClock(clock Clock) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clock = clock
+}
+
+// SetMode ties the conditioner to a ModeManager: degraded adds a delay,
+// flaky fails at the flaky rate, read-only rejects mutating methods, and
+// offline or maintenance rejects every call. Pass nil to detach.
+func (c *CallConditioner) SetMode(mgr ModeManager) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modeMgr = mgr
+}
+
+// SetFlakyRate sets the failure probability used in flaky mode (default 0.5).
+func (c *CallConditioner) SetFlakyRate(rate float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flakyRate = rate
+}
+
+// Calls returns how many times method was called.
+func (c *CallConditioner) Calls(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[method]
+}
+
+// Durations returns a snapshot of the recorded call durations for method, in
+// nanoseconds, e.g. p95, _ := c.Durations("Health").Percentile(95).
+func (c *CallConditioner) Durations(method string) *IntCollection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := c.durations[method]; ok {
+		return NewIntCollection(d.Values()...)
+	}
+	return NewIntCollection()
+}
+
+// History returns every call made so far, in the order they finished.
+func (c *CallConditioner) History() []ConditionedCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ConditionedCall(nil), c.history...)
+}
+
+// Enter counts a call to method and applies, in order: an exact-call
+// injected error (returned immediately), the mode check, the fixed delay
+// plus jitter, and finally the probabilistic error rate. The caller makes
+// the real call only when err is nil, and calls done with the outcome
+// either way so the call is recorded.
+func (c *CallConditioner) Enter(method string) (done func(error), err error) {
+	c.mu.Lock()
+	c.calls[method]++
+	call := c.calls[method]
+	clock := c.clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	start := clock.Now()
+	done = func(err error) { c.record(method, call, start, err) }
+	if err, ok := c.errs[method][call]; ok {
+		delete(c.errs[method], call)
+		c.mu.Unlock()
+		return done, err
+	}
+	wait := c.delays[method]
+	if c.jitterMax > 0 {
+		wait += c.jitterMin + time.Duration(c.rng.Int63n(int64(c.jitterMax-c.jitterMin)+1))
+	}
+	rate := c.rates[method]
+	fail := rate > 0 && c.rng.Float64() < rate
+	mgr := c.modeMgr
+	flaky := c.flakyRate > 0 && c.rng.Float64() < c.flakyRate
+	mutating := c.mutating[method]
+	c.mu.Unlock()
+
+	if mgr != nil {
+		switch mode := mgr.CurrentMode(); mode {
+		case ModeDegraded:
+			wait += degradedDelay
+		case ModeReadOnly:
+			if mutating {
+				return done, fmt.Errorf("%w: %s denied in read-only mode", c.sentinel, method)
+			}
+		case ModeOffline, ModeMaintenance:
+			return done, fmt.Errorf("%w: %s unavailable (%s)", c.sentinel, method, mode)
+		case ModeFlaky:
+			if flaky {
+				return done, fmt.Errorf("%w: %s flaky error", c.sentinel, method)
+			}
+		}
+	}
+
+	sleepWith(clock, wait)
+	if fail {
+		return done, fmt.Errorf("%w: %s (error rate %.2f)", c.sentinel, method, rate)
+	}
+	return done, nil
+}
+
+// record stores the outcome of a call that started at start.
+func (c *CallConditioner) record(method string, call int, start time.Time, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clock := c.clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	elapsed := clock.Now().Sub(start)
+	d, ok := c.durations[method]
+	if !ok {
+		d = NewIntCollection()
+		c.durations[method] = d
+	}
+	d.Add(int(elapsed))
+	c.history = append(c.history, ConditionedCall{Method: method, Call: call, Start: start, Duration: elapsed, Err: err})
+}
+
+// ConditionCall runs call through c as method: an injected error is returned
+// with T's zero value instead of calling it.
+func ConditionCall[T any](c *CallConditioner, method string, call func() (T, error)) (T, error) {
+	done, err := c.Enter(method)
+	if err != nil {
+		done(err)
+		var zero T
+		return zero, err
+	}
+	v, err := call()
+	done(err)
+	return v, err
+}
+
+// ConditionCallErr is ConditionCall for methods that only return an error.
+func ConditionCallErr(c *CallConditioner, method string, call func() error) error {
+	_, err := ConditionCall(c, method, func() (struct{}, error) { return struct{}{}, call() })
+	return err
+}
+
+// ConditionCallVoid is ConditionCall for methods that cannot report an
+// error: the delay applies and the call is recorded, but an injected
+// failure only shows up in History and the call still goes through.
+func ConditionCallVoid(c *CallConditioner, method string, call func()) {
+	done, err := c.Enter(method)
+	call()
+	done(err)
+}
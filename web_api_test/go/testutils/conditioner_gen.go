@@ -0,0 +1,303 @@
+//go:build ignore
+// +build ignore
+
+// conditioner_gen.go writes the interface methods of a typed conditioner:
+// each one runs the wrapped implementation through the conditioner's
+// CallConditioner (see conditioner.go). The struct, its constructor and
+// any setters stay hand-written next to it. Run it with go:generate:
+//
+//	//go:generate go run conditioner_gen.go -iface Component -type ComponentConditioner -field component -passthrough Name
+//
+// The conditioner struct must hold the wrapped value in -field and the
+// CallConditioner in cond. Methods are conditioned by their results:
+//
+//	error                ConditionCallErr: an injected error is returned
+//	(T, error)           ConditionCall: an injected error comes with T's zero value
+//	(T1, ..., Tn, error) the same through CallConditioner.Enter
+//	anything else        ConditionCallVoid: the call always goes through
+//
+// Methods named in -passthrough delegate without conditioning.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	iface := flag.String("iface", "", "interface to wrap")
+	typ := flag.String("type", "", "conditioner type to generate methods for")
+	field := flag.String("field", "", "field of -type holding the wrapped implementation")
+	passthrough := flag.String("passthrough", "", "comma-separated methods that delegate without conditioning")
+	output := flag.String("output", "", "output file (default <type>_gen.go in snake case)")
+	flag.Parse()
+	if *iface == "" || *typ == "" || *field == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	log.SetFlags(0)
+	log.SetPrefix("conditioner_gen: ")
+
+	fset := token.NewFileSet()
+	file, spec, err := findInterface(fset, *iface)
+	if err != nil {
+		log.Fatal(err)
+	}
+	skip := make(map[string]bool)
+	for _, name := range strings.Split(*passthrough, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			skip[name] = true
+		}
+	}
+
+	g := &generator{fset: fset, file: file, recv: *typ, field: *field, imports: make(map[string]string)}
+	for _, m := range spec.Methods.List {
+		fn, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			log.Fatalf("%s embeds %s; list its methods instead", *iface, g.expr(m.Type))
+		}
+		for _, name := range m.Names {
+			g.method(name.Name, fn, skip[name.Name])
+		}
+	}
+
+	src, err := g.source(*iface)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output == "" {
+		*output = snakeCase(*typ) + "_gen.go"
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// findInterface looks for the interface declaration among the package's
+// non-test files. Files that do not parse are skipped.
+func findInterface(fset *token.FileSet, name string) (*ast.File, *ast.InterfaceType, error) {
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") || strings.HasSuffix(path, "_gen.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil || file.Name.Name == "main" {
+			continue
+		}
+		for _, decl := range file.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, s := range gd.Specs {
+				ts := s.(*ast.TypeSpec)
+				if it, ok := ts.Type.(*ast.InterfaceType); ok && ts.Name.Name == name {
+					return file, it, nil
+				}
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("interface %s not found", name)
+}
+
+type generator struct {
+	fset    *token.FileSet
+	file    *ast.File
+	recv    string
+	field   string
+	imports map[string]string // package name -> import path, for the output
+	body    bytes.Buffer
+}
+
+// expr prints e as it appears in the source and records the imports it
+// refers to.
+func (g *generator) expr(e ast.Expr) string {
+	ast.Inspect(e, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				g.addImport(id.Name)
+			}
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	printer.Fprint(&buf, g.fset, e)
+	return buf.String()
+}
+
+func (g *generator) addImport(name string) {
+	for _, imp := range g.file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if (imp.Name != nil && imp.Name.Name == name) || (imp.Name == nil && filepath.Base(path) == name) {
+			g.imports[name] = path
+			return
+		}
+	}
+}
+
+func (g *generator) method(name string, fn *ast.FuncType, passthrough bool) {
+	var params, args []string
+	variadic := false
+	if fn.Params != nil {
+		for i, p := range fn.Params.List {
+			typ := g.expr(p.Type)
+			if _, ok := p.Type.(*ast.Ellipsis); ok {
+				variadic = true
+			}
+			names := p.Names
+			if len(names) == 0 {
+				names = []*ast.Ident{ast.NewIdent("p" + strconv.Itoa(i))}
+			}
+			for _, n := range names {
+				params = append(params, n.Name+" "+typ)
+				args = append(args, n.Name)
+			}
+		}
+	}
+	var results []string
+	if fn.Results != nil {
+		for _, r := range fn.Results.List {
+			typ := g.expr(r.Type)
+			for n := 0; n < max(1, len(r.Names)); n++ {
+				results = append(results, typ)
+			}
+		}
+	}
+
+	call := "c." + g.field + "." + name
+	callArgs := strings.Join(args, ", ")
+	if variadic {
+		callArgs += "..."
+	}
+	sig := fmt.Sprintf("func (c *%s) %s(%s)", g.recv, name, strings.Join(params, ", "))
+	switch len(results) {
+	case 0:
+	case 1:
+		sig += " " + results[0]
+	default:
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+	returnsErr := len(results) > 0 && results[len(results)-1] == "error"
+	// A method value reads like the hand-written wrappers when no
+	// arguments need capturing.
+	thunk := func(sig string) string {
+		if len(args) == 0 && (returnsErr || len(results) == 0) {
+			return call
+		}
+		return fmt.Sprintf("func() %s { return %s(%s) }", sig, call, callArgs)
+	}
+
+	w := &g.body
+	switch {
+	case passthrough:
+		fmt.Fprintf(w, "// %s delegates.\n%s {\n", name, sig)
+		if len(results) > 0 {
+			fmt.Fprintf(w, "return ")
+		}
+		fmt.Fprintf(w, "%s(%s)\n}\n\n", call, callArgs)
+	case returnsErr && len(results) == 1:
+		fmt.Fprintf(w, "// %s adds delay then delegates.\n%s {\n", name, sig)
+		fmt.Fprintf(w, "return ConditionCallErr(c.cond, %q, %s)\n}\n\n", name, thunk("error"))
+	case returnsErr && len(results) == 2:
+		fmt.Fprintf(w, "// %s adds delay then delegates.\n%s {\n", name, sig)
+		fmt.Fprintf(w, "return ConditionCall(c.cond, %q, %s)\n}\n\n", name, thunk("("+results[0]+", error)"))
+	case returnsErr:
+		vars := resultVars(len(results), true)
+		fmt.Fprintf(w, "// %s adds delay then delegates.\n%s {\n", name, sig)
+		declareVars(w, vars, results)
+		fmt.Fprintf(w, "done, %s := c.cond.Enter(%q)\n", vars[len(vars)-1], name)
+		fmt.Fprintf(w, "if %s == nil {\n%s = %s(%s)\n}\n", vars[len(vars)-1], strings.Join(vars, ", "), call, callArgs)
+		fmt.Fprintf(w, "done(%s)\n", vars[len(vars)-1])
+		fmt.Fprintf(w, "return %s\n}\n\n", strings.Join(vars, ", "))
+	case len(results) == 0:
+		fmt.Fprintf(w, "// %s adds delay then delegates.\n%s {\n", name, sig)
+		if len(args) == 0 {
+			fmt.Fprintf(w, "ConditionCallVoid(c.cond, %q, %s)\n}\n\n", name, call)
+		} else {
+			fmt.Fprintf(w, "ConditionCallVoid(c.cond, %q, func() { %s(%s) })\n}\n\n", name, call, callArgs)
+		}
+	default:
+		vars := resultVars(len(results), false)
+		fmt.Fprintf(w, "// %s adds delay then delegates.\n%s {\n", name, sig)
+		declareVars(w, vars, results)
+		fmt.Fprintf(w, "ConditionCallVoid(c.cond, %q, func() { %s = %s(%s) })\n", name, strings.Join(vars, ", "), call, callArgs)
+		fmt.Fprintf(w, "return %s\n}\n\n", strings.Join(vars, ", "))
+	}
+}
+
+// declareVars writes the declaration of the result variables.
+func declareVars(w *bytes.Buffer, vars, types []string) {
+	if len(vars) == 1 {
+		fmt.Fprintf(w, "var %s %s\n", vars[0], types[0])
+		return
+	}
+	fmt.Fprintf(w, "var (\n")
+	for i, typ := range types {
+		fmt.Fprintf(w, "%s %s\n", vars[i], typ)
+	}
+	fmt.Fprintf(w, ")\n")
+}
+
+// resultVars names n results r0, r1, ..., with err last if withErr.
+func resultVars(n int, withErr bool) []string {
+	vars := make([]string, n)
+	for i := range vars {
+		vars[i] = "r" + strconv.Itoa(i)
+	}
+	if withErr {
+		vars[n-1] = "err"
+	}
+	return vars
+}
+
+func (g *generator) source(iface string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by conditioner_gen.go; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", g.file.Name.Name)
+	if len(g.imports) > 0 {
+		var paths []string
+		for name, p := range g.imports {
+			if filepath.Base(p) != name {
+				paths = append(paths, name+" "+strconv.Quote(p))
+				continue
+			}
+			paths = append(paths, strconv.Quote(p))
+		}
+		sort.Strings(paths)
+		if len(paths) == 1 {
+			fmt.Fprintf(&out, "import %s\n\n", paths[0])
+		} else {
+			fmt.Fprintf(&out, "import (\n%s\n)\n\n", strings.Join(paths, "\n"))
+		}
+	}
+	fmt.Fprintf(&out, "var _ %s = (*%s)(nil)\n\n", iface, g.recv)
+	out.Write(g.body.Bytes())
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+var wordStart = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+func snakeCase(s string) string {
+	return strings.ToLower(wordStart.ReplaceAllString(s, "${1}_${2}"))
+}
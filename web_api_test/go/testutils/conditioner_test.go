@@ -0,0 +1,164 @@
+package testutils
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var errStoreInjected = errors.New("store conditioner: injected failure")
+
+// kvStore is a small interface wrapped the way a test suite would wrap its
+// own dependencies with CallConditioner.
+type kvStore interface {
+	Get(key string) (string, error)
+	Put(key, value string) error
+}
+
+type mapStore map[string]string
+
+func (m mapStore) Get(key string) (string, error) {
+	v, ok := m[key]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return v, nil
+}
+
+func (m mapStore) Put(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+type storeConditioner struct {
+	store kvStore
+	cond  *CallConditioner
+}
+
+func (s *storeConditioner) Get(key string) (string, error) {
+	return ConditionCall(s.cond, "Get", func() (string, error) { return s.store.Get(key) })
+}
+
+func (s *storeConditioner) Put(key, value string) error {
+	return ConditionCallErr(s.cond, "Put", func() error { return s.store.Put(key, value) })
+}
+
+func newStoreConditioner(store kvStore) *storeConditioner {
+	cond := NewCallConditioner(errStoreInjected)
+	cond.SetMutating("Put")
+	return &storeConditioner{store: store, cond: cond}
+}
+
+func TestCallConditioner_DelaysAndExactCallErrors(t *testing.T) {
+	clock := NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	s := newStoreConditioner(mapStore{"a": "1"})
+	s.cond.SetClock(clock)
+	s.cond.SetDelay("Get", 20*time.Millisecond)
+	boom := errors.New("boom")
+	s.cond.InjectError("Get", 2, boom)
+
+	if v, err := s.Get("a"); err != nil || v != "1" {
+		t.Fatalf("Get #1 = %q, %v", v, err)
+	}
+	if _, err := s.Get("a"); !errors.Is(err, boom) {
+		t.Fatalf("Get #2 err = %v, want boom", err)
+	}
+	if v, err := s.Get("a"); err != nil || v != "1" {
+		t.Fatalf("Get #3 = %q, %v", v, err)
+	}
+	if n := s.cond.Calls("Get"); n != 3 {
+		t.Errorf("Calls(Get) = %d, want 3", n)
+	}
+
+	hist := s.cond.History()
+	if len(hist) != 3 {
+		t.Fatalf("history has %d calls, want 3", len(hist))
+	}
+	wantDur := []time.Duration{20 * time.Millisecond, 0, 20 * time.Millisecond}
+	for i, h := range hist {
+		if h.Method != "Get" || h.Call != i+1 || h.Duration != wantDur[i] {
+			t.Errorf("history[%d] = %+v, want Get call %d taking %v", i, h, i+1, wantDur[i])
+		}
+	}
+	if !errors.Is(hist[1].Err, boom) {
+		t.Errorf("history[1].Err = %v, want boom", hist[1].Err)
+	}
+	if p, _ := s.cond.Durations("Get").Max(); time.Duration(p) != 20*time.Millisecond {
+		t.Errorf("max Get duration = %v, want 20ms", time.Duration(p))
+	}
+}
+
+func TestCallConditioner_ErrorRateIsReproducible(t *testing.T) {
+	run := func() []bool {
+		s := newStoreConditioner(mapStore{})
+		s.cond.SetClock(NewMockClock(time.Time{}))
+		s.cond.SetSeed(7)
+		s.cond.SetErrorRate("Put", 0.5)
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			err := s.Put("k", "v")
+			if err != nil && !errors.Is(err, errStoreInjected) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	a, b := run(), run()
+	fails := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("runs diverged at call %d", i+1)
+		}
+		if a[i] {
+			fails++
+		}
+	}
+	if fails == 0 || fails == len(a) {
+		t.Errorf("%d of %d calls failed at rate 0.5", fails, len(a))
+	}
+}
+
+func TestCallConditioner_ReadOnlyModeRejectsMutatingMethods(t *testing.T) {
+	store := mapStore{"a": "1"}
+	s := newStoreConditioner(store)
+	s.cond.SetClock(NewMockClock(time.Time{}))
+	mgr := NewMockModeManager(ModeReadOnly)
+	s.cond.SetMode(mgr)
+
+	if err := s.Put("b", "2"); !errors.Is(err, errStoreInjected) {
+		t.Fatalf("Put in read-only mode err = %v, want injected", err)
+	}
+	if _, ok := store["b"]; ok {
+		t.Error("rejected Put reached the store")
+	}
+	if _, err := s.Get("a"); err != nil {
+		t.Fatalf("Get in read-only mode: %v", err)
+	}
+
+	mgr.SetMode(ModeOffline)
+	if _, err := s.Get("a"); !errors.Is(err, errStoreInjected) {
+		t.Fatalf("Get offline err = %v, want injected", err)
+	}
+}
+
+func TestTracerConditioner_FlushErrorsAndHistory(t *testing.T) {
+	tc := NewTracerConditioner(NewMockTracer())
+	tc.SetClock(NewMockClock(time.Time{}))
+	boom := errors.New("exporter down")
+	tc.InjectFlushError(1, boom)
+
+	if err := tc.Flush(); !errors.Is(err, boom) {
+		t.Fatalf("Flush #1 err = %v, want boom", err)
+	}
+	if err := tc.Flush(); err != nil {
+		t.Fatalf("Flush #2: %v", err)
+	}
+	tc.Conditions().SetErrorRate("Flush", 1)
+	if err := tc.Flush(); !errors.Is(err, ErrTracerConditionerInjected) {
+		t.Fatalf("Flush #3 err = %v, want ErrTracerConditionerInjected", err)
+	}
+	if n := tc.Conditions().Calls("Flush"); n != 3 {
+		t.Errorf("Calls(Flush) = %d, want 3", n)
+	}
+}
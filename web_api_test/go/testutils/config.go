@@ -3,6 +3,8 @@ package testutils
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -179,6 +181,11 @@ type Config struct {
 	Concurrency    ConcurrencyConfig     `json:"concurrency" yaml:"concurrency" env:"CONCURRENCY"`
 	Metrics        MetricsConfig         `json:"metrics" yaml:"metrics" env:"METRICS"`
 	Paths          PathsConfig           `json:"paths" yaml:"paths" env:"PATHS"`
+	Preflight      PreflightConfig       `json:"preflight" yaml:"preflight" env:"PREFLIGHT"`
+	HTTP           HTTPClientConfig      `json:"http" yaml:"http" env:"HTTP"`
+	// HTTPProfiles adds or adjusts HTTPClientFactory profiles; their
+	// non-zero fields override HTTP.
+	HTTPProfiles map[string]HTTPClientConfig `json:"http_profiles" yaml:"http_profiles" env:"-"`
 }
 
 // LoggerConfig holds logger configuration
@@ -197,6 +204,15 @@ type LoggerConfig struct {
 	DefaultFields   map[string]interface{} `json:"default_fields" yaml:"default_fields" env:"DEFAULT_FIELDS"`
 	EnableColors    bool                   `json:"enable_colors" yaml:"enable_colors" env:"ENABLE_COLORS"`
 	LevelOverrides  map[string]LogLevel    `json:"level_overrides" yaml:"level_overrides" env:"LEVEL_OVERRIDES"`
+	// Sinks, when set, replace the single output; see OpenSinks.
+	Sinks []LogSinkConfig `json:"sinks" yaml:"sinks" env:"-"`
+}
+
+// LogSinkConfig describes one entry of LoggerConfig.Sinks.
+type LogSinkConfig struct {
+	Output   string    `json:"output" yaml:"output"` // "stdout", "stderr" or a file path
+	Format   LogFormat `json:"format" yaml:"format"` // "text" (default) or "json"
+	MinLevel LogLevel  `json:"min_level" yaml:"min_level"`
 }
 
 // PortCheckerConfig holds port checker configuration
@@ -217,8 +233,16 @@ type PortCheckerConfig struct {
 	MaxPort          int           `json:"max_port" yaml:"max_port" env:"MAX_PORT"`
 	OperationTimeout time.Duration `json:"operation_timeout" yaml:"operation_timeout" env:"OPERATION_TIMEOUT"`
 	WaitTimeout      time.Duration `json:"wait_timeout" yaml:"wait_timeout" env:"WAIT_TIMEOUT"`
+	PerCheckTimeout  time.Duration `json:"per_check_timeout" yaml:"per_check_timeout" env:"PER_CHECK_TIMEOUT"` // caps one IsPortOpen call including retries; 0 disables
 	EnableStats      bool          `json:"enable_stats" yaml:"enable_stats" env:"ENABLE_STATS"`
 	Deterministic    bool          `json:"deterministic" yaml:"deterministic" env:"DETERMINISTIC"`
+	LocalAddr        string        `json:"local_addr" yaml:"local_addr" env:"LOCAL_ADDR"`                         // source IP or IP:port to dial from; empty lets the OS choose
+	KeepAlive        time.Duration `json:"keep_alive" yaml:"keep_alive" env:"KEEP_ALIVE"`                         // TCP keepalive period; 0 uses the Go default, negative disables
+	Linger           int           `json:"linger" yaml:"linger" env:"LINGER"`                                     // SO_LINGER seconds; 0 keeps the OS default, negative resets on close
+	CacheTTL         time.Duration `json:"cache_ttl" yaml:"cache_ttl" env:"CACHE_TTL"`                            // how long IsPortOpen reuses an open result; 0 disables
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl" yaml:"negative_cache_ttl" env:"NEGATIVE_CACHE_TTL"` // the same for a closed result, usually shorter
+	FallbackDelay    time.Duration `json:"fallback_delay" yaml:"fallback_delay" env:"FALLBACK_DELAY"`             // with AnyIP, how long a host name is tried over IPv6 before IPv4 joins in
+	StabilityChecks  int           `json:"stability_checks" yaml:"stability_checks" env:"STABILITY_CHECKS"`       // successful checks in a row, RetryInterval apart, before a wait succeeds
 }
 
 // RetryConfig holds retry configuration
@@ -234,6 +258,38 @@ type RetryConfig struct {
 	RetryOnPanics   []string      `json:"retry_on_panics" yaml:"retry_on_panics" env:"RETRY_ON_PANICS"`
 	MaxElapsedTime  time.Duration `json:"max_elapsed_time" yaml:"max_elapsed_time" env:"MAX_ELAPSED_TIME"`
 	EnableMetrics   bool          `json:"enable_metrics" yaml:"enable_metrics" env:"ENABLE_METRICS"`
+	RetryableCodes  []int         `json:"retryable_codes" yaml:"retryable_codes" env:"RETRYABLE_CODES"` // HTTP statuses worth retrying
+}
+
+// RetryableStatus reports whether an HTTP response with status code should
+// be retried.
+func (r RetryConfig) RetryableStatus(code int) bool {
+	for _, c := range r.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before retry attempt n (1-based): InitialDelay
+// grown by Multiplier, capped at MaxDelay and spread by ±JitterFactor.
+func (r RetryConfig) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	mult := r.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	delay := float64(r.InitialDelay) * math.Pow(mult, float64(attempt-1))
+	if r.MaxDelay > 0 && delay > float64(r.MaxDelay) {
+		delay = float64(r.MaxDelay)
+	}
+	if r.JitterFactor > 0 {
+		delay += delay * r.JitterFactor * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
 }
 
 // TestDataManagerConfig holds test data manager configuration
@@ -250,6 +306,7 @@ type TestDataManagerConfig struct {
 	AtomicWrites   bool        `json:"atomic_writes" yaml:"atomic_writes" env:"ATOMIC_WRITES"`
 	MaxDirectories int         `json:"max_directories" yaml:"max_directories" env:"MAX_DIRECTORIES"`
 	MaxFiles       int         `json:"max_files" yaml:"max_files" env:"MAX_FILES"`
+	KeepOnFailure  bool        `json:"keep_on_failure" yaml:"keep_on_failure" env:"KEEP_ON_FAILURE"` // Scope keeps the directories of failed tests
 }
 
 // TimerConfig holds timer configuration
@@ -259,6 +316,9 @@ type TimerConfig struct {
 	MaxLaps          int           `json:"max_laps" yaml:"max_laps" env:"MAX_LAPS"`
 	ReportFormat     string        `json:"report_format" yaml:"report_format" env:"REPORT_FORMAT"`
 	EnableStats      bool          `json:"enable_stats" yaml:"enable_stats" env:"ENABLE_STATS"`
+	// NumericDurationJSON writes result durations as nanoseconds instead
+	// of strings, for consumers of the old JSON; see SetNumericDurationJSON.
+	NumericDurationJSON bool `json:"numeric_duration_json" yaml:"numeric_duration_json" env:"NUMERIC_DURATION_JSON"`
 }
 
 // SafeExecuteConfig holds safe execution configuration
@@ -309,6 +369,8 @@ type ConcurrencyConfig struct {
 	EnableDeadlockDetection bool          `json:"enable_deadlock_detection" yaml:"enable_deadlock_detection" env:"ENABLE_DEADLOCK_DETECTION"`
 	WorkerIdleTimeout       time.Duration `json:"worker_idle_timeout" yaml:"worker_idle_timeout" env:"WORKER_IDLE_TIMEOUT"`
 	MaxTaskDuration         time.Duration `json:"max_task_duration" yaml:"max_task_duration" env:"MAX_TASK_DURATION"`
+	TaskFailDuration        time.Duration `json:"task_fail_duration" yaml:"task_fail_duration" env:"TASK_FAIL_DURATION"` // fails the test once a task runs this long; 0 only warns
+	MaxStackDepth           int           `json:"max_stack_depth" yaml:"max_stack_depth" env:"MAX_STACK_DEPTH"`
 }
 
 // MetricsConfig holds metrics configuration
@@ -320,7 +382,7 @@ type MetricsConfig struct {
 	EnableHTTP       bool              `json:"enable_http" yaml:"enable_http" env:"ENABLE_HTTP"`
 	EnablePrometheus bool              `json:"enable_prometheus" yaml:"enable_prometheus" env:"ENABLE_PROMETHEUS"`
 	EnableStatsD     bool              `json:"enable_statsd" yaml:"enable_statsd" env:"ENABLE_STATSD"`
-	StatsDAddress    string            `json:"statsd_address" yaml:"statsd_address" env:"STATSD_ADDRESS"`
+	StatsDAddress    string            `json:"statsd_address" yaml:"statsd_address" env:"STATSD_ADDRESS" sensitive:"true"`
 	DefaultLabels    map[string]string `json:"default_labels" yaml:"default_labels" env:"DEFAULT_LABELS"`
 	HistogramBuckets []float64         `json:"histogram_buckets" yaml:"histogram_buckets" env:"HISTOGRAM_BUCKETS"`
 }
@@ -336,6 +398,35 @@ type PathsConfig struct {
 	ImportPaths    []string `json:"import_paths" yaml:"import_paths" env:"IMPORT_PATHS"`
 	AllowedPaths   []string `json:"allowed_paths" yaml:"allowed_paths" env:"ALLOWED_PATHS"`
 	ForbiddenPaths []string `json:"forbidden_paths" yaml:"forbidden_paths" env:"FORBIDDEN_PATHS"`
+
+	// Failure artifacts (see artifacts.go), written under DataDir/artifacts.
+	CollectArtifacts bool  `json:"collect_artifacts" yaml:"collect_artifacts" env:"COLLECT_ARTIFACTS"`
+	ArtifactMaxBytes int64 `json:"artifact_max_bytes" yaml:"artifact_max_bytes" env:"ARTIFACT_MAX_BYTES"` // 0 uses DefaultArtifactMaxBytes
+}
+
+// PreflightConfig tunes Preflight.
+type PreflightConfig struct {
+	Skip         []string      `json:"skip" yaml:"skip" env:"SKIP"`                               // check names or path.Match patterns, e.g. "exec:*"
+	MinFreeDisk  int64         `json:"min_free_disk" yaml:"min_free_disk" env:"MIN_FREE_DISK"`    // bytes required in Paths.TempDir
+	MaxClockSkew time.Duration `json:"max_clock_skew" yaml:"max_clock_skew" env:"MAX_CLOCK_SKEW"` // tolerated offset from the reference clock
+}
+
+// HTTPClientConfig configures the clients built by HTTPClientFactory.
+// Timeout applies per client; every other field is a Transport setting.
+// Zero fields take the defaults of NewHTTPClientFactory.
+type HTTPClientConfig struct {
+	Timeout               time.Duration `json:"timeout" yaml:"timeout" env:"TIMEOUT"`
+	DialTimeout           time.Duration `json:"dial_timeout" yaml:"dial_timeout" env:"DIAL_TIMEOUT"`
+	KeepAlive             time.Duration `json:"keep_alive" yaml:"keep_alive" env:"KEEP_ALIVE"`
+	TLSHandshakeTimeout   time.Duration `json:"tls_handshake_timeout" yaml:"tls_handshake_timeout" env:"TLS_HANDSHAKE_TIMEOUT"`
+	ResponseHeaderTimeout time.Duration `json:"response_header_timeout" yaml:"response_header_timeout" env:"RESPONSE_HEADER_TIMEOUT"` // 0 waits as long as Timeout allows
+	IdleConnTimeout       time.Duration `json:"idle_conn_timeout" yaml:"idle_conn_timeout" env:"IDLE_CONN_TIMEOUT"`
+	MaxIdleConns          int           `json:"max_idle_conns" yaml:"max_idle_conns" env:"MAX_IDLE_CONNS"`
+	MaxIdleConnsPerHost   int           `json:"max_idle_conns_per_host" yaml:"max_idle_conns_per_host" env:"MAX_IDLE_CONNS_PER_HOST"`
+	MaxConnsPerHost       int           `json:"max_conns_per_host" yaml:"max_conns_per_host" env:"MAX_CONNS_PER_HOST"` // 0 is unlimited
+	DisableCompression    bool          `json:"disable_compression" yaml:"disable_compression" env:"DISABLE_COMPRESSION"`
+	DisableKeepAlives     bool          `json:"disable_keep_alives" yaml:"disable_keep_alives" env:"DISABLE_KEEP_ALIVES"`
+	InsecureSkipVerify    bool          `json:"insecure_skip_verify" yaml:"insecure_skip_verify" env:"INSECURE_SKIP_VERIFY"`
 }
 
 // DefaultConfig returns a default configuration with sane defaults
@@ -380,6 +471,9 @@ func DefaultConfig() *Config {
 			MaxPort:          65535,
 			OperationTimeout: 30 * time.Second,
 			WaitTimeout:      5 * time.Minute,
+			PerCheckTimeout:  10 * time.Second,
+			FallbackDelay:    300 * time.Millisecond,
+			StabilityChecks:  1,
 			EnableStats:      true,
 			Deterministic:    false,
 		},
@@ -395,6 +489,7 @@ func DefaultConfig() *Config {
 			RetryOnPanics:   []string{},
 			MaxElapsedTime:  5 * time.Minute,
 			EnableMetrics:   true,
+			RetryableCodes:  []int{429, 502, 503, 504},
 		},
 		TestData: TestDataManagerConfig{
 			TempDir:        os.TempDir(),
@@ -458,6 +553,8 @@ func DefaultConfig() *Config {
 			EnableDeadlockDetection: false,
 			WorkerIdleTimeout:       1 * time.Minute,
 			MaxTaskDuration:         5 * time.Minute,
+			TaskFailDuration:        0,
+			MaxStackDepth:           32,
 		},
 		Metrics: MetricsConfig{
 			Enabled:          false,
@@ -485,34 +582,53 @@ func DefaultConfig() *Config {
 			AllowedPaths:   []string{},
 			ForbiddenPaths: []string{"/", "/etc", "/bin", "/sbin", "/usr/bin", "/usr/sbin"},
 		},
+		Preflight: PreflightConfig{
+			Skip:         []string{},
+			MinFreeDisk:  512 * 1024 * 1024, // 512MB
+			MaxClockSkew: 2 * time.Second,
+		},
+		HTTP: HTTPClientConfig{
+			Timeout:             30 * time.Second,
+			DialTimeout:         30 * time.Second,
+			KeepAlive:           30 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			IdleConnTimeout:     90 * time.Second,
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 10,
+		},
 	}
 }
 
-// LoadConfig loads configuration from a file
-func LoadConfig(filePath string) (*Config, error) {
+// LoadConfig loads configuration from a file. Keys that match no field are
+// ignored unless WithStrictKeys is given.
+func LoadConfig(filePath string, opts ...ConfigLoadOption) (*Config, error) {
 	config := DefaultConfig()
 
 	if filePath == "" {
 		return config, nil
 	}
 
+	var o configLoadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	ext := strings.ToLower(filepath.Ext(filePath))
-	switch ext {
-	case ".json":
-		if err := json.Unmarshal(data, config); err != nil {
-			return nil, fmt.Errorf("failed to parse JSON config: %w", err)
+	if err := decodeConfig(filePath, data, config); err != nil {
+		return nil, err
+	}
+	if o.strict {
+		report, err := checkConfigKeys(filePath, data)
+		if err != nil {
+			return nil, err
 		}
-	case ".yaml", ".yml":
-		if err := yaml.Unmarshal(data, config); err != nil {
-			return nil, fmt.Errorf("failed to parse YAML config: %w", err)
+		if report.HasErrors() {
+			return nil, fmt.Errorf("config file has unknown keys: %w", report)
 		}
-	default:
-		return nil, fmt.Errorf("unsupported config file format: %s", ext)
 	}
 
 	// Load environment variables
@@ -529,6 +645,24 @@ func LoadConfig(filePath string) (*Config, error) {
 	return config, nil
 }
 
+// decodeConfig parses data into config according to the file extension.
+func decodeConfig(filePath string, data []byte, config *Config) error {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	switch ext {
+	case ".json":
+		if err := json.Unmarshal(data, config); err != nil {
+			return fmt.Errorf("failed to parse JSON config: %w", err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return fmt.Errorf("failed to parse YAML config: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format: %s", ext)
+	}
+	return nil
+}
+
 // LoadFromEnv loads configuration from environment variables
 func (c *Config) LoadFromEnv() {
 	c.loadStructFromEnv("TESTUTILS_", reflect.ValueOf(c).Elem())
@@ -606,9 +740,15 @@ func (c *Config) setFieldFromEnv(field reflect.Value, fieldType reflect.StructFi
 		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
 		for i, v := range values {
 			elem := slice.Index(i)
-			// Handle string slices
-			if elem.Kind() == reflect.String {
+			switch elem.Kind() {
+			case reflect.String:
 				elem.SetString(strings.TrimSpace(v))
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+				if err != nil {
+					return err
+				}
+				elem.SetInt(n)
 			}
 			// TODO: Handle other slice types
 		}
@@ -621,7 +761,7 @@ func (c *Config) setFieldFromEnv(field reflect.Value, fieldType reflect.StructFi
 		// Handle custom types
 		switch field.Type() {
 		case reflect.TypeOf(time.Duration(0)):
-			if val, err := time.ParseDuration(envValue); err != nil {
+			if val, err := ParseFlexibleDuration(envValue); err != nil {
 				return err
 			} else {
 				field.Set(reflect.ValueOf(val))
@@ -691,130 +831,219 @@ func (c *Config) ExpandPaths() {
 
 	// Expand paths in LoggerConfig
 	c.Logger.OutputFile = expand(c.Logger.OutputFile)
+	for i := range c.Logger.Sinks {
+		c.Logger.Sinks[i].Output = expand(c.Logger.Sinks[i].Output)
+	}
 }
 
-// Validate checks the configuration for errors and sanity
+// Validate checks the configuration for errors and sanity. It returns a
+// *ValidationReport when any error-severity issue is found; warnings alone
+// do not fail validation. Use ValidateReport to inspect warnings.
 func (c *Config) Validate() error {
-	var errors []string
+	report := c.ValidateReport()
+	if report.HasErrors() {
+		return report
+	}
+	return nil
+}
+
+// ValidateReport checks the configuration and returns every issue found,
+// including warnings.
+func (c *Config) ValidateReport() *ValidationReport {
+	r := &ValidationReport{}
 
 	// App validation
 	if c.AppName == "" {
-		errors = append(errors, "AppName cannot be empty")
+		r.addError("AppName", "AppName cannot be empty")
 	}
 
 	// Logger validation
 	if c.Logger.MaxFileSize < 0 {
-		errors = append(errors, "Logger MaxFileSize must be >= 0")
+		r.addError("Logger.MaxFileSize", "Logger MaxFileSize must be >= 0")
 	}
 	if c.Logger.MaxBackups < 0 {
-		errors = append(errors, "Logger MaxBackups must be >= 0")
+		r.addError("Logger.MaxBackups", "Logger MaxBackups must be >= 0")
 	}
 	if c.Logger.MaxAge < 0 {
-		errors = append(errors, "Logger MaxAge must be >= 0")
+		r.addError("Logger.MaxAge", "Logger MaxAge must be >= 0")
 	}
 
 	// PortChecker validation
 	if c.PortChecker.MaxConcurrency <= 0 {
-		errors = append(errors, "PortChecker MaxConcurrency must be > 0")
+		r.addError("PortChecker.MaxConcurrency", "PortChecker MaxConcurrency must be > 0")
 	}
 	if c.PortChecker.Workers <= 0 {
-		errors = append(errors, "PortChecker Workers must be > 0")
+		r.addError("PortChecker.Workers", "PortChecker Workers must be > 0")
 	}
 	if c.PortChecker.MinPort < 1 || c.PortChecker.MinPort > 65535 {
-		errors = append(errors, "PortChecker MinPort must be between 1 and 65535")
+		r.addError("PortChecker.MinPort", "PortChecker MinPort must be between 1 and 65535")
 	}
 	if c.PortChecker.MaxPort < 1 || c.PortChecker.MaxPort > 65535 {
-		errors = append(errors, "PortChecker MaxPort must be between 1 and 65535")
+		r.addError("PortChecker.MaxPort", "PortChecker MaxPort must be between 1 and 65535")
 	}
 	if c.PortChecker.MinPort > c.PortChecker.MaxPort {
-		errors = append(errors, "PortChecker MinPort must be <= MaxPort")
+		r.addError("PortChecker.MinPort", "PortChecker MinPort must be <= MaxPort")
 	}
 	if c.PortChecker.BackoffFactor < 1.0 {
-		errors = append(errors, "PortChecker BackoffFactor must be >= 1.0")
+		r.addError("PortChecker.BackoffFactor", "PortChecker BackoffFactor must be >= 1.0")
 	}
 
 	// Retry validation
 	if c.Retry.Attempts < 1 {
-		errors = append(errors, "Retry Attempts must be >= 1")
+		r.addError("Retry.Attempts", "Retry Attempts must be >= 1")
 	}
 	if c.Retry.Multiplier < 1.0 {
-		errors = append(errors, "Retry Multiplier must be >= 1.0")
+		r.addError("Retry.Multiplier", "Retry Multiplier must be >= 1.0")
 	}
 	if c.Retry.MaxDelay < c.Retry.InitialDelay {
-		errors = append(errors, "Retry MaxDelay must be >= InitialDelay")
+		r.addError("Retry.MaxDelay", "Retry MaxDelay must be >= InitialDelay")
 	}
 	if c.Retry.JitterFactor < 0 || c.Retry.JitterFactor > 1 {
-		errors = append(errors, "Retry JitterFactor must be between 0 and 1")
+		r.addError("Retry.JitterFactor", "Retry JitterFactor must be between 0 and 1")
+	}
+	for _, code := range c.Retry.RetryableCodes {
+		if code < 100 || code > 599 {
+			r.addError("Retry.RetryableCodes", fmt.Sprintf("Retry RetryableCodes contains %d, which is not an HTTP status", code))
+		}
 	}
 
 	// TestData validation
 	if c.TestData.MaxFileSize < 0 {
-		errors = append(errors, "TestData MaxFileSize must be >= 0")
+		r.addError("TestData.MaxFileSize", "TestData MaxFileSize must be >= 0")
 	}
 	if c.TestData.MaxDirectories < 0 {
-		errors = append(errors, "TestData MaxDirectories must be >= 0")
+		r.addError("TestData.MaxDirectories", "TestData MaxDirectories must be >= 0")
 	}
 	if c.TestData.MaxFiles < 0 {
-		errors = append(errors, "TestData MaxFiles must be >= 0")
+		r.addError("TestData.MaxFiles", "TestData MaxFiles must be >= 0")
 	}
 
 	// IntegerUtils validation
 	if c.IntegerUtils.MaxRetries < 0 {
-		errors = append(errors, "IntegerUtils MaxRetries must be >= 0")
+		r.addError("IntegerUtils.MaxRetries", "IntegerUtils MaxRetries must be >= 0")
 	}
 	if c.IntegerUtils.CacheSize < 0 {
-		errors = append(errors, "IntegerUtils CacheSize must be >= 0")
+		r.addError("IntegerUtils.CacheSize", "IntegerUtils CacheSize must be >= 0")
 	}
 	if c.IntegerUtils.PrimeCacheLimit < 0 {
-		errors = append(errors, "IntegerUtils PrimeCacheLimit must be >= 0")
+		r.addError("IntegerUtils.PrimeCacheLimit", "IntegerUtils PrimeCacheLimit must be >= 0")
 	}
 
 	// Concurrency validation
 	if c.Concurrency.MaxGoroutines <= 0 {
-		errors = append(errors, "Concurrency MaxGoroutines must be > 0")
+		r.addError("Concurrency.MaxGoroutines", "Concurrency MaxGoroutines must be > 0")
 	}
 	if c.Concurrency.DefaultPoolSize <= 0 {
-		errors = append(errors, "Concurrency DefaultPoolSize must be > 0")
+		r.addError("Concurrency.DefaultPoolSize", "Concurrency DefaultPoolSize must be > 0")
 	}
 	if c.Concurrency.QueueSize <= 0 {
-		errors = append(errors, "Concurrency QueueSize must be > 0")
+		r.addError("Concurrency.QueueSize", "Concurrency QueueSize must be > 0")
+	}
+	if c.Concurrency.MaxStackDepth < 0 {
+		r.addError("Concurrency.MaxStackDepth", "Concurrency MaxStackDepth must be >= 0")
+	}
+	if c.Concurrency.TaskFailDuration < 0 {
+		r.addError("Concurrency.TaskFailDuration", "Concurrency TaskFailDuration must be >= 0")
+	} else if c.Concurrency.TaskFailDuration > 0 && c.Concurrency.TaskFailDuration < c.Concurrency.MaxTaskDuration {
+		r.addWarning("Concurrency.TaskFailDuration", "Concurrency TaskFailDuration is shorter than MaxTaskDuration, so tasks fail before any warning")
 	}
 
 	// Metrics validation
 	if c.Metrics.Enabled {
 		if c.Metrics.MetricsPort < 1 || c.Metrics.MetricsPort > 65535 {
-			errors = append(errors, "Metrics Port must be between 1 and 65535")
+			r.addError("Metrics.MetricsPort", "Metrics Port must be between 1 and 65535")
 		}
 	}
 
 	// Timer validation
 	if c.Timer.DefaultPrecision <= 0 {
-		errors = append(errors, "Timer DefaultPrecision must be > 0")
+		r.addError("Timer.DefaultPrecision", "Timer DefaultPrecision must be > 0")
 	}
 	if c.Timer.MaxLaps < 0 {
-		errors = append(errors, "Timer MaxLaps must be >= 0")
+		r.addError("Timer.MaxLaps", "Timer MaxLaps must be >= 0")
 	}
 
 	// FileOperations validation
 	if c.FileOperations.BufferSize <= 0 {
-		errors = append(errors, "FileOperations BufferSize must be > 0")
+		r.addError("FileOperations.BufferSize", "FileOperations BufferSize must be > 0")
 	}
 	if c.FileOperations.CopyConcurrency <= 0 {
-		errors = append(errors, "FileOperations CopyConcurrency must be > 0")
+		r.addError("FileOperations.CopyConcurrency", "FileOperations CopyConcurrency must be > 0")
 	}
 	if c.FileOperations.MaxFileSize < 0 {
-		errors = append(errors, "FileOperations MaxFileSize must be >= 0")
+		r.addError("FileOperations.MaxFileSize", "FileOperations MaxFileSize must be >= 0")
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("validation errors: %s", strings.Join(errors, "; "))
+	// Warnings: legal but almost certainly unintended combinations
+	if c.Metrics.Enabled && !c.Metrics.EnablePrometheus && !c.Metrics.EnableStatsD {
+		r.addWarning("Metrics.Enabled", "Metrics enabled but EnablePrometheus and EnableStatsD both false")
+	}
+	if c.Retry.Timeout > 0 && c.Retry.Timeout < c.Retry.InitialDelay {
+		r.addWarning("Retry.Timeout", "Retry Timeout is shorter than InitialDelay, so no retry can happen")
+	}
+	if c.PortChecker.OperationTimeout > 0 && c.PortChecker.DialTimeout > c.PortChecker.OperationTimeout {
+		r.addWarning("PortChecker.DialTimeout", "PortChecker DialTimeout exceeds OperationTimeout")
+	}
+	if c.PortChecker.PerCheckTimeout < 0 {
+		r.addError("PortChecker.PerCheckTimeout", "PortChecker PerCheckTimeout must be >= 0")
+	} else if c.PortChecker.PerCheckTimeout > 0 && c.PortChecker.PerCheckTimeout < c.PortChecker.DialTimeout {
+		r.addWarning("PortChecker.PerCheckTimeout", "PortChecker PerCheckTimeout is shorter than DialTimeout, so a slow dial can consume the whole budget")
+	}
+	if c.PortChecker.FallbackDelay < 0 {
+		r.addError("PortChecker.FallbackDelay", "PortChecker FallbackDelay must be >= 0")
+	}
+	if c.PortChecker.StabilityChecks < 0 {
+		r.addError("PortChecker.StabilityChecks", "PortChecker StabilityChecks must be >= 0")
+	} else if n := c.PortChecker.StabilityChecks; n > 1 && c.PortChecker.WaitTimeout > 0 &&
+		time.Duration(n-1)*c.PortChecker.RetryInterval >= c.PortChecker.WaitTimeout {
+		r.addWarning("PortChecker.StabilityChecks", "PortChecker StabilityChecks spaced by RetryInterval take longer than WaitTimeout, so no wait can succeed")
+	}
+	if c.PortChecker.CacheTTL < 0 {
+		r.addError("PortChecker.CacheTTL", "PortChecker CacheTTL must be >= 0")
+	}
+	if c.PortChecker.NegativeCacheTTL < 0 {
+		r.addError("PortChecker.NegativeCacheTTL", "PortChecker NegativeCacheTTL must be >= 0")
+	} else if c.PortChecker.NegativeCacheTTL > c.PortChecker.CacheTTL && c.PortChecker.CacheTTL > 0 {
+		r.addWarning("PortChecker.NegativeCacheTTL", "PortChecker NegativeCacheTTL exceeds CacheTTL, so a port that comes up stays reported closed for longer")
+	}
+	if c.PortChecker.LocalAddr != "" {
+		if _, err := parseLocalAddr(c.PortChecker.LocalAddr); err != nil {
+			r.addError("PortChecker.LocalAddr", "PortChecker LocalAddr "+err.Error())
+		}
+	}
+	if c.HTTP.MaxConnsPerHost < 0 {
+		r.addError("HTTP.MaxConnsPerHost", "HTTP MaxConnsPerHost must be >= 0")
+	}
+	if c.HTTP.Timeout > 0 && c.HTTP.ResponseHeaderTimeout > c.HTTP.Timeout {
+		r.addWarning("HTTP.ResponseHeaderTimeout", "HTTP ResponseHeaderTimeout exceeds Timeout")
+	}
+	if c.Logger.OutputFile == "" && c.Logger.MaxBackups > 0 && c.Logger.MaxFileSize == 0 {
+		r.addWarning("Logger.MaxBackups", "Logger MaxBackups has no effect without MaxFileSize")
+	}
+	for i, sink := range c.Logger.Sinks {
+		field := fmt.Sprintf("Logger.Sinks[%d]", i)
+		if sink.Output == "" {
+			r.addError(field+".Output", "Logger sink Output must be stdout, stderr or a file path")
+		}
+		if sink.Format != "" && sink.Format != LogFormatText && sink.Format != LogFormatJSON {
+			r.addError(field+".Format", fmt.Sprintf("Logger sink Format must be text or json, got %q", sink.Format))
+		}
 	}
 
-	return nil
+	return r
 }
 
-// Save saves the configuration to a file
+// Save saves the configuration to a file. It refuses to write a config that
+// holds sensitive values; use SaveWithSecrets to write them deliberately.
 func (c *Config) Save(filePath string) error {
+	if c.HasSecrets() {
+		return fmt.Errorf("config contains sensitive values, use SaveWithSecrets to write %s", filePath)
+	}
+	return c.SaveWithSecrets(filePath)
+}
+
+// SaveWithSecrets saves the configuration to a file including sensitive values
+func (c *Config) SaveWithSecrets(filePath string) error {
 	ext := strings.ToLower(filepath.Ext(filePath))
 
 	var data []byte
@@ -946,9 +1175,10 @@ func (c *Config) GetTempDir() string {
 	return os.TempDir()
 }
 
-// String returns a string representation of the configuration
+// String returns a string representation of the configuration with sensitive
+// values redacted
 func (c *Config) String() string {
-	data, err := json.MarshalIndent(c, "", "  ")
+	data, err := json.MarshalIndent(c.Redacted(), "", "  ")
 	if err != nil {
 		return fmt.Sprintf("Error marshaling config: %v", err)
 	}
@@ -958,7 +1188,7 @@ func (c *Config) String() string {
 // ShortString returns a concise string representation
 func (c *Config) ShortString() string {
 	return fmt.Sprintf("Config[App=%s v%s Env=%s]",
-		c.AppName, c.AppVersion, c.Environment)
+		c.displayField("AppName"), c.displayField("AppVersion"), c.displayField("Environment"))
 }
 
 // ExampleUsage demonstrates how to use the configuration
@@ -983,8 +1213,8 @@ func ExampleUsage() {
 	fmt.Printf("Environment: %s\n", config.Environment)
 	fmt.Printf("Log Level: %s\n", config.Logger.DefaultLevel)
 
-	// Save configuration
-	if err := config.Save("config-backup.yaml"); err != nil {
+	// Save configuration; the backup deliberately keeps sensitive values
+	if err := config.SaveWithSecrets("config-backup.yaml"); err != nil {
 		fmt.Printf("Failed to save config: %v\n", err)
 	}
 }
@@ -0,0 +1,206 @@
+package testutils
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces sensitive values in String(), Redacted() and logs.
+const redactedValue = "****"
+
+// sensitiveKeyPattern matches map keys whose values must be redacted, e.g.
+// entries in Logger.DefaultFields or Metrics.DefaultLabels.
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)password|passwd|token|secret|key|credential`)
+
+// Redacted returns the configuration as a generic map keyed by json field
+// names, with every field tagged `sensitive:"true"` and every map entry whose
+// key matches password|token|secret|key replaced by "****". Non-sensitive
+// values keep their structure so the result is safe to log or diff.
+func (c *Config) Redacted() map[string]any {
+	out, _ := redactValue(reflect.ValueOf(c).Elem(), false).(map[string]any)
+	return out
+}
+
+// HasSecrets reports whether any sensitive field or map entry is non-empty
+// and differs from DefaultConfig, so the shipped defaults (e.g. the local
+// StatsD address) do not block Save.
+func (c *Config) HasSecrets() bool {
+	return hasSecrets(reflect.ValueOf(c).Elem(), reflect.ValueOf(DefaultConfig()).Elem(), false)
+}
+
+// redactValue converts v into plain maps, slices and scalars, masking values
+// that are sensitive either by tag or by map key.
+func redactValue(v reflect.Value, sensitive bool) any {
+	if !v.IsValid() {
+		return nil
+	}
+	if sensitive {
+		// Map values like DefaultFields' are interfaces; judge what they hold.
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.IsZero() {
+			return zeroDisplay(v)
+		}
+		return redactedValue
+	}
+
+	// Types with custom text forms (LogLevel, Protocol) render as text.
+	if v.CanInterface() {
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok && v.Kind() != reflect.Struct {
+			if text, err := tm.MarshalText(); err == nil {
+				return string(text)
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem(), false)
+	case reflect.Struct:
+		t := v.Type()
+		out := make(map[string]any, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name := jsonFieldName(sf)
+			if name == "-" {
+				continue
+			}
+			out[name] = redactValue(v.Field(i), sf.Tag.Get("sensitive") == "true")
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		out := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			out[key] = redactValue(iter.Value(), sensitiveKeyPattern.MatchString(key))
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return []any{}
+		}
+		out := make([]any, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out[i] = redactValue(v.Index(i), false)
+		}
+		return out
+	default:
+		if v.CanInterface() {
+			return v.Interface()
+		}
+		return nil
+	}
+}
+
+// zeroDisplay keeps empty sensitive values empty so "unset" stays visible.
+func zeroDisplay(v reflect.Value) any {
+	if v.Kind() == reflect.String {
+		return ""
+	}
+	return nil
+}
+
+// hasSecrets walks v looking for non-empty sensitive values. def is the
+// matching value of a default config, walked alongside v; a sensitive value
+// equal to its default does not count. def may be invalid where there is no
+// counterpart, e.g. a map entry the defaults lack.
+func hasSecrets(v, def reflect.Value, sensitive bool) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if sensitive {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			v = v.Elem()
+		}
+		if def.IsValid() && def.Kind() == reflect.Interface && !def.IsNil() {
+			def = def.Elem()
+		}
+		if v.IsZero() {
+			return false
+		}
+		return !def.IsValid() || !reflect.DeepEqual(v.Interface(), def.Interface())
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		if def.IsValid() && def.Kind() == v.Kind() && !def.IsNil() {
+			def = def.Elem()
+		} else {
+			def = reflect.Value{}
+		}
+		return hasSecrets(v.Elem(), def, false)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if !t.Field(i).IsExported() {
+				continue
+			}
+			var d reflect.Value
+			if def.IsValid() && def.Type() == t {
+				d = def.Field(i)
+			}
+			if hasSecrets(v.Field(i), d, t.Field(i).Tag.Get("sensitive") == "true") {
+				return true
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			var d reflect.Value
+			if def.IsValid() && def.Type() == v.Type() {
+				d = def.MapIndex(iter.Key())
+			}
+			key := fmt.Sprint(iter.Key().Interface())
+			if hasSecrets(iter.Value(), d, sensitiveKeyPattern.MatchString(key)) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var d reflect.Value
+			if def.IsValid() && def.Type() == v.Type() && i < def.Len() {
+				d = def.Index(i)
+			}
+			if hasSecrets(v.Index(i), d, false) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonFieldName returns the json tag name of a field, or its Go name.
+func jsonFieldName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// displayField returns the named top-level field formatted for display,
+// honouring the sensitive tag.
+func (c *Config) displayField(name string) string {
+	sf, ok := reflect.TypeOf(c).Elem().FieldByName(name)
+	if !ok {
+		return ""
+	}
+	v := reflect.ValueOf(c).Elem().FieldByIndex(sf.Index)
+	return fmt.Sprint(redactValue(v, sf.Tag.Get("sensitive") == "true"))
+}
@@ -0,0 +1,110 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfig_SaveDefault(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.HasSecrets() {
+		t.Fatalf("the default config must not hold secrets: %v", cfg.Redacted())
+	}
+	for _, name := range []string{"config.yaml", "config.json"} {
+		path := filepath.Join(t.TempDir(), name)
+		if err := cfg.Save(path); err != nil {
+			t.Fatalf("Save(%s): %v", name, err)
+		}
+		loaded, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig(%s): %v", name, err)
+		}
+		if loaded.Metrics.StatsDAddress != "localhost:8125" {
+			t.Errorf("%s: statsd_address %q after a round trip", name, loaded.Metrics.StatsDAddress)
+		}
+	}
+}
+
+func TestConfig_SaveRefusesSecrets(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Logger.DefaultFields = map[string]any{"service": "orders", "db_password": "hunter2"}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := cfg.Save(path)
+	if err == nil || !strings.Contains(err.Error(), "use SaveWithSecrets") {
+		t.Fatalf("Save with a secret: %v", err)
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		t.Errorf("Save wrote %s despite refusing", path)
+	}
+
+	statsd := DefaultConfig()
+	statsd.Metrics.StatsDAddress = "statsd.internal:8125"
+	if !statsd.HasSecrets() {
+		t.Error("a non-default statsd_address is not reported as a secret")
+	}
+
+	if err := cfg.SaveWithSecrets(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "hunter2") {
+		t.Errorf("SaveWithSecrets dropped the secret:\n%s", data)
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Logger.DefaultFields = map[string]any{"service": "orders", "API_Token": "t0k", "empty_secret": ""}
+	cfg.Metrics.DefaultLabels["region"] = "eu"
+	cfg.Metrics.StatsDAddress = "statsd.internal:8125"
+
+	redacted := cfg.Redacted()
+	fields := redacted["logger"].(map[string]any)["default_fields"].(map[string]any)
+	want := map[string]any{"service": "orders", "API_Token": redactedValue, "empty_secret": ""}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("default_fields %v, want %v", fields, want)
+	}
+	metrics := redacted["metrics"].(map[string]any)
+	if metrics["statsd_address"] != redactedValue {
+		t.Errorf("statsd_address %v, want it redacted", metrics["statsd_address"])
+	}
+	if metrics["default_labels"].(map[string]any)["region"] != "eu" {
+		t.Errorf("non-sensitive metrics values were changed: %v", metrics)
+	}
+	if level := redacted["logger"].(map[string]any)["default_level"]; level != cfg.Logger.DefaultLevel.String() {
+		t.Errorf("default_level %v, want its text form", level)
+	}
+	if s := cfg.String(); strings.Contains(s, "t0k") || strings.Contains(s, "statsd.internal") || !strings.Contains(s, redactedValue) {
+		t.Errorf("String() leaks or loses the redaction:\n%s", s)
+	}
+	if cfg.Logger.DefaultFields["API_Token"] != "t0k" {
+		t.Error("Redacted modified the config")
+	}
+}
+
+func TestRedactValue_SensitiveTag(t *testing.T) {
+	type creds struct {
+		User     string            `json:"user"`
+		Password string            `json:"password" sensitive:"true"`
+		Unset    string            `json:"unset" sensitive:"true"`
+		Env      map[string]string `json:"env" sensitive:"true"`
+		Skipped  string            `json:"-"`
+	}
+	v := creds{User: "app", Password: "pw", Env: map[string]string{"A": "b"}, Skipped: "x"}
+
+	got := redactValue(reflect.ValueOf(v), false)
+	want := map[string]any{"user": "app", "password": redactedValue, "unset": "", "env": redactedValue}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redactValue = %v, want %v", got, want)
+	}
+	if !hasSecrets(reflect.ValueOf(v), reflect.Value{}, false) || hasSecrets(reflect.ValueOf(creds{User: "app"}), reflect.Value{}, false) {
+		t.Error("hasSecrets must follow the sensitive tag")
+	}
+	if hasSecrets(reflect.ValueOf(v), reflect.ValueOf(v), false) {
+		t.Error("hasSecrets counted values equal to their defaults")
+	}
+}
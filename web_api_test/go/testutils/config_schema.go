@@ -0,0 +1,356 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------------------------------------------------
+// JSON Schema and unknown-key detection for config files
+// ------------------------------------------------------------------------
+
+// configSchemaURI is the draft JSONSchema generates.
+const configSchemaURI = "http://json-schema.org/draft-07/schema#"
+
+// durationPattern matches the strings time.ParseDuration accepts.
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+// jsonSchema is the subset of draft-07 the config needs. Field order fixes
+// the key order of the output, and properties are sorted by json.Marshal,
+// so the generated schema is byte-for-byte stable.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 any                    `json:"type,omitempty"` // string or []string
+	Enum                 []any                  `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties any                    `json:"additionalProperties,omitempty"` // false or *jsonSchema
+	Nullable             bool                   `json:"nullable,omitempty"`             // OpenAPI-style, see schema_validate.go
+
+	re *regexp.Regexp // compiled Pattern, set by prepare
+}
+
+// schemaBound is a numeric limit placed on a field in the schema.
+type schemaBound struct {
+	min, max  *float64
+	exclusive bool // min is exclusive
+}
+
+func atLeast(v float64) schemaBound      { return schemaBound{min: &v} }
+func above(v float64) schemaBound        { return schemaBound{min: &v, exclusive: true} }
+func between(lo, hi float64) schemaBound { return schemaBound{min: &lo, max: &hi} }
+
+// configSchemaBounds mirrors the unconditional numeric rules of
+// ValidateReport, keyed by Go field path. Keep the two in step.
+var configSchemaBounds = map[string]schemaBound{
+	"Logger.MaxFileSize":             atLeast(0),
+	"Logger.MaxBackups":              atLeast(0),
+	"Logger.MaxAge":                  atLeast(0),
+	"PortChecker.MaxConcurrency":     atLeast(1),
+	"PortChecker.Workers":            atLeast(1),
+	"PortChecker.MinPort":            between(1, 65535),
+	"PortChecker.MaxPort":            between(1, 65535),
+	"PortChecker.BackoffFactor":      atLeast(1),
+	"PortChecker.PerCheckTimeout":    atLeast(0),
+	"Retry.Attempts":                 atLeast(1),
+	"Retry.Multiplier":               atLeast(1),
+	"Retry.JitterFactor":             between(0, 1),
+	"TestData.MaxFileSize":           atLeast(0),
+	"TestData.MaxDirectories":        atLeast(0),
+	"TestData.MaxFiles":              atLeast(0),
+	"IntegerUtils.MaxRetries":        atLeast(0),
+	"IntegerUtils.CacheSize":         atLeast(0),
+	"IntegerUtils.PrimeCacheLimit":   atLeast(0),
+	"Concurrency.MaxGoroutines":      atLeast(1),
+	"Concurrency.DefaultPoolSize":    atLeast(1),
+	"Concurrency.QueueSize":          atLeast(1),
+	"Concurrency.MaxStackDepth":      atLeast(0),
+	"Concurrency.TaskFailDuration":   atLeast(0),
+	"Metrics.MetricsPort":            between(0, 65535),
+	"Timer.DefaultPrecision":         above(0),
+	"Timer.MaxLaps":                  atLeast(0),
+	"FileOperations.BufferSize":      atLeast(1),
+	"FileOperations.CopyConcurrency": atLeast(1),
+	"FileOperations.MaxFileSize":     atLeast(0),
+}
+
+// JSONSchema returns a draft-07 JSON Schema for config files, generated
+// from the struct tags. Unknown keys are rejected, levels, protocols and IP
+// versions are enumerated, and numeric fields carry the minimums Validate
+// enforces. The output is stable, so it can be committed and diffed:
+//
+//	schema, _ := (&Config{}).JSONSchema()
+//	os.WriteFile("config.schema.json", schema, 0o644)
+func (c *Config) JSONSchema() ([]byte, error) {
+	root := schemaFor(reflect.TypeOf(Config{}), "")
+	root.Schema = configSchemaURI
+	root.Title = "testutils configuration"
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config schema: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+var (
+	durationType  = reflect.TypeOf(time.Duration(0))
+	logLevelType  = reflect.TypeOf(LogLevel(0))
+	protocolType  = reflect.TypeOf(Protocol(""))
+	ipVersionType = reflect.TypeOf(IPVersion(0))
+)
+
+// schemaFor builds the schema of t; path is its Go field path.
+func schemaFor(t reflect.Type, path string) *jsonSchema {
+	s := &jsonSchema{}
+	switch t {
+	case durationType:
+		s.Type = []string{"integer", "string"}
+		s.Pattern = durationPattern
+		s.Description = `nanoseconds, or a duration string such as "1.5s"`
+	case logLevelType:
+		s.Type = "string"
+		for _, name := range logLevelNames {
+			s.Enum = append(s.Enum, name, strings.ToLower(name))
+		}
+	case protocolType:
+		s.Type = "string"
+		for _, p := range []Protocol{TCP, TCP4, TCP6, UDP, UDP4, UDP6} {
+			s.Enum = append(s.Enum, string(p))
+		}
+	case ipVersionType:
+		s.Type = "integer"
+		s.Enum = []any{int(AnyIP), int(IPv4), int(IPv6)}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			s.Type = "boolean"
+		case reflect.String:
+			s.Type = "string"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s.Type = "integer"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s.Type = "integer"
+			zero := 0.0
+			s.Minimum = &zero
+		case reflect.Float32, reflect.Float64:
+			s.Type = "number"
+		case reflect.Slice, reflect.Array:
+			s.Type = "array"
+			s.Items = schemaFor(t.Elem(), "")
+		case reflect.Map:
+			s.Type = "object"
+			if t.Elem().Kind() != reflect.Interface {
+				s.AdditionalProperties = schemaFor(t.Elem(), "")
+			}
+		case reflect.Struct:
+			s.Type = "object"
+			s.Properties = make(map[string]*jsonSchema)
+			s.AdditionalProperties = false
+			for i := 0; i < t.NumField(); i++ {
+				sf := t.Field(i)
+				key := configKey(sf)
+				if key == "" {
+					continue
+				}
+				s.Properties[key] = schemaFor(sf.Type, joinFieldPath(path, sf.Name))
+			}
+		}
+	}
+	if b, ok := configSchemaBounds[path]; ok {
+		if b.exclusive {
+			s.ExclusiveMinimum = b.min
+		} else {
+			s.Minimum = b.min
+		}
+		s.Maximum = b.max
+	}
+	return s
+}
+
+// configKey returns the key a field is read from in config files, or ""
+// for fields that are never read.
+func configKey(sf reflect.StructField) string {
+	if !sf.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+	if name == "" {
+		name, _, _ = strings.Cut(sf.Tag.Get("json"), ",")
+	}
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+func joinFieldPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// ConfigLoadOption configures LoadConfig.
+type ConfigLoadOption func(*configLoadOptions)
+
+type configLoadOptions struct {
+	strict bool
+}
+
+// WithStrictKeys makes LoadConfig fail on keys that match no Config field,
+// instead of ignoring them and silently keeping the default.
+func WithStrictKeys() ConfigLoadOption {
+	return func(o *configLoadOptions) { o.strict = true }
+}
+
+// ValidateFile checks the config file at path without applying the
+// environment: unknown keys, with the closest valid key suggested, and
+// every issue ValidateReport finds in the resulting configuration. The
+// error is reserved for files that cannot be read or parsed.
+func ValidateFile(path string) (*ValidationReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	config := DefaultConfig()
+	if err := decodeConfig(path, data, config); err != nil {
+		return nil, err
+	}
+	report, err := checkConfigKeys(path, data)
+	if err != nil {
+		return nil, err
+	}
+	report.Issues = append(report.Issues, config.ValidateReport().Issues...)
+	return report, nil
+}
+
+// checkConfigKeys reports every key in the file that matches no field.
+func checkConfigKeys(path string, data []byte) (*ValidationReport, error) {
+	var doc any
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &doc)
+	} else {
+		err = yaml.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	r := &ValidationReport{}
+	walkConfigKeys(r, doc, reflect.TypeOf(Config{}), "")
+	return r, nil
+}
+
+// walkConfigKeys compares the decoded document against t. Keys are visited
+// in sorted order so reports are stable.
+func walkConfigKeys(r *ValidationReport, node any, t reflect.Type, path string) {
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := node.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := make(map[string]reflect.StructField, t.NumField())
+		var valid []string
+		for i := 0; i < t.NumField(); i++ {
+			if key := configKey(t.Field(i)); key != "" {
+				fields[key] = t.Field(i)
+				valid = append(valid, key)
+			}
+		}
+		for _, key := range sortedKeys(m) {
+			keyPath := joinFieldPath(path, key)
+			sf, ok := fields[key]
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", keyPath)
+				if s := closestKey(key, valid); s != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", s)
+				}
+				r.addError(keyPath, msg)
+				continue
+			}
+			walkConfigKeys(r, m[key], sf.Type, keyPath)
+		}
+	case reflect.Map:
+		if m, ok := node.(map[string]any); ok {
+			for _, key := range sortedKeys(m) {
+				walkConfigKeys(r, m[key], t.Elem(), joinFieldPath(path, key))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if items, ok := node.([]any); ok {
+			for i, item := range items {
+				walkConfigKeys(r, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closestKey returns the candidate nearest to key by edit distance, or ""
+// when none is close enough to be a plausible typo.
+func closestKey(key string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		if d := levenshtein(key, c); bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	limit := len(key) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	if bestDist < 0 || bestDist > limit {
+		return ""
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(min(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
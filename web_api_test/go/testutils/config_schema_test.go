@@ -0,0 +1,136 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfig_JSONSchema(t *testing.T) {
+	first, err := (&Config{}).JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := DefaultConfig().JSONSchema()
+	if !bytes.Equal(first, second) {
+		t.Fatal("schema output is not stable")
+	}
+
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Additional *bool  `json:"additionalProperties"`
+		Properties map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(first, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Schema != configSchemaURI || schema.Additional == nil || *schema.Additional {
+		t.Errorf("root: $schema=%q additionalProperties=%v", schema.Schema, schema.Additional)
+	}
+
+	logger := schema.Properties["logger"].Properties
+	if enum, _ := logger["default_level"]["enum"].([]any); len(enum) != 2*len(logLevelNames) || enum[0] != "TRACE" {
+		t.Errorf("default_level enum = %v", logger["default_level"]["enum"])
+	}
+	pc := schema.Properties["port_checker"].Properties
+	if pc["min_port"]["minimum"] != float64(1) || pc["min_port"]["maximum"] != float64(65535) {
+		t.Errorf("min_port = %v", pc["min_port"])
+	}
+	if pc["protocol"]["enum"] == nil || pc["ip_version"]["type"] != "integer" {
+		t.Errorf("protocol = %v, ip_version = %v", pc["protocol"], pc["ip_version"])
+	}
+	if pc["dial_timeout"]["pattern"] != durationPattern {
+		t.Errorf("dial_timeout = %v", pc["dial_timeout"])
+	}
+	timer := schema.Properties["timer"].Properties
+	if timer["default_precision"]["exclusiveMinimum"] != float64(0) {
+		t.Errorf("default_precision = %v", timer["default_precision"])
+	}
+}
+
+func TestLoadConfig_StrictKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+app_name: demo
+logger:
+  defualt_level: DEBUG
+retry:
+  attempts: 5
+  jiter_factor: 0.2
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("lenient load: %v", err)
+	}
+	if cfg.Logger.DefaultLevel != DefaultConfig().Logger.DefaultLevel {
+		t.Errorf("typo'd key applied: %v", cfg.Logger.DefaultLevel)
+	}
+
+	_, err = LoadConfig(path, WithStrictKeys())
+	if err == nil {
+		t.Fatal("strict load accepted unknown keys")
+	}
+	for _, want := range []string{
+		`unknown key "logger.defualt_level" (did you mean "default_level"?)`,
+		`unknown key "retry.jiter_factor" (did you mean "jitter_factor"?)`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{
+		"app_name": "",
+		"port_checker": {"workers": 4, "zzz": 1},
+		"paths": {"import_paths": ["a"]}
+	}`)
+	report, err := ValidateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.HasIssue("port_checker.zzz") || !report.HasIssue("AppName") {
+		t.Errorf("issues = %+v", report.Issues)
+	}
+	for _, issue := range report.Issues {
+		if issue.FieldPath == "port_checker.zzz" && strings.Contains(issue.Message, "did you mean") {
+			t.Errorf("implausible suggestion: %s", issue.Message)
+		}
+	}
+
+	if _, err := ValidateFile(writeConfigFile(t, "bad.yaml", "logger: [")); err == nil {
+		t.Error("unparsable file did not fail")
+	}
+}
+
+func TestClosestKey(t *testing.T) {
+	keys := []string{"attempts", "initial_delay", "max_delay"}
+	tests := map[string]string{
+		"attempt":      "attempts",
+		"inital_delay": "initial_delay",
+		"max_dealy":    "max_delay",
+		"color":        "",
+	}
+	for in, want := range tests {
+		if got := closestKey(in, keys); got != want {
+			t.Errorf("closestKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if d := levenshtein("kitten", "sitting"); d != 3 {
+		t.Errorf("levenshtein = %d", d)
+	}
+}
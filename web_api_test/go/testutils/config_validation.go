@@ -0,0 +1,131 @@
+package testutils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Severity classifies a validation issue.
+type Severity int
+
+const (
+	// SeverityWarning marks a legal but suspicious setting.
+	SeverityWarning Severity = iota
+	// SeverityError marks a setting that makes the configuration unusable.
+	SeverityError
+)
+
+// String returns string representation
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// ValidationIssue is a single finding from Config.ValidateReport.
+type ValidationIssue struct {
+	FieldPath string   `json:"field_path"` // Go field path, e.g. "Retry.MaxDelay"; file key path for unknown keys
+	Message   string   `json:"message"`
+	Severity  Severity `json:"severity"`
+}
+
+// ValidationReport collects validation issues. It satisfies error so it can
+// be returned from Validate; Error() keeps the historical
+// "validation errors: a; b" format and lists error-severity issues only.
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+}
+
+func (r *ValidationReport) addError(path, msg string) {
+	r.Issues = append(r.Issues, ValidationIssue{FieldPath: path, Message: msg, Severity: SeverityError})
+}
+
+func (r *ValidationReport) addWarning(path, msg string) {
+	r.Issues = append(r.Issues, ValidationIssue{FieldPath: path, Message: msg, Severity: SeverityWarning})
+}
+
+// Error implements error.
+func (r *ValidationReport) Error() string {
+	var msgs []string
+	for _, issue := range r.Errors() {
+		msgs = append(msgs, issue.Message)
+	}
+	return fmt.Sprintf("validation errors: %s", strings.Join(msgs, "; "))
+}
+
+// Errors returns the error-severity issues.
+func (r *ValidationReport) Errors() []ValidationIssue {
+	return r.bySeverity(SeverityError)
+}
+
+// Warnings returns the warning-severity issues.
+func (r *ValidationReport) Warnings() []ValidationIssue {
+	return r.bySeverity(SeverityWarning)
+}
+
+// HasErrors reports whether any error-severity issue was found.
+func (r *ValidationReport) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// HasIssue reports whether an issue was recorded for the field path.
+func (r *ValidationReport) HasIssue(fieldPath string) bool {
+	for _, issue := range r.Issues {
+		if issue.FieldPath == fieldPath {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ValidationReport) bySeverity(sev Severity) []ValidationIssue {
+	var out []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == sev {
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+// Diff returns the sorted Go field paths (e.g. "Logger.DefaultLevel") whose
+// values differ between c and other. Maps and slices are compared as a whole.
+func (c *Config) Diff(other *Config) []string {
+	if other == nil {
+		other = &Config{}
+	}
+	var paths []string
+	diffStructs("", reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem(), &paths)
+	sort.Strings(paths)
+	return paths
+}
+
+// diffStructs recursively compares two struct values field by field
+func diffStructs(prefix string, a, b reflect.Value, paths *[]string) {
+	t := a.Type()
+	for i := 0; i < a.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		path := sf.Name
+		if prefix != "" {
+			path = prefix + "." + sf.Name
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct {
+			diffStructs(path, fa, fb, paths)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*paths = append(*paths, path)
+		}
+	}
+}
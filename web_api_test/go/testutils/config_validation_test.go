@@ -0,0 +1,113 @@
+package testutils
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_ValidateDefault(t *testing.T) {
+	cfg := DefaultConfig()
+	report := cfg.ValidateReport()
+	if report.HasErrors() || len(report.Warnings()) != 0 {
+		t.Fatalf("the default config must validate cleanly: %+v", report.Issues)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestConfig_ValidateReportErrors(t *testing.T) {
+	for _, tc := range []struct {
+		field  string
+		mutate func(*Config)
+	}{
+		{"AppName", func(c *Config) { c.AppName = "" }},
+		{"Logger.MaxBackups", func(c *Config) { c.Logger.MaxBackups = -1 }},
+		{"PortChecker.MaxPort", func(c *Config) { c.PortChecker.MaxPort = 70000 }},
+		{"PortChecker.MinPort", func(c *Config) { c.PortChecker.MinPort, c.PortChecker.MaxPort = 9000, 8000 }},
+		{"PortChecker.LocalAddr", func(c *Config) { c.PortChecker.LocalAddr = "not an address:x" }},
+		{"Retry.MaxDelay", func(c *Config) { c.Retry.MaxDelay = c.Retry.InitialDelay - 1 }},
+		{"Retry.JitterFactor", func(c *Config) { c.Retry.JitterFactor = 1.5 }},
+		{"Retry.RetryableCodes", func(c *Config) { c.Retry.RetryableCodes = []int{503, 42} }},
+		{"Concurrency.QueueSize", func(c *Config) { c.Concurrency.QueueSize = 0 }},
+		{"Metrics.MetricsPort", func(c *Config) { c.Metrics.Enabled, c.Metrics.MetricsPort = true, 0 }},
+		{"Logger.Sinks[1].Format", func(c *Config) {
+			c.Logger.Sinks = []LogSinkConfig{{Output: "stdout"}, {Output: "stderr", Format: "xml"}}
+		}},
+	} {
+		cfg := DefaultConfig()
+		tc.mutate(cfg)
+		report := cfg.ValidateReport()
+		errs := report.Errors()
+		if len(errs) != 1 || errs[0].FieldPath != tc.field {
+			t.Errorf("%s: got errors %+v", tc.field, errs)
+			continue
+		}
+		err := cfg.Validate()
+		var got *ValidationReport
+		if !errors.As(err, &got) || !strings.Contains(err.Error(), errs[0].Message) {
+			t.Errorf("%s: Validate returned %v", tc.field, err)
+		}
+	}
+}
+
+func TestConfig_ValidateReportWarnings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Metrics.Enabled, cfg.Metrics.EnablePrometheus, cfg.Metrics.EnableStatsD = true, false, false
+	cfg.Retry.Timeout = cfg.Retry.InitialDelay / 2
+	cfg.PortChecker.OperationTimeout = time.Second
+	cfg.PortChecker.DialTimeout = 2 * time.Second
+
+	report := cfg.ValidateReport()
+	if report.HasErrors() {
+		t.Fatalf("warnings alone reported errors: %+v", report.Errors())
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("warnings must not fail Validate: %v", err)
+	}
+	var fields []string
+	for _, issue := range report.Warnings() {
+		if issue.Severity != SeverityWarning {
+			t.Errorf("%s: severity %v", issue.FieldPath, issue.Severity)
+		}
+		fields = append(fields, issue.FieldPath)
+	}
+	want := []string{"Metrics.Enabled", "Retry.Timeout", "PortChecker.DialTimeout"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("warnings on %v, want %v", fields, want)
+	}
+	if !report.HasIssue("Retry.Timeout") || report.HasIssue("Retry.MaxDelay") {
+		t.Error("HasIssue does not match the recorded field paths")
+	}
+}
+
+func TestValidationReport_Error(t *testing.T) {
+	r := &ValidationReport{}
+	r.addWarning("Retry.Timeout", "ignored")
+	r.addError("AppName", "AppName cannot be empty")
+	r.addError("Retry.Attempts", "Retry Attempts must be >= 1")
+
+	if got, want := r.Error(), "validation errors: AppName cannot be empty; Retry Attempts must be >= 1"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if SeverityError.String() != "error" || Severity(7).String() != "Severity(7)" {
+		t.Errorf("severity strings %q, %q", SeverityError, Severity(7))
+	}
+}
+
+func TestConfig_Diff(t *testing.T) {
+	a, b := DefaultConfig(), DefaultConfig()
+	if d := a.Diff(b); len(d) != 0 {
+		t.Fatalf("identical configs differ on %v", d)
+	}
+	b.AppName = "other"
+	b.Retry.Attempts++
+	b.Metrics.DefaultLabels = map[string]string{"env": "ci"}
+	want := []string{"AppName", "Metrics.DefaultLabels", "Retry.Attempts"}
+	if d := a.Diff(b); !reflect.DeepEqual(d, want) {
+		t.Errorf("Diff = %v, want %v", d, want)
+	}
+}
@@ -0,0 +1,223 @@
+package testutils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// DBFixture – seeding and resetting PostgreSQL between tests
+// ------------------------------------------------------------------------
+
+// DBFixture seeds and resets a PostgreSQL database through database/sql.
+// The caller opens the *sql.DB with whatever driver it uses; the fixture
+// only issues SQL, plus pg_dump/pg_restore inside the container when a
+// DumpStrategy is configured.
+type DBFixture struct {
+	db     *sql.DB
+	logger *TestLogger
+	docker *DockerManager
+	dump   DumpStrategy
+}
+
+// DumpStrategy tells Snapshot and Restore where pg_dump runs. The dump is
+// kept inside the container, so no host tools or ports are needed.
+type DumpStrategy struct {
+	Service  string // compose service running postgres
+	User     string // passed to -U
+	Database string // passed to -d
+	Path     string // dump file inside the container (default /tmp/testutils-snapshot.dump)
+}
+
+// DBFixtureOption configures a DBFixture.
+type DBFixtureOption func(*DBFixture)
+
+// WithDBFixtureLogger logs loaded files and truncated tables to l.
+func WithDBFixtureLogger(l *TestLogger) DBFixtureOption {
+	return func(f *DBFixture) { f.logger = l }
+}
+
+// WithDumpStrategy enables Snapshot and Restore through dm.Exec.
+func WithDumpStrategy(dm *DockerManager, s DumpStrategy) DBFixtureOption {
+	return func(f *DBFixture) {
+		if s.Path == "" {
+			s.Path = "/tmp/testutils-snapshot.dump"
+		}
+		f.docker = dm
+		f.dump = s
+	}
+}
+
+// NewDBFixture creates a fixture on db.
+func NewDBFixture(db *sql.DB, opts ...DBFixtureOption) *DBFixture {
+	f := &DBFixture{db: db}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.logger == nil {
+		f.logger = NewTestLogger("db-fixture", os.Stderr, WithLevel(WARN))
+	}
+	return f
+}
+
+// LoadSQLFile executes the file at path in one transaction. The file is
+// sent as a single multi-statement query, so it may contain functions and
+// dollar-quoted bodies.
+func (f *DBFixture) LoadSQLFile(ctx context.Context, path string) error {
+	return f.loadFiles(ctx, []string{path})
+}
+
+// LoadSQLDir executes every *.sql file in dir in lexical order, all in one
+// transaction, so a failing file leaves the database untouched.
+func (f *DBFixture) LoadSQLDir(ctx context.Context, dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+	if err != nil {
+		return fmt.Errorf("failed to list SQL files in %s: %w", dir, err)
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no SQL files in %s", dir)
+	}
+	sort.Strings(paths)
+	return f.loadFiles(ctx, paths)
+}
+
+func (f *DBFixture) loadFiles(ctx context.Context, paths []string) error {
+	tx, err := f.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin seed transaction: %w", err)
+	}
+	defer tx.Rollback() // no-op after Commit
+
+	for _, path := range paths {
+		script, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read SQL file: %w", err)
+		}
+		start := time.Now()
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			return fmt.Errorf("failed to execute %s: %w", filepath.Base(path), err)
+		}
+		f.logger.Debug("SQL file loaded", map[string]any{
+			"file":     path,
+			"duration": time.Since(start).String(),
+		})
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit seed transaction: %w", err)
+	}
+	return nil
+}
+
+// listTablesQuery finds the user tables TruncateAll empties.
+const listTablesQuery = `SELECT table_schema, table_name FROM information_schema.tables
+WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+ORDER BY table_schema, table_name`
+
+// TruncateAll empties every user table except those named in except, as
+// "table" or "schema.table", and resets their sequences. CASCADE is used,
+// so a kept table that references a truncated one is emptied too.
+func (f *DBFixture) TruncateAll(ctx context.Context, except ...string) error {
+	tables, err := f.tables(ctx)
+	if err != nil {
+		return err
+	}
+	keep := make(map[string]bool, len(except))
+	for _, name := range except {
+		keep[name] = true
+	}
+
+	var quoted, names []string
+	for _, t := range tables {
+		if keep[t[1]] || keep[t[0]+"."+t[1]] {
+			continue
+		}
+		quoted = append(quoted, quoteIdent(t[0])+"."+quoteIdent(t[1]))
+		names = append(names, t[0]+"."+t[1])
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	stmt := "TRUNCATE TABLE " + strings.Join(quoted, ", ") + " RESTART IDENTITY CASCADE"
+	if _, err := f.db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to truncate tables: %w", err)
+	}
+	f.logger.Debug("tables truncated", map[string]any{"tables": names})
+	return nil
+}
+
+func (f *DBFixture) tables(ctx context.Context) ([][2]string, error) {
+	rows, err := f.db.QueryContext(ctx, listTablesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+	var out [][2]string
+	for rows.Next() {
+		var t [2]string
+		if err := rows.Scan(&t[0], &t[1]); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// quoteIdent quotes a PostgreSQL identifier.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Snapshot dumps the database inside its container with pg_dump in custom
+// format, overwriting the previous snapshot.
+func (f *DBFixture) Snapshot(ctx context.Context) error {
+	if f.docker == nil {
+		return fmt.Errorf("snapshot: no dump strategy configured")
+	}
+	_, err := f.docker.Exec(ctx, f.dump.Service, []string{
+		"pg_dump", "-U", f.dump.User, "-d", f.dump.Database, "-Fc", "-f", f.dump.Path,
+	}, ExecOptions{})
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database contents with the last Snapshot. It runs
+// in a single transaction, so a failed restore leaves the data as it was.
+func (f *DBFixture) Restore(ctx context.Context) error {
+	if f.docker == nil {
+		return fmt.Errorf("restore: no dump strategy configured")
+	}
+	_, err := f.docker.Exec(ctx, f.dump.Service, []string{
+		"pg_restore", "-U", f.dump.User, "-d", f.dump.Database,
+		"--clean", "--if-exists", "--single-transaction", f.dump.Path,
+	}, ExecOptions{})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	return nil
+}
+
+// Isolate begins a transaction that is rolled back when t finishes, so
+// whatever the test writes through it disappears. Code under test must use
+// the returned *sql.Tx rather than the pool for the isolation to hold.
+func (f *DBFixture) Isolate(t testing.TB) *sql.Tx {
+	t.Helper()
+	tx, err := f.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to begin isolation transaction: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := tx.Rollback(); err != nil && err != sql.ErrTxDone {
+			t.Errorf("failed to roll back isolation transaction: %v", err)
+		}
+	})
+	return tx
+}
@@ -0,0 +1,171 @@
+package testutils
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakePG is a database/sql driver that records statements and answers the
+// information_schema query with a fixed table list.
+type fakePG struct {
+	mu     sync.Mutex
+	log    []string
+	tables [][2]string
+	failOn string
+}
+
+func (f *fakePG) record(s string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, s)
+	if f.failOn != "" && strings.Contains(s, f.failOn) {
+		return errors.New("syntax error")
+	}
+	return nil
+}
+
+func (f *fakePG) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+func (f *fakePG) Connect(context.Context) (driver.Conn, error) { return fakePGConn{f}, nil }
+func (f *fakePG) Driver() driver.Driver                        { return nil }
+
+type fakePGConn struct{ db *fakePG }
+
+func (c fakePGConn) Prepare(q string) (driver.Stmt, error) { return fakePGStmt{c.db, q}, nil }
+func (c fakePGConn) Close() error                          { return nil }
+func (c fakePGConn) Begin() (driver.Tx, error)             { return fakePGTx{c.db}, c.db.record("BEGIN") }
+
+type fakePGTx struct{ db *fakePG }
+
+func (t fakePGTx) Commit() error   { return t.db.record("COMMIT") }
+func (t fakePGTx) Rollback() error { return t.db.record("ROLLBACK") }
+
+type fakePGStmt struct {
+	db *fakePG
+	q  string
+}
+
+func (s fakePGStmt) Close() error  { return nil }
+func (s fakePGStmt) NumInput() int { return -1 }
+func (s fakePGStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), s.db.record(s.q)
+}
+func (s fakePGStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fakePGRows{tables: s.db.tables}, s.db.record(s.q)
+}
+
+type fakePGRows struct{ tables [][2]string }
+
+func (r *fakePGRows) Columns() []string { return []string{"table_schema", "table_name"} }
+func (r *fakePGRows) Close() error      { return nil }
+func (r *fakePGRows) Next(dest []driver.Value) error {
+	if len(r.tables) == 0 {
+		return io.EOF
+	}
+	dest[0], dest[1] = r.tables[0][0], r.tables[0][1]
+	r.tables = r.tables[1:]
+	return nil
+}
+
+func newFakePGFixture(t *testing.T, pg *fakePG) *DBFixture {
+	db := sql.OpenDB(pg)
+	t.Cleanup(func() { db.Close() })
+	return NewDBFixture(db)
+}
+
+func writeSQLFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDBFixture_LoadSQLDir(t *testing.T) {
+	pg := &fakePG{}
+	dir := writeSQLFiles(t, map[string]string{
+		"02_users.sql":  "INSERT INTO users VALUES (1);",
+		"01_schema.sql": "CREATE TABLE users (id int);",
+		"README.md":     "not sql",
+	})
+	if err := newFakePGFixture(t, pg).LoadSQLDir(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"BEGIN", "CREATE TABLE users (id int);", "INSERT INTO users VALUES (1);", "COMMIT"}
+	if got := pg.statements(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("statements %q, want %q", got, want)
+	}
+}
+
+func TestDBFixture_LoadSQLDirRollsBack(t *testing.T) {
+	pg := &fakePG{failOn: "broken"}
+	dir := writeSQLFiles(t, map[string]string{
+		"01_ok.sql":     "CREATE TABLE a (id int);",
+		"02_broken.sql": "broken sql",
+	})
+	err := newFakePGFixture(t, pg).LoadSQLDir(context.Background(), dir)
+	if err == nil || !strings.Contains(err.Error(), "02_broken.sql") {
+		t.Fatalf("error %v does not name the failing file", err)
+	}
+	got := pg.statements()
+	if got[len(got)-1] != "ROLLBACK" || strings.Contains(strings.Join(got, "|"), "COMMIT") {
+		t.Errorf("statements %q", got)
+	}
+}
+
+func TestDBFixture_TruncateAll(t *testing.T) {
+	pg := &fakePG{tables: [][2]string{
+		{"audit", "events"},
+		{"public", "orders"},
+		{"public", "schema_migrations"},
+		{"public", "users"},
+	}}
+	f := newFakePGFixture(t, pg)
+	if err := f.TruncateAll(context.Background(), "public.schema_migrations", "events"); err != nil {
+		t.Fatal(err)
+	}
+	got := pg.statements()
+	want := `TRUNCATE TABLE "public"."orders", "public"."users" RESTART IDENTITY CASCADE`
+	if len(got) != 2 || got[0] != listTablesQuery || got[1] != want {
+		t.Errorf("statements %q", got)
+	}
+}
+
+func TestDBFixture_Isolate(t *testing.T) {
+	pg := &fakePG{}
+	f := newFakePGFixture(t, pg)
+	t.Run("inner", func(t *testing.T) {
+		tx := f.Isolate(t)
+		if _, err := tx.Exec("INSERT INTO users VALUES (2)"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	want := []string{"BEGIN", "INSERT INTO users VALUES (2)", "ROLLBACK"}
+	if got := pg.statements(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("statements %q, want %q", got, want)
+	}
+}
+
+func TestDBFixture_SnapshotNeedsStrategy(t *testing.T) {
+	f := newFakePGFixture(t, &fakePG{})
+	if err := f.Snapshot(context.Background()); err == nil {
+		t.Error("snapshot without a dump strategy succeeded")
+	}
+	if err := f.Restore(context.Background()); err == nil {
+		t.Error("restore without a dump strategy succeeded")
+	}
+}
@@ -0,0 +1,227 @@
+package testutils
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestDiskConformance runs the same behavioural suite against every Disk
+// meant to be interchangeable: the real file system, MemDisk, and MemDisk
+// behind a ModeAwareDisk in normal mode.
+func TestDiskConformance(t *testing.T) {
+	for name, newDisk := range map[string]func(t *testing.T) Disk{
+		"OSDisk":  func(t *testing.T) Disk { return NewOSDisk(t.TempDir()) },
+		"MemDisk": func(t *testing.T) Disk { return NewMemDisk() },
+		"ModeAwareDisk": func(t *testing.T) Disk {
+			return NewModeAwareDisk(NewMemDisk(), NewInMemoryModeManager(ModeNormal))
+		},
+	} {
+		t.Run(name, func(t *testing.T) { testDiskConformance(t, newDisk) })
+	}
+}
+
+func testDiskConformance(t *testing.T, newDisk func(t *testing.T) Disk) {
+	writeFile := func(t *testing.T, d Disk, name, data string) {
+		t.Helper()
+		f, err := d.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%q): %v", name, err)
+		}
+		if _, err := io.WriteString(f, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	readFile := func(t *testing.T, d Disk, name string) string {
+		t.Helper()
+		f, err := d.Open(name)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", name, err)
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("CreateReadStat", func(t *testing.T) {
+		d := newDisk(t)
+		writeFile(t, d, "a.txt", "hello")
+		if got := readFile(t, d, "a.txt"); got != "hello" {
+			t.Errorf("content = %q", got)
+		}
+		fi, err := d.Stat("a.txt")
+		if err != nil || fi.Name != "a.txt" || fi.Size != 5 || fi.IsDir {
+			t.Errorf("Stat = %+v, %v", fi, err)
+		}
+		writeFile(t, d, "a.txt", "hi")
+		if got := readFile(t, d, "a.txt"); got != "hi" {
+			t.Errorf("Create did not truncate: %q", got)
+		}
+		if _, err := d.Open("missing"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Open(missing) = %v", err)
+		}
+		if _, err := d.Stat("missing"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat(missing) = %v", err)
+		}
+	})
+
+	t.Run("OpenFileFlags", func(t *testing.T) {
+		d := newDisk(t)
+		if _, err := d.OpenFile("f", os.O_RDONLY, 0); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("open without O_CREATE = %v", err)
+		}
+		f, err := d.OpenFile("f", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, "abc")
+		if _, err := f.Read(make([]byte, 1)); err == nil {
+			t.Error("read from a write-only handle succeeded")
+		}
+		f.Close()
+		if _, err := d.OpenFile("f", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); !errors.Is(err, os.ErrExist) {
+			t.Errorf("O_EXCL on existing file = %v", err)
+		}
+
+		f, _ = d.OpenFile("f", os.O_WRONLY|os.O_APPEND, 0)
+		io.WriteString(f, "def")
+		f.Close()
+		if got := readFile(t, d, "f"); got != "abcdef" {
+			t.Errorf("after O_APPEND = %q", got)
+		}
+
+		f, _ = d.OpenFile("f", os.O_RDONLY, 0)
+		if _, err := f.Write([]byte("x")); err == nil {
+			t.Error("write to a read-only handle succeeded")
+		}
+		f.Close()
+
+		f, _ = d.OpenFile("f", os.O_RDWR|os.O_TRUNC, 0)
+		io.WriteString(f, "xy")
+		f.Close()
+		if got := readFile(t, d, "f"); got != "xy" {
+			t.Errorf("after O_TRUNC = %q", got)
+		}
+	})
+
+	t.Run("SeekAndIndependentHandles", func(t *testing.T) {
+		d := newDisk(t)
+		writeFile(t, d, "s", "0123456789")
+		f, _ := d.OpenFile("s", os.O_RDWR, 0)
+		defer f.Close()
+		if pos, err := f.Seek(-3, io.SeekEnd); err != nil || pos != 7 {
+			t.Fatalf("Seek = %d, %v", pos, err)
+		}
+		io.WriteString(f, "ABCDE")
+		g, _ := d.Open("s")
+		defer g.Close()
+		buf := make([]byte, 4)
+		io.ReadFull(g, buf)
+		if string(buf) != "0123" {
+			t.Errorf("second handle read %q", buf)
+		}
+		if got := readFile(t, d, "s"); got != "0123456ABCDE" {
+			t.Errorf("content = %q", got)
+		}
+		if fi, _ := f.Stat(); fi.Size != 12 {
+			t.Errorf("handle Stat size = %d", fi.Size)
+		}
+	})
+
+	t.Run("Directories", func(t *testing.T) {
+		d := newDisk(t)
+		if _, err := d.Create("dir/f"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Create in missing dir = %v", err)
+		}
+		if err := d.Mkdir("x/y", 0755); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Mkdir without parent = %v", err)
+		}
+		if err := d.MkdirAll("x/y/z", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.MkdirAll("x/y", 0755); err != nil {
+			t.Errorf("MkdirAll of existing dir = %v", err)
+		}
+		if err := d.Mkdir("x", 0755); !errors.Is(err, os.ErrExist) {
+			t.Errorf("Mkdir existing = %v", err)
+		}
+		fi, err := d.Stat("x/y")
+		if err != nil || !fi.IsDir || fi.Name != "y" || !fi.Mode.IsDir() {
+			t.Errorf("Stat dir = %+v, %v", fi, err)
+		}
+		writeFile(t, d, "x/file", "data")
+		if err := d.MkdirAll("x/file/sub", 0755); err == nil {
+			t.Error("MkdirAll through a file succeeded")
+		}
+		if err := d.Remove("x"); err == nil {
+			t.Error("Remove of non-empty dir succeeded")
+		}
+		if err := d.Remove("x/y/z"); err != nil {
+			t.Errorf("Remove empty dir = %v", err)
+		}
+		if err := d.Remove("x/file"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Stat("x/file"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat after Remove = %v", err)
+		}
+		if err := d.Remove("x/file"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("second Remove = %v", err)
+		}
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		d := newDisk(t)
+		writeFile(t, d, "a", "A")
+		writeFile(t, d, "b", "B")
+		if err := d.Rename("a", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, d, "b"); got != "A" {
+			t.Errorf("Rename did not replace: %q", got)
+		}
+		if _, err := d.Stat("a"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("old name still exists: %v", err)
+		}
+		if err := d.Rename("missing", "c"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Rename missing = %v", err)
+		}
+		if err := d.Rename("b", "nodir/b"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Rename into missing dir = %v", err)
+		}
+
+		d.MkdirAll("src/sub", 0755)
+		writeFile(t, d, "src/sub/f", "nested")
+		if err := d.Rename("src", "dst"); err != nil {
+			t.Fatal(err)
+		}
+		if got := readFile(t, d, "dst/sub/f"); got != "nested" {
+			t.Errorf("moved file = %q", got)
+		}
+		if _, err := d.Stat("src/sub"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("old dir still exists: %v", err)
+		}
+		if err := d.Rename("b", "dst"); err == nil {
+			t.Error("Rename of a file over a directory succeeded")
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		d := newDisk(t)
+		f, _ := d.Create("c")
+		f.Close()
+		if _, err := f.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("Write after Close = %v", err)
+		}
+		if err := f.Close(); !errors.Is(err, os.ErrClosed) {
+			t.Errorf("second Close = %v", err)
+		}
+	})
+}
@@ -0,0 +1,585 @@
+package testutils
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// MemDisk – in-memory file system with os-like semantics.
+// --------------------------------------------------------------------
+
+// MemDisk implements Disk in memory with enough fidelity to stand in for
+// OSDisk: directories must exist before files are created in them,
+// OpenFile honours O_RDONLY/O_WRONLY/O_RDWR, O_CREATE, O_EXCL, O_TRUNC and
+// O_APPEND, every open returns an independent handle with its own offset,
+// and Rename replaces files and moves whole directories. Errors are
+// *os.PathError or *os.LinkError wrapping os.ErrNotExist, os.ErrExist and
+// friends, so errors.Is works as it does against the real disk.
+//
+// Unlike InMemoryDisk, handles do not share a read position and Seek
+// works. Unix semantics apply to removed files: open handles keep working.
+//
+// For storage-pressure tests a MemDisk can be given a byte capacity, a file
+// (inode) limit and per-operation latencies; see SetCapacity, SetMaxFiles
+// and SetLatency.
+type MemDisk struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode // cleaned path → node; "." is the root
+	clock Clock
+
+	capacity int64 // bytes; 0 is unlimited
+	maxFiles int   // files and directories; 0 is unlimited
+	latency  map[DiskOp]DiskLatency
+	rng      *rand.Rand // latency source, seeded for reproducible runs
+}
+
+type memNode struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (n *memNode) isDir() bool { return n.mode.IsDir() }
+
+// NewMemDisk returns an empty disk holding only the root directory.
+func NewMemDisk() *MemDisk {
+	d := &MemDisk{
+		nodes:   make(map[string]*memNode),
+		clock:   RealClock{},
+		latency: make(map[DiskOp]DiskLatency),
+		rng:     rand.New(rand.NewSource(0)),
+	}
+	d.nodes["."] = &memNode{mode: os.ModeDir | 0755, modTime: d.clock.Now()}
+	return d
+}
+
+// SetClock sets the clock used for modification times.
+func (d *MemDisk) SetClock(clock Clock) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clock = clock
+}
+
+// cleanPath turns a slash-separated name into a key of nodes.
+func cleanPath(name string) string {
+	p := path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// parentDir checks that the parent of p exists and is a directory. Callers
+// hold d.mu.
+func (d *MemDisk) parentDir(op, name, p string) error {
+	parent, ok := d.nodes[path.Dir(p)]
+	if !ok {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if !parent.isDir() {
+		return &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return nil
+}
+
+func (d *MemDisk) Open(name string) (File, error) {
+	return d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (d *MemDisk) Create(name string) (File, error) {
+	return d.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (d *MemDisk) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	d.pause(DiskOpOpen)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := cleanPath(name)
+	access := flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR)
+	writable := access == os.O_WRONLY || access == os.O_RDWR
+
+	node, ok := d.nodes[p]
+	switch {
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	case ok && node.isDir() && writable:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case !ok:
+		if err := d.parentDir("open", name, p); err != nil {
+			return nil, err
+		}
+		if d.filesFull() {
+			return nil, &os.PathError{Op: "open", Path: name, Err: ErrDiskFull}
+		}
+		node = &memNode{mode: perm.Perm(), modTime: d.clock.Now()}
+		d.nodes[p] = node
+	}
+	if flag&os.O_TRUNC != 0 && writable && !node.isDir() {
+		node.data = nil
+		node.modTime = d.clock.Now()
+	}
+	return &memFile{
+		disk:     d,
+		node:     node,
+		name:     name,
+		readable: access != os.O_WRONLY,
+		writable: writable,
+		append:   flag&os.O_APPEND != 0,
+	}, nil
+}
+
+// children returns the paths directly or indirectly under dir. Callers
+// hold d.mu.
+func (d *MemDisk) children(dir string) []string {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	var out []string
+	for p := range d.nodes {
+		if p != "." && p != dir && strings.HasPrefix(p, prefix) {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (d *MemDisk) Remove(name string) error {
+	d.pause(DiskOpRemove)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := cleanPath(name)
+	node, ok := d.nodes[p]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if p == "." || (node.isDir() && len(d.children(p)) > 0) {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(d.nodes, p)
+	return nil
+}
+
+func (d *MemDisk) Rename(oldpath, newpath string) error {
+	d.pause(DiskOpRename)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	from, to := cleanPath(oldpath), cleanPath(newpath)
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	src, ok := d.nodes[from]
+	if !ok {
+		return linkErr(os.ErrNotExist)
+	}
+	if from == to {
+		return nil
+	}
+	if err := d.parentDir("rename", newpath, to); err != nil {
+		return linkErr(os.ErrNotExist)
+	}
+	if src.isDir() && strings.HasPrefix(to, from+"/") {
+		return linkErr(syscall.EINVAL)
+	}
+	if dst, ok := d.nodes[to]; ok {
+		switch {
+		case dst.isDir() && !src.isDir():
+			return linkErr(syscall.EISDIR)
+		case !dst.isDir() && src.isDir():
+			return linkErr(syscall.ENOTDIR)
+		case dst.isDir() && len(d.children(to)) > 0:
+			return linkErr(syscall.ENOTEMPTY)
+		}
+	}
+	if src.isDir() {
+		for _, child := range d.children(from) {
+			d.nodes[to+strings.TrimPrefix(child, from)] = d.nodes[child]
+			delete(d.nodes, child)
+		}
+	}
+	d.nodes[to] = src
+	delete(d.nodes, from)
+	return nil
+}
+
+func (d *MemDisk) Stat(name string) (FileInfo, error) {
+	d.pause(DiskOpStat)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := cleanPath(name)
+	node, ok := d.nodes[p]
+	if !ok {
+		return FileInfo{}, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return node.info(path.Base(p)), nil
+}
+
+func (n *memNode) info(base string) FileInfo {
+	return FileInfo{
+		Name:    base,
+		Size:    int64(len(n.data)),
+		Mode:    n.mode,
+		ModTime: n.modTime,
+		IsDir:   n.isDir(),
+	}
+}
+
+func (d *MemDisk) Mkdir(name string, perm os.FileMode) error {
+	d.pause(DiskOpMkdir)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := cleanPath(name)
+	if _, ok := d.nodes[p]; ok {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	if err := d.parentDir("mkdir", name, p); err != nil {
+		return err
+	}
+	if d.filesFull() {
+		return &os.PathError{Op: "mkdir", Path: name, Err: ErrDiskFull}
+	}
+	d.nodes[p] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: d.clock.Now()}
+	return nil
+}
+
+func (d *MemDisk) MkdirAll(name string, perm os.FileMode) error {
+	d.pause(DiskOpMkdir)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := cleanPath(name)
+	if p == "." {
+		return nil
+	}
+	dir := ""
+	for _, part := range strings.Split(p, "/") {
+		dir = path.Join(dir, part)
+		node, ok := d.nodes[dir]
+		if !ok {
+			if d.filesFull() {
+				return &os.PathError{Op: "mkdir", Path: dir, Err: ErrDiskFull}
+			}
+			d.nodes[dir] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: d.clock.Now()}
+			continue
+		}
+		if !node.isDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil
+}
+
+// memFile is an open handle on a MemDisk node. Data lives in the node and
+// is guarded by the disk's mutex; the offset belongs to the handle.
+type memFile struct {
+	disk     *MemDisk
+	node     *memNode
+	name     string
+	offset   int64
+	readable bool
+	writable bool
+	append   bool
+	closed   bool
+}
+
+func (f *memFile) check(op string) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.disk.pause(DiskOpRead)
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	if err := f.check("read"); err != nil {
+		return 0, err
+	}
+	if !f.readable {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+	if f.node.isDir() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.disk.pause(DiskOpWrite)
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	if err := f.check("write"); err != nil {
+		return 0, err
+	}
+	if !f.writable {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+	}
+	if f.append {
+		f.offset = int64(len(f.node.data))
+	}
+	// Like a full device, store what fits and report ENOSPC for the rest.
+	var err error
+	if avail := f.disk.available(); avail >= 0 {
+		growth := f.offset + int64(len(p)) - int64(len(f.node.data))
+		if growth > avail {
+			fits := int64(len(p)) - (growth - avail)
+			if fits < 0 {
+				fits = 0
+			}
+			p = p[:fits]
+			err = &os.PathError{Op: "write", Path: f.name, Err: ErrDiskFull}
+		}
+	}
+	if len(p) == 0 {
+		return 0, err
+	}
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		f.node.resize(end)
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = f.disk.clock.Now()
+	return len(p), err
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	if err := f.check("seek"); err != nil {
+		return 0, err
+	}
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = f.offset + offset
+	case io.SeekEnd:
+		abs = int64(len(f.node.data)) + offset
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	if abs < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.offset = abs
+	return abs, nil
+}
+
+func (f *memFile) Close() error {
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	if err := f.check("close"); err != nil {
+		return err
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	f.disk.pause(DiskOpSync)
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	return f.check("sync")
+}
+
+func (f *memFile) Stat() (FileInfo, error) {
+	f.disk.mu.Lock()
+	defer f.disk.mu.Unlock()
+	if err := f.check("stat"); err != nil {
+		return FileInfo{}, err
+	}
+	return f.node.info(path.Base(cleanPath(f.name))), nil
+}
+
+func (f *memFile) Name() string { return f.name }
+
+// resize grows data with zeros or cuts it to size.
+func (n *memNode) resize(size int64) {
+	if size <= int64(len(n.data)) {
+		n.data = n.data[:size]
+		return
+	}
+	grown := make([]byte, size)
+	copy(grown, n.data)
+	n.data = grown
+}
+
+// --------------------------------------------------------------------
+// Storage pressure – capacity, file limits and latency.
+// --------------------------------------------------------------------
+
+// ErrDiskFull is the error inside the *os.PathError a MemDisk returns when
+// a write exceeds its capacity or a new file or directory exceeds its file
+// limit. It matches syscall.ENOSPC and ErrQuotaExceeded with errors.Is.
+var ErrDiskFull error = diskFullError{}
+
+type diskFullError struct{}
+
+func (diskFullError) Error() string { return "no space left on device" }
+
+func (diskFullError) Is(target error) bool {
+	return target == syscall.ENOSPC || target == ErrQuotaExceeded
+}
+
+// DiskOp names a MemDisk operation for SetLatency.
+type DiskOp string
+
+const (
+	DiskOpOpen   DiskOp = "open" // Open, Create and OpenFile
+	DiskOpRead   DiskOp = "read"
+	DiskOpWrite  DiskOp = "write" // Write and Truncate
+	DiskOpSync   DiskOp = "sync"
+	DiskOpStat   DiskOp = "stat"
+	DiskOpRemove DiskOp = "remove"
+	DiskOpRename DiskOp = "rename"
+	DiskOpMkdir  DiskOp = "mkdir" // Mkdir and MkdirAll
+)
+
+// DiskLatency is a uniform latency distribution over [Min, Max]. A zero Max
+// (or Max below Min) means a fixed Min.
+type DiskLatency struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+// SetCapacity limits the bytes stored across all files; 0 removes the
+// limit. Lowering it below Usage does not drop data, but every growing
+// write then fails.
+func (d *MemDisk) SetCapacity(bytes int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.capacity = bytes
+}
+
+// SetMaxFiles limits the number of files and directories, like an inode
+// table; 0 removes the limit.
+func (d *MemDisk) SetMaxFiles(n int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.maxFiles = n
+}
+
+// SetLatency delays every op by a duration drawn from lat, slept on the
+// disk's clock before the operation takes the disk's lock.
+func (d *MemDisk) SetLatency(op DiskOp, lat DiskLatency) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latency[op] = lat
+}
+
+// SetLatencySeed reseeds the source latencies are drawn from. The default
+// seed is 0, so runs are reproducible either way.
+func (d *MemDisk) SetLatencySeed(seed int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rng = rand.New(rand.NewSource(seed))
+}
+
+// Usage returns the bytes stored and the number of files and directories,
+// not counting the root. Data of removed files still open is not counted.
+func (d *MemDisk) Usage() (bytes int64, files int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.usedBytes(), len(d.nodes) - 1
+}
+
+// Truncate changes the size of the named file, as os.Truncate does.
+// Growing it counts against the capacity.
+func (d *MemDisk) Truncate(name string, size int64) error {
+	d.pause(DiskOpWrite)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	node, ok := d.nodes[cleanPath(name)]
+	switch {
+	case !ok:
+		return &os.PathError{Op: "truncate", Path: name, Err: os.ErrNotExist}
+	case node.isDir():
+		return &os.PathError{Op: "truncate", Path: name, Err: syscall.EISDIR}
+	case size < 0:
+		return &os.PathError{Op: "truncate", Path: name, Err: syscall.EINVAL}
+	}
+	if avail := d.available(); avail >= 0 && size-int64(len(node.data)) > avail {
+		return &os.PathError{Op: "truncate", Path: name, Err: ErrDiskFull}
+	}
+	node.resize(size)
+	node.modTime = d.clock.Now()
+	return nil
+}
+
+// EvictAll removes every file and directory, as a cleanup job emptying the
+// disk would, and returns what was freed. Open handles keep their data.
+func (d *MemDisk) EvictAll() (bytes int64, files int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bytes, files = d.usedBytes(), len(d.nodes)-1
+	for p := range d.nodes {
+		if p != "." {
+			delete(d.nodes, p)
+		}
+	}
+	return bytes, files
+}
+
+// usedBytes sums file sizes. Callers hold d.mu.
+func (d *MemDisk) usedBytes() int64 {
+	var used int64
+	for _, n := range d.nodes {
+		used += int64(len(n.data))
+	}
+	return used
+}
+
+// available returns the free bytes, or -1 when capacity is unlimited.
+// Callers hold d.mu.
+func (d *MemDisk) available() int64 {
+	if d.capacity <= 0 {
+		return -1
+	}
+	if free := d.capacity - d.usedBytes(); free > 0 {
+		return free
+	}
+	return 0
+}
+
+// filesFull reports whether another file or directory would exceed the
+// file limit. Callers hold d.mu.
+func (d *MemDisk) filesFull() bool {
+	return d.maxFiles > 0 && len(d.nodes)-1 >= d.maxFiles
+}
+
+// pause sleeps for op's configured latency.
+func (d *MemDisk) pause(op DiskOp) {
+	d.mu.Lock()
+	lat, ok := d.latency[op]
+	if !ok {
+		d.mu.Unlock()
+		return
+	}
+	delay := lat.Min
+	if lat.Max > lat.Min {
+		delay += time.Duration(d.rng.Int63n(int64(lat.Max-lat.Min) + 1))
+	}
+	clock := d.clock
+	d.mu.Unlock()
+	sleepWith(clock, delay)
+}
+
+var _ Disk = (*MemDisk)(nil)
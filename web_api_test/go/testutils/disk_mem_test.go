@@ -0,0 +1,133 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMemDisk_Capacity(t *testing.T) {
+	d := NewMemDisk()
+	d.SetCapacity(10)
+	f, _ := d.Create("a")
+	n, err := io.WriteString(f, "0123456")
+	if n != 7 || err != nil {
+		t.Fatalf("first write = %d, %v", n, err)
+	}
+	n, err = io.WriteString(f, "789ab")
+	if n != 3 || !errors.Is(err, syscall.ENOSPC) || !errors.Is(err, ErrQuotaExceeded) || Kind(err) != KindQuotaExceeded {
+		t.Fatalf("write past capacity = %d, %v", n, err)
+	}
+	// Overwriting in place needs no space.
+	f.Seek(0, io.SeekStart)
+	if _, err := io.WriteString(f, "xyz"); err != nil {
+		t.Errorf("overwrite on a full disk: %v", err)
+	}
+	if bytes, files := d.Usage(); bytes != 10 || files != 1 {
+		t.Errorf("Usage = %d bytes, %d files", bytes, files)
+	}
+
+	if err := d.Truncate("a", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Truncate("a", 20); !errors.Is(err, ErrDiskFull) {
+		t.Errorf("growing Truncate = %v", err)
+	}
+	if bytes, _ := d.Usage(); bytes != 4 {
+		t.Errorf("after Truncate, %d bytes used", bytes)
+	}
+
+	d.MkdirAll("logs/old", 0755)
+	if bytes, files := d.EvictAll(); bytes != 4 || files != 3 {
+		t.Errorf("EvictAll freed %d bytes, %d files", bytes, files)
+	}
+	if bytes, files := d.Usage(); bytes != 0 || files != 0 {
+		t.Errorf("Usage after EvictAll = %d, %d", bytes, files)
+	}
+}
+
+func TestMemDisk_MaxFiles(t *testing.T) {
+	d := NewMemDisk()
+	d.SetMaxFiles(2)
+	if err := d.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Create("dir/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Create("dir/b"); !errors.Is(err, syscall.ENOSPC) {
+		t.Errorf("third inode = %v", err)
+	}
+	if err := d.MkdirAll("x/y", 0755); !errors.Is(err, ErrDiskFull) {
+		t.Errorf("MkdirAll past the limit = %v", err)
+	}
+	if _, err := d.Create("dir/a"); err != nil {
+		t.Errorf("recreating an existing file: %v", err)
+	}
+}
+
+func TestMemDisk_Latency(t *testing.T) {
+	run := func(seed int64) time.Duration {
+		clock := NewMockClock(time.Time{})
+		d := NewMemDisk()
+		d.SetClock(clock)
+		d.SetLatencySeed(seed)
+		d.SetLatency(DiskOpWrite, DiskLatency{Min: time.Millisecond, Max: 10 * time.Millisecond})
+		d.SetLatency(DiskOpSync, DiskLatency{Min: 50 * time.Millisecond})
+		start := clock.Now()
+		f, _ := d.Create("a")
+		for i := 0; i < 10; i++ {
+			f.Write([]byte("x"))
+		}
+		f.Sync()
+		return clock.Now().Sub(start)
+	}
+	took := run(3)
+	if took < 60*time.Millisecond || took > 150*time.Millisecond {
+		t.Errorf("took %v", took)
+	}
+	if again := run(3); again != took {
+		t.Errorf("same seed took %v then %v", took, again)
+	}
+}
+
+func TestModeAwareDisk_OverFullMemDisk(t *testing.T) {
+	mem := NewMemDisk()
+	mem.SetCapacity(1024)
+	disk := NewModeAwareDisk(mem, NewInMemoryModeManager(ModeNormal))
+
+	// Generate fixtures until the disk fills; the failure must be the
+	// deterministic quota error, at the same file every run.
+	var failedAt int
+	for i := 0; i < 100; i++ {
+		f, err := disk.Create(fmt.Sprintf("fixture-%02d.json", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.Write(make([]byte, 100))
+		f.Close()
+		if err != nil {
+			if !errors.Is(err, ErrDiskFull) {
+				t.Fatalf("write %d: %v", i, err)
+			}
+			failedAt = i
+			break
+		}
+	}
+	if failedAt != 10 {
+		t.Errorf("disk filled at write %d, want 10", failedAt)
+	}
+
+	mem.EvictAll()
+	f, err := disk.OpenFile("after-cleanup", os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(make([]byte, 100)); err != nil {
+		t.Errorf("write after EvictAll: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// --------------------------------------------------------------------
+// OSDisk – Disk backed by the real file system.
+// --------------------------------------------------------------------
+
+// OSDisk implements Disk with thin wrappers over package os. Names are
+// slash-separated and resolved under Root when it is set, so tests can
+// point it at t.TempDir(). Root is a convenience, not a sandbox: ".."
+// segments are not rejected.
+type OSDisk struct {
+	Root string
+}
+
+// NewOSDisk returns a disk rooted at root; an empty root uses names as is.
+func NewOSDisk(root string) *OSDisk {
+	return &OSDisk{Root: root}
+}
+
+func (d *OSDisk) path(name string) string {
+	name = filepath.FromSlash(name)
+	if d.Root == "" {
+		return name
+	}
+	return filepath.Join(d.Root, name)
+}
+
+func (d *OSDisk) Open(name string) (File, error) {
+	return d.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (d *OSDisk) Create(name string) (File, error) {
+	return d.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (d *OSDisk) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(d.path(name), flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{f: f, name: name}, nil
+}
+
+func (d *OSDisk) Remove(name string) error {
+	return os.Remove(d.path(name))
+}
+
+func (d *OSDisk) Rename(oldpath, newpath string) error {
+	return os.Rename(d.path(oldpath), d.path(newpath))
+}
+
+func (d *OSDisk) Stat(name string) (FileInfo, error) {
+	fi, err := os.Stat(d.path(name))
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return fileInfoFromOS(fi), nil
+}
+
+func (d *OSDisk) Mkdir(name string, perm os.FileMode) error {
+	return os.Mkdir(d.path(name), perm)
+}
+
+func (d *OSDisk) MkdirAll(name string, perm os.FileMode) error {
+	return os.MkdirAll(d.path(name), perm)
+}
+
+// osFile adapts *os.File to File. Name reports the name the file was opened
+// with, not the path under Root.
+type osFile struct {
+	f    *os.File
+	name string
+}
+
+func (f *osFile) Read(p []byte) (int, error)  { return f.f.Read(p) }
+func (f *osFile) Write(p []byte) (int, error) { return f.f.Write(p) }
+func (f *osFile) Seek(offset int64, whence int) (int64, error) {
+	return f.f.Seek(offset, whence)
+}
+func (f *osFile) Close() error { return f.f.Close() }
+func (f *osFile) Sync() error  { return f.f.Sync() }
+func (f *osFile) Name() string { return f.name }
+
+func (f *osFile) Stat() (FileInfo, error) {
+	fi, err := f.f.Stat()
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return fileInfoFromOS(fi), nil
+}
+
+func fileInfoFromOS(fi os.FileInfo) FileInfo {
+	return FileInfo{
+		Name:    fi.Name(),
+		Size:    fi.Size(),
+		Mode:    fi.Mode(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+}
+
+var _ Disk = (*OSDisk)(nil)
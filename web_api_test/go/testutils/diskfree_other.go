@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package testutils
+
+import "errors"
+
+// freeDiskBytes is not implemented on this platform; CheckDiskSpace warns.
+func freeDiskBytes(dir string) (uint64, error) {
+	return 0, errors.New("free space query not supported on this platform")
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package testutils
+
+import "syscall"
+
+// freeDiskBytes returns the space available to unprivileged users in the
+// file system holding dir.
+func freeDiskBytes(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -1,30 +1,51 @@
 package testutils
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
 // DockerManager handles Docker Compose operations.
 type DockerManager struct {
-	config     *config.TestConfig
-	dockerCfg  config.DockerConfig
-	logger     *test.TestLogger
-	cancelFunc context.CancelFunc
+	config    *config.TestConfig
+	dockerCfg config.DockerConfig
+	logger    *test.TestLogger
+	events    *EventBus
+
+	// project isolates this run's containers; see docker_compose.go.
+	project     string
+	dataManager *TestDataManager
+
+	startMu    sync.Mutex
+	cancelFunc context.CancelFunc // of the latest start's wait loops; guarded by startMu
+
+	renderMu     sync.Mutex
+	renderer     *composeRenderer
+	renderedFile string
+	presetPorts  map[string]int // from ComposeBuild.DockerOption
+	runner       CommandRunner
+
+	serviceChecks map[string][]string // see WithServiceChecks
+	checkOrder    []string            // services in serviceChecks, first use first
 }
 
-// NewDockerManager creates a new Docker manager instance.
-func NewDockerManager(cfg *config.TestConfig, logger *test.TestLogger) (*DockerManager, error) {
+// NewDockerManager creates a new Docker manager instance. The compose file
+// is rendered as a template on Start and every command runs under a
+// project name derived from the test ID, so parallel runs stay apart.
+func NewDockerManager(cfg *config.TestConfig, logger *test.TestLogger, opts ...DockerOption) (*DockerManager, error) {
 	if cfg == nil {
-		return nil, fmt.Errorf("test config cannot be nil")
+		return nil, kindErrorf(ErrValidation, "test config cannot be nil")
 	}
 	if cfg.DockerConfig.ComposePath == "" {
-		return nil, fmt.Errorf("docker compose path not found")
+		return nil, kindErrorf(ErrValidation, "docker compose path not found")
 	}
 
 	// Ensure the directory exists
@@ -32,25 +53,51 @@ func NewDockerManager(cfg *config.TestConfig, logger *test.TestLogger) (*DockerM
 		return nil, fmt.Errorf("failed to create docker compose directory: %w", err)
 	}
 
-	return &DockerManager{
+	dm := &DockerManager{
 		config:    cfg,
 		dockerCfg: cfg.DockerConfig,
 		logger:    logger,
-	}, nil
+		project:   composeProjectName(cfg.TestID),
+		runner:    RealRunner{},
+	}
+	for _, opt := range opts {
+		opt(dm)
+	}
+	return dm, nil
+}
+
+// SetEventBus makes the manager publish docker_* lifecycle events to bus.
+func (dm *DockerManager) SetEventBus(bus *EventBus) {
+	dm.events = bus
 }
 
 // Start launches Docker containers and waits for services to be ready.
 // It accepts a context for cancellation support.
 func (dm *DockerManager) Start(ctx context.Context) error {
-	ctx, cancel := context.WithCancel(ctx)
-	dm.cancelFunc = cancel
+	return dm.StartServices(ctx)
+}
 
-	args := []string{"compose", "-f", dm.dockerCfg.ComposeFile}
-	if dm.dockerCfg.Network != "" {
-		args = append(args, "--project-name", dm.dockerCfg.Network)
+// StartServices launches only the named compose services (all services when
+// names is empty) and waits for the readiness checks belonging to them.
+func (dm *DockerManager) StartServices(ctx context.Context, names ...string) error {
+	if err := dm.prepareCompose(); err != nil {
+		return err
+	}
+	checks, err := dm.servicesFor(names)
+	if err != nil {
+		return err
 	}
 
-	args = append(args, "up", "-d")
+	// A previous start's wait loops belong to containers this call replaces.
+	ctx, cancel := context.WithCancel(ctx)
+	dm.startMu.Lock()
+	if dm.cancelFunc != nil {
+		dm.cancelFunc()
+	}
+	dm.cancelFunc = cancel
+	dm.startMu.Unlock()
+
+	args := []string{"up", "-d"}
 	if dm.dockerCfg.Build {
 		args = append(args, "--build")
 	}
@@ -60,30 +107,33 @@ func (dm *DockerManager) Start(ctx context.Context) error {
 	if dm.dockerCfg.RemoveOrphans {
 		args = append(args, "--remove-orphans")
 	}
+	args = append(args, names...)
 
-	dm.logger.Info("Starting Docker containers", "composeFile", dm.dockerCfg.ComposeFile)
-
-	// Create command with context to allow cancellation
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Dir = dm.dockerCfg.ComposePath
-	cmd.Stdout = dm.logger.Writer()
-	cmd.Stderr = dm.logger.Writer()
+	dm.logger.Info("Starting Docker containers", "composeFile", dm.composeFile(), "project", dm.project, "services", names)
+	dm.events.Emit(EventDockerStarting, "docker", map[string]any{"services": names})
 
-	if err := cmd.Run(); err != nil {
+	if _, err := dm.runCompose(ctx, args...); err != nil {
 		return fmt.Errorf("failed to start docker compose: %w", err)
 	}
 
-	return dm.waitForServices(ctx)
+	if err := dm.waitForServices(ctx, checks); err != nil {
+		return err
+	}
+	dm.events.Emit(EventDockerHealthy, "docker", map[string]any{"services": names})
+	return nil
 }
 
-// Stop terminates Docker containers and cleans up resources.
+// Stop terminates Docker containers and cleans up resources. Only the
+// containers of this manager's project are touched.
 func (dm *DockerManager) Stop() error {
 	// Cancel any ongoing wait loops if running
+	dm.startMu.Lock()
 	if dm.cancelFunc != nil {
 		dm.cancelFunc()
 	}
+	dm.startMu.Unlock()
 
-	args := []string{"compose", "-f", dm.dockerCfg.ComposeFile, "down"}
+	args := []string{"down"}
 	if dm.dockerCfg.RemoveOrphans {
 		args = append(args, "--remove-orphans")
 	}
@@ -91,24 +141,143 @@ func (dm *DockerManager) Stop() error {
 		args = append(args, "--volumes")
 	}
 
-	dm.logger.Info("Stopping Docker containers")
+	dm.logger.Info("Stopping Docker containers", "project", dm.project)
 
-	cmd := exec.Command("docker", args...)
-	cmd.Dir = dm.dockerCfg.ComposePath
-	cmd.Stdout = dm.logger.Writer()
-	cmd.Stderr = dm.logger.Writer()
+	if _, err := dm.runCompose(context.Background(), args...); err != nil {
+		return err
+	}
+	dm.events.Emit(EventDockerStopped, "docker", nil)
+	return nil
+}
+
+// StopServices stops and removes only the named compose services, leaving
+// the rest of the environment running.
+func (dm *DockerManager) StopServices(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return kindErrorf(ErrValidation, "no services given, use Stop to tear down the whole environment")
+	}
+
+	dm.logger.Info("Stopping Docker services", "services", names)
 
-	return cmd.Run()
+	if _, err := dm.runCompose(ctx, append([]string{"stop"}, names...)...); err != nil {
+		return fmt.Errorf("failed to stop services %v: %w", names, err)
+	}
+
+	args := []string{"rm", "-f"}
+	if dm.dockerCfg.RemoveVolumes {
+		args = append(args, "-v")
+	}
+	if _, err := dm.runCompose(ctx, append(args, names...)...); err != nil {
+		return fmt.Errorf("failed to remove services %v: %w", names, err)
+	}
+	dm.events.Emit(EventDockerStopped, "docker", map[string]any{"services": names})
+	return nil
 }
 
-// waitForServices verifies that all required services are accessible.
-func (dm *DockerManager) waitForServices(ctx context.Context) error {
-	if len(dm.dockerCfg.Services) == 0 {
+// composeService is one entry of `docker compose ps --format json`.
+type composeService struct {
+	Name    string `json:"Name"`
+	Service string `json:"Service"`
+	State   string `json:"State"`
+	Health  string `json:"Health"`
+}
+
+// IsServiceRunning reports whether the named compose service has a running
+// container.
+func (dm *DockerManager) IsServiceRunning(ctx context.Context, name string) (bool, error) {
+	out, err := dm.runCompose(ctx, "ps", "--format", "json", name)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect service %s: %w", name, err)
+	}
+
+	services, err := parseComposePS(out)
+	if err != nil {
+		return false, err
+	}
+	for _, svc := range services {
+		if svc.Service == name && svc.State == "running" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// parseComposePS handles both output shapes of `docker compose ps --format
+// json`: a single JSON array (older releases) or one object per line.
+func parseComposePS(out []byte) ([]composeService, error) {
+	out = bytes.TrimSpace(out)
+	if len(out) == 0 {
+		return nil, nil
+	}
+
+	var services []composeService
+	if out[0] == '[' {
+		if err := json.Unmarshal(out, &services); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+		}
+		return services, nil
+	}
+
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var svc composeService
+		if err := json.Unmarshal(line, &svc); err != nil {
+			return nil, fmt.Errorf("failed to parse docker compose ps output: %w", err)
+		}
+		services = append(services, svc)
+	}
+	return services, nil
+}
+
+// runCompose runs `docker compose` with the manager's file and project name.
+// The project directory stays the original compose directory so relative
+// build contexts and volumes resolve as before rendering. Stdout is
+// returned and both streams are mirrored to the logger; on failure the
+// captured stderr is included in the error.
+func (dm *DockerManager) runCompose(ctx context.Context, args ...string) ([]byte, error) {
+	return dm.runDocker(ctx, append(dm.composeArgs(), args...)...)
+}
+
+// composeArgs is the `docker compose` prefix shared by every invocation.
+func (dm *DockerManager) composeArgs() []string {
+	return []string{"compose",
+		"--project-name", dm.project,
+		"--project-directory", dm.dockerCfg.ComposePath,
+		"-f", dm.composeFile(),
+	}
+}
+
+// runDocker runs the docker CLI in the compose directory, capturing stdout
+// and including stderr in any error.
+func (dm *DockerManager) runDocker(ctx context.Context, args ...string) ([]byte, error) {
+	res, err := dm.runner.Run(ctx, CommandSpec{
+		Name:   "docker",
+		Args:   args,
+		Dir:    dm.dockerCfg.ComposePath,
+		Stdout: dm.logger.Writer(),
+		Stderr: dm.logger.Writer(),
+	})
+	if err != nil {
+		if msg := strings.TrimSpace(string(res.Stderr)); msg != "" {
+			return res.Stdout, fmt.Errorf("docker %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return res.Stdout, fmt.Errorf("docker %s: %w", strings.Join(args, " "), err)
+	}
+	return res.Stdout, nil
+}
+
+// waitForServices verifies that the given "host:port" readiness checks are
+// accessible.
+func (dm *DockerManager) waitForServices(ctx context.Context, services []string) error {
+	if len(services) == 0 {
 		dm.logger.Info("No services defined in config, skipping wait")
 		return nil
 	}
 
-	for _, service := range dm.dockerCfg.Services {
+	for _, service := range services {
 		dm.logger.Debug("Waiting for service", "service", service)
 
 		// Check for context cancellation before starting to wait
@@ -124,37 +293,76 @@ func (dm *DockerManager) waitForServices(ctx context.Context) error {
 	return nil
 }
 
+// servicesFor returns the readiness checks for the named compose services,
+// or every check when names is empty. A check belongs to the service it was
+// attributed to with WithServiceChecks, or else to the service its host
+// names, as in "db:5432". A check whose host is localhost or an IP address
+// cannot be attributed that way; starting a subset of services with such a
+// check configured is an error rather than a silently skipped wait.
+func (dm *DockerManager) servicesFor(names []string) ([]string, error) {
+	all := append([]string(nil), dm.dockerCfg.Services...)
+	for _, service := range dm.checkOrder {
+		for _, check := range dm.serviceChecks[service] {
+			if !slices.Contains(all, check) {
+				all = append(all, check)
+			}
+		}
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	owners := make(map[string]string)
+	for service, checks := range dm.serviceChecks {
+		for _, check := range checks {
+			owners[check] = service
+		}
+	}
+	var out, unmapped []string
+	for _, check := range all {
+		owner, ok := owners[check]
+		if !ok {
+			host, _, err := net.SplitHostPort(check)
+			if err != nil {
+				host = check
+			}
+			if host == "" || host == "localhost" || net.ParseIP(host) != nil {
+				unmapped = append(unmapped, check)
+				continue
+			}
+			owner = host
+		}
+		if slices.Contains(names, owner) {
+			out = append(out, check)
+		}
+	}
+	if len(unmapped) > 0 {
+		return nil, kindErrorf(ErrValidation,
+			"cannot tell which compose service readiness check(s) %s belong to; attribute them with WithServiceChecks",
+			strings.Join(unmapped, ", "))
+	}
+	return out, nil
+}
+
 // waitForServicePort verifies TCP connectivity to a service.
 // It is private to the package as it is an implementation detail of the manager.
 func (dm *DockerManager) waitForServicePort(ctx context.Context, service string, timeout time.Duration) error {
 	parts := strings.Split(service, ":")
 	if len(parts) != 2 {
-		return fmt.Errorf("invalid service format: %s, expected 'host:port'", service)
+		return kindErrorf(ErrValidation, "invalid service format: %s, expected 'host:port'", service)
 	}
 
 	host, port := parts[0], parts[1]
-	address := net.JoinHostPort(host, port)
-
-	deadline := time.Now().Add(timeout)
-
-	ticker := time.NewTicker(dm.config.PollInterval)
-	defer ticker.Stop()
+	address := dm.serviceAddress(host, port)
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-ticker.C:
+	return WaitFor(ctx, "service "+service+" at "+address, dm.config.PollInterval,
+		func(ctx context.Context) (bool, string, error) {
 			conn, err := net.DialTimeout("tcp", address, 2*time.Second)
-			if err == nil {
-				conn.Close()
-				dm.logger.Debug("Service port accessible", "service", service)
-				return nil
-			}
-
-			if time.Now().After(deadline) {
-				return fmt.Errorf("service %s not accessible after %v", service, timeout)
+			if err != nil {
+				return false, err.Error(), nil
 			}
-		}
-	}
+			conn.Close()
+			dm.logger.Debug("Service port accessible", "service", service)
+			return true, "", nil
+		}, WithWaitTimeout(timeout))
 }
@@ -0,0 +1,35 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DumpArtifacts writes each compose service's logs to <service>.log, for
+// an ArtifactCollector. Services whose logs cannot be read are reported
+// but do not stop the others.
+func (dm *DockerManager) DumpArtifacts(dir string) error {
+	ctx := context.Background()
+	res, err := dm.runComposeIO(ctx, 0, nil, "ps", "--services", "--all")
+	if err != nil {
+		return fmt.Errorf("failed to list services: %w", err)
+	}
+	errs := NewCompositeError("dump service logs")
+	for _, service := range strings.Fields(string(res.Stdout)) {
+		logs, err := dm.runComposeIO(ctx, 0, nil, "logs", "--no-color", "--timestamps", service)
+		if err != nil {
+			errs.Add(fmt.Errorf("%s: %w", service, err))
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, service+".log"), logs.Stdout, 0o644); err != nil {
+			errs.Add(fmt.Errorf("%s: %w", service, err))
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
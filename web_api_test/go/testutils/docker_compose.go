@@ -0,0 +1,292 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ------------------------------------------------------------------------
+// Compose file templating
+// ------------------------------------------------------------------------
+
+// RenderedComposeFile is the name the rendered compose file is written
+// under in the test directory.
+const RenderedComposeFile = "docker-compose.rendered.yml"
+
+// ComposeTemplateData is the data the compose file is executed with.
+// Besides the fields, the template can call:
+//
+//	{{ hostPort "postgres" 5432 }}   a free host port for postgres:5432
+//	{{ dataDir "postgres" }}         a per-run data directory for postgres
+//
+// Repeated calls with the same arguments return the same value, so a port
+// can be referenced both in a binding and in an environment variable:
+//
+//	services:
+//	  postgres:
+//	    ports: ["{{ hostPort "postgres" 5432 }}:5432"]
+//	    volumes: ["{{ dataDir "postgres" }}:/var/lib/postgresql/data"]
+type ComposeTemplateData struct {
+	TestID  string
+	Project string
+	DataDir string
+}
+
+// composeRenderer executes a compose template and remembers the host ports
+// and data directories it handed out.
+type composeRenderer struct {
+	data     ComposeTemplateData
+	freePort func() (int, error)
+
+	mu       sync.Mutex
+	ports    map[string]int // "service/containerPort" -> host port
+	dataDirs map[string]string
+}
+
+func newComposeRenderer(data ComposeTemplateData) *composeRenderer {
+	return &composeRenderer{
+		data:     data,
+		freePort: FreePort,
+		ports:    make(map[string]int),
+		dataDirs: make(map[string]string),
+	}
+}
+
+func (r *composeRenderer) render(name string, src []byte) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"hostPort": r.hostPort,
+			"dataDir":  r.dataDir,
+		}).
+		Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse compose template %s: %w", name, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, r.data); err != nil {
+		return nil, fmt.Errorf("failed to render compose template %s: %w", name, err)
+	}
+	return out.Bytes(), nil
+}
+
+func (r *composeRenderer) hostPort(service string, containerPort int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := portKey(service, containerPort)
+	if port, ok := r.ports[key]; ok {
+		return port, nil
+	}
+	port, err := r.freePort()
+	if err != nil {
+		return 0, fmt.Errorf("no free host port for %s: %w", key, err)
+	}
+	r.ports[key] = port
+	return port, nil
+}
+
+func (r *composeRenderer) dataDir(service string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dir, ok := r.dataDirs[service]; ok {
+		return dir, nil
+	}
+	if r.data.DataDir == "" {
+		return "", fmt.Errorf("no data directory configured for %s", service)
+	}
+	dir := filepath.Join(r.data.DataDir, service)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create data directory for %s: %w", service, err)
+	}
+	r.dataDirs[service] = dir
+	return dir, nil
+}
+
+func (r *composeRenderer) port(service string, containerPort int) (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	port, ok := r.ports[portKey(service, containerPort)]
+	return port, ok
+}
+
+func portKey(service string, containerPort int) string {
+	return service + "/" + strconv.Itoa(containerPort)
+}
+
+// composeProjectName turns a test ID into a valid compose project name:
+// lowercase letters, digits, '-' and '_', starting with a letter.
+func composeProjectName(testID string) string {
+	var b strings.Builder
+	b.WriteString("test-")
+	for _, r := range strings.ToLower(testID) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// DockerOption configures a DockerManager.
+type DockerOption func(*DockerManager)
+
+// WithComposeProject overrides the compose project name, which defaults to
+// one derived from the test ID.
+func WithComposeProject(name string) DockerOption {
+	return func(dm *DockerManager) {
+		if name != "" {
+			dm.project = name
+		}
+	}
+}
+
+// WithDockerDataManager writes the rendered compose file and the service
+// data directories into tdm's test directory, so they are removed with it.
+func WithDockerDataManager(tdm *TestDataManager) DockerOption {
+	return func(dm *DockerManager) { dm.dataManager = tdm }
+}
+
+// WithDockerRunner runs the docker CLI through runner instead of
+// RealRunner, e.g. a FakeRunner in unit tests.
+func WithDockerRunner(runner CommandRunner) DockerOption {
+	return func(dm *DockerManager) {
+		if runner != nil {
+			dm.runner = runner
+		}
+	}
+}
+
+// WithServiceChecks attributes "host:port" readiness checks to a compose
+// service, so StartServices(ctx, service) waits for them. Use it for checks
+// against published ports, like "localhost:6379", whose host does not name
+// the service. The checks need not also appear in DockerConfig.Services.
+func WithServiceChecks(service string, checks ...string) DockerOption {
+	return func(dm *DockerManager) {
+		if dm.serviceChecks == nil {
+			dm.serviceChecks = make(map[string][]string)
+		}
+		if _, ok := dm.serviceChecks[service]; !ok {
+			dm.checkOrder = append(dm.checkOrder, service)
+		}
+		dm.serviceChecks[service] = append(dm.serviceChecks[service], checks...)
+	}
+}
+
+// Project returns the compose project name every command runs under.
+func (dm *DockerManager) Project() string {
+	return dm.project
+}
+
+// prepareCompose renders the compose file into the test directory the
+// first time it is needed. Later calls reuse the rendered file so host
+// ports stay stable across StartServices calls.
+func (dm *DockerManager) prepareCompose() error {
+	dm.renderMu.Lock()
+	defer dm.renderMu.Unlock()
+	if dm.renderedFile != "" {
+		return nil
+	}
+
+	src := dm.dockerCfg.ComposeFile
+	if !filepath.IsAbs(src) {
+		src = filepath.Join(dm.dockerCfg.ComposePath, src)
+	}
+	raw, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read compose file: %w", err)
+	}
+
+	testDir := dm.config.TestDataDir
+	if dm.dataManager != nil {
+		testDir = dm.dataManager.GetTestDir()
+	}
+	dm.renderer = newComposeRenderer(ComposeTemplateData{
+		TestID:  dm.config.TestID,
+		Project: dm.project,
+		DataDir: filepath.Join(testDir, "docker-data"),
+	})
+	for key, port := range dm.presetPorts {
+		dm.renderer.ports[key] = port
+	}
+	rendered, err := dm.renderer.render(filepath.Base(src), raw)
+	if err != nil {
+		return err
+	}
+
+	var path string
+	if dm.dataManager != nil {
+		path, err = dm.dataManager.CreateTestFile(RenderedComposeFile, string(rendered))
+	} else {
+		path = filepath.Join(testDir, RenderedComposeFile)
+		if err = os.MkdirAll(testDir, 0o755); err == nil {
+			err = os.WriteFile(path, rendered, 0o644)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write rendered compose file: %w", err)
+	}
+	dm.renderedFile = path
+	dm.logger.Info("Rendered compose file", "path", path, "project", dm.project)
+	return nil
+}
+
+// composeFile returns the rendered compose file once Start has run, and the
+// configured one before.
+func (dm *DockerManager) composeFile() string {
+	dm.renderMu.Lock()
+	defer dm.renderMu.Unlock()
+	if dm.renderedFile != "" {
+		return dm.renderedFile
+	}
+	return dm.dockerCfg.ComposeFile
+}
+
+// ResolvedPort returns the host port bound to containerPort of service.
+// Ports allocated by hostPort in the template are answered directly; any
+// other binding is looked up with `docker compose port`.
+func (dm *DockerManager) ResolvedPort(service string, containerPort int) (int, error) {
+	dm.renderMu.Lock()
+	renderer := dm.renderer
+	dm.renderMu.Unlock()
+	if renderer != nil {
+		if port, ok := renderer.port(service, containerPort); ok {
+			return port, nil
+		}
+	}
+
+	out, err := dm.runCompose(context.Background(), "port", service, strconv.Itoa(containerPort))
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve port %s: %w", portKey(service, containerPort), err)
+	}
+	_, portStr, err := net.SplitHostPort(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected docker compose port output %q: %w", out, err)
+	}
+	return strconv.Atoi(portStr)
+}
+
+// serviceAddress maps a configured "host:port" readiness check onto the
+// host port the service was published on, when the template remapped it.
+func (dm *DockerManager) serviceAddress(host, port string) string {
+	dm.renderMu.Lock()
+	renderer := dm.renderer
+	dm.renderMu.Unlock()
+	if renderer != nil {
+		if p, err := strconv.Atoi(port); err == nil {
+			if hostPort, ok := renderer.port(host, p); ok {
+				return net.JoinHostPort("127.0.0.1", strconv.Itoa(hostPort))
+			}
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,67 @@
+package testutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComposeRenderer_PortsAndDataDirs(t *testing.T) {
+	dataDir := filepath.Join(t.TempDir(), "docker-data")
+	r := newComposeRenderer(ComposeTemplateData{TestID: "abc", Project: "test-abc", DataDir: dataDir})
+	next := 40000
+	r.freePort = func() (int, error) { next++; return next, nil }
+
+	src := `name: {{ .Project }}
+services:
+  postgres:
+    ports: ["{{ hostPort "postgres" 5432 }}:5432"]
+    environment:
+      PGPORT_HOST: "{{ hostPort "postgres" 5432 }}"
+    volumes: ["{{ dataDir "postgres" }}:/var/lib/postgresql/data"]
+  redis:
+    ports: ["{{ hostPort "redis" 6379 }}:6379"]
+`
+	out, err := r.render("docker-compose.yml", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"name: test-abc",
+		`["40001:5432"]`,
+		`PGPORT_HOST: "40001"`,
+		`["40002:6379"]`,
+		filepath.Join(dataDir, "postgres") + ":/var/lib/postgresql/data",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered file missing %q:\n%s", want, got)
+		}
+	}
+	if port, ok := r.port("postgres", 5432); !ok || port != 40001 {
+		t.Errorf("postgres port %d, %v", port, ok)
+	}
+	if _, ok := r.port("postgres", 5433); ok {
+		t.Error("unallocated port reported")
+	}
+	if info, err := os.Stat(filepath.Join(dataDir, "postgres")); err != nil || !info.IsDir() {
+		t.Errorf("data dir not created: %v", err)
+	}
+}
+
+func TestComposeRenderer_Errors(t *testing.T) {
+	r := newComposeRenderer(ComposeTemplateData{})
+	if _, err := r.render("bad.yml", []byte("{{ .Missing }}")); err == nil {
+		t.Error("unknown field rendered")
+	}
+	if _, err := r.render("bad.yml", []byte(`{{ dataDir "db" }}`)); err == nil {
+		t.Error("dataDir without a data directory rendered")
+	}
+}
+
+func TestComposeProjectName(t *testing.T) {
+	if got := composeProjectName("3F2a.B/9"); got != "test-3f2a-b-9" {
+		t.Errorf("project name %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExecOptions configures a command run inside a compose service.
+type ExecOptions struct {
+	User    string            // --user
+	Workdir string            // --workdir
+	Env     map[string]string // --env, applied in key order
+	Stdin   io.Reader         // streamed to the command; nil for none
+	Timeout time.Duration     // 0 uses DockerConfig.Timeout
+}
+
+// ExecResult is the outcome of a command run inside a container.
+type ExecResult struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Duration time.Duration
+}
+
+// ExecError reports a command that ran but exited non-zero.
+type ExecError struct {
+	Service  string
+	Cmd      []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("exec %q in %s exited with code %d", strings.Join(e.Cmd, " "), e.Service, e.ExitCode)
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+// Exec runs cmd in the running container of service with `docker compose
+// exec -T`, so no TTY is needed and stdin can be piped. The result is
+// returned even on failure; a non-zero exit yields an *ExecError carrying
+// the captured stderr.
+//
+//	res, err := dm.Exec(ctx, "postgres", []string{"psql", "-U", "app", "-c", "select 1"}, ExecOptions{})
+func (dm *DockerManager) Exec(ctx context.Context, service string, cmd []string, opts ExecOptions) (*ExecResult, error) {
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("exec in %s: empty command", service)
+	}
+	args := append([]string{"exec"}, execFlags(opts)...)
+	args = append(append(args, service), cmd...)
+
+	res, err := dm.runComposeIO(ctx, opts.Timeout, opts.Stdin, args...)
+	dm.logger.Info("Docker exec", "service", service, "cmd", strings.Join(cmd, " "),
+		"exitCode", res.ExitCode, "duration", res.Duration)
+	if err != nil {
+		if res.ExitCode > 0 {
+			return res, &ExecError{
+				Service:  service,
+				Cmd:      cmd,
+				ExitCode: res.ExitCode,
+				Stderr:   strings.TrimSpace(string(res.Stderr)),
+			}
+		}
+		return res, fmt.Errorf("exec in %s: %w", service, err)
+	}
+	return res, nil
+}
+
+// ExecScript streams script to interpreter's stdin inside service, e.g. a
+// SQL file into "psql -U app -d app" or a shell script into "sh". The
+// interpreter string is split on whitespace.
+func (dm *DockerManager) ExecScript(ctx context.Context, service string, script io.Reader, interpreter string) (*ExecResult, error) {
+	cmd := strings.Fields(interpreter)
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("exec script in %s: empty interpreter", service)
+	}
+	return dm.Exec(ctx, service, cmd, ExecOptions{Stdin: script})
+}
+
+// CopyToContainer copies localPath, a file or directory, to containerPath
+// in service's container with `docker compose cp`.
+func (dm *DockerManager) CopyToContainer(ctx context.Context, service, localPath, containerPath string) error {
+	res, err := dm.runComposeIO(ctx, 0, nil, "cp", localPath, service+":"+containerPath)
+	dm.logger.Info("Docker cp", "service", service, "src", localPath, "dst", containerPath,
+		"duration", res.Duration)
+	if err != nil {
+		if msg := strings.TrimSpace(string(res.Stderr)); msg != "" {
+			return fmt.Errorf("failed to copy %s to %s:%s: %w: %s", localPath, service, containerPath, err, msg)
+		}
+		return fmt.Errorf("failed to copy %s to %s:%s: %w", localPath, service, containerPath, err)
+	}
+	return nil
+}
+
+// execFlags maps options onto `docker compose exec` flags.
+func execFlags(opts ExecOptions) []string {
+	flags := []string{"-T"}
+	if opts.User != "" {
+		flags = append(flags, "--user", opts.User)
+	}
+	if opts.Workdir != "" {
+		flags = append(flags, "--workdir", opts.Workdir)
+	}
+	keys := make([]string, 0, len(opts.Env))
+	for k := range opts.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		flags = append(flags, "--env", k+"="+opts.Env[k])
+	}
+	return flags
+}
+
+// runComposeIO is runCompose with stdin, separate output buffers, the exit
+// code and a timeout. It always returns a non-nil result.
+func (dm *DockerManager) runComposeIO(ctx context.Context, timeout time.Duration, stdin io.Reader, args ...string) (*ExecResult, error) {
+	if timeout <= 0 {
+		timeout = dm.dockerCfg.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	out, err := dm.runner.Run(ctx, CommandSpec{
+		Name:  "docker",
+		Args:  append(dm.composeArgs(), args...),
+		Dir:   dm.dockerCfg.ComposePath,
+		Stdin: stdin,
+	})
+	res := &ExecResult{
+		Stdout:   out.Stdout,
+		Stderr:   out.Stderr,
+		ExitCode: out.ExitCode,
+		Duration: out.Duration,
+	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return res, fmt.Errorf("docker %s timed out after %v: %w", strings.Join(args, " "), timeout, ctx.Err())
+	}
+	return res, err
+}
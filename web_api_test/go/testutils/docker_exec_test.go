@@ -0,0 +1,36 @@
+package testutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecFlags(t *testing.T) {
+	got := execFlags(ExecOptions{
+		User:    "postgres",
+		Workdir: "/tmp",
+		Env:     map[string]string{"PGUSER": "app", "PGDATABASE": "app"},
+	})
+	want := []string{"-T", "--user", "postgres", "--workdir", "/tmp",
+		"--env", "PGDATABASE=app", "--env", "PGUSER=app"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("flags %q, want %q", got, want)
+	}
+	if got := execFlags(ExecOptions{}); !reflect.DeepEqual(got, []string{"-T"}) {
+		t.Errorf("default flags %q", got)
+	}
+}
+
+func TestExecError(t *testing.T) {
+	err := &ExecError{
+		Service:  "postgres",
+		Cmd:      []string{"psql", "-c", "select"},
+		ExitCode: 3,
+		Stderr:   `ERROR:  syntax error at end of input`,
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, `"psql -c select" in postgres exited with code 3`) || !strings.Contains(msg, "syntax error") {
+		t.Errorf("message %q", msg)
+	}
+}
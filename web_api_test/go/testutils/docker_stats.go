@@ -0,0 +1,347 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContainerStats is a single resource sample for one compose service.
+type ContainerStats struct {
+	Service     string    `json:"service"`
+	Container   string    `json:"container"`
+	Time        time.Time `json:"time"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	MemUsage    int64     `json:"mem_usage_bytes"`
+	MemLimit    int64     `json:"mem_limit_bytes"`
+	MemPercent  float64   `json:"mem_percent"`
+	NetRxBytes  int64     `json:"net_rx_bytes"`
+	NetTxBytes  int64     `json:"net_tx_bytes"`
+	BlockRead   int64     `json:"block_read_bytes"`
+	BlockWrite  int64     `json:"block_write_bytes"`
+	PIDs        int       `json:"pids"`
+	Unavailable bool      `json:"unavailable,omitempty"` // container missing or restarting
+}
+
+// dockerStatsLine is one line of `docker stats --no-stream --format json`.
+type dockerStatsLine struct {
+	Name     string `json:"Name"`
+	ID       string `json:"ID"`
+	CPUPerc  string `json:"CPUPerc"`
+	MemUsage string `json:"MemUsage"`
+	MemPerc  string `json:"MemPerc"`
+	NetIO    string `json:"NetIO"`
+	BlockIO  string `json:"BlockIO"`
+	PIDs     string `json:"PIDs"`
+}
+
+// CollectStats samples resource usage for the given compose services every
+// interval until ctx is cancelled. The returned channel is closed when
+// sampling stops. Containers are re-resolved on every tick, so a service that
+// restarts mid-collection yields an Unavailable sample instead of ending the
+// collection.
+func (dm *DockerManager) CollectStats(ctx context.Context, services []string, interval time.Duration) <-chan ContainerStats {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	out := make(chan ContainerStats, len(services))
+
+	go func() {
+		defer close(out)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			for _, sample := range dm.sampleStats(ctx, services) {
+				select {
+				case out <- sample:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return out
+}
+
+// sampleStats takes one snapshot of the requested services.
+func (dm *DockerManager) sampleStats(ctx context.Context, services []string) []ContainerStats {
+	now := time.Now()
+
+	psOut, err := dm.runCompose(ctx, append([]string{"ps", "--format", "json"}, services...)...)
+	if err != nil {
+		if ctx.Err() == nil {
+			dm.logger.Warn("Failed to list containers for stats", "error", err)
+		}
+		return unavailableSamples(services, now)
+	}
+	running, err := parseComposePS(psOut)
+	if err != nil {
+		dm.logger.Warn("Failed to parse container list for stats", "error", err)
+		return unavailableSamples(services, now)
+	}
+
+	containerFor := make(map[string]string)
+	var names []string
+	for _, svc := range running {
+		if svc.State != "running" {
+			continue
+		}
+		containerFor[svc.Service] = svc.Name
+		names = append(names, svc.Name)
+	}
+
+	byContainer := make(map[string]dockerStatsLine)
+	if len(names) > 0 {
+		statsOut, err := dm.runDocker(ctx, append([]string{"stats", "--no-stream", "--format", "json"}, names...)...)
+		if err != nil && ctx.Err() == nil {
+			// A container can disappear between ps and stats; report what we have.
+			dm.logger.Debug("docker stats returned an error", "error", err)
+		}
+		for _, line := range bytes.Split(bytes.TrimSpace(statsOut), []byte("\n")) {
+			var l dockerStatsLine
+			if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &l) != nil {
+				continue
+			}
+			byContainer[l.Name] = l
+		}
+	}
+
+	samples := make([]ContainerStats, 0, len(services))
+	for _, service := range services {
+		name, ok := containerFor[service]
+		line, found := byContainer[name]
+		if !ok || !found {
+			samples = append(samples, ContainerStats{Service: service, Container: name, Time: now, Unavailable: true})
+			continue
+		}
+		samples = append(samples, parseStatsLine(service, line, now))
+	}
+	return samples
+}
+
+func unavailableSamples(services []string, now time.Time) []ContainerStats {
+	samples := make([]ContainerStats, len(services))
+	for i, s := range services {
+		samples[i] = ContainerStats{Service: s, Time: now, Unavailable: true}
+	}
+	return samples
+}
+
+// parseStatsLine converts docker's human-readable columns into numbers.
+func parseStatsLine(service string, l dockerStatsLine, now time.Time) ContainerStats {
+	cs := ContainerStats{Service: service, Container: l.Name, Time: now}
+	cs.CPUPercent = parsePercent(l.CPUPerc)
+	cs.MemPercent = parsePercent(l.MemPerc)
+	cs.MemUsage, cs.MemLimit = parseBytePair(l.MemUsage)
+	cs.NetRxBytes, cs.NetTxBytes = parseBytePair(l.NetIO)
+	cs.BlockRead, cs.BlockWrite = parseBytePair(l.BlockIO)
+	cs.PIDs, _ = strconv.Atoi(strings.TrimSpace(l.PIDs))
+	return cs
+}
+
+func parsePercent(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	return v
+}
+
+// parseBytePair parses "10MiB / 1.9GiB" style columns.
+func parseBytePair(s string) (int64, int64) {
+	left, right, _ := strings.Cut(s, "/")
+	return parseByteSize(left), parseByteSize(right)
+}
+
+// dockerByteUnits maps docker's decimal and binary unit suffixes to multipliers.
+var dockerByteUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"B", 1},
+}
+
+// parseByteSize parses values such as "1.5GiB", "12kB" or "0B". The result
+// is rounded, since "8.19kB" times 1e3 is not exactly 8190 in floating point.
+func parseByteSize(s string) int64 {
+	s = strings.TrimSpace(s)
+	for _, u := range dockerByteUnits {
+		if num, ok := strings.CutSuffix(s, u.suffix); ok {
+			v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+			if err != nil {
+				return 0
+			}
+			return int64(math.Round(v * u.mult))
+		}
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return int64(v)
+}
+
+// --------------------------------------------------------------------
+// StatsSummary – min/mean/max aggregation per service
+// --------------------------------------------------------------------
+
+// StatRange is the min, mean and max of one metric.
+type StatRange struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	Max  float64 `json:"max"`
+}
+
+// ServiceStatsSummary aggregates the samples collected for one service.
+type ServiceStatsSummary struct {
+	Service     string    `json:"service"`
+	Samples     int       `json:"samples"`
+	Unavailable int       `json:"unavailable"`
+	CPUPercent  StatRange `json:"cpu_percent"`
+	MemUsage    StatRange `json:"mem_usage_bytes"`
+	NetRxBytes  StatRange `json:"net_rx_bytes"`
+	NetTxBytes  StatRange `json:"net_tx_bytes"`
+	BlockRead   StatRange `json:"block_read_bytes"`
+	BlockWrite  StatRange `json:"block_write_bytes"`
+}
+
+// cpuScale stores CPU percentages as hundredths so IntCollection can hold them.
+const cpuScale = 100
+
+// StatsSummary accumulates ContainerStats into per-service summaries.
+type StatsSummary struct {
+	mu       sync.Mutex
+	services map[string]*serviceSamples
+}
+
+type serviceSamples struct {
+	unavailable int
+	cpu         *IntCollection
+	mem         *IntCollection
+	netRx       *IntCollection
+	netTx       *IntCollection
+	blockRead   *IntCollection
+	blockWrite  *IntCollection
+}
+
+// NewStatsSummary creates an empty summary.
+func NewStatsSummary() *StatsSummary {
+	return &StatsSummary{services: make(map[string]*serviceSamples)}
+}
+
+// Add records a sample.
+func (s *StatsSummary) Add(cs ContainerStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss, ok := s.services[cs.Service]
+	if !ok {
+		ss = &serviceSamples{
+			cpu: NewIntCollection(), mem: NewIntCollection(),
+			netRx: NewIntCollection(), netTx: NewIntCollection(),
+			blockRead: NewIntCollection(), blockWrite: NewIntCollection(),
+		}
+		s.services[cs.Service] = ss
+	}
+	if cs.Unavailable {
+		ss.unavailable++
+		return
+	}
+	ss.cpu.Add(int(cs.CPUPercent * cpuScale))
+	ss.mem.Add(int(cs.MemUsage))
+	ss.netRx.Add(int(cs.NetRxBytes))
+	ss.netTx.Add(int(cs.NetTxBytes))
+	ss.blockRead.Add(int(cs.BlockRead))
+	ss.blockWrite.Add(int(cs.BlockWrite))
+}
+
+// Consume reads samples from ch until it is closed.
+func (s *StatsSummary) Consume(ch <-chan ContainerStats) {
+	for cs := range ch {
+		s.Add(cs)
+	}
+}
+
+// Summaries returns the per-service summaries sorted by service name.
+func (s *StatsSummary) Summaries() []ServiceStatsSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ServiceStatsSummary, 0, len(s.services))
+	for name, ss := range s.services {
+		out = append(out, ServiceStatsSummary{
+			Service:     name,
+			Samples:     ss.cpu.Len(),
+			Unavailable: ss.unavailable,
+			CPUPercent:  rangeOf(ss.cpu, cpuScale),
+			MemUsage:    rangeOf(ss.mem, 1),
+			NetRxBytes:  rangeOf(ss.netRx, 1),
+			NetTxBytes:  rangeOf(ss.netTx, 1),
+			BlockRead:   rangeOf(ss.blockRead, 1),
+			BlockWrite:  rangeOf(ss.blockWrite, 1),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Service < out[j].Service })
+	return out
+}
+
+func rangeOf(c *IntCollection, scale float64) StatRange {
+	minV, ok := c.Min()
+	if !ok {
+		return StatRange{}
+	}
+	maxV, _ := c.Max()
+	return StatRange{
+		Min:  float64(minV) / scale,
+		Mean: c.Average() / scale,
+		Max:  float64(maxV) / scale,
+	}
+}
+
+// Fields returns the summary of one service as flat structured log fields.
+func (s ServiceStatsSummary) Fields() map[string]any {
+	return map[string]any{
+		"service":          s.Service,
+		"samples":          s.Samples,
+		"unavailable":      s.Unavailable,
+		"cpu_pct_min":      s.CPUPercent.Min,
+		"cpu_pct_mean":     s.CPUPercent.Mean,
+		"cpu_pct_max":      s.CPUPercent.Max,
+		"mem_bytes_min":    s.MemUsage.Min,
+		"mem_bytes_mean":   s.MemUsage.Mean,
+		"mem_bytes_max":    s.MemUsage.Max,
+		"net_rx_bytes_max": s.NetRxBytes.Max,
+		"net_tx_bytes_max": s.NetTxBytes.Max,
+		"block_read_max":   s.BlockRead.Max,
+		"block_write_max":  s.BlockWrite.Max,
+	}
+}
+
+// Log writes one structured entry per service to the logger.
+func (s *StatsSummary) Log(logger *TestLogger) {
+	for _, summary := range s.Summaries() {
+		logger.Info("container stats summary", summary.Fields())
+	}
+}
+
+// String returns a compact human-readable table of the summary.
+func (s *StatsSummary) String() string {
+	var b strings.Builder
+	for _, sum := range s.Summaries() {
+		fmt.Fprintf(&b, "%s: samples=%d cpu=%.1f/%.1f/%.1f%% mem=%.0f/%.0f/%.0fB\n",
+			sum.Service, sum.Samples,
+			sum.CPUPercent.Min, sum.CPUPercent.Mean, sum.CPUPercent.Max,
+			sum.MemUsage.Min, sum.MemUsage.Mean, sum.MemUsage.Max)
+	}
+	return b.String()
+}
@@ -0,0 +1,134 @@
+package testutils
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+	}{
+		{"0B", 0},
+		{"512B", 512},
+		{"12kB", 12_000},
+		{"1.5KiB", 1536},
+		{" 10MiB ", 10 << 20},
+		{"1.5GiB", 3 << 29},
+		{"3MB", 3_000_000},
+		{"2TB", 2e12},
+		{"42", 42},
+		{"", 0},
+		{"lotsMiB", 0},
+		{"--", 0},
+	} {
+		if got := parseByteSize(tc.in); got != tc.want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseStatsColumns(t *testing.T) {
+	for _, tc := range []struct {
+		in          string
+		left, right int64
+	}{
+		{"10MiB / 1GiB", 10 << 20, 1 << 30},
+		{"1.2kB / 648B", 1200, 648},
+		{"0B / 0B", 0, 0},
+		{"5MB", 5_000_000, 0},
+	} {
+		if l, r := parseBytePair(tc.in); l != tc.left || r != tc.right {
+			t.Errorf("parseBytePair(%q) = %d, %d; want %d, %d", tc.in, l, r, tc.left, tc.right)
+		}
+	}
+	for in, want := range map[string]float64{"0.25%": 0.25, " 101.5% ": 101.5, "--": 0, "": 0} {
+		if got := parsePercent(in); got != want {
+			t.Errorf("parsePercent(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestDockerManager_CollectStats(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	runner.On("docker", "ps").Stdout(
+		`{"Service":"db","Name":"p-db-1","State":"running"}` + "\n" +
+			`{"Service":"cache","Name":"p-cache-1","State":"restarting"}`)
+	runner.On("docker", "stats").Stdout(
+		`{"Name":"p-db-1","CPUPerc":"12.50%","MemUsage":"64MiB / 1GiB","MemPerc":"6.25%",` +
+			`"NetIO":"1.5kB / 648B","BlockIO":"8.19kB / 0B","PIDs":"7"}` + "\n" + `not json`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var samples []ContainerStats
+	for cs := range dm.CollectStats(ctx, []string{"db", "cache"}, time.Hour) {
+		samples = append(samples, cs)
+		if len(samples) == 2 {
+			cancel()
+		}
+	}
+	cancel()
+
+	if len(samples) != 2 {
+		t.Fatalf("got %d samples, want one per service: %+v", len(samples), samples)
+	}
+	db := samples[0]
+	db.Time = time.Time{}
+	want := ContainerStats{
+		Service: "db", Container: "p-db-1", CPUPercent: 12.5, MemUsage: 64 << 20, MemLimit: 1 << 30,
+		MemPercent: 6.25, NetRxBytes: 1500, NetTxBytes: 648, BlockRead: 8190, PIDs: 7,
+	}
+	if !reflect.DeepEqual(db, want) {
+		t.Errorf("db sample\n%+v\nwant\n%+v", db, want)
+	}
+	if cache := samples[1]; cache.Service != "cache" || !cache.Unavailable {
+		t.Errorf("a restarting container must be unavailable: %+v", cache)
+	}
+
+	args := runner.Args()
+	if got := strings.Join(args[len(args)-1], " "); got != "stats --no-stream --format json p-db-1" {
+		t.Errorf("stats only the running containers, got %q", got)
+	}
+}
+
+func TestDockerManager_CollectStatsWhenPSFails(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	runner.On("docker", "ps").Stderr("Cannot connect to the Docker daemon").Exit(1)
+
+	samples := dm.sampleStats(context.Background(), []string{"db"})
+	if len(samples) != 1 || !samples[0].Unavailable || samples[0].Service != "db" {
+		t.Errorf("samples %+v", samples)
+	}
+	if n := len(runner.Calls()); n != 1 {
+		t.Errorf("ran %d docker commands, want only the failed ps", n)
+	}
+}
+
+func TestStatsSummary(t *testing.T) {
+	s := NewStatsSummary()
+	for _, cs := range []ContainerStats{
+		{Service: "db", CPUPercent: 10, MemUsage: 100, NetRxBytes: 5},
+		{Service: "db", CPUPercent: 30.5, MemUsage: 300, NetRxBytes: 9},
+		{Service: "db", Unavailable: true},
+		{Service: "api", CPUPercent: 1, MemUsage: 50},
+	} {
+		s.Add(cs)
+	}
+
+	sums := s.Summaries()
+	if len(sums) != 2 || sums[0].Service != "api" || sums[1].Service != "db" {
+		t.Fatalf("summaries %+v", sums)
+	}
+	db := sums[1]
+	if db.Samples != 2 || db.Unavailable != 1 ||
+		db.CPUPercent != (StatRange{Min: 10, Mean: 20.25, Max: 30.5}) ||
+		db.MemUsage != (StatRange{Min: 100, Mean: 200, Max: 300}) || db.NetRxBytes.Max != 9 {
+		t.Errorf("db summary %+v", db)
+	}
+	if got := s.String(); !strings.Contains(got, "db: samples=2 cpu=10.0/20.2/30.5% mem=100/200/300B") {
+		t.Errorf("String() = %q", got)
+	}
+}
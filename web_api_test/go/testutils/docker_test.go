@@ -0,0 +1,200 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFakeDockerManager returns a manager for a one-line compose file whose
+// docker calls go to a FakeRunner.
+func newFakeDockerManager(t *testing.T, dockerCfg config.DockerConfig, opts ...DockerOption) (*DockerManager, *FakeRunner) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dockerCfg.ComposePath, dockerCfg.ComposeFile = dir, "docker-compose.yml"
+	cfg := &config.TestConfig{TestID: "fake", TestDataDir: filepath.Join(dir, "data"), DockerConfig: dockerCfg}
+
+	runner := NewFakeRunner()
+	opts = append([]DockerOption{WithDockerRunner(runner), WithComposeProject("p")}, opts...)
+	dm, err := NewDockerManager(cfg, test.NewTestLogger(t), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dm, runner
+}
+
+func TestDockerManager_ComposeFlags(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{
+		Build: true, ForceRecreate: true, RemoveOrphans: true, RemoveVolumes: true,
+	})
+	ctx := context.Background()
+	if err := dm.StartServices(ctx, "db", "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.StopServices(ctx, "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, args := range runner.Args() {
+		if !reflect.DeepEqual(args[:3], []string{"compose", "--project-name", "p"}) {
+			t.Errorf("missing compose prefix: %q", args)
+		}
+		got = append(got, strings.Join(args[7:], " "))
+	}
+	want := []string{
+		"up -d --build --force-recreate --remove-orphans db cache",
+		"stop cache",
+		"rm -f -v cache",
+		"down --remove-orphans --volumes",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("commands\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestDockerManager_FailureAndPS(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	runner.On("docker", "up").Stderr("pull access denied for app\n").Exit(1)
+	runner.On("docker", "ps").Stdout(`{"Service":"db","State":"running"}` + "\n" + `{"Service":"cache","State":"exited"}`)
+	ctx := context.Background()
+
+	err := dm.Start(ctx)
+	if err == nil || !strings.Contains(err.Error(), "exit status 1: pull access denied for app") {
+		t.Errorf("start error must carry stderr: %v", err)
+	}
+	if running, err := dm.IsServiceRunning(ctx, "db"); err != nil || !running {
+		t.Errorf("db running %v, %v", running, err)
+	}
+	if running, err := dm.IsServiceRunning(ctx, "cache"); err != nil || running {
+		t.Errorf("cache running %v, %v", running, err)
+	}
+}
+
+func TestDockerManager_ExecWithFakeRunner(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	runner.On("docker", "exec", "psql").Stdout("1\n").Stderr("relation missing").Exit(3)
+	runner.On("docker", "exec", "sleep").Delay(time.Minute)
+	ctx := context.Background()
+
+	res, err := dm.Exec(ctx, "db", []string{"psql", "-c", "select 1"}, ExecOptions{User: "postgres", Env: map[string]string{"PGDATABASE": "app"}})
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 3 || execErr.Stderr != "relation missing" || string(res.Stdout) != "1\n" {
+		t.Fatalf("expected an exit code 3 ExecError, got %v", err)
+	}
+	args := runner.Args()[0]
+	if got := strings.Join(args[7:], " "); got != "exec -T --user postgres --env PGDATABASE=app db psql -c select 1" {
+		t.Errorf("exec args %q", got)
+	}
+
+	_, err = dm.Exec(ctx, "db", []string{"sleep", "60"}, ExecOptions{Timeout: 10 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+}
+
+func TestDockerManager_ServiceChecks(t *testing.T) {
+	services := []string{"db:5432", "localhost:6379", "127.0.0.1:9000"}
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{Services: services},
+		WithServiceChecks("cache", "localhost:6379"), WithServiceChecks("search", "localhost:9200"))
+
+	err := dm.StartServices(context.Background(), "db")
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "127.0.0.1:9000") || strings.Contains(err.Error(), "6379") {
+		t.Fatalf("expected the unattributed check to fail the start, got %v", err)
+	}
+	if n := len(runner.Calls()); n != 0 {
+		t.Errorf("ran %d docker command(s) before rejecting the checks", n)
+	}
+
+	WithServiceChecks("api", "127.0.0.1:9000")(dm)
+	for _, tc := range []struct {
+		names []string
+		want  []string
+	}{
+		{nil, []string{"db:5432", "localhost:6379", "127.0.0.1:9000", "localhost:9200"}},
+		{[]string{"cache"}, []string{"localhost:6379"}},
+		{[]string{"db", "api"}, []string{"db:5432", "127.0.0.1:9000"}},
+		{[]string{"worker"}, nil},
+	} {
+		got, err := dm.servicesFor(tc.names)
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("servicesFor(%q) = %q, %v; want %q", tc.names, got, err, tc.want)
+		}
+	}
+}
+
+func TestDockerManager_RestartCancelsPreviousStart(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	runner.On("docker", "up", "db").Delay(time.Hour).Times(1)
+
+	first := make(chan error, 1)
+	go func() { first <- dm.StartServices(context.Background(), "db") }()
+	for len(runner.Calls()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := dm.StartServices(context.Background(), "db"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-first:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("first start returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second start did not cancel the first")
+	}
+}
+
+func TestDockerManager_StopDuringStart(t *testing.T) {
+	dm, runner := newFakeDockerManager(t, config.DockerConfig{})
+	ctx := context.Background()
+
+	// Starts and stops from two goroutines; -race flags unguarded state.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			dm.StartServices(ctx, "db")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			dm.Stop()
+		}
+	}()
+	wg.Wait()
+
+	runner.On("docker", "up").Delay(time.Hour)
+	calls := len(runner.Calls())
+	started := make(chan error, 1)
+	go func() { started <- dm.StartServices(ctx, "db") }()
+	for len(runner.Calls()) == calls {
+		time.Sleep(time.Millisecond)
+	}
+	if err := dm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-started:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("start returned %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the start in progress")
+	}
+}
@@ -0,0 +1,222 @@
+package testutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ----------------------------------------------------------------------
+// Duration formatting and parsing for configs, logs and reports
+// ----------------------------------------------------------------------
+
+// FormatDuration renders d for people: "800ns", "250µs", "340ms",
+// "1.25s", "2m03s", "1h02m03.5s". d is first rounded to precision; a
+// precision of zero or less keeps every digit, and the result then parses
+// back to d with ParseFlexibleDuration. Use TimerConfig.FormatDuration to
+// honour the configured precision.
+func FormatDuration(d, precision time.Duration) string {
+	if precision > 0 {
+		d = d.Round(precision)
+	}
+	if d == 0 {
+		return "0s"
+	}
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	switch {
+	case d < time.Microsecond:
+		return sign + strconv.FormatInt(int64(d), 10) + "ns"
+	case d < time.Millisecond:
+		return sign + decimalDuration(d, time.Microsecond, 1) + "µs"
+	case d < time.Second:
+		return sign + decimalDuration(d, time.Millisecond, 1) + "ms"
+	case d < time.Minute:
+		return sign + decimalDuration(d, time.Second, 1) + "s"
+	}
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := decimalDuration(d, time.Second, 2)
+	if hours > 0 {
+		return fmt.Sprintf("%s%dh%02dm%ss", sign, hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%s%dm%ss", sign, minutes, seconds)
+}
+
+// decimalDuration writes d in units of unit, zero-padding the whole part
+// to width and dropping trailing zeros from the fraction. It is exact.
+func decimalDuration(d, unit time.Duration, width int) string {
+	s := fmt.Sprintf("%0*d", width, d/unit)
+	if rem := d % unit; rem != 0 {
+		digits := len(strconv.FormatInt(int64(unit), 10)) - 1
+		s += "." + strings.TrimRight(fmt.Sprintf("%0*d", digits, rem), "0")
+	}
+	return s
+}
+
+// FormatDuration renders d with FormatDuration at the configured
+// DefaultPrecision.
+func (c TimerConfig) FormatDuration(d time.Duration) string {
+	return FormatDuration(d, c.DefaultPrecision)
+}
+
+// ParseFlexibleDuration parses what people write in configs: any Go
+// duration ("1h30m", "1.5m", "2m03s") or a bare number, which counts
+// milliseconds ("90", "2.5").
+func ParseFlexibleDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+	if ms, err := strconv.ParseFloat(s, 64); err == nil {
+		ns := ms * float64(time.Millisecond)
+		if math.IsNaN(ns) || math.Abs(ns) >= math.MaxInt64 {
+			return 0, fmt.Errorf("duration %q is out of range", s)
+		}
+		return time.Duration(math.Round(ns)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q: want a duration such as \"1h30m\" or \"1.5s\", or milliseconds", s)
+	}
+	return d, nil
+}
+
+// numericDurationJSON makes DurationJSON marshal as nanoseconds.
+var numericDurationJSON atomic.Bool
+
+// SetNumericDurationJSON makes DurationJSON, and so the JSON of
+// ConnectionResult, WaitResult and PortCheckResult, write nanosecond
+// numbers as it did before durations became strings. It returns the
+// previous setting. NewPortCheckerFromConfig applies
+// TimerConfig.NumericDurationJSON.
+func SetNumericDurationJSON(on bool) bool {
+	return numericDurationJSON.Swap(on)
+}
+
+// DurationJSON is a time.Duration whose JSON form is a FormatDuration
+// string ("1.25s"). It unmarshals strings with ParseFlexibleDuration and
+// numbers as nanoseconds, so older numeric documents still load.
+type DurationJSON time.Duration
+
+// Duration returns d as a time.Duration.
+func (d DurationJSON) Duration() time.Duration { return time.Duration(d) }
+
+func (d DurationJSON) String() string { return FormatDuration(time.Duration(d), 0) }
+
+// MarshalJSON writes d as a string, or as nanoseconds after
+// SetNumericDurationJSON(true).
+func (d DurationJSON) MarshalJSON() ([]byte, error) {
+	if numericDurationJSON.Load() {
+		return json.Marshal(int64(d))
+	}
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON reads a duration string or a number of nanoseconds.
+func (d *DurationJSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		v, err := ParseFlexibleDuration(s)
+		if err != nil {
+			return err
+		}
+		*d = DurationJSON(v)
+		return nil
+	}
+	var ns float64
+	if err := json.Unmarshal(data, &ns); err != nil {
+		return fmt.Errorf("duration must be a string or a number of nanoseconds: %w", err)
+	}
+	*d = DurationJSON(math.Round(ns))
+	return nil
+}
+
+// ----------------------------------------------------------------------
+// Readable durations in result JSON
+// ----------------------------------------------------------------------
+
+// MarshalJSON writes Latency as a DurationJSON.
+func (r ConnectionResult) MarshalJSON() ([]byte, error) {
+	type plain ConnectionResult
+	return json.Marshal(struct {
+		plain
+		Latency DurationJSON `json:"latency"`
+	}{plain(r), DurationJSON(r.Latency)})
+}
+
+// UnmarshalJSON accepts Latency as a string or in nanoseconds.
+func (r *ConnectionResult) UnmarshalJSON(data []byte) error {
+	type plain ConnectionResult
+	aux := struct {
+		*plain
+		Latency DurationJSON `json:"latency"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Latency = time.Duration(aux.Latency)
+	return nil
+}
+
+// MarshalJSON writes Duration as a DurationJSON.
+func (r WaitResult) MarshalJSON() ([]byte, error) {
+	type plain WaitResult
+	return json.Marshal(struct {
+		plain
+		Duration DurationJSON `json:"duration"`
+	}{plain(r), DurationJSON(r.Duration)})
+}
+
+// UnmarshalJSON accepts Duration as a string or in nanoseconds.
+func (r *WaitResult) UnmarshalJSON(data []byte) error {
+	type plain WaitResult
+	aux := struct {
+		*plain
+		Duration DurationJSON `json:"duration"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Duration = time.Duration(aux.Duration)
+	return nil
+}
+
+// MarshalJSON writes Latency as a DurationJSON.
+func (r PortCheckResult) MarshalJSON() ([]byte, error) {
+	type plain PortCheckResult
+	return json.Marshal(struct {
+		plain
+		Latency DurationJSON `json:"latency,omitempty"`
+	}{plain(r), DurationJSON(r.Latency)})
+}
+
+// UnmarshalJSON accepts Latency as a string or in nanoseconds.
+func (r *PortCheckResult) UnmarshalJSON(data []byte) error {
+	type plain PortCheckResult
+	aux := struct {
+		*plain
+		Latency DurationJSON `json:"latency,omitempty"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	r.Latency = time.Duration(aux.Latency)
+	return nil
+}
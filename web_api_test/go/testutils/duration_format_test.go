@@ -0,0 +1,113 @@
+package testutils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d, precision time.Duration
+		want         string
+	}{
+		{0, 0, "0s"},
+		{800 * time.Nanosecond, 0, "800ns"},
+		{250 * time.Microsecond, 0, "250µs"},
+		{340 * time.Millisecond, 0, "340ms"},
+		{1500 * time.Microsecond, 0, "1.5ms"},
+		{1250 * time.Millisecond, 0, "1.25s"},
+		{1234567891 * time.Nanosecond, 10 * time.Millisecond, "1.23s"},
+		{1234567891 * time.Nanosecond, 0, "1.234567891s"},
+		{2*time.Minute + 3*time.Second, 0, "2m03s"},
+		{2*time.Minute + 3500*time.Millisecond, 0, "2m03.5s"},
+		{2*time.Minute + 3500*time.Millisecond, time.Second, "2m04s"},
+		{time.Hour + 2*time.Minute + 3*time.Second, 0, "1h02m03s"},
+		{90 * time.Minute, 0, "1h30m00s"},
+		{-1500 * time.Millisecond, 0, "-1.5s"},
+	} {
+		if got := FormatDuration(tc.d, tc.precision); got != tc.want {
+			t.Errorf("FormatDuration(%v, %v) = %q, want %q", tc.d, tc.precision, got, tc.want)
+		}
+	}
+
+	if got := DefaultConfig().Timer.FormatDuration(1234567891 * time.Nanosecond); got != "1.234568s" {
+		t.Errorf("expected microsecond precision from the default TimerConfig, got %q", got)
+	}
+}
+
+func TestFormatDuration_RoundTrips(t *testing.T) {
+	for _, d := range []time.Duration{1, 999, 1001, 123456789, 61*time.Second + 7, 3*time.Hour + 1, -42 * time.Millisecond} {
+		got, err := ParseFlexibleDuration(FormatDuration(d, 0))
+		if err != nil || got != d {
+			t.Errorf("%v formatted as %q parsed back as %v, %v", d, FormatDuration(d, 0), got, err)
+		}
+	}
+}
+
+func TestParseFlexibleDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"1h30m":  90 * time.Minute,
+		"1.5m":   90 * time.Second,
+		"2m03s":  123 * time.Second,
+		"90":     90 * time.Millisecond,
+		" 2.5 ":  2500 * time.Microsecond,
+		"-10":    -10 * time.Millisecond,
+		"250µs":  250 * time.Microsecond,
+		"0":      0,
+		"1h0m0s": time.Hour,
+	} {
+		got, err := ParseFlexibleDuration(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFlexibleDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "soon", "1x", "NaN", "1e300"} {
+		if _, err := ParseFlexibleDuration(in); err == nil {
+			t.Errorf("ParseFlexibleDuration(%q) should fail", in)
+		}
+	}
+}
+
+func TestDurationJSON_ResultsAreReadable(t *testing.T) {
+	result := WaitResult{
+		Host:      "localhost",
+		Port:      8080,
+		Success:   true,
+		Duration:  2*time.Minute + 3*time.Second,
+		FoundPort: &ConnectionResult{Host: "localhost", Port: 8080, Open: true, Latency: 340 * time.Millisecond},
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"duration":"2m03s"`, `"latency":"340ms"`, `"host":"localhost"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+	var back WaitResult
+	if err := json.Unmarshal(data, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.Duration != result.Duration || back.FoundPort.Latency != result.FoundPort.Latency || back.Host != "localhost" {
+		t.Errorf("round trip lost data: %+v", back)
+	}
+
+	// Documents written before the switch still load.
+	var legacy ConnectionResult
+	if err := json.Unmarshal([]byte(`{"port": 22, "latency": 1500000}`), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Latency != 1500*time.Microsecond || legacy.Port != 22 {
+		t.Errorf("numeric latency not read: %+v", legacy)
+	}
+
+	prev := SetNumericDurationJSON(true)
+	defer SetNumericDurationJSON(prev)
+	data, _ = json.Marshal(PortCheckResult{Port: 22, Latency: 1500 * time.Microsecond})
+	if !strings.Contains(string(data), `"latency":1500000`) {
+		t.Errorf("expected nanoseconds in numeric mode, got %s", data)
+	}
+}
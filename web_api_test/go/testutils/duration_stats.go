@@ -0,0 +1,163 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DurationCollection collects latency samples.
+type DurationCollection = NumberCollection[time.Duration]
+
+// NewDurationCollection creates a new duration collection
+func NewDurationCollection(values ...time.Duration) *DurationCollection {
+	return NewNumberCollection(values...)
+}
+
+// DurationStats summarises a set of durations. It marshals each figure both
+// as nanoseconds and as a human-readable string.
+type DurationStats struct {
+	Count  int
+	Min    time.Duration
+	Max    time.Duration
+	Mean   time.Duration
+	Median time.Duration
+	P90    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+	StdDev time.Duration
+}
+
+// NewDurationStats computes the summary of c. A nil or empty collection
+// yields the zero value.
+func NewDurationStats(c *DurationCollection) DurationStats {
+	if c == nil || c.Len() == 0 {
+		return DurationStats{}
+	}
+	pct := func(p float64) time.Duration {
+		v, _ := c.Percentile(p)
+		return time.Duration(math.Round(v))
+	}
+	min, _ := c.Min()
+	max, _ := c.Max()
+	return DurationStats{
+		Count:  c.Len(),
+		Min:    min,
+		Max:    max,
+		Mean:   time.Duration(math.Round(c.Mean())),
+		Median: time.Duration(math.Round(c.Median())),
+		P90:    pct(90),
+		P95:    pct(95),
+		P99:    pct(99),
+		StdDev: time.Duration(math.Round(c.StdDev())),
+	}
+}
+
+// String renders the summary on one line, e.g.
+// "n=20 min=1.02ms p50=3.4ms p95=9.87ms p99=12.1ms max=12.5ms".
+func (s DurationStats) String() string {
+	if s.Count == 0 {
+		return "n=0"
+	}
+	return fmt.Sprintf("n=%d min=%s p50=%s p95=%s p99=%s max=%s",
+		s.Count, HumanDuration(s.Min), HumanDuration(s.Median),
+		HumanDuration(s.P95), HumanDuration(s.P99), HumanDuration(s.Max))
+}
+
+// durationJSON is one figure in DurationStats' JSON form.
+type durationJSON struct {
+	Nanos int64  `json:"ns"`
+	Human string `json:"human"`
+}
+
+func newDurationJSON(d time.Duration) durationJSON {
+	return durationJSON{Nanos: int64(d), Human: HumanDuration(d)}
+}
+
+// durationStatsJSON is the wire form of DurationStats.
+type durationStatsJSON struct {
+	Count  int          `json:"count"`
+	Min    durationJSON `json:"min"`
+	Max    durationJSON `json:"max"`
+	Mean   durationJSON `json:"mean"`
+	Median durationJSON `json:"median"`
+	P90    durationJSON `json:"p90"`
+	P95    durationJSON `json:"p95"`
+	P99    durationJSON `json:"p99"`
+	StdDev durationJSON `json:"std_dev"`
+}
+
+// MarshalJSON emits every figure as {"ns": ..., "human": "..."}.
+func (s DurationStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(durationStatsJSON{
+		Count:  s.Count,
+		Min:    newDurationJSON(s.Min),
+		Max:    newDurationJSON(s.Max),
+		Mean:   newDurationJSON(s.Mean),
+		Median: newDurationJSON(s.Median),
+		P90:    newDurationJSON(s.P90),
+		P95:    newDurationJSON(s.P95),
+		P99:    newDurationJSON(s.P99),
+		StdDev: newDurationJSON(s.StdDev),
+	})
+}
+
+// UnmarshalJSON reads the form written by MarshalJSON, using the
+// nanosecond values.
+func (s *DurationStats) UnmarshalJSON(data []byte) error {
+	var raw durationStatsJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = DurationStats{
+		Count:  raw.Count,
+		Min:    time.Duration(raw.Min.Nanos),
+		Max:    time.Duration(raw.Max.Nanos),
+		Mean:   time.Duration(raw.Mean.Nanos),
+		Median: time.Duration(raw.Median.Nanos),
+		P90:    time.Duration(raw.P90.Nanos),
+		P95:    time.Duration(raw.P95.Nanos),
+		P99:    time.Duration(raw.P99.Nanos),
+		StdDev: time.Duration(raw.StdDev.Nanos),
+	}
+	return nil
+}
+
+// HumanDuration formats d with three significant digits, e.g. 1.23ms or
+// 45.7s. Unlike Duration in format.go it keeps sub-millisecond precision,
+// which latency figures need.
+func HumanDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	// Round two decimal places below the leading digit.
+	var unit time.Duration
+	switch {
+	case abs >= 100*time.Second:
+		unit = time.Second
+	case abs >= 10*time.Second:
+		unit = 100 * time.Millisecond
+	case abs >= time.Second:
+		unit = 10 * time.Millisecond
+	case abs >= 100*time.Millisecond:
+		unit = time.Millisecond
+	case abs >= 10*time.Millisecond:
+		unit = 100 * time.Microsecond
+	case abs >= time.Millisecond:
+		unit = 10 * time.Microsecond
+	case abs >= 100*time.Microsecond:
+		unit = time.Microsecond
+	case abs >= 10*time.Microsecond:
+		unit = 100 * time.Nanosecond
+	case abs >= time.Microsecond:
+		unit = 10 * time.Nanosecond
+	default:
+		unit = time.Nanosecond
+	}
+	return d.Round(unit).String()
+}
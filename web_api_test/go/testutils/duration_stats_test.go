@@ -0,0 +1,72 @@
+package testutils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHumanDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                                    "0s",
+		850 * time.Nanosecond:                "850ns",
+		1234567 * time.Nanosecond:            "1.23ms",
+		45678 * time.Microsecond:             "45.7ms",
+		2*time.Second + 345*time.Millisecond: "2.35s",
+		-1234 * time.Microsecond:             "-1.23ms",
+		3*time.Minute + 20*time.Second + 400*time.Millisecond: "3m20s",
+	}
+	for d, want := range tests {
+		if got := HumanDuration(d); got != want {
+			t.Errorf("HumanDuration(%d) = %q, want %q", int64(d), got, want)
+		}
+	}
+}
+
+func TestDurationStats_JSON(t *testing.T) {
+	c := NewDurationCollection()
+	for i := 1; i <= 100; i++ {
+		c.Add(time.Duration(i) * time.Millisecond)
+	}
+	stats := NewDurationStats(c)
+	if stats.Count != 100 || stats.Min != time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"p99":{"ns":99010000,"human":"99ms"}`) {
+		t.Errorf("p99 not emitted as ns and human string: %s", data)
+	}
+
+	var decoded DurationStats
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if decoded != stats {
+		t.Errorf("round trip changed stats:\n got %+v\nwant %+v", decoded, stats)
+	}
+}
+
+func TestStopwatch_Report(t *testing.T) {
+	clock := NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	sw := NewStopwatch("requests", clock)
+	for _, d := range []time.Duration{10, 20, 30} {
+		clock.Advance(d * time.Millisecond)
+		sw.Lap()
+	}
+
+	report := sw.Report()
+	if report.Elapsed != 60*time.Millisecond {
+		t.Errorf("expected 60ms elapsed, got %v", report.Elapsed)
+	}
+	if report.Laps.Median != 20*time.Millisecond {
+		t.Errorf("expected 20ms median lap, got %v", report.Laps.Median)
+	}
+	if want := "requests: elapsed=60ms n=3 min=10ms p50=20ms p95=29ms p99=29.8ms max=30ms"; report.String() != want {
+		t.Errorf("unexpected report\n got: %s\nwant: %s", report, want)
+	}
+}
@@ -0,0 +1,134 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ------------------------------------------------------------------------
+// Error kinds – a small taxonomy for errors.Is across the package
+// ------------------------------------------------------------------------
+
+// Kind sentinels. Errors returned by the package's managers and helpers
+// match one of these with errors.Is while keeping their original message:
+//
+//	if errors.Is(err, ErrTimeout) { ... }
+//
+// ErrTimeout, the timeout kind, predates the taxonomy and is declared in
+// lock.go.
+var (
+	ErrUnavailable   = errors.New("unavailable")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrPathTraversal = errors.New("path traversal")
+	ErrValidation    = errors.New("validation failed")
+	ErrCancelled     = errors.New("cancelled")
+)
+
+// ErrorKind names the class of an error, as reported by Kind.
+type ErrorKind string
+
+const (
+	KindNone          ErrorKind = ""
+	KindUnknown       ErrorKind = "unknown"
+	KindTimeout       ErrorKind = "timeout"
+	KindUnavailable   ErrorKind = "unavailable"
+	KindQuotaExceeded ErrorKind = "quota_exceeded"
+	KindPathTraversal ErrorKind = "path_traversal"
+	KindValidation    ErrorKind = "validation"
+	KindCancelled     ErrorKind = "cancelled"
+)
+
+// kindSentinels is in precedence order: an error wrapping several kinds,
+// such as a cancelled wait that had timed out attempts, reports the first.
+var kindSentinels = []struct {
+	err  error
+	kind ErrorKind
+}{
+	{ErrCancelled, KindCancelled},
+	{ErrTimeout, KindTimeout},
+	{ErrQuotaExceeded, KindQuotaExceeded},
+	{ErrPathTraversal, KindPathTraversal},
+	{ErrValidation, KindValidation},
+	{ErrUnavailable, KindUnavailable},
+}
+
+// Kind classifies err. Errors tagged with a kind sentinel report that kind;
+// untagged context, timeout and connection errors from the standard library
+// are recognised too. It returns KindNone for nil and KindUnknown for
+// anything else.
+func Kind(err error) ErrorKind {
+	if err == nil {
+		return KindNone
+	}
+	for _, s := range kindSentinels {
+		if errors.Is(err, s.err) {
+			return s.kind
+		}
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return KindCancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return KindTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return KindTimeout
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return KindUnavailable
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return KindUnavailable
+	}
+	return KindUnknown
+}
+
+// Sentinel returns the sentinel error for k, or nil for KindNone and
+// KindUnknown.
+func (k ErrorKind) Sentinel() error {
+	for _, s := range kindSentinels {
+		if s.kind == k {
+			return s.err
+		}
+	}
+	return nil
+}
+
+// kindError tags an error with a kind sentinel without changing its message.
+type kindError struct {
+	err  error
+	kind error
+}
+
+func (e *kindError) Error() string   { return e.err.Error() }
+func (e *kindError) Unwrap() []error { return []error{e.err, e.kind} }
+
+// withKind tags err with the kind sentinel. The message is unchanged and
+// errors.Is still matches everything err wrapped. nil stays nil.
+func withKind(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &kindError{err: err, kind: kind}
+}
+
+// kindErrorf is fmt.Errorf for an error of a known kind.
+func kindErrorf(kind error, format string, args ...any) error {
+	return withKind(kind, fmt.Errorf(format, args...))
+}
+
+// tagKind tags err with the sentinel for whatever Kind recognises in it, so
+// errors.Is(err, ErrTimeout) holds for a raw dial timeout as well.
+func tagKind(err error) error {
+	if s := Kind(err).Sentinel(); s != nil {
+		return withKind(s, err)
+	}
+	return err
+}
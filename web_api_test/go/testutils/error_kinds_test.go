@@ -0,0 +1,152 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestKind_Classification(t *testing.T) {
+	inner := errors.New("disk full")
+	for _, tc := range []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, KindNone},
+		{"plain", errors.New("boom"), KindUnknown},
+		{"context canceled", fmt.Errorf("stop: %w", context.Canceled), KindCancelled},
+		{"deadline", context.DeadlineExceeded, KindTimeout},
+		{"os deadline", os.ErrDeadlineExceeded, KindTimeout},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, KindUnavailable},
+		{"dns", &net.DNSError{Name: "nowhere.invalid", IsNotFound: true}, KindUnavailable},
+		{"tagged", withKind(ErrQuotaExceeded, inner), KindQuotaExceeded},
+		{"precedence", errors.Join(ErrUnavailable, ErrCancelled), KindCancelled},
+	} {
+		if got := Kind(tc.err); got != tc.want {
+			t.Errorf("%s: Kind = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestWithKind_KeepsMessageAndChain(t *testing.T) {
+	inner := errors.New("disk full")
+	err := withKind(ErrQuotaExceeded, fmt.Errorf("write failed: %w", inner))
+	if err.Error() != "write failed: disk full" {
+		t.Errorf("message = %q", err.Error())
+	}
+	if !errors.Is(err, ErrQuotaExceeded) || !errors.Is(err, inner) {
+		t.Errorf("errors.Is lost a target: %v", err)
+	}
+	if withKind(ErrTimeout, nil) != nil {
+		t.Error("withKind(nil) should stay nil")
+	}
+	if again := withKind(ErrQuotaExceeded, err); again != err {
+		t.Error("re-tagging with the same kind should not wrap again")
+	}
+	if KindUnknown.Sentinel() != nil || KindTimeout.Sentinel() != ErrTimeout {
+		t.Error("Sentinel mapping is wrong")
+	}
+}
+
+// TestErrorKinds_ExistingSites pins the kind and the exact message of
+// errors that predate the taxonomy, so callers matching on either keep
+// working.
+func TestErrorKinds_ExistingSites(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:   time.Second,
+		RetryInterval: time.Millisecond,
+		MinPort:       1000,
+		MaxPort:       65535,
+		ValidatePorts: true,
+	})
+	_, rangeErr := pc.IsPortOpen(context.Background(), "127.0.0.1", 80, TCP)
+
+	waitErr := WaitFor(context.Background(), "never", time.Millisecond,
+		func(context.Context) (bool, string, error) { return false, "", nil },
+		WithWaitTimeout(5*time.Millisecond))
+	pollErr := Poll(&PollConfig{Interval: time.Millisecond, Timeout: 5 * time.Millisecond},
+		func(int) bool { return false })
+
+	tdm := newTestManager(t, "kinds", nil, &TestDataManagerConfig{MaxFileSize: 4})
+	_, traversalErr := tdm.CreateTestFile("../escape.txt", "x")
+	_, sizeErr := tdm.CreateTestFile("big.txt", "12345")
+	limited := newTestManager(t, "kinds", nil, &TestDataManagerConfig{MaxFiles: 1})
+	if _, err := limited.CreateTestFile("a.txt", "a"); err != nil {
+		t.Fatal(err)
+	}
+	_, countErr := limited.CreateTestFile("b.txt", "b")
+	_, serverErr := NewServerManager(ServerConfig{}, "http://localhost:8080", discardServerLogger{})
+	_, dockerErr := NewDockerManager(nil, nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+	portErr := checkPortAvailable("127.0.0.1", busy)
+
+	for _, tc := range []struct {
+		name string
+		err  error
+		kind error
+		msg  string
+	}{
+		{"ValidatePort", ValidatePort(70000), ErrValidation, "port 70000 outside valid range [1-65535]"},
+		{"allowed range", rangeErr, ErrValidation, "port 80 outside allowed range [1000-65535]"},
+		{"WaitFor timeout", waitErr, ErrTimeout, ""},
+		{"Poll timeout", pollErr, ErrTimeout, "polling timed out after 5ms"},
+		{"path traversal", traversalErr, ErrPathTraversal, `invalid filename "../escape.txt": path traversal out of test root attempted`},
+		{"file size quota", sizeErr, ErrQuotaExceeded,
+			fmt.Sprintf("file %q is 5 bytes, exceeding the 4 byte limit", filepath.Join(tdm.GetTestDir(), "big.txt"))},
+		{"file count quota", countErr, ErrQuotaExceeded, fmt.Sprintf("file limit of 1 reached in %q", limited.GetTestDir())},
+		{"ServerManager config", serverErr, ErrValidation, "server path cannot be empty"},
+		{"DockerManager config", dockerErr, ErrValidation, "test config cannot be nil"},
+		{"port in use", portErr, ErrUnavailable, ""},
+	} {
+		if !errors.Is(tc.err, tc.kind) {
+			t.Errorf("%s: %v does not match %v", tc.name, tc.err, tc.kind)
+		}
+		if tc.msg != "" && tc.err.Error() != tc.msg {
+			t.Errorf("%s: message = %q, want %q", tc.name, tc.err.Error(), tc.msg)
+		}
+	}
+	var mu Mutex
+	mu.Lock()
+	if err := mu.TryLockTimeout(time.Millisecond); err != ErrTimeout || err.Error() != "lock: timeout acquiring mutex" || Kind(err) != KindTimeout {
+		t.Errorf("lock timeout = %v (kind %q), want the original ErrTimeout", err, Kind(err))
+	}
+	if want := fmt.Sprintf("port %d on 127.0.0.1 is already in use: ", busy); portErr == nil || !strings.HasPrefix(portErr.Error(), want) {
+		t.Errorf("port in use: message = %v, want prefix %q", portErr, want)
+	}
+	if !errors.Is(waitErr, errWaitTimeout) {
+		t.Errorf("WaitFor timeout no longer matches errWaitTimeout: %v", waitErr)
+	}
+}
+
+func TestPortChecker_ClosedPortIsUnavailable(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:     time.Second,
+		RetryInterval:   time.Millisecond,
+		MaxRetries:      1,
+		PerCheckTimeout: 5 * time.Second,
+		MinPort:         1,
+		MaxPort:         65535,
+	})
+
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP)
+	if !errors.Is(err, ErrUnavailable) || Kind(err) != KindUnavailable {
+		t.Fatalf("err = %v (kind %q)", err, Kind(err))
+	}
+	if result.ErrorKind != KindUnavailable || result.ErrorType != "connection_failed" {
+		t.Errorf("ErrorKind = %q, ErrorType = %q", result.ErrorKind, result.ErrorType)
+	}
+}
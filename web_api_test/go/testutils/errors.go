@@ -135,6 +135,11 @@ type CompositeError struct {
 	Prefix    string          `json:"prefix,omitempty"`
 	Metadata  ErrorMetadata   `json:"metadata"`
 	createdAt time.Time
+
+	// GroupSimilar makes Error collapse errors that differ only in the
+	// hosts and ports they mention into one line with a count and sample
+	// targets, see Groups. The errors themselves are kept as added.
+	GroupSimilar bool `json:"-"`
 }
 
 // NewCompositeError creates a new CompositeError
@@ -173,6 +178,13 @@ func (ce *CompositeError) Error() string {
 	}
 
 	builder.WriteString(fmt.Sprintf("%d error(s) occurred", len(ce.Errors)))
+	var groups []ErrorGroup
+	if ce.GroupSimilar {
+		groups = ce.groupsLocked()
+		if len(groups) < len(ce.Errors) {
+			builder.WriteString(fmt.Sprintf(", %d distinct", len(groups)))
+		}
+	}
 
 	// Add metadata summary
 	if ce.Metadata.Component != "" || ce.Metadata.Operation != "" {
@@ -191,43 +203,67 @@ func (ce *CompositeError) Error() string {
 
 	builder.WriteString(":\n")
 
+	if groups != nil {
+		for i, g := range groups {
+			if g.Count == 1 {
+				writeErrorEntry(&builder, i, g.Errors[0])
+				continue
+			}
+			builder.WriteString(fmt.Sprintf("  %d. [%s] ", i+1, severityNames[g.severity()]))
+			if code := g.Errors[0].Metadata.Code; code != "" {
+				builder.WriteString(fmt.Sprintf("(%s) ", code))
+			}
+			builder.WriteString(fmt.Sprintf("%s ×%d", g.Message, g.Count))
+			if summary := g.summary(); summary != "" {
+				builder.WriteString(" (" + summary + ")")
+			}
+			builder.WriteString("\n")
+		}
+		return builder.String()
+	}
 	for i, wrappedErr := range ce.Errors {
-		builder.WriteString(fmt.Sprintf("  %d. ", i+1))
+		writeErrorEntry(&builder, i, wrappedErr)
+	}
 
-		// Add error severity if available
-		severity := severityNames[wrappedErr.Metadata.Severity]
-		builder.WriteString(fmt.Sprintf("[%s] ", severity))
+	return builder.String()
+}
 
-		// Add error code if available
-		if wrappedErr.Metadata.Code != "" {
-			builder.WriteString(fmt.Sprintf("(%s) ", wrappedErr.Metadata.Code))
-		}
+// writeErrorEntry writes the i-th line of CompositeError.Error, with the
+// context of wrappedErr below it.
+func writeErrorEntry(builder *strings.Builder, i int, wrappedErr *WrappedError) {
+	builder.WriteString(fmt.Sprintf("  %d. ", i+1))
 
-		builder.WriteString(wrappedErr.error.Error())
+	// Add error severity if available
+	severity := severityNames[wrappedErr.Metadata.Severity]
+	builder.WriteString(fmt.Sprintf("[%s] ", severity))
 
-		// Add timestamp if it's recent (within last hour)
-		if time.Since(wrappedErr.Metadata.Timestamp) < time.Hour {
-			builder.WriteString(fmt.Sprintf(" (at %s)", wrappedErr.Metadata.Timestamp.Format("15:04:05")))
-		}
+	// Add error code if available
+	if wrappedErr.Metadata.Code != "" {
+		builder.WriteString(fmt.Sprintf("(%s) ", wrappedErr.Metadata.Code))
+	}
 
-		builder.WriteString("\n")
+	builder.WriteString(wrappedErr.error.Error())
 
-		// Add context if available
-		if len(wrappedErr.Metadata.Context) > 0 {
-			builder.WriteString("     Context: ")
-			first := true
-			for k, v := range wrappedErr.Metadata.Context {
-				if !first {
-					builder.WriteString(", ")
-				}
-				builder.WriteString(fmt.Sprintf("%s=%v", k, v))
-				first = false
+	// Add timestamp if it's recent (within last hour)
+	if time.Since(wrappedErr.Metadata.Timestamp) < time.Hour {
+		builder.WriteString(fmt.Sprintf(" (at %s)", wrappedErr.Metadata.Timestamp.Format("15:04:05")))
+	}
+
+	builder.WriteString("\n")
+
+	// Add context if available
+	if len(wrappedErr.Metadata.Context) > 0 {
+		builder.WriteString("     Context: ")
+		first := true
+		for k, v := range wrappedErr.Metadata.Context {
+			if !first {
+				builder.WriteString(", ")
 			}
-			builder.WriteString("\n")
+			builder.WriteString(fmt.Sprintf("%s=%v", k, v))
+			first = false
 		}
+		builder.WriteString("\n")
 	}
-
-	return builder.String()
 }
 
 // Add adds an error to the composite error with optional metadata
@@ -0,0 +1,204 @@
+package testutils
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------------------------------------
+// Grouping similar errors in a CompositeError
+// ------------------------------------------------------------------------
+
+// ErrorGroup is a set of errors in a CompositeError that differ only in
+// the hosts and ports they mention, such as one "connection refused" per
+// port of a range scan.
+type ErrorGroup struct {
+	Kind    ErrorKind // Kind of every member
+	Message string    // member message with differing hosts and ports as "*"
+	Count   int
+	Ports   []int    // distinct ports mentioned, in order of appearance
+	Hosts   []string // distinct hosts mentioned, in order of appearance
+	Errors  []*WrappedError
+}
+
+// groupSampleSize is how many ports or hosts a group's summary lists.
+const groupSampleSize = 3
+
+// errorTargetPattern matches the targets stripped before comparing
+// messages: a bracketed IPv6 host:port, an IPv4 address with an optional
+// port, a host name with a port, or "port N".
+var errorTargetPattern = regexp.MustCompile(
+	`\[[0-9A-Fa-f:.]+(?:%[\w.-]+)?\]:\d+` +
+		`|\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b` +
+		`|\b[A-Za-z][A-Za-z0-9-]*(?:\.[A-Za-z0-9-]+)*:\d+\b` +
+		`|\bport \d+\b`)
+
+// errorTarget is one host and/or port found in a message.
+type errorTarget struct {
+	host string
+	port int // -1 if none
+}
+
+// splitErrorTargets splits msg into the text around its targets and the
+// targets themselves; len(text) == len(targets)+1.
+func splitErrorTargets(msg string) (text []string, targets []errorTarget) {
+	last := 0
+	for _, loc := range errorTargetPattern.FindAllStringIndex(msg, -1) {
+		text = append(text, msg[last:loc[0]])
+		targets = append(targets, parseErrorTarget(msg[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	return append(text, msg[last:]), targets
+}
+
+func parseErrorTarget(s string) errorTarget {
+	if p, ok := strings.CutPrefix(s, "port "); ok {
+		port, _ := strconv.Atoi(p)
+		return errorTarget{port: port}
+	}
+	host, p, err := net.SplitHostPort(s)
+	if err != nil {
+		return errorTarget{host: s, port: -1}
+	}
+	port, _ := strconv.Atoi(p)
+	return errorTarget{host: host, port: port}
+}
+
+// format writes t the way it appeared, with the parts that differ across
+// a group replaced by "*".
+func (t errorTarget) format(sameHost, samePort bool) string {
+	host, port := t.host, strconv.Itoa(t.port)
+	if !sameHost {
+		host = "*"
+	}
+	if !samePort {
+		port = "*"
+	}
+	switch {
+	case t.host == "":
+		return "port " + port
+	case t.port < 0:
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// Groups returns the errors grouped by Kind, code and message with hosts
+// and ports stripped, in order of first appearance. It does not depend on
+// GroupSimilar.
+func (ce *CompositeError) Groups() []ErrorGroup {
+	ce.mu.RLock()
+	defer ce.mu.RUnlock()
+	return ce.groupsLocked()
+}
+
+func (ce *CompositeError) groupsLocked() []ErrorGroup {
+	type pending struct {
+		group   ErrorGroup
+		text    []string
+		targets [][]errorTarget // per member
+	}
+	var order []*pending
+	byKey := make(map[string]*pending)
+	for _, we := range ce.Errors {
+		text, targets := splitErrorTargets(we.error.Error())
+		kind := Kind(we.error)
+		key := string(kind) + "\x00" + we.Metadata.Code + "\x00" + strings.Join(text, "\x00")
+		p, ok := byKey[key]
+		if !ok {
+			p = &pending{group: ErrorGroup{Kind: kind}, text: text}
+			byKey[key] = p
+			order = append(order, p)
+		}
+		p.group.Count++
+		p.group.Errors = append(p.group.Errors, we)
+		p.targets = append(p.targets, targets)
+	}
+
+	groups := make([]ErrorGroup, len(order))
+	for i, p := range order {
+		g := p.group
+		seenPort, seenHost := make(map[int]bool), make(map[string]bool)
+		for _, targets := range p.targets {
+			for _, t := range targets {
+				if t.port >= 0 && !seenPort[t.port] {
+					seenPort[t.port] = true
+					g.Ports = append(g.Ports, t.port)
+				}
+				if t.host != "" && !seenHost[t.host] {
+					seenHost[t.host] = true
+					g.Hosts = append(g.Hosts, t.host)
+				}
+			}
+		}
+
+		var msg strings.Builder
+		first := p.targets[0]
+		for slot, t := range first {
+			sameHost, samePort := true, true
+			for _, targets := range p.targets[1:] {
+				sameHost = sameHost && targets[slot].host == t.host
+				samePort = samePort && targets[slot].port == t.port
+			}
+			msg.WriteString(p.text[slot])
+			msg.WriteString(t.format(sameHost, samePort))
+		}
+		msg.WriteString(p.text[len(first)])
+		g.Message = msg.String()
+		groups[i] = g
+	}
+	return groups
+}
+
+// severity returns the highest severity among the members.
+func (g ErrorGroup) severity() ErrorSeverity {
+	var highest ErrorSeverity
+	for _, we := range g.Errors {
+		if we.Metadata.Severity > highest {
+			highest = we.Metadata.Severity
+		}
+	}
+	return highest
+}
+
+// summary describes the targets of a group, e.g.
+// "ports 1000–10999, e.g. 1000, 1001, 1002, ...".
+func (g ErrorGroup) summary() string {
+	var parts []string
+	if len(g.Ports) > 1 {
+		sorted := append([]int(nil), g.Ports...)
+		sort.Ints(sorted)
+		ports := make([]string, 0, groupSampleSize)
+		for _, p := range g.Ports {
+			if len(ports) == groupSampleSize {
+				break
+			}
+			ports = append(ports, strconv.Itoa(p))
+		}
+		if len(g.Ports) <= groupSampleSize {
+			parts = append(parts, "ports "+strings.Join(ports, ", "))
+		} else {
+			parts = append(parts, fmt.Sprintf("ports %d–%d, e.g. %s, ...",
+				sorted[0], sorted[len(sorted)-1], strings.Join(ports, ", ")))
+		}
+	}
+	if len(g.Hosts) > 1 {
+		if len(g.Hosts) <= groupSampleSize {
+			parts = append(parts, "hosts "+strings.Join(g.Hosts, ", "))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d hosts, e.g. %s, ...",
+				len(g.Hosts), strings.Join(g.Hosts[:groupSampleSize], ", ")))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// AllErrors returns every error added, in order, also when GroupSimilar
+// collapses them in Error. It is the same as All.
+func (ce *CompositeError) AllErrors() []error {
+	return ce.All()
+}
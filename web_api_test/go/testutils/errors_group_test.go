@@ -0,0 +1,146 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func refusedError(host string, port int) error {
+	addr := net.JoinHostPort(host, fmt.Sprint(port))
+	return withKind(ErrUnavailable, &net.OpError{Op: "dial", Net: "tcp", Addr: stringAddr(addr), Err: syscall.ECONNREFUSED})
+}
+
+type stringAddr string
+
+func (a stringAddr) Network() string { return "tcp" }
+func (a stringAddr) String() string  { return string(a) }
+
+func TestCompositeError_GroupSimilar(t *testing.T) {
+	ce := NewCompositeError("port check errors")
+	ce.GroupSimilar = true
+	for port := 1000; port < 11000; port++ {
+		if port%700 == 0 {
+			ce.Add(kindErrorf(ErrTimeout, "dial tcp 127.0.0.1:%d: i/o timeout", port))
+			continue
+		}
+		ce.Add(refusedError("127.0.0.1", port))
+	}
+	ce.Add(errors.New("scan aborted"))
+
+	groups := ce.Groups()
+	if len(groups) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(groups))
+	}
+	refused := groups[0]
+	if refused.Kind != KindUnavailable || refused.Count != 9986 || len(refused.Ports) != 9986 ||
+		!reflect.DeepEqual(refused.Hosts, []string{"127.0.0.1"}) {
+		t.Errorf("unexpected refused group: %v, %d errors, %d ports, hosts %v",
+			refused.Kind, refused.Count, len(refused.Ports), refused.Hosts)
+	}
+	if groups[1].Kind != KindTimeout || groups[1].Count != 14 || groups[2].Count != 1 {
+		t.Errorf("unexpected groups %v ×%d, %v ×%d", groups[1].Kind, groups[1].Count, groups[2].Kind, groups[2].Count)
+	}
+
+	msg := ce.Error()
+	for _, want := range []string{
+		"10001 error(s) occurred, 3 distinct:",
+		"1. [MEDIUM] dial tcp 127.0.0.1:*: connection refused ×9986 (ports 1000–10999, e.g. 1000, 1001, 1002, ...)",
+		"2. [MEDIUM] dial tcp 127.0.0.1:*: i/o timeout ×14 (ports 1400–10500, e.g. 1400, 2100, 2800, ...)",
+		"3. [MEDIUM] scan aborted (at ",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in:\n%s", want, msg)
+		}
+	}
+	if n := strings.Count(msg, "\n"); n != 4 {
+		t.Errorf("expected 4 lines, got %d:\n%s", n, msg)
+	}
+
+	if all := ce.AllErrors(); len(all) != 10001 || all[1].Error() != "dial tcp 127.0.0.1:1001: connection refused" {
+		t.Errorf("AllErrors must keep every error as added, got %d", len(all))
+	}
+	if !errors.Is(ce, syscall.ECONNREFUSED) || !errors.Is(ce, ErrTimeout) {
+		t.Error("errors.Is must still match grouped members")
+	}
+	var opErr *net.OpError
+	if !errors.As(ce, &opErr) || opErr.Addr.String() != "127.0.0.1:1000" {
+		t.Errorf("errors.As must reach the first grouped member, got %v", opErr)
+	}
+}
+
+func TestCompositeError_GroupingHeuristics(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		errs []error
+		want []string // group messages
+	}{
+		{
+			"hosts differ, port shared",
+			[]error{refusedError("10.0.0.1", 5432), refusedError("10.0.0.2", 5432), refusedError("::1", 5432)},
+			[]string{"dial tcp *:5432: connection refused"},
+		},
+		{
+			"IPv6 and host names",
+			[]error{refusedError("::1", 80), refusedError("fe80::1%eth0", 81), refusedError("db.internal", 82)},
+			[]string{"dial tcp *:*: connection refused"},
+		},
+		{
+			"port N",
+			[]error{fmt.Errorf("port 80: closed"), fmt.Errorf("port 443: closed"), fmt.Errorf("port 8080: filtered")},
+			[]string{"port *: closed", "port 8080: filtered"},
+		},
+		{
+			"kinds kept apart",
+			[]error{kindErrorf(ErrTimeout, "check db:5432 failed"), kindErrorf(ErrUnavailable, "check db:5433 failed")},
+			[]string{"check db:5432 failed", "check db:5433 failed"},
+		},
+		{
+			"other numbers are significant",
+			[]error{errors.New("status 500 from api:80"), errors.New("status 502 from api:81")},
+			[]string{"status 500 from api:80", "status 502 from api:81"},
+		},
+	} {
+		ce := NewCompositeError("")
+		for _, err := range tc.errs {
+			ce.Add(err)
+		}
+		var got []string
+		for _, g := range ce.Groups() {
+			got = append(got, g.Message)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: groups %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// Codes split groups too.
+	ce := NewCompositeError("")
+	ce.Add(errors.New("dial db:1: refused"), WithErrorCode("E1"))
+	ce.Add(errors.New("dial db:2: refused"), WithErrorCode("E2"))
+	if n := len(ce.Groups()); n != 2 {
+		t.Errorf("errors with different codes grouped together: %d groups", n)
+	}
+}
+
+func TestCompositeError_GroupSummary(t *testing.T) {
+	ce := NewCompositeError("")
+	ce.GroupSimilar = true
+	for _, host := range []string{"a.svc", "b.svc", "c.svc", "d.svc"} {
+		ce.Add(fmt.Errorf("dial %s:6379: refused", host), WithSeverity(SeverityLow))
+	}
+	ce.Add(fmt.Errorf("dial e.svc:6379: refused"), WithSeverity(SeverityHigh))
+	want := "1. [HIGH] dial *:6379: refused ×5 (5 hosts, e.g. a.svc, b.svc, c.svc, ...)"
+	if msg := ce.Error(); !strings.Contains(msg, want) {
+		t.Errorf("missing %q in:\n%s", want, msg)
+	}
+
+	ce.GroupSimilar = false
+	if msg := ce.Error(); strings.Contains(msg, "×") || strings.Count(msg, "\n") != 6 {
+		t.Errorf("without GroupSimilar every error gets a line:\n%s", msg)
+	}
+}
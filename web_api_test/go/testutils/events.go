@@ -0,0 +1,255 @@
+package testutils
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Lifecycle event types published by the managers in this package.
+const (
+	EventDockerStarting = "docker_starting"
+	EventDockerHealthy  = "docker_healthy"
+	EventDockerStopped  = "docker_stopped"
+	EventServerStarted  = "server_started"
+	EventServerReady    = "server_ready"
+	EventServerStopped  = "server_stopped"
+	EventModeChanged    = "mode_changed"
+	EventCleanupDone    = "cleanup_done"
+	EventHealthChanged  = "health_changed"
+	EventPortOpen       = "port_open"
+)
+
+// defaultEventBuffer is the channel capacity of each subscription.
+const defaultEventBuffer = 256
+
+// Event is a structured lifecycle event.
+type Event struct {
+	Type   string         `json:"type"`
+	Source string         `json:"source"`
+	Fields map[string]any `json:"fields,omitempty"`
+	Time   time.Time      `json:"time"`
+	Seq    uint64         `json:"seq"` // publish order, starting at 1
+}
+
+// EventBus fans lifecycle events out to subscribers and keeps a history for
+// ordering assertions. Publishing never blocks: a subscriber whose buffer is
+// full misses the event, which is counted in Dropped. A nil *EventBus is a
+// valid no-op bus, so managers can publish unconditionally.
+type EventBus struct {
+	mu      sync.Mutex
+	subs    map[chan Event]map[string]bool // nil type set means all types
+	history []Event
+	seq     uint64
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// NewEventBus creates an empty bus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]map[string]bool)}
+}
+
+// Publish records the event and delivers it to matching subscribers. Time is
+// set to now if zero.
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.seq++
+	e.Seq = b.seq
+	b.history = append(b.history, e)
+
+	for ch, types := range b.subs {
+		if types != nil && !types[e.Type] {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Emit is shorthand for Publish(Event{Type: typ, Source: source, Fields: fields}).
+func (b *EventBus) Emit(typ, source string, fields map[string]any) {
+	b.Publish(Event{Type: typ, Source: source, Fields: fields})
+}
+
+// Subscribe returns a channel receiving events of the given types, or all
+// events when no types are given. The channel is closed by Unsubscribe or
+// Close; no goroutine is started per subscription.
+func (b *EventBus) Subscribe(types ...string) <-chan Event {
+	ch := make(chan Event, defaultEventBuffer)
+	if b == nil {
+		close(ch)
+		return ch
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch
+	}
+	var set map[string]bool
+	if len(types) > 0 {
+		set = make(map[string]bool, len(types))
+		for _, t := range types {
+			set[t] = true
+		}
+	}
+	b.subs[ch] = set
+	return ch
+}
+
+// Unsubscribe removes the subscription and closes its channel.
+func (b *EventBus) Unsubscribe(sub <-chan Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		if (<-chan Event)(ch) == sub {
+			delete(b.subs, ch)
+			close(ch)
+			return
+		}
+	}
+}
+
+// History returns a copy of every event published so far, in order.
+func (b *EventBus) History() []Event {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Event, len(b.history))
+	copy(out, b.history)
+	return out
+}
+
+// Dropped returns how many deliveries were skipped because a subscriber's
+// buffer was full.
+func (b *EventBus) Dropped() uint64 {
+	if b == nil {
+		return 0
+	}
+	return b.dropped.Load()
+}
+
+// Close closes every subscription channel. Further publishes are ignored.
+func (b *EventBus) Close() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for ch := range b.subs {
+		close(ch)
+	}
+	b.subs = nil
+	return nil
+}
+
+// find returns the first event of type typ in history.
+func (b *EventBus) find(typ string) (Event, bool) {
+	for _, e := range b.History() {
+		if e.Type == typ {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// --------------------------------------------------------------------
+// EventAssertions
+// --------------------------------------------------------------------
+
+// EventAssertions provides convenience methods for verifying lifecycle events.
+type EventAssertions struct {
+	t   testingT
+	bus *EventBus
+}
+
+// NewEventAssertions creates a new assertion helper for bus.
+func NewEventAssertions(t testingT, bus *EventBus) *EventAssertions {
+	return &EventAssertions{t: t, bus: bus}
+}
+
+// WaitFor returns the first event of the given type, waiting up to timeout
+// for it to be published. It reports a test error on timeout.
+func (a *EventAssertions) WaitFor(eventType string, timeout time.Duration) (Event, bool) {
+	// Subscribe before checking history so an event published in between is
+	// not missed.
+	sub := a.bus.Subscribe(eventType)
+	defer a.bus.Unsubscribe(sub)
+
+	if e, ok := a.bus.find(eventType); ok {
+		return e, true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case e, ok := <-sub:
+		if ok {
+			return e, true
+		}
+		a.t.Errorf("event bus closed while waiting for %q", eventType)
+	case <-timer.C:
+		a.t.Errorf("timed out after %v waiting for event %q", timeout, eventType)
+	}
+	return Event{}, false
+}
+
+// AssertPublished asserts that at least one event of the given type was published.
+func (a *EventAssertions) AssertPublished(eventType string) {
+	if _, ok := a.bus.find(eventType); !ok {
+		a.t.Errorf("expected event %q to be published, but it wasn't", eventType)
+	}
+}
+
+// AssertNotPublished asserts that no event of the given type was published.
+func (a *EventAssertions) AssertNotPublished(eventType string) {
+	if e, ok := a.bus.find(eventType); ok {
+		a.t.Errorf("expected event %q not to be published, but it was (source %s)", eventType, e.Source)
+	}
+}
+
+// AssertBefore asserts that the first event of type first was published
+// before the first event of type second.
+func (a *EventAssertions) AssertBefore(first, second string) {
+	e1, ok1 := a.bus.find(first)
+	e2, ok2 := a.bus.find(second)
+	switch {
+	case !ok1:
+		a.t.Errorf("expected event %q before %q, but %q was never published", first, second, first)
+	case !ok2:
+		a.t.Errorf("expected event %q before %q, but %q was never published", first, second, second)
+	case e1.Seq > e2.Seq:
+		a.t.Errorf("expected event %q (seq %d) before %q (seq %d)", first, e1.Seq, second, e2.Seq)
+	}
+}
+
+// AssertOrder asserts that the first occurrences of the given types were
+// published in the given order.
+func (a *EventAssertions) AssertOrder(types ...string) {
+	for i := 1; i < len(types); i++ {
+		a.AssertBefore(types[i-1], types[i])
+	}
+}
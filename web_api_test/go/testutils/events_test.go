@@ -0,0 +1,228 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// drain collects events from sub until it is closed.
+func drain(sub <-chan Event) <-chan []Event {
+	out := make(chan []Event, 1)
+	go func() {
+		var got []Event
+		for e := range sub {
+			got = append(got, e)
+		}
+		out <- got
+	}()
+	return out
+}
+
+func eventTypes(events []Event) []string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestEventBus_SubscribeUnsubscribeClose(t *testing.T) {
+	VerifyNoLeaks(t)
+	bus := NewEventBus()
+	all := drain(bus.Subscribe())
+	docker := drain(bus.Subscribe(EventDockerHealthy, EventDockerStopped))
+
+	gone := bus.Subscribe()
+	bus.Unsubscribe(gone)
+	if _, ok := <-gone; ok {
+		t.Error("Unsubscribe left the channel open")
+	}
+
+	bus.Emit(EventDockerStarting, "docker", nil)
+	bus.Emit(EventDockerHealthy, "docker", map[string]any{"services": []string{"db"}})
+	bus.Emit(EventServerReady, "server", nil)
+	if err := bus.Close(); err != nil {
+		t.Fatal(err)
+	}
+	bus.Emit(EventDockerStopped, "docker", nil)
+
+	got := <-all
+	if types := strings.Join(eventTypes(got), ","); types != "docker_starting,docker_healthy,server_ready" {
+		t.Errorf("unfiltered subscriber got %s", types)
+	}
+	for i, e := range got {
+		if e.Seq != uint64(i+1) || e.Time.IsZero() {
+			t.Errorf("event %d has seq %d, time %v", i, e.Seq, e.Time)
+		}
+	}
+	if got := <-docker; len(got) != 1 || got[0].Type != EventDockerHealthy {
+		t.Errorf("filtered subscriber got %v", eventTypes(got))
+	}
+	if n := len(bus.History()); n != 3 {
+		t.Errorf("history has %d events, want the 3 published before Close", n)
+	}
+	if _, ok := <-bus.Subscribe(); ok {
+		t.Error("Subscribe after Close returned an open channel")
+	}
+	if err := bus.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestEventBus_Nil(t *testing.T) {
+	var bus *EventBus
+	bus.Emit(EventServerReady, "server", nil)
+	if _, ok := <-bus.Subscribe(); ok {
+		t.Error("a nil bus returned an open subscription")
+	}
+	if bus.History() != nil || bus.Dropped() != 0 || bus.Close() != nil {
+		t.Error("a nil bus is not a no-op")
+	}
+}
+
+func TestEventBus_Dropped(t *testing.T) {
+	bus := NewEventBus()
+	full := bus.Subscribe()
+	bus.Subscribe(EventModeChanged) // never matches, so never drops
+
+	for i := 0; i < defaultEventBuffer+3; i++ {
+		bus.Emit(EventHealthChanged, "health", map[string]any{"i": i})
+	}
+	if n := bus.Dropped(); n != 3 {
+		t.Errorf("Dropped() = %d, want 3", n)
+	}
+	if n := len(full); n != defaultEventBuffer {
+		t.Errorf("subscriber buffered %d events, want %d", n, defaultEventBuffer)
+	}
+	if n := len(bus.History()); n != defaultEventBuffer+3 {
+		t.Errorf("history has %d events; drops must not affect it", n)
+	}
+}
+
+func TestEventAssertions_WaitFor(t *testing.T) {
+	VerifyNoLeaks(t)
+
+	t.Run("AlreadyPublished", func(t *testing.T) {
+		bus := NewEventBus()
+		bus.Emit(EventServerReady, "server", nil)
+		if e, ok := NewEventAssertions(t, bus).WaitFor(EventServerReady, time.Second); !ok || e.Seq != 1 {
+			t.Errorf("WaitFor = %+v, %v", e, ok)
+		}
+	})
+
+	t.Run("PublishedLater", func(t *testing.T) {
+		bus := NewEventBus()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			bus.Emit(EventDockerStarting, "docker", nil)
+			bus.Emit(EventDockerHealthy, "docker", nil)
+		}()
+		e, ok := NewEventAssertions(t, bus).WaitFor(EventDockerHealthy, 5*time.Second)
+		if !ok || e.Type != EventDockerHealthy || e.Seq != 2 {
+			t.Errorf("WaitFor = %+v, %v", e, ok)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		bus := NewEventBus()
+		rt := &recordingT{}
+		if _, ok := NewEventAssertions(rt, bus).WaitFor(EventServerReady, 10*time.Millisecond); ok {
+			t.Error("WaitFor succeeded without the event")
+		}
+		if f := rt.failures(); len(f) != 1 || !strings.Contains(f[0], "timed out after 10ms") {
+			t.Errorf("failures %q", f)
+		}
+		bus.mu.Lock()
+		defer bus.mu.Unlock()
+		if len(bus.subs) != 0 {
+			t.Errorf("WaitFor left %d subscription(s) behind", len(bus.subs))
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		bus := NewEventBus()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			bus.Close()
+		}()
+		rt := &recordingT{}
+		start := time.Now()
+		if _, ok := NewEventAssertions(rt, bus).WaitFor(EventServerReady, time.Minute); ok {
+			t.Error("WaitFor succeeded on a closed bus")
+		}
+		if f := rt.failures(); len(f) != 1 || !strings.Contains(f[0], "event bus closed") {
+			t.Errorf("failures %q", f)
+		}
+		if waited := time.Since(start); waited > 10*time.Second {
+			t.Errorf("WaitFor waited %v after the bus closed", waited)
+		}
+	})
+}
+
+func TestEventAssertions_Order(t *testing.T) {
+	bus := NewEventBus()
+	for _, typ := range []string{EventDockerStarting, EventDockerHealthy, EventServerReady, EventDockerHealthy} {
+		bus.Emit(typ, "test", nil)
+	}
+
+	rt := &recordingT{}
+	a := NewEventAssertions(rt, bus)
+	a.AssertBefore(EventDockerStarting, EventServerReady)
+	a.AssertOrder(EventDockerStarting, EventDockerHealthy, EventServerReady)
+	a.AssertPublished(EventServerReady)
+	a.AssertNotPublished(EventServerStopped)
+	if f := rt.failures(); len(f) != 0 {
+		t.Fatalf("unexpected failures %q", f)
+	}
+
+	for _, tc := range []struct {
+		assert func()
+		want   string
+	}{
+		{func() { a.AssertBefore(EventServerReady, EventDockerHealthy) }, `"server_ready" (seq 3) before "docker_healthy" (seq 2)`},
+		{func() { a.AssertOrder(EventDockerHealthy, EventDockerStarting) }, `"docker_healthy" (seq 2) before "docker_starting" (seq 1)`},
+		{func() { a.AssertBefore(EventServerStopped, EventServerReady) }, `"server_stopped" was never published`},
+		{func() { a.AssertBefore(EventServerReady, EventServerStopped) }, `"server_stopped" was never published`},
+		{func() { a.AssertPublished(EventCleanupDone) }, `"cleanup_done" to be published`},
+		{func() { a.AssertNotPublished(EventDockerHealthy) }, `(source test)`},
+	} {
+		rt.errors = nil
+		tc.assert()
+		if f := rt.failures(); len(f) != 1 || !strings.Contains(f[0], tc.want) {
+			t.Errorf("failures %q, want one containing %s", f, tc.want)
+		}
+	}
+}
+
+func TestEventBus_ManagerOrder(t *testing.T) {
+	bus := NewEventBus()
+	dm, _ := newFakeDockerManager(t, config.DockerConfig{})
+	dm.SetEventBus(bus)
+	sm, runner := newFakeServerManager(t, http.StatusOK, ServerConfig{})
+	runner.On("npm").UntilSignal()
+	sm.SetEventBus(bus)
+
+	ctx := context.Background()
+	if err := dm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	sm.Stop(ctx)
+	if err := dm.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewEventAssertions(t, bus)
+	a.AssertOrder(EventDockerStarting, EventDockerHealthy, EventServerStarted, EventServerReady,
+		EventServerStopped, EventDockerStopped)
+	for _, e := range bus.History() {
+		if want := strings.SplitN(e.Type, "_", 2)[0]; e.Source != want {
+			t.Errorf("%s published by %q, want %q", e.Type, e.Source, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// ------------------------------------------------------------------------
+// Fault plans – seeded storage faults for mode-aware wrappers
+// ------------------------------------------------------------------------
+
+// ErrInjectedFault is wrapped by errors a FaultPlan injects.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultKind names a fault a FaultPlan can inject.
+type FaultKind string
+
+const (
+	// FaultShortWrite makes Write store only a prefix of p and return
+	// n < len(p) with a nil error.
+	FaultShortWrite FaultKind = "short_write"
+	// FaultDelayedError lets Write succeed but makes the next Sync (Close
+	// for a buffer) fail with ErrInjectedFault.
+	FaultDelayedError FaultKind = "delayed_error"
+	// FaultCorruptRead flips one bit of the data returned by Read.
+	FaultCorruptRead FaultKind = "corrupt_read"
+)
+
+// FaultPlan configures the faults a ModeAwareDisk or ModeAwareBuffer
+// injects on top of its mode. Each rate is a probability (0.0–1.0) per
+// Write or Read. Every eligible operation draws from a source seeded with
+// Seed, so the same plan and the same sequence of operations inject the
+// same faults.
+type FaultPlan struct {
+	Seed             int64
+	ShortWriteRate   float64
+	DelayedErrorRate float64
+	CorruptReadRate  float64
+}
+
+// Fault records one injected fault.
+type Fault struct {
+	Seq    int       `json:"seq"` // 1-based order of injection
+	Kind   FaultKind `json:"kind"`
+	Target string    `json:"target"` // file name, or "buffer"
+	// Offset is the stream position of the operation; for a corrupt read,
+	// the position of the flipped byte.
+	Offset int64 `json:"offset"`
+	// Length is the size of the caller's buffer.
+	Length int `json:"length"`
+	// Written is the byte count a short write reported.
+	Written int `json:"written,omitempty"`
+	// Mask is the bit a corrupt read flipped.
+	Mask byte `json:"mask,omitempty"`
+}
+
+func (f Fault) String() string {
+	switch f.Kind {
+	case FaultShortWrite:
+		return fmt.Sprintf("#%d %s %s@%d: wrote %d of %d bytes", f.Seq, f.Kind, f.Target, f.Offset, f.Written, f.Length)
+	case FaultCorruptRead:
+		return fmt.Sprintf("#%d %s %s@%d: flipped bit %#02x", f.Seq, f.Kind, f.Target, f.Offset, f.Mask)
+	default:
+		return fmt.Sprintf("#%d %s %s@%d: %d bytes", f.Seq, f.Kind, f.Target, f.Offset, f.Length)
+	}
+}
+
+// FaultLog collects the faults a plan injected, so tests can correlate what
+// the application did with what it was given. It is safe for concurrent
+// use.
+type FaultLog struct {
+	mu     sync.Mutex
+	faults []Fault
+}
+
+// Faults returns a copy of the recorded faults in injection order.
+func (l *FaultLog) Faults() []Fault {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Fault(nil), l.faults...)
+}
+
+// OfKind returns the recorded faults of kind k.
+func (l *FaultLog) OfKind(k FaultKind) []Fault {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Fault
+	for _, f := range l.faults {
+		if f.Kind == k {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Len returns the number of recorded faults.
+func (l *FaultLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.faults)
+}
+
+// Reset discards the recorded faults.
+func (l *FaultLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.faults = nil
+}
+
+func (l *FaultLog) record(f Fault) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f.Seq = len(l.faults) + 1
+	l.faults = append(l.faults, f)
+}
+
+// faultInjector rolls a FaultPlan's dice. A nil injector injects nothing.
+type faultInjector struct {
+	mu   sync.Mutex
+	plan FaultPlan
+	rng  *rand.Rand
+	log  *FaultLog
+}
+
+func newFaultInjector(plan FaultPlan, log *FaultLog) *faultInjector {
+	return &faultInjector{plan: plan, rng: rand.New(rand.NewSource(plan.Seed)), log: log}
+}
+
+// roll reports whether a fault with the given rate fires. It always draws,
+// so plans with the same seed and different rates see the same sequence.
+func (fi *faultInjector) roll(rate float64) bool {
+	return fi.rng.Float64() < rate
+}
+
+// shortWrite decides whether a write of p at offset is cut short, and if so
+// how many bytes of it are stored.
+func (fi *faultInjector) shortWrite(target string, offset int64, p []byte) (int, bool) {
+	if fi == nil || len(p) == 0 {
+		return 0, false
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if !fi.roll(fi.plan.ShortWriteRate) {
+		return 0, false
+	}
+	n := fi.rng.Intn(len(p))
+	fi.log.record(Fault{Kind: FaultShortWrite, Target: target, Offset: offset, Length: len(p), Written: n})
+	return n, true
+}
+
+// delayedError decides whether a completed write of n bytes at offset
+// should make the next Sync fail, and returns that error.
+func (fi *faultInjector) delayedError(target string, offset int64, n int) error {
+	if fi == nil || n == 0 {
+		return nil
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if !fi.roll(fi.plan.DelayedErrorRate) {
+		return nil
+	}
+	fi.log.record(Fault{Kind: FaultDelayedError, Target: target, Offset: offset, Length: n})
+	return fmt.Errorf("%s: write of %d bytes at offset %d was lost: %w", target, n, offset, ErrInjectedFault)
+}
+
+// corruptRead may flip one bit in p[:n], data read from offset.
+func (fi *faultInjector) corruptRead(target string, offset int64, p []byte, n int) {
+	if fi == nil || n == 0 {
+		return
+	}
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if !fi.roll(fi.plan.CorruptReadRate) {
+		return
+	}
+	i := fi.rng.Intn(n)
+	mask := byte(1) << fi.rng.Intn(8)
+	p[i] ^= mask
+	fi.log.record(Fault{Kind: FaultCorruptRead, Target: target, Offset: offset + int64(i), Length: n, Mask: mask})
+}
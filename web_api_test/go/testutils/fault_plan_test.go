@@ -0,0 +1,124 @@
+package testutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// writeAndReadBack writes chunks through a faulty disk, syncing after each,
+// then reads the file back. It returns what the application saw.
+func writeAndReadBack(t *testing.T, plan FaultPlan) (short, syncErrs int, data []byte, log []Fault) {
+	t.Helper()
+	disk := NewModeAwareDisk(NewInMemoryDisk(), NewInMemoryModeManager(ModeNormal))
+	disk.SetFaultPlan(plan)
+
+	f, err := disk.Create("data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := bytes.Repeat([]byte("abcdefgh"), 8)
+	for i := 0; i < 20; i++ {
+		n, err := f.Write(chunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < len(chunk) {
+			short++
+		}
+		if err := f.Sync(); err != nil {
+			if !errors.Is(err, ErrInjectedFault) {
+				t.Fatalf("Sync: %v", err)
+			}
+			syncErrs++
+		}
+	}
+	r, err := disk.Open("data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err = io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return short, syncErrs, data, disk.FaultLog().Faults()
+}
+
+func TestModeAwareDisk_FaultPlan(t *testing.T) {
+	plan := FaultPlan{Seed: 42, ShortWriteRate: 0.3, DelayedErrorRate: 0.3, CorruptReadRate: 0.5}
+	short, syncErrs, data, log := writeAndReadBack(t, plan)
+
+	var wantShort, wantSync int
+	var stored int64
+	for _, f := range log {
+		switch f.Kind {
+		case FaultShortWrite:
+			wantShort++
+			stored += int64(f.Written)
+		case FaultDelayedError:
+			wantSync++
+		case FaultCorruptRead:
+			if f.Offset < 0 || f.Offset >= int64(len(data)) || f.Mask == 0 {
+				t.Errorf("bad corrupt read record: %v", f)
+			}
+		}
+	}
+	if short == 0 || syncErrs == 0 || len(log) == wantShort+wantSync {
+		t.Fatalf("plan injected too little: %d short, %d sync errors, log %v", short, syncErrs, log)
+	}
+	if short != wantShort || syncErrs != wantSync {
+		t.Errorf("observed %d short writes and %d sync errors, log has %d and %d", short, syncErrs, wantShort, wantSync)
+	}
+	for _, f := range log {
+		if f.Kind == FaultCorruptRead {
+			want := data[f.Offset] ^ f.Mask
+			if !bytes.ContainsRune([]byte("abcdefgh"), rune(want)) {
+				t.Errorf("%v: flipping back gives %q", f, want)
+			}
+		}
+	}
+
+	// The same seed and operations inject exactly the same faults.
+	_, _, data2, log2 := writeAndReadBack(t, plan)
+	if !reflect.DeepEqual(log, log2) || !bytes.Equal(data, data2) {
+		t.Errorf("same seed, different faults:\n%v\n%v", log, log2)
+	}
+	if _, _, _, log3 := writeAndReadBack(t, FaultPlan{Seed: 7, ShortWriteRate: 0.3}); reflect.DeepEqual(log, log3) {
+		t.Error("different seed, same faults")
+	}
+}
+
+func TestModeAwareBuffer_FaultPlan(t *testing.T) {
+	buf := NewModeAwareBuffer(NewInMemoryBuffer(), NewInMemoryModeManager(ModeNormal))
+	buf.SetFaultPlan(FaultPlan{Seed: 1, ShortWriteRate: 1})
+	n, err := buf.Write([]byte("hello world"))
+	if err != nil || n >= 11 {
+		t.Fatalf("Write = %d, %v; want a short write without error", n, err)
+	}
+	if got := buf.Len(); got != n {
+		t.Errorf("buffer holds %d bytes, write reported %d", got, n)
+	}
+	faults := buf.FaultLog().OfKind(FaultShortWrite)
+	if len(faults) != 1 || faults[0].Written != n || faults[0].Length != 11 || faults[0].Target != "buffer" {
+		t.Errorf("log = %v", faults)
+	}
+
+	buf.SetFaultPlan(FaultPlan{Seed: 1, DelayedErrorRate: 1, CorruptReadRate: 1})
+	if n, err := buf.Write([]byte("!")); n != 1 || err != nil {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	p := make([]byte, 64)
+	n, _ = buf.Read(p)
+	corrupt := buf.FaultLog().OfKind(FaultCorruptRead)
+	if len(corrupt) != 1 || corrupt[0].Offset >= int64(n) {
+		t.Fatalf("corrupt reads = %v", corrupt)
+	}
+	if err := buf.Close(); !errors.Is(err, ErrInjectedFault) {
+		t.Errorf("Close = %v, want the delayed error", err)
+	}
+	if got := buf.FaultLog().Len(); got != 3 {
+		t.Errorf("log has %d faults, want 3", got)
+	}
+}
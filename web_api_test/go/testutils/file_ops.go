@@ -0,0 +1,507 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Skip reasons recorded in CopyReport.Skipped.
+const (
+	SkipReasonHidden     = "hidden"
+	SkipReasonTooLarge   = "exceeds max file size"
+	SkipReasonNotRegular = "not a regular file"
+	SkipReasonLoop       = "symlink loop"
+)
+
+// copyPartialSuffix marks a destination file that is still being written.
+const copyPartialSuffix = ".copying"
+
+// CopyProgress is a point-in-time view of a running CopyTree.
+type CopyProgress struct {
+	FilesDone   int
+	FilesTotal  int
+	BytesDone   int64
+	BytesTotal  int64
+	CurrentFile string // relative path most recently started
+}
+
+// CopySkip is a source path CopyTree deliberately left out.
+type CopySkip struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// CopyFailure is a source path that could not be copied.
+type CopyFailure struct {
+	Path string `json:"path"`
+	Err  error  `json:"-"`
+}
+
+// CopyReport describes the outcome of CopyTree. Every file named in Copied
+// exists in full at the destination; files in Failed or Pending were never
+// written there, so a cancelled copy never leaves a truncated file.
+// Paths are relative to the source root and sorted.
+type CopyReport struct {
+	Source      string        `json:"source"`
+	Destination string        `json:"destination"`
+	Copied      []string      `json:"copied"`
+	Skipped     []CopySkip    `json:"skipped,omitempty"`
+	Failed      []CopyFailure `json:"failed,omitempty"`
+	Pending     []string      `json:"pending,omitempty"` // not attempted before cancellation
+	BytesCopied int64         `json:"bytes_copied"`
+	Duration    time.Duration `json:"duration"`
+	Cancelled   bool          `json:"cancelled"`
+}
+
+// FileOps performs bulk file operations according to FileOperationsConfig.
+type FileOps struct {
+	config     FileOperationsConfig
+	logger     Logger
+	clock      Clock
+	onProgress func(CopyProgress)
+}
+
+// FileOpsOption configures a FileOps.
+type FileOpsOption func(*FileOps)
+
+// WithCopyProgress registers fn to receive progress every ProgressInterval
+// and once when the copy finishes. fn is called from a single goroutine.
+func WithCopyProgress(fn func(CopyProgress)) FileOpsOption {
+	return func(f *FileOps) {
+		f.onProgress = fn
+	}
+}
+
+// WithFileOpsClock sets the clock that drives progress reporting.
+func WithFileOpsClock(c Clock) FileOpsOption {
+	return func(f *FileOps) {
+		if c != nil {
+			f.clock = c
+		}
+	}
+}
+
+// NewFileOps creates a FileOps. Zero BufferSize, CopyConcurrency and
+// ProgressInterval fall back to 32KB, runtime.NumCPU() and one second.
+// When EnableProgress is set and no callback is registered, progress is
+// logged at debug level instead.
+func NewFileOps(config FileOperationsConfig, logger Logger, opts ...FileOpsOption) *FileOps {
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = 32 * 1024
+	}
+	if config.CopyConcurrency <= 0 {
+		config.CopyConcurrency = runtime.NumCPU()
+	}
+	if config.ProgressInterval <= 0 {
+		config.ProgressInterval = time.Second
+	}
+
+	f := &FileOps{
+		config: config,
+		logger: logger,
+		clock:  RealClock{},
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.onProgress == nil && config.EnableProgress {
+		f.onProgress = func(p CopyProgress) {
+			logger.Debug("copy progress", map[string]any{
+				"files_done":   p.FilesDone,
+				"files_total":  p.FilesTotal,
+				"bytes_done":   p.BytesDone,
+				"bytes_total":  p.BytesTotal,
+				"current_file": p.CurrentFile,
+			})
+		}
+	}
+	return f
+}
+
+// copyJob is one entry of the copy plan.
+type copyJob struct {
+	rel    string
+	src    string // path to read; for followed symlinks, the link itself
+	mode   fs.FileMode
+	size   int64
+	link   string // symlink target when the link is recreated, not followed
+	isLink bool
+}
+
+// copyPlan is the result of walking the source tree.
+type copyPlan struct {
+	dirs    []copyJob
+	files   []copyJob
+	skipped []CopySkip
+	failed  []CopyFailure
+	bytes   int64
+}
+
+// CopyTree copies the directory src to dst. Files are copied by a pool of
+// CopyConcurrency workers; directories are created up front. Hidden entries,
+// symlinks and oversized files are handled per the config, and skipped
+// paths are listed in the report rather than treated as errors.
+//
+// On cancellation CopyTree stops starting new files, abandons files in
+// flight, and returns the report with ctx.Err(). Per-file failures do not
+// stop the copy; they are returned together as a *CompositeError.
+func (f *FileOps) CopyTree(ctx context.Context, src, dst string) (*CopyReport, error) {
+	start := f.clock.Now()
+	src = filepath.Clean(src)
+	dst = filepath.Clean(dst)
+	report := &CopyReport{Source: src, Destination: dst}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return report, err
+	}
+	if !info.IsDir() {
+		return report, fmt.Errorf("source %q is not a directory", src)
+	}
+
+	plan := &copyPlan{}
+	if err := f.walk(plan, src, "", map[string]bool{}); err != nil {
+		return report, fmt.Errorf("failed to scan %q: %w", src, err)
+	}
+	report.Skipped = plan.skipped
+	report.Failed = plan.failed
+
+	if err := os.MkdirAll(dst, f.dirMode(info.Mode())|0700); err != nil {
+		return report, fmt.Errorf("failed to create destination %q: %w", dst, err)
+	}
+	for _, d := range plan.dirs {
+		if err := os.MkdirAll(filepath.Join(dst, d.rel), f.dirMode(d.mode)|0700); err != nil {
+			return report, fmt.Errorf("failed to create directory %q: %w", d.rel, err)
+		}
+	}
+
+	f.logger.Debug("copying tree", map[string]any{
+		"source":      src,
+		"destination": dst,
+		"files":       len(plan.files),
+		"bytes":       plan.bytes,
+		"workers":     f.config.CopyConcurrency,
+	})
+
+	tracker := &copyTracker{filesTotal: len(plan.files), bytesTotal: plan.bytes}
+	stopProgress := f.reportProgress(tracker)
+
+	jobs := make(chan copyJob)
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+	for i := 0; i < f.config.CopyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, f.config.BufferSize)
+			for job := range jobs {
+				if ctx.Err() != nil {
+					mu.Lock()
+					report.Pending = append(report.Pending, job.rel)
+					mu.Unlock()
+					continue
+				}
+				tracker.start(job.rel)
+				n, err := f.copyOne(ctx, job, filepath.Join(dst, job.rel), buf, tracker)
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					report.Copied = append(report.Copied, job.rel)
+					report.BytesCopied += n
+				case ctx.Err() != nil:
+					report.Pending = append(report.Pending, job.rel)
+				default:
+					report.Failed = append(report.Failed, CopyFailure{Path: job.rel, Err: err})
+				}
+				mu.Unlock()
+				tracker.finish(err == nil)
+			}
+		}()
+	}
+	for _, job := range plan.files {
+		jobs <- job
+	}
+	close(jobs)
+	wg.Wait()
+	stopProgress()
+
+	// Directories were created writable; apply their real modes now that
+	// nothing more is written into them, deepest first.
+	if f.config.PreservePermissions {
+		for i := len(plan.dirs) - 1; i >= 0; i-- {
+			d := plan.dirs[i]
+			os.Chmod(filepath.Join(dst, d.rel), d.mode.Perm())
+		}
+		os.Chmod(dst, info.Mode().Perm())
+	}
+
+	sort.Strings(report.Copied)
+	sort.Strings(report.Pending)
+	sort.Slice(report.Failed, func(i, j int) bool { return report.Failed[i].Path < report.Failed[j].Path })
+	report.Cancelled = ctx.Err() != nil
+	report.Duration = f.clock.Now().Sub(start)
+
+	if report.Cancelled {
+		f.logger.Warn("copy cancelled", map[string]any{
+			"source":  src,
+			"copied":  len(report.Copied),
+			"pending": len(report.Pending),
+		})
+		return report, ctx.Err()
+	}
+	if len(report.Failed) > 0 {
+		ce := NewCompositeError(fmt.Sprintf("failed to copy %q", src))
+		for _, fail := range report.Failed {
+			ce.Add(fmt.Errorf("%s: %w", fail.Path, fail.Err))
+		}
+		return report, ce
+	}
+	return report, nil
+}
+
+// walk adds the entries under root to plan, with relative paths prefixed
+// by base. visited holds the resolved directories already walked so that
+// followed symlinks cannot recurse forever.
+func (f *FileOps) walk(plan *copyPlan, root, base string, visited map[string]bool) error {
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		visited[real] = true
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.Join(base, rel)
+
+		if f.config.SkipHidden && strings.HasPrefix(d.Name(), ".") {
+			plan.skipped = append(plan.skipped, CopySkip{Path: rel, Reason: SkipReasonHidden})
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 {
+			return f.planSymlink(plan, path, rel, visited)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			plan.dirs = append(plan.dirs, copyJob{rel: rel, mode: info.Mode()})
+			return nil
+		}
+		f.planFile(plan, copyJob{rel: rel, src: path, mode: info.Mode(), size: info.Size()})
+		return nil
+	})
+}
+
+// planSymlink either recreates the link or, with FollowSymlinks, copies
+// what it points to.
+func (f *FileOps) planSymlink(plan *copyPlan, path, rel string, visited map[string]bool) error {
+	if !f.config.FollowSymlinks {
+		target, err := os.Readlink(path)
+		if err != nil {
+			plan.failed = append(plan.failed, CopyFailure{Path: rel, Err: err})
+			return nil
+		}
+		plan.files = append(plan.files, copyJob{rel: rel, src: path, link: target, isLink: true})
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		plan.failed = append(plan.failed, CopyFailure{Path: rel, Err: err})
+		return nil
+	}
+	if !info.IsDir() {
+		f.planFile(plan, copyJob{rel: rel, src: path, mode: info.Mode(), size: info.Size()})
+		return nil
+	}
+
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		plan.failed = append(plan.failed, CopyFailure{Path: rel, Err: err})
+		return nil
+	}
+	if visited[real] {
+		plan.skipped = append(plan.skipped, CopySkip{Path: rel, Reason: SkipReasonLoop})
+		return nil
+	}
+	plan.dirs = append(plan.dirs, copyJob{rel: rel, mode: info.Mode()})
+	return f.walk(plan, real, rel, visited)
+}
+
+// planFile queues a regular file, or records why it is skipped.
+func (f *FileOps) planFile(plan *copyPlan, job copyJob) {
+	switch {
+	case !job.mode.IsRegular():
+		plan.skipped = append(plan.skipped, CopySkip{Path: job.rel, Reason: SkipReasonNotRegular})
+	case f.config.MaxFileSize > 0 && job.size > f.config.MaxFileSize:
+		plan.skipped = append(plan.skipped, CopySkip{Path: job.rel, Reason: SkipReasonTooLarge})
+	default:
+		plan.files = append(plan.files, job)
+		plan.bytes += job.size
+	}
+}
+
+// copyOne writes job to dst through a temporary name and renames it into
+// place, so dst is either complete or absent.
+func (f *FileOps) copyOne(ctx context.Context, job copyJob, dst string, buf []byte, tracker *copyTracker) (int64, error) {
+	if job.isLink {
+		os.Remove(dst)
+		return 0, os.Symlink(job.link, dst)
+	}
+
+	in, err := os.Open(job.src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tmp := dst + copyPartialSuffix
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.fileMode(job.mode))
+	if err != nil {
+		return 0, err
+	}
+
+	// Hide out's ReadFrom so the copy uses buf and honours BufferSize.
+	n, err := io.CopyBuffer(struct{ io.Writer }{out}, &trackingReader{ctxReader: ctxReader{ctx: ctx, r: in}, tracker: tracker}, buf)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && f.config.PreservePermissions {
+		err = os.Chmod(tmp, job.mode.Perm())
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
+
+func (f *FileOps) fileMode(mode fs.FileMode) fs.FileMode {
+	if f.config.PreservePermissions {
+		return mode.Perm()
+	}
+	return 0644
+}
+
+func (f *FileOps) dirMode(mode fs.FileMode) fs.FileMode {
+	if f.config.PreservePermissions {
+		return mode.Perm()
+	}
+	return 0755
+}
+
+// reportProgress calls onProgress every ProgressInterval until the
+// returned stop function runs, which also delivers a final report.
+func (f *FileOps) reportProgress(t *copyTracker) (stop func()) {
+	if f.onProgress == nil {
+		return func() {}
+	}
+
+	ticker := f.clock.NewTicker(f.config.ProgressInterval)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-ticker.C():
+				f.onProgress(t.snapshot())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-exited
+		f.onProgress(t.snapshot())
+	}
+}
+
+// copyTracker accumulates progress across workers.
+type copyTracker struct {
+	mu         sync.Mutex
+	filesDone  int
+	filesTotal int
+	bytesDone  int64
+	bytesTotal int64
+	current    string
+}
+
+func (t *copyTracker) start(rel string) {
+	t.mu.Lock()
+	t.current = rel
+	t.mu.Unlock()
+}
+
+func (t *copyTracker) finish(ok bool) {
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	t.filesDone++
+	t.mu.Unlock()
+}
+
+func (t *copyTracker) add(n int) {
+	t.mu.Lock()
+	t.bytesDone += int64(n)
+	t.mu.Unlock()
+}
+
+func (t *copyTracker) snapshot() CopyProgress {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return CopyProgress{
+		FilesDone:   t.filesDone,
+		FilesTotal:  t.filesTotal,
+		BytesDone:   t.bytesDone,
+		BytesTotal:  t.bytesTotal,
+		CurrentFile: t.current,
+	}
+}
+
+// trackingReader is a ctxReader that feeds the bytes it reads to tracker.
+type trackingReader struct {
+	ctxReader
+	tracker *copyTracker
+}
+
+func (tr *trackingReader) Read(p []byte) (int, error) {
+	n, err := tr.ctxReader.Read(p)
+	tr.tracker.add(n)
+	return n, err
+}
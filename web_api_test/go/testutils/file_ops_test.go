@@ -0,0 +1,176 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTree creates files (relative path -> content) under root.
+func writeTree(t testing.TB, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileOps_SkipRules(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	writeTree(t, src, map[string]string{
+		"a.txt":          "small",
+		"big.bin":        strings.Repeat("x", 64),
+		".hidden":        "secret",
+		".git/config":    "[core]",
+		"nested/b.txt":   "b",
+		"real/inner.txt": "inner",
+	})
+	if err := os.Symlink("a.txt", filepath.Join(src, "link.txt")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	if err := os.Symlink("real", filepath.Join(src, "alias")); err != nil {
+		t.Fatal(err)
+	}
+
+	ops := NewFileOps(FileOperationsConfig{SkipHidden: true, MaxFileSize: 32, FollowSymlinks: true, CopyConcurrency: 4}, nil)
+	report, err := ops.CopyTree(context.Background(), src, dst)
+	if err != nil {
+		t.Fatalf("CopyTree failed: %v", err)
+	}
+
+	want := []string{"a.txt", "alias/inner.txt", "link.txt", "nested/b.txt", "real/inner.txt"}
+	if fmt.Sprint(report.Copied) != fmt.Sprint(want) {
+		t.Errorf("copied %v, want %v", report.Copied, want)
+	}
+	reasons := map[string]string{}
+	for _, s := range report.Skipped {
+		reasons[s.Path] = s.Reason
+	}
+	for path, reason := range map[string]string{".hidden": SkipReasonHidden, ".git": SkipReasonHidden, "big.bin": SkipReasonTooLarge} {
+		if reasons[path] != reason {
+			t.Errorf("skip reason for %q = %q, want %q", path, reasons[path], reason)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "link.txt")); err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Errorf("followed symlink should be copied as a regular file, got %v, %v", info, err)
+	}
+
+	// Without FollowSymlinks the link is recreated.
+	dst2 := filepath.Join(t.TempDir(), "out")
+	if _, err := NewFileOps(FileOperationsConfig{}, nil).CopyTree(context.Background(), src, dst2); err != nil {
+		t.Fatalf("CopyTree failed: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst2, "link.txt")); err != nil || target != "a.txt" {
+		t.Errorf("expected link to a.txt, got %q, %v", target, err)
+	}
+}
+
+func TestFileOps_CancelLeavesConsistentState(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	files := make(map[string]string)
+	for i := 0; i < 200; i++ {
+		files[fmt.Sprintf("dir%d/file%03d.txt", i%5, i)] = strings.Repeat("y", 4096)
+	}
+	writeTree(t, src, files)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var ticks int
+	ops := NewFileOps(FileOperationsConfig{CopyConcurrency: 2, BufferSize: 512, ProgressInterval: time.Millisecond}, nil,
+		WithCopyProgress(func(p CopyProgress) {
+			ticks++
+			if p.FilesDone > 0 {
+				cancel()
+			}
+		}))
+	report, err := ops.CopyTree(ctx, src, dst)
+
+	if report.Cancelled != errors.Is(err, context.Canceled) {
+		t.Fatalf("Cancelled=%v but err=%v", report.Cancelled, err)
+	}
+	if ticks == 0 {
+		t.Error("progress callback never ran")
+	}
+	if got := len(report.Copied) + len(report.Pending) + len(report.Failed); got != len(files) {
+		t.Errorf("report accounts for %d files, want %d", got, len(files))
+	}
+
+	copied := make(map[string]bool)
+	for _, rel := range report.Copied {
+		copied[filepath.ToSlash(rel)] = true
+	}
+	filepath.WalkDir(dst, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dst, path)
+		rel = filepath.ToSlash(rel)
+		if !copied[rel] {
+			t.Errorf("%s exists at destination but is not reported as copied", rel)
+			return nil
+		}
+		if data, _ := os.ReadFile(path); string(data) != files[rel] {
+			t.Errorf("%s is incomplete (%d bytes)", rel, len(data))
+		}
+		return nil
+	})
+}
+
+func TestFileOps_PreCancelledCopiesNothing(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "out")
+	writeTree(t, src, map[string]string{"a": "1", "b": "2"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := NewFileOps(FileOperationsConfig{}, nil).CopyTree(ctx, src, dst)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(report.Copied) != 0 || len(report.Pending) != 2 {
+		t.Errorf("expected 0 copied and 2 pending, got %v and %v", report.Copied, report.Pending)
+	}
+}
+
+// smallFileTree builds n small files spread over a few directories.
+func smallFileTree(b *testing.B, n int) string {
+	b.Helper()
+	root := b.TempDir()
+	files := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		files[fmt.Sprintf("d%02d/f%04d.txt", i%20, i)] = strings.Repeat("z", 512)
+	}
+	writeTree(b, root, files)
+	return root
+}
+
+func BenchmarkCopyDir(b *testing.B) {
+	src := smallFileTree(b, 1000)
+	out := b.TempDir()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := copyDir(src, filepath.Join(out, fmt.Sprint(i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFileOps_CopyTree(b *testing.B) {
+	src := smallFileTree(b, 1000)
+	out := b.TempDir()
+	ops := NewFileOps(FileOperationsConfig{PreservePermissions: true}, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ops.CopyTree(context.Background(), src, filepath.Join(out, fmt.Sprint(i))); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
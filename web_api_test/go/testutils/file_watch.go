@@ -0,0 +1,353 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// File watching for the test directory
+// ------------------------------------------------------------------------
+
+// defaultFileWatchInterval is how often the polling watcher and the Expect*
+// helpers look at the file system.
+const defaultFileWatchInterval = 50 * time.Millisecond
+
+// FileOp is the kind of change a FileEvent reports.
+type FileOp string
+
+const (
+	FileCreated  FileOp = "created"
+	FileModified FileOp = "modified"
+	FileRemoved  FileOp = "removed"
+)
+
+// FileEvent is a change to a file under the test directory.
+type FileEvent struct {
+	Path string    `json:"path"` // absolute
+	Op   FileOp    `json:"op"`
+	Time time.Time `json:"time"` // when the watcher noticed the change
+	// Own is set when the file is exactly as the manager last wrote it
+	// through CreateTestFile, the helpers built on it, or a committed
+	// WriteBatch.
+	Own bool `json:"own,omitempty"`
+}
+
+// WatchOption configures WatchDir.
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	interval  time.Duration
+	ignoreOwn bool
+	clock     Clock
+}
+
+// WithPollInterval sets how often the polling watcher rescans the test
+// directory (50ms by default). The fsnotify watcher ignores it.
+func WithPollInterval(d time.Duration) WatchOption {
+	return func(c *watchConfig) { c.interval = d }
+}
+
+// IgnoreOwnWrites drops events whose Own flag would be set, so the stream
+// carries only changes made by the code under test.
+func IgnoreOwnWrites() WatchOption {
+	return func(c *watchConfig) { c.ignoreOwn = true }
+}
+
+// WithWatchClock drives the polling watcher's ticker and event times from c.
+func WithWatchClock(c Clock) WatchOption {
+	return func(cfg *watchConfig) { cfg.clock = c }
+}
+
+// watchBackend sets up a watch of dir and returns the loop that delivers
+// events through emit until ctx ends or emit reports false. Set-up happens
+// before WatchDir returns, so no change made afterwards is missed. Builds
+// with the fsnotify tag replace the polling backend.
+var watchBackend = pollWatch
+
+// dirWatcher is a running WatchDir, stopped by Cleanup.
+type dirWatcher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func stampOf(fi fs.FileInfo) fileStamp {
+	return fileStamp{size: fi.Size(), modTime: fi.ModTime()}
+}
+
+// WatchDir streams changes to files in the test directory until ctx ends
+// or Cleanup runs; the channel is then closed. Files already present when
+// WatchDir returns are not reported. Events arrive in order, and a reader
+// that falls behind stalls only the watcher.
+//
+// The default watcher polls (see WithPollInterval) so it needs no
+// dependencies; build with -tags fsnotify for native notifications. Either
+// way it sees the real file system, not a Disk set with SetDisk.
+func (tdm *TestDataManager) WatchDir(ctx context.Context, opts ...WatchOption) (<-chan FileEvent, error) {
+	cfg := watchConfig{interval: defaultFileWatchInterval, clock: RealClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.interval <= 0 {
+		cfg.interval = defaultFileWatchInterval
+	}
+
+	dir := tdm.GetTestDir()
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan FileEvent, 64)
+	emit := func(ev FileEvent) bool {
+		if ev.Op != FileRemoved {
+			ev.Own = tdm.isOwnWrite(ev.Path)
+		}
+		if ev.Own && cfg.ignoreOwn {
+			return true
+		}
+		select {
+		case out <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	run, err := watchBackend(ctx, dir, cfg, emit)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to watch %q: %w", dir, err)
+	}
+
+	w := &dirWatcher{cancel: cancel, done: make(chan struct{})}
+	tdm.watchMu.Lock()
+	if tdm.watchers == nil {
+		tdm.watchers = make(map[*dirWatcher]struct{})
+	}
+	tdm.watchers[w] = struct{}{}
+	tdm.watchMu.Unlock()
+
+	go func() {
+		defer close(w.done)
+		defer close(out)
+		defer cancel()
+		run()
+		tdm.watchMu.Lock()
+		delete(tdm.watchers, w)
+		tdm.watchMu.Unlock()
+	}()
+	return out, nil
+}
+
+// stopWatchers stops every running WatchDir and waits for their goroutines
+// to exit.
+func (tdm *TestDataManager) stopWatchers() {
+	tdm.watchMu.Lock()
+	watchers := make([]*dirWatcher, 0, len(tdm.watchers))
+	for w := range tdm.watchers {
+		watchers = append(watchers, w)
+	}
+	tdm.watchMu.Unlock()
+
+	for _, w := range watchers {
+		w.cancel()
+		<-w.done
+	}
+}
+
+// noteOwnWrites records the current version of paths as written by the
+// manager.
+func (tdm *TestDataManager) noteOwnWrites(paths ...string) {
+	tdm.ownMu.Lock()
+	defer tdm.ownMu.Unlock()
+	if tdm.ownWrites == nil {
+		tdm.ownWrites = make(map[string]fileStamp)
+	}
+	for _, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			tdm.ownWrites[p] = stampOf(fi)
+		}
+	}
+}
+
+// isOwnWrite reports whether path is unchanged since the manager wrote it.
+func (tdm *TestDataManager) isOwnWrite(path string) bool {
+	tdm.ownMu.Lock()
+	stamp, ok := tdm.ownWrites[path]
+	tdm.ownMu.Unlock()
+	if !ok {
+		return false
+	}
+	fi, err := os.Stat(path)
+	return err == nil && stampOf(fi) == stamp
+}
+
+// ExpectFileCreated waits up to timeout for path, relative to the test
+// directory, to exist as a file that the manager did not write itself.
+func (tdm *TestDataManager) ExpectFileCreated(path string, timeout time.Duration) error {
+	full, err := tdm.watchedPath(path)
+	if err != nil {
+		return err
+	}
+	return WaitFor(context.Background(), "file "+path+" created", defaultFileWatchInterval,
+		func(context.Context) (bool, string, error) {
+			if detail := tdm.externalFile(full); detail != "" {
+				return false, detail, nil
+			}
+			return true, "", nil
+		}, WithWaitTimeout(timeout))
+}
+
+// ExpectFileContains waits up to timeout for path, relative to the test
+// directory, to contain substr in a version the manager did not write
+// itself.
+func (tdm *TestDataManager) ExpectFileContains(path, substr string, timeout time.Duration) error {
+	full, err := tdm.watchedPath(path)
+	if err != nil {
+		return err
+	}
+	return WaitFor(context.Background(), fmt.Sprintf("file %s containing %q", path, substr), defaultFileWatchInterval,
+		func(context.Context) (bool, string, error) {
+			if detail := tdm.externalFile(full); detail != "" {
+				return false, detail, nil
+			}
+			data, err := os.ReadFile(full)
+			if err != nil {
+				return false, err.Error(), nil
+			}
+			if !strings.Contains(string(data), substr) {
+				return false, fmt.Sprintf("%d bytes without the substring", len(data)), nil
+			}
+			return true, "", nil
+		}, WithWaitTimeout(timeout))
+}
+
+// externalFile describes why full does not yet count as written by the
+// code under test, or returns "" when it does.
+func (tdm *TestDataManager) externalFile(full string) string {
+	fi, err := os.Stat(full)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return "missing"
+	case err != nil:
+		return err.Error()
+	case fi.IsDir():
+		return "is a directory"
+	case tdm.isOwnWrite(full):
+		return "only written by the test data manager"
+	}
+	return ""
+}
+
+// watchedPath resolves path against the test directory. Absolute paths are
+// accepted when they lie inside it.
+func (tdm *TestDataManager) watchedPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return tdm.resolvePath(path)
+	}
+	dir := filepath.Clean(tdm.GetTestDir())
+	if !strings.HasPrefix(filepath.Clean(path), dir+string(os.PathSeparator)) {
+		return "", kindErrorf(ErrPathTraversal, "path %q is outside the test directory", path)
+	}
+	return filepath.Clean(path), nil
+}
+
+// ignoredWatchPath reports paths that only ever hold the manager's
+// in-flight writes: temporary files of CreateTestFile and WriteBatch
+// staging directories.
+func ignoredWatchPath(path string) bool {
+	base := filepath.Base(path)
+	return strings.Contains(base, ".tmp.") || strings.HasPrefix(base, writeBatchPrefix)
+}
+
+// pollWatch is the dependency-free backend: it rescans dir every interval
+// and reports the differences.
+func pollWatch(ctx context.Context, dir string, cfg watchConfig, emit func(FileEvent) bool) (func(), error) {
+	prev, err := scanFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		ticker := cfg.clock.NewTicker(cfg.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C():
+			}
+			cur, err := scanFiles(dir)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				cur = map[string]fileStamp{}
+			}
+			for _, ev := range diffFiles(prev, cur, cfg.clock.Now()) {
+				if !emit(ev) {
+					return
+				}
+			}
+			prev = cur
+		}
+	}, nil
+}
+
+// scanFiles stamps every file under dir.
+func scanFiles(dir string) (map[string]fileStamp, error) {
+	files := make(map[string]fileStamp)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil // removed mid-walk
+			}
+			return err
+		}
+		if path != dir && ignoredWatchPath(path) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil // removed mid-walk
+		}
+		files[path] = stampOf(fi)
+		return nil
+	})
+	return files, err
+}
+
+// diffFiles returns the events that turn prev into cur, sorted by path.
+func diffFiles(prev, cur map[string]fileStamp, now time.Time) []FileEvent {
+	var events []FileEvent
+	for path, stamp := range cur {
+		old, ok := prev[path]
+		switch {
+		case !ok:
+			events = append(events, FileEvent{Path: path, Op: FileCreated, Time: now})
+		case old != stamp:
+			events = append(events, FileEvent{Path: path, Op: FileModified, Time: now})
+		}
+	}
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			events = append(events, FileEvent{Path: path, Op: FileRemoved, Time: now})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	return events
+}
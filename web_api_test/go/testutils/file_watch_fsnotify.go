@@ -0,0 +1,89 @@
+//go:build fsnotify
+
+package testutils
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Builds with -tags fsnotify watch the test directory with native file
+// system notifications instead of polling. The module must require
+// github.com/fsnotify/fsnotify for this build.
+func init() {
+	watchBackend = fsnotifyWatch
+}
+
+// fsnotifyWatch watches dir and every directory created under it.
+func fsnotifyWatch(ctx context.Context, dir string, cfg watchConfig, emit func(FileEvent) bool) (func(), error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := addWatchTree(w, dir); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return func() {
+		defer w.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.Errors:
+				// Overflows and the like; there is nothing to resend.
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ignoredWatchPath(ev.Name) {
+					continue
+				}
+				var op FileOp
+				switch {
+				case ev.Has(fsnotify.Create):
+					if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+						// Files written before the watch was added would
+						// otherwise go unreported.
+						addWatchTree(w, ev.Name)
+						files, _ := scanFiles(ev.Name)
+						for _, created := range diffFiles(nil, files, cfg.clock.Now()) {
+							if !emit(created) {
+								return
+							}
+						}
+						continue
+					}
+					op = FileCreated
+				case ev.Has(fsnotify.Write):
+					op = FileModified
+				case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+					op = FileRemoved
+				default:
+					continue
+				}
+				if !emit(FileEvent{Path: ev.Name, Op: op, Time: cfg.clock.Now()}) {
+					return
+				}
+			}
+		}
+	}, nil
+}
+
+// addWatchTree adds root and its subdirectories, skipping write batch
+// staging directories.
+func addWatchTree(w *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if path != root && ignoredWatchPath(path) {
+			return filepath.SkipDir
+		}
+		return w.Add(path)
+	})
+}
@@ -0,0 +1,157 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// nextEvent returns the next event or fails the test after a second.
+func nextEvent(t *testing.T, events <-chan FileEvent) FileEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("event stream closed early")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a file event")
+	}
+	return FileEvent{}
+}
+
+func TestWatchDir_ReportsExternalChangesAndTagsOwnWrites(t *testing.T) {
+	tdm := newTestManager(t, "watch", nil, nil)
+	defer tdm.Cleanup()
+	if _, err := tdm.CreateTestFile("before.txt", "old"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := tdm.WatchDir(ctx, WithPollInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchDir: %v", err)
+	}
+
+	own, err := tdm.CreateTestFile("own.txt", "fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events); ev.Path != own || ev.Op != FileCreated || !ev.Own {
+		t.Errorf("expected an own create of %s, got %+v", own, ev)
+	}
+
+	external := filepath.Join(tdm.GetTestDir(), "sub", "out.log")
+	os.MkdirAll(filepath.Dir(external), 0755)
+	if err := os.WriteFile(external, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events); ev.Path != external || ev.Op != FileCreated || ev.Own {
+		t.Errorf("expected an external create of %s, got %+v", external, ev)
+	}
+
+	if err := os.WriteFile(own, []byte("changed by the code under test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ev := nextEvent(t, events); ev.Path != own || ev.Op != FileModified || ev.Own {
+		t.Errorf("expected an external modify of %s, got %+v", own, ev)
+	}
+
+	before := filepath.Join(tdm.GetTestDir(), "before.txt")
+	os.Remove(before)
+	if ev := nextEvent(t, events); ev.Path != before || ev.Op != FileRemoved {
+		t.Errorf("expected removal of %s, got %+v", before, ev)
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchDir_IgnoreOwnWrites(t *testing.T) {
+	tdm := newTestManager(t, "watch", nil, nil)
+	defer tdm.Cleanup()
+	events, err := tdm.WatchDir(context.Background(), WithPollInterval(5*time.Millisecond), IgnoreOwnWrites())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tdm.CreateJSONFile("fixture.json", map[string]int{"n": 1})
+	batch, err := tdm.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch.CreateTestFile("batch.txt", "staged")
+	if _, err := batch.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // let the watcher see the own writes first
+	external := filepath.Join(tdm.GetTestDir(), "result.txt")
+	os.WriteFile(external, []byte("done"), 0644)
+
+	if ev := nextEvent(t, events); ev.Path != external {
+		t.Errorf("expected only the external file, got %+v", ev)
+	}
+}
+
+func TestExpectFileCreated(t *testing.T) {
+	tdm := newTestManager(t, "watch", nil, nil)
+	defer tdm.Cleanup()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.WriteFile(filepath.Join(tdm.GetTestDir(), "report.json"), []byte(`{"status":"ok"}`), 0644)
+	}()
+	if err := tdm.ExpectFileCreated("report.json", time.Second); err != nil {
+		t.Fatalf("ExpectFileCreated: %v", err)
+	}
+	if err := tdm.ExpectFileContains("report.json", `"ok"`, time.Second); err != nil {
+		t.Fatalf("ExpectFileContains: %v", err)
+	}
+
+	if _, err := tdm.CreateTestFile("seeded.txt", "needle"); err != nil {
+		t.Fatal(err)
+	}
+	err := tdm.ExpectFileContains("seeded.txt", "needle", 100*time.Millisecond)
+	var waitErr *WaitError
+	if !errors.As(err, &waitErr) {
+		t.Fatalf("expected a WaitError for a file only the manager wrote, got %v", err)
+	}
+	if last := waitErr.Details[len(waitErr.Details)-1].Detail; last != "only written by the test data manager" {
+		t.Errorf("unexpected wait detail %q", last)
+	}
+
+	if err := tdm.ExpectFileCreated("../escape.txt", time.Millisecond); !errors.Is(err, ErrPathTraversal) {
+		t.Errorf("expected ErrPathTraversal, got %v", err)
+	}
+}
+
+func TestCleanup_StopsWatchers(t *testing.T) {
+	VerifyNoLeaks(t)
+	tdm := newTestManager(t, "watch", nil, nil)
+
+	var streams []<-chan FileEvent
+	for i := 0; i < 3; i++ {
+		events, err := tdm.WatchDir(context.Background(), WithPollInterval(time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, events)
+	}
+	// Leave an event unread so one watcher is blocked delivering it.
+	os.WriteFile(filepath.Join(tdm.GetTestDir(), "x"), nil, 0644)
+	time.Sleep(10 * time.Millisecond)
+
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	for i, events := range streams {
+		for range events {
+		}
+		t.Logf("stream %d closed", i)
+	}
+}
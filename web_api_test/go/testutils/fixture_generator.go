@@ -0,0 +1,336 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Character sets for FixtureGenerator.String.
+const (
+	CharsetLower        = "abcdefghijklmnopqrstuvwxyz"
+	CharsetUpper        = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	CharsetDigits       = "0123456789"
+	CharsetHex          = "0123456789abcdef"
+	CharsetAlpha        = CharsetLower + CharsetUpper
+	CharsetAlphaNumeric = CharsetAlpha + CharsetDigits
+)
+
+// FixtureGenerator produces random test values beyond integers. With a
+// fixed RandomIntConfig.Seed and clock, the sequence is reproducible.
+type FixtureGenerator struct {
+	mu     sync.Mutex
+	rand   *rand.Rand
+	seed   int64
+	clock  Clock
+	config RandomIntConfig
+	seen   map[string]map[any]bool // per-key values handed out by Unique
+}
+
+// FixtureOption configures a FixtureGenerator.
+type FixtureOption func(*FixtureGenerator)
+
+// WithFixtureClock sets the reference clock for PastTime. Use a MockClock
+// when generated timestamps must be reproducible.
+func WithFixtureClock(c Clock) FixtureOption {
+	return func(g *FixtureGenerator) {
+		if c != nil {
+			g.clock = c
+		}
+	}
+}
+
+// WithFixtureTestRun seeds the generator from run's "fixtures" child seed,
+// overriding RandomIntConfig.Seed.
+func WithFixtureTestRun(run *TestRun) FixtureOption {
+	return func(g *FixtureGenerator) {
+		if run == nil {
+			return
+		}
+		g.seed = run.ChildSeed("fixtures")
+		g.config.Seed = g.seed
+		g.rand = rand.New(rand.NewSource(g.seed))
+	}
+}
+
+// NewFixtureGenerator creates a generator seeded from config.Seed (0 for
+// time-based). RetryMax bounds the attempts made by Unique.
+func NewFixtureGenerator(config RandomIntConfig, opts ...FixtureOption) *FixtureGenerator {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if config.RetryMax <= 0 {
+		config.RetryMax = 1000
+	}
+
+	g := &FixtureGenerator{
+		rand:   rand.New(rand.NewSource(config.Seed)),
+		seed:   config.Seed,
+		clock:  RealClock{},
+		config: config,
+		seen:   make(map[string]map[any]bool),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Seed returns the generator's seed
+func (g *FixtureGenerator) Seed() int64 {
+	return g.seed
+}
+
+// Int returns a value in [min, max].
+func (g *FixtureGenerator) Int(min, max int) int {
+	if min > max {
+		min, max = max, min
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.rand.Intn(max-min+1) + min
+}
+
+// Bool returns true or false with equal probability.
+func (g *FixtureGenerator) Bool() bool {
+	return g.Int(0, 1) == 1
+}
+
+// String returns n characters drawn from charset (CharsetAlphaNumeric when
+// empty).
+func (g *FixtureGenerator) String(n int, charset string) string {
+	if charset == "" {
+		charset = CharsetAlphaNumeric
+	}
+	chars := []rune(charset)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteRune(chars[g.rand.Intn(len(chars))])
+	}
+	return b.String()
+}
+
+// Slug returns a lowercase slug such as "kqzx-mtpa-42".
+func (g *FixtureGenerator) Slug() string {
+	return fmt.Sprintf("%s-%s-%d", g.String(4, CharsetLower), g.String(4, CharsetLower), g.Int(10, 99))
+}
+
+// Email returns an address at domain ("example.test" when empty).
+func (g *FixtureGenerator) Email(domain string) string {
+	if domain == "" {
+		domain = "example.test"
+	}
+	return fmt.Sprintf("%s.%s@%s", g.String(6, CharsetLower), g.String(3, CharsetDigits), domain)
+}
+
+// ID returns a UUID-formatted identifier with version 4 and variant bits
+// set. It is random, not cryptographically strong.
+func (g *FixtureGenerator) ID() string {
+	g.mu.Lock()
+	var b [16]byte
+	g.rand.Read(b[:])
+	g.mu.Unlock()
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// PastTime returns a time within window before the clock's now, truncated
+// to the second.
+func (g *FixtureGenerator) PastTime(window time.Duration) time.Time {
+	now := g.clock.Now()
+	if window <= 0 {
+		return now.Truncate(time.Second)
+	}
+	g.mu.Lock()
+	offset := time.Duration(g.rand.Int63n(int64(window)))
+	g.mu.Unlock()
+	return now.Add(-offset).Truncate(time.Second)
+}
+
+// Unique calls gen until it returns a value not yet produced for key, in
+// the manner of RandomIntGenerator.GenerateUnique. It gives up after
+// RetryMax attempts.
+func (g *FixtureGenerator) Unique(key string, gen func() any) (any, error) {
+	for attempt := 0; attempt < g.config.RetryMax; attempt++ {
+		v := gen()
+
+		g.mu.Lock()
+		seen := g.seen[key]
+		if seen == nil {
+			seen = make(map[any]bool)
+			g.seen[key] = seen
+		}
+		fresh := !seen[v]
+		if fresh {
+			seen[v] = true
+		}
+		g.mu.Unlock()
+
+		if fresh {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to generate a unique value for %q after %d attempts", key, g.config.RetryMax)
+}
+
+// PickOne returns a random element of items. It panics on an empty slice.
+func PickOne[T any](g *FixtureGenerator, items []T) T {
+	return items[g.Int(0, len(items)-1)]
+}
+
+// FieldKind selects how a record field is generated.
+type FieldKind string
+
+const (
+	FieldString FieldKind = "string"
+	FieldInt    FieldKind = "int"
+	FieldBool   FieldKind = "bool"
+	FieldEmail  FieldKind = "email"
+	FieldSlug   FieldKind = "slug"
+	FieldID     FieldKind = "id"
+	FieldTime   FieldKind = "time"
+	FieldPick   FieldKind = "pick"
+)
+
+// FieldSpec describes one field of a generated record. Only the settings
+// relevant to Kind are read.
+type FieldSpec struct {
+	Kind    FieldKind
+	Length  int           // FieldString; defaults to 8
+	Charset string        // FieldString
+	Min     int           // FieldInt
+	Max     int           // FieldInt
+	Domain  string        // FieldEmail
+	Window  time.Duration // FieldTime; defaults to 30 days
+	Choices []any         // FieldPick; must be comparable when Unique is set
+	Unique  bool          // no two records share the value
+}
+
+// Value generates one value for the spec.
+func (s FieldSpec) Value(g *FixtureGenerator) (any, error) {
+	switch s.Kind {
+	case FieldString:
+		n := s.Length
+		if n <= 0 {
+			n = 8
+		}
+		return g.String(n, s.Charset), nil
+	case FieldInt:
+		return g.Int(s.Min, s.Max), nil
+	case FieldBool:
+		return g.Bool(), nil
+	case FieldEmail:
+		return g.Email(s.Domain), nil
+	case FieldSlug:
+		return g.Slug(), nil
+	case FieldID:
+		return g.ID(), nil
+	case FieldTime:
+		window := s.Window
+		if window <= 0 {
+			window = 30 * 24 * time.Hour
+		}
+		return g.PastTime(window), nil
+	case FieldPick:
+		if len(s.Choices) == 0 {
+			return nil, errors.New("pick field has no choices")
+		}
+		return PickOne(g, s.Choices), nil
+	default:
+		return nil, fmt.Errorf("unknown field kind %q", s.Kind)
+	}
+}
+
+// Records generates count records following schema. Fields are filled in
+// key order so a fixed seed always yields the same records.
+func (g *FixtureGenerator) Records(count int, schema map[string]FieldSpec) ([]map[string]any, error) {
+	if count <= 0 {
+		return nil, fmt.Errorf("count must be positive, got %d", count)
+	}
+
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	records := make([]map[string]any, 0, count)
+	for i := 0; i < count; i++ {
+		record := make(map[string]any, len(schema))
+		for _, name := range names {
+			spec := schema[name]
+			var (
+				v   any
+				err error
+			)
+			if spec.Unique {
+				v, err = g.Unique(name, func() any {
+					v, _ := spec.Value(g)
+					return v
+				})
+			} else {
+				v, err = spec.Value(g)
+			}
+			if err != nil {
+				return records, fmt.Errorf("record %d field %q: %w", i, name, err)
+			}
+			record[name] = v
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// GenerateTestRecords generates records with the given schema, seeded from
+// config.Seed, with logging in the style of GenerateTestInts. Pass
+// WithFixtureClock to make FieldTime values reproducible as well.
+func (l *TestLogger) GenerateTestRecords(count int, schema map[string]FieldSpec, config RandomIntConfig, opts ...FixtureOption) ([]map[string]any, error) {
+	l.Info("generating test records", map[string]any{
+		"count":  count,
+		"fields": len(schema),
+		"seed":   config.Seed,
+	})
+
+	records, err := NewFixtureGenerator(config, opts...).Records(count, schema)
+	if err != nil {
+		l.Error("failed to generate test records", map[string]any{
+			"count": count,
+			"error": err.Error(),
+		})
+		return nil, err
+	}
+
+	l.Debug("test records generated", map[string]any{
+		"count": len(records),
+	})
+	return records, nil
+}
+
+// GenerateAndCreateRecordsFile generates records and writes them to a JSON
+// file in the test directory.
+func (tdm *TestDataManager) GenerateAndCreateRecordsFile(filename string, count int, schema map[string]FieldSpec, config RandomIntConfig, opts ...FixtureOption) (string, []map[string]any, error) {
+	var (
+		records []map[string]any
+		err     error
+	)
+	if logger, ok := tdm.logger.(*TestLogger); ok {
+		records, err = logger.GenerateTestRecords(count, schema, config, opts...)
+	} else {
+		records, err = NewFixtureGenerator(config, opts...).Records(count, schema)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	path, err := tdm.CreateJSONFile(filename, records)
+	return path, records, err
+}
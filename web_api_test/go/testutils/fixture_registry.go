@@ -0,0 +1,246 @@
+package testutils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FixtureBuilder creates a fixture inside tdm's directory and returns the
+// path of the file or directory it produced. Builders should declare the
+// fixtures they need as dependencies rather than calling GetFixture.
+type FixtureBuilder func(tdm *TestDataManager) (string, error)
+
+type fixtureDef struct {
+	build FixtureBuilder
+	deps  []string
+}
+
+// fixtureEntry is one row of the checksum manifest: what was built, its
+// checksum at build time, and the dependency builds it was built from.
+// Dependencies are tracked by build generation rather than checksum, so a
+// dependency rebuilt with identical content still rebuilds its dependents.
+type fixtureEntry struct {
+	path       string
+	checksum   string
+	generation uint64
+	depGens    map[string]uint64
+	owner      *TestDataManager
+}
+
+// fixtureCall tracks an in-flight build so concurrent callers share it.
+type fixtureCall struct {
+	done  chan struct{}
+	entry *fixtureEntry
+	err   error
+}
+
+// fixtureCache holds built fixtures keyed by name.
+type fixtureCache struct {
+	mu         sync.Mutex
+	entries    map[string]*fixtureEntry
+	inflight   map[string]*fixtureCall
+	generation uint64 // last generation handed to a build
+}
+
+func newFixtureCache() *fixtureCache {
+	return &fixtureCache{
+		entries:  make(map[string]*fixtureEntry),
+		inflight: make(map[string]*fixtureCall),
+	}
+}
+
+// sharedFixtureCache is used by managers with EnableCache set. Entries are
+// keyed by fixture name only, so managers sharing the cache must register
+// equivalent builders under the same name.
+var sharedFixtureCache = newFixtureCache()
+
+// RegisterFixture registers a lazily built fixture. dependsOn names fixtures
+// that are built first; a dependency that changes triggers a rebuild.
+// Registering a name again replaces the builder and drops the cached copy.
+func (tdm *TestDataManager) RegisterFixture(name string, builder FixtureBuilder, dependsOn ...string) {
+	tdm.fixturesMu.Lock()
+	if tdm.fixtures == nil {
+		tdm.fixtures = make(map[string]fixtureDef)
+	}
+	tdm.fixtures[name] = fixtureDef{build: builder, deps: dependsOn}
+	tdm.fixturesMu.Unlock()
+
+	cache := tdm.fixtureCache()
+	cache.mu.Lock()
+	delete(cache.entries, name)
+	cache.mu.Unlock()
+}
+
+// GetFixture returns the path of the named fixture, building it on first
+// use. A cached fixture is rebuilt when its files no longer match the
+// recorded checksum or one of its dependencies changed. Concurrent calls
+// for the same name build it exactly once.
+func (tdm *TestDataManager) GetFixture(name string) (string, error) {
+	entry, err := tdm.resolveFixture(name, nil)
+	if err != nil {
+		return "", err
+	}
+	return entry.path, nil
+}
+
+// fixtureCache returns the cache this manager uses.
+func (tdm *TestDataManager) fixtureCache() *fixtureCache {
+	if tdm.config.EnableCache {
+		return sharedFixtureCache
+	}
+	tdm.fixturesMu.Lock()
+	defer tdm.fixturesMu.Unlock()
+	if tdm.localFixtures == nil {
+		tdm.localFixtures = newFixtureCache()
+	}
+	return tdm.localFixtures
+}
+
+// resolveFixture builds dependencies, then returns a valid cached entry or
+// builds a new one. stack holds the names being resolved, to catch cycles.
+func (tdm *TestDataManager) resolveFixture(name string, stack []string) (*fixtureEntry, error) {
+	for _, s := range stack {
+		if s == name {
+			return nil, fmt.Errorf("fixture dependency cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+
+	tdm.fixturesMu.Lock()
+	def, ok := tdm.fixtures[name]
+	tdm.fixturesMu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("fixture %q is not registered", name)
+	}
+
+	stack = append(stack, name)
+	depGens := make(map[string]uint64, len(def.deps))
+	for _, dep := range def.deps {
+		e, err := tdm.resolveFixture(dep, stack)
+		if err != nil {
+			return nil, fmt.Errorf("fixture %q: %w", name, err)
+		}
+		depGens[dep] = e.generation
+	}
+
+	cache := tdm.fixtureCache()
+	for {
+		cache.mu.Lock()
+		if e, ok := cache.entries[name]; ok {
+			cache.mu.Unlock()
+			if e.valid(depGens) {
+				return e, nil
+			}
+			tdm.logger.Debug("fixture changed, rebuilding", map[string]any{"fixture": name, "path": e.path})
+			cache.mu.Lock()
+			if cache.entries[name] == e {
+				delete(cache.entries, name)
+			}
+			cache.mu.Unlock()
+			continue
+		}
+		if call, ok := cache.inflight[name]; ok {
+			cache.mu.Unlock()
+			<-call.done
+			if call.err != nil {
+				return nil, call.err
+			}
+			continue // re-validate against our dependency builds
+		}
+		call := &fixtureCall{done: make(chan struct{})}
+		cache.inflight[name] = call
+		cache.mu.Unlock()
+
+		call.entry, call.err = tdm.buildFixture(name, def, depGens)
+
+		cache.mu.Lock()
+		delete(cache.inflight, name)
+		if call.err == nil {
+			cache.generation++
+			call.entry.generation = cache.generation
+			cache.entries[name] = call.entry
+		}
+		cache.mu.Unlock()
+		close(call.done)
+
+		return call.entry, call.err
+	}
+}
+
+func (tdm *TestDataManager) buildFixture(name string, def fixtureDef, depGens map[string]uint64) (*fixtureEntry, error) {
+	tdm.logger.Debug("building fixture", map[string]any{"fixture": name})
+
+	path, err := def.build(tdm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build fixture %q: %w", name, err)
+	}
+	sum, err := fixtureChecksum(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum fixture %q at %q: %w", name, path, err)
+	}
+
+	tdm.logger.Info("fixture built", map[string]any{"fixture": name, "path": path, "checksum": sum})
+	return &fixtureEntry{path: path, checksum: sum, depGens: depGens, owner: tdm}, nil
+}
+
+// valid reports whether the fixture's files are unchanged and it was built
+// from the current builds of its dependencies.
+func (e *fixtureEntry) valid(depGens map[string]uint64) bool {
+	if len(e.depGens) != len(depGens) {
+		return false
+	}
+	for dep, gen := range depGens {
+		if e.depGens[dep] != gen {
+			return false
+		}
+	}
+	sum, err := fixtureChecksum(e.path)
+	return err == nil && sum == e.checksum
+}
+
+// invalidateFixtures drops every cached fixture built by this manager.
+func (tdm *TestDataManager) invalidateFixtures() {
+	cache := tdm.fixtureCache()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for name, e := range cache.entries {
+		if e.owner == tdm {
+			delete(cache.entries, name)
+		}
+	}
+}
+
+// fixtureChecksum hashes a file, or every file under a directory together
+// with its relative path.
+func fixtureChecksum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return SHA256File(path)
+	}
+
+	h := sha256.New()
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		sum, err := SHA256File(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(path, p)
+		fmt.Fprintf(h, "%s\x00%s\n", filepath.ToSlash(rel), sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
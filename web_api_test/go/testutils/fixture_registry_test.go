@@ -0,0 +1,126 @@
+package testutils
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTestDataManager_GetFixtureBuildsOnce(t *testing.T) {
+	tdm := newTestManager(t, "fixture-once", nil, nil)
+	var builds atomic.Int32
+	tdm.RegisterFixture("users", func(tdm *TestDataManager) (string, error) {
+		builds.Add(1)
+		return tdm.CreateTestFile("users.json", `[{"id":1}]`)
+	})
+
+	var wg sync.WaitGroup
+	paths := make([]string, 20)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p, err := tdm.GetFixture("users")
+			if err != nil {
+				t.Errorf("GetFixture failed: %v", err)
+			}
+			paths[i] = p
+		}(i)
+	}
+	wg.Wait()
+
+	if n := builds.Load(); n != 1 {
+		t.Errorf("expected one build, got %d", n)
+	}
+	for _, p := range paths {
+		if p != paths[0] {
+			t.Errorf("callers got different paths: %q vs %q", p, paths[0])
+		}
+	}
+}
+
+func TestTestDataManager_GetFixtureRebuildsOnChange(t *testing.T) {
+	tdm := newTestManager(t, "fixture-change", nil, nil)
+	var schemaBuilds, seedBuilds int
+	tdm.RegisterFixture("schema", func(tdm *TestDataManager) (string, error) {
+		schemaBuilds++
+		return tdm.CreateTestFile("schema.sql", "CREATE TABLE t (id int);")
+	})
+	tdm.RegisterFixture("seed", func(tdm *TestDataManager) (string, error) {
+		seedBuilds++
+		return tdm.CreateTestFile("seed.sql", "INSERT INTO t VALUES (1);")
+	}, "schema")
+
+	if _, err := tdm.GetFixture("seed"); err != nil {
+		t.Fatalf("GetFixture failed: %v", err)
+	}
+	if _, err := tdm.GetFixture("seed"); err != nil {
+		t.Fatalf("GetFixture failed: %v", err)
+	}
+	if schemaBuilds != 1 || seedBuilds != 1 {
+		t.Fatalf("expected cached fixtures, got %d schema and %d seed builds", schemaBuilds, seedBuilds)
+	}
+
+	// Tampering with the dependency rebuilds it and everything built on it.
+	schemaPath, _ := tdm.GetFixture("schema")
+	if err := os.WriteFile(schemaPath, []byte("DROP TABLE t;"), 0644); err != nil {
+		t.Fatalf("failed to modify fixture: %v", err)
+	}
+	if _, err := tdm.GetFixture("seed"); err != nil {
+		t.Fatalf("GetFixture failed: %v", err)
+	}
+	if schemaBuilds != 2 || seedBuilds != 2 {
+		t.Errorf("expected rebuilds after modification, got %d schema and %d seed builds", schemaBuilds, seedBuilds)
+	}
+
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatalf("Cleanup failed: %v", err)
+	}
+	if _, err := tdm.GetFixture("schema"); err != nil {
+		t.Fatalf("GetFixture after cleanup failed: %v", err)
+	}
+	if schemaBuilds != 3 {
+		t.Errorf("expected cleanup to invalidate the cache, got %d schema builds", schemaBuilds)
+	}
+}
+
+func TestTestDataManager_SharedFixtureCache(t *testing.T) {
+	var builds int
+	builder := func(tdm *TestDataManager) (string, error) {
+		builds++
+		return tdm.CreateTestFile("shared-golden.txt", "golden")
+	}
+	a := newTestManager(t, "fixture-shared-a", nil, &TestDataManagerConfig{EnableCache: true})
+	b := newTestManager(t, "fixture-shared-b", nil, &TestDataManagerConfig{EnableCache: true})
+	a.RegisterFixture("shared-golden", builder)
+	b.RegisterFixture("shared-golden", builder)
+	defer a.invalidateFixtures()
+
+	pa, err := a.GetFixture("shared-golden")
+	if err != nil {
+		t.Fatalf("GetFixture failed: %v", err)
+	}
+	pb, err := b.GetFixture("shared-golden")
+	if err != nil {
+		t.Fatalf("GetFixture failed: %v", err)
+	}
+	if builds != 1 || pa != pb {
+		t.Errorf("expected one shared build, got %d builds and paths %q, %q", builds, pa, pb)
+	}
+}
+
+func TestTestDataManager_FixtureErrors(t *testing.T) {
+	tdm := newTestManager(t, "fixture-errors", nil, nil)
+	noop := func(tdm *TestDataManager) (string, error) { return tdm.CreateTestFile("x", "x") }
+	tdm.RegisterFixture("a", noop, "b")
+	tdm.RegisterFixture("b", noop, "a")
+
+	if _, err := tdm.GetFixture("a"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+	if _, err := tdm.GetFixture("missing"); err == nil {
+		t.Error("expected an error for an unregistered fixture")
+	}
+}
@@ -3,7 +3,6 @@ package testutils
 
 import (
     "sync"
-    "time"
 )
 
 // --------------------------------------------------------------------
@@ -0,0 +1,465 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultHARBodyLimit caps the bytes kept per request or response body.
+const defaultHARBodyLimit = 64 << 10
+
+// HAR is the top-level HAR 1.2 document.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog holds the recorded entries.
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+// HARCreator identifies the tool that produced the document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is one request/response exchange.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARRequest describes the recorded request.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARResponse describes the recorded response.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARNameValue is a header, cookie or query parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData is the request body. HAR 1.2 has no encoding field for
+// postData, so binary bodies carry the custom _encoding marker.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+// HARContent is the response body. Encoding is "base64" for binary content.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+// HARTimings splits Time into phases. Phases that do not apply to an
+// in-process handler are -1, as the spec requires.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder captures every request passing through its middleware and
+// exports them as a HAR 1.2 document loadable in browser dev tools.
+type HARRecorder struct {
+	mu      sync.Mutex
+	entries []HAREntry
+
+	bodyLimit int
+	skip      func(*Request) bool
+	clock     Clock
+}
+
+// HARRecorderOption configures a HARRecorder.
+type HARRecorderOption func(*HARRecorder)
+
+// WithHARBodyLimit caps the bytes kept per body (default 64 KiB). Larger
+// bodies are truncated and the entry's content is marked as such.
+func WithHARBodyLimit(n int) HARRecorderOption {
+	return func(h *HARRecorder) {
+		if n >= 0 {
+			h.bodyLimit = n
+		}
+	}
+}
+
+// WithHARSkip excludes requests for which fn returns true.
+func WithHARSkip(fn func(*Request) bool) HARRecorderOption {
+	return func(h *HARRecorder) { h.skip = fn }
+}
+
+// WithHARClock sets the clock used for entry timestamps and timings.
+func WithHARClock(c Clock) HARRecorderOption {
+	return func(h *HARRecorder) {
+		if c != nil {
+			h.clock = c
+		}
+	}
+}
+
+// NewHARRecorder creates an empty recorder.
+func NewHARRecorder(opts ...HARRecorderOption) *HARRecorder {
+	h := &HARRecorder{
+		bodyLimit: defaultHARBodyLimit,
+		clock:     RealClock{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// harSkipKey carries the per-request opt-out flag set by NoRecord.
+type harSkipKey struct{}
+
+// NoRecord returns middleware that excludes the routes it wraps from any
+// enclosing HARRecorder, e.g. to keep uploads out of the HAR:
+//
+//	app.Use(rec.Middleware())
+//	app.Group("/upload", func(g *Group) {
+//		g.Use(NoRecord())
+//		g.Post("/", upload)
+//	})
+func NoRecord() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if flag, ok := ctx.Value(harSkipKey{}).(*atomic.Bool); ok {
+				flag.Store(true)
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// Middleware returns the recording middleware. Register it with App.Use
+// before other middleware so the recorded response is what the client sees.
+func (h *HARRecorder) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, req *Request) (*Response, error) {
+			if h.skip != nil && h.skip(req) {
+				return next(ctx, req)
+			}
+
+			reqBody, reqSize := h.captureRequestBody(req)
+			skipped := new(atomic.Bool)
+			ctx = context.WithValue(ctx, harSkipKey{}, skipped)
+			req.Request = req.Request.WithContext(ctx)
+
+			start := h.clock.Now()
+			resp, err := next(ctx, req)
+			elapsed := h.clock.Now().Sub(start)
+
+			if !skipped.Load() {
+				h.add(h.buildEntry(req, reqBody, reqSize, resp, err, start, elapsed))
+			}
+			return resp, err
+		}
+	}
+}
+
+// captureRequestBody reads up to the body limit and puts the bytes back in
+// front of the unread remainder, so the handler still sees the full body.
+func (h *HARRecorder) captureRequestBody(req *Request) ([]byte, int) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, 0
+	}
+	head, _ := io.ReadAll(io.LimitReader(req.Body, int64(h.bodyLimit)))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+
+	size := int(req.ContentLength)
+	if size < 0 {
+		size = len(head)
+	}
+	return head, size
+}
+
+func (h *HARRecorder) buildEntry(req *Request, reqBody []byte, reqSize int, resp *Response, err error, start time.Time, elapsed time.Duration) HAREntry {
+	ms := float64(elapsed) / float64(time.Millisecond)
+	entry := HAREntry{
+		StartedDateTime: start,
+		Time:            ms,
+		Request: HARRequest{
+			Method:      req.Method,
+			URL:         requestURL(req),
+			HTTPVersion: req.Proto,
+			Cookies:     requestCookies(req.Request),
+			Headers:     harHeaders(req.Request.Header),
+			QueryString: harQuery(req),
+			HeadersSize: -1,
+			BodySize:    reqSize,
+		},
+		Timings: HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: ms},
+	}
+	if entry.Request.HTTPVersion == "" {
+		entry.Request.HTTPVersion = "HTTP/1.1"
+	}
+	if len(reqBody) > 0 {
+		text, enc := harBodyText(reqBody, req.Request.Header.Get("Content-Type"))
+		entry.Request.PostData = &HARPostData{
+			MimeType: req.Request.Header.Get("Content-Type"),
+			Text:     text,
+			Encoding: enc,
+		}
+	}
+
+	if err != nil {
+		resp = req.errorResponse(req.Context(), err)
+	}
+	if resp == nil {
+		entry.Comment = "handler returned no response"
+		resp = &Response{Status: http.StatusInternalServerError}
+	}
+	entry.Response = h.harResponse(resp)
+	return entry
+}
+
+// harResponse converts resp the way writeResponse would send it.
+func (h *HARRecorder) harResponse(resp *Response) HARResponse {
+	headers := resp.Headers.Clone()
+	if headers == nil {
+		headers = http.Header{}
+	}
+	body := resp.RawBody
+	if body == nil && resp.Body != nil {
+		if headers.Get("Content-Type") == "" {
+			headers.Set("Content-Type", "application/json; charset=utf-8")
+		}
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(resp.Body); err == nil {
+			body = buf.Bytes()
+		}
+	}
+
+	out := HARResponse{
+		Status:      resp.Status,
+		StatusText:  http.StatusText(resp.Status),
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     responseCookies(headers),
+		Headers:     harHeaders(headers),
+		RedirectURL: headers.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    len(body),
+		Content: HARContent{
+			Size:     len(body),
+			MimeType: headers.Get("Content-Type"),
+		},
+	}
+	if len(body) > h.bodyLimit {
+		body = body[:h.bodyLimit]
+		out.Content.Comment = fmt.Sprintf("truncated to %d bytes", h.bodyLimit)
+	}
+	if headers.Get("Content-Encoding") != "" {
+		out.Content.Text, out.Content.Encoding = base64.StdEncoding.EncodeToString(body), "base64"
+	} else {
+		out.Content.Text, out.Content.Encoding = harBodyText(body, out.Content.MimeType)
+	}
+	return out
+}
+
+func (h *HARRecorder) add(e HAREntry) {
+	h.mu.Lock()
+	h.entries = append(h.entries, e)
+	h.mu.Unlock()
+}
+
+// Entries returns a copy of the recorded entries in arrival order.
+func (h *HARRecorder) Entries() []HAREntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]HAREntry, len(h.entries))
+	copy(out, h.entries)
+	return out
+}
+
+// Len returns the number of recorded entries.
+func (h *HARRecorder) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.entries)
+}
+
+// Reset discards all recorded entries.
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	h.entries = nil
+	h.mu.Unlock()
+}
+
+// HAR returns the recorded entries as a HAR document.
+func (h *HARRecorder) HAR() HAR {
+	entries := h.Entries()
+	if entries == nil {
+		entries = []HAREntry{}
+	}
+	return HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "testutils", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// ExportHAR writes the recorded entries to w as an indented HAR 1.2 document.
+func (h *HARRecorder) ExportHAR(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(h.HAR()); err != nil {
+		return fmt.Errorf("har: export: %w", err)
+	}
+	return nil
+}
+
+// DumpOnFailure writes the HAR to path when code, the result of m.Run, is
+// non-zero. It is meant for TestMain:
+//
+//	code := m.Run()
+//	if err := rec.DumpOnFailure(code, "api.har"); err != nil {
+//		log.Print(err)
+//	}
+//	os.Exit(code)
+func (h *HARRecorder) DumpOnFailure(code int, path string) error {
+	if code == 0 {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("har: dump: %w", err)
+	}
+	if err := h.ExportHAR(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// requestURL reconstructs the absolute URL of an inbound request.
+func requestURL(req *Request) string {
+	if req.URL.IsAbs() {
+		return req.URL.String()
+	}
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	host := req.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return scheme + "://" + host + req.URL.RequestURI()
+}
+
+// harHeaders flattens h into name/value pairs.
+func harHeaders(h http.Header) []HARNameValue {
+	out := []HARNameValue{}
+	for name, values := range h {
+		for _, v := range values {
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func harQuery(req *Request) []HARNameValue {
+	out := []HARNameValue{}
+	for name, values := range req.URL.Query() {
+		for _, v := range values {
+			out = append(out, HARNameValue{Name: name, Value: v})
+		}
+	}
+	return out
+}
+
+func requestCookies(r *http.Request) []HARNameValue {
+	out := []HARNameValue{}
+	for _, c := range r.Cookies() {
+		out = append(out, HARNameValue{Name: c.Name, Value: c.Value})
+	}
+	return out
+}
+
+func responseCookies(h http.Header) []HARNameValue {
+	out := []HARNameValue{}
+	for _, c := range (&http.Response{Header: h}).Cookies() {
+		out = append(out, HARNameValue{Name: c.Name, Value: c.Value})
+	}
+	return out
+}
+
+// harBodyText returns body as text when it is valid UTF-8 of a textual
+// media type, and base64 with encoding "base64" otherwise.
+func harBodyText(body []byte, contentType string) (string, string) {
+	if len(body) == 0 {
+		return "", ""
+	}
+	if utf8.Valid(body) && isTextual(contentType) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// isTextual reports whether contentType is safe to store as text. An empty
+// type is treated as textual and left to the UTF-8 check.
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return isCompressible(mediaType) ||
+		mediaType == "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,153 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func recordOne(t *testing.T, h Handler, r *http.Request) *Response {
+	t.Helper()
+	req := &Request{Request: r}
+	resp, err := h(req.Context(), req)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	return resp
+}
+
+func TestHARRecorder_RecordsJSONExchange(t *testing.T) {
+	rec := NewHARRecorder()
+	echo := func(ctx context.Context, req *Request) (*Response, error) {
+		var in map[string]string
+		if err := req.BindJSON(&in); err != nil {
+			return nil, err
+		}
+		return JSON(http.StatusCreated, in)
+	}
+	h := rec.Middleware()(echo)
+
+	r := httptest.NewRequest(http.MethodPost, "/users?page=2", strings.NewReader(`{"name":"ada"}`))
+	r.Header.Set("Content-Type", "application/json")
+	if resp := recordOne(t, h, r); resp.Status != http.StatusCreated {
+		t.Fatalf("status %d, handler did not see the request body", resp.Status)
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Request.URL != "http://example.com/users?page=2" {
+		t.Errorf("url %q", e.Request.URL)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"name":"ada"}` {
+		t.Errorf("postData %+v", e.Request.PostData)
+	}
+	if len(e.Request.QueryString) != 1 || e.Request.QueryString[0].Value != "2" {
+		t.Errorf("queryString %+v", e.Request.QueryString)
+	}
+	if e.Response.Status != http.StatusCreated || e.Response.Content.Encoding != "" {
+		t.Errorf("response %+v", e.Response)
+	}
+	if strings.TrimSpace(e.Response.Content.Text) != `{"name":"ada"}` {
+		t.Errorf("content text %q", e.Response.Content.Text)
+	}
+	if e.Timings.DNS != -1 || e.Timings.Wait < 0 {
+		t.Errorf("timings %+v", e.Timings)
+	}
+}
+
+func TestHARRecorder_BinaryAndTruncatedBodies(t *testing.T) {
+	rec := NewHARRecorder(WithHARBodyLimit(4))
+	blob := []byte{0xff, 0x00, 0xfe, 0x01, 0x02, 0x03}
+	h := rec.Middleware()(func(ctx context.Context, req *Request) (*Response, error) {
+		got, _ := io.ReadAll(req.Body)
+		if !bytes.Equal(got, blob) {
+			t.Errorf("handler saw %v, want %v", got, blob)
+		}
+		return &Response{
+			Status:  http.StatusOK,
+			Headers: http.Header{"Content-Type": []string{"application/octet-stream"}},
+			RawBody: blob,
+		}, nil
+	})
+
+	r := httptest.NewRequest(http.MethodPut, "/blob", bytes.NewReader(blob))
+	r.Header.Set("Content-Type", "application/octet-stream")
+	recordOne(t, h, r)
+
+	e := rec.Entries()[0]
+	if e.Request.PostData.Encoding != "base64" || e.Request.BodySize != len(blob) {
+		t.Errorf("postData %+v, bodySize %d", e.Request.PostData, e.Request.BodySize)
+	}
+	c := e.Response.Content
+	if c.Encoding != "base64" || c.Size != len(blob) || c.Comment == "" {
+		t.Errorf("content %+v", c)
+	}
+	if c.Text != base64.StdEncoding.EncodeToString(blob[:4]) {
+		t.Errorf("content text %q is not the truncated body", c.Text)
+	}
+}
+
+func TestHARRecorder_NoRecordAndErrors(t *testing.T) {
+	rec := NewHARRecorder()
+	upload := rec.Middleware()(NoRecord()(okHandler))
+	failing := rec.Middleware()(func(ctx context.Context, req *Request) (*Response, error) {
+		return nil, &Error{Code: http.StatusConflict, Message: "taken"}
+	})
+
+	recordOne(t, upload, httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("data")))
+	req := &Request{Request: httptest.NewRequest(http.MethodGet, "/fail", nil)}
+	if _, err := failing(req.Context(), req); err == nil {
+		t.Fatal("recorder swallowed the handler error")
+	}
+
+	entries := rec.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want only the failing request", len(entries))
+	}
+	if entries[0].Response.Status != http.StatusConflict {
+		t.Errorf("status %d, want 409", entries[0].Response.Status)
+	}
+}
+
+func TestHARRecorder_ExportAndDump(t *testing.T) {
+	rec := NewHARRecorder()
+	recordOne(t, rec.Middleware()(okHandler), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	var buf bytes.Buffer
+	if err := rec.ExportHAR(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	log := doc["log"].(map[string]any)
+	if log["version"] != "1.2" || len(log["entries"].([]any)) != 1 {
+		t.Errorf("unexpected log %v", log)
+	}
+
+	path := filepath.Join(t.TempDir(), "api.har")
+	if err := rec.DumpOnFailure(0, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("DumpOnFailure wrote a file for a passing run")
+	}
+	if err := rec.DumpOnFailure(1, path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("no HAR after failing run: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package testutils
+
+import (
+	"context"
+	"os"
+)
+
+// --------------------------------------------------------------------
+// Harness – per-suite config, logger, client and managers
+// --------------------------------------------------------------------
+
+type configContextKey struct{}
+
+// WithHarnessConfig returns a context carrying cfg, so helpers deep in a
+// test can read the suite's config without a package global.
+func WithHarnessConfig(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, configContextKey{}, cfg)
+}
+
+// ConfigFromContext returns the config stored by WithHarnessConfig (or by
+// Harness.Context), or nil when there is none.
+func ConfigFromContext(ctx context.Context) *Config {
+	if ctx == nil {
+		return nil
+	}
+	cfg, _ := ctx.Value(configContextKey{}).(*Config)
+	return cfg
+}
+
+// Harness bundles what an integration suite used to keep in package
+// globals. Each instance is independent, so parallel tests (or two suites
+// in one binary) can run against different configs and base URLs.
+type Harness struct {
+	Config     *Config
+	BaseURL    string
+	Logger     *TestLogger
+	Client     *HTTPTestClient
+	Components *ComponentRegistry // managers started by Start, stopped by Close
+	Teardown   *Cleanup           // extra steps run by Close after the components stop
+	Artifacts  *ArtifactCollector // set when Config.Paths.CollectArtifacts; see CloseOnExit
+
+	clientOpts []HTTPTestClientOption
+}
+
+// HarnessOption configures a Harness.
+type HarnessOption func(*Harness)
+
+// WithBaseURL sets the API the harness client talks to. The default is
+// TEST_BASE_URL from the environment.
+func WithBaseURL(url string) HarnessOption {
+	return func(h *Harness) { h.BaseURL = url }
+}
+
+// WithHarnessLogger replaces the logger built from Config.Logger.
+func WithHarnessLogger(l *TestLogger) HarnessOption {
+	return func(h *Harness) { h.Logger = l }
+}
+
+// WithClientOptions passes opts to the harness's HTTPTestClient.
+func WithClientOptions(opts ...HTTPTestClientOption) HarnessOption {
+	return func(h *Harness) { h.clientOpts = append(h.clientOpts, opts...) }
+}
+
+// NewHarness builds a harness around cfg; a nil cfg uses DefaultConfig.
+// The logger is named after cfg.AppName and configured from cfg.Logger.
+func NewHarness(cfg *Config, opts ...HarnessOption) *Harness {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	h := &Harness{
+		Config:     cfg,
+		BaseURL:    os.Getenv("TEST_BASE_URL"),
+		Components: NewComponentRegistry(),
+		Teardown:   &Cleanup{},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.Logger == nil {
+		loggerOpts := []LoggerOption{WithLoggerConfig(cfg.Logger)}
+		if cfg.Paths.CollectArtifacts {
+			loggerOpts = append(loggerOpts, WithEntryCapture(harnessCapturedEntries))
+		}
+		h.Logger = NewTestLogger(cfg.AppName, nil, loggerOpts...)
+	}
+	h.Client = NewHTTPTestClient(h.BaseURL, h.clientOpts...)
+	h.Teardown.SetLogger(h.Logger)
+	if cfg.Paths.CollectArtifacts {
+		h.Artifacts = NewArtifactCollectorFromConfig(cfg, WithArtifactLogger(h.Logger))
+		if h.Logger.capture != nil {
+			h.Artifacts.Register("logs", h.Logger.DumpArtifacts)
+		}
+	}
+	return h
+}
+
+// harnessCapturedEntries is how many log entries a harness keeps for its
+// failure artifacts.
+const harnessCapturedEntries = 10000
+
+type harnessContextKey struct{}
+
+// Context returns parent carrying the harness and its config.
+func (h *Harness) Context(parent context.Context) context.Context {
+	return context.WithValue(WithHarnessConfig(parent, h.Config), harnessContextKey{}, h)
+}
+
+// HarnessFromContext returns the harness stored by Harness.Context, or nil.
+func HarnessFromContext(ctx context.Context) *Harness {
+	if ctx == nil {
+		return nil
+	}
+	h, _ := ctx.Value(harnessContextKey{}).(*Harness)
+	return h
+}
+
+// Start starts the registered components in dependency order, stopping
+// the ones already up if one fails. It is Setup without the report.
+func (h *Harness) Start() error {
+	_, err := h.Setup(context.Background())
+	return err
+}
+
+// CloseOnExit is Close for TestMain: when code, the result of m.Run, is
+// non-zero and artifacts are configured, it first collects a bundle, while
+// the components are still up to be dumped.
+//
+//	code := m.Run()
+//	h.CloseOnExit(ctx, code)
+//	os.Exit(code)
+func (h *Harness) CloseOnExit(ctx context.Context, code int) error {
+	errs := NewCompositeError("harness close")
+	if h.Artifacts != nil {
+		if _, err := h.Artifacts.CollectOnFailure(code); err != nil {
+			errs.Add(err)
+		}
+	}
+	if err := h.Close(ctx); err != nil {
+		errs.Add(err)
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// Close stops the components, then runs the teardown steps, and reports
+// every failure.
+func (h *Harness) Close(ctx context.Context) error {
+	errs := NewCompositeError("harness close")
+	if err := h.Components.StopAll(); err != nil {
+		errs.Add(err)
+	}
+	if err := h.Teardown.Run(ctx); err != nil {
+		errs.Add(err)
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Harness setup with rollback
+// --------------------------------------------------------------------
+
+// SetupReport records a Harness.Setup: how long each component took to
+// start and, if setup failed, which were stopped again and how long that
+// took.
+type SetupReport struct {
+	Steps            []SetupStep   `json:"steps"`
+	Duration         time.Duration `json:"duration"`
+	Failed           string        `json:"failed,omitempty"` // component whose start failed or panicked
+	Panicked         bool          `json:"panicked,omitempty"`
+	RollbackDuration time.Duration `json:"rollback_duration,omitempty"`
+}
+
+// SetupStep is one component start in a SetupReport.
+type SetupStep struct {
+	Component string        `json:"component"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+	// Startup is the failed start of a ServerManager, with its health
+	// attempts.
+	Startup *StartupReport `json:"startup,omitempty"`
+
+	RolledBack       bool          `json:"rolled_back,omitempty"`
+	RollbackDuration time.Duration `json:"rollback_duration,omitempty"`
+	RollbackError    string        `json:"rollback_error,omitempty"`
+}
+
+// Setup starts the registered components in dependency order like Start,
+// pushing each one's Stop onto an undo stack once its Start succeeds. If a
+// Start fails or panics, the panic is recovered and the stack is run, so
+// the components already up (containers included) are stopped before
+// Setup returns. The component that failed is not stopped; its Start is
+// expected to clean up after itself, as ServerManager.Start does.
+//
+// The error is a *CompositeError holding the failure, labeled "setup:",
+// followed by every rollback failure, labeled "rollback:". The report is
+// returned either way.
+func (h *Harness) Setup(ctx context.Context) (report *SetupReport, err error) {
+	report = &SetupReport{}
+	clock := h.Components.beginStart()
+	began := clock.Now()
+	undo := &Cleanup{}
+	undo.SetLogger(h.Logger)
+	index := make(map[string]int) // undo step name -> report step
+
+	for _, c := range h.Components.startOrder() {
+		took, startErr := startRecovered(h.Components, clock, c, &report.Panicked)
+		step := SetupStep{Component: c.Name(), Duration: took}
+		if startErr == nil {
+			report.Steps = append(report.Steps, step)
+			name := "stop component " + c.Name()
+			index[name] = len(report.Steps) - 1
+			undo.Push(name, func(context.Context) error { return c.Stop() })
+			continue
+		}
+
+		step.Error = startErr.Error()
+		var startup *StartupError
+		if errors.As(startErr, &startup) {
+			step.Startup = startup.Report
+		}
+		report.Steps = append(report.Steps, step)
+		report.Failed = c.Name()
+
+		errs := NewCompositeError("harness setup")
+		errs.Add(fmt.Errorf("setup: failed to start component %s: %w", c.Name(), startErr), WithSeverity(SeverityHigh))
+
+		// Roll back even if ctx is what ended the setup.
+		rollbackStart := clock.Now()
+		undo.Run(context.WithoutCancel(ctx))
+		report.RollbackDuration = clock.Now().Sub(rollbackStart)
+		for _, r := range undo.Results() {
+			s := &report.Steps[index[r.Name]]
+			s.RolledBack = true
+			s.RollbackDuration = r.Duration
+			if r.Err != nil {
+				s.RollbackError = r.Err.Error()
+				errs.Add(fmt.Errorf("rollback: %s: %w", r.Name, r.Err))
+			}
+		}
+		report.Duration = clock.Now().Sub(began)
+		h.Logger.Error("harness setup failed", map[string]any{
+			"component":   c.Name(),
+			"error":       startErr.Error(),
+			"rolled_back": len(undo.Results()),
+			"rollback":    report.RollbackDuration.String(),
+		})
+		return report, errs
+	}
+	report.Duration = clock.Now().Sub(began)
+	return report, nil
+}
+
+// startRecovered starts c, turning a panic into an error and setting
+// *panicked.
+func startRecovered(r *ComponentRegistry, clock Clock, c Component, panicked *bool) (took time.Duration, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			*panicked = true
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return r.startOne(clock, c)
+}
@@ -0,0 +1,101 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSetupHarness registers postgres and redis, standing in for compose
+// services, and an api server depending on both.
+func newSetupHarness(t *testing.T) (*Harness, *MockComponent, *MockComponent, *MockComponent) {
+	t.Helper()
+	h := NewHarness(nil, WithHarnessLogger(NewTestLogger("h", io.Discard)))
+	postgres, redis, api := NewMockComponent("postgres"), NewMockComponent("redis"), NewMockComponent("api")
+	for _, c := range []Component{postgres, redis, api} {
+		h.Components.MustRegister(c)
+	}
+	if err := h.Components.DependsOn("api", "postgres", "redis"); err != nil {
+		t.Fatal(err)
+	}
+	return h, postgres, redis, api
+}
+
+func TestHarness_SetupRollsBackOnFailure(t *testing.T) {
+	h, postgres, redis, api := newSetupHarness(t)
+	startup := &StartupReport{Outcome: StartupUnhealthy, HealthAttempts: make([]HealthResult, 2)}
+	api.InjectStartError(1, &StartupError{Err: errors.New("server health check failed"), Report: startup})
+	redis.InjectStopError(1, errors.New("container still running"))
+
+	report, err := h.Setup(context.Background())
+	var ce *CompositeError
+	if !errors.As(err, &ce) || ce.ErrorCount() != 2 {
+		t.Fatalf("expected the failure and one rollback error, got %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{
+		"setup: failed to start component api: server health check failed",
+		`rollback: stop component redis: container still running`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("missing %q in:\n%s", want, msg)
+		}
+	}
+
+	// Both stand-ins for containers were stopped, the failed server was not.
+	for _, c := range []*MockComponent{postgres, redis} {
+		if _, stops, _, _, _ := c.CallCounts(); stops != 1 {
+			t.Errorf("%s stopped %d times during rollback", c.Name(), stops)
+		}
+	}
+	if _, stops, _, _, _ := api.CallCounts(); stops != 0 {
+		t.Errorf("the failed component was stopped %d times", stops)
+	}
+
+	if report.Failed != "api" || report.Panicked || len(report.Steps) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for _, s := range report.Steps[:2] {
+		if !s.RolledBack {
+			t.Errorf("step %s was not rolled back", s.Component)
+		}
+	}
+	last := report.Steps[2]
+	if last.RolledBack || last.Startup != startup || len(last.Startup.HealthAttempts) != 2 {
+		t.Errorf("the failed step must keep its startup report: %+v", last)
+	}
+	if report.Steps[1].RollbackError != "container still running" {
+		t.Errorf("rollback error %q", report.Steps[1].RollbackError)
+	}
+}
+
+func TestHarness_SetupRecoversPanic(t *testing.T) {
+	h, postgres, redis, api := newSetupHarness(t)
+	api.SetStartFunc(func() error { panic("nil config") })
+	clock := NewFakeClock(time.Time{})
+	h.Components.SetClock(clock)
+	postgres.SetStopFunc(func() error { clock.Advance(3 * time.Second); return nil })
+
+	report, err := h.Setup(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "panic: nil config") || !report.Panicked {
+		t.Fatalf("expected a recovered panic, got %v, %+v", err, report)
+	}
+	if _, stops, _, _, _ := redis.CallCounts(); stops != 1 {
+		t.Errorf("redis stopped %d times", stops)
+	}
+	if report.RollbackDuration != 3*time.Second || report.Steps[0].RollbackDuration <= 0 {
+		t.Errorf("rollback took %v, postgres %v", report.RollbackDuration, report.Steps[0].RollbackDuration)
+	}
+
+	// Start shares the rollback.
+	api.SetStartFunc(func() error { return errors.New("boom") })
+	if err := h.Start(); err == nil {
+		t.Fatal("expected Start to fail")
+	}
+	if _, stops, _, _, _ := redis.CallCounts(); stops != 2 {
+		t.Errorf("Start did not roll back: redis stopped %d times", stops)
+	}
+}
@@ -0,0 +1,61 @@
+package testutils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHarness_ParallelInstancesDoNotInterfere(t *testing.T) {
+	for _, name := range []string{"alpha", "beta"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, name)
+			}))
+			defer srv.Close()
+
+			cfg := DefaultConfig()
+			cfg.AppName = name
+			h := NewHarness(cfg, WithBaseURL(srv.URL), WithHarnessLogger(NewTestLogger(name, io.Discard)))
+			ctx := h.Context(context.Background())
+
+			for i := 0; i < 50; i++ {
+				got := HarnessFromContext(ctx)
+				if got != h || ConfigFromContext(ctx).AppName != name {
+					t.Fatalf("context carries %q, want %q", ConfigFromContext(ctx).AppName, name)
+				}
+				resp, err := got.Client.Get(ctx, "/")
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != name {
+					t.Fatalf("harness %s reached server %q", name, body)
+				}
+			}
+			if err := h.Close(ctx); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestHarness_CloseRunsTeardown(t *testing.T) {
+	h := NewHarness(nil, WithHarnessLogger(NewTestLogger("h", io.Discard)))
+	var ran bool
+	h.Teardown.Push("mark", func(context.Context) error { ran = true; return nil })
+	if err := h.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(context.Background()); err != nil || !ran {
+		t.Errorf("Close = %v, teardown ran = %v", err, ran)
+	}
+	if ConfigFromContext(context.Background()) != nil {
+		t.Error("config found in a bare context")
+	}
+}
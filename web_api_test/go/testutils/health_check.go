@@ -0,0 +1,286 @@
+package testutils
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// HealthCheck – typed HTTP health probes with reasons
+// ------------------------------------------------------------------------
+
+// healthBodySnippet is how much of a response body a HealthResult keeps.
+const healthBodySnippet = 512
+
+// HealthCheck probes an HTTP health endpoint and says why it is not
+// healthy: a wrong path fails with "endpoint returned 404" instead of
+// passing as "the server answered".
+//
+//	check := &HealthCheck{URL: baseURL + "/health", RequiredFields: []string{"status", "checks.db"}}
+//	if _, err := check.WaitHealthy(ctx, 30*time.Second); err != nil {
+//		t.Fatal(err) // lists every failed attempt
+//	}
+type HealthCheck struct {
+	URL    string
+	Method string // default GET
+
+	// ExpectedStatuses are the healthy status codes; empty means any 2xx.
+	// AcceptStatus, when set, decides instead.
+	ExpectedStatuses []int
+	AcceptStatus     func(status int) bool
+
+	// RequiredFields are dotted paths ("status", "checks.db", "items.0")
+	// that must be present in the JSON body.
+	RequiredFields []string
+
+	// TLSConfig and InsecureSkipVerify configure the client used when
+	// Client is nil.
+	TLSConfig          *tls.Config
+	InsecureSkipVerify bool
+	Client             *http.Client
+
+	Timeout     time.Duration // per attempt; default 2s
+	Interval    time.Duration // first retry delay for WaitHealthy; default 100ms
+	MaxInterval time.Duration // retry delay cap; default 2s
+	Clock       Clock         // default RealClock
+
+	// OnAttempt, when set, sees every result WaitHealthy gets.
+	OnAttempt func(HealthResult)
+}
+
+// HealthResult is the outcome of one health probe.
+type HealthResult struct {
+	Healthy bool          `json:"healthy"`
+	Status  int           `json:"status,omitempty"` // 0 when no response arrived
+	Body    string        `json:"body,omitempty"`   // first bytes of the body
+	Reason  string        `json:"reason,omitempty"` // why it is not healthy
+	Latency time.Duration `json:"latency"`
+	Attempt int           `json:"attempt,omitempty"` // set by WaitHealthy
+}
+
+func (r HealthResult) String() string {
+	if r.Healthy {
+		return fmt.Sprintf("healthy (status %d, %v)", r.Status, r.Latency.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("unhealthy: %s", r.Reason)
+}
+
+func (h *HealthCheck) clock() Clock {
+	if h.Clock != nil {
+		return h.Clock
+	}
+	return RealClock{}
+}
+
+func (h *HealthCheck) client() *http.Client {
+	if h.Client != nil {
+		return h.Client
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	if h.TLSConfig != nil || h.InsecureSkipVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		tlsCfg := &tls.Config{}
+		if h.TLSConfig != nil {
+			tlsCfg = h.TLSConfig.Clone()
+		}
+		if h.InsecureSkipVerify {
+			tlsCfg.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsCfg
+		client.Transport = transport
+	}
+	return client
+}
+
+func (h *HealthCheck) statusOK(status int) bool {
+	if h.AcceptStatus != nil {
+		return h.AcceptStatus(status)
+	}
+	if len(h.ExpectedStatuses) > 0 {
+		return slices.Contains(h.ExpectedStatuses, status)
+	}
+	return status >= 200 && status < 300
+}
+
+// Run probes the endpoint once.
+func (h *HealthCheck) Run(ctx context.Context) HealthResult {
+	return h.run(ctx, h.client())
+}
+
+func (h *HealthCheck) run(ctx context.Context, client *http.Client) HealthResult {
+	method := h.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, h.URL, nil)
+	if err != nil {
+		return HealthResult{Reason: fmt.Sprintf("invalid health URL: %v", err)}
+	}
+
+	start := h.clock().Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return HealthResult{Reason: err.Error(), Latency: h.clock().Now().Sub(start)}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	result := HealthResult{Status: resp.StatusCode, Latency: h.clock().Now().Sub(start)}
+	result.Body = string(body)
+	if len(result.Body) > healthBodySnippet {
+		result.Body = result.Body[:healthBodySnippet] + "..."
+	}
+
+	switch {
+	case err != nil:
+		result.Reason = fmt.Sprintf("reading body: %v", err)
+	case !h.statusOK(resp.StatusCode):
+		result.Reason = fmt.Sprintf("endpoint returned %d", resp.StatusCode)
+	default:
+		result.Reason = missingHealthFields(body, h.RequiredFields)
+	}
+	result.Healthy = result.Reason == ""
+	return result
+}
+
+// missingHealthFields describes the first required field body lacks, or
+// returns "" when all are present.
+func missingHealthFields(body []byte, fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return fmt.Sprintf("body is not JSON: %v", err)
+	}
+	for _, field := range fields {
+		if !hasJSONPath(doc, strings.Split(field, ".")) {
+			return fmt.Sprintf("body has no %q field", field)
+		}
+	}
+	return ""
+}
+
+func hasJSONPath(v any, path []string) bool {
+	for _, key := range path {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return false
+			}
+			v = node[i]
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// HealthWaitError is returned when WaitHealthy gives up. Attempts holds
+// every failed probe, oldest first.
+type HealthWaitError struct {
+	URL      string
+	Attempts []HealthResult
+	Elapsed  time.Duration
+	Err      error // context error or timeout
+}
+
+func (e *HealthWaitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "health check %s: %v after %v (%d attempts)",
+		e.URL, e.Err, e.Elapsed.Round(time.Millisecond), len(e.Attempts))
+	var details []WaitAttempt
+	for _, a := range e.Attempts {
+		details = recordWaitDetail(details, a.Attempt, a.Reason, 0)
+	}
+	for _, d := range details {
+		if d.First == d.Last {
+			fmt.Fprintf(&b, "\n  #%d: %s", d.First, d.Detail)
+		} else {
+			fmt.Fprintf(&b, "\n  #%d-%d: %s", d.First, d.Last, d.Detail)
+		}
+	}
+	return b.String()
+}
+
+func (e *HealthWaitError) Unwrap() error { return e.Err }
+
+// Last returns the most recent failed attempt.
+func (e *HealthWaitError) Last() HealthResult {
+	if len(e.Attempts) == 0 {
+		return HealthResult{}
+	}
+	return e.Attempts[len(e.Attempts)-1]
+}
+
+// WaitHealthy probes until the endpoint is healthy, backing off from
+// Interval to MaxInterval between attempts, and returns the healthy
+// result. When timeout (if positive) or ctx ends first, the error is a
+// *HealthWaitError with every failed attempt.
+func (h *HealthCheck) WaitHealthy(ctx context.Context, timeout time.Duration) (HealthResult, error) {
+	clock := h.clock()
+	client := h.client()
+	interval, maxInterval := h.Interval, h.MaxInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	if maxInterval <= 0 {
+		maxInterval = 2 * time.Second
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	start := clock.Now()
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := clock.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C()
+	}
+
+	var failed []HealthResult
+	fail := func(err error) (HealthResult, error) {
+		return HealthResult{}, &HealthWaitError{URL: h.URL, Attempts: failed, Elapsed: clock.Now().Sub(start), Err: err}
+	}
+	for attempt, delay := 1, interval; ; attempt++ {
+		result := h.run(ctx, client)
+		result.Attempt = attempt
+		if h.OnAttempt != nil {
+			h.OnAttempt(result)
+		}
+		if result.Healthy {
+			return result, nil
+		}
+		failed = append(failed, result)
+
+		select {
+		case <-ctx.Done():
+			return fail(ctx.Err())
+		case <-deadline:
+			return fail(errWaitTimeout)
+		case <-clock.After(delay):
+		}
+		if delay *= 2; delay > maxInterval {
+			delay = maxInterval
+		}
+	}
+}
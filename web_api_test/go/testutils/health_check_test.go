@@ -0,0 +1,117 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthCheck_Run(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"status":"ok","checks":{"db":"up"},"replicas":[1]}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		check  HealthCheck
+		reason string
+	}{
+		{"healthy", HealthCheck{URL: srv.URL + "/health", RequiredFields: []string{"status", "checks.db", "replicas.0"}}, ""},
+		{"wrong path", HealthCheck{URL: srv.URL + "/healthz"}, "endpoint returned 404"},
+		{"unexpected status", HealthCheck{URL: srv.URL + "/health", ExpectedStatuses: []int{http.StatusNoContent}}, "endpoint returned 200"},
+		{"missing field", HealthCheck{URL: srv.URL + "/health", RequiredFields: []string{"checks.cache"}}, `body has no "checks.cache" field`},
+		{"not json", HealthCheck{URL: srv.URL + "/healthz", AcceptStatus: func(int) bool { return true }, RequiredFields: []string{"status"}}, "body is not JSON"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.check.Run(ctx)
+			if got.Healthy != (tc.reason == "") || !strings.HasPrefix(got.Reason, tc.reason) {
+				t.Errorf("got %+v, want reason %q", got, tc.reason)
+			}
+			if got.Status == 0 || got.Body == "" {
+				t.Errorf("status and body should be recorded: %+v", got)
+			}
+		})
+	}
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	if got := (&HealthCheck{URL: closed.URL}).Run(ctx); got.Healthy || got.Status != 0 || got.Reason == "" {
+		t.Errorf("expected a connection failure, got %+v", got)
+	}
+}
+
+func TestHealthCheck_TLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if got := (&HealthCheck{URL: srv.URL}).Run(context.Background()); got.Healthy || !strings.Contains(got.Reason, "certificate") {
+		t.Errorf("expected an untrusted certificate, got %+v", got)
+	}
+	if got := (&HealthCheck{URL: srv.URL, InsecureSkipVerify: true}).Run(context.Background()); !got.Healthy {
+		t.Errorf("expected healthy with verification off, got %+v", got)
+	}
+}
+
+func TestHealthCheck_WaitHealthy(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"status":"ok"}`)
+	}))
+	defer srv.Close()
+
+	var seen []int
+	check := &HealthCheck{
+		URL:            srv.URL,
+		RequiredFields: []string{"status"},
+		Interval:       time.Millisecond,
+		MaxInterval:    4 * time.Millisecond,
+		OnAttempt:      func(r HealthResult) { seen = append(seen, r.Status) },
+	}
+	got, err := check.WaitHealthy(context.Background(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Healthy || got.Attempt != 4 || len(seen) != 4 || seen[0] != http.StatusServiceUnavailable {
+		t.Errorf("unexpected result %+v after %v", got, seen)
+	}
+}
+
+func TestHealthCheck_WaitHealthyTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	check := &HealthCheck{URL: srv.URL + "/health", Interval: time.Millisecond, MaxInterval: 5 * time.Millisecond}
+	_, err := check.WaitHealthy(context.Background(), 50*time.Millisecond)
+
+	var waitErr *HealthWaitError
+	if !errors.As(err, &waitErr) || !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected a timed out HealthWaitError, got %v", err)
+	}
+	if len(waitErr.Attempts) < 2 {
+		t.Fatalf("expected several attempts, got %d", len(waitErr.Attempts))
+	}
+	for i, a := range waitErr.Attempts {
+		if a.Attempt != i+1 || a.Reason != "endpoint returned 404" {
+			t.Errorf("attempt %d: %+v", i, a)
+		}
+	}
+	if !strings.Contains(err.Error(), "endpoint returned 404") {
+		t.Errorf("error should carry the reason: %v", err)
+	}
+}
@@ -0,0 +1,261 @@
+package testutils
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// HealthThresholds sets when a failing component moves the system mode.
+// Degrade and Offline count consecutive failed checks; Recover counts the
+// consecutive passing checks needed before a component stops counting as
+// failed, which keeps a component that alternates from flapping the mode.
+type HealthThresholds struct {
+	Degrade int
+	Offline int
+	Recover int
+}
+
+// DefaultHealthThresholds degrades after 2 failures, goes offline after 5
+// and recovers after 2 passes.
+var DefaultHealthThresholds = HealthThresholds{Degrade: 2, Offline: 5, Recover: 2}
+
+// componentHealthState tracks one component across polls.
+type componentHealthState struct {
+	failures  int
+	successes int
+	level     Mode // ModeNormal, ModeDegraded or ModeOffline
+	lastError string
+}
+
+// HealthWatcher polls the components in a registry and drives a
+// ModeManager from the results: the worst component decides between
+// ModeNormal, ModeDegraded and ModeOffline. Modes it does not manage, such
+// as ModeMaintenance set by a test, are left alone until set back.
+type HealthWatcher struct {
+	registry *ComponentRegistry
+	modes    ModeManager
+	logger   Logger
+	events   *EventBus
+	clock    Clock
+	interval time.Duration
+	timeout  time.Duration
+
+	mu         sync.Mutex
+	defaults   HealthThresholds
+	thresholds map[string]HealthThresholds
+	states     map[string]*componentHealthState
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// HealthWatcherOption configures a HealthWatcher.
+type HealthWatcherOption func(*HealthWatcher)
+
+// WithHealthThresholds replaces DefaultHealthThresholds for every component.
+func WithHealthThresholds(t HealthThresholds) HealthWatcherOption {
+	return func(w *HealthWatcher) { w.defaults = t }
+}
+
+// WithComponentThresholds sets the thresholds for one component.
+func WithComponentThresholds(name string, t HealthThresholds) HealthWatcherOption {
+	return func(w *HealthWatcher) { w.thresholds[name] = t }
+}
+
+// WithWatcherEventBus publishes health_changed events to bus.
+func WithWatcherEventBus(bus *EventBus) HealthWatcherOption {
+	return func(w *HealthWatcher) { w.events = bus }
+}
+
+// WithWatcherClock sets the clock that paces polling and health timeouts.
+func WithWatcherClock(c Clock) HealthWatcherOption {
+	return func(w *HealthWatcher) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// WithWatcherTimeout bounds each component's check (default 2s).
+func WithWatcherTimeout(d time.Duration) HealthWatcherOption {
+	return func(w *HealthWatcher) {
+		if d > 0 {
+			w.timeout = d
+		}
+	}
+}
+
+// NewHealthWatcher creates a watcher polling at config.CollectInterval
+// (10s when unset). Call Start to begin polling or Check to poll once.
+func NewHealthWatcher(registry *ComponentRegistry, modes ModeManager, logger Logger, config MetricsConfig, opts ...HealthWatcherOption) *HealthWatcher {
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	interval := config.CollectInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	w := &HealthWatcher{
+		registry:   registry,
+		modes:      modes,
+		logger:     logger,
+		clock:      RealClock{},
+		interval:   interval,
+		timeout:    2 * time.Second,
+		defaults:   DefaultHealthThresholds,
+		thresholds: make(map[string]HealthThresholds),
+		states:     make(map[string]*componentHealthState),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start polls in the background until Stop is called.
+func (w *HealthWatcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return errors.New("health watcher already started")
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := w.clock.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				w.Check()
+			case <-stop:
+				return
+			}
+		}
+	}(w.stop, w.done)
+	return nil
+}
+
+// Stop ends background polling and waits for an in-flight poll to finish.
+func (w *HealthWatcher) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Check polls every component once, updates the failure counts and moves
+// the mode if the worst component's level changed. It returns the mode the
+// watcher wants, which is not applied while an unmanaged mode is set.
+func (w *HealthWatcher) Check() Mode {
+	comps := w.registry.All()
+	reports := make([]ComponentHealthReport, len(comps))
+	var wg sync.WaitGroup
+	for i, c := range comps {
+		wg.Add(1)
+		go func(i int, c Component) {
+			defer wg.Done()
+			reports[i] = probeComponent(c, w.timeout, w.clock)
+		}(i, c)
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, r := range reports {
+		w.observe(r)
+	}
+
+	target, culprit := ModeNormal, ""
+	for _, r := range reports {
+		if level := w.states[r.Name].level; modeSeverity(level) > modeSeverity(target) {
+			target, culprit = level, r.Name
+		}
+	}
+
+	current := w.modes.CurrentMode()
+	if current == target || modeSeverity(current) < 0 {
+		return target
+	}
+
+	fields := map[string]any{"from": string(current), "to": string(target)}
+	if culprit != "" {
+		st := w.states[culprit]
+		fields["component"] = culprit
+		fields["failures"] = st.failures
+		fields["error"] = st.lastError
+		w.logger.Warn("component health changed system mode", fields)
+	} else {
+		w.logger.Info("components recovered, restoring normal mode", fields)
+	}
+	w.modes.SetMode(target)
+	w.events.Emit(EventHealthChanged, "health_watcher", fields)
+	return target
+}
+
+// observe folds one report into the component's state. Must hold w.mu.
+func (w *HealthWatcher) observe(r ComponentHealthReport) {
+	st, ok := w.states[r.Name]
+	if !ok {
+		st = &componentHealthState{level: ModeNormal}
+		w.states[r.Name] = st
+	}
+	t, ok := w.thresholds[r.Name]
+	if !ok {
+		t = w.defaults
+	}
+
+	if !r.Healthy {
+		st.successes = 0
+		st.failures++
+		st.lastError = r.Error
+		switch {
+		case t.Offline > 0 && st.failures >= t.Offline:
+			st.level = ModeOffline
+		case t.Degrade > 0 && st.failures >= t.Degrade && st.level == ModeNormal:
+			st.level = ModeDegraded
+		}
+		return
+	}
+
+	st.successes++
+	if st.level == ModeNormal || st.successes >= t.Recover {
+		st.failures = 0
+		st.level = ModeNormal
+		st.lastError = ""
+	}
+}
+
+// Failures returns the current consecutive failure count for a component.
+func (w *HealthWatcher) Failures(name string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if st, ok := w.states[name]; ok {
+		return st.failures
+	}
+	return 0
+}
+
+// modeSeverity orders the modes the watcher manages; other modes are -1.
+func modeSeverity(m Mode) int {
+	switch m {
+	case ModeNormal:
+		return 0
+	case ModeDegraded:
+		return 1
+	case ModeOffline:
+		return 2
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,110 @@
+package testutils
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthWatcher_DegradesGoesOfflineAndRecovers(t *testing.T) {
+	var healthy atomic.Bool
+	healthy.Store(true)
+	db := NewMockComponent("db")
+	db.SetHealthFunc(func() (bool, error) {
+		if healthy.Load() {
+			return true, nil
+		}
+		return false, errors.New("connection refused")
+	})
+	reg := NewComponentRegistry()
+	reg.MustRegister(db)
+	reg.MustRegister(NewMockComponent("cache"))
+
+	bus := NewEventBus()
+	defer bus.Close()
+	events := bus.Subscribe(EventHealthChanged)
+
+	modes := NewInMemoryModeManager(ModeNormal)
+	defer modes.Close()
+	w := NewHealthWatcher(reg, modes, nil, MetricsConfig{},
+		WithComponentThresholds("db", HealthThresholds{Degrade: 2, Offline: 4, Recover: 2}),
+		WithWatcherEventBus(bus))
+
+	steps := []struct {
+		healthy bool
+		want    Mode
+	}{
+		{false, ModeNormal},
+		{false, ModeDegraded},
+		{true, ModeDegraded}, // one pass neither recovers nor resets the count
+		{false, ModeDegraded},
+		{false, ModeOffline},
+		{true, ModeOffline},
+		{true, ModeNormal},
+	}
+	for i, step := range steps {
+		healthy.Store(step.healthy)
+		w.Check()
+		if got := modes.CurrentMode(); got != step.want {
+			t.Fatalf("step %d: mode %q, want %q", i, got, step.want)
+		}
+	}
+
+	var transitions []string
+	for len(events) > 0 {
+		e := <-events
+		transitions = append(transitions, e.Fields["to"].(string))
+		if e.Fields["to"] != string(ModeNormal) && e.Fields["component"] != "db" {
+			t.Errorf("event did not name the failing component: %v", e.Fields)
+		}
+	}
+	if want := []string{"degraded", "offline", "normal"}; len(transitions) != 3 ||
+		transitions[0] != want[0] || transitions[1] != want[1] || transitions[2] != want[2] {
+		t.Errorf("transitions %v, want %v", transitions, want)
+	}
+}
+
+func TestHealthWatcher_LeavesUnmanagedModesAlone(t *testing.T) {
+	db := NewMockComponent("db")
+	db.SetHealthFunc(func() (bool, error) { return false, nil })
+	reg := NewComponentRegistry()
+	reg.MustRegister(db)
+
+	modes := NewInMemoryModeManager(ModeMaintenance)
+	defer modes.Close()
+	w := NewHealthWatcher(reg, modes, nil, MetricsConfig{}, WithHealthThresholds(HealthThresholds{Degrade: 1, Offline: 2, Recover: 1}))
+	for i := 0; i < 3; i++ {
+		w.Check()
+	}
+	if got := modes.CurrentMode(); got != ModeMaintenance {
+		t.Errorf("mode %q, want maintenance left in place", got)
+	}
+	if w.Failures("db") != 3 {
+		t.Errorf("failures %d, want 3", w.Failures("db"))
+	}
+}
+
+func TestHealthWatcher_StartPollsAtInterval(t *testing.T) {
+	db := NewMockComponent("db")
+	db.SetHealthFunc(func() (bool, error) { return false, nil })
+	reg := NewComponentRegistry()
+	reg.MustRegister(db)
+
+	modes := NewInMemoryModeManager(ModeNormal)
+	defer modes.Close()
+	w := NewHealthWatcher(reg, modes, nil, MetricsConfig{CollectInterval: 5 * time.Millisecond},
+		WithHealthThresholds(HealthThresholds{Degrade: 1, Offline: 100, Recover: 1}))
+	if err := w.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for modes.CurrentMode() != ModeDegraded {
+		if time.Now().After(deadline) {
+			t.Fatal("watcher never degraded the mode")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
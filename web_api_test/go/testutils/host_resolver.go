@@ -0,0 +1,203 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Host resolution – injectable DNS for PortChecker and HTTPTestClient
+// ------------------------------------------------------------------------
+
+// Resolver resolves a host name to IP addresses. *net.Resolver satisfies
+// it, so net.DefaultResolver is the production implementation.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// MapResolver is a Resolver backed by a static host→IPs map, for tests that
+// use compose service names without /etc/hosts entries. Hosts that are not
+// in the map fail with an NXDOMAIN *net.DNSError; IP literals resolve to
+// themselves.
+type MapResolver struct {
+	mu      sync.Mutex
+	hosts   map[string][]string
+	errs    map[string]error
+	latency map[string]time.Duration
+	lookups map[string]int
+	clock   Clock
+}
+
+// NewMapResolver returns a resolver knowing the given hosts.
+func NewMapResolver(hosts map[string][]string) *MapResolver {
+	r := &MapResolver{
+		hosts:   make(map[string][]string),
+		errs:    make(map[string]error),
+		latency: make(map[string]time.Duration),
+		lookups: make(map[string]int),
+		clock:   RealClock{},
+	}
+	for host, ips := range hosts {
+		r.Set(host, ips...)
+	}
+	return r
+}
+
+// Set maps host to ips, replacing earlier addresses and clearing a
+// programmed error.
+func (r *MapResolver) Set(host string, ips ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = normalizeHost(host)
+	r.hosts[host] = append([]string(nil), ips...)
+	delete(r.errs, host)
+}
+
+// SetError makes lookups of host fail with err. A nil err clears it.
+func (r *MapResolver) SetError(host string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	host = normalizeHost(host)
+	if err == nil {
+		delete(r.errs, host)
+		return
+	}
+	r.errs[host] = err
+}
+
+// SetLatency delays lookups of host by d, or of every host when host is
+// "*". Lookups give up early when their context ends.
+func (r *MapResolver) SetLatency(host string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latency[normalizeHost(host)] = d
+}
+
+// SetClock sets the clock latencies are measured on.
+func (r *MapResolver) SetClock(clock Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clock
+}
+
+// Lookups returns how many times host was looked up.
+func (r *MapResolver) Lookups(host string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups[normalizeHost(host)]
+}
+
+// LookupHost implements Resolver.
+func (r *MapResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	key := normalizeHost(host)
+
+	r.mu.Lock()
+	r.lookups[key]++
+	delay, ok := r.latency[key]
+	if !ok {
+		delay = r.latency["*"]
+	}
+	ips, found := r.hosts[key]
+	ips = append([]string(nil), ips...)
+	err := r.errs[key]
+	clock := r.clock
+	r.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, &net.DNSError{Err: ctx.Err().Error(), Name: host, IsTimeout: errors.Is(ctx.Err(), context.DeadlineExceeded)}
+		case <-afterWith(clock, delay):
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !found || len(ips) == 0 {
+		return nil, NXDomain(host)
+	}
+	return ips, nil
+}
+
+// NXDomain returns the error a resolver gives for a host that does not
+// exist.
+func NXDomain(host string) error {
+	return &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// IsDNSError reports whether err came from resolving a host name rather
+// than from connecting to it.
+func IsDNSError(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// resolvingDialer dials through a Resolver: the host of address is
+// resolved, addresses that do not suit network are dropped, and each
+// remaining IP is tried in order. It returns the IP of the last dial, so
+// callers can report which address was used.
+func resolvingDialer(resolver Resolver, dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, string, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, string, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, "", err
+		}
+		if net.ParseIP(host) != nil {
+			conn, err := dial(ctx, network, address)
+			return conn, host, err
+		}
+		ips, err := resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, "", &net.OpError{Op: "dial", Net: network, Err: err}
+		}
+		ips = filterIPs(ips, network)
+		if len(ips) == 0 {
+			return nil, "", &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{
+				Err: "no suitable address for " + network, Name: host, IsNotFound: true,
+			}}
+		}
+		var ip string
+		for _, ip = range ips {
+			conn, dialErr := dial(ctx, network, net.JoinHostPort(ip, port))
+			if dialErr == nil {
+				return conn, ip, nil
+			}
+			err = dialErr
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		return nil, ip, err
+	}
+}
+
+// filterIPs keeps the addresses usable on network: IPv4 for "tcp4"/"udp4",
+// IPv6 for "tcp6"/"udp6", all of them otherwise.
+func filterIPs(ips []string, network string) []string {
+	want4, want6 := strings.HasSuffix(network, "4"), strings.HasSuffix(network, "6")
+	if !want4 && !want6 {
+		return ips
+	}
+	var out []string
+	for _, s := range ips {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			continue
+		}
+		if is4 := ip.To4() != nil; is4 == want4 {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func resolverPortChecker(r Resolver, opts ...PortCheckerOption) *PortChecker {
+	return NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:   time.Second,
+		RetryInterval: time.Millisecond,
+		MaxRetries:    1,
+		MinPort:       1,
+		MaxPort:       65535,
+	}, append([]PortCheckerOption{WithPortCheckerResolver(r)}, opts...)...)
+}
+
+func TestPortChecker_ResolverNXDOMAINIsNotConnectionRefused(t *testing.T) {
+	r := NewMapResolver(map[string][]string{"db": {"127.0.0.1"}})
+	pc := resolverPortChecker(r)
+	port := closedPort(t)
+
+	result, err := pc.IsPortOpen(context.Background(), "cache", port, TCP)
+	if !IsDNSError(err) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("unknown host: err = %v", err)
+	}
+	if result.ErrorType != "dns_not_found" || result.ResolvedIP != "" {
+		t.Errorf("unknown host: ErrorType = %q, ResolvedIP = %q", result.ErrorType, result.ResolvedIP)
+	}
+	if n := r.Lookups("cache"); n != 2 {
+		t.Errorf("lookups = %d, want one per attempt", n)
+	}
+
+	result, err = pc.IsPortOpen(context.Background(), "db", port, TCP)
+	if IsDNSError(err) || !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("refused: err = %v", err)
+	}
+	if result.ErrorType != "connection_failed" || result.ResolvedIP != "127.0.0.1" {
+		t.Errorf("refused: ErrorType = %q, ResolvedIP = %q", result.ErrorType, result.ResolvedIP)
+	}
+
+	r.SetError("db", &net.DNSError{Err: "server misbehaving", Name: "db", IsTemporary: true})
+	if result, _ := pc.IsPortOpen(context.Background(), "db", port, TCP); result.ErrorType != "dns_error" {
+		t.Errorf("resolver failure: ErrorType = %q", result.ErrorType)
+	}
+}
+
+func TestPortChecker_ResolverTriesEachAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	// 10.0.0.1 stands in for a stale address that refuses connections.
+	var dialed []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		if host, _, _ := net.SplitHostPort(address); host == "10.0.0.1" {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+		}
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+	r := NewMapResolver(map[string][]string{"api": {"::1", "10.0.0.1", "127.0.0.1"}})
+	pc := resolverPortChecker(r, WithPortCheckerDialer(dial))
+	pc.config.IPVersion = IPv4
+
+	result, err := pc.IsPortOpen(context.Background(), "api", port, TCP)
+	if err != nil || !result.Open {
+		t.Fatalf("IsPortOpen: %v", err)
+	}
+	if result.ResolvedIP != "127.0.0.1" {
+		t.Errorf("ResolvedIP = %q", result.ResolvedIP)
+	}
+	want := []string{net.JoinHostPort("10.0.0.1", strconv.Itoa(port)), net.JoinHostPort("127.0.0.1", strconv.Itoa(port))}
+	if len(dialed) != 2 || dialed[0] != want[0] || dialed[1] != want[1] {
+		t.Errorf("dialed %v, want %v (IPv6 filtered out)", dialed, want)
+	}
+}
+
+func TestMapResolver_Latency(t *testing.T) {
+	r := NewMapResolver(map[string][]string{"slow": {"127.0.0.1"}})
+	r.SetLatency("*", time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := r.LookupHost(ctx, "slow")
+	if !IsDNSError(err) || Kind(err) != KindTimeout {
+		t.Fatalf("err = %v (kind %q)", err, Kind(err))
+	}
+
+	clock := NewMockClock(time.Time{})
+	r.SetClock(clock)
+	r.SetLatency("slow", 50*time.Millisecond)
+	done := make(chan []string)
+	go func() {
+		ips, _ := r.LookupHost(context.Background(), "SLOW.")
+		done <- ips
+	}()
+	// Advance in steps until the lookup, which may not have reached its
+	// timer yet, returns.
+	for {
+		select {
+		case ips := <-done:
+			if len(ips) != 1 || ips[0] != "127.0.0.1" {
+				t.Errorf("ips = %v", ips)
+			}
+			return
+		case <-time.After(time.Millisecond):
+			clock.Advance(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestHTTPTestClient_WithResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	r := NewMapResolver(map[string][]string{"api.compose": {"127.0.0.1"}})
+	client := NewHTTPTestClient("http://api.compose:"+port, WithResolver(r))
+	resp, err := client.Get(context.Background(), "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+
+	client = NewHTTPTestClient("http://missing.compose:"+port, WithResolver(r))
+	if _, err := client.Get(context.Background(), "/"); !IsDNSError(err) {
+		t.Errorf("err = %v, want a DNS error", err)
+	}
+}
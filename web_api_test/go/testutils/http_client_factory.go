@@ -0,0 +1,264 @@
+package testutils
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// HTTPClientFactory – named client profiles over shared transports
+// ------------------------------------------------------------------------
+
+// Built-in HTTPClientFactory profiles.
+const (
+	HTTPProfileDefault     = "default"      // the factory's base settings
+	HTTPProfileLoad        = "load"         // many connections per host for load tests
+	HTTPProfileSlowTimeout = "slow-timeout" // five minute client timeout for slow endpoints
+)
+
+// HTTPClientFactory builds http.Clients from an HTTPClientConfig and named
+// profiles that adjust it, so a test section can switch to different
+// connection limits or timeouts without rebuilding clients by hand:
+//
+//	clients := NewHTTPClientFactoryFromConfig(cfg)
+//	defer clients.Close()
+//	load, _ := clients.Client(HTTPProfileLoad)
+//	raw, _ := clients.CloneWith(HTTPProfileDefault, func(c *HTTPClientConfig) {
+//		c.DisableCompression = true
+//	})
+//
+// Clients whose Transport settings are equal share one Transport, and so
+// its connection pool; Timeout alone never forces a new Transport. A
+// Transport's idle connections are closed when the last client using it
+// is disposed of. It is safe for concurrent use.
+type HTTPClientFactory struct {
+	mu         sync.Mutex
+	base       HTTPClientConfig
+	profiles   map[string]func(*HTTPClientConfig)
+	clients    map[string]*http.Client // cached by profile
+	clones     map[*http.Client]struct{}
+	transports map[HTTPClientConfig]*factoryTransport // keyed by transportKey
+}
+
+// factoryTransport counts the clients using a Transport.
+type factoryTransport struct {
+	transport *http.Transport
+	refs      int
+}
+
+// NewHTTPClientFactory returns a factory for cfg with the built-in
+// profiles registered. Zero fields of cfg take the defaults of
+// DefaultConfig().HTTP.
+func NewHTTPClientFactory(cfg HTTPClientConfig) *HTTPClientFactory {
+	f := &HTTPClientFactory{
+		base:       overlayHTTPConfig(DefaultConfig().HTTP, cfg),
+		profiles:   make(map[string]func(*HTTPClientConfig)),
+		clients:    make(map[string]*http.Client),
+		clones:     make(map[*http.Client]struct{}),
+		transports: make(map[HTTPClientConfig]*factoryTransport),
+	}
+	f.profiles[HTTPProfileDefault] = func(*HTTPClientConfig) {}
+	f.profiles[HTTPProfileLoad] = func(c *HTTPClientConfig) {
+		c.MaxIdleConns = 1000
+		c.MaxIdleConnsPerHost = 256
+		c.MaxConnsPerHost = 0
+	}
+	f.profiles[HTTPProfileSlowTimeout] = func(c *HTTPClientConfig) {
+		c.Timeout = 5 * time.Minute
+	}
+	return f
+}
+
+// NewHTTPClientFactoryFromConfig builds a factory from cfg.HTTP and adds
+// or adjusts the profiles in cfg.HTTPProfiles.
+func NewHTTPClientFactoryFromConfig(cfg *Config) *HTTPClientFactory {
+	f := NewHTTPClientFactory(cfg.HTTP)
+	for name, over := range cfg.HTTPProfiles {
+		prev := f.profiles[name]
+		over := over
+		f.profiles[name] = func(c *HTTPClientConfig) {
+			if prev != nil {
+				prev(c)
+			}
+			*c = overlayHTTPConfig(*c, over)
+		}
+	}
+	return f
+}
+
+// RegisterProfile adds the profile name, or replaces it. edit adjusts a
+// copy of the base settings. A client already built for name keeps its
+// old settings until the profile is disposed of.
+func (f *HTTPClientFactory) RegisterProfile(name string, edit func(*HTTPClientConfig)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.profiles[name] = edit
+}
+
+// Profiles returns the registered profile names, sorted.
+func (f *HTTPClientFactory) Profiles() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := make([]string, 0, len(f.profiles))
+	for name := range f.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ProfileConfig returns the settings of profile.
+func (f *HTTPClientFactory) ProfileConfig(profile string) (HTTPClientConfig, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.profileConfig(profile)
+}
+
+func (f *HTTPClientFactory) profileConfig(profile string) (HTTPClientConfig, error) {
+	edit, ok := f.profiles[profile]
+	if !ok {
+		return HTTPClientConfig{}, kindErrorf(ErrValidation, "unknown HTTP client profile %q", profile)
+	}
+	cfg := f.base
+	edit(&cfg)
+	return cfg, nil
+}
+
+// Client returns the client of profile, building it on first use.
+func (f *HTTPClientFactory) Client(profile string) (*http.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[profile]; ok {
+		return c, nil
+	}
+	cfg, err := f.profileConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	c := f.newClient(cfg)
+	f.clients[profile] = c
+	return c, nil
+}
+
+// CloneWith returns a new client with the settings of profile adjusted by
+// override, e.g. compression disabled for one test. It shares a Transport
+// with other clients whose Transport settings end up equal, and gets a new
+// one otherwise. The client is not cached; Release disposes of it.
+func (f *HTTPClientFactory) CloneWith(profile string, override func(*HTTPClientConfig)) (*http.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cfg, err := f.profileConfig(profile)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		override(&cfg)
+	}
+	c := f.newClient(cfg)
+	f.clones[c] = struct{}{}
+	return c, nil
+}
+
+// Dispose drops the cached client of profile, closing the idle
+// connections of its Transport if no other client uses it. The next
+// Client call builds a new client.
+func (f *HTTPClientFactory) Dispose(profile string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[profile]; ok {
+		delete(f.clients, profile)
+		f.release(c)
+	}
+}
+
+// Release disposes of a client returned by CloneWith.
+func (f *HTTPClientFactory) Release(c *http.Client) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.clones[c]; ok {
+		delete(f.clones, c)
+		f.release(c)
+	}
+}
+
+// Close disposes of every client and closes all idle connections.
+func (f *HTTPClientFactory) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for profile, c := range f.clients {
+		delete(f.clients, profile)
+		f.release(c)
+	}
+	for c := range f.clones {
+		delete(f.clones, c)
+		f.release(c)
+	}
+}
+
+// newClient builds a client for cfg on a shared or new Transport.
+func (f *HTTPClientFactory) newClient(cfg HTTPClientConfig) *http.Client {
+	key := transportKey(cfg)
+	ft, ok := f.transports[key]
+	if !ok {
+		ft = &factoryTransport{transport: newFactoryTransport(cfg)}
+		f.transports[key] = ft
+	}
+	ft.refs++
+	return &http.Client{Timeout: cfg.Timeout, Transport: ft.transport}
+}
+
+// release drops c's reference to its Transport.
+func (f *HTTPClientFactory) release(c *http.Client) {
+	for key, ft := range f.transports {
+		if ft.transport != c.Transport {
+			continue
+		}
+		if ft.refs--; ft.refs == 0 {
+			delete(f.transports, key)
+			ft.transport.CloseIdleConnections()
+		}
+		return
+	}
+}
+
+// transportKey returns cfg without its client-level settings, so equal
+// keys can share a Transport.
+func transportKey(cfg HTTPClientConfig) HTTPClientConfig {
+	cfg.Timeout = 0
+	return cfg
+}
+
+func newFactoryTransport(cfg HTTPClientConfig) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: cfg.KeepAlive,
+		}).DialContext,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    cfg.DisableCompression,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		},
+	}
+}
+
+// overlayHTTPConfig returns base with the non-zero fields of over, the
+// rule Config.Merge applies.
+func overlayHTTPConfig(base, over HTTPClientConfig) HTTPClientConfig {
+	new(Config).mergeStructs(reflect.ValueOf(&base).Elem(), reflect.ValueOf(over))
+	return base
+}
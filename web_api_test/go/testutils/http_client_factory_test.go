@@ -0,0 +1,146 @@
+package testutils
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientFactory_TransportSharing(t *testing.T) {
+	f := NewHTTPClientFactory(HTTPClientConfig{MaxConnsPerHost: 4})
+	defer f.Close()
+
+	def, err := f.Client(HTTPProfileDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slow, _ := f.Client(HTTPProfileSlowTimeout)
+	load, _ := f.Client(HTTPProfileLoad)
+
+	if again, _ := f.Client(HTTPProfileDefault); again != def {
+		t.Error("profile clients must be cached")
+	}
+	if slow.Transport != def.Transport || slow.Timeout != 5*time.Minute {
+		t.Errorf("a profile differing only in Timeout should share the Transport")
+	}
+	if load.Transport == def.Transport {
+		t.Error("profiles with different connection settings must not share a Transport")
+	}
+	if tr := load.Transport.(*http.Transport); tr.MaxConnsPerHost != 0 || tr.MaxIdleConnsPerHost != 256 {
+		t.Errorf("load profile not applied: %d/%d", tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost)
+	}
+	if tr := def.Transport.(*http.Transport); tr.MaxConnsPerHost != 4 || tr.IdleConnTimeout != 90*time.Second {
+		t.Errorf("base settings or defaults not applied: %d %v", tr.MaxConnsPerHost, tr.IdleConnTimeout)
+	}
+
+	same, _ := f.CloneWith(HTTPProfileDefault, func(c *HTTPClientConfig) { c.Timeout = time.Second })
+	raw, _ := f.CloneWith(HTTPProfileDefault, func(c *HTTPClientConfig) { c.DisableCompression = true })
+	if same.Transport != def.Transport || raw.Transport == def.Transport {
+		t.Error("CloneWith must share the Transport only when its settings are unchanged")
+	}
+
+	if _, err := f.Client("bulk"); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error for an unknown profile, got %v", err)
+	}
+}
+
+func TestHTTPClientFactory_DisableCompression(t *testing.T) {
+	var mu sync.Mutex
+	var encodings []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	f := NewHTTPClientFactory(HTTPClientConfig{})
+	defer f.Close()
+	def, _ := f.Client(HTTPProfileDefault)
+	raw, _ := f.CloneWith(HTTPProfileDefault, func(c *HTTPClientConfig) { c.DisableCompression = true })
+	for _, c := range []*http.Client{def, raw} {
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if encodings[0] != "gzip" || encodings[1] != "" {
+		t.Errorf("Accept-Encoding sent: %q", encodings)
+	}
+}
+
+func TestHTTPClientFactory_DisposeClosesIdleConnections(t *testing.T) {
+	closed := make(chan struct{}, 4)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			closed <- struct{}{}
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	f := NewHTTPClientFactory(HTTPClientConfig{})
+	defer f.Close()
+	def, _ := f.Client(HTTPProfileDefault)
+	if _, err := f.Client(HTTPProfileSlowTimeout); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := def.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The slow-timeout client still uses the Transport, so its pool stays.
+	f.Dispose(HTTPProfileDefault)
+	select {
+	case <-closed:
+		t.Fatal("idle connection closed while the Transport is still in use")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if again, _ := f.Client(HTTPProfileDefault); again == def {
+		t.Error("Dispose must drop the cached client")
+	}
+	f.Dispose(HTTPProfileDefault)
+
+	f.Dispose(HTTPProfileSlowTimeout)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("idle connection not closed after the last client was disposed of")
+	}
+}
+
+func TestNewHTTPClientFactoryFromConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.HTTPProfiles = map[string]HTTPClientConfig{
+		HTTPProfileLoad: {MaxConnsPerHost: 50},
+		"reports":       {Timeout: 2 * time.Minute, DisableKeepAlives: true},
+	}
+	f := NewHTTPClientFactoryFromConfig(cfg)
+	defer f.Close()
+
+	load, err := f.ProfileConfig(HTTPProfileLoad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if load.MaxConnsPerHost != 50 || load.MaxIdleConnsPerHost != 256 {
+		t.Errorf("config profile must adjust the built-in one: %+v", load)
+	}
+	reports, err := f.ProfileConfig("reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reports.Timeout != 2*time.Minute || !reports.DisableKeepAlives || reports.IdleConnTimeout != cfg.HTTP.IdleConnTimeout {
+		t.Errorf("unexpected reports profile %+v", reports)
+	}
+	if got := f.Profiles(); len(got) != 4 {
+		t.Errorf("expected 4 profiles, got %q", got)
+	}
+}
@@ -0,0 +1,478 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------------------
+// HTTPTestClient – API client with cookie sessions and token plumbing
+// ------------------------------------------------------------------------
+
+// TokenRefresher obtains a new bearer token after the server answered 401.
+// It is called with the client so it can use LoginJSON or the session cookies;
+// requests made with the ctx it receives are never refreshed themselves.
+type TokenRefresher func(ctx context.Context, c *HTTPTestClient) (string, error)
+
+// HTTPTestClient sends requests to an API under test. It keeps an optional
+// cookie jar and an Authorization value that is attached to every request
+// unless a request supplies its own.
+type HTTPTestClient struct {
+	baseURL string
+	client  *http.Client
+	headers http.Header
+
+	mu      sync.RWMutex
+	auth    string // Authorization header value, empty for none
+	refresh TokenRefresher
+	retry   *RetryConfig // nil disables retries; see WithRetry
+	tracer  Tracer       // nil disables client spans; see WithTracer
+
+	envelope *ErrorEnvelopeContract // nil disables; see EnforceErrorEnvelope
+
+	// refreshMu guards refreshing, the in-flight refresh that concurrent
+	// 401s wait on so only one runs.
+	refreshMu  sync.Mutex
+	refreshing *tokenRefresh
+}
+
+// tokenRefresh is one TokenRefresher call; done is closed once err is set.
+type tokenRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// refreshingKey marks the context passed to the TokenRefresher.
+type refreshingKey struct{}
+
+// HTTPTestClientOption configures an HTTPTestClient.
+type HTTPTestClientOption interface {
+	applyClient(*HTTPTestClient)
+}
+
+// RequestOption configures a single HTTPTestClient request.
+type RequestOption interface {
+	applyRequest(*http.Request)
+}
+
+type clientOptionFunc func(*HTTPTestClient)
+
+func (f clientOptionFunc) applyClient(c *HTTPTestClient) { f(c) }
+
+// AuthOption sets the Authorization header. It can be passed to
+// NewHTTPTestClient to apply to every request, or to a single request to
+// override the client's credentials for that call.
+type AuthOption struct {
+	value string
+}
+
+func (o AuthOption) applyClient(c *HTTPTestClient) { c.auth = o.value }
+
+func (o AuthOption) applyRequest(r *http.Request) { r.Header.Set("Authorization", o.value) }
+
+// WithBearerToken authenticates with "Authorization: Bearer <token>".
+func WithBearerToken(token string) AuthOption {
+	return AuthOption{value: "Bearer " + token}
+}
+
+// WithBasicAuth authenticates with HTTP basic credentials.
+func WithBasicAuth(user, pass string) AuthOption {
+	return AuthOption{value: "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))}
+}
+
+// RequestHeader sets a header on a single request.
+type RequestHeader struct {
+	Key, Value string
+}
+
+func (h RequestHeader) applyRequest(r *http.Request) { r.Header.Set(h.Key, h.Value) }
+
+// WithCookieJar gives the client a cookie jar so session cookies set by the
+// server are sent back on later requests. Clients have no jar by default.
+func WithCookieJar() HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) {
+		jar, _ := cookiejar.New(nil) // only fails for a bad PublicSuffixList
+		c.client.Jar = jar
+	})
+}
+
+// WithTokenRefresh sets the callback used when a request authenticated with
+// the client's token is answered 401. The request is retried once with the
+// refreshed token.
+func WithTokenRefresh(fn TokenRefresher) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) { c.refresh = fn })
+}
+
+// WithDefaultHeader sets a header sent on every request.
+func WithDefaultHeader(key, value string) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) { c.headers.Set(key, value) })
+}
+
+// WithTransport replaces the client's http.Client. A jar configured by
+// WithCookieJar is kept if hc has none.
+func WithTransport(hc *http.Client) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) {
+		if hc.Jar == nil {
+			hc.Jar = c.client.Jar
+		}
+		c.client = hc
+	})
+}
+
+// WithResolver resolves host names with r, e.g. a MapResolver for compose
+// service names, by installing a DialContext on a clone of the client's
+// *http.Transport (or of http.DefaultTransport). Put it after WithTransport
+// when both are used.
+func WithResolver(r Resolver) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) {
+		base, ok := c.client.Transport.(*http.Transport)
+		if !ok {
+			base = http.DefaultTransport.(*http.Transport)
+		}
+		tr := base.Clone()
+		dial := tr.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		resolving := resolvingDialer(r, dial)
+		tr.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, _, err := resolving(ctx, network, address)
+			return conn, err
+		}
+		c.client.Transport = tr
+	})
+}
+
+// WithTracer starts a client span with tracer around every Do (covering its
+// retries and token refresh) and sends it as the traceparent header, so
+// servers using the Tracing middleware continue the same trace.
+func WithTracer(tracer Tracer) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) { c.tracer = tracer })
+}
+
+// NewHTTPTestClient creates a client for the API at baseURL. Redirects are
+// not followed, matching TestApplication.Client.
+func NewHTTPTestClient(baseURL string, opts ...HTTPTestClientOption) *HTTPTestClient {
+	c := &HTTPTestClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client: &http.Client{
+			Timeout: DefaultTimeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		headers: make(http.Header),
+	}
+	for _, opt := range opts {
+		opt.applyClient(c)
+	}
+	return c
+}
+
+// HTTPClient returns the underlying http.Client.
+func (c *HTTPTestClient) HTTPClient() *http.Client { return c.client }
+
+// Jar returns the cookie jar, or nil when WithCookieJar was not used.
+func (c *HTTPTestClient) Jar() http.CookieJar { return c.client.Jar }
+
+// Cookies returns the cookies the jar would send to path.
+func (c *HTTPTestClient) Cookies(path string) []*http.Cookie {
+	if c.client.Jar == nil {
+		return nil
+	}
+	u, err := url.Parse(c.url(path))
+	if err != nil {
+		return nil
+	}
+	return c.client.Jar.Cookies(u)
+}
+
+// SetBearerToken replaces the client's credentials with a bearer token.
+func (c *HTTPTestClient) SetBearerToken(token string) {
+	c.setAuth(WithBearerToken(token).value)
+}
+
+// SetBasicAuth replaces the client's credentials with basic auth.
+func (c *HTTPTestClient) SetBasicAuth(user, pass string) {
+	c.setAuth(WithBasicAuth(user, pass).value)
+}
+
+// ClearAuth removes the client's credentials.
+func (c *HTTPTestClient) ClearAuth() { c.setAuth("") }
+
+// Token returns the current bearer token, or "" if the client is not using
+// bearer authentication.
+func (c *HTTPTestClient) Token() string {
+	token, ok := strings.CutPrefix(c.currentAuth(), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+func (c *HTTPTestClient) setAuth(v string) {
+	c.mu.Lock()
+	c.auth = v
+	c.mu.Unlock()
+}
+
+func (c *HTTPTestClient) currentAuth() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.auth
+}
+
+// LoginJSON posts creds as JSON to path, extracts the token found at
+// tokenJSONPath in the response (a dotted path such as "data.access_token";
+// numeric segments index arrays) and stores it as the client's bearer token.
+func (c *HTTPTestClient) LoginJSON(ctx context.Context, path string, creds any, tokenJSONPath string) (string, error) {
+	resp, err := c.Do(ctx, http.MethodPost, path, creds)
+	if err != nil {
+		return "", fmt.Errorf("login: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("login: read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("login: %s %s returned %d: %s", http.MethodPost, path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("login: decode response: %w", err)
+	}
+	v, ok := lookupJSONPath(doc, tokenJSONPath)
+	if !ok {
+		return "", fmt.Errorf("login: no value at %q in response", tokenJSONPath)
+	}
+	token, ok := v.(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("login: value at %q is %T, want non-empty string", tokenJSONPath, v)
+	}
+	c.SetBearerToken(token)
+	return token, nil
+}
+
+// Get sends a GET request.
+func (c *HTTPTestClient) Get(ctx context.Context, path string, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodGet, path, nil, opts...)
+}
+
+// Post sends a POST request; body is encoded as for Do.
+func (c *HTTPTestClient) Post(ctx context.Context, path string, body any, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodPost, path, body, opts...)
+}
+
+// Put sends a PUT request; body is encoded as for Do.
+func (c *HTTPTestClient) Put(ctx context.Context, path string, body any, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodPut, path, body, opts...)
+}
+
+// Delete sends a DELETE request.
+func (c *HTTPTestClient) Delete(ctx context.Context, path string, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodDelete, path, nil, opts...)
+}
+
+// Do sends a request to path relative to the base URL. body may be nil,
+// []byte, string, io.Reader or any value to encode as JSON. If the request
+// carried the client's own token, was answered 401 and a TokenRefresher is
+// set, the token is refreshed and the request retried once. Other retries
+// follow WithRetry. With EnforceErrorEnvelope, an error response whose body
+// breaks the contract is returned along with an *EnvelopeViolation.
+func (c *HTTPTestClient) Do(ctx context.Context, method, path string, body any, opts ...RequestOption) (*http.Response, error) {
+	if c.tracer != nil {
+		return c.tracedDo(ctx, method, path, func(ctx context.Context) (*http.Response, error) {
+			return c.do(ctx, method, path, body, opts)
+		})
+	}
+	return c.do(ctx, method, path, body, opts)
+}
+
+func (c *HTTPTestClient) do(ctx context.Context, method, path string, body any, opts []RequestOption) (*http.Response, error) {
+	payload, contentType, err := encodeTestClientBody(body)
+	if err != nil {
+		return nil, err
+	}
+	opts, retryable := c.retryPlan(method, opts)
+
+	resp, usedAuth, err := c.sendWithRetry(ctx, method, path, payload, contentType, opts, retryable)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || c.refresh == nil || usedAuth == "" ||
+		ctx.Value(refreshingKey{}) != nil {
+		return c.checkEnvelope(resp, err, opts)
+	}
+
+	if err := c.refreshToken(ctx, usedAuth); err != nil {
+		// Keep the 401 so the caller can assert on it, but surface why the
+		// refresh did not help.
+		return resp, fmt.Errorf("token refresh: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	resp, _, err = c.sendWithRetry(ctx, method, path, payload, contentType, opts, retryable)
+	return c.checkEnvelope(resp, err, opts)
+}
+
+// checkEnvelope applies the ErrorEnvelopeContract to a response that
+// arrived.
+func (c *HTTPTestClient) checkEnvelope(resp *http.Response, err error, opts []RequestOption) (*http.Response, error) {
+	if err != nil || c.envelope == nil {
+		return resp, err
+	}
+	return resp, c.envelope.check(resp, opts)
+}
+
+// send performs one attempt. It returns the client Authorization value used,
+// or "" if the request carried none or overrode it.
+func (c *HTTPTestClient) send(ctx context.Context, method, path string, payload []byte, contentType string, opts []RequestOption) (*http.Response, string, error) {
+	req, auth, err := c.newRequest(ctx, method, path, payload, contentType, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	return resp, auth, nil
+}
+
+// newRequest builds a request carrying the client's default headers and
+// credentials. Like send, it reports the client Authorization value used.
+func (c *HTTPTestClient) newRequest(ctx context.Context, method, path string, payload []byte, contentType string, opts []RequestOption) (*http.Request, string, error) {
+	var rd io.Reader
+	if payload != nil {
+		rd = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), rd)
+	if err != nil {
+		return nil, "", fmt.Errorf("build request: %w", err)
+	}
+	for k, v := range c.headers {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if span := spanFromContext(ctx); c.tracer != nil && span != nil {
+		InjectTraceparent(req.Header, span.Context)
+	}
+	auth := c.currentAuth()
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	for _, opt := range opts {
+		opt.applyRequest(req)
+	}
+	if req.Header.Get("Authorization") != auth {
+		auth = ""
+	}
+	return req, auth, nil
+}
+
+// refreshToken runs the refresher unless another goroutine already replaced
+// the credentials that were rejected or is refreshing them, in which case it
+// waits for that refresh. refreshMu is not held while the refresher runs, so
+// the refresher may send requests through c.
+func (c *HTTPTestClient) refreshToken(ctx context.Context, rejected string) error {
+	c.refreshMu.Lock()
+	if c.currentAuth() != rejected {
+		c.refreshMu.Unlock()
+		return nil
+	}
+	if call := c.refreshing; call != nil {
+		c.refreshMu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &tokenRefresh{done: make(chan struct{})}
+	c.refreshing = call
+	c.refreshMu.Unlock()
+
+	token, err := c.refresh(context.WithValue(ctx, refreshingKey{}, true), c)
+	if err == nil && token == "" {
+		err = fmt.Errorf("refresher returned an empty token")
+	}
+	c.refreshMu.Lock()
+	if err == nil {
+		c.SetBearerToken(token)
+	}
+	c.refreshing = nil
+	c.refreshMu.Unlock()
+	call.err = err
+	close(call.done)
+	return err
+}
+
+func (c *HTTPTestClient) url(path string) string {
+	if c.baseURL == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.baseURL + "/" + strings.TrimLeft(path, "/")
+}
+
+// encodeTestClientBody buffers body so it can be resent after a refresh.
+func encodeTestClientBody(body any) ([]byte, string, error) {
+	switch v := body.(type) {
+	case nil:
+		return nil, "", nil
+	case []byte:
+		return v, "", nil
+	case string:
+		return []byte(v), "", nil
+	case io.Reader:
+		data, err := io.ReadAll(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("read request body: %w", err)
+		}
+		return data, "", nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", fmt.Errorf("marshal request body: %w", err)
+		}
+		return data, "application/json", nil
+	}
+}
+
+// lookupJSONPath walks a decoded JSON document along a dotted path.
+func lookupJSONPath(doc any, path string) (any, bool) {
+	cur := doc
+	for _, seg := range strings.Split(strings.TrimPrefix(path, "$."), ".") {
+		if seg == "" {
+			continue
+		}
+		switch node := cur.(type) {
+		case map[string]any:
+			next, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []any:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			cur = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
@@ -0,0 +1,173 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------------------
+// HTTPTestClient error envelope contract
+// ------------------------------------------------------------------------
+
+// ErrorEnvelopeSchema is the error body the API promises on every error
+// response: {"error": ..., "message": ..., "request_id": ...}.
+var ErrorEnvelopeSchema = MustParseContractSchema(`{
+	"type": "object",
+	"required": ["error", "message", "request_id"],
+	"properties": {
+		"error":      {"type": "string"},
+		"message":    {"type": "string"},
+		"request_id": {"type": "string"}
+	}
+}`)
+
+// envelopeBodyLimit caps the body quoted in an EnvelopeViolation.
+const envelopeBodyLimit = 2048
+
+// ErrorEnvelopeContract checks the body of every 4xx and 5xx response a
+// client receives against a schema. Pass it to NewHTTPTestClient; one
+// contract may be shared by the clients of a suite to collect a single
+// Report. Redirects and HEAD responses carry no body and are not checked.
+type ErrorEnvelopeContract struct {
+	schema *ContractSchema
+
+	mu         sync.Mutex
+	checked    int
+	skipped    int
+	violations []EnvelopeViolation
+}
+
+// EnforceErrorEnvelope returns a contract checking error bodies against
+// schema, or ErrorEnvelopeSchema if schema is nil. A response that breaks
+// it is still returned, together with an *EnvelopeViolation error quoting
+// the body, so the test fails where it made the request. Use
+// SkipErrorEnvelope for requests whose errors are legitimately not JSON.
+func EnforceErrorEnvelope(schema *ContractSchema) *ErrorEnvelopeContract {
+	if schema == nil {
+		schema = ErrorEnvelopeSchema
+	}
+	return &ErrorEnvelopeContract{schema: schema}
+}
+
+func (e *ErrorEnvelopeContract) applyClient(c *HTTPTestClient) { c.envelope = e }
+
+type skipEnvelopeOption struct{}
+
+func (skipEnvelopeOption) applyRequest(*http.Request) {}
+
+// SkipErrorEnvelope exempts one request from the client's
+// ErrorEnvelopeContract, e.g. a body over the size limit that the server
+// rejects with a plain-text 413.
+func SkipErrorEnvelope() RequestOption { return skipEnvelopeOption{} }
+
+// EnvelopeViolation is an error response whose body broke the contract.
+type EnvelopeViolation struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"` // without the query
+	Status     int      `json:"status"`
+	Violations []string `json:"violations"`
+	Body       string   `json:"body"` // truncated to 2 KiB
+}
+
+func (v *EnvelopeViolation) Error() string {
+	return fmt.Sprintf("%s %s: %d response violates the error envelope: %s; body: %s",
+		v.Method, v.Path, v.Status, strings.Join(v.Violations, "; "), v.Body)
+}
+
+// check validates resp unless it is exempt, restoring its body for the
+// caller. It returns the violation, if any.
+func (e *ErrorEnvelopeContract) check(resp *http.Response, opts []RequestOption) error {
+	if resp.StatusCode < 400 || resp.Request == nil || resp.Request.Method == http.MethodHead {
+		return nil
+	}
+	for _, opt := range opts {
+		if _, ok := opt.(skipEnvelopeOption); ok {
+			e.mu.Lock()
+			e.skipped++
+			e.mu.Unlock()
+			return nil
+		}
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("read error body for envelope check: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.checked++
+	violations := e.schema.ValidateJSON(data)
+	if len(violations) == 0 {
+		return nil
+	}
+	body := string(data)
+	if len(body) > envelopeBodyLimit {
+		body = body[:envelopeBodyLimit] + "..."
+	}
+	v := EnvelopeViolation{
+		Method:     resp.Request.Method,
+		Path:       resp.Request.URL.Path,
+		Status:     resp.StatusCode,
+		Violations: violations,
+		Body:       body,
+	}
+	e.violations = append(e.violations, v)
+	return &v
+}
+
+// EnvelopeReport summarises the error responses a contract has seen.
+type EnvelopeReport struct {
+	Checked    int                 `json:"checked"`
+	Skipped    int                 `json:"skipped"` // exempted with SkipErrorEnvelope
+	Violations []EnvelopeViolation `json:"violations,omitempty"`
+}
+
+// Report returns what the contract has seen so far.
+func (e *ErrorEnvelopeContract) Report() EnvelopeReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return EnvelopeReport{
+		Checked:    e.checked,
+		Skipped:    e.skipped,
+		Violations: append([]EnvelopeViolation(nil), e.violations...),
+	}
+}
+
+// Endpoints returns the "METHOD /path" endpoints that violated the
+// contract, sorted, each once.
+func (r EnvelopeReport) Endpoints() []string {
+	seen := make(map[string]bool)
+	var endpoints []string
+	for _, v := range r.Violations {
+		ep := v.Method + " " + v.Path
+		if !seen[ep] {
+			seen[ep] = true
+			endpoints = append(endpoints, ep)
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
+
+// String lists the violating endpoints with their statuses, one per line.
+func (r EnvelopeReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "error envelope: %d checked, %d skipped, %d violation(s)", r.Checked, r.Skipped, len(r.Violations))
+	statuses := make(map[string][]int)
+	for _, v := range r.Violations {
+		ep := v.Method + " " + v.Path
+		statuses[ep] = append(statuses[ep], v.Status)
+	}
+	for _, ep := range r.Endpoints() {
+		fmt.Fprintf(&b, "\n  %s: %v", ep, statuses[ep])
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPTestClient_EnforceErrorEnvelope(t *testing.T) {
+	ms := NewMockServerT(t)
+	ms.On(http.MethodGet, "/orders/1").Respond(JSONResp(http.StatusNotFound, map[string]any{
+		"error": "not_found", "message": "order 1 not found", "request_id": "req-1",
+	}))
+	ms.On(http.MethodPost, "/orders").Respond(JSONResp(http.StatusBadRequest, map[string]any{
+		"error": "invalid", "message": "quantity must be positive",
+	}))
+	ms.On(http.MethodGet, "/health").Respond(TextResp(http.StatusServiceUnavailable, "down"))
+	ms.On(http.MethodPost, "/upload").Respond(TextResp(http.StatusRequestEntityTooLarge, "too large"))
+	ms.On(http.MethodGet, "/orders").Respond(JSONResp(http.StatusOK, []any{}))
+
+	envelope := EnforceErrorEnvelope(nil)
+	c := NewHTTPTestClient(ms.URL, envelope)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "/orders/1"); err != nil {
+		t.Errorf("a conforming 404 must pass: %v", err)
+	}
+	if _, err := c.Get(ctx, "/orders"); err != nil {
+		t.Errorf("2xx responses are not checked: %v", err)
+	}
+
+	resp, err := c.Post(ctx, "/orders", map[string]int{"quantity": 0})
+	var violation *EnvelopeViolation
+	if !errors.As(err, &violation) || violation.Status != http.StatusBadRequest ||
+		!reflect.DeepEqual(violation.Violations, []string{`$: missing required property "request_id"`}) {
+		t.Fatalf("expected a request_id violation, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `body: {"error":"invalid"`) {
+		t.Errorf("the violation must quote the body: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "quantity must be positive") {
+		t.Errorf("the body must still be readable, got %q", body)
+	}
+
+	if _, err := c.Get(ctx, "/health?verbose=1"); !errors.As(err, &violation) ||
+		!strings.Contains(violation.Violations[0], "invalid JSON") || violation.Path != "/health" {
+		t.Errorf("expected a non-JSON violation, got %v", err)
+	}
+	if _, err := c.Post(ctx, "/upload", "big", SkipErrorEnvelope()); err != nil {
+		t.Errorf("SkipErrorEnvelope must exempt the request: %v", err)
+	}
+
+	// A second client reporting to the same contract.
+	other := NewHTTPTestClient(ms.URL, envelope)
+	other.Get(ctx, "/health")
+
+	report := envelope.Report()
+	if report.Checked != 4 || report.Skipped != 1 || len(report.Violations) != 3 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if got := report.Endpoints(); !reflect.DeepEqual(got, []string{"GET /health", "POST /orders"}) {
+		t.Errorf("endpoints %v", got)
+	}
+	if s := report.String(); !strings.Contains(s, "GET /health: [503 503]") {
+		t.Errorf("unexpected summary:\n%s", s)
+	}
+}
@@ -0,0 +1,200 @@
+package testutils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// HTTPTestClient retries
+// ------------------------------------------------------------------------
+
+// IdempotencyKeyHeader carries the key that lets a server deduplicate
+// retried POST and PATCH requests.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// RetryAttempt records one attempt of a request sent with WithRetry.
+type RetryAttempt struct {
+	Attempt    int           // 1-based
+	Status     int           // 0 if the request failed in transport
+	Err        error         // transport error, if any
+	RetryAfter string        // the response's Retry-After header, verbatim
+	Delay      time.Duration // wait before the next attempt; 0 on the last
+}
+
+type retryHistoryKey struct{}
+
+// WithRetry makes the client retry requests that fail in transport or are
+// answered with one of cfg.RetryableCodes, up to cfg.Attempts attempts in
+// total. The wait is cfg.Backoff, or the response's Retry-After when it has
+// one, capped at cfg.MaxDelay either way.
+//
+// Only idempotent requests are retried: GET, HEAD, OPTIONS, TRACE, PUT and
+// DELETE always, POST and PATCH only when marked with Idempotent or sent
+// with an Idempotency-Key.
+func WithRetry(cfg RetryConfig) HTTPTestClientOption {
+	return clientOptionFunc(func(c *HTTPTestClient) { c.retry = &cfg })
+}
+
+type idempotentOption struct {
+	autoKey bool
+}
+
+func (idempotentOption) applyRequest(*http.Request) {}
+
+// Idempotent marks a POST or PATCH request as safe to retry.
+func Idempotent() RequestOption { return idempotentOption{} }
+
+// WithIdempotencyKey sends key as the Idempotency-Key header, which also
+// makes a POST or PATCH request retryable.
+func WithIdempotencyKey(key string) RequestOption {
+	return RequestHeader{Key: IdempotencyKeyHeader, Value: key}
+}
+
+// AutoIdempotencyKey sends the request's X-Request-ID as its
+// Idempotency-Key, generating a request ID if the request has none. Every
+// attempt carries the same key.
+func AutoIdempotencyKey() RequestOption { return idempotentOption{autoKey: true} }
+
+// RetryHistoryOf returns the attempts behind a response from a client
+// configured with WithRetry, the final one last. It returns nil for other
+// responses.
+func RetryHistoryOf(resp *http.Response) []RetryAttempt {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	history, _ := resp.Request.Context().Value(retryHistoryKey{}).([]RetryAttempt)
+	return history
+}
+
+// retryPlan resolves the idempotency options for one request. It returns
+// opts with any generated headers added and whether the request may be
+// retried.
+func (c *HTTPTestClient) retryPlan(method string, opts []RequestOption) ([]RequestOption, bool) {
+	probe := &http.Request{Method: method, Header: c.headers.Clone()}
+	marked, autoKey := false, false
+	for _, opt := range opts {
+		opt.applyRequest(probe)
+		if o, ok := opt.(idempotentOption); ok {
+			marked = true
+			autoKey = autoKey || o.autoKey
+		}
+	}
+	if autoKey && probe.Header.Get(IdempotencyKeyHeader) == "" {
+		id := probe.Header.Get("X-Request-ID")
+		if id == "" {
+			id = newClientRequestID()
+			opts = append(opts, RequestHeader{Key: "X-Request-ID", Value: id})
+		}
+		opts = append(opts, RequestHeader{Key: IdempotencyKeyHeader, Value: id})
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return opts, true
+	}
+	return opts, marked || probe.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// sendWithRetry is send repeated as WithRetry allows. The returned response
+// carries the attempt history for RetryHistoryOf.
+func (c *HTTPTestClient) sendWithRetry(ctx context.Context, method, path string, payload []byte, contentType string, opts []RequestOption, retryable bool) (*http.Response, string, error) {
+	if c.retry == nil {
+		return c.send(ctx, method, path, payload, contentType, opts)
+	}
+	attempts := c.retry.Attempts
+	if !retryable || attempts < 1 {
+		attempts = 1
+	}
+
+	var history []RetryAttempt
+	for attempt := 1; ; attempt++ {
+		resp, auth, err := c.send(ctx, method, path, payload, contentType, opts)
+		rec := RetryAttempt{Attempt: attempt, Err: err}
+		if resp != nil {
+			rec.Status = resp.StatusCode
+			rec.RetryAfter = resp.Header.Get("Retry-After")
+		}
+		again := attempt < attempts && ctx.Err() == nil &&
+			(err != nil || c.retry.RetryableStatus(resp.StatusCode))
+		if !again {
+			history = append(history, rec)
+			if err != nil {
+				if attempt > 1 {
+					err = fmt.Errorf("%w (after %d attempts)", err, attempt)
+				}
+				return nil, "", err
+			}
+			resp.Request = resp.Request.WithContext(context.WithValue(resp.Request.Context(), retryHistoryKey{}, history))
+			return resp, auth, nil
+		}
+
+		rec.Delay = c.retryDelay(attempt, resp)
+		history = append(history, rec)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(rec.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, "", fmt.Errorf("%s %s: retry after attempt %d: %w", method, path, attempt, ctx.Err())
+		}
+	}
+}
+
+// retryDelay returns the wait after a failed attempt.
+func (c *HTTPTestClient) retryDelay(attempt int, resp *http.Response) time.Duration {
+	d := c.retry.Backoff(attempt)
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			d = after
+		}
+	}
+	if c.retry.MaxDelay > 0 && d > c.retry.MaxDelay {
+		d = c.retry.MaxDelay
+	}
+	return d
+}
+
+// parseRetryAfter reads a Retry-After value in either of its RFC 9110
+// forms, delay-seconds or HTTP-date. Dates in the past mean no delay.
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > int64(time.Duration(1<<63-1)/time.Second) {
+			return time.Duration(1<<63 - 1), true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// newClientRequestID returns a random ID for requests that carry none.
+func newClientRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
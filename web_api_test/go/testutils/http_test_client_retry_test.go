@@ -0,0 +1,171 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRetryConfig() RetryConfig {
+	return RetryConfig{
+		Attempts:       3,
+		InitialDelay:   time.Millisecond,
+		MaxDelay:       20 * time.Millisecond,
+		Multiplier:     1,
+		RetryableCodes: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable},
+	}
+}
+
+func TestHTTPTestClient_RetryHonorsRetryAfter(t *testing.T) {
+	ms := NewMockServerT(t)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	ms.On(http.MethodGet, "/quota").RespondSequence(
+		Resp(http.StatusTooManyRequests).WithHeader("Retry-After", "0"),
+		Resp(http.StatusServiceUnavailable).WithHeader("Retry-After", past),
+		Resp(http.StatusOK),
+	)
+	c := NewHTTPTestClient(ms.URL, WithRetry(testRetryConfig()))
+
+	resp, err := c.Get(context.Background(), "/quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	history := RetryHistoryOf(resp)
+	if len(history) != 3 {
+		t.Fatalf("history = %+v", history)
+	}
+	for i, want := range []RetryAttempt{
+		{Attempt: 1, Status: 429, RetryAfter: "0"},
+		{Attempt: 2, Status: 503, RetryAfter: past},
+		{Attempt: 3, Status: 200},
+	} {
+		if history[i] != want {
+			t.Errorf("attempt %d = %+v, want %+v", i+1, history[i], want)
+		}
+	}
+}
+
+func TestHTTPTestClient_RetryAfterCappedByMaxDelay(t *testing.T) {
+	for name, retryAfter := range map[string]string{
+		"seconds":   "120",
+		"http-date": time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+	} {
+		t.Run(name, func(t *testing.T) {
+			ms := NewMockServerT(t)
+			ms.On(http.MethodGet, "/slow").RespondSequence(
+				Resp(http.StatusTooManyRequests).WithHeader("Retry-After", retryAfter),
+				Resp(http.StatusOK),
+			)
+			c := NewHTTPTestClient(ms.URL, WithRetry(testRetryConfig()))
+
+			start := time.Now()
+			resp, err := c.Get(context.Background(), "/slow")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if d := RetryHistoryOf(resp)[0].Delay; d != 20*time.Millisecond {
+				t.Errorf("delay = %v, want MaxDelay", d)
+			}
+			if time.Since(start) > time.Second {
+				t.Error("client waited for the uncapped Retry-After")
+			}
+		})
+	}
+}
+
+func TestHTTPTestClient_RetryOnlyIdempotentRequests(t *testing.T) {
+	ms := NewMockServerT(t)
+	ctx := context.Background()
+	c := NewHTTPTestClient(ms.URL, WithRetry(testRetryConfig()))
+	script := func(path string) *MockRoute {
+		return ms.On(http.MethodPost, path).RespondSequence(Resp(http.StatusServiceUnavailable), Resp(http.StatusCreated))
+	}
+
+	plain := script("/plain")
+	resp, err := c.Post(ctx, "/plain", map[string]int{"n": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusServiceUnavailable || plain.Calls() != 1 {
+		t.Errorf("plain POST: status %d after %d calls, want no retry", resp.StatusCode, plain.Calls())
+	}
+
+	for path, opt := range map[string]RequestOption{
+		"/marked": Idempotent(),
+		"/keyed":  WithIdempotencyKey("order-42"),
+		"/auto":   AutoIdempotencyKey(),
+	} {
+		script(path)
+		resp, err := c.Post(ctx, path, map[string]int{"n": 1}, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusCreated || len(RetryHistoryOf(resp)) != 2 {
+			t.Errorf("%s: status %d, history %+v", path, resp.StatusCode, RetryHistoryOf(resp))
+		}
+	}
+
+	reqs := ms.RequestsFor(http.MethodPost, "/auto")
+	key := reqs[0].Headers.Get(IdempotencyKeyHeader)
+	if key == "" || key != reqs[0].Headers.Get("X-Request-ID") || reqs[1].Headers.Get(IdempotencyKeyHeader) != key {
+		t.Errorf("auto keys: %q/%q then %q", key, reqs[0].Headers.Get("X-Request-ID"), reqs[1].Headers.Get(IdempotencyKeyHeader))
+	}
+	if got := ms.RequestsFor(http.MethodPost, "/keyed")[1].Headers.Get(IdempotencyKeyHeader); got != "order-42" {
+		t.Errorf("explicit key on retry = %q", got)
+	}
+}
+
+func TestHTTPTestClient_RetryExhausted(t *testing.T) {
+	ms := NewMockServerT(t)
+	route := ms.On(http.MethodDelete, "/flaky").Respond(Resp(http.StatusServiceUnavailable))
+	c := NewHTTPTestClient(ms.URL, WithRetry(testRetryConfig()))
+
+	resp, err := c.Delete(context.Background(), "/flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	history := RetryHistoryOf(resp)
+	if resp.StatusCode != http.StatusServiceUnavailable || route.Calls() != 3 || len(history) != 3 {
+		t.Fatalf("status %d, %d calls, history %+v", resp.StatusCode, route.Calls(), history)
+	}
+	if history[0].Delay != time.Millisecond || history[2].Delay != 0 {
+		t.Errorf("delays: %+v", history)
+	}
+
+	ms.On(http.MethodGet, "/gone").Respond(DropResp())
+	_, err = c.Get(context.Background(), "/gone")
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Errorf("transport failure: %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"7", 7 * time.Second, true},
+		{" 0 ", 0, true},
+		{"Wed, 01 May 2024 12:00:30 GMT", 30 * time.Second, true},
+		{"Wednesday, 01-May-24 12:01:00 GMT", time.Minute, true}, // RFC 850
+		{"Wed, 01 May 2024 11:00:00 GMT", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{"", 0, false},
+	} {
+		got, ok := parseRetryAfter(tc.in, now)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
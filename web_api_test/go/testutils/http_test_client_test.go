@@ -0,0 +1,213 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPTestClient_AuthPropagation(t *testing.T) {
+	ms := NewMockServerT(t)
+	ms.On(http.MethodGet, "/me").Respond(Resp(http.StatusOK))
+	ctx := context.Background()
+
+	c := NewHTTPTestClient(ms.URL, WithBearerToken("client-token"), WithDefaultHeader("X-Suite", "auth"))
+	if _, err := c.Get(ctx, "/me"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "/me", WithBasicAuth("ada", "secret")); err != nil {
+		t.Fatal(err)
+	}
+	c.ClearAuth()
+	if _, err := c.Get(ctx, "/me"); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := ms.RequestsFor(http.MethodGet, "/me")
+	if len(reqs) != 3 {
+		t.Fatalf("got %d requests, want 3", len(reqs))
+	}
+	want := []string{"Bearer client-token", "Basic YWRhOnNlY3JldA==", ""}
+	for i, r := range reqs {
+		if got := r.Headers.Get("Authorization"); got != want[i] {
+			t.Errorf("request %d: Authorization %q, want %q", i, got, want[i])
+		}
+		if got := r.Headers.Get("X-Suite"); got != "auth" {
+			t.Errorf("request %d: X-Suite %q", i, got)
+		}
+	}
+}
+
+func TestHTTPTestClient_LoginJSON(t *testing.T) {
+	ms := NewMockServerT(t)
+	ms.On(http.MethodPost, "/login").Respond(JSONResp(http.StatusOK, map[string]any{
+		"data": map[string]any{"tokens": []any{map[string]any{"access": "tok-1"}}},
+	}))
+	ms.On(http.MethodGet, "/orders").Respond(Resp(http.StatusOK))
+
+	c := NewHTTPTestClient(ms.URL)
+	token, err := c.LoginJSON(context.Background(), "/login",
+		map[string]string{"user": "ada", "pass": "secret"}, "data.tokens.0.access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "tok-1" || c.Token() != "tok-1" {
+		t.Fatalf("token %q, stored %q", token, c.Token())
+	}
+	login := ms.RequestsFor(http.MethodPost, "/login")[0]
+	if !strings.Contains(string(login.Body), `"user":"ada"`) || login.Headers.Get("Content-Type") != "application/json" {
+		t.Errorf("login request %s %v", login.Body, login.Headers)
+	}
+
+	if _, err := c.Get(context.Background(), "/orders"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ms.RequestsFor(http.MethodGet, "/orders")[0].Headers.Get("Authorization"); got != "Bearer tok-1" {
+		t.Errorf("Authorization %q after login", got)
+	}
+
+	if _, err := c.LoginJSON(context.Background(), "/login", nil, "data.missing"); err == nil {
+		t.Error("expected an error for a missing token path")
+	}
+}
+
+func TestHTTPTestClient_RefreshOn401(t *testing.T) {
+	ms := NewMockServerT(t)
+	ms.On(http.MethodPost, "/orders").RespondSequence(Resp(http.StatusUnauthorized), Resp(http.StatusCreated))
+
+	var refreshes atomic.Int32
+	c := NewHTTPTestClient(ms.URL,
+		WithBearerToken("stale"),
+		WithTokenRefresh(func(ctx context.Context, c *HTTPTestClient) (string, error) {
+			refreshes.Add(1)
+			return "fresh", nil
+		}))
+
+	resp, err := c.Post(context.Background(), "/orders", map[string]int{"qty": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status %d, want 201 after refresh", resp.StatusCode)
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("refreshed %d times, want 1", refreshes.Load())
+	}
+
+	reqs := ms.RequestsFor(http.MethodPost, "/orders")
+	if len(reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(reqs))
+	}
+	if reqs[0].Headers.Get("Authorization") != "Bearer stale" || reqs[1].Headers.Get("Authorization") != "Bearer fresh" {
+		t.Errorf("Authorization headers %q, %q", reqs[0].Headers.Get("Authorization"), reqs[1].Headers.Get("Authorization"))
+	}
+	if string(reqs[1].Body) != `{"qty":2}` {
+		t.Errorf("retried body %q", reqs[1].Body)
+	}
+
+	// A per-request override is not the client's token, so no refresh.
+	ms.On(http.MethodGet, "/admin").Respond(Resp(http.StatusUnauthorized))
+	resp, err = c.Get(context.Background(), "/admin", WithBasicAuth("root", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || refreshes.Load() != 1 {
+		t.Errorf("status %d, refreshes %d", resp.StatusCode, refreshes.Load())
+	}
+}
+
+func TestHTTPTestClient_RefresherUsesClient(t *testing.T) {
+	// The login endpoint rejects the stale bearer too, as many servers do.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch {
+		case r.URL.Path == "/login" && auth != "Bearer stale":
+			w.Write([]byte(`{"token":"fresh"}`))
+		case r.URL.Path == "/orders" && auth == "Bearer fresh":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	var refreshes, loginFailures atomic.Int32
+	c := NewHTTPTestClient(srv.URL,
+		WithBearerToken("stale"),
+		WithTokenRefresh(func(ctx context.Context, c *HTTPTestClient) (string, error) {
+			refreshes.Add(1)
+			if _, err := c.LoginJSON(ctx, "/login", nil, "token"); err != nil {
+				loginFailures.Add(1)
+				c.SetBasicAuth("ada", "pw")
+			}
+			return c.LoginJSON(ctx, "/login", nil, "token")
+		}))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := c.Get(context.Background(), "/orders")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %d, want 200 after refresh", resp.StatusCode)
+			}
+		}()
+	}
+	go func() { wg.Wait(); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("requests deadlocked on a refresher that uses the client")
+	}
+	if refreshes.Load() != 1 || loginFailures.Load() != 1 {
+		t.Errorf("%d refreshes, %d failed logins; want one of each and no nested refresh", refreshes.Load(), loginFailures.Load())
+	}
+}
+
+func TestHTTPTestClient_CookieJar(t *testing.T) {
+	ms := NewMockServerT(t)
+	login := Resp(http.StatusOK)
+	login.Headers.Set("Set-Cookie", "session=abc; Path=/")
+
+	for _, withJar := range []bool{false, true} {
+		ms.Reset()
+		ms.On(http.MethodPost, "/session").Respond(login)
+		ms.On(http.MethodGet, "/me").Respond(Resp(http.StatusOK))
+
+		var opts []HTTPTestClientOption
+		if withJar {
+			opts = append(opts, WithCookieJar())
+		}
+		c := NewHTTPTestClient(ms.URL, opts...)
+		if _, err := c.Post(context.Background(), "/session", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Get(context.Background(), "/me"); err != nil {
+			t.Fatal(err)
+		}
+		got := ms.RequestsFor(http.MethodGet, "/me")[0].Headers.Get("Cookie")
+		if withJar && got != "session=abc" {
+			t.Errorf("with jar: Cookie %q", got)
+		}
+		if !withJar && got != "" {
+			t.Errorf("without jar: Cookie %q", got)
+		}
+		if withJar && len(c.Cookies("/me")) != 1 {
+			t.Errorf("jar holds %v", c.Cookies("/me"))
+		}
+	}
+}
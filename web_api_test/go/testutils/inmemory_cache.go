@@ -0,0 +1,416 @@
+package testutils
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ----------------------------------------------------------------------------
+// InMemoryCache – a controllable cache Component
+// ----------------------------------------------------------------------------
+
+// InMemoryCache is a string-keyed cache with per-entry TTLs read from a
+// Clock and least-recently-used eviction once MaxEntries is reached. It
+// implements Component so it can stand in for a cache service, and its
+// counters let tests assert exactly how code used it. A new cache is
+// ready to use; Stop drops every entry and refuses operations until Start.
+//
+// Expired entries are removed when Get meets them or on a sweep; nothing
+// runs in the background, so with a FakeClock expiry happens only when
+// the test advances time.
+type InMemoryCache struct {
+	mu         sync.Mutex
+	name       string
+	clock      Clock
+	maxEntries int           // 0 means unbounded
+	defaultTTL time.Duration // 0 means entries never expire
+	entries    map[string]*list.Element
+	lru        *list.List // front is most recently used
+	stopped    bool
+
+	hits, misses, evictions, expirations int64
+}
+
+type cacheEntry struct {
+	key       string
+	value     any
+	expiresAt time.Time // zero never expires
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// InMemoryCacheOption configures an InMemoryCache.
+type InMemoryCacheOption func(*InMemoryCache)
+
+// WithCacheClock sets the clock TTLs are measured with. Pass a FakeClock
+// to use AdvanceAndSweep.
+func WithCacheClock(clock Clock) InMemoryCacheOption {
+	return func(c *InMemoryCache) {
+		if clock != nil {
+			c.clock = clock
+		}
+	}
+}
+
+// WithCacheMaxEntries bounds the cache to n entries; setting one more
+// evicts the least recently used.
+func WithCacheMaxEntries(n int) InMemoryCacheOption {
+	return func(c *InMemoryCache) {
+		c.maxEntries = n
+	}
+}
+
+// WithCacheDefaultTTL sets the TTL used by Set when it is given none.
+func WithCacheDefaultTTL(ttl time.Duration) InMemoryCacheOption {
+	return func(c *InMemoryCache) {
+		c.defaultTTL = ttl
+	}
+}
+
+// NewInMemoryCache creates an empty cache registered under name.
+func NewInMemoryCache(name string, opts ...InMemoryCacheOption) *InMemoryCache {
+	c := &InMemoryCache{
+		name:    name,
+		clock:   RealClock{},
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// errCacheStopped is returned by operations on a stopped cache.
+var errCacheStopped = withKind(ErrUnavailable, errors.New("in-memory cache: stopped"))
+
+// Get returns the value stored under key and marks it recently used. A
+// missing or expired key returns ErrNotFound and counts as a miss.
+func (c *InMemoryCache) Get(key string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return nil, errCacheStopped
+	}
+	el, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, ErrNotFound
+	}
+	e := el.Value.(*cacheEntry)
+	if e.expired(c.clock.Now()) {
+		c.removeLocked(el)
+		c.expirations++
+		c.misses++
+		return nil, ErrNotFound
+	}
+	c.lru.MoveToFront(el)
+	c.hits++
+	return e.value, nil
+}
+
+// Set stores value under key for ttl, or for the default TTL if ttl is 0
+// or less, and marks it recently used. If that makes the cache exceed
+// MaxEntries the least recently used entry is evicted.
+func (c *InMemoryCache) Set(key string, value any, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return errCacheStopped
+	}
+	if ttl <= 0 {
+		ttl = c.defaultTTL
+	}
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = c.clock.Now().Add(ttl)
+	}
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*cacheEntry)
+		e.value, e.expiresAt = value, expiresAt
+		c.lru.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, value: value, expiresAt: expiresAt})
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		// An expired entry pushed out counts as expired, not evicted.
+		oldest := c.lru.Back()
+		if oldest.Value.(*cacheEntry).expired(c.clock.Now()) {
+			c.expirations++
+		} else {
+			c.evictions++
+		}
+		c.removeLocked(oldest)
+	}
+	return nil
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (c *InMemoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return errCacheStopped
+	}
+	if el, ok := c.entries[key]; ok {
+		c.removeLocked(el)
+	}
+	return nil
+}
+
+// Len returns the number of entries held, expired ones not yet removed
+// included.
+func (c *InMemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Keys returns the keys held from most to least recently used.
+func (c *InMemoryCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*cacheEntry).key)
+	}
+	return keys
+}
+
+func (c *InMemoryCache) removeLocked(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// ----------------------------------------------------------------------------
+// Test hooks
+// ----------------------------------------------------------------------------
+
+// ExpireNow makes key expire at the current time, as if its TTL had run
+// out: the next Get misses and a sweep removes it. It reports whether the
+// key was present.
+func (c *InMemoryCache) ExpireNow(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok {
+		el.Value.(*cacheEntry).expiresAt = c.clock.Now()
+	}
+	return ok
+}
+
+// AdvanceAndSweep advances the cache's clock by d, then removes every
+// expired entry and returns how many it removed. It panics unless the
+// clock can be advanced, like a FakeClock.
+func (c *InMemoryCache) AdvanceAndSweep(d time.Duration) int {
+	c.mu.Lock()
+	clock := c.clock
+	c.mu.Unlock()
+	fc, ok := clock.(interface{ Advance(time.Duration) })
+	if !ok {
+		panic("InMemoryCache.AdvanceAndSweep: clock cannot be advanced; use WithCacheClock(NewFakeClock(...))")
+	}
+	fc.Advance(d)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock.Now()
+	removed := 0
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if el.Value.(*cacheEntry).expired(now) {
+			c.removeLocked(el)
+			c.expirations++
+			removed++
+		}
+		el = prev
+	}
+	return removed
+}
+
+// ----------------------------------------------------------------------------
+// Component
+// ----------------------------------------------------------------------------
+
+// Name returns the name given to NewInMemoryCache.
+func (c *InMemoryCache) Name() string { return c.name }
+
+// Start makes a stopped cache usable again, empty.
+func (c *InMemoryCache) Start() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = false
+	return nil
+}
+
+// Stop drops every entry and makes operations fail until Start. The
+// counters are kept.
+func (c *InMemoryCache) Stop() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	return nil
+}
+
+// Status reports "running" or "stopped".
+func (c *InMemoryCache) Status() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return "stopped", nil
+	}
+	return "running", nil
+}
+
+// Health reports whether the cache is running.
+func (c *InMemoryCache) Health() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.stopped, nil
+}
+
+// Stats returns the hits, misses, evictions (LRU only), expirations,
+// entries and max_entries counters, all int64.
+func (c *InMemoryCache) Stats() (map[string]interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]interface{}{
+		"hits":        c.hits,
+		"misses":      c.misses,
+		"evictions":   c.evictions,
+		"expirations": c.expirations,
+		"entries":     int64(c.lru.Len()),
+		"max_entries": int64(c.maxEntries),
+	}, nil
+}
+
+// ----------------------------------------------------------------------------
+// ModeAwareCache – wraps an InMemoryCache and enforces mode semantics.
+// ----------------------------------------------------------------------------
+
+// ModeAwareCache makes an InMemoryCache follow a ModeManager, so a test
+// can take the cache away from the code under test: ModeOffline and
+// ModeMaintenance fail every operation, as an unreachable cache server
+// would, ModeReadOnly fails Set and Delete, ModeDegraded delays each
+// operation and ModeFlaky fails a share of them. Rejected operations do
+// not touch the cache's counters; Stats adds them as "rejected".
+type ModeAwareCache struct {
+	*InMemoryCache
+	mgr ModeManager
+
+	mu        sync.Mutex
+	flakyRate float64
+	rejected  int64
+}
+
+// NewModeAwareCache wraps cache.
+func NewModeAwareCache(cache *InMemoryCache, mgr ModeManager) *ModeAwareCache {
+	return &ModeAwareCache{InMemoryCache: cache, mgr: mgr}
+}
+
+// SetFlakyRate sets the share of operations failed in ModeFlaky.
+func (m *ModeAwareCache) SetFlakyRate(rate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flakyRate = rate
+}
+
+func (m *ModeAwareCache) checkMode(write bool) error {
+	err := m.modeError(write)
+	if err != nil {
+		m.mu.Lock()
+		m.rejected++
+		m.mu.Unlock()
+	}
+	return err
+}
+
+func (m *ModeAwareCache) modeError(write bool) error {
+	mode := m.mgr.CurrentMode()
+	switch mode {
+	case ModeDegraded:
+		sleepWith(m.clock, degradedDelay)
+	case ModeReadOnly:
+		if write {
+			return kindErrorf(ErrUnavailable, "mode aware cache %s: write denied in read-only mode", m.name)
+		}
+	case ModeOffline, ModeMaintenance:
+		return kindErrorf(ErrUnavailable, "mode aware cache %s: unavailable (%s)", m.name, mode)
+	case ModeFlaky:
+		m.mu.Lock()
+		rate := m.flakyRate
+		m.mu.Unlock()
+		if rate > 0 && randFloat() < rate {
+			return kindErrorf(ErrUnavailable, "mode aware cache %s: flaky error", m.name)
+		}
+	}
+	return nil
+}
+
+// Get is InMemoryCache.Get in modes that allow reads.
+func (m *ModeAwareCache) Get(key string) (any, error) {
+	if err := m.checkMode(false); err != nil {
+		return nil, err
+	}
+	return m.InMemoryCache.Get(key)
+}
+
+// Set is InMemoryCache.Set in modes that allow writes.
+func (m *ModeAwareCache) Set(key string, value any, ttl time.Duration) error {
+	if err := m.checkMode(true); err != nil {
+		return err
+	}
+	return m.InMemoryCache.Set(key, value, ttl)
+}
+
+// Delete is InMemoryCache.Delete in modes that allow writes.
+func (m *ModeAwareCache) Delete(key string) error {
+	if err := m.checkMode(true); err != nil {
+		return err
+	}
+	return m.InMemoryCache.Delete(key)
+}
+
+// Status reports the mode when it is not ModeNormal, else the cache's
+// status.
+func (m *ModeAwareCache) Status() (string, error) {
+	if mode := m.mgr.CurrentMode(); mode != ModeNormal {
+		return string(mode), nil
+	}
+	return m.InMemoryCache.Status()
+}
+
+// Health reports false while the mode makes the cache unreachable.
+func (m *ModeAwareCache) Health() (bool, error) {
+	switch m.mgr.CurrentMode() {
+	case ModeOffline, ModeMaintenance:
+		return false, nil
+	}
+	return m.InMemoryCache.Health()
+}
+
+// Stats adds the mode and the number of rejected operations to the
+// cache's counters.
+func (m *ModeAwareCache) Stats() (map[string]interface{}, error) {
+	stats, err := m.InMemoryCache.Stats()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats["mode"] = string(m.mgr.CurrentMode())
+	stats["rejected"] = m.rejected
+	return stats, nil
+}
+
+var (
+	_ Component = (*InMemoryCache)(nil)
+	_ Component = (*ModeAwareCache)(nil)
+)
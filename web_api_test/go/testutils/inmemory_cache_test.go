@@ -0,0 +1,154 @@
+package testutils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func cacheCounters(t *testing.T, c Component) map[string]interface{} {
+	t.Helper()
+	stats, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestInMemoryCache_Conformance(t *testing.T) {
+	fc := NewFakeClock(time.Time{})
+	c := NewInMemoryCache("sessions", WithCacheClock(fc), WithCacheMaxEntries(3))
+
+	mustGet := func(key string, want any) {
+		t.Helper()
+		got, err := c.Get(key)
+		if err != nil || got != want {
+			t.Fatalf("Get(%q) = %v, %v; want %v", key, got, err, want)
+		}
+	}
+	mustMiss := func(key string) {
+		t.Helper()
+		if _, err := c.Get(key); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(%q): expected ErrNotFound, got %v", key, err)
+		}
+	}
+
+	// LRU order: reading a refreshes it, so b is evicted by d.
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Set("c", 3, time.Minute)
+	mustGet("a", 1)
+	c.Set("d", 4, 0)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"d", "a", "c"}) {
+		t.Errorf("LRU order %v, want [d a c]", keys)
+	}
+	mustMiss("b")
+
+	// Overwriting refreshes recency without evicting.
+	c.Set("c", 30, time.Minute)
+	mustGet("c", 30)
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{"c", "d", "a"}) {
+		t.Errorf("LRU order %v, want [c d a]", keys)
+	}
+
+	// TTL expiry follows the clock: one nanosecond short is still a hit.
+	fc.Advance(time.Minute - time.Nanosecond)
+	mustGet("c", 30)
+	fc.Advance(time.Nanosecond)
+	mustMiss("c")
+
+	// ExpireNow leaves the entry until it is met or swept.
+	if !c.ExpireNow("a") || c.ExpireNow("missing") {
+		t.Error("ExpireNow must report whether the key was present")
+	}
+	if n := c.Len(); n != 2 {
+		t.Errorf("expected 2 entries before the sweep, got %d", n)
+	}
+	c.Set("e", 5, time.Second)
+	if n := c.AdvanceAndSweep(time.Second); n != 2 {
+		t.Errorf("AdvanceAndSweep removed %d entries, want 2 (a, e)", n)
+	}
+	mustGet("d", 4)
+	c.Delete("d")
+	mustMiss("d")
+
+	// Script: hits a, c, c, d; misses b, c, d; evicted b; expired c, a, e.
+	want := map[string]interface{}{
+		"hits":        int64(4),
+		"misses":      int64(3),
+		"evictions":   int64(1),
+		"expirations": int64(3),
+		"entries":     int64(0),
+		"max_entries": int64(3),
+	}
+	if got := cacheCounters(t, c); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats %v, want %v", got, want)
+	}
+}
+
+func TestInMemoryCache_EvictsExpiredFirstInCounters(t *testing.T) {
+	fc := NewFakeClock(time.Time{})
+	c := NewInMemoryCache("c", WithCacheClock(fc), WithCacheMaxEntries(1), WithCacheDefaultTTL(time.Second))
+	c.Set("old", 1, 0)
+	fc.Advance(time.Second)
+	c.Set("new", 2, 0)
+	stats := cacheCounters(t, c)
+	if stats["evictions"] != int64(0) || stats["expirations"] != int64(1) {
+		t.Errorf("an expired entry pushed out must count as expired: %v", stats)
+	}
+}
+
+func TestInMemoryCache_Lifecycle(t *testing.T) {
+	c := NewInMemoryCache("c")
+	c.Set("k", "v", 0)
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get("k"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected a stopped cache to be unavailable, got %v", err)
+	}
+	if status, _ := c.Status(); status != "stopped" {
+		t.Errorf("status %q", status)
+	}
+	c.Start()
+	if _, err := c.Get("k"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stop must drop entries, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AdvanceAndSweep must panic on a real clock")
+		}
+	}()
+	c.AdvanceAndSweep(time.Second)
+}
+
+func TestModeAwareCache_Outage(t *testing.T) {
+	mgr := NewMockModeManager(ModeNormal)
+	c := NewModeAwareCache(NewInMemoryCache("c"), mgr)
+	if err := c.Set("k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.SetMode(ModeOffline)
+	if _, err := c.Get("k"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected an outage, got %v", err)
+	}
+	if healthy, _ := c.Health(); healthy {
+		t.Error("an offline cache must be unhealthy")
+	}
+
+	mgr.SetMode(ModeReadOnly)
+	if err := c.Set("k", "w", 0); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected writes to fail in read-only mode, got %v", err)
+	}
+	if v, err := c.Get("k"); err != nil || v != "v" {
+		t.Errorf("reads must pass in read-only mode, got %v, %v", v, err)
+	}
+
+	stats := cacheCounters(t, c)
+	if stats["rejected"] != int64(2) || stats["hits"] != int64(1) || stats["misses"] != int64(0) || stats["mode"] != "readonly" {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
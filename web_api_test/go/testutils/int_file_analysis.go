@@ -0,0 +1,340 @@
+package testutils
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ------------------------------------------------------------------------
+// Integer extraction from test files
+// ------------------------------------------------------------------------
+
+// IntFileParser extracts the integers in r, calling emit for each one. Bad
+// records are passed to reject with their 1-based line and parsing goes
+// on; a returned error abandons the rest of the file.
+type IntFileParser func(r io.Reader, emit func(int), reject func(line int, err error)) error
+
+// errSkipFile is returned by SkipIntFile.
+var errSkipFile = errors.New("file holds no data")
+
+// SkipIntFile is a parser for files that match a data pattern but hold
+// none, such as the _stats.txt summaries CreateIntegerTestFiles writes.
+// Such files are listed in IntFileAnalysis.Skipped.
+func SkipIntFile(io.Reader, func(int), func(int, error)) error { return errSkipFile }
+
+// KeyValueIntParser reads "key: value" lines. With no keys, every line
+// whose value is an integer counts and other lines are ignored. With keys,
+// only lines whose key starts with one of them count, and a value that is
+// not an integer is rejected.
+func KeyValueIntParser(keys ...string) IntFileParser {
+	return func(r io.Reader, emit func(int), reject func(int, error)) error {
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; sc.Scan(); line++ {
+			key, value, ok := strings.Cut(sc.Text(), ":")
+			if !ok {
+				continue
+			}
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if len(keys) > 0 && !hasAnyPrefix(key, keys) {
+				continue
+			}
+			n, err := strconv.Atoi(value)
+			switch {
+			case err == nil:
+				emit(n)
+			case len(keys) > 0:
+				reject(line, fmt.Errorf("value of %q is not an integer: %q", key, value))
+			}
+		}
+		return sc.Err()
+	}
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVColumnIntParser reads the named column of a CSV file with a header
+// row. Rows may have more fields than the header, as the factor lists of
+// CreateIntegerTestFiles do.
+func CSVColumnIntParser(column string) IntFileParser {
+	return func(r io.Reader, emit func(int), reject func(int, error)) error {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		header, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		col := -1
+		for i, name := range header {
+			if strings.TrimSpace(name) == column {
+				col = i
+				break
+			}
+		}
+		if col < 0 {
+			return fmt.Errorf("CSV has no column %q (columns: %s)", column, strings.Join(header, ", "))
+		}
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				var parseErr *csv.ParseError
+				if errors.As(err, &parseErr) {
+					reject(parseErr.Line, err)
+					continue
+				}
+				return err
+			}
+			line, _ := cr.FieldPos(0)
+			if col >= len(record) {
+				reject(line, fmt.Errorf("row has no column %q", column))
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(record[col]))
+			if err != nil {
+				reject(line, fmt.Errorf("column %q is not an integer: %q", column, record[col]))
+				continue
+			}
+			emit(n)
+		}
+	}
+}
+
+// JSONPathIntParser reads the integers at a dotted path ("results.value")
+// in a JSON document. Arrays along the path are walked element by element,
+// so "items.id" collects the id of every item. An empty path takes the
+// document itself. Values that are not integers are rejected; line 0 is
+// reported since the document is decoded as a whole.
+func JSONPathIntParser(path string) IntFileParser {
+	var keys []string
+	if path != "" {
+		keys = strings.Split(path, ".")
+	}
+	return func(r io.Reader, emit func(int), reject func(int, error)) error {
+		dec := json.NewDecoder(r)
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		walkJSONInts(doc, keys, "$", emit, reject)
+		return nil
+	}
+}
+
+func walkJSONInts(v any, keys []string, at string, emit func(int), reject func(int, error)) {
+	if items, ok := v.([]any); ok {
+		for i, item := range items {
+			walkJSONInts(item, keys, fmt.Sprintf("%s[%d]", at, i), emit, reject)
+		}
+		return
+	}
+	if len(keys) > 0 {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			reject(0, fmt.Errorf("%s is not an object", at))
+			return
+		}
+		child, ok := obj[keys[0]]
+		if !ok {
+			reject(0, fmt.Errorf("%s has no key %q", at, keys[0]))
+			return
+		}
+		walkJSONInts(child, keys[1:], at+"."+keys[0], emit, reject)
+		return
+	}
+	num, ok := v.(json.Number)
+	if !ok {
+		reject(0, fmt.Errorf("%s is not a number", at))
+		return
+	}
+	n, err := num.Int64()
+	if err != nil || n < math.MinInt || n > math.MaxInt {
+		reject(0, fmt.Errorf("%s is not an integer: %s", at, num))
+		return
+	}
+	emit(int(n))
+}
+
+// defaultIntParsers maps file suffixes to parsers. The longest matching
+// suffix wins, so "_stats.txt" overrides ".txt".
+func defaultIntParsers() map[string]IntFileParser {
+	return map[string]IntFileParser{
+		".txt":       KeyValueIntParser(),
+		"_stats.txt": SkipIntFile,
+		".csv":       CSVColumnIntParser("value"),
+		".json":      JSONPathIntParser(""),
+	}
+}
+
+// AnalyzeOption configures AnalyzeTestFiles.
+type AnalyzeOption func(map[string]IntFileParser)
+
+// WithIntParser parses files whose name ends in suffix (".tsv",
+// "_metrics.json") with p, replacing any parser registered for it. A nil
+// p unregisters the suffix.
+func WithIntParser(suffix string, p IntFileParser) AnalyzeOption {
+	return func(parsers map[string]IntFileParser) {
+		if p == nil {
+			delete(parsers, suffix)
+			return
+		}
+		parsers[suffix] = p
+	}
+}
+
+// parserFor returns the parser with the longest suffix matching name.
+func parserFor(parsers map[string]IntFileParser, name string) (string, IntFileParser) {
+	best := ""
+	for suffix := range parsers {
+		if strings.HasSuffix(name, suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+	return best, parsers[best]
+}
+
+// IntFileCount is how many integers one file contributed.
+type IntFileCount struct {
+	Path   string `json:"path"`
+	Parser string `json:"parser"` // the suffix that selected the parser
+	Count  int    `json:"count"`
+}
+
+// SkippedFile is a matched file that contributed nothing, and why.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// IntParseError is a record a parser rejected, or the error that stopped
+// it part-way through a file. Line is 0 when unknown.
+type IntParseError struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	Err  string `json:"error"`
+}
+
+func (e IntParseError) String() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Path, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+// IntFileAnalysis is the result of AnalyzeTestFiles.
+type IntFileAnalysis struct {
+	Stats   *IntStats       `json:"stats"`
+	Files   []IntFileCount  `json:"files"`
+	Skipped []SkippedFile   `json:"skipped,omitempty"`
+	Errors  []IntParseError `json:"errors,omitempty"`
+}
+
+// AnalyzeTestFiles collects the integers in the test files matching
+// pattern and summarises them. Each file is streamed through the parser
+// registered for its suffix (see WithIntParser): "key: value" lines for
+// .txt, the "value" column for .csv and every number for .json. Files
+// that cannot be read or have no parser are listed in Skipped, and bad
+// records in Errors; neither stops the analysis.
+func (tdm *TestDataManager) AnalyzeTestFiles(pattern string, opts ...AnalyzeOption) (*IntFileAnalysis, error) {
+	files, err := filepath.Glob(filepath.Join(tdm.testDir, pattern))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find files with pattern %q: %w", pattern, err)
+	}
+	sort.Strings(files)
+
+	parsers := defaultIntParsers()
+	for _, opt := range opts {
+		opt(parsers)
+	}
+
+	analysis := &IntFileAnalysis{}
+	var allInts []int
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.IsDir() {
+			continue
+		}
+		suffix, parse := parserFor(parsers, filepath.Base(file))
+		if parse == nil {
+			analysis.Skipped = append(analysis.Skipped, SkippedFile{Path: file, Reason: "no parser for this file type"})
+			continue
+		}
+		count, err := parseIntFile(file, parse, func(n int) {
+			allInts = append(allInts, n)
+		}, func(line int, err error) {
+			analysis.Errors = append(analysis.Errors, IntParseError{Path: file, Line: line, Err: err.Error()})
+		})
+		switch {
+		case errors.Is(err, errSkipFile):
+			analysis.Skipped = append(analysis.Skipped, SkippedFile{Path: file, Reason: err.Error()})
+			continue
+		case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+			analysis.Skipped = append(analysis.Skipped, SkippedFile{Path: file, Reason: err.Error()})
+			continue
+		case err != nil:
+			analysis.Errors = append(analysis.Errors, IntParseError{Path: file, Err: err.Error()})
+		}
+		analysis.Files = append(analysis.Files, IntFileCount{Path: file, Parser: suffix, Count: count})
+	}
+
+	if len(analysis.Skipped) > 0 || len(analysis.Errors) > 0 {
+		tdm.logger.Warn("some test files were not fully analyzed", map[string]any{
+			"pattern": pattern,
+			"skipped": len(analysis.Skipped),
+			"errors":  len(analysis.Errors),
+		})
+	}
+	analysis.Stats = tdm.intUtilities().Analyze(allInts)
+	return analysis, nil
+}
+
+// parseIntFile streams path through parse and returns how many integers
+// it produced.
+func parseIntFile(path string, parse IntFileParser, emit func(int), reject func(int, error)) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	count := 0
+	err = parse(bufio.NewReader(f), func(n int) {
+		count++
+		emit(n)
+	}, reject)
+	return count, err
+}
+
+// intUtilities returns the logger's integer utilities when it has them.
+func (tdm *TestDataManager) intUtilities() *IntUtilities {
+	if logger, ok := tdm.logger.(*TestLogger); ok && logger.intUtils != nil {
+		return logger.intUtils
+	}
+	return NewIntUtilities()
+}
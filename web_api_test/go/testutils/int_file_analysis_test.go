@@ -0,0 +1,126 @@
+package testutils
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeTestFiles_RoundTripsCreateIntegerTestFiles(t *testing.T) {
+	logger := NewTestLogger("analyze", io.Discard)
+	tdm := newTestManager(t, "analyze", logger, nil)
+	ints := []int{12, -5, 7, 7, 100, 0, 42}
+	if _, err := logger.CreateIntegerTestFiles(tdm, "nums", ints); err != nil {
+		t.Fatalf("CreateIntegerTestFiles: %v", err)
+	}
+	want := NewIntUtilities().Analyze(ints)
+
+	csv, err := tdm.AnalyzeTestFiles("nums_data.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(csv.Stats, want) {
+		t.Errorf("CSV stats:\n got %+v\nwant %+v", csv.Stats, want)
+	}
+	if len(csv.Files) != 1 || csv.Files[0].Count != len(ints) || len(csv.Errors) != 0 {
+		t.Errorf("expected one CSV file with %d values and no errors, got %+v", len(ints), csv)
+	}
+
+	txt, err := tdm.AnalyzeTestFiles("nums_*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(txt.Stats, want) {
+		t.Errorf("text file stats:\n got %+v\nwant %+v", txt.Stats, want)
+	}
+	if len(txt.Files) != len(ints) || len(txt.Errors) != 0 {
+		t.Errorf("expected %d text files and no errors, got %+v", len(ints), txt)
+	}
+	for _, f := range txt.Files {
+		if f.Count != 1 || f.Parser != ".txt" {
+			t.Errorf("expected one value from %s via .txt, got %+v", f.Path, f)
+		}
+	}
+	if len(txt.Skipped) != 1 || filepath.Base(txt.Skipped[0].Path) != "nums_stats.txt" {
+		t.Errorf("expected the stats file to be skipped, got %+v", txt.Skipped)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tdm.GetTestDir(), "nums_stats.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, body, _ := strings.Cut(string(data), "\n")
+	var written IntStats
+	if err := json.Unmarshal([]byte(body), &written); err != nil {
+		t.Fatalf("stats file does not hold JSON: %v", err)
+	}
+	if !reflect.DeepEqual(&written, csv.Stats) {
+		t.Errorf("stats file disagrees with the analysis:\n file %+v\n  got %+v", written, csv.Stats)
+	}
+}
+
+func TestAnalyzeTestFiles_ReportsProblemsAndUsesCustomParsers(t *testing.T) {
+	tdm := newTestManager(t, "analyze", nil, nil)
+	tdm.CreateTestFile("a.csv", "index,value\n0,5\n1,x\n2,\"7\n")
+	tdm.CreateTestFile("b.json", `{"items": [{"id": 3}, {"id": 4.5}, {"name": "no id"}]}`)
+	tdm.CreateTestFile("c.dat", "n=1\nn=2\n")
+	tdm.CreateTestFile("d.txt", "count: 9\nnote: ok\n")
+	os.Symlink(filepath.Join(tdm.GetTestDir(), "missing"), filepath.Join(tdm.GetTestDir(), "e.txt"))
+
+	got, err := tdm.AnalyzeTestFiles("*",
+		WithIntParser(".json", JSONPathIntParser("items.id")),
+		WithIntParser(".dat", func(r io.Reader, emit func(int), reject func(int, error)) error {
+			return KeyValueIntParser("n")(strings.NewReader(strings.ReplaceAll(readAll(t, r), "=", ":")), emit, reject)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[string]int{}
+	for _, f := range got.Files {
+		counts[filepath.Base(f.Path)] = f.Count
+	}
+	if want := map[string]int{"a.csv": 1, "b.json": 1, "c.dat": 2, "d.txt": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("per-file counts: got %v, want %v", counts, want)
+	}
+	if got.Stats.Count != 5 || got.Stats.Sum != 5+3+1+2+9 {
+		t.Errorf("unexpected stats %+v", got.Stats)
+	}
+
+	var errs []string
+	for _, e := range got.Errors {
+		rel, _ := filepath.Rel(tdm.GetTestDir(), e.Path)
+		errs = append(errs, strings.Replace(e.String(), e.Path, rel, 1))
+	}
+	wantErrs := []string{
+		`a.csv:3: column "value" is not an integer: "x"`,
+		`a.csv:4: parse error on line 4`,
+		`b.json: $.items[1].id is not an integer: 4.5`,
+		`b.json: $.items[2] has no key "id"`,
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("parse errors:\n got %q\nwant %q", errs, wantErrs)
+	}
+	for i := range wantErrs {
+		if !strings.HasPrefix(errs[i], wantErrs[i]) {
+			t.Errorf("parse error %d:\n got %s\nwant %s", i, errs[i], wantErrs[i])
+		}
+	}
+	if len(got.Skipped) != 1 || filepath.Base(got.Skipped[0].Path) != "e.txt" {
+		t.Errorf("expected the dangling link to be skipped, got %+v", got.Skipped)
+	}
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	t.Helper()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -9,12 +9,66 @@ import (
     "sync"
 )
 
+// defaultPrimeCacheLimit matches IntegerUtilsConfig.PrimeCacheLimit in DefaultConfig.
+const defaultPrimeCacheLimit = 1000000
+
 // IntUtilities provides various integer utility functions
-type IntUtilities struct{}
+type IntUtilities struct {
+    primeLimit int // sieve covers [0, primeLimit]; 0 disables it
+
+    sieveOnce sync.Once
+    spf       []int32 // smallest prime factor of each index; spf[p] == p for primes
+}
 
 // NewIntUtilities creates a new integer utilities instance
 func NewIntUtilities() *IntUtilities {
-    return &IntUtilities{}
+    return &IntUtilities{primeLimit: defaultPrimeCacheLimit}
+}
+
+// NewIntUtilitiesFromConfig creates an instance whose prime sieve covers
+// values up to cfg.PrimeCacheLimit. A limit of 0 disables the sieve.
+func NewIntUtilitiesFromConfig(cfg IntegerUtilsConfig) *IntUtilities {
+    limit := cfg.PrimeCacheLimit
+    if limit < 0 {
+        limit = 0
+    }
+    return &IntUtilities{primeLimit: limit}
+}
+
+// PrimeCacheLimit returns the largest value answered from the sieve.
+func (iu *IntUtilities) PrimeCacheLimit() int {
+    return iu.primeLimit
+}
+
+// sieve returns the smallest-prime-factor table, building it on first use.
+func (iu *IntUtilities) sieve() []int32 {
+    iu.sieveOnce.Do(func() {
+        if iu.primeLimit < 2 {
+            return
+        }
+        spf := make([]int32, iu.primeLimit+1)
+        for i := 2; i <= iu.primeLimit; i++ {
+            if spf[i] != 0 {
+                continue
+            }
+            spf[i] = int32(i)
+            if i > iu.primeLimit/i {
+                continue
+            }
+            for j := i * i; j <= iu.primeLimit; j += i {
+                if spf[j] == 0 {
+                    spf[j] = int32(i)
+                }
+            }
+        }
+        iu.spf = spf
+    })
+    return iu.spf
+}
+
+// cached reports whether n is covered by the sieve.
+func (iu *IntUtilities) cached(n int) bool {
+    return n >= 0 && n <= iu.primeLimit && iu.primeLimit >= 2
 }
 
 // ParseInts parses a comma-separated string of integers
@@ -72,8 +126,61 @@ func (iu *IntUtilities) ParseInts(s string) ([]int, *CompositeIntError) {
     return results, nil
 }
 
-// IsPrime checks if a number is prime
+// IsPrime checks if a number is prime. Values up to the prime cache limit
+// are answered from the sieve; larger ones use trial division.
 func (iu *IntUtilities) IsPrime(n int) bool {
+    if n <= 1 {
+        return false
+    }
+    if iu.cached(n) {
+        return int(iu.sieve()[n]) == n
+    }
+    return isPrimeTrial(n)
+}
+
+// PrimesUpTo returns all primes <= n in ascending order.
+func (iu *IntUtilities) PrimesUpTo(n int) []int {
+    if n < 2 {
+        return []int{}
+    }
+
+    var primes []int
+    top := n
+    if top > iu.primeLimit {
+        top = iu.primeLimit
+    }
+    if iu.cached(top) {
+        spf := iu.sieve()
+        for i := 2; i <= top; i++ {
+            if int(spf[i]) == i {
+                primes = append(primes, i)
+            }
+        }
+    } else {
+        top = 1
+    }
+    for v := top + 1; v <= n; v++ {
+        if isPrimeTrial(v) {
+            primes = append(primes, v)
+        }
+    }
+    return primes
+}
+
+// NextPrime returns the smallest prime strictly greater than n.
+func (iu *IntUtilities) NextPrime(n int) int {
+    if n < 2 {
+        return 2
+    }
+    for v := n + 1; ; v++ {
+        if iu.IsPrime(v) {
+            return v
+        }
+    }
+}
+
+// isPrimeTrial checks primality by 6k±1 trial division.
+func isPrimeTrial(n int) bool {
     if n <= 1 {
         return false
     }
@@ -110,13 +217,18 @@ func (iu *IntUtilities) LCM(a, b int) int {
     return abs(a*b) / iu.GCD(a, b)
 }
 
-// Factors returns all factors of a number
+// Factors returns all factors of a number. Within the prime cache limit the
+// divisors are generated from the sieve's prime factorization.
 func (iu *IntUtilities) Factors(n int) []int {
     if n == 0 {
         return []int{}
     }
 
     n = abs(n)
+    if iu.cached(n) {
+        return iu.sieveFactors(n)
+    }
+
     var factors []int
 
     limit := int(math.Sqrt(float64(n)))
@@ -133,6 +245,31 @@ func (iu *IntUtilities) Factors(n int) []int {
     return factors
 }
 
+// sieveFactors builds the sorted divisors of n (0 < n <= primeLimit) from
+// its prime factorization.
+func (iu *IntUtilities) sieveFactors(n int) []int {
+    spf := iu.sieve()
+    factors := []int{1}
+    for n > 1 {
+        p := int(spf[n])
+        count := 0
+        for n%p == 0 {
+            n /= p
+            count++
+        }
+        existing := len(factors)
+        mult := 1
+        for k := 0; k < count; k++ {
+            mult *= p
+            for i := 0; i < existing; i++ {
+                factors = append(factors, factors[i]*mult)
+            }
+        }
+    }
+    sort.Ints(factors)
+    return factors
+}
+
 // Fibonacci generates Fibonacci numbers up to limit or count
 func (iu *IntUtilities) Fibonacci(limit int, maxCount int) []int {
     if limit <= 0 && maxCount <= 0 {
@@ -0,0 +1,98 @@
+package testutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIntUtilities_SieveMatchesTrialDivision(t *testing.T) {
+	iu := NewIntUtilitiesFromConfig(IntegerUtilsConfig{PrimeCacheLimit: 1000})
+	for n := -5; n <= 1200; n++ {
+		if got, want := iu.IsPrime(n), isPrimeTrial(n); got != want {
+			t.Errorf("IsPrime(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func TestIntUtilities_PrimeCacheBoundary(t *testing.T) {
+	// 97 and 101 are primes on either side of the limit; 100 sits on it.
+	iu := NewIntUtilitiesFromConfig(IntegerUtilsConfig{PrimeCacheLimit: 100})
+
+	cases := map[int]bool{97: true, 99: false, 100: false, 101: true, 103: true}
+	for n, want := range cases {
+		if got := iu.IsPrime(n); got != want {
+			t.Errorf("IsPrime(%d) = %v, want %v", n, got, want)
+		}
+	}
+
+	if got := iu.NextPrime(97); got != 101 {
+		t.Errorf("NextPrime(97) = %d, want 101", got)
+	}
+	if got := iu.NextPrime(1); got != 2 {
+		t.Errorf("NextPrime(1) = %d, want 2", got)
+	}
+
+	want := []int{83, 89, 97, 101, 103, 107, 109}
+	primes := iu.PrimesUpTo(110)
+	if tail := primes[len(primes)-len(want):]; !reflect.DeepEqual(tail, want) {
+		t.Errorf("PrimesUpTo(110) ends with %v, want %v", tail, want)
+	}
+	if len(primes) != 29 {
+		t.Errorf("expected 29 primes up to 110, got %d", len(primes))
+	}
+}
+
+func TestIntUtilities_SieveDisabled(t *testing.T) {
+	iu := NewIntUtilitiesFromConfig(IntegerUtilsConfig{PrimeCacheLimit: 0})
+	if !iu.IsPrime(7919) || iu.IsPrime(7917) {
+		t.Error("trial division fallback gave wrong answers")
+	}
+	if got := iu.PrimesUpTo(10); !reflect.DeepEqual(got, []int{2, 3, 5, 7}) {
+		t.Errorf("PrimesUpTo(10) = %v", got)
+	}
+	if iu.spf != nil {
+		t.Error("sieve was built although it is disabled")
+	}
+}
+
+func TestIntUtilities_FactorsFromSieve(t *testing.T) {
+	sieved := NewIntUtilitiesFromConfig(IntegerUtilsConfig{PrimeCacheLimit: 1000})
+	plain := NewIntUtilitiesFromConfig(IntegerUtilsConfig{})
+	for _, n := range []int{1, 2, 12, 97, 360, 999, 1000, -36, 1001} {
+		if got, want := sieved.Factors(n), plain.Factors(n); !reflect.DeepEqual(got, want) {
+			t.Errorf("Factors(%d) = %v, want %v", n, got, want)
+		}
+	}
+}
+
+func BenchmarkIntUtilities_IsPrimeSieve(b *testing.B) {
+	iu := NewIntUtilities()
+	iu.sieve()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iu.IsPrime(999983 - i%1000)
+	}
+}
+
+func BenchmarkIntUtilities_IsPrimeTrial(b *testing.B) {
+	iu := NewIntUtilitiesFromConfig(IntegerUtilsConfig{})
+	for i := 0; i < b.N; i++ {
+		iu.IsPrime(999983 - i%1000)
+	}
+}
+
+func BenchmarkIntUtilities_FactorsSieve(b *testing.B) {
+	iu := NewIntUtilities()
+	iu.sieve()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		iu.Factors(720720 + i%1000)
+	}
+}
+
+func BenchmarkIntUtilities_FactorsTrial(b *testing.B) {
+	iu := NewIntUtilitiesFromConfig(IntegerUtilsConfig{})
+	for i := 0; i < b.N; i++ {
+		iu.Factors(720720 + i%1000)
+	}
+}
@@ -0,0 +1,140 @@
+package testutils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ------------------------------------------------------------------------
+// Streaming JSON decoding – large arrays and NDJSON without buffering
+// ------------------------------------------------------------------------
+
+// ErrStopStream is returned by a DecodeJSONStream callback to stop reading
+// early. DecodeJSONStream then returns a nil error.
+var ErrStopStream = errors.New("stop json stream")
+
+// JSONStreamError reports an element DecodeJSONStream could not decode.
+type JSONStreamError struct {
+	Index  int   // zero-based element index
+	Offset int64 // bytes read before a syntax error, else where the element began
+	Err    error
+}
+
+func (e *JSONStreamError) Error() string {
+	return fmt.Sprintf("json stream: element %d at byte %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *JSONStreamError) Unwrap() error { return e.Err }
+
+// DecodeJSONStream decodes r one element at a time, calling fn with the
+// index and value of each, so a large response never has to fit in memory:
+//
+//	n, err := DecodeJSONStream(resp.Body, func(i int, u User) error {
+//		if u.Email == "" {
+//			return fmt.Errorf("user %d has no email", i)
+//		}
+//		return nil
+//	})
+//
+// r holds either a top-level JSON array or a sequence of JSON values such
+// as NDJSON, one per line. fn may return ErrStopStream to stop without an
+// error; any other error stops decoding and is returned as is. A malformed
+// element is reported as a *JSONStreamError with its index and offset.
+// The count of elements passed to fn is returned.
+func DecodeJSONStream[T any](r io.Reader, fn func(index int, item T) error) (int, error) {
+	br := bufio.NewReader(r)
+	lead, err := skipJSONSpace(br)
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("json stream: %w", err)
+	}
+	first, _ := br.Peek(1)
+	dec := json.NewDecoder(br)
+	array := first[0] == '['
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return 0, &JSONStreamError{Offset: lead, Err: err}
+		}
+	}
+
+	n := 0
+	for {
+		if array && !dec.More() {
+			break
+		}
+		offset := lead + dec.InputOffset()
+		var item T
+		if err := dec.Decode(&item); err != nil {
+			if err == io.EOF && !array {
+				break
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			var syntax *json.SyntaxError
+			if errors.As(err, &syntax) {
+				offset = lead + syntax.Offset
+			}
+			return n, &JSONStreamError{Index: n, Offset: offset, Err: err}
+		}
+		if err := fn(n, item); err != nil {
+			if errors.Is(err, ErrStopStream) {
+				return n + 1, nil
+			}
+			return n + 1, err
+		}
+		n++
+	}
+	if !array {
+		return n, nil
+	}
+
+	offset := lead + dec.InputOffset()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim(']') {
+		if err == nil {
+			err = fmt.Errorf("unexpected %v", tok)
+		}
+		return n, &JSONStreamError{Index: n, Offset: offset, Err: err}
+	}
+	return n, nil
+}
+
+// skipJSONSpace consumes leading whitespace and returns how much it read.
+func skipJSONSpace(br *bufio.Reader) (int64, error) {
+	var n int64
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return n, br.UnreadByte()
+		}
+		n++
+	}
+}
+
+// GetJSONStream sends a GET request and streams the JSON array or NDJSON
+// body through DecodeJSONStream, passing each element undecoded so fn can
+// unmarshal it into its own type. A non-2xx status is an error carrying the
+// start of the body. The body is closed on return, also when fn stops early.
+func (c *HTTPTestClient) GetJSONStream(ctx context.Context, path string, fn func(index int, item json.RawMessage) error, opts ...RequestOption) (int, error) {
+	resp, err := c.Get(ctx, path, opts...)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		head, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("%s %s returned %d: %s", http.MethodGet, path, resp.StatusCode, bytes.TrimSpace(head))
+	}
+	return DecodeJSONStream(resp.Body, fn)
+}
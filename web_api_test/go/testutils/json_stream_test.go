@@ -0,0 +1,177 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type streamUser struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// syntheticUsers generates a JSON array of n users as it is read, so the
+// body itself never sits in memory.
+type syntheticUsers struct {
+	n, next int
+	buf     []byte
+	done    bool
+}
+
+func (s *syntheticUsers) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		switch {
+		case s.done:
+			return 0, io.EOF
+		case s.next == 0:
+			s.buf = append(s.buf, '[')
+		case s.next > s.n:
+			s.buf = append(s.buf, ']')
+			s.done = true
+			continue
+		default:
+			if s.next > 1 {
+				s.buf = append(s.buf, ',')
+			}
+			id := strconv.Itoa(s.next)
+			s.buf = append(s.buf, `{"id":`+id+`,"name":"user `+id+`","email":"user`+id+`@example.com"}`...)
+		}
+		s.next++
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func TestDecodeJSONStream_ArrayAndNDJSON(t *testing.T) {
+	for name, body := range map[string]string{
+		"array":  ` [{"id":1,"name":"a"}, {"id":2,"name":"b"},{"id":3,"name":"c"}]`,
+		"ndjson": "{\"id\":1,\"name\":\"a\"}\n{\"id\":2,\"name\":\"b\"}\n{\"id\":3,\"name\":\"c\"}\n",
+	} {
+		var ids []int
+		n, err := DecodeJSONStream(strings.NewReader(body), func(i int, u streamUser) error {
+			if u.ID != i+1 {
+				t.Errorf("%s: element %d has id %d", name, i, u.ID)
+			}
+			ids = append(ids, u.ID)
+			return nil
+		})
+		if err != nil || n != 3 || len(ids) != 3 {
+			t.Errorf("%s: decoded %d (%v), err %v", name, n, ids, err)
+		}
+	}
+
+	if n, err := DecodeJSONStream(strings.NewReader("  "), func(int, streamUser) error { return nil }); n != 0 || err != nil {
+		t.Errorf("empty body: %d, %v", n, err)
+	}
+}
+
+func TestDecodeJSONStream_StopEarly(t *testing.T) {
+	src := &syntheticUsers{n: 1000}
+	n, err := DecodeJSONStream(src, func(i int, u streamUser) error {
+		if i == 9 {
+			return ErrStopStream
+		}
+		return nil
+	})
+	if err != nil || n != 10 {
+		t.Fatalf("expected to stop after 10 elements, got %d, %v", n, err)
+	}
+	if src.done {
+		t.Error("the rest of the body should not have been read")
+	}
+
+	boom := errors.New("bad user")
+	if _, err := DecodeJSONStream(&syntheticUsers{n: 5}, func(i int, u streamUser) error {
+		return boom
+	}); err != boom {
+		t.Errorf("callback error not returned as is: %v", err)
+	}
+}
+
+func TestDecodeJSONStream_MalformedElement(t *testing.T) {
+	for _, tc := range []struct {
+		name, body string
+		index      int
+		offset     int64
+	}{
+		{"syntax", `[{"id":1}, {"id":x}]`, 1, 18},
+		{"type", `[{"id":1},{"id":"two"}]`, 1, 9},
+		{"truncated", `[{"id":1},{"id":2}`, 2, 18},
+		{"ndjson", "{\"id\":1}\n{\"id\":}\n", 1, 16},
+	} {
+		n, err := DecodeJSONStream(strings.NewReader(tc.body), func(int, streamUser) error { return nil })
+		var se *JSONStreamError
+		if !errors.As(err, &se) {
+			t.Errorf("%s: expected a *JSONStreamError, got %v", tc.name, err)
+			continue
+		}
+		if se.Index != tc.index || se.Offset != tc.offset || n != tc.index {
+			t.Errorf("%s: element %d at byte %d after %d elements (%v), want element %d at byte %d",
+				tc.name, se.Index, se.Offset, n, se.Err, tc.index, tc.offset)
+		}
+	}
+}
+
+// TestDecodeJSONStream_ConstantMemory decodes 10k and 100k element bodies
+// and checks that the cost per element does not grow with the body.
+func TestDecodeJSONStream_ConstantMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("decodes 110k elements")
+	}
+	measure := func(n int) (allocsPerElem, bytesPerElem float64) {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		count, err := DecodeJSONStream(&syntheticUsers{n: n}, func(int, streamUser) error { return nil })
+		runtime.ReadMemStats(&after)
+		if err != nil || count != n {
+			t.Fatalf("decoded %d of %d: %v", count, n, err)
+		}
+		return float64(after.Mallocs-before.Mallocs) / float64(n),
+			float64(after.TotalAlloc-before.TotalAlloc) / float64(n)
+	}
+	smallAllocs, smallBytes := measure(10_000)
+	largeAllocs, largeBytes := measure(100_000)
+	t.Logf("per element: %.1f allocs, %.0f B at 10k; %.1f allocs, %.0f B at 100k",
+		smallAllocs, smallBytes, largeAllocs, largeBytes)
+	if largeAllocs > smallAllocs*1.5 || largeBytes > smallBytes*1.5 {
+		t.Errorf("allocations grow with the body: %.1f allocs/%.0f B per element at 100k vs %.1f/%.0f at 10k",
+			largeAllocs, largeBytes, smallAllocs, smallBytes)
+	}
+}
+
+func TestHTTPTestClient_GetJSONStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.Copy(w, &syntheticUsers{n: 50})
+	}))
+	defer srv.Close()
+	c := NewHTTPTestClient(srv.URL)
+
+	var last streamUser
+	n, err := c.GetJSONStream(context.Background(), "/users", func(i int, raw json.RawMessage) error {
+		return json.Unmarshal(raw, &last)
+	})
+	if err != nil || n != 50 || last.Email != "user50@example.com" {
+		t.Errorf("streamed %d users, last %+v, err %v", n, last, err)
+	}
+
+	if _, err := c.GetJSONStream(context.Background(), "/missing", func(int, json.RawMessage) error { return nil }); err == nil ||
+		!strings.Contains(err.Error(), "404") {
+		t.Errorf("expected the status in the error, got %v", err)
+	}
+}
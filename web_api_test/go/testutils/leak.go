@@ -0,0 +1,242 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Goroutine leak detection
+// ------------------------------------------------------------------------
+
+// defaultLeakIgnores are stack fragments of goroutines that outlive tests
+// legitimately: the test runner itself, signal handling, and idle
+// keep-alive connections parked in the net poller.
+var defaultLeakIgnores = []string{
+	"testing.(*T).Run(",
+	"testing.(*M).",
+	"testing.tRunner.func1",
+	"testing.runTests",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"net/http.(*persistConn).readLoop",
+	"net/http.(*persistConn).writeLoop",
+}
+
+// expectedGoroutines holds the IDs of package goroutines that are meant to
+// outlive the test that started them, such as a shared log dispatcher.
+var expectedGoroutines struct {
+	mu  sync.Mutex
+	ids map[int64]bool
+}
+
+// markExpectedGoroutine excludes the calling goroutine from leak checks
+// until the returned release function runs. Long-lived package goroutines
+// call it first thing:
+//
+//	go func() {
+//		defer markExpectedGoroutine()()
+//		...
+//	}()
+func markExpectedGoroutine() (release func()) {
+	id := currentGoroutineID()
+	expectedGoroutines.mu.Lock()
+	if expectedGoroutines.ids == nil {
+		expectedGoroutines.ids = make(map[int64]bool)
+	}
+	expectedGoroutines.ids[id] = true
+	expectedGoroutines.mu.Unlock()
+	return func() {
+		expectedGoroutines.mu.Lock()
+		delete(expectedGoroutines.ids, id)
+		expectedGoroutines.mu.Unlock()
+	}
+}
+
+func isExpectedGoroutine(id int64) bool {
+	expectedGoroutines.mu.Lock()
+	defer expectedGoroutines.mu.Unlock()
+	return expectedGoroutines.ids[id]
+}
+
+// GoroutineInfo is one goroutine from a runtime stack dump.
+type GoroutineInfo struct {
+	ID    int64
+	State string
+	Stack string // full text including the "goroutine N [state]:" header
+}
+
+// GoroutineSnapshot records the goroutines alive at a point in time.
+type GoroutineSnapshot struct {
+	ids map[int64]bool
+}
+
+// SnapshotGoroutines records the goroutines running now.
+func SnapshotGoroutines() *GoroutineSnapshot {
+	s := &GoroutineSnapshot{ids: make(map[int64]bool)}
+	for _, g := range allGoroutines() {
+		s.ids[g.ID] = true
+	}
+	return s
+}
+
+// LeakOption configures a leak check.
+type LeakOption func(*leakConfig)
+
+type leakConfig struct {
+	ignores  []string
+	attempts int
+	backoff  time.Duration
+}
+
+// IgnoreGoroutines treats goroutines whose stack contains any of the given
+// fragments (e.g. "mypkg.(*Cache).janitor") as expected.
+func IgnoreGoroutines(fragments ...string) LeakOption {
+	return func(c *leakConfig) { c.ignores = append(c.ignores, fragments...) }
+}
+
+// WithLeakRetry sets how many times the check looks again, doubling the
+// wait from backoff each time, before reporting goroutines that are still
+// shutting down as leaks. The default is 8 attempts from 5ms (about 1s).
+func WithLeakRetry(attempts int, backoff time.Duration) LeakOption {
+	return func(c *leakConfig) {
+		c.attempts = attempts
+		c.backoff = backoff
+	}
+}
+
+// GoroutineLeakError lists goroutines started after a snapshot that were
+// still running when it was checked.
+type GoroutineLeakError struct {
+	Leaks []GoroutineInfo
+}
+
+func (e *GoroutineLeakError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d leaked goroutine(s):", len(e.Leaks))
+	for _, g := range e.Leaks {
+		b.WriteString("\n\n")
+		b.WriteString(g.Stack)
+	}
+	return b.String()
+}
+
+// Check returns a *GoroutineLeakError when goroutines started since the
+// snapshot are still running after the retries, and nil otherwise.
+func (s *GoroutineSnapshot) Check(opts ...LeakOption) error {
+	cfg := leakConfig{attempts: 8, backoff: 5 * time.Millisecond}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	ignores := append(append([]string(nil), defaultLeakIgnores...), cfg.ignores...)
+
+	backoff := cfg.backoff
+	var leaks []GoroutineInfo
+	for attempt := 0; ; attempt++ {
+		leaks = s.leaks(ignores)
+		if len(leaks) == 0 {
+			return nil
+		}
+		if attempt >= cfg.attempts-1 {
+			break
+		}
+		time.Sleep(backoff)
+		if backoff < 200*time.Millisecond {
+			backoff *= 2
+		}
+	}
+	return &GoroutineLeakError{Leaks: leaks}
+}
+
+func (s *GoroutineSnapshot) leaks(ignores []string) []GoroutineInfo {
+	self := currentGoroutineID()
+	var out []GoroutineInfo
+	for _, g := range allGoroutines() {
+		if g.ID == self || s.ids[g.ID] || isExpectedGoroutine(g.ID) {
+			continue
+		}
+		if containsAny(g.Stack, ignores) {
+			continue
+		}
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// VerifyNoLeaks snapshots the running goroutines and registers a cleanup
+// that fails t if goroutines started during the test are still running
+// once every other cleanup has run. Call it first in the test:
+//
+//	func TestWatcher(t *testing.T) {
+//		testutils.VerifyNoLeaks(t)
+//		...
+//	}
+func VerifyNoLeaks(t testing.TB, opts ...LeakOption) {
+	t.Helper()
+	snap := SnapshotGoroutines()
+	t.Cleanup(func() {
+		if err := snap.Check(opts...); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+// allGoroutines parses a full runtime stack dump.
+func allGoroutines() []GoroutineInfo {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	var out []GoroutineInfo
+	for _, block := range bytes.Split(bytes.TrimSpace(buf), []byte("\n\n")) {
+		if g, ok := parseGoroutineHeader(string(block)); ok {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// parseGoroutineHeader reads "goroutine 18 [chan receive, 2 minutes]:".
+func parseGoroutineHeader(block string) (GoroutineInfo, bool) {
+	header, _, _ := strings.Cut(block, "\n")
+	rest, ok := strings.CutPrefix(header, "goroutine ")
+	if !ok {
+		return GoroutineInfo{}, false
+	}
+	idStr, state, _ := strings.Cut(rest, " ")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return GoroutineInfo{}, false
+	}
+	state = strings.TrimSuffix(strings.TrimPrefix(state, "["), "]:")
+	return GoroutineInfo{ID: id, State: state, Stack: block}, true
+}
+
+// currentGoroutineID returns the ID of the calling goroutine.
+func currentGoroutineID() int64 {
+	var buf [64]byte
+	g, _ := parseGoroutineHeader(string(buf[:runtime.Stack(buf[:], false)]))
+	return g.ID
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if f != "" && strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package testutils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakyComponent starts a goroutine that Stop only ends when stopWorker is
+// set, so the registry leak check has something to find.
+type leakyComponent struct {
+	stopWorker bool
+	quit       chan struct{}
+}
+
+func (c *leakyComponent) Name() string { return "leaky" }
+func (c *leakyComponent) Start() error {
+	c.quit = make(chan struct{})
+	go func() { <-c.quit }()
+	return nil
+}
+func (c *leakyComponent) Stop() error {
+	if c.stopWorker {
+		close(c.quit)
+	}
+	return nil
+}
+func (c *leakyComponent) Status() (string, error)                { return "running", nil }
+func (c *leakyComponent) Health() (bool, error)                  { return true, nil }
+func (c *leakyComponent) Stats() (map[string]interface{}, error) { return nil, nil }
+
+func fastRetry() LeakOption { return WithLeakRetry(3, time.Millisecond) }
+
+func TestGoroutineSnapshot_DetectsLeak(t *testing.T) {
+	snap := SnapshotGoroutines()
+	block := make(chan struct{})
+	defer close(block)
+	go func() { <-block }()
+
+	err := snap.Check(fastRetry())
+	var leak *GoroutineLeakError
+	if !errors.As(err, &leak) || len(leak.Leaks) != 1 {
+		t.Fatalf("expected one leak, got %v", err)
+	}
+	if g := leak.Leaks[0]; g.State != "chan receive" || !strings.Contains(g.Stack, "TestGoroutineSnapshot_DetectsLeak") {
+		t.Errorf("leak %+v", g)
+	}
+}
+
+func TestGoroutineSnapshot_WaitsForShutdown(t *testing.T) {
+	snap := SnapshotGoroutines()
+	go time.Sleep(20 * time.Millisecond)
+	if err := snap.Check(); err != nil {
+		t.Errorf("goroutine exiting within the retries reported: %v", err)
+	}
+}
+
+func TestGoroutineSnapshot_Ignores(t *testing.T) {
+	snap := SnapshotGoroutines()
+	block := make(chan struct{})
+	defer close(block)
+	go parkForLeakTest(block)
+	if err := snap.Check(fastRetry(), IgnoreGoroutines("parkForLeakTest")); err != nil {
+		t.Errorf("ignored goroutine reported: %v", err)
+	}
+
+	marked := make(chan struct{})
+	go func() {
+		defer markExpectedGoroutine()()
+		close(marked)
+		<-block
+	}()
+	<-marked
+	if err := snap.Check(fastRetry(), IgnoreGoroutines("parkForLeakTest")); err != nil {
+		t.Errorf("expected goroutine reported: %v", err)
+	}
+}
+
+func parkForLeakTest(block chan struct{}) { <-block }
+
+func TestVerifyNoLeaks(t *testing.T) {
+	VerifyNoLeaks(t)
+	done := make(chan struct{})
+	go func() { close(done) }()
+	<-done
+}
+
+func TestComponentRegistry_StopAllLeakCheck(t *testing.T) {
+	for _, stop := range []bool{true, false} {
+		snap := SnapshotGoroutines()
+		c := &leakyComponent{stopWorker: stop}
+		reg := NewComponentRegistry()
+		reg.MustRegister(c)
+		if err := reg.StartAll(); err != nil {
+			t.Fatal(err)
+		}
+		err := reg.StopAll(WithLeakCheck(snap, fastRetry()))
+		if stop && err != nil {
+			t.Errorf("clean stop reported: %v", err)
+		}
+		if !stop {
+			if err == nil || !strings.Contains(err.Error(), "leaked goroutine") {
+				t.Errorf("leak not reported: %v", err)
+			}
+			close(c.quit)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package testutils
+
+import "errors"
+
+// setLinger is not implemented on this platform; dials with
+// PortCheckerConfig.Linger set fail.
+func setLinger(fd uintptr, sec int) error {
+	return errors.New("not supported on this platform")
+}
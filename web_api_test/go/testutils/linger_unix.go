@@ -0,0 +1,11 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package testutils
+
+import "syscall"
+
+// setLinger sets SO_LINGER on the socket fd to sec seconds.
+func setLinger(fd uintptr, sec int) error {
+	return syscall.SetsockoptLinger(int(fd), syscall.SOL_SOCKET, syscall.SO_LINGER, &syscall.Linger{Onoff: 1, Linger: int32(sec)})
+}
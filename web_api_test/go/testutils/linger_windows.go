@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package testutils
+
+import "syscall"
+
+// setLinger sets SO_LINGER on the socket fd to sec seconds.
+func setLinger(fd uintptr, sec int) error {
+	return syscall.SetsockoptLinger(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_LINGER, &syscall.Linger{Onoff: 1, Linger: int32(sec)})
+}
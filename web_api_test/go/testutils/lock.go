@@ -21,7 +21,8 @@ import (
 // --------------------------------------------------------------------
 
 // ErrTimeout is returned by TryLockTimeout when the lock cannot be acquired
-// within the specified duration.
+// within the specified duration. It is also the sentinel of KindTimeout, so
+// every timeout error in the package matches it with errors.Is.
 var ErrTimeout = errors.New("lock: timeout acquiring mutex")
 
 // Mutex is a mutual exclusion lock that extends sync.Mutex with TryLock
@@ -0,0 +1,407 @@
+package testutils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exporter ships batches of log entries to an external sink (a file, Loki,
+// an OTLP collector, ...). Export is called from a single background
+// goroutine, never from the logging hot path.
+type Exporter interface {
+	Export(entries []LogEntry) error
+}
+
+// ExportOptions tunes the batching dispatcher attached by WithExporter.
+type ExportOptions struct {
+	BatchSize     int           // flush when this many entries are buffered
+	FlushInterval time.Duration // flush at least this often
+	QueueSize     int           // entries beyond this are dropped, not blocked on
+}
+
+// DefaultExportOptions returns reasonable batching defaults.
+func DefaultExportOptions() ExportOptions {
+	return ExportOptions{
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		QueueSize:     10000,
+	}
+}
+
+// ExportStats reports dispatcher health.
+type ExportStats struct {
+	Exported uint64 `json:"exported"`
+	Dropped  uint64 `json:"dropped"`
+	Failed   uint64 `json:"failed"` // entries in batches the exporter rejected
+	LastErr  string `json:"last_error,omitempty"`
+}
+
+// WithExporter attaches an exporter to the logger. Entries are queued without
+// blocking and shipped in batches on size, interval, Flush or Close.
+func WithExporter(exp Exporter, opts ExportOptions) LoggerOption {
+	return func(l *TestLogger) {
+		l.dispatcher = newLogDispatcher(exp, opts)
+	}
+}
+
+// Flush blocks until every entry logged so far has been written by
+// WithAsyncOutput and handed to the exporter. It is a no-op when neither
+// is in use.
+func (l *TestLogger) Flush() error {
+	if l.async != nil {
+		l.async.flush()
+	}
+	if l.dispatcher == nil {
+		return nil
+	}
+	return l.dispatcher.flush()
+}
+
+// Close flushes pending entries and stops the async writer and the
+// exporter. Call it from TestMain to guarantee delivery before the process
+// exits. Loggers derived with WithField share both, so closing any of them
+// closes them for all.
+func (l *TestLogger) Close() error {
+	if l.async != nil {
+		l.async.close()
+	}
+	if l.dispatcher == nil {
+		return nil
+	}
+	return l.dispatcher.close()
+}
+
+// ExportStats returns counters for the attached exporter.
+func (l *TestLogger) ExportStats() ExportStats {
+	if l.dispatcher == nil {
+		return ExportStats{}
+	}
+	return l.dispatcher.stats()
+}
+
+// --------------------------------------------------------------------
+// logDispatcher – batching queue between TestLogger and an Exporter
+// --------------------------------------------------------------------
+
+type logDispatcher struct {
+	exporter Exporter
+	opts     ExportOptions
+	queue    chan LogEntry
+	flushReq chan chan error
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+
+	// mu guards closed: enqueue holds it shared while sending, so no entry
+	// lands in the queue after close has drained it.
+	mu     sync.RWMutex
+	closed bool
+
+	exported atomic.Uint64
+	dropped  atomic.Uint64
+	failed   atomic.Uint64
+	lastErr  atomic.Value // string
+}
+
+func newLogDispatcher(exp Exporter, opts ExportOptions) *logDispatcher {
+	def := DefaultExportOptions()
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = def.BatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = def.FlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = def.QueueSize
+	}
+	d := &logDispatcher{
+		exporter: exp,
+		opts:     opts,
+		queue:    make(chan LogEntry, opts.QueueSize),
+		flushReq: make(chan chan error),
+		done:     make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// enqueue never blocks; when the queue is full or the dispatcher is closed
+// the entry is counted and dropped.
+func (d *logDispatcher) enqueue(entry LogEntry) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		d.dropped.Add(1)
+		return
+	}
+	select {
+	case d.queue <- entry:
+	default:
+		d.dropped.Add(1)
+	}
+}
+
+func (d *logDispatcher) run() {
+	defer d.wg.Done()
+	// The dispatcher is shared by every derived logger and closed from
+	// TestMain, so it is not a leak of the test that created it.
+	defer markExpectedGoroutine()()
+	ticker := time.NewTicker(d.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LogEntry, 0, d.opts.BatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := d.export(batch)
+		batch = make([]LogEntry, 0, d.opts.BatchSize)
+		return err
+	}
+	drain := func() {
+		for {
+			select {
+			case e := <-d.queue:
+				batch = append(batch, e)
+				if len(batch) >= d.opts.BatchSize {
+					send()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-d.queue:
+			batch = append(batch, e)
+			if len(batch) >= d.opts.BatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case reply := <-d.flushReq:
+			drain()
+			reply <- send()
+		case <-d.done:
+			drain()
+			send()
+			return
+		}
+	}
+}
+
+// export calls the exporter, converting panics into errors so a faulty
+// exporter cannot take the logger down.
+func (d *logDispatcher) export(batch []LogEntry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("log exporter panicked: %v", r)
+		}
+		if err != nil {
+			d.failed.Add(uint64(len(batch)))
+			d.lastErr.Store(err.Error())
+		} else {
+			d.exported.Add(uint64(len(batch)))
+		}
+	}()
+	return d.exporter.Export(batch)
+}
+
+func (d *logDispatcher) flush() error {
+	d.mu.RLock()
+	closed := d.closed
+	d.mu.RUnlock()
+	if closed {
+		return nil
+	}
+	reply := make(chan error, 1)
+	select {
+	case d.flushReq <- reply:
+		return <-reply
+	case <-d.done:
+		return nil
+	}
+}
+
+func (d *logDispatcher) close() error {
+	var err error
+	d.once.Do(func() {
+		d.mu.Lock()
+		d.closed = true
+		d.mu.Unlock()
+		close(d.done)
+		d.wg.Wait()
+		if c, ok := d.exporter.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
+
+func (d *logDispatcher) stats() ExportStats {
+	s := ExportStats{
+		Exported: d.exported.Load(),
+		Dropped:  d.dropped.Load(),
+		Failed:   d.failed.Load(),
+	}
+	if v, ok := d.lastErr.Load().(string); ok {
+		s.LastErr = v
+	}
+	return s
+}
+
+// --------------------------------------------------------------------
+// JSONLinesExporter – appends one JSON object per line to a file
+// --------------------------------------------------------------------
+
+// JSONLinesExporter writes entries as JSON lines, suitable for promtail or
+// any agent that tails files.
+type JSONLinesExporter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewJSONLinesExporter opens (or creates) path for appending.
+func NewJSONLinesExporter(path string) (*JSONLinesExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log export file: %w", err)
+	}
+	return &JSONLinesExporter{file: f}, nil
+}
+
+// Export writes the batch and syncs the file.
+func (e *JSONLinesExporter) Export(entries []LogEntry) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	w := bufio.NewWriter(e.file)
+	enc := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode log entry: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return e.file.Sync()
+}
+
+// Close closes the underlying file.
+func (e *JSONLinesExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.file.Close()
+}
+
+// --------------------------------------------------------------------
+// HTTPExporter – POSTs batches to an HTTP endpoint with gzip and retry
+// --------------------------------------------------------------------
+
+// HTTPExporter POSTs each batch as a JSON array. Failed requests are retried
+// following Retry (Attempts, InitialDelay, Multiplier, MaxDelay,
+// JitterFactor); 4xx responses other than 429 are not retried.
+type HTTPExporter struct {
+	URL     string
+	Client  *http.Client
+	Headers http.Header
+	Gzip    bool
+	Retry   RetryConfig
+	Clock   Clock
+}
+
+// NewHTTPExporter creates an exporter using the default retry configuration.
+func NewHTTPExporter(url string) *HTTPExporter {
+	return &HTTPExporter{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Gzip:   true,
+		Retry:  DefaultConfig().Retry,
+		Clock:  RealClock{},
+	}
+}
+
+// errPermanent marks responses that must not be retried.
+var errPermanent = errors.New("permanent export failure")
+
+// Export sends the batch, retrying transient failures.
+func (e *HTTPExporter) Export(entries []LogEntry) error {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to marshal log batch: %w", err)
+	}
+	if e.Gzip {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	attempts := e.Retry.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			sleepWith(e.Clock, e.Retry.Backoff(attempt))
+		}
+		lastErr = e.send(body)
+		if lastErr == nil || errors.Is(lastErr, errPermanent) {
+			return lastErr
+		}
+	}
+	return fmt.Errorf("log export failed after %d attempts: %w", attempts, lastErr)
+}
+
+func (e *HTTPExporter) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	for k, v := range e.Headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("log export endpoint returned %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("%w: endpoint returned %d", errPermanent, resp.StatusCode)
+	}
+}
@@ -0,0 +1,224 @@
+package testutils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingExporter keeps every batch it is handed.
+type recordingExporter struct {
+	mu      sync.Mutex
+	batches [][]LogEntry
+	closed  bool
+}
+
+func (e *recordingExporter) Export(entries []LogEntry) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, append([]LogEntry(nil), entries...))
+	return nil
+}
+
+func (e *recordingExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closed = true
+	return nil
+}
+
+func (e *recordingExporter) sizes() []int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var out []int
+	for _, b := range e.batches {
+		out = append(out, len(b))
+	}
+	return out
+}
+
+func TestLogDispatcher_BatchesAndFlushesOnClose(t *testing.T) {
+	exp := &recordingExporter{}
+	logger := NewTestLogger("export", io.Discard, WithExporter(exp, ExportOptions{BatchSize: 3, FlushInterval: time.Hour}))
+
+	for i := 0; i < 7; i++ {
+		logger.Info("entry", map[string]any{"i": i})
+	}
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := exp.sizes(); len(got) != 3 || got[0] != 3 || got[1] != 3 || got[2] != 1 {
+		t.Errorf("batch sizes %v, want [3 3 1]", got)
+	}
+	if !exp.closed {
+		t.Error("Close did not close the exporter")
+	}
+
+	logger.Info("late", nil)
+	if err := logger.Flush(); err != nil {
+		t.Errorf("Flush after Close: %v", err)
+	}
+	if s := logger.ExportStats(); s.Exported != 7 || s.Dropped != 1 {
+		t.Errorf("stats %+v, want 7 exported and the late entry dropped", s)
+	}
+}
+
+func TestLogDispatcher_EnqueueRacesClose(t *testing.T) {
+	for round := 0; round < 5; round++ {
+		exp := &recordingExporter{}
+		logger := NewTestLogger("export", io.Discard, WithExporter(exp, ExportOptions{BatchSize: 16, FlushInterval: time.Hour}))
+
+		var logged atomic.Uint64
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					logger.Info("entry", nil)
+					logged.Add(1)
+				}
+			}()
+		}
+		for logged.Load() < 100 {
+			time.Sleep(10 * time.Microsecond)
+		}
+		logger.Close()
+		close(stop)
+		wg.Wait()
+
+		total := 0
+		for _, n := range exp.sizes() {
+			total += n
+		}
+		s := logger.ExportStats()
+		if s.Exported != uint64(total) || s.Exported+s.Dropped != logged.Load() {
+			t.Fatalf("round %d: %d logged, stats %+v, %d received; entries went missing", round, logged.Load(), s, total)
+		}
+	}
+}
+
+func TestLogDispatcher_ExporterFailures(t *testing.T) {
+	logger := NewTestLogger("export", io.Discard, WithExporter(
+		exporterFunc(func([]LogEntry) error { panic("boom") }), ExportOptions{}))
+	logger.Info("one", nil)
+	logger.Info("two", nil)
+	if err := logger.Flush(); err == nil {
+		t.Error("Flush hid the exporter failure")
+	}
+	logger.Close()
+	if s := logger.ExportStats(); s.Failed != 2 || s.LastErr != "log exporter panicked: boom" {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+type exporterFunc func([]LogEntry) error
+
+func (f exporterFunc) Export(entries []LogEntry) error { return f(entries) }
+
+func TestJSONLinesExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+	exp, err := NewJSONLinesExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewTestLogger("jsonl", io.Discard, WithExporter(exp, ExportOptions{FlushInterval: time.Hour}))
+	logger.Info("first", map[string]any{"n": 1})
+	logger.Warn("second", nil)
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := logLines(t, string(data))
+	if len(entries) != 2 || entries[0].Message != "first" || entries[1].Level != WARN || entries[0].TestID != "jsonl" {
+		t.Errorf("exported %+v", entries)
+	}
+	if err := exp.Export(entries); err == nil {
+		t.Error("Export after Close wrote to a closed file")
+	}
+	if _, err := NewJSONLinesExporter(filepath.Join(t.TempDir(), "missing", "logs.jsonl")); err == nil {
+		t.Error("opening a file in a missing directory succeeded")
+	}
+}
+
+func TestHTTPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		received []LogEntry
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Encoding") != "gzip" || r.Header.Get("X-Scope-OrgID") != "tests" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err == nil {
+			err = json.NewDecoder(zr).Decode(&received)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	exp := NewHTTPExporter(srv.URL)
+	exp.Headers = http.Header{"X-Scope-OrgID": {"tests"}}
+	exp.Retry.InitialDelay = time.Millisecond
+	logger := NewTestLogger("http", io.Discard, WithExporter(exp, ExportOptions{FlushInterval: time.Hour}))
+	logger.Info("shipped", nil)
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 || len(received) != 1 || received[0].Message != "shipped" {
+		t.Errorf("%d requests, received %+v; want a retry after the 503", requests, received)
+	}
+	if s := logger.ExportStats(); s.Exported != 1 || s.Failed != 0 {
+		t.Errorf("stats %+v", s)
+	}
+}
+
+func TestHTTPExporter_PermanentFailure(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	exp := NewHTTPExporter(srv.URL)
+	exp.Gzip = false
+	err := exp.Export([]LogEntry{{Message: "denied"}})
+	if !errors.Is(err, errPermanent) || requests.Load() != 1 {
+		t.Errorf("a 401 must fail without retry: %v after %d requests", err, requests.Load())
+	}
+}
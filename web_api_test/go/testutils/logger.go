@@ -7,11 +7,9 @@ import (
     "fmt"
     "io"
     "math/rand"
-    "net"
     "os"
     "path/filepath"
     "runtime"
-    "sort"
     "strconv"
     "strings"
     "sync"
@@ -47,6 +45,8 @@ type PortCheckConfig struct {
 }
 
 // PortCheckResult represents the result of a port checking operation
+//
+// Deprecated: use ConnectionResult from PortChecker.
 type PortCheckResult struct {
     Port          int           `json:"port"`
     Protocol      string        `json:"protocol"`
@@ -61,6 +61,8 @@ type PortCheckResult struct {
 }
 
 // PortRangeCheckResult represents the result of checking a range of ports
+//
+// Deprecated: use PortRangeResult from PortChecker.
 type PortRangeCheckResult struct {
     StartPort    int               `json:"start_port"`
     EndPort      int               `json:"end_port"`
@@ -87,6 +89,21 @@ type TestLogger struct {
     sequence    atomic.Uint64
     portChecks  []PortCheckResult
     rangeChecks []PortRangeCheckResult
+    dispatcher  *logDispatcher  // shared with derived loggers; nil when no exporter
+    portChecker *PortChecker    // backs CheckPort and friends; nil builds one per call
+    correlation *logCorrelation // set by CorrelateLogs; shared with derived loggers
+    sinks       []*countedSink  // see logger_sinks.go; replaces output when set
+
+    maxFieldBytes int // see logger_fields.go; <= 0 disables truncation
+
+    capture *logCapture  // see logger_capture.go; shared with derived loggers
+    async   *asyncOutput // see logger_async.go; shared with derived loggers
+
+    // Text formatting (see logger_format.go)
+    colorMode       ColorMode
+    hideTimestamp   bool
+    timestampFormat string
+    showCaller      bool
 }
 
 // LoggerOption configures TestLogger behavior
@@ -104,6 +121,9 @@ func NewTestLogger(testID string, output io.Writer, opts ...LoggerOption) *TestL
         jsonOutput: false,
         fields:     make(map[string]any),
         callerSkip: 3,
+
+        maxFieldBytes:   DefaultMaxFieldBytes,
+        timestampFormat: defaultTimestampFormat,
     }
 
     for _, opt := range opts {
@@ -152,183 +172,165 @@ func WithLevel(level LogLevel) LoggerOption {
 }
 
 // Port checking methods
-
-// CheckPort performs a single port check with detailed logging
-func (l *TestLogger) CheckPort(ctx context.Context, host string, port int, config PortCheckConfig) (PortCheckResult, error) {
-    startTime := time.Now()
-    result := PortCheckResult{
-        Port:      port,
-        Protocol:  config.Protocol,
-        CheckedAt: startTime,
+//
+// These predate PortChecker and are kept as adapters: every check is
+// delegated to a *PortChecker and converted back to the legacy result types.
+
+// WithPortChecker makes the logger's port-check methods delegate to pc. The
+// checker's own configuration (timeouts, retries, IP version) then governs
+// every check and PortCheckConfig only supplies the protocol.
+func WithPortChecker(pc *PortChecker) LoggerOption {
+    return func(l *TestLogger) {
+        l.portChecker = pc
+    }
+}
+
+// portCheckerConfig maps the legacy settings onto PortCheckerConfig. The
+// legacy retry delay is constant, so BackoffFactor is 1. CheckAll is not
+// deterministic mode: it only decides whether the adapters return per-port
+// results and scan in order, so per-port stats are always collected.
+func (c PortCheckConfig) portCheckerConfig() PortCheckerConfig {
+    protocol, _ := ParseProtocol(c.Protocol) // unknown protocols fall back to TCP
+    return PortCheckerConfig{
+        Protocol:      protocol,
+        IPVersion:     parseLegacyIPVersion(c.IPVersion),
+        DialTimeout:   c.Timeout,
+        RetryInterval: c.RetryDelay,
+        BackoffFactor: 1,
+        JitterEnabled: c.JitterEnabled,
+        Workers:       runtime.NumCPU(),
+        ValidatePorts: true,
+    }
+}
+
+// parseLegacyIPVersion accepts "ipv4"/"4" and "ipv6"/"6"; anything else
+// means no preference.
+func parseLegacyIPVersion(s string) IPVersion {
+    switch strings.ToLower(strings.TrimSpace(s)) {
+    case "ipv4", "v4", "4":
+        return IPv4
+    case "ipv6", "v6", "6":
+        return IPv6
+    default:
+        return AnyIP
     }
+}
 
-    // Build network address based on protocol and IP version
-    network, address := l.buildNetworkAddress(host, port, config)
-    result.Network = network
-    result.Address = address
-
-    l.Debug("starting port check", map[string]any{
-        "host":       host,
-        "port":       port,
-        "protocol":   config.Protocol,
-        "ip_version": config.IPVersion,
-        "timeout":    config.Timeout,
-    })
+// portCheckerFor returns the injected checker, or one built from config.
+func (l *TestLogger) portCheckerFor(config PortCheckConfig) (*PortChecker, Protocol) {
+    protocol, _ := ParseProtocol(config.Protocol)
+    if l.portChecker != nil {
+        return l.portChecker, protocol
+    }
+    pc := NewPortChecker(l, config.portCheckerConfig())
+    // withDefaults treats zero retries as unset, but the legacy API allows a
+    // single attempt.
+    pc.config.MaxRetries = config.RetryCount
+    if pc.config.MaxRetries < 0 {
+        pc.config.MaxRetries = 0
+    }
+    return pc, protocol
+}
 
-    var lastErr error
-    for attempt := 0; attempt <= config.RetryCount; attempt++ {
-        select {
-        case <-ctx.Done():
-            result.Error = ctx.Err().Error()
-            l.logPortCheck(result, attempt)
-            return result, ctx.Err()
-        default:
-            attemptStart := time.Now()
-            conn, err := net.DialTimeout(network, address, config.Timeout)
-            latency := time.Since(attemptStart)
-
-            if err == nil {
-                conn.Close()
-                result.Success = true
-                result.Latency = latency
-                result.RetryCount = attempt
-                l.logPortCheck(result, attempt)
-                return result, nil
-            }
+// toPortCheckResult converts a PortChecker result to the legacy type.
+func toPortCheckResult(pc *PortChecker, r *ConnectionResult, checkedAt time.Time) PortCheckResult {
+    network, _ := pc.buildNetworkAddress(r.Host, strconv.Itoa(r.Port), r.Protocol, pc.config.IPVersion)
+    retries := r.Attempts - 1
+    if retries < 0 {
+        retries = 0
+    }
+    return PortCheckResult{
+        Port:          r.Port,
+        Protocol:      string(r.Protocol),
+        Network:       network,
+        Address:       r.Address,
+        Success:       r.Open,
+        Error:         r.Error,
+        Latency:       r.Latency,
+        RetryCount:    retries,
+        CheckedAt:     checkedAt,
+        Deterministic: r.Deterministic,
+    }
+}
 
-            lastErr = err
+// CheckPort performs a single port check with detailed logging.
+//
+// Deprecated: use PortChecker.IsPortOpen, which returns a ConnectionResult.
+func (l *TestLogger) CheckPort(ctx context.Context, host string, port int, config PortCheckConfig) (PortCheckResult, error) {
+    pc, protocol := l.portCheckerFor(config)
+    checkedAt := pc.now()
+
+    r, err := pc.IsPortOpen(ctx, host, port, protocol)
+    if r == nil {
+        // Rejected before dialing (e.g. port outside the allowed range).
+        network, address := pc.buildNetworkAddress(host, strconv.Itoa(port), protocol, pc.config.IPVersion)
+        result := PortCheckResult{
+            Port:      port,
+            Protocol:  string(protocol),
+            Network:   network,
+            Address:   address,
+            CheckedAt: checkedAt,
+        }
+        if err != nil {
             result.Error = err.Error()
-            result.Latency = latency
-
-            if attempt < config.RetryCount {
-                delay := config.RetryDelay
-                if config.JitterEnabled {
-                    // Add ±25% jitter
-                    jitter := time.Duration(float64(delay) * 0.25)
-                    delay += time.Duration(float64(jitter) * (2*float64(time.Now().UnixNano()%100)/100 - 1))
-                }
-                l.Debug("port check failed, retrying", map[string]any{
-                    "port":        port,
-                    "attempt":     attempt + 1,
-                    "max_retries": config.RetryCount,
-                    "delay":       delay,
-                    "error":       err.Error(),
-                })
-                time.Sleep(delay)
-            }
         }
+        l.logPortCheck(result, 0)
+        return result, err
     }
 
-    l.logPortCheck(result, config.RetryCount)
-    return result, lastErr
+    result := toPortCheckResult(pc, r, checkedAt)
+    l.logPortCheck(result, result.RetryCount)
+    return result, err
 }
 
-// CheckPortRange checks a range of ports with deterministic results
+// CheckPortRange checks a range of ports with results in port order.
+//
+// Deprecated: use PortChecker.CheckPortRange, which returns a PortRangeResult.
 func (l *TestLogger) CheckPortRange(ctx context.Context, host string, startPort, endPort int, config PortCheckConfig) (PortRangeCheckResult, error) {
-    if startPort > endPort {
-        startPort, endPort = endPort, startPort
-    }
+    pc, protocol := l.portCheckerFor(config)
+    checkedAt := pc.now()
 
-    startTime := time.Now()
-    result := PortRangeCheckResult{
-        StartPort:  startPort,
-        EndPort:    endPort,
-        Protocol:   config.Protocol,
-        IPVersion:  config.IPVersion,
-        TotalPorts: endPort - startPort + 1,
+    r, err := pc.CheckPortRange(ctx, host, startPort, endPort, protocol)
+    if r == nil {
+        return PortRangeCheckResult{}, err
     }
 
-    l.Info("starting port range check", map[string]any{
-        "host":       host,
-        "start_port": startPort,
-        "end_port":   endPort,
-        "total":      result.TotalPorts,
-        "protocol":   config.Protocol,
-        "check_all":  config.CheckAll,
-    })
-
-    // Use a worker pool for concurrent checks
-    workerCount := runtime.NumCPU()
-    if workerCount > result.TotalPorts {
-        workerCount = result.TotalPorts
-    }
-
-    ports := make(chan int, result.TotalPorts)
-    results := make(chan PortCheckResult, result.TotalPorts)
-
-    // Start workers
-    var wg sync.WaitGroup
-    for i := 0; i < workerCount; i++ {
-        wg.Add(1)
-        go func(workerID int) {
-            defer wg.Done()
-            for port := range ports {
-                select {
-                case <-ctx.Done():
-                    return
-                default:
-                    portResult, _ := l.CheckPort(ctx, host, port, config)
-                    portResult.Deterministic = true // Mark as deterministic
-                    results <- portResult
-                }
-            }
-        }(i)
-    }
-
-    // Send ports to workers
-    for port := startPort; port <= endPort; port++ {
-        ports <- port
-    }
-    close(ports)
-
-    // Collect results
-    go func() {
-        wg.Wait()
-        close(results)
-    }()
-
-    var perPortStats []PortCheckResult
-    for portResult := range results {
-        perPortStats = append(perPortStats, portResult)
-        if portResult.Success {
-            result.OpenPorts = append(result.OpenPorts, portResult.Port)
-            result.SuccessCount++
-        } else {
-            result.ClosedPorts = append(result.ClosedPorts, portResult.Port)
-            result.FailureCount++
+    result := PortRangeCheckResult{
+        StartPort:    r.StartPort,
+        EndPort:      r.EndPort,
+        Protocol:     string(r.Protocol),
+        IPVersion:    config.IPVersion,
+        TotalPorts:   r.TotalPorts,
+        OpenPorts:    r.OpenPorts,
+        ClosedPorts:  r.ClosedPorts,
+        SuccessCount: r.SuccessCount,
+        FailureCount: r.FailureCount,
+        Duration:     r.Duration,
+    }
+
+    var perPort []PortCheckResult
+    for _, stat := range r.PerPortStats {
+        if stat != nil {
+            perPort = append(perPort, toPortCheckResult(pc, stat, checkedAt))
         }
     }
-
-    // Sort results for deterministic output
-    sort.Ints(result.OpenPorts)
-    sort.Ints(result.ClosedPorts)
-    sort.Slice(perPortStats, func(i, j int) bool {
-        return perPortStats[i].Port < perPortStats[j].Port
-    })
-
     if config.CheckAll {
-        result.PerPortStats = perPortStats
+        result.PerPortStats = perPort
     }
 
-    result.Duration = time.Since(startTime)
-
-    // Log summary
-    l.Info("port range check completed", map[string]any{
-        "start_port":    startPort,
-        "end_port":      endPort,
-        "total_ports":   result.TotalPorts,
-        "open_ports":    result.OpenPorts,
-        "open_count":    result.SuccessCount,
-        "closed_count":  result.FailureCount,
-        "duration":      result.Duration,
-        "deterministic": true,
-    })
-
+    l.mu.Lock()
+    l.portChecks = append(l.portChecks, perPort...)
     l.rangeChecks = append(l.rangeChecks, result)
-    return result, nil
+    l.mu.Unlock()
+    return result, err
 }
 
-// WaitForAnyPort waits for any port in a range to become available
+// WaitForAnyPort makes one pass over a range and returns the first open
+// port. Ports are tried in ascending order when CheckAll is set and in
+// random order otherwise.
+//
+// Deprecated: use PortChecker.WaitForAnyPort, which keeps polling until
+// WaitTimeout.
 func (l *TestLogger) WaitForAnyPort(ctx context.Context, host string, startPort, endPort int, config PortCheckConfig) (PortCheckResult, error) {
     l.Info("waiting for any port to become available", map[string]any{
         "host":       host,
@@ -337,12 +339,13 @@ func (l *TestLogger) WaitForAnyPort(ctx context.Context, host string, startPort,
         "protocol":   config.Protocol,
     })
 
+    if startPort > endPort {
+        startPort, endPort = endPort, startPort
+    }
     ports := make([]int, endPort-startPort+1)
     for i := range ports {
         ports[i] = startPort + i
     }
-
-    // Shuffle ports for better distribution if not deterministic
     if !config.CheckAll {
         rand.Shuffle(len(ports), func(i, j int) {
             ports[i], ports[j] = ports[j], ports[i]
@@ -350,20 +353,18 @@ func (l *TestLogger) WaitForAnyPort(ctx context.Context, host string, startPort,
     }
 
     for _, port := range ports {
-        select {
-        case <-ctx.Done():
-            return PortCheckResult{}, ctx.Err()
-        default:
-            result, err := l.CheckPort(ctx, host, port, config)
-            if err == nil && result.Success {
-                l.Info("found available port", map[string]any{
-                    "port":     port,
-                    "host":     host,
-                    "protocol": config.Protocol,
-                    "latency":  result.Latency,
-                })
-                return result, nil
-            }
+        if err := ctx.Err(); err != nil {
+            return PortCheckResult{}, err
+        }
+        result, err := l.CheckPort(ctx, host, port, config)
+        if err == nil && result.Success {
+            l.Info("found available port", map[string]any{
+                "port":     port,
+                "host":     host,
+                "protocol": config.Protocol,
+                "latency":  result.Latency,
+            })
+            return result, nil
         }
     }
 
@@ -403,29 +404,6 @@ func (l *TestLogger) LogPortStats() {
 
 // Helper methods
 
-func (l *TestLogger) buildNetworkAddress(host string, port int, config PortCheckConfig) (string, string) {
-    var network, address string
-
-    switch strings.ToLower(config.Protocol) {
-    case "tcp", "tcp4", "tcp6":
-        network = config.Protocol
-    case "udp", "udp4", "udp6":
-        network = config.Protocol
-    default:
-        network = "tcp"
-    }
-
-    // Handle IPv4/IPv6
-    if strings.HasSuffix(network, "4") && !strings.Contains(host, ":") {
-        host = host + ":v4"
-    } else if strings.HasSuffix(network, "6") && !strings.Contains(host, ":") {
-        host = "[" + host + "]:v6"
-    }
-
-    address = net.JoinHostPort(host, strconv.Itoa(port))
-    return network, address
-}
-
 func (l *TestLogger) logPortCheck(result PortCheckResult, retryCount int) {
     fields := map[string]any{
         "port":        result.Port,
@@ -504,6 +482,19 @@ func (l *TestLogger) clone() *TestLogger {
         fields:     fields,
         callerSkip: l.callerSkip,
         sequence:   atomic.Uint64{},
+        dispatcher: l.dispatcher,
+
+        portChecker:   l.portChecker,
+        correlation:   l.correlation,
+        sinks:         l.sinks,
+        maxFieldBytes: l.maxFieldBytes,
+        capture:       l.capture,
+        async:         l.async,
+
+        colorMode:       l.colorMode,
+        hideTimestamp:   l.hideTimestamp,
+        timestampFormat: l.timestampFormat,
+        showCaller:      l.showCaller,
     }
 }
 
@@ -538,45 +529,63 @@ func (l *TestLogger) log(level LogLevel, msg string, fields map[string]any) {
     }
 
     l.writeEntry(entry)
+    if l.capture != nil {
+        l.capture.add(entry)
+    }
+    if l.dispatcher != nil {
+        exported := entry
+        exported.Fields = l.jsonFields(entry.Fields)
+        l.dispatcher.enqueue(exported)
+    }
 }
 
 func (l *TestLogger) writeEntry(entry LogEntry) {
+    if l.async != nil && l.async.enqueue(l, entry) {
+        return
+    }
+    l.writeEntryNow(entry)
+}
+
+// writeEntryNow writes entry to the sinks or output from the calling
+// goroutine.
+func (l *TestLogger) writeEntryNow(entry LogEntry) {
+    if len(l.sinks) > 0 {
+        l.writeSinks(entry)
+        return
+    }
+    output := l.formatEntry(entry, l.jsonOutput, l.output)
+
+    // The lock is not held across the write, so a slow output never
+    // stalls callers that take it to record history.
+    l.mu.RLock()
+    out := l.output
+    l.mu.RUnlock()
+    if out != nil {
+        // One write per entry, so concurrent entries never interleave.
+        io.WriteString(out, output)
+    }
+}
+
+// formatEntry renders entry as one JSON or text line for out.
+func (l *TestLogger) formatEntry(entry LogEntry, asJSON bool, out io.Writer) string {
     var output string
-    if l.jsonOutput {
+    if asJSON {
+        entry.Fields = l.jsonFields(entry.Fields)
         jsonBytes, err := json.Marshal(entry)
         if err != nil {
             // Fallback to plain text if JSON marshaling fails
-            output = fmt.Sprintf("[ERROR] Failed to marshal log entry: %v", err)
+            output = fmt.Sprintf("[ERROR] Failed to marshal log entry %q: %v", entry.Message, err)
         } else {
             output = string(jsonBytes)
         }
     } else {
-        levelStr := logLevelNames[entry.Level]
-        fieldsStr := ""
-        if len(entry.Fields) > 0 {
-            var pairs []string
-            for k, v := range entry.Fields {
-                pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
-            }
-            fieldsStr = " " + strings.Join(pairs, " ")
-        }
-
-        output = fmt.Sprintf("[%s] [%s] %s: %s%s\n",
-            entry.Timestamp.Format("2006-01-02 15:04:05.000"),
-            levelStr,
-            entry.TestID,
-            entry.Message,
-            fieldsStr)
+        output = l.formatText(entry, out)
     }
 
-    l.mu.RLock()
-    defer l.mu.RUnlock()
-    if l.output != nil {
-        fmt.Fprint(l.output, output)
-        if !strings.HasSuffix(output, "\n") {
-            fmt.Fprintln(l.output)
-        }
+    if !strings.HasSuffix(output, "\n") {
+        output += "\n"
     }
+    return output
 }
 
 // Logging methods with field support
@@ -602,6 +611,7 @@ func (l *TestLogger) Errorf(format string, args ...any) {
 
 func (l *TestLogger) Fatalf(format string, args ...any) {
     l.log(FATAL, fmt.Sprintf(format, args...), nil)
+    l.Flush()
     os.Exit(1)
 }
 
@@ -0,0 +1,171 @@
+package testutils
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Async output – keeping slow writers off the logging hot path
+// ------------------------------------------------------------------------
+
+// AsyncOutputOption configures WithAsyncOutput.
+type AsyncOutputOption func(*asyncOutput)
+
+// DropWhenFull makes a full queue drop entries instead of blocking the
+// caller. Dropped entries are counted by DroppedLogs and reported in a
+// "log entries dropped" warning with a dropped_logs field once the writer
+// catches up.
+func DropWhenFull() AsyncOutputOption {
+	return func(a *asyncOutput) { a.dropWhenFull = true }
+}
+
+// WithAsyncOutput writes entries to the output and sinks from a
+// background goroutine fed by a queue of bufferSize entries, so a slow
+// pipe (docker logs under load) no longer stalls the code that logs. When
+// the queue is full the caller blocks until there is room, or, with
+// DropWhenFull, the entry is dropped. Entries keep their order. Flush
+// waits for the queue to drain and Close stops the goroutine; loggers
+// derived with WithField share the queue. bufferSize <= 0 keeps writes
+// synchronous.
+func WithAsyncOutput(bufferSize int, opts ...AsyncOutputOption) LoggerOption {
+	return func(l *TestLogger) {
+		if l.async != nil {
+			l.async.close()
+			l.async = nil
+		}
+		if bufferSize > 0 {
+			l.async = newAsyncOutput(bufferSize, opts...)
+		}
+	}
+}
+
+// DroppedLogs returns how many entries DropWhenFull has dropped.
+func (l *TestLogger) DroppedLogs() uint64 {
+	if l.async == nil {
+		return 0
+	}
+	return l.async.dropped.Load()
+}
+
+// asyncItem is a queued entry with the logger that formats it, or a
+// flush marker when flushed is set.
+type asyncItem struct {
+	logger  *TestLogger
+	entry   LogEntry
+	flushed chan struct{}
+}
+
+type asyncOutput struct {
+	queue        chan asyncItem
+	dropWhenFull bool
+
+	// mu guards closing the queue: writers hold it shared while sending.
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	dropped  atomic.Uint64
+	reported uint64 // drops already reported; writer goroutine only
+}
+
+func newAsyncOutput(size int, opts ...AsyncOutputOption) *asyncOutput {
+	a := &asyncOutput{
+		queue: make(chan asyncItem, size),
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	go a.run()
+	return a
+}
+
+// enqueue queues entry for l. It reports false when the output is closed,
+// in which case the caller writes synchronously.
+func (a *asyncOutput) enqueue(l *TestLogger, entry LogEntry) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return false
+	}
+	item := asyncItem{logger: l, entry: entry}
+	if !a.dropWhenFull {
+		a.queue <- item
+		return true
+	}
+	select {
+	case a.queue <- item:
+	default:
+		a.dropped.Add(1)
+	}
+	return true
+}
+
+func (a *asyncOutput) run() {
+	defer close(a.done)
+	// The writer is shared by every derived logger and closed with the
+	// logger, so it is not a leak of the test that created it.
+	defer markExpectedGoroutine()()
+	var last *TestLogger
+	for item := range a.queue {
+		if item.flushed != nil {
+			a.reportDrops(last)
+			close(item.flushed)
+			continue
+		}
+		item.logger.writeEntryNow(item.entry)
+		last = item.logger
+		if len(a.queue) == 0 {
+			a.reportDrops(last)
+		}
+	}
+	a.reportDrops(last)
+}
+
+// reportDrops writes a warning through l when entries were dropped since
+// the last report.
+func (a *asyncOutput) reportDrops(l *TestLogger) {
+	dropped := a.dropped.Load()
+	if l == nil || dropped == a.reported {
+		return
+	}
+	n := dropped - a.reported
+	a.reported = dropped
+	l.writeEntryNow(LogEntry{
+		Timestamp: time.Now().UTC(),
+		Level:     WARN,
+		TestID:    l.testID,
+		Message:   "log entries dropped",
+		Fields:    map[string]any{"dropped_logs": n, "dropped_logs_total": dropped},
+	})
+}
+
+// flush waits until everything queued before it has been written.
+func (a *asyncOutput) flush() {
+	a.mu.RLock()
+	if a.closed {
+		a.mu.RUnlock()
+		return
+	}
+	marker := make(chan struct{})
+	a.queue <- asyncItem{flushed: marker}
+	a.mu.RUnlock()
+	<-marker
+}
+
+// close drains the queue and stops the writer. Later entries are written
+// synchronously.
+func (a *asyncOutput) close() {
+	a.mu.Lock()
+	if a.closed {
+		a.mu.Unlock()
+		<-a.done
+		return
+	}
+	a.closed = true
+	close(a.queue)
+	a.mu.Unlock()
+	<-a.done
+}
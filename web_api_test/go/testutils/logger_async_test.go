@@ -0,0 +1,158 @@
+package testutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedWriter blocks every write until the gate is opened.
+type gatedWriter struct {
+	gate chan struct{}
+	out  syncBuffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	return w.out.Write(p)
+}
+
+// slowWriter stands in for a pipe that is slow to drain.
+type slowWriter struct {
+	delay time.Duration
+	out   syncBuffer
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.out.Write(p)
+}
+
+func logLines(t *testing.T, out string) []LogEntry {
+	t.Helper()
+	var entries []LogEntry
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		var e LogEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("bad line %q: %v", sc.Text(), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestAsyncOutput_PreservesOrder(t *testing.T) {
+	w := &slowWriter{delay: 10 * time.Microsecond}
+	logger := NewTestLogger("async", w, WithJSONOutput(true), WithAsyncOutput(8))
+	defer logger.Close()
+
+	for i := 0; i < 500; i++ {
+		logger.Info("tick", map[string]any{"i": i})
+	}
+	if err := logger.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	entries := logLines(t, w.out.String())
+	if len(entries) != 500 {
+		t.Fatalf("expected 500 entries after Flush, got %d", len(entries))
+	}
+	for i, e := range entries {
+		if e.Sequence != uint64(i+1) {
+			t.Fatalf("entry %d has sequence %d", i, e.Sequence)
+		}
+	}
+	if logger.DroppedLogs() != 0 {
+		t.Errorf("the blocking policy must not drop, dropped %d", logger.DroppedLogs())
+	}
+}
+
+func TestAsyncOutput_DropWhenFull(t *testing.T) {
+	w := &gatedWriter{gate: make(chan struct{})}
+	logger := NewTestLogger("async", w, WithJSONOutput(true), WithAsyncOutput(4, DropWhenFull()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			logger.Info("burst", nil)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging blocked on a stalled writer")
+	}
+	dropped := logger.DroppedLogs()
+	if dropped < 15 {
+		t.Errorf("expected at least 15 of 20 entries dropped, got %d", dropped)
+	}
+
+	close(w.gate)
+	logger.Close()
+	var written, reported int
+	for _, e := range logLines(t, w.out.String()) {
+		switch e.Message {
+		case "burst":
+			written++
+		case "log entries dropped":
+			reported += int(e.Fields["dropped_logs"].(float64))
+		}
+	}
+	if written+int(dropped) != 20 || reported != int(dropped) {
+		t.Errorf("wrote %d, dropped %d, reported %d", written, dropped, reported)
+	}
+
+	// After Close, entries are written synchronously.
+	logger.Info("after close", nil)
+	if !strings.Contains(w.out.String(), "after close") {
+		t.Error("entry logged after Close was lost")
+	}
+}
+
+func TestAsyncOutput_SharedByDerivedLoggers(t *testing.T) {
+	var out syncBuffer
+	logger := NewTestLogger("async", &out, WithAsyncOutput(16))
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(child *TestLogger) {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				child.Info("child", nil)
+			}
+		}(logger.WithField("worker", i))
+	}
+	wg.Wait()
+	logger.Close()
+	if n := strings.Count(out.String(), "child"); n != 100 {
+		t.Errorf("expected 100 entries, got %d", n)
+	}
+}
+
+// BenchmarkLogPortCheck_SlowSink logs port scan results into a sink that
+// takes 50µs per write. Synchronously every result pays that latency;
+// with WithAsyncOutput and DropWhenFull it does not.
+func BenchmarkLogPortCheck_SlowSink(b *testing.B) {
+	result := PortCheckResult{Port: 8080, Protocol: "tcp", Success: true, Latency: time.Millisecond}
+	for _, bc := range []struct {
+		name string
+		opts []LoggerOption
+	}{
+		{"sync", nil},
+		{"async", []LoggerOption{WithAsyncOutput(1024, DropWhenFull())}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			logger := NewTestLogger("bench", &slowWriter{delay: 50 * time.Microsecond}, bc.opts...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				logger.logPortCheck(result, 0)
+			}
+			b.StopTimer()
+			logger.Close()
+		})
+	}
+}
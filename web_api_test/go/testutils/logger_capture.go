@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ------------------------------------------------------------------------
+// Entry capture – keeping recent entries for failure artifacts
+// ------------------------------------------------------------------------
+
+// WithEntryCapture keeps the last n entries the logger and the loggers
+// derived from it write, for CapturedEntries and DumpArtifacts. Entries
+// filtered out by the level are not kept. n <= 0 disables capture.
+func WithEntryCapture(n int) LoggerOption {
+	return func(l *TestLogger) {
+		if n <= 0 {
+			l.capture = nil
+			return
+		}
+		l.capture = &logCapture{entries: make([]LogEntry, n)}
+	}
+}
+
+// logCapture is a ring of the most recent entries.
+type logCapture struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+func (c *logCapture) add(entry LogEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[c.next] = entry
+	c.next++
+	if c.next == len(c.entries) {
+		c.next = 0
+		c.full = true
+	}
+}
+
+func (c *logCapture) snapshot() []LogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.full {
+		return append([]LogEntry(nil), c.entries[:c.next]...)
+	}
+	out := make([]LogEntry, 0, len(c.entries))
+	out = append(out, c.entries[c.next:]...)
+	return append(out, c.entries[:c.next]...)
+}
+
+// CapturedEntries returns the captured entries, oldest first, or nil
+// without WithEntryCapture.
+func (l *TestLogger) CapturedEntries() []LogEntry {
+	if l.capture == nil {
+		return nil
+	}
+	return l.capture.snapshot()
+}
+
+// DumpArtifacts writes the captured entries to log.jsonl, one JSON entry
+// per line, for an ArtifactCollector.
+func (l *TestLogger) DumpArtifacts(dir string) error {
+	f, err := os.Create(filepath.Join(dir, "log.jsonl"))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, entry := range l.CapturedEntries() {
+		entry.Fields = l.jsonFields(entry.Fields)
+		if err := enc.Encode(entry); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,254 @@
+package testutils
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ------------------------------------------------------------------------
+// Field serialization – keeping one bad value from breaking a log line
+// ------------------------------------------------------------------------
+
+// DefaultMaxFieldBytes is the size a rendered field is truncated to unless
+// WithMaxFieldBytes says otherwise.
+const DefaultMaxFieldBytes = 64 << 10
+
+// FieldErrorsKey is the field that lists, per field name, why a value was
+// replaced by a placeholder.
+const FieldErrorsKey = "log_field_errors"
+
+// Limits on the values walked before rendering. Deeper or larger values
+// are replaced rather than risk a runaway encoder.
+const (
+	maxFieldDepth = 64
+	maxFieldNodes = 10000
+)
+
+var (
+	errFieldCycle    = errors.New("cycle detected")
+	errFieldTooDeep  = fmt.Errorf("nested deeper than %d levels", maxFieldDepth)
+	errFieldTooLarge = fmt.Errorf("more than %d values", maxFieldNodes)
+)
+
+// WithMaxFieldBytes truncates rendered field values longer than n bytes,
+// marking the cut with an ellipsis. n <= 0 disables truncation.
+func WithMaxFieldBytes(n int) LoggerOption {
+	return func(l *TestLogger) {
+		l.maxFieldBytes = n
+	}
+}
+
+// jsonFields returns fields with every value made safe for json.Marshal:
+// values that panic, cycle or fail to encode become a placeholder noted
+// under FieldErrorsKey, and long encodings are truncated to strings.
+func (l *TestLogger) jsonFields(fields map[string]any) map[string]any {
+	if len(fields) == 0 {
+		return fields
+	}
+	out := make(map[string]any, len(fields))
+	var notes map[string]string
+	for k, v := range fields {
+		safe, note := jsonField(v, l.maxFieldBytes)
+		out[k] = safe
+		if note != "" {
+			if notes == nil {
+				notes = make(map[string]string)
+			}
+			notes[k] = note
+		}
+	}
+	if notes != nil {
+		out[FieldErrorsKey] = notes
+	}
+	return out
+}
+
+func jsonField(v any, max int) (safe any, note string) {
+	defer func() {
+		if r := recover(); r != nil {
+			safe, note = unserializable(v), fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	if s, ok := v.(string); ok {
+		return truncateField(s, max), ""
+	}
+	if err := checkField(v, true); err != nil {
+		return unserializable(v), err.Error()
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return unserializable(v), err.Error()
+	}
+	if max > 0 && len(raw) > max {
+		return truncateField(string(raw), max), ""
+	}
+	return json.RawMessage(raw), ""
+}
+
+// textField renders v for the text formatter with the same protection as
+// jsonField.
+func textField(v any, max int) (s string, note string) {
+	defer func() {
+		if r := recover(); r != nil {
+			s, note = unserializable(v), fmt.Sprintf("panic: %v", r)
+		}
+	}()
+	if err := checkField(v, false); err != nil {
+		return unserializable(v), err.Error()
+	}
+	return truncateField(fmt.Sprintf("%v", v), max), ""
+}
+
+func unserializable(v any) string {
+	return fmt.Sprintf("<unserializable: %T>", v)
+}
+
+// truncateField cuts s to max bytes on a rune boundary and appends a
+// marker with the number of bytes dropped.
+func truncateField(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s…(truncated %d bytes)", s[:cut], len(s)-cut)
+}
+
+// checkField walks v looking for cycles and for values too deep or too
+// large to render. It follows what the renderer would: for JSON it stops at
+// marshalers and skips unexported fields; for text it stops at Stringers
+// and, like fmt, does not follow pointers below the top level.
+func checkField(v any, forJSON bool) error {
+	w := fieldWalker{forJSON: forJSON, onPath: make(map[fieldRef]bool)}
+	return w.walk(reflect.ValueOf(v), 0)
+}
+
+type fieldWalker struct {
+	forJSON bool
+	nodes   int
+	onPath  map[fieldRef]bool
+}
+
+// fieldRef identifies a map, slice or pointee; the type keeps a struct and
+// its first field, which share an address, apart.
+type fieldRef struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	formatterType     = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+)
+
+func (w *fieldWalker) walk(v reflect.Value, depth int) error {
+	if !v.IsValid() {
+		return nil
+	}
+	if depth > maxFieldDepth {
+		return errFieldTooDeep
+	}
+	if w.nodes++; w.nodes > maxFieldNodes {
+		return errFieldTooLarge
+	}
+	if w.rendersItself(v.Type()) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		return w.walk(v.Elem(), depth)
+	case reflect.Pointer:
+		if v.IsNil() || (!w.forJSON && depth > 0) {
+			return nil
+		}
+		return w.enter(v, func() error { return w.walk(v.Elem(), depth+1) })
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		return w.enter(v, func() error {
+			iter := v.MapRange()
+			for iter.Next() {
+				if err := w.walk(iter.Key(), depth+1); err != nil {
+					return err
+				}
+				if err := w.walk(iter.Value(), depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case reflect.Slice:
+		if v.Len() == 0 || isScalarKind(v.Type().Elem().Kind()) {
+			return nil
+		}
+		return w.enter(v, func() error { return w.walkElems(v, depth) })
+	case reflect.Array:
+		if isScalarKind(v.Type().Elem().Kind()) {
+			return nil
+		}
+		return w.walkElems(v, depth)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if w.forJSON && !t.Field(i).IsExported() && !t.Field(i).Anonymous {
+				continue
+			}
+			if err := w.walk(v.Field(i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *fieldWalker) walkElems(v reflect.Value, depth int) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := w.walk(v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enter marks v as being walked for the duration of fn; meeting it again
+// inside fn is a cycle. Shared but acyclic values are fine.
+func (w *fieldWalker) enter(v reflect.Value, fn func() error) error {
+	ref := fieldRef{ptr: v.Pointer(), typ: v.Type()}
+	if w.onPath[ref] {
+		return errFieldCycle
+	}
+	w.onPath[ref] = true
+	defer delete(w.onPath, ref)
+	return fn()
+}
+
+// rendersItself reports whether values of t decide their own output, so
+// their internals need not be walked.
+func (w *fieldWalker) rendersItself(t reflect.Type) bool {
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	if w.forJSON {
+		return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+	}
+	return t.Implements(stringerType) || t.Implements(errorType) || t.Implements(formatterType)
+}
+
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type panickyMarshaler struct{}
+
+func (panickyMarshaler) MarshalJSON() ([]byte, error) { panic("boom") }
+
+type panickyStringer struct{}
+
+func (panickyStringer) String() string { panic("boom") }
+
+type listNode struct {
+	Name string
+	Next *listNode
+}
+
+// hostileFields returns values that used to break or crash log rendering.
+func hostileFields() map[string]any {
+	cyclicMap := map[string]any{"a": 1}
+	cyclicMap["self"] = cyclicMap
+	cyclicSlice := []any{nil}
+	cyclicSlice[0] = cyclicSlice
+	ring := &listNode{Name: "a"}
+	ring.Next = &listNode{Name: "b", Next: ring}
+	deep := any("leaf")
+	for i := 0; i < 2*maxFieldDepth; i++ {
+		deep = []any{deep}
+	}
+	return map[string]any{
+		"chan":         make(chan int),
+		"func":         func() {},
+		"cyclic_map":   cyclicMap,
+		"cyclic_slice": cyclicSlice,
+		"ring":         ring,
+		"deep":         deep,
+		"marshaler":    panickyMarshaler{},
+		"stringer":     panickyStringer{},
+		"huge":         strings.Repeat("x", 10<<20),
+		"ok":           42,
+	}
+}
+
+func TestTestLogger_JSONSurvivesHostileFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewTestLogger("fields", &buf, WithJSONOutput(true), WithMaxFieldBytes(1024))
+	l.Info("hostile", hostileFields())
+
+	var entry struct {
+		Message string         `json:"message"`
+		Fields  map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output is not JSON: %v\n%.500s", err, buf.String())
+	}
+	if entry.Message != "hostile" || entry.Fields["ok"] != float64(42) {
+		t.Errorf("well-behaved parts lost: %+v", entry)
+	}
+	if buf.Len() > 8<<10 {
+		t.Errorf("entry is %d bytes, huge field not truncated", buf.Len())
+	}
+	if s := entry.Fields["huge"].(string); !strings.HasSuffix(s, "…(truncated 10484736 bytes)") {
+		t.Errorf("huge field = %.40q...", s)
+	}
+
+	notes, _ := entry.Fields[FieldErrorsKey].(map[string]any)
+	for _, k := range []string{"chan", "func", "cyclic_map", "cyclic_slice", "ring", "deep", "marshaler"} {
+		if !strings.HasPrefix(entry.Fields[k].(string), "<unserializable: ") {
+			t.Errorf("%s = %v, want placeholder", k, entry.Fields[k])
+		}
+		if notes[k] == nil {
+			t.Errorf("no error note for %s in %v", k, notes)
+		}
+	}
+	if notes["cyclic_map"] != errFieldCycle.Error() || notes["marshaler"] != "panic: boom" {
+		t.Errorf("notes = %v", notes)
+	}
+}
+
+func TestTestLogger_TextSurvivesHostileFields(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewTestLogger("fields", &buf, WithMaxFieldBytes(1024))
+	l.Info("hostile", hostileFields())
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 || buf.Len() > 8<<10 {
+		t.Fatalf("expected one bounded line, got %d bytes", buf.Len())
+	}
+	for _, want := range []string{
+		"cyclic_map=<unserializable: map[string]interface {}>",
+		"cyclic_slice=<unserializable: []interface {}>",
+		"deep=<unserializable: []interface {}>",
+		"ok=42",
+		"…(truncated 10484736 bytes)",
+		FieldErrorsKey + "=map[",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in %.300q", want, out)
+		}
+	}
+	// fmt prints nested pointers as addresses, so the ring is renderable.
+	if strings.Contains(out, "ring=<unserializable") {
+		t.Errorf("ring replaced in text output")
+	}
+}
+
+func TestCheckField_SharedValuesAreNotCycles(t *testing.T) {
+	shared := map[string]int{"x": 1}
+	leaf := &listNode{Name: "leaf"}
+	v := map[string]any{"a": shared, "b": shared, "c": []*listNode{leaf, leaf}}
+	for _, forJSON := range []bool{true, false} {
+		if err := checkField(v, forJSON); err != nil {
+			t.Errorf("forJSON=%v: %v", forJSON, err)
+		}
+	}
+}
+
+func TestTruncateField_RuneBoundary(t *testing.T) {
+	got := truncateField("aé", 2) // é is two bytes
+	if got != "a…(truncated 2 bytes)" {
+		t.Errorf("got %q", got)
+	}
+	if got := truncateField("short", 0); got != "short" {
+		t.Errorf("truncation with limit 0: %q", got)
+	}
+}
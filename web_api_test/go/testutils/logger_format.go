@@ -0,0 +1,184 @@
+package testutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultTimestampFormat is used when no TimestampFormat is configured.
+const defaultTimestampFormat = "2006-01-02 15:04:05.000"
+
+// ColorMode controls ANSI coloring of text log output.
+type ColorMode int
+
+const (
+	// ColorNever disables coloring.
+	ColorNever ColorMode = iota
+	// ColorAuto colors only when the output is a terminal and NO_COLOR is unset.
+	ColorAuto
+	// ColorAlways colors regardless of the output (used by snapshot tests).
+	ColorAlways
+)
+
+// levelColors maps each level to its color name in the colors table.
+var levelColors = map[LogLevel]string{
+	TRACE: "white",
+	DEBUG: "blue",
+	INFO:  "green",
+	WARN:  "yellow",
+	ERROR: "red",
+	FATAL: "magenta",
+}
+
+// levelWidth is the width of the level column, set by the longest name.
+const levelWidth = 5
+
+// WithColorMode sets how text output is colored.
+func WithColorMode(mode ColorMode) LoggerOption {
+	return func(l *TestLogger) {
+		l.colorMode = mode
+	}
+}
+
+// WithTimestamp toggles the timestamp column and sets its layout; an empty
+// format keeps the current one.
+func WithTimestamp(enabled bool, format string) LoggerOption {
+	return func(l *TestLogger) {
+		l.hideTimestamp = !enabled
+		if format != "" {
+			l.timestampFormat = format
+		}
+	}
+}
+
+// WithCaller toggles the caller (file:line) suffix in text output.
+func WithCaller(enabled bool) LoggerOption {
+	return func(l *TestLogger) {
+		l.showCaller = enabled
+	}
+}
+
+// WithLoggerConfig applies a LoggerConfig: level, JSON output, caller skip,
+// default fields, colors, and the timestamp and caller toggles.
+// EnableColors maps to ColorAuto, so colors still switch off on non-TTY output.
+func WithLoggerConfig(cfg LoggerConfig) LoggerOption {
+	return func(l *TestLogger) {
+		l.logLevel = cfg.DefaultLevel
+		l.jsonOutput = cfg.JSONOutput
+		if cfg.CallerSkip > 0 {
+			l.callerSkip = cfg.CallerSkip
+		}
+		for k, v := range cfg.DefaultFields {
+			l.fields[k] = v
+		}
+		l.colorMode = ColorNever
+		if cfg.EnableColors {
+			l.colorMode = ColorAuto
+		}
+		l.hideTimestamp = !cfg.EnableTimestamp
+		if cfg.TimestampFormat != "" {
+			l.timestampFormat = cfg.TimestampFormat
+		}
+		l.showCaller = cfg.EnableCaller
+	}
+}
+
+// useColors resolves the color mode against out.
+func (l *TestLogger) useColors(out io.Writer) bool {
+	switch l.colorMode {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		return os.Getenv("NO_COLOR") == "" && isTerminal(out)
+	default:
+		return false
+	}
+}
+
+// isTerminal reports whether w is a character device such as a TTY.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatText renders entry as a human-readable line:
+//
+//	[2006-01-02 15:04:05.000] [INFO ] test-id: message a=1 b=2 (file.go:42)
+//
+// Fields are sorted by key so output is stable. Colors are resolved against
+// out, the writer the line is destined for.
+func (l *TestLogger) formatText(entry LogEntry, out io.Writer) string {
+	color := l.useColors(out)
+	paint := func(name, s string) string {
+		if !color || s == "" {
+			return s
+		}
+		return colors[name] + s + colors["reset"]
+	}
+
+	var b strings.Builder
+	if !l.hideTimestamp {
+		format := l.timestampFormat
+		if format == "" {
+			format = defaultTimestampFormat
+		}
+		b.WriteString("[")
+		b.WriteString(paint("dim", entry.Timestamp.Format(format)))
+		b.WriteString("] ")
+	}
+
+	levelStr := "?"
+	if int(entry.Level) >= 0 && int(entry.Level) < len(logLevelNames) {
+		levelStr = logLevelNames[entry.Level]
+	}
+	b.WriteString("[")
+	b.WriteString(paint(levelColors[entry.Level], fmt.Sprintf("%-*s", levelWidth, levelStr)))
+	b.WriteString("] ")
+
+	b.WriteString(entry.TestID)
+	b.WriteString(": ")
+	b.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var notes map[string]string
+	for _, k := range keys {
+		s, note := textField(entry.Fields[k], l.maxFieldBytes)
+		if note != "" {
+			if notes == nil {
+				notes = make(map[string]string)
+			}
+			notes[k] = note
+		}
+		b.WriteString(" ")
+		b.WriteString(paint("cyan", k))
+		b.WriteString("=")
+		b.WriteString(s)
+	}
+	if notes != nil {
+		b.WriteString(" ")
+		b.WriteString(paint("cyan", FieldErrorsKey))
+		b.WriteString("=")
+		b.WriteString(fmt.Sprintf("%v", notes))
+	}
+
+	if l.showCaller && entry.Caller != "" {
+		b.WriteString(" ")
+		b.WriteString(paint("dim", "("+entry.Caller+")"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,62 @@
+package testutils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestTestLogger_FormatTextSnapshots(t *testing.T) {
+	entry := LogEntry{
+		Timestamp: time.Date(2024, 3, 1, 12, 30, 45, 123000000, time.UTC),
+		Level:     WARN,
+		TestID:    "suite-1",
+		Message:   "port busy",
+		Fields:    map[string]any{"port": 8080, "host": "localhost"},
+		Caller:    "port_checker.go:42",
+	}
+
+	tests := []struct {
+		name string
+		opts []LoggerOption
+		want string
+	}{
+		{
+			name: "plain",
+			opts: []LoggerOption{WithColorMode(ColorNever)},
+			want: "[2024-03-01 12:30:45.123] [WARN ] suite-1: port busy host=localhost port=8080\n",
+		},
+		{
+			name: "plain with caller, no timestamp",
+			opts: []LoggerOption{WithTimestamp(false, ""), WithCaller(true)},
+			want: "[WARN ] suite-1: port busy host=localhost port=8080 (port_checker.go:42)\n",
+		},
+		{
+			name: "custom timestamp format",
+			opts: []LoggerOption{WithTimestamp(true, time.RFC3339)},
+			want: "[2024-03-01T12:30:45Z] [WARN ] suite-1: port busy host=localhost port=8080\n",
+		},
+		{
+			name: "colored",
+			opts: []LoggerOption{WithColorMode(ColorAlways), WithCaller(true)},
+			want: "[\033[2m2024-03-01 12:30:45.123\033[0m] [\033[33mWARN \033[0m] suite-1: port busy " +
+				"\033[36mhost\033[0m=localhost \033[36mport\033[0m=8080 \033[2m(port_checker.go:42)\033[0m\n",
+		},
+		{
+			name: "auto disables colors for non-tty output",
+			opts: []LoggerOption{WithColorMode(ColorAuto)},
+			want: "[2024-03-01 12:30:45.123] [WARN ] suite-1: port busy host=localhost port=8080\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := NewTestLogger("suite-1", &buf, tt.opts...)
+			l.writeEntry(entry)
+			if got := buf.String(); got != tt.want {
+				t.Errorf("unexpected rendering\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
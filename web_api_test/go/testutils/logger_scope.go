@@ -0,0 +1,90 @@
+package testutils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// ------------------------------------------------------------------------
+// Per-test loggers and the package default
+// ------------------------------------------------------------------------
+
+var defaultLog atomic.Pointer[TestLogger]
+
+// Default returns the package-level logger for code that runs outside any
+// test, such as TestMain or helpers without a testing.TB. It is created by
+// DefaultLogger on first use unless SetDefault was called.
+func Default() *TestLogger {
+	if l := defaultLog.Load(); l != nil {
+		return l
+	}
+	newLog := DefaultLogger()
+	if defaultLog.CompareAndSwap(nil, newLog) {
+		return newLog
+	}
+	return defaultLog.Load()
+}
+
+// SetDefault replaces the package-level logger. It is safe for concurrent
+// use.
+func SetDefault(l *TestLogger) {
+	defaultLog.Store(l)
+}
+
+// ForTest returns a child logger bound to t. Entries carry test_name and
+// test_id fields and are written with t.Log, so `go test` attributes them
+// to t even when parallel subtests log at the same time and shows them
+// with that test's output. Once t and its subtests finish, the child
+// falls back to l's output, since t.Log must not be called after a test
+// completes.
+//
+//	t.Run(tc.name, func(t *testing.T) {
+//		t.Parallel()
+//		log := logger.ForTest(t)
+//		log.Info("seeding", nil)
+//	})
+func (l *TestLogger) ForTest(t testing.TB) *TestLogger {
+	l.mu.RLock()
+	child := l.clone()
+	fallback := l.output
+	l.mu.RUnlock()
+
+	w := &testLogWriter{t: t, fallback: fallback}
+	t.Cleanup(w.unbind)
+
+	child.testID = fmt.Sprintf("%s/%s", l.testID, t.Name())
+	child.fields["test_name"] = t.Name()
+	child.fields["test_id"] = child.testID
+	child.output = w
+	return child
+}
+
+// testLogWriter writes through t.Log until the test's cleanup unbinds it.
+type testLogWriter struct {
+	mu       sync.Mutex
+	t        testing.TB
+	fallback io.Writer
+}
+
+func (w *testLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.t == nil {
+		if w.fallback == nil {
+			return len(p), nil
+		}
+		return w.fallback.Write(p)
+	}
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+func (w *testLogWriter) unbind() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.t = nil
+}
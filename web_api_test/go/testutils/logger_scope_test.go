@@ -0,0 +1,101 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingTB captures what a test logs while leaving everything else to
+// the real test.
+type recordingTB struct {
+	testing.TB
+	mu    sync.Mutex
+	lines []string
+}
+
+func (r *recordingTB) Log(args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lines = append(r.lines, fmt.Sprint(args...))
+}
+
+func TestTestLogger_ForTestParallelSubtests(t *testing.T) {
+	var parentOut bytes.Buffer
+	logger := NewTestLogger("suite", &parentOut, WithJSONOutput(true))
+
+	t.Run("group", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			name := fmt.Sprintf("sub%02d", i)
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				rec := &recordingTB{TB: t}
+				log := logger.ForTest(rec)
+
+				var wg sync.WaitGroup
+				for g := 0; g < 4; g++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for n := 0; n < 5; n++ {
+							log.Info("step", map[string]any{"n": n})
+						}
+					}()
+				}
+				wg.Wait()
+
+				rec.mu.Lock()
+				defer rec.mu.Unlock()
+				if len(rec.lines) != 20 {
+					t.Fatalf("logged %d lines, want 20", len(rec.lines))
+				}
+				for _, line := range rec.lines {
+					if !strings.Contains(line, `"test_name":"`+t.Name()+`"`) ||
+						!strings.Contains(line, `"test_id":"suite/`+t.Name()+`"`) {
+						t.Fatalf("line attributed to another test: %s", line)
+					}
+				}
+			})
+		}
+	})
+
+	if parentOut.Len() != 0 {
+		t.Errorf("subtest entries leaked to the parent output:\n%s", parentOut.String())
+	}
+}
+
+func TestTestLogger_ForTestUnbindsOnCleanup(t *testing.T) {
+	var parentOut bytes.Buffer
+	logger := NewTestLogger("suite", &parentOut)
+
+	var log *TestLogger
+	var rec *recordingTB
+	t.Run("short", func(t *testing.T) {
+		rec = &recordingTB{TB: t}
+		log = logger.ForTest(rec)
+		log.Info("during", nil)
+	})
+	log.Info("after", nil) // t.Log here would panic
+
+	if len(rec.lines) != 1 || !strings.Contains(rec.lines[0], "during") {
+		t.Errorf("test lines = %q", rec.lines)
+	}
+	if out := parentOut.String(); !strings.Contains(out, "after") || strings.Contains(out, "during") {
+		t.Errorf("parent output = %q", out)
+	}
+}
+
+func TestDefaultLogger_SetDefault(t *testing.T) {
+	prev := Default()
+	if Default() != prev {
+		t.Fatal("Default is not stable")
+	}
+	custom := NewTestLogger("custom", &bytes.Buffer{})
+	SetDefault(custom)
+	defer SetDefault(prev)
+	if Default() != custom {
+		t.Error("SetDefault was ignored")
+	}
+}
@@ -0,0 +1,129 @@
+package testutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// LogFormat selects how a sink renders entries.
+type LogFormat string
+
+const (
+	// LogFormatText is the human-readable format of formatText.
+	LogFormatText LogFormat = "text"
+	// LogFormatJSON writes one JSON object per line.
+	LogFormatJSON LogFormat = "json"
+)
+
+// LogSink is one destination of a multiplexed TestLogger. An empty Format
+// is text.
+type LogSink struct {
+	Writer   io.Writer
+	Format   LogFormat
+	MinLevel LogLevel
+}
+
+// countedSink is a LogSink with its failure count. Derived loggers share
+// the same *countedSink values, so counts cover the whole logger family.
+type countedSink struct {
+	LogSink
+	errors atomic.Int64
+}
+
+// WithSinks writes every entry to each sink at or above the sink's
+// MinLevel, in the sink's format, instead of to the logger's output. Sinks
+// fail independently: a write error is counted (see SinkErrors) and the
+// remaining sinks are still written. The logger's level is lowered to the
+// lowest MinLevel so no sink is starved; a later WithLevel or SetLevel acts
+// as a floor for all sinks.
+//
+//	logger := NewTestLogger("api", nil, WithSinks(
+//		LogSink{Writer: os.Stdout, MinLevel: INFO},
+//		LogSink{Writer: file, Format: LogFormatJSON, MinLevel: DEBUG},
+//	))
+func WithSinks(sinks ...LogSink) LoggerOption {
+	return func(l *TestLogger) {
+		l.sinks = make([]*countedSink, 0, len(sinks))
+		for i, s := range sinks {
+			l.sinks = append(l.sinks, &countedSink{LogSink: s})
+			if i == 0 || s.MinLevel < l.logLevel {
+				l.logLevel = s.MinLevel
+			}
+		}
+	}
+}
+
+// SinkErrors returns the number of failed writes per sink, in the order
+// given to WithSinks.
+func (l *TestLogger) SinkErrors() []int64 {
+	out := make([]int64, len(l.sinks))
+	for i, s := range l.sinks {
+		out[i] = s.errors.Load()
+	}
+	return out
+}
+
+// writeSinks fans entry out to the sinks that accept its level.
+func (l *TestLogger) writeSinks(entry LogEntry) {
+	l.mu.RLock()
+	sinks := l.sinks
+	l.mu.RUnlock()
+	for _, s := range sinks {
+		if entry.Level < s.MinLevel || s.Writer == nil {
+			continue
+		}
+		line := l.formatEntry(entry, s.Format == LogFormatJSON, s.Writer)
+		// One write per entry, so concurrent entries never interleave.
+		if n, err := io.WriteString(s.Writer, line); err != nil || n < len(line) {
+			s.errors.Add(1)
+		}
+	}
+}
+
+// OpenSinks opens the writers described by c.Sinks for use with WithSinks.
+// Files are created (with their directories) and appended to. close closes
+// the files; it is safe to call when no sinks were configured.
+func (c LoggerConfig) OpenSinks() (sinks []LogSink, close func() error, err error) {
+	var files []*os.File
+	close = func() error {
+		errs := NewCompositeError("close log sinks")
+		for _, f := range files {
+			if err := f.Close(); err != nil {
+				errs.Add(err)
+			}
+		}
+		if errs.HasErrors() {
+			return errs
+		}
+		return nil
+	}
+	for i, sc := range c.Sinks {
+		var w io.Writer
+		switch sc.Output {
+		case "stdout":
+			w = os.Stdout
+		case "stderr":
+			w = os.Stderr
+		case "":
+			close()
+			return nil, nil, withKind(ErrValidation, fmt.Errorf("log sink %d has no output", i))
+		default:
+			if err := os.MkdirAll(filepath.Dir(sc.Output), 0o755); err != nil {
+				close()
+				return nil, nil, fmt.Errorf("log sink %d: %w", i, err)
+			}
+			f, err := os.OpenFile(sc.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				close()
+				return nil, nil, fmt.Errorf("log sink %d: %w", i, err)
+			}
+			files = append(files, f)
+			w = f
+		}
+		sinks = append(sinks, LogSink{Writer: w, Format: sc.Format, MinLevel: sc.MinLevel})
+	}
+	return sinks, close, nil
+}
@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestTestLogger_Sinks(t *testing.T) {
+	var text, jsonOut bytes.Buffer
+	logger := NewTestLogger("multi", nil, WithSinks(
+		LogSink{Writer: &text, MinLevel: INFO},
+		LogSink{Writer: failingWriter{}, MinLevel: TRACE},
+		LogSink{Writer: &jsonOut, Format: LogFormatJSON, MinLevel: DEBUG},
+	))
+	logger.Debug("cache warm", nil)
+	logger.WithField("user", 7).Info("login", nil)
+
+	if got := text.String(); strings.Contains(got, "cache warm") || !strings.Contains(got, "[INFO ] multi: login user=7") {
+		t.Errorf("text sink:\n%s", got)
+	}
+	lines := strings.Split(strings.TrimSpace(jsonOut.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("json sink has %d lines:\n%s", len(lines), jsonOut.String())
+	}
+	var entry LogEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil || entry.Message != "login" || entry.Fields["user"] != float64(7) {
+		t.Errorf("json entry %+v, err %v", entry, err)
+	}
+	if got := logger.SinkErrors(); got[0] != 0 || got[1] != 2 || got[2] != 0 {
+		t.Errorf("SinkErrors = %v, want [0 2 0]", got)
+	}
+
+	// SetLevel still acts as a floor for every sink.
+	logger.SetLevel(WARN)
+	logger.Info("quiet", nil)
+	if strings.Contains(text.String()+jsonOut.String(), "quiet") {
+		t.Error("entry below the logger level reached a sink")
+	}
+}
+
+func TestLoggerConfig_OpenSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "test.jsonl")
+	cfgPath := writeConfigFile(t, "config.yaml", `
+app_name: demo
+logger:
+  sinks:
+    - output: stderr
+      min_level: INFO
+    - output: `+path+`
+      format: json
+      min_level: DEBUG
+`)
+	cfg, err := LoadConfig(cfgPath, WithStrictKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Logger.Sinks) != 2 || cfg.Logger.Sinks[1].Format != LogFormatJSON || cfg.Logger.Sinks[1].MinLevel != DEBUG {
+		t.Fatalf("sinks = %+v", cfg.Logger.Sinks)
+	}
+
+	sinks, closeSinks, err := cfg.Logger.OpenSinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := NewTestLogger("cfg", nil, WithSinks(sinks...))
+	logger.Debug("to file only", nil)
+	if err := closeSinks(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), `"message":"to file only"`) {
+		t.Errorf("file sink: %s, %v", data, err)
+	}
+
+	cfg.Logger.Sinks = append(cfg.Logger.Sinks, LogSinkConfig{Output: "stdout", Format: "xml"})
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `got "xml"`) {
+		t.Errorf("Validate = %v, want a sink format error", err)
+	}
+}
@@ -0,0 +1,118 @@
+package testutils
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTestLogger_CheckPortDelegatesToPortChecker(t *testing.T) {
+	var mu sync.Mutex
+	var dialed []string
+	dialer := func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, address)
+		mu.Unlock()
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	pc := NewPortChecker(nil, PortCheckerConfig{}, WithPortCheckerDialer(dialer))
+	l := NewTestLogger("ports", io.Discard, WithPortChecker(pc))
+
+	tests := []struct {
+		host        string
+		wantAddress string
+	}{
+		{"127.0.0.1", "127.0.0.1:5432"},
+		{"::1", "[::1]:5432"},
+		{"db.internal", "db.internal:5432"},
+	}
+
+	for _, tt := range tests {
+		result, err := l.CheckPort(context.Background(), tt.host, 5432, PortCheckConfig{Protocol: "tcp", IPVersion: "ipv4"})
+		if err != nil {
+			t.Fatalf("CheckPort(%q) failed: %v", tt.host, err)
+		}
+		if !result.Success || result.Address != tt.wantAddress {
+			t.Errorf("CheckPort(%q) = success %v address %q, want success at %q", tt.host, result.Success, result.Address, tt.wantAddress)
+		}
+	}
+
+	want := []string{"127.0.0.1:5432", "[::1]:5432", "db.internal:5432"}
+	if len(dialed) != len(want) {
+		t.Fatalf("expected %d dials, got %v", len(want), dialed)
+	}
+	for i := range want {
+		if dialed[i] != want[i] {
+			t.Errorf("dial %d went to %q, want %q", i, dialed[i], want[i])
+		}
+	}
+	if n := len(l.GetPortCheckHistory()); n != len(want) {
+		t.Errorf("expected %d recorded checks, got %d", len(want), n)
+	}
+}
+
+func TestTestLogger_CheckPortKeepsHostnames(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	l := NewTestLogger("ports", io.Discard)
+	result, err := l.CheckPort(context.Background(), "localhost", port, PortCheckConfig{
+		Protocol:  "tcp",
+		IPVersion: "ipv4",
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("CheckPort(localhost) failed: %v", err)
+	}
+	if result.Network != "tcp4" {
+		t.Errorf("expected network tcp4, got %q", result.Network)
+	}
+	if want := net.JoinHostPort("localhost", strconv.Itoa(port)); result.Address != want {
+		t.Errorf("hostname was mangled: got %q, want %q", result.Address, want)
+	}
+	if result.RetryCount != 0 {
+		t.Errorf("expected success on the first attempt, got %d retries", result.RetryCount)
+	}
+}
+
+func TestTestLogger_CheckAllIsNotDeterministic(t *testing.T) {
+	if cfg := (PortCheckConfig{CheckAll: true, JitterEnabled: true}).portCheckerConfig(); cfg.Deterministic || !cfg.JitterEnabled {
+		t.Errorf("CheckAll changed the checker mode: %+v", cfg)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	for _, checkAll := range []bool{false, true} {
+		l := NewTestLogger("ports", io.Discard)
+		result, err := l.CheckPortRange(context.Background(), "127.0.0.1", port, port, PortCheckConfig{
+			Protocol: "tcp", Timeout: time.Second, CheckAll: checkAll,
+		})
+		if err != nil || result.SuccessCount != 1 {
+			t.Fatalf("CheckAll=%v: %+v, %v", checkAll, result, err)
+		}
+		if got := len(result.PerPortStats); (got == 1) != checkAll {
+			t.Errorf("CheckAll=%v returned %d per-port results", checkAll, got)
+		}
+		if checkAll && result.PerPortStats[0].Deterministic {
+			t.Error("CheckAll marked the result deterministic")
+		}
+		if got := len(l.portChecks); got != 1 {
+			t.Errorf("CheckAll=%v recorded %d port checks, want 1", checkAll, got)
+		}
+	}
+}
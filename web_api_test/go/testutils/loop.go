@@ -96,7 +96,7 @@ func Poll(cfg *PollConfig, fn func(attempt int) bool) error {
 		select {
 		case <-ticker.C:
 			if time.Now().After(deadline) {
-				return fmt.Errorf("polling timed out after %v", cfg.Timeout)
+				return kindErrorf(ErrTimeout, "polling timed out after %v", cfg.Timeout)
 			}
 			attempt++
 			if fn(attempt) {
@@ -2,286 +2,11 @@ package testutils
 
 
 import (
-	"encoding/json"
 	"fmt"
 	"math"
 	"sort"
-	"sync"
 )
 
-// IntCollection manages a collection of integers with statistical operations
-type IntCollection struct {
-	mu     sync.RWMutex
-	values []int
-	sorted bool
-}
-
-// NewIntCollection creates a new integer collection
-func NewIntCollection(values ...int) *IntCollection {
-	// Create a copy to avoid external mutation
-	cpy := make([]int, len(values))
-	copy(cpy, values)
-	return &IntCollection{
-		values: cpy,
-		sorted: false,
-	}
-}
-
-// Add adds values to the collection
-func (ic *IntCollection) Add(values ...int) {
-	ic.mu.Lock()
-	defer ic.mu.Unlock()
-	ic.values = append(ic.values, values...)
-	ic.sorted = false
-}
-
-// Len returns the number of values
-func (ic *IntCollection) Len() int {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	return len(ic.values)
-}
-
-// Values returns a copy of the values
-func (ic *IntCollection) Values() []int {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	values := make([]int, len(ic.values))
-	copy(values, ic.values)
-	return values
-}
-
-// ensureSorted safely sorts the collection if it is not already sorted.
-// It handles upgrading the lock from read to write without race conditions.
-func (ic *IntCollection) ensureSorted() {
-	ic.mu.RLock()
-	if ic.sorted {
-		ic.mu.RUnlock()
-		return
-	}
-	ic.mu.RUnlock()
-
-	ic.mu.Lock()
-	defer ic.mu.Unlock()
-	// Double-check pattern
-	if !ic.sorted {
-		sort.Ints(ic.values)
-		ic.sorted = true
-	}
-}
-
-// Sum calculates the sum of all values
-func (ic *IntCollection) Sum() int {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	sum := 0
-	for _, v := range ic.values {
-		sum += v
-	}
-	return sum
-}
-
-// Average calculates the average of all values
-func (ic *IntCollection) Average() float64 {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	if len(ic.values) == 0 {
-		return 0
-	}
-	return float64(ic.Sum()) / float64(len(ic.values))
-}
-
-// Median calculates the median value
-func (ic *IntCollection) Median() float64 {
-	ic.ensureSorted()
-
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	if len(ic.values) == 0 {
-		return 0
-	}
-
-	if len(ic.values)%2 == 1 {
-		return float64(ic.values[len(ic.values)/2])
-	}
-
-	middle := len(ic.values) / 2
-	return float64(ic.values[middle-1]+ic.values[middle]) / 2.0
-}
-
-// Mode calculates the mode (most frequent value)
-func (ic *IntCollection) Mode() []int {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	if len(ic.values) == 0 {
-		return nil
-	}
-
-	freq := make(map[int]int)
-	maxFreq := 0
-
-	for _, v := range ic.values {
-		freq[v]++
-		if freq[v] > maxFreq {
-			maxFreq = freq[v]
-		}
-	}
-
-	var modes []int
-	for v, f := range freq {
-		if f == maxFreq {
-			modes = append(modes, v)
-		}
-	}
-
-	return modes
-}
-
-// Min returns the minimum value
-func (ic *IntCollection) Min() (int, bool) {
-	ic.ensureSorted()
-
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	if len(ic.values) == 0 {
-		return 0, false
-	}
-	return ic.values[0], true
-}
-
-// Max returns the maximum value
-func (ic *IntCollection) Max() (int, bool) {
-	ic.ensureSorted()
-
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	if len(ic.values) == 0 {
-		return 0, false
-	}
-	return ic.values[len(ic.values)-1], true
-}
-
-// Range returns the range (max - min)
-func (ic *IntCollection) Range() (int, bool) {
-	min, ok1 := ic.Min()
-	max, ok2 := ic.Max()
-	if !ok1 || !ok2 {
-		return 0, false
-	}
-	return max - min, true
-}
-
-// StandardDeviation calculates the population standard deviation
-func (ic *IntCollection) StandardDeviation() float64 {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	if len(ic.values) < 2 {
-		return 0
-	}
-
-	mean := ic.Average()
-	var sumSquares float64
-	for _, v := range ic.values {
-		diff := float64(v) - mean
-		sumSquares += diff * diff
-	}
-
-	return math.Sqrt(sumSquares / float64(len(ic.values)))
-}
-
-// Variance calculates the population variance
-func (ic *IntCollection) Variance() float64 {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	if len(ic.values) < 2 {
-		return 0
-	}
-
-	mean := ic.Average()
-	var sumSquares float64
-	for _, v := range ic.values {
-		diff := float64(v) - mean
-		sumSquares += diff * diff
-	}
-
-	return sumSquares / float64(len(ic.values))
-}
-
-// Percentile calculates the value at the given percentile (0-100)
-func (ic *IntCollection) Percentile(p float64) (float64, error) {
-	if p < 0 || p > 100 {
-		return 0, fmt.Errorf("percentile must be between 0 and 100, got %f", p)
-	}
-
-	ic.ensureSorted()
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	if len(ic.values) == 0 {
-		return 0, fmt.New("no values in collection")
-	}
-
-	if p == 0 {
-		return float64(ic.values[0]), nil
-	}
-	if p == 100 {
-		return float64(ic.values[len(ic.values)-1]), nil
-	}
-
-	index := (p / 100) * float64(len(ic.values)-1)
-	lower := int(math.Floor(index))
-	upper := int(math.Ceil(index))
-
-	if lower == upper {
-		return float64(ic.values[lower]), nil
-	}
-
-	// Linear interpolation
-	lowerValue := float64(ic.values[lower])
-	upperValue := float64(ic.values[upper])
-	weight := index - float64(lower)
-
-	return lowerValue + (upperValue-lowerValue)*weight, nil
-}
-
-// Filter returns a new collection with values that match the predicate
-func (ic *IntCollection) Filter(predicate func(int) bool) *IntCollection {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	var filtered []int
-	for _, v := range ic.values {
-		if predicate(v) {
-			filtered = append(filtered, v)
-		}
-	}
-
-	return NewIntCollection(filtered...)
-}
-
-// Map applies a function to each value and returns a new collection
-func (ic *IntCollection) Map(mapper func(int) int) *IntCollection {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-
-	mapped := make([]int, len(ic.values))
-	for i, v := range ic.values {
-		mapped[i] = mapper(v)
-	}
-
-	return NewIntCollection(mapped...)
-}
-
-// JSON returns the collection as a JSON array
-func (ic *IntCollection) JSON() ([]byte, error) {
-	ic.mu.RLock()
-	defer ic.mu.RUnlock()
-	return json.Marshal(ic.values)
-}
-
 // IntStats provides statistical analysis for integer collections
 type IntStats struct {
 	Count    int     `json:"count"`
@@ -297,6 +22,10 @@ type IntStats struct {
 	Q1       float64 `json:"q1"`  // First quartile
 	Q3       float64 `json:"q3"`  // Third quartile
 	IQR      float64 `json:"iqr"` // Interquartile range
+
+	// Set by AnalyzeWith when the options ask for them.
+	OutlierCount int     `json:"outlier_count,omitempty"`
+	TrimmedMean  float64 `json:"trimmed_mean,omitempty"`
 }
 
 // Analyze analyzes a collection of integers by leveraging IntCollection
@@ -330,21 +59,127 @@ func (iu *IntUtilities) Analyze(values []int) *IntStats {
 	sorted := ic.Values()
 	sort.Ints(sorted)
 
-	if stats.Count >= 4 {
-		mid := stats.Count / 2
-		lowerHalf := sorted[:mid]
-		upperHalf := sorted[mid+stats.Count%2:]
-
-		stats.Q1 = median(lowerHalf)
-		stats.Q3 = median(upperHalf)
+	if q1, q3, ok := quartiles(sorted); ok {
+		stats.Q1 = q1
+		stats.Q3 = q3
 		stats.IQR = stats.Q3 - stats.Q1
 	}
 
 	return stats
 }
 
+// Outlier detection methods for Outliers.
+const (
+	// OutlierIQR flags values more than 1.5×IQR below Q1 or above Q3.
+	OutlierIQR = "iqr"
+	// OutlierZScore flags values more than 3 standard deviations from the
+	// mean.
+	OutlierZScore = "zscore"
+)
+
+// outlierZThreshold is the |z| above which OutlierZScore flags a value.
+const outlierZThreshold = 3.0
+
+// Outlier is a flagged value and its index in the input.
+type Outlier struct {
+	Index int `json:"index"`
+	Value int `json:"value"`
+}
+
+// Outliers returns the values the method flags, in input order. Samples
+// of fewer than 4 values have no outliers, and neither do samples with no
+// spread.
+func (iu *IntUtilities) Outliers(values []int, method string) ([]Outlier, error) {
+	var flagged func(v int) bool
+	switch method {
+	case OutlierIQR:
+		sorted := append([]int(nil), values...)
+		sort.Ints(sorted)
+		q1, q3, ok := quartiles(sorted)
+		if !ok {
+			return nil, nil
+		}
+		iqr := q3 - q1
+		lo, hi := q1-1.5*iqr, q3+1.5*iqr
+		flagged = func(v int) bool { return float64(v) < lo || float64(v) > hi }
+	case OutlierZScore:
+		if len(values) < 4 {
+			return nil, nil
+		}
+		ic := NewIntCollection(values...)
+		mean, sd := ic.Average(), ic.StandardDeviation()
+		if sd == 0 {
+			return nil, nil
+		}
+		flagged = func(v int) bool { return math.Abs(float64(v)-mean)/sd > outlierZThreshold }
+	default:
+		return nil, fmt.Errorf("unknown outlier method %q (want %q or %q)", method, OutlierIQR, OutlierZScore)
+	}
+
+	var out []Outlier
+	for i, v := range values {
+		if flagged(v) {
+			out = append(out, Outlier{Index: i, Value: v})
+		}
+	}
+	return out, nil
+}
+
+// TrimmedStats analyzes values after dropping trimPercent percent of them
+// from each end of the sorted sample, rounding the count dropped down.
+// trimPercent must be in [0, 50).
+func (iu *IntUtilities) TrimmedStats(values []int, trimPercent float64) (*IntStats, error) {
+	if trimPercent < 0 || trimPercent >= 50 || math.IsNaN(trimPercent) {
+		return nil, fmt.Errorf("trim percent must be in [0, 50), got %v", trimPercent)
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	k := int(float64(len(sorted)) * trimPercent / 100)
+	return iu.Analyze(sorted[k : len(sorted)-k]), nil
+}
+
+// IntAnalysisOptions selects the extra statistics AnalyzeWith computes.
+type IntAnalysisOptions struct {
+	// OutlierMethod, when set, fills OutlierCount (see Outliers).
+	OutlierMethod string
+	// TrimPercent, when positive, fills TrimmedMean (see TrimmedStats).
+	TrimPercent float64
+}
+
+// AnalyzeWith is Analyze plus the statistics opts asks for.
+func (iu *IntUtilities) AnalyzeWith(values []int, opts IntAnalysisOptions) (*IntStats, error) {
+	stats := iu.Analyze(values)
+	if opts.OutlierMethod != "" {
+		outliers, err := iu.Outliers(values, opts.OutlierMethod)
+		if err != nil {
+			return nil, err
+		}
+		stats.OutlierCount = len(outliers)
+	}
+	if opts.TrimPercent > 0 {
+		trimmed, err := iu.TrimmedStats(values, opts.TrimPercent)
+		if err != nil {
+			return nil, err
+		}
+		stats.TrimmedMean = trimmed.Mean
+	}
+	return stats, nil
+}
+
 // Helper functions
 
+// quartiles returns Q1 and Q3 of sorted as the medians of its lower and
+// upper halves, excluding the middle value of odd samples. It needs at
+// least 4 values.
+func quartiles(sorted []int) (q1, q3 float64, ok bool) {
+	n := len(sorted)
+	if n < 4 {
+		return 0, 0, false
+	}
+	mid := n / 2
+	return median(sorted[:mid]), median(sorted[mid+n%2:]), true
+}
+
 func median(values []int) float64 {
 	n := len(values)
 	if n == 0 {
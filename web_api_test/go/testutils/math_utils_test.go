@@ -0,0 +1,164 @@
+package testutils
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// refOutliers is a deliberately plain implementation of both methods.
+func refOutliers(values []int, method string) []Outlier {
+	n := len(values)
+	if n < 4 {
+		return nil
+	}
+	var flagged []Outlier
+	switch method {
+	case OutlierIQR:
+		s := make([]float64, n)
+		for i, v := range values {
+			s[i] = float64(v)
+		}
+		sort.Float64s(s)
+		half := func(xs []float64) float64 {
+			if len(xs)%2 == 1 {
+				return xs[len(xs)/2]
+			}
+			return (xs[len(xs)/2-1] + xs[len(xs)/2]) / 2
+		}
+		q1, q3 := half(s[:n/2]), half(s[(n+1)/2:])
+		for i, v := range values {
+			if x := float64(v); x < q1-1.5*(q3-q1) || x > q3+1.5*(q3-q1) {
+				flagged = append(flagged, Outlier{i, v})
+			}
+		}
+	case OutlierZScore:
+		var sum float64
+		for _, v := range values {
+			sum += float64(v)
+		}
+		mean := sum / float64(n)
+		var sq float64
+		for _, v := range values {
+			sq += (float64(v) - mean) * (float64(v) - mean)
+		}
+		sd := math.Sqrt(sq / float64(n))
+		for i, v := range values {
+			if sd > 0 && math.Abs(float64(v)-mean) > 3*sd {
+				flagged = append(flagged, Outlier{i, v})
+			}
+		}
+	}
+	return flagged
+}
+
+func randomSample(r *rand.Rand) []int {
+	n := r.Intn(40)
+	values := make([]int, n)
+	for i := range values {
+		values[i] = r.Intn(200) - 100
+		if r.Intn(15) == 0 {
+			values[i] *= 300 // the occasional 30-second latency
+		}
+	}
+	return values
+}
+
+func TestIntUtilities_OutliersMatchReference(t *testing.T) {
+	iu := NewIntUtilities()
+	r := rand.New(rand.NewSource(1))
+	for trial := 0; trial < 2000; trial++ {
+		values := randomSample(r)
+		for _, method := range []string{OutlierIQR, OutlierZScore} {
+			got, err := iu.Outliers(values, method)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := refOutliers(values, method); !reflect.DeepEqual(got, want) {
+				t.Fatalf("%s outliers of %v:\n got %v\nwant %v", method, values, got, want)
+			}
+		}
+	}
+}
+
+func TestIntUtilities_OutliersEdgeCases(t *testing.T) {
+	iu := NewIntUtilities()
+	for _, values := range [][]int{nil, {5}, {1, 1000000, 2}, {7, 7, 7, 7, 7, 7}, {-3, -3, -3, -3}} {
+		for _, method := range []string{OutlierIQR, OutlierZScore} {
+			got, err := iu.Outliers(values, method)
+			if err != nil || len(got) != 0 {
+				t.Errorf("%s outliers of %v: got %v, %v; want none", method, values, got, err)
+			}
+		}
+	}
+
+	latencies := []int{120, 130, 125, 118, 30000, 122, 127, 119, 131, 124}
+	got, _ := iu.Outliers(latencies, OutlierIQR)
+	if want := []Outlier{{Index: 4, Value: 30000}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IQR outliers: got %v, want %v", got, want)
+	}
+	if _, err := iu.Outliers(latencies, "mad"); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+}
+
+func TestIntUtilities_TrimmedStatsMatchesReference(t *testing.T) {
+	iu := NewIntUtilities()
+	r := rand.New(rand.NewSource(2))
+	for trial := 0; trial < 1000; trial++ {
+		values := randomSample(r)
+		pct := float64(r.Intn(50))
+		got, err := iu.TrimmedStats(values, pct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sorted := append([]int(nil), values...)
+		sort.Ints(sorted)
+		k := len(sorted) * int(pct) / 100
+		kept := sorted[k : len(sorted)-k]
+		var sum int
+		for _, v := range kept {
+			sum += v
+		}
+		if got.Count != len(kept) || got.Sum != sum {
+			t.Fatalf("trim %v%% of %v: got count %d sum %d, want %d and %d", pct, values, got.Count, got.Sum, len(kept), sum)
+		}
+	}
+
+	for _, pct := range []float64{-1, 50, math.NaN()} {
+		if _, err := iu.TrimmedStats([]int{1, 2, 3}, pct); err == nil {
+			t.Errorf("expected an error for trim percent %v", pct)
+		}
+	}
+}
+
+func TestIntUtilities_AnalyzeWithFillsOptionalFields(t *testing.T) {
+	iu := NewIntUtilities()
+	latencies := []int{120, 130, 125, 118, 30000, 122, 127, 119, 131, 124}
+
+	plain := iu.Analyze(latencies)
+	if plain.OutlierCount != 0 || plain.TrimmedMean != 0 {
+		t.Errorf("Analyze should leave the optional fields unset, got %+v", plain)
+	}
+
+	stats, err := iu.AnalyzeWith(latencies, IntAnalysisOptions{OutlierMethod: OutlierIQR, TrimPercent: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.OutlierCount != 1 {
+		t.Errorf("expected 1 outlier, got %d", stats.OutlierCount)
+	}
+	// Dropping one value from each end leaves 119..131.
+	if want := float64(119+120+122+124+125+127+130+131) / 8; stats.TrimmedMean != want {
+		t.Errorf("trimmed mean: got %v, want %v", stats.TrimmedMean, want)
+	}
+	if stats.Mean < 3000 {
+		t.Errorf("the untrimmed mean should still include the outlier, got %v", stats.Mean)
+	}
+
+	if _, err := iu.AnalyzeWith(latencies, IntAnalysisOptions{OutlierMethod: "bogus"}); err == nil {
+		t.Error("expected an error for an unknown outlier method")
+	}
+}
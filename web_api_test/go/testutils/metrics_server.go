@@ -0,0 +1,310 @@
+package testutils
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ------------------------------------------------------------------------
+// MetricsServer – dependency-free Prometheus exposition
+// ------------------------------------------------------------------------
+
+// metricKind is the Prometheus type of a metric family.
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// MetricsServer holds counters, gauges and histograms and serves them in
+// the Prometheus text format. It covers what the test servers need without
+// pulling in the Prometheus client library.
+type MetricsServer struct {
+	mu            sync.RWMutex
+	buckets       []float64
+	defaultLabels map[string]string
+	families      map[string]*metricFamily
+}
+
+// NewMetricsServer creates a server whose histograms default to
+// cfg.HistogramBuckets and whose series all carry cfg.DefaultLabels.
+func NewMetricsServer(cfg MetricsConfig) *MetricsServer {
+	buckets := append([]float64(nil), cfg.HistogramBuckets...)
+	if len(buckets) == 0 {
+		buckets = DefaultConfig().Metrics.HistogramBuckets
+	}
+	sort.Float64s(buckets)
+	labels := make(map[string]string, len(cfg.DefaultLabels))
+	for k, v := range cfg.DefaultLabels {
+		labels[k] = v
+	}
+	return &MetricsServer{
+		buckets:       buckets,
+		defaultLabels: labels,
+		families:      make(map[string]*metricFamily),
+	}
+}
+
+// Counter registers a counter, or returns the one already registered
+// under name. It panics if name is taken by a different kind of metric or
+// with other labels.
+func (m *MetricsServer) Counter(name, help string, labelNames ...string) *MetricVec {
+	return m.register(name, help, kindCounter, nil, labelNames)
+}
+
+// Gauge registers a gauge; see Counter.
+func (m *MetricsServer) Gauge(name, help string, labelNames ...string) *MetricVec {
+	return m.register(name, help, kindGauge, nil, labelNames)
+}
+
+// Histogram registers a histogram with the server's buckets; see Counter.
+func (m *MetricsServer) Histogram(name, help string, labelNames ...string) *MetricVec {
+	return m.register(name, help, kindHistogram, m.buckets, labelNames)
+}
+
+func (m *MetricsServer) register(name, help string, kind metricKind, buckets []float64, labelNames []string) *MetricVec {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f, ok := m.families[name]; ok {
+		if f.kind != kind || strings.Join(f.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(fmt.Sprintf("MetricsServer: %s is already registered as a %s with labels %v", name, f.kind, f.labelNames))
+		}
+		return &MetricVec{family: f}
+	}
+	f := &metricFamily{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		buckets:    buckets,
+		labelNames: append([]string(nil), labelNames...),
+		series:     make(map[string]*metricSeries),
+	}
+	m.families[name] = f
+	return &MetricVec{family: f}
+}
+
+// Handler serves the current values in the Prometheus text format, for
+// mounting at /metrics.
+func (m *MetricsServer) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		m.write(bw)
+		bw.Flush()
+	})
+}
+
+// String renders the current values as Handler would.
+func (m *MetricsServer) String() string {
+	var sb strings.Builder
+	bw := bufio.NewWriter(&sb)
+	m.write(bw)
+	bw.Flush()
+	return sb.String()
+}
+
+func (m *MetricsServer) write(w *bufio.Writer) {
+	m.mu.RLock()
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	families := make([]*metricFamily, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		families = append(families, m.families[name])
+	}
+	m.mu.RUnlock()
+
+	for _, f := range families {
+		f.write(w, m.defaultLabels)
+	}
+}
+
+// metricFamily is every series of one metric.
+type metricFamily struct {
+	name       string
+	help       string
+	kind       metricKind
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*metricSeries // keyed by the joined label values
+}
+
+type metricSeries struct {
+	labelValues []string
+	value       float64  // counter and gauge value; histogram sum
+	count       uint64   // histogram observations
+	counts      []uint64 // histogram observations per bucket, not cumulative
+}
+
+func (f *metricFamily) seriesFor(labelValues []string) *metricSeries {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("MetricsServer: %s takes labels %v, got %d values", f.name, f.labelNames, len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &metricSeries{labelValues: append([]string(nil), labelValues...)}
+		if f.kind == kindHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *metricFamily) write(w *bufio.Writer, defaults map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		labels := f.labelPairs(defaults, s.labelValues)
+		if f.kind != kindHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, formatLabels(labels), formatMetricValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range f.buckets {
+			cumulative += s.counts[i]
+			le := append(labels, [2]string{"le", formatMetricValue(upper)})
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, formatLabels(append(labels, [2]string{"le", "+Inf"})), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, formatLabels(labels), formatMetricValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", f.name, formatLabels(labels), s.count)
+	}
+}
+
+// labelPairs merges the default labels, sorted by name, ahead of the
+// series' own labels, which win on a clash.
+func (f *metricFamily) labelPairs(defaults map[string]string, values []string) [][2]string {
+	pairs := make([][2]string, 0, len(defaults)+len(values)+1)
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	own := make(map[string]bool, len(f.labelNames))
+	for _, name := range f.labelNames {
+		own[name] = true
+	}
+	for _, name := range names {
+		if !own[name] {
+			pairs = append(pairs, [2]string{name, defaults[name]})
+		}
+	}
+	for i, name := range f.labelNames {
+		pairs = append(pairs, [2]string{name, values[i]})
+	}
+	return pairs
+}
+
+func formatLabels(pairs [][2]string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p[0] + `="` + escape.Replace(p[1]) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// MetricVec is a registered metric; the label values passed to its
+// methods select the series, in the order the label names were given.
+type MetricVec struct {
+	family *metricFamily
+}
+
+// Inc adds one to a counter or gauge.
+func (v *MetricVec) Inc(labelValues ...string) { v.Add(1, labelValues...) }
+
+// Dec subtracts one from a gauge.
+func (v *MetricVec) Dec(labelValues ...string) { v.Add(-1, labelValues...) }
+
+// Add adds delta to a counter or gauge. Counters panic on a negative
+// delta.
+func (v *MetricVec) Add(delta float64, labelValues ...string) {
+	f := v.family
+	if f.kind == kindHistogram || (f.kind == kindCounter && delta < 0) {
+		panic(fmt.Sprintf("MetricsServer: cannot add %v to %s %s", delta, f.kind, f.name))
+	}
+	f.mu.Lock()
+	f.seriesFor(labelValues).value += delta
+	f.mu.Unlock()
+}
+
+// Set sets a gauge.
+func (v *MetricVec) Set(value float64, labelValues ...string) {
+	f := v.family
+	if f.kind != kindGauge {
+		panic(fmt.Sprintf("MetricsServer: cannot set %s %s", f.kind, f.name))
+	}
+	f.mu.Lock()
+	f.seriesFor(labelValues).value = value
+	f.mu.Unlock()
+}
+
+// Observe records value in a histogram.
+func (v *MetricVec) Observe(value float64, labelValues ...string) {
+	f := v.family
+	if f.kind != kindHistogram {
+		panic(fmt.Sprintf("MetricsServer: cannot observe %s %s", f.kind, f.name))
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.seriesFor(labelValues)
+	s.value += value
+	s.count++
+	if i := sort.SearchFloat64s(f.buckets, value); i < len(f.buckets) {
+		s.counts[i]++
+	}
+}
+
+// Value returns a counter's or gauge's value, or a histogram's
+// observation count.
+func (v *MetricVec) Value(labelValues ...string) float64 {
+	f := v.family
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s, ok := f.series[strings.Join(labelValues, "\xff")]
+	switch {
+	case !ok:
+		return 0
+	case f.kind == kindHistogram:
+		return float64(s.count)
+	}
+	return s.value
+}
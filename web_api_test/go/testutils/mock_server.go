@@ -0,0 +1,407 @@
+// Package testutils provides utilities for testing web APIs.
+package testutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// MockServer – scriptable HTTP upstream for exercising retry and error paths
+// ------------------------------------------------------------------------
+
+// MockResponse describes a single scripted reply from a MockServer route.
+type MockResponse struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+	// Delay is applied before any bytes are written. A delay longer than the
+	// client timeout simulates a hanging upstream.
+	Delay time.Duration
+	// Drop closes the underlying connection without writing a response.
+	Drop bool
+	// DribbleChunk and DribbleInterval, when both set, write the body in
+	// chunks of DribbleChunk bytes with DribbleInterval between them.
+	DribbleChunk    int
+	DribbleInterval time.Duration
+}
+
+// Resp returns an empty response with the given status code.
+func Resp(status int) MockResponse {
+	return MockResponse{Status: status, Headers: make(http.Header)}
+}
+
+// JSONResp returns a response whose body is the JSON encoding of v.
+// It panics if v cannot be marshalled, as that is a bug in the test itself.
+func JSONResp(status int, v interface{}) MockResponse {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("JSONResp: failed to marshal: %v", err))
+	}
+	r := Resp(status)
+	r.Headers.Set("Content-Type", "application/json")
+	r.Body = data
+	return r
+}
+
+// TextResp returns a plain-text response.
+func TextResp(status int, text string) MockResponse {
+	r := Resp(status)
+	r.Headers.Set("Content-Type", "text/plain; charset=utf-8")
+	r.Body = []byte(text)
+	return r
+}
+
+// DropResp returns a response that closes the connection mid-request.
+func DropResp() MockResponse {
+	return MockResponse{Drop: true}
+}
+
+// WithDelay returns a copy of r that waits d before responding.
+func (r MockResponse) WithDelay(d time.Duration) MockResponse {
+	r.Delay = d
+	return r
+}
+
+// WithHeader returns a copy of r with header key set to value.
+func (r MockResponse) WithHeader(key, value string) MockResponse {
+	r.Headers = r.Headers.Clone()
+	if r.Headers == nil {
+		r.Headers = make(http.Header)
+	}
+	r.Headers.Set(key, value)
+	return r
+}
+
+// WithDribble returns a copy of r that writes its body slowly.
+func (r MockResponse) WithDribble(chunk int, interval time.Duration) MockResponse {
+	r.DribbleChunk = chunk
+	r.DribbleInterval = interval
+	return r
+}
+
+// RecordedRequest captures a request received by a MockServer.
+type RecordedRequest struct {
+	Method     string
+	Path       string
+	Query      url.Values
+	Headers    http.Header
+	Body       []byte
+	ReceivedAt time.Time
+}
+
+// MockRoute holds the scripted responses for a single method and path.
+type MockRoute struct {
+	mu        sync.Mutex
+	responses []MockResponse
+	latency   time.Duration
+	calls     int
+	limiter   *KeyedRateLimiter
+}
+
+// Respond sets a single response that is returned for every call.
+func (r *MockRoute) Respond(resp MockResponse) *MockRoute {
+	return r.RespondSequence(resp)
+}
+
+// RespondSequence scripts responses returned in order, one per call. Once the
+// sequence is exhausted the last response is repeated.
+func (r *MockRoute) RespondSequence(resps ...MockResponse) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append([]MockResponse(nil), resps...)
+	r.calls = 0
+	return r
+}
+
+// WithLatency adds a fixed delay to every response on this route, on top of
+// any per-response Delay.
+func (r *MockRoute) WithLatency(d time.Duration) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latency = d
+	return r
+}
+
+// WithRateLimit answers 429 with Retry-After once l has no token left for
+// the request, as a rate-limited upstream would. Rejected requests are
+// recorded but do not advance the response sequence or count as calls.
+func (r *MockRoute) WithRateLimit(l *KeyedRateLimiter) *MockRoute {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.limiter = l
+	return r
+}
+
+// Calls returns how many requests this route has served.
+func (r *MockRoute) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+// next returns the response for the current call and advances the sequence.
+func (r *MockRoute) next() (MockResponse, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls++
+	if len(r.responses) == 0 {
+		return Resp(http.StatusOK), r.latency
+	}
+	idx := r.calls - 1
+	if idx >= len(r.responses) {
+		idx = len(r.responses) - 1
+	}
+	return r.responses[idx], r.latency
+}
+
+// MockServer is an httptest.Server whose routes are scripted by the test.
+// Unmatched requests receive 404 and are still recorded.
+type MockServer struct {
+	// Server is the underlying test server.
+	Server *httptest.Server
+	// URL is the base URL of the server, e.g. "http://127.0.0.1:54321".
+	URL string
+
+	mu       sync.Mutex
+	routes   map[string]*MockRoute
+	requests []RecordedRequest
+}
+
+// NewMockServer starts a new MockServer. Call Close when done, or pass a
+// testing.TB via NewMockServerT to have it closed automatically.
+func NewMockServer() *MockServer {
+	ms := &MockServer{routes: make(map[string]*MockRoute)}
+	ms.Server = httptest.NewServer(http.HandlerFunc(ms.serveHTTP))
+	ms.URL = ms.Server.URL
+	return ms
+}
+
+// NewMockServerT starts a MockServer and registers Close with t.Cleanup.
+func NewMockServerT(t interface{ Cleanup(func()) }) *MockServer {
+	ms := NewMockServer()
+	t.Cleanup(ms.Close)
+	return ms
+}
+
+// On returns the route for method and path, creating it if necessary.
+func (ms *MockServer) On(method, path string) *MockRoute {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	key := method + " " + path
+	route, ok := ms.routes[key]
+	if !ok {
+		route = &MockRoute{}
+		ms.routes[key] = route
+	}
+	return route
+}
+
+// Requests returns a copy of all requests received so far.
+func (ms *MockServer) Requests() []RecordedRequest {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	out := make([]RecordedRequest, len(ms.requests))
+	copy(out, ms.requests)
+	return out
+}
+
+// RequestsFor returns the recorded requests matching method and path.
+func (ms *MockServer) RequestsFor(method, path string) []RecordedRequest {
+	var out []RecordedRequest
+	for _, r := range ms.Requests() {
+		if r.Method == method && r.Path == path {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// Reset clears recorded requests and all scripted routes.
+func (ms *MockServer) Reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.routes = make(map[string]*MockRoute)
+	ms.requests = nil
+}
+
+// Close shuts down the server. Hanging handlers are released because their
+// request contexts are cancelled.
+func (ms *MockServer) Close() {
+	ms.Server.CloseClientConnections()
+	ms.Server.Close()
+}
+
+// Addr returns the listener address as "host:port".
+func (ms *MockServer) Addr() string {
+	return ms.Server.Listener.Addr().String()
+}
+
+// ServiceCheck describes a readiness target in the same "host:port" form that
+// DockerConfig.Services uses, so suites without Docker can reuse the same
+// wait logic against a MockServer.
+type ServiceCheck struct {
+	Name    string
+	Address string
+	Target  PortTarget
+}
+
+// ServiceCheck returns a readiness target pointing at this server.
+func (ms *MockServer) ServiceCheck(name string) ServiceCheck {
+	host, portStr, _ := net.SplitHostPort(ms.Addr())
+	port, _ := strconv.Atoi(portStr)
+	return ServiceCheck{
+		Name:    name,
+		Address: ms.Addr(),
+		Target:  PortTarget{Host: host, Port: port, Protocol: TCP},
+	}
+}
+
+func (ms *MockServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body.Close()
+
+	ms.mu.Lock()
+	ms.requests = append(ms.requests, RecordedRequest{
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Headers:    r.Header.Clone(),
+		Body:       body,
+		ReceivedAt: time.Now(),
+	})
+	route, ok := ms.routes[r.Method+" "+r.URL.Path]
+	ms.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	route.mu.Lock()
+	limiter := route.limiter
+	route.mu.Unlock()
+	if limiter != nil {
+		if d := limiter.allowRequest(r); !d.Allowed {
+			limited := rateLimitedResponse(d)
+			for k, v := range limited.Headers {
+				w.Header()[k] = v
+			}
+			w.WriteHeader(limited.Status)
+			json.NewEncoder(w).Encode(limited.Body)
+			return
+		}
+	}
+
+	resp, latency := route.next()
+	if !sleepCtx(r, latency+resp.Delay) {
+		return
+	}
+
+	if resp.Drop {
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+		}
+		panic(http.ErrAbortHandler)
+	}
+
+	for k, v := range resp.Headers {
+		w.Header()[k] = v
+	}
+	status := resp.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if resp.DribbleChunk <= 0 || resp.DribbleInterval <= 0 {
+		w.WriteHeader(status)
+		w.Write(resp.Body)
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+	w.WriteHeader(status)
+	flusher, _ := w.(http.Flusher)
+	rd := bytes.NewReader(resp.Body)
+	chunk := make([]byte, resp.DribbleChunk)
+	for {
+		n, err := rd.Read(chunk)
+		if n > 0 {
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil || rd.Len() == 0 {
+			return
+		}
+		if !sleepCtx(r, resp.DribbleInterval) {
+			return
+		}
+	}
+}
+
+// sleepCtx waits for d or until the request is cancelled; it reports whether
+// the full duration elapsed.
+func sleepCtx(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// ------------------------------------------------------------------------
+// Assertions
+// ------------------------------------------------------------------------
+
+// AssertRequestCount asserts that the server received exactly n requests for
+// method and path.
+func (a *ComponentAssertions) AssertRequestCount(ms *MockServer, method, path string, n int) {
+	if got := len(ms.RequestsFor(method, path)); got != n {
+		a.t.Errorf("expected %d %s %s requests, got %d", n, method, path, got)
+	}
+}
+
+// AssertRequestReceived asserts that at least one request for method and path
+// was received.
+func (a *ComponentAssertions) AssertRequestReceived(ms *MockServer, method, path string) {
+	if len(ms.RequestsFor(method, path)) == 0 {
+		a.t.Errorf("expected %s %s to be requested, but it wasn't", method, path)
+	}
+}
+
+// AssertRequestHeader asserts that every request for method and path carried
+// the header with the given value.
+func (a *ComponentAssertions) AssertRequestHeader(ms *MockServer, method, path, header, value string) {
+	reqs := ms.RequestsFor(method, path)
+	if len(reqs) == 0 {
+		a.t.Errorf("expected %s %s to be requested, but it wasn't", method, path)
+		return
+	}
+	for i, r := range reqs {
+		if got := r.Headers.Get(header); got != value {
+			a.t.Errorf("request %d to %s %s: expected header %s=%q, got %q", i+1, method, path, header, value, got)
+		}
+	}
+}
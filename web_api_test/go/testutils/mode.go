@@ -6,6 +6,8 @@ package testutils
 
 import (
     "errors"
+    "math/rand"
+    "os"
     "sync"
     "time"
 )
@@ -170,6 +172,7 @@ type InMemoryModeManager struct {
     mode     Mode
     watchers []chan Mode
     closed   bool
+    events   *EventBus
 }
 
 func NewInMemoryModeManager(initial Mode) *InMemoryModeManager {
@@ -184,13 +187,24 @@ func (m *InMemoryModeManager) CurrentMode() Mode {
     return m.mode
 }
 
+// SetEventBus makes the manager publish mode_changed events to bus.
+func (m *InMemoryModeManager) SetEventBus(bus *EventBus) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.events = bus
+}
+
 func (m *InMemoryModeManager) SetMode(mode Mode) {
     m.mu.Lock()
     defer m.mu.Unlock()
     if m.closed {
         return
     }
+    prev := m.mode
     m.mode = mode
+    if prev != mode {
+        m.events.Emit(EventModeChanged, "mode", map[string]any{"from": string(prev), "to": string(mode)})
+    }
     for _, ch := range m.watchers {
         select {
         case ch <- mode:
@@ -228,6 +242,9 @@ func (m *InMemoryModeManager) Close() error {
     return nil
 }
 
+// degradedDelay is the extra latency ModeDegraded adds to each operation.
+const degradedDelay = 50 * time.Millisecond
+
 // --------------------------------------------------------------------
 // ModeAwareDisk – wraps a Disk and enforces mode semantics.
 // --------------------------------------------------------------------
@@ -241,6 +258,9 @@ type ModeAwareDisk struct {
     mu    sync.Mutex
     // For ModeFlaky: control failure probability (0.0–1.0)
     flakyRate float64
+    clock     Clock
+    faults    *faultInjector // nil unless SetFaultPlan was called
+    faultLog  FaultLog
 }
 
 func NewModeAwareDisk(disk Disk, mgr ModeManager) *ModeAwareDisk {
@@ -257,6 +277,33 @@ func (d *ModeAwareDisk) SetFlakyRate(rate float64) {
     d.flakyRate = rate
 }
 
+// SetClock replaces the clock used for degraded-mode delays.
+func (d *ModeAwareDisk) SetClock(clock Clock) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.clock = clock
+}
+
+// SetFaultPlan injects short writes, delayed Sync errors and corrupt reads
+// into files opened from now on, in every mode that lets the operation
+// through. The plan's source is reseeded; FaultLog keeps earlier entries.
+func (d *ModeAwareDisk) SetFaultPlan(plan FaultPlan) {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    d.faults = newFaultInjector(plan, &d.faultLog)
+}
+
+// FaultLog returns the faults injected so far.
+func (d *ModeAwareDisk) FaultLog() *FaultLog {
+    return &d.faultLog
+}
+
+func (d *ModeAwareDisk) wrapFile(f File) *modeAwareFile {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return &modeAwareFile{file: f, disk: d, faults: d.faults}
+}
+
 func (d *ModeAwareDisk) checkMode(write bool) error {
     mode := d.mgr.CurrentMode()
     switch mode {
@@ -264,7 +311,10 @@ func (d *ModeAwareDisk) checkMode(write bool) error {
         return nil
     case ModeDegraded:
         // Add a small delay to simulate slowness
-        time.Sleep(50 * time.Millisecond)
+        d.mu.Lock()
+        clock := d.clock
+        d.mu.Unlock()
+        sleepWith(clock, degradedDelay)
         return nil
     case ModeReadOnly:
         if write {
@@ -295,7 +345,7 @@ func (d *ModeAwareDisk) Open(name string) (File, error) {
         return nil, err
     }
     // Wrap the file to also check mode on read/write
-    return &modeAwareFile{file: f, disk: d}, nil
+    return d.wrapFile(f), nil
 }
 
 func (d *ModeAwareDisk) Create(name string) (File, error) {
@@ -306,7 +356,7 @@ func (d *ModeAwareDisk) Create(name string) (File, error) {
     if err != nil {
         return nil, err
     }
-    return &modeAwareFile{file: f, disk: d}, nil
+    return d.wrapFile(f), nil
 }
 
 func (d *ModeAwareDisk) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
@@ -318,7 +368,7 @@ func (d *ModeAwareDisk) OpenFile(name string, flag int, perm os.FileMode) (File,
     if err != nil {
         return nil, err
     }
-    return &modeAwareFile{file: f, disk: d}, nil
+    return d.wrapFile(f), nil
 }
 
 func (d *ModeAwareDisk) Remove(name string) error {
@@ -360,20 +410,47 @@ func (d *ModeAwareDisk) MkdirAll(name string, perm os.FileMode) error {
 type modeAwareFile struct {
     file File
     disk *ModeAwareDisk
+
+    // Fault injection state: the plan in force when the file was opened,
+    // the stream position for fault offsets, and an error held for Sync.
+    faults   *faultInjector
+    mu       sync.Mutex
+    pos      int64
+    syncErr  error
 }
 
 func (f *modeAwareFile) Read(p []byte) (int, error) {
     if err := f.disk.checkMode(false); err != nil {
         return 0, err
     }
-    return f.file.Read(p)
+    n, err := f.file.Read(p)
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.faults.corruptRead(f.file.Name(), f.pos, p, n)
+    f.pos += int64(n)
+    return n, err
 }
 
 func (f *modeAwareFile) Write(p []byte) (int, error) {
     if err := f.disk.checkMode(true); err != nil {
         return 0, err
     }
-    return f.file.Write(p)
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    offset := f.pos
+    if short, ok := f.faults.shortWrite(f.file.Name(), offset, p); ok {
+        // Store the prefix, then report it as if it were everything
+        // there was to report: n < len(p) with no error.
+        n, err := f.file.Write(p[:short])
+        f.pos += int64(n)
+        return n, err
+    }
+    n, err := f.file.Write(p)
+    f.pos += int64(n)
+    if err == nil && f.syncErr == nil {
+        f.syncErr = f.faults.delayedError(f.file.Name(), offset, n)
+    }
+    return n, err
 }
 
 func (f *modeAwareFile) Seek(offset int64, whence int) (int64, error) {
@@ -381,7 +458,13 @@ func (f *modeAwareFile) Seek(offset int64, whence int) (int64, error) {
     if err := f.disk.checkMode(false); err != nil {
         return 0, err
     }
-    return f.file.Seek(offset, whence)
+    pos, err := f.file.Seek(offset, whence)
+    if err == nil {
+        f.mu.Lock()
+        f.pos = pos
+        f.mu.Unlock()
+    }
+    return pos, err
 }
 
 func (f *modeAwareFile) Close() error {
@@ -393,7 +476,15 @@ func (f *modeAwareFile) Sync() error {
     if err := f.disk.checkMode(true); err != nil {
         return err
     }
-    return f.file.Sync()
+    if err := f.file.Sync(); err != nil {
+        return err
+    }
+    // A delayed fault surfaces once, like a lost write reported by fsync.
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    err := f.syncErr
+    f.syncErr = nil
+    return err
 }
 
 func (f *modeAwareFile) Stat() (FileInfo, error) {
@@ -416,6 +507,7 @@ type ModeAwareCollector struct {
     mgr  ModeManager
     mu   sync.Mutex
     flakyRate float64
+    clock     Clock
 }
 
 func NewModeAwareCollector(coll Collector, mgr ModeManager) *ModeAwareCollector {
@@ -431,13 +523,23 @@ func (c *ModeAwareCollector) SetFlakyRate(rate float64) {
     c.flakyRate = rate
 }
 
+// SetClock replaces the clock used for degraded-mode delays.
+func (c *ModeAwareCollector) SetClock(clock Clock) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.clock = clock
+}
+
 func (c *ModeAwareCollector) checkMode() error {
     mode := c.mgr.CurrentMode()
     switch mode {
     case ModeNormal:
         return nil
     case ModeDegraded:
-        time.Sleep(50 * time.Millisecond)
+        c.mu.Lock()
+        clock := c.clock
+        c.mu.Unlock()
+        sleepWith(clock, degradedDelay)
         return nil
     case ModeReadOnly, ModeOffline, ModeMaintenance:
         return errors.New("mode aware collector: collection unavailable (" + string(mode) + ")")
@@ -491,6 +593,7 @@ type ModeAwareFree struct {
     mgr  ModeManager
     mu   sync.Mutex
     flakyRate float64
+    clock     Clock
 }
 
 func NewModeAwareFree(free Free, mgr ModeManager) *ModeAwareFree {
@@ -506,13 +609,23 @@ func (f *ModeAwareFree) SetFlakyRate(rate float64) {
     f.flakyRate = rate
 }
 
+// SetClock replaces the clock used for degraded-mode delays.
+func (f *ModeAwareFree) SetClock(clock Clock) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.clock = clock
+}
+
 func (f *ModeAwareFree) checkMode() error {
     mode := f.mgr.CurrentMode()
     switch mode {
     case ModeNormal:
         return nil
     case ModeDegraded:
-        time.Sleep(50 * time.Millisecond)
+        f.mu.Lock()
+        clock := f.clock
+        f.mu.Unlock()
+        sleepWith(clock, degradedDelay)
         return nil
     case ModeReadOnly, ModeOffline, ModeMaintenance:
         return errors.New("mode aware free: resource unavailable (" + string(mode) + ")")
@@ -566,6 +679,16 @@ type ModeAwareBuffer struct {
     mgr  ModeManager
     mu   sync.Mutex
     flakyRate float64
+    clock     Clock
+
+    // Fault injection; see SetFaultPlan. ioMu orders reads and writes so
+    // fault offsets match the stream.
+    faults    *faultInjector
+    faultLog  FaultLog
+    ioMu      sync.Mutex
+    readPos   int64
+    writePos  int64
+    closeErr  error
 }
 
 func NewModeAwareBuffer(buf Buffer, mgr ModeManager) *ModeAwareBuffer {
@@ -581,13 +704,39 @@ func (b *ModeAwareBuffer) SetFlakyRate(rate float64) {
     b.flakyRate = rate
 }
 
+// SetClock replaces the clock used for degraded-mode delays.
+func (b *ModeAwareBuffer) SetClock(clock Clock) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    b.clock = clock
+}
+
+// SetFaultPlan injects short writes, corrupt reads and delayed errors into
+// the buffer, in every mode that lets the operation through. A buffer has
+// no Sync, so delayed errors surface from Close. Offsets count bytes
+// written and read since the plan was set.
+func (b *ModeAwareBuffer) SetFaultPlan(plan FaultPlan) {
+    b.ioMu.Lock()
+    defer b.ioMu.Unlock()
+    b.faults = newFaultInjector(plan, &b.faultLog)
+    b.readPos, b.writePos = 0, 0
+}
+
+// FaultLog returns the faults injected so far.
+func (b *ModeAwareBuffer) FaultLog() *FaultLog {
+    return &b.faultLog
+}
+
 func (b *ModeAwareBuffer) checkMode(write bool) error {
     mode := b.mgr.CurrentMode()
     switch mode {
     case ModeNormal:
         return nil
     case ModeDegraded:
-        time.Sleep(50 * time.Millisecond)
+        b.mu.Lock()
+        clock := b.clock
+        b.mu.Unlock()
+        sleepWith(clock, degradedDelay)
         return nil
     case ModeReadOnly:
         if write {
@@ -613,19 +762,44 @@ func (b *ModeAwareBuffer) Read(p []byte) (int, error) {
     if err := b.checkMode(false); err != nil {
         return 0, err
     }
-    return b.buf.Read(p)
+    b.ioMu.Lock()
+    defer b.ioMu.Unlock()
+    n, err := b.buf.Read(p)
+    b.faults.corruptRead("buffer", b.readPos, p, n)
+    b.readPos += int64(n)
+    return n, err
 }
 
 func (b *ModeAwareBuffer) Write(p []byte) (int, error) {
     if err := b.checkMode(true); err != nil {
         return 0, err
     }
-    return b.buf.Write(p)
+    b.ioMu.Lock()
+    defer b.ioMu.Unlock()
+    offset := b.writePos
+    if short, ok := b.faults.shortWrite("buffer", offset, p); ok {
+        n, err := b.buf.Write(p[:short])
+        b.writePos += int64(n)
+        return n, err
+    }
+    n, err := b.buf.Write(p)
+    b.writePos += int64(n)
+    if err == nil && b.closeErr == nil {
+        b.closeErr = b.faults.delayedError("buffer", offset, n)
+    }
+    return n, err
 }
 
 func (b *ModeAwareBuffer) Close() error {
     // Close is usually allowed even in read‑only/offline? We'll allow.
-    return b.buf.Close()
+    if err := b.buf.Close(); err != nil {
+        return err
+    }
+    b.ioMu.Lock()
+    defer b.ioMu.Unlock()
+    err := b.closeErr
+    b.closeErr = nil
+    return err
 }
 
 func (b *ModeAwareBuffer) Len() int {
@@ -661,7 +835,6 @@ func (b *ModeAwareBuffer) Bytes() []byte {
 // --------------------------------------------------------------------
 // Helper (pseudo‑random for flaky mode)
 // --------------------------------------------------------------------
-import "math/rand"
 
 func randFloat() float64 {
     return rand.Float64()
@@ -0,0 +1,195 @@
+package testutils
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// modeGate holds the state behind ModeGate. The current mode is taken from
+// the manager's Watch channel, so changes apply to the next request.
+type modeGate struct {
+	mu   sync.RWMutex
+	mode Mode
+
+	retryAfter     time.Duration
+	message        string
+	readOnlyStatus int
+	latency        time.Duration
+	flakyRate      float64
+	clock          Clock
+
+	rngMu sync.Mutex
+	rng   *rand.Rand
+}
+
+// ModeGateOption configures ModeGate.
+type ModeGateOption func(*modeGate)
+
+// WithRetryAfter sets the Retry-After advertised in offline and
+// maintenance mode (default 30s).
+func WithRetryAfter(d time.Duration) ModeGateOption {
+	return func(g *modeGate) { g.retryAfter = d }
+}
+
+// WithMaintenanceMessage sets the message returned in maintenance mode.
+func WithMaintenanceMessage(msg string) ModeGateOption {
+	return func(g *modeGate) { g.message = msg }
+}
+
+// WithReadOnlyStatus sets the status for writes in read-only mode:
+// http.StatusMethodNotAllowed (the default) or http.StatusForbidden.
+func WithReadOnlyStatus(code int) ModeGateOption {
+	return func(g *modeGate) { g.readOnlyStatus = code }
+}
+
+// WithDegradedLatency sets the delay added to each request in degraded
+// mode (default 50ms, as for the mode-aware wrappers).
+func WithDegradedLatency(d time.Duration) ModeGateOption {
+	return func(g *modeGate) { g.latency = d }
+}
+
+// WithFlakyRate sets the fraction of requests failed in flaky mode
+// (default 0.5).
+func WithFlakyRate(rate float64) ModeGateOption {
+	return func(g *modeGate) { g.flakyRate = rate }
+}
+
+// WithFlakySeed seeds the random source for flaky mode so the same
+// requests fail on every run.
+func WithFlakySeed(seed int64) ModeGateOption {
+	return func(g *modeGate) { g.rng = rand.New(rand.NewSource(seed)) }
+}
+
+// WithModeGateTestRun seeds flaky mode from run's "flaky-modes" child seed.
+func WithModeGateTestRun(run *TestRun) ModeGateOption {
+	return func(g *modeGate) {
+		if run != nil {
+			g.rng = run.Rand("flaky-modes")
+		}
+	}
+}
+
+// WithModeGateClock sets the clock used for degraded-mode latency.
+func WithModeGateClock(c Clock) ModeGateOption {
+	return func(g *modeGate) {
+		if c != nil {
+			g.clock = c
+		}
+	}
+}
+
+// ModeGate returns middleware that makes the HTTP layer follow mgr's mode:
+//
+//   - ModeOffline and ModeMaintenance answer 503 with Retry-After
+//   - ModeReadOnly rejects methods other than GET, HEAD and OPTIONS
+//   - ModeDegraded delays each request
+//   - ModeFlaky fails a seeded fraction of requests with 500
+//
+// The gate watches mgr for its lifetime; closing the manager freezes the
+// last mode seen.
+func ModeGate(mgr ModeManager, opts ...ModeGateOption) Middleware {
+	return newModeGate(mgr, opts...).middleware
+}
+
+func newModeGate(mgr ModeManager, opts ...ModeGateOption) *modeGate {
+	// Subscribe before reading the mode, so a change landing in between is
+	// still delivered to the gate.
+	watch := mgr.Watch()
+	g := &modeGate{
+		mode:           mgr.CurrentMode(),
+		retryAfter:     30 * time.Second,
+		message:        "the service is undergoing maintenance",
+		readOnlyStatus: http.StatusMethodNotAllowed,
+		latency:        degradedDelay,
+		flakyRate:      0.5,
+		clock:          RealClock{},
+		rng:            rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	go func() {
+		defer markExpectedGoroutine()() // lives as long as the manager
+		for mode := range watch {
+			g.mu.Lock()
+			g.mode = mode
+			g.mu.Unlock()
+		}
+	}()
+	return g
+}
+
+func (g *modeGate) current() Mode {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.mode
+}
+
+func (g *modeGate) middleware(next Handler) Handler {
+	return func(ctx context.Context, req *Request) (*Response, error) {
+		switch mode := g.current(); mode {
+		case ModeOffline, ModeMaintenance:
+			return g.unavailable(mode), nil
+
+		case ModeReadOnly:
+			switch req.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				return g.readOnly(), nil
+			}
+
+		case ModeDegraded:
+			select {
+			case <-g.clock.After(g.latency):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+
+		case ModeFlaky:
+			g.rngMu.Lock()
+			fail := g.rng.Float64() < g.flakyRate
+			g.rngMu.Unlock()
+			if fail {
+				resp := ErrorResponse(http.StatusInternalServerError, "injected failure")
+				resp.Headers.Set("X-Mode", string(mode))
+				return resp, nil
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// unavailable builds the 503 answer for offline and maintenance mode.
+func (g *modeGate) unavailable(mode Mode) *Response {
+	seconds := int(g.retryAfter.Round(time.Second) / time.Second)
+	body := map[string]any{
+		"error":       "service unavailable",
+		"mode":        string(mode),
+		"retry_after": seconds,
+	}
+	if mode == ModeMaintenance {
+		body["message"] = g.message
+	}
+	resp, _ := JSON(http.StatusServiceUnavailable, body)
+	resp.Headers.Set("Retry-After", strconv.Itoa(seconds))
+	resp.Headers.Set("X-Mode", string(mode))
+	return resp
+}
+
+// readOnly builds the answer for a write in read-only mode.
+func (g *modeGate) readOnly() *Response {
+	resp, _ := JSON(g.readOnlyStatus, map[string]any{
+		"error": "service is read-only",
+		"mode":  string(ModeReadOnly),
+	})
+	if g.readOnlyStatus == http.StatusMethodNotAllowed {
+		resp.Headers.Set("Allow", "GET, HEAD, OPTIONS")
+	}
+	resp.Headers.Set("X-Mode", string(ModeReadOnly))
+	return resp
+}
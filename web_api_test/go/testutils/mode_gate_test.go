@@ -0,0 +1,195 @@
+package testutils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func okHandler(ctx context.Context, req *Request) (*Response, error) {
+	return JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// gateFor builds a gate on mgr and waits until it has observed mode.
+func gateFor(t *testing.T, mgr *InMemoryModeManager, mode Mode, opts ...ModeGateOption) Handler {
+	t.Helper()
+	g := newModeGate(mgr, opts...)
+	mgr.SetMode(mode)
+	deadline := time.Now().Add(time.Second)
+	for g.current() != mode {
+		if time.Now().After(deadline) {
+			t.Fatalf("gate never observed mode %q", mode)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return g.middleware(okHandler)
+}
+
+func serve(t *testing.T, h Handler, method string) *Response {
+	t.Helper()
+	req := &Request{Request: httptest.NewRequest(method, "/items", nil)}
+	resp, err := h(req.Context(), req)
+	if err != nil {
+		t.Fatalf("%s returned error: %v", method, err)
+	}
+	return resp
+}
+
+func TestModeGate_Normal(t *testing.T) {
+	mgr := NewInMemoryModeManager(ModeNormal)
+	defer mgr.Close()
+	h := gateFor(t, mgr, ModeNormal)
+	for _, m := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		if resp := serve(t, h, m); resp.Status != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", m, resp.Status)
+		}
+	}
+}
+
+func TestModeGate_OfflineAndMaintenance(t *testing.T) {
+	for _, mode := range []Mode{ModeOffline, ModeMaintenance} {
+		mgr := NewInMemoryModeManager(ModeNormal)
+		h := gateFor(t, mgr, mode, WithRetryAfter(2*time.Minute), WithMaintenanceMessage("back soon"))
+
+		resp := serve(t, h, http.MethodGet)
+		if resp.Status != http.StatusServiceUnavailable {
+			t.Errorf("%s: status %d, want 503", mode, resp.Status)
+		}
+		if got := resp.Headers.Get("Retry-After"); got != "120" {
+			t.Errorf("%s: Retry-After %q, want 120", mode, got)
+		}
+		body := resp.Body.(map[string]any)
+		if body["mode"] != string(mode) {
+			t.Errorf("%s: body mode %v", mode, body["mode"])
+		}
+		if _, ok := body["message"]; ok != (mode == ModeMaintenance) {
+			t.Errorf("%s: unexpected message presence in %v", mode, body)
+		}
+		mgr.Close()
+	}
+}
+
+func TestModeGate_ReadOnly(t *testing.T) {
+	for _, code := range []int{http.StatusMethodNotAllowed, http.StatusForbidden} {
+		mgr := NewInMemoryModeManager(ModeNormal)
+		h := gateFor(t, mgr, ModeReadOnly, WithReadOnlyStatus(code))
+
+		for _, m := range []string{http.MethodGet, http.MethodHead, http.MethodOptions} {
+			if resp := serve(t, h, m); resp.Status != http.StatusOK {
+				t.Errorf("%s: status %d, want 200", m, resp.Status)
+			}
+		}
+		for _, m := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if resp := serve(t, h, m); resp.Status != code {
+				t.Errorf("%s: status %d, want %d", m, resp.Status, code)
+			}
+		}
+		mgr.Close()
+	}
+}
+
+func TestModeGate_Degraded(t *testing.T) {
+	mgr := NewInMemoryModeManager(ModeNormal)
+	defer mgr.Close()
+	h := gateFor(t, mgr, ModeDegraded, WithDegradedLatency(30*time.Millisecond))
+
+	start := time.Now()
+	if resp := serve(t, h, http.MethodGet); resp.Status != http.StatusOK {
+		t.Errorf("status %d, want 200", resp.Status)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("request took %v, want at least 30ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := &Request{Request: httptest.NewRequest(http.MethodGet, "/items", nil)}
+	if _, err := h(ctx, req); err == nil {
+		t.Error("expected a cancelled request to stop waiting")
+	}
+}
+
+func TestModeGate_FlakyIsSeeded(t *testing.T) {
+	run := func() []int {
+		mgr := NewInMemoryModeManager(ModeNormal)
+		defer mgr.Close()
+		h := gateFor(t, mgr, ModeFlaky, WithFlakySeed(7), WithFlakyRate(0.3))
+		statuses := make([]int, 50)
+		for i := range statuses {
+			statuses[i] = serve(t, h, http.MethodGet).Status
+		}
+		return statuses
+	}
+
+	first, second := run(), run()
+	failed := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("request %d: %d vs %d with the same seed", i, first[i], second[i])
+		}
+		if first[i] == http.StatusInternalServerError {
+			failed++
+		}
+	}
+	if failed == 0 || failed == len(first) {
+		t.Errorf("expected some but not all requests to fail, got %d/%d", failed, len(first))
+	}
+}
+
+func TestModeGate_FollowsModeChanges(t *testing.T) {
+	mgr := NewInMemoryModeManager(ModeNormal)
+	defer mgr.Close()
+	h := gateFor(t, mgr, ModeOffline)
+	if resp := serve(t, h, http.MethodGet); resp.Status != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", resp.Status)
+	}
+
+	mgr.SetMode(ModeNormal)
+	deadline := time.Now().Add(time.Second)
+	for serve(t, h, http.MethodGet).Status != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("gate did not follow the switch back to normal")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// changesOnlyModes is a ModeManager whose Watch reports changes only, without
+// replaying the current mode. afterRead runs once, after the first
+// CurrentMode call has read the mode.
+type changesOnlyModes struct {
+	*InMemoryModeManager
+	afterRead *sync.Once
+	change    func()
+}
+
+func (m changesOnlyModes) Watch() <-chan Mode {
+	ch := m.InMemoryModeManager.Watch()
+	<-ch
+	return ch
+}
+
+func (m changesOnlyModes) CurrentMode() Mode {
+	mode := m.InMemoryModeManager.CurrentMode()
+	m.afterRead.Do(m.change)
+	return mode
+}
+
+func TestModeGate_ChangeDuringSetupIsNotMissed(t *testing.T) {
+	inner := NewInMemoryModeManager(ModeNormal)
+	defer inner.Close()
+	mgr := changesOnlyModes{InMemoryModeManager: inner, afterRead: &sync.Once{}}
+	mgr.change = func() { inner.SetMode(ModeOffline) }
+
+	g := newModeGate(mgr)
+	deadline := time.Now().Add(time.Second)
+	for g.current() != ModeOffline {
+		if time.Now().After(deadline) {
+			t.Fatalf("gate stuck in %q after a change that landed while it subscribed", g.current())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,6 +4,7 @@
 package testutils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -47,19 +48,17 @@ func IsPortOpen(host string, port int) bool {
 }
 
 // WaitForPort waits up to timeout for a TCP port to become open on the given host.
-// It polls every 100ms. Returns nil if the port becomes open, otherwise an error.
+// It polls every 100ms. Returns nil if the port becomes open, otherwise a
+// *WaitError.
 func WaitForPort(host string, port int, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
-	for time.Now().Before(deadline) {
-		if IsPortOpen(host, port) {
-			return nil
-		}
-		<-ticker.C
-	}
-	return errors.New("timeout waiting for port to become open")
+	target := net.JoinHostPort(host, fmt.Sprintf("%d", port))
+	return WaitFor(context.Background(), "port "+target, 100*time.Millisecond,
+		func(context.Context) (bool, string, error) {
+			if IsPortOpen(host, port) {
+				return true, "", nil
+			}
+			return false, "closed", nil
+		}, WithWaitTimeout(timeout))
 }
 
 // LocalIP returns the first non‑loopback IPv4 address of the local machine.
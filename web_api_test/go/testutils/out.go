@@ -19,6 +19,7 @@ var colors = map[string]string{
 	"cyan":    "\033[36m",
 	"white":   "\033[37m",
 	"bold":    "\033[1m",
+	"dim":     "\033[2m",
 }
 
 // EnableColors can be set to false to disable colored output (e.g., for non‑TTY).
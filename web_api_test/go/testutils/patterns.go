@@ -24,6 +24,7 @@ type WorkerPool struct {
 	jobQueue    chan Job
 	wg          sync.WaitGroup
 	quit        chan struct{}
+	watchdog    *Watchdog
 }
 
 func NewWorkerPool(workerCount, queueSize int) *WorkerPool {
@@ -58,8 +59,23 @@ func (wp *WorkerPool) worker(id int) {
 	}
 }
 
+// SetWatchdog attaches a deadlock watchdog and starts it. Jobs submitted
+// afterwards are tracked, and Stop shuts the watchdog down with the pool.
+// Call it before submitting; a nil watchdog leaves the pool untracked.
+func (wp *WorkerPool) SetWatchdog(w *Watchdog) {
+	wp.watchdog = w
+	w.Start()
+}
+
 // Submit adds job, supports context cancellation
 func (wp *WorkerPool) SubmitCtx(ctx context.Context, job Job) error {
+	return wp.SubmitLabeled(ctx, "job", job)
+}
+
+// SubmitLabeled is SubmitCtx with the label the watchdog reports for a
+// stalled job.
+func (wp *WorkerPool) SubmitLabeled(ctx context.Context, label string, job Job) error {
+	job = wp.watchdog.Wrap(label, job)
 	select {
 	case wp.jobQueue <- job:
 		return nil
@@ -72,6 +88,7 @@ func (wp *WorkerPool) Stop() {
 	close(wp.quit)
 	close(wp.jobQueue)
 	wp.wg.Wait()
+	wp.watchdog.Stop()
 }
 
 // =============================================================================
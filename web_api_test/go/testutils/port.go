@@ -4,6 +4,7 @@
 package testutils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -105,16 +106,13 @@ func IsOpenUDP(host string, port int) bool {
 // WaitForTCP waits up to timeout for a TCP port to become open.
 // It polls every 100 ms.
 func WaitForTCP(host string, port int, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-	for time.Now().Before(deadline) {
-		if IsOpenTCP(host, port) {
-			return nil
-		}
-		<-ticker.C
-	}
-	return fmt.Errorf("port %d on %s not open after %v", port, host, timeout)
+	return WaitFor(context.Background(), fmt.Sprintf("port %d on %s", port, host), 100*time.Millisecond,
+		func(context.Context) (bool, string, error) {
+			if IsOpenTCP(host, port) {
+				return true, "", nil
+			}
+			return false, "closed", nil
+		}, WithWaitTimeout(timeout))
 }
 
 // ----------------------------------------------------------------------
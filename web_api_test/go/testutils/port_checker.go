@@ -2,8 +2,10 @@ package testutils
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net"
 	"strconv"
 	"strings"
@@ -76,6 +78,12 @@ func (c PortCheckerConfig) withDefaults() PortCheckerConfig {
 	if c.WaitTimeout <= 0 {
 		c.WaitTimeout = 5 * time.Minute
 	}
+	if c.FallbackDelay <= 0 {
+		c.FallbackDelay = 300 * time.Millisecond
+	}
+	if c.StabilityChecks <= 0 {
+		c.StabilityChecks = 1
+	}
 	return c
 }
 
@@ -93,14 +101,29 @@ type ConnectionResult struct {
 	Latency       time.Duration `json:"latency"`
 	Error         string        `json:"error,omitempty"`
 	ErrorType     string        `json:"error_type,omitempty"`
+	ErrorKind     ErrorKind     `json:"error_kind,omitempty"` // taxonomy class of Error, see Kind
 	LocalAddr     string        `json:"local_addr,omitempty"`
 	RemoteAddr    string        `json:"remote_addr,omitempty"`
+	ResolvedIP    string        `json:"resolved_ip,omitempty"` // IP dialled for Host
 	ConnectedAt   time.Time     `json:"connected_at,omitempty"`
 	Attempts      int           `json:"attempts"`
 	IPVersion     IPVersion     `json:"ip_version"`
 	Deterministic bool          `json:"deterministic"` // For test reproducibility
+	StopReason    StopReason    `json:"stop_reason,omitempty"`
+	Family        IPVersion     `json:"family,omitempty"` // IP version of the address connected to
+	Cached        bool          `json:"cached,omitempty"` // served from the result cache, see PortCheckerConfig.CacheTTL
 }
 
+// StopReason explains why IsPortOpen stopped trying.
+type StopReason string
+
+const (
+	StopConnected        StopReason = "connected"
+	StopRetriesExhausted StopReason = "retries_exhausted"
+	StopBudgetExhausted  StopReason = "budget_exhausted"  // PerCheckTimeout elapsed
+	StopCancelled        StopReason = "context_cancelled" // caller's context ended
+)
+
 // PortRangeResult contains results for a range of ports.
 type PortRangeResult struct {
 	Host         string              `json:"host"`
@@ -128,6 +151,10 @@ type WaitResult struct {
 	Attempts  int               `json:"attempts"`
 	Errors    []string          `json:"errors,omitempty"`
 	FoundPort *ConnectionResult `json:"found_port,omitempty"`
+
+	// StabilityAttempts lists the checks from each run of successes on,
+	// including a failure that broke a run; see StabilityChecks.
+	StabilityAttempts []PortWaitAttempt `json:"stability_attempts,omitempty"`
 }
 
 //
@@ -154,6 +181,78 @@ type PortChecker struct {
 	sem      chan struct{}
 	stats    *PortCheckerStats
 	sequence atomic.Uint64 // For deterministic ordering
+	clock    Clock
+	dial     func(ctx context.Context, network, address string) (net.Conn, error)
+	resolver Resolver // nil leaves resolution to the dialer
+	cache    portCache
+	events   *EventBus // port_open on successful waits; nil publishes nothing
+
+	onAttempt func(PortWaitAttempt) error // see WithPortCheckerOnAttempt
+
+	rngMu sync.Mutex
+	rng   *rand.Rand // jitter source; seeded from seed in deterministic mode
+	seed  int64
+	// seeded forces seed to be used outside deterministic mode; set by
+	// WithPortCheckerTestRun.
+	seeded bool
+}
+
+// PortCheckerOption configures optional PortChecker behaviour.
+type PortCheckerOption func(*PortChecker)
+
+// WithPortCheckerSeed sets the jitter seed used when Deterministic is on.
+// NewPortCheckerFromConfig passes IntegerUtilsConfig.RandomSeed here.
+func WithPortCheckerSeed(seed int64) PortCheckerOption {
+	return func(pc *PortChecker) {
+		pc.seed = seed
+	}
+}
+
+// WithPortCheckerTestRun seeds jitter from run's "jitter" child seed, even
+// when Deterministic is off, so retry timing repeats under the same seed.
+func WithPortCheckerTestRun(run *TestRun) PortCheckerOption {
+	return func(pc *PortChecker) {
+		if run == nil {
+			return
+		}
+		pc.seed = run.ChildSeed("jitter")
+		pc.seeded = true
+	}
+}
+
+// WithPortCheckerEventBus publishes a port_open event to bus each time
+// WaitForPort or WaitForAnyPort finds its port open.
+func WithPortCheckerEventBus(bus *EventBus) PortCheckerOption {
+	return func(pc *PortChecker) {
+		pc.events = bus
+	}
+}
+
+// WithPortCheckerDialer replaces the network dialer, e.g. with fake targets.
+// The LocalAddr, KeepAlive and Linger settings are not applied to it.
+func WithPortCheckerDialer(dial func(ctx context.Context, network, address string) (net.Conn, error)) PortCheckerOption {
+	return func(pc *PortChecker) {
+		pc.dial = dial
+	}
+}
+
+// WithPortCheckerResolver resolves host names with r before dialling, e.g.
+// a MapResolver for compose service names. ConnectionResult.ResolvedIP
+// records the address used.
+func WithPortCheckerResolver(r Resolver) PortCheckerOption {
+	return func(pc *PortChecker) {
+		pc.resolver = r
+	}
+}
+
+// WithPortCheckerClock sets the clock used for retry backoff and wait
+// intervals. Tests can pass a FakeClock to avoid real sleeps.
+func WithPortCheckerClock(c Clock) PortCheckerOption {
+	return func(pc *PortChecker) {
+		if c != nil {
+			pc.clock = c
+		}
+	}
 }
 
 // PortCheckerStats holds operational statistics.
@@ -166,11 +265,23 @@ type PortCheckerStats struct {
 	AverageLatency  time.Duration      `json:"average_latency"`
 	LastCheck       time.Time          `json:"last_check"`
 	PortsByProtocol map[Protocol]int64 `json:"ports_by_protocol"`
+	CacheHits       int64              `json:"cache_hits"`
+	CacheMisses     int64              `json:"cache_misses"`
+
+	latencies *DurationCollection
+	recent    *RollingWindow
+	clock     Clock
 }
 
+// recentLatencyHorizon is the longest window RecentLatency can look back
+// over.
+const recentLatencyHorizon = 15 * time.Minute
+
 func NewPortCheckerStats() *PortCheckerStats {
 	return &PortCheckerStats{
 		PortsByProtocol: make(map[Protocol]int64),
+		latencies:       NewDurationCollection(),
+		clock:           RealClock{},
 	}
 }
 
@@ -190,8 +301,90 @@ func (s *PortCheckerStats) Record(result *ConnectionResult) {
 		s.AverageLatency = s.TotalLatency / time.Duration(s.ChecksCompleted)
 	}
 
-	s.LastCheck = time.Now()
+	s.LastCheck = s.now()
 	s.PortsByProtocol[result.Protocol]++
+	if s.latencies == nil {
+		s.latencies = NewDurationCollection()
+	}
+	s.latencies.Add(result.Latency)
+	s.recentWindow().ObserveDuration(result.Latency, s.LastCheck)
+}
+
+// recordCache counts an IsPortOpen call answered from the cache (hit) or
+// one that had to dial although the cache is enabled (miss).
+func (s *PortCheckerStats) recordCache(hit bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hit {
+		s.CacheHits++
+	} else {
+		s.CacheMisses++
+	}
+}
+
+func (s *PortCheckerStats) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock.Now()
+}
+
+// recentWindow returns the window of recent latencies, creating it on
+// first use. Callers hold s.mu for writing.
+func (s *PortCheckerStats) recentWindow() *RollingWindow {
+	if s.recent == nil {
+		s.recent = NewRollingWindow(WithWindowAge(recentLatencyHorizon), WithWindowClock(s.clock))
+	}
+	return s.recent
+}
+
+// MarshalJSON encodes the counters under the read lock, so stats can be
+// served while checks are still running.
+func (s *PortCheckerStats) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	byProtocol := make(map[Protocol]int64, len(s.PortsByProtocol))
+	for p, n := range s.PortsByProtocol {
+		byProtocol[p] = n
+	}
+	return json.Marshal(struct {
+		ChecksCompleted int64              `json:"checks_completed"`
+		ChecksSucceeded int64              `json:"checks_succeeded"`
+		ChecksFailed    int64              `json:"checks_failed"`
+		TotalLatency    time.Duration      `json:"total_latency"`
+		AverageLatency  time.Duration      `json:"average_latency"`
+		LastCheck       time.Time          `json:"last_check"`
+		PortsByProtocol map[Protocol]int64 `json:"ports_by_protocol"`
+		CacheHits       int64              `json:"cache_hits"`
+		CacheMisses     int64              `json:"cache_misses"`
+	}{s.ChecksCompleted, s.ChecksSucceeded, s.ChecksFailed, s.TotalLatency, s.AverageLatency, s.LastCheck, byProtocol,
+		s.CacheHits, s.CacheMisses})
+}
+
+// LatencyStats summarises the latencies of all recorded checks.
+func (s *PortCheckerStats) LatencyStats() DurationStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return NewDurationStats(s.latencies)
+}
+
+// RecentLatency summarises the latencies of checks recorded within window
+// of now, so a health dashboard can tell a port that is slow right now
+// from one that was slow an hour ago. A window that is not positive or
+// is longer than 15 minutes covers the last 15 minutes.
+func (s *PortCheckerStats) RecentLatency(window time.Duration) DurationStats {
+	s.mu.RLock()
+	recent := s.recent
+	s.mu.RUnlock()
+	if recent == nil {
+		return DurationStats{}
+	}
+	values := recent.Values(window)
+	c := NewDurationCollection()
+	for _, v := range values {
+		c.Add(time.Duration(v))
+	}
+	return NewDurationStats(c)
 }
 
 func (s *PortCheckerStats) Reset() {
@@ -204,55 +397,168 @@ func (s *PortCheckerStats) Reset() {
 	s.TotalLatency = 0
 	s.AverageLatency = 0
 	s.PortsByProtocol = make(map[Protocol]int64)
+	s.CacheHits = 0
+	s.CacheMisses = 0
+	s.latencies = NewDurationCollection()
+	if s.recent != nil {
+		s.recent.Reset()
+	}
 }
 
 //
 // Constructor
 //
 
-func NewPortChecker(logger Logger, config PortCheckerConfig) *PortChecker {
+func NewPortChecker(logger Logger, config PortCheckerConfig, opts ...PortCheckerOption) *PortChecker {
 	if logger == nil {
 		logger = noopLogger{}
 	}
 
 	cfg := config.withDefaults()
 
-	return &PortChecker{
+	pc := &PortChecker{
 		logger: logger,
 		config: cfg,
 		sem:    make(chan struct{}, cfg.MaxConcurrency),
 		stats:  NewPortCheckerStats(),
+		clock:  RealClock{},
+	}
+	for _, opt := range opts {
+		opt(pc)
+	}
+	pc.stats.clock = pc.clock
+	seed := time.Now().UnixNano()
+	if cfg.Deterministic || pc.seeded {
+		seed = pc.seed
+	}
+	pc.rng = rand.New(rand.NewSource(seed))
+	return pc
+}
+
+// NewPortCheckerFromConfig builds a PortChecker from a full Config, seeding
+// deterministic mode with IntegerUtils.RandomSeed and applying
+// Timer.NumericDurationJSON. Later options win.
+func NewPortCheckerFromConfig(logger Logger, cfg *Config, opts ...PortCheckerOption) *PortChecker {
+	SetNumericDurationJSON(cfg.Timer.NumericDurationJSON)
+	opts = append([]PortCheckerOption{WithPortCheckerSeed(cfg.IntegerUtils.RandomSeed)}, opts...)
+	return NewPortChecker(logger, cfg.PortChecker, opts...)
+}
+
+// sleep waits d on the checker's clock, returning ctx.Err() if ctx is done
+// first. The timer is stopped either way, so an abandoned wait does not
+// linger as a pending FakeClock waiter.
+func (pc *PortChecker) sleep(ctx context.Context, d time.Duration) error {
+	clock := pc.clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	t := clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
 	}
 }
 
+// now reads the checker's clock so timings are reproducible under a fake clock.
+func (pc *PortChecker) now() time.Time {
+	if pc.clock == nil {
+		return time.Now()
+	}
+	return pc.clock.Now()
+}
+
+// jitterFraction returns a value in [-1, 1) from the checker's random source.
+func (pc *PortChecker) jitterFraction() float64 {
+	pc.rngMu.Lock()
+	defer pc.rngMu.Unlock()
+	return 2*pc.rng.Float64() - 1
+}
+
+// portJitterFraction returns the jitter in [-1, 1) for a retry of port. In
+// deterministic or seeded mode it is derived from the seed, port and attempt
+// alone, so concurrent workers get the same delays whatever order they run
+// in; otherwise it comes from the shared random source.
+func (pc *PortChecker) portJitterFraction(port, attempt int) float64 {
+	if !pc.config.Deterministic && !pc.seeded {
+		return pc.jitterFraction()
+	}
+	x := splitMix64(uint64(pc.seed) ^ uint64(port)<<32 ^ uint64(attempt))
+	return 2*(float64(x>>11)/(1<<53)) - 1
+}
+
 //
 // Core Port Checking
 //
 
 // IsPortOpen attempts a connection to host:port with the specified protocol.
+// With PortCheckerConfig.CacheTTL or NegativeCacheTTL set, a recent result
+// for the same host, port and protocol is returned instead, marked Cached;
+// WithPortCacheBypass forces a fresh check.
 func (pc *PortChecker) IsPortOpen(
 	ctx context.Context,
 	host string,
 	port int,
 	protocol Protocol,
 ) (*ConnectionResult, error) {
+	if !pc.cacheEnabled() {
+		return pc.checkPort(ctx, host, port, protocol)
+	}
+	key := portCacheKey{host: host, port: port, protocol: protocol}
+	if !portCacheBypassed(ctx) {
+		if result, err, ok := pc.cachedResult(key); ok {
+			pc.stats.recordCache(true)
+			return result, err
+		}
+		pc.stats.recordCache(false)
+	}
+	result, err := pc.checkPort(ctx, host, port, protocol)
+	pc.storeResult(key, result, err)
+	return result, err
+}
+
+// checkPort is IsPortOpen without the cache.
+func (pc *PortChecker) checkPort(
+	ctx context.Context,
+	host string,
+	port int,
+	protocol Protocol,
+) (*ConnectionResult, error) {
 
 	// Validate port range
 	if pc.config.ValidatePorts {
 		if port < pc.config.MinPort || port > pc.config.MaxPort {
-			return nil, fmt.Errorf("port %d outside allowed range [%d-%d]",
+			return nil, kindErrorf(ErrValidation, "port %d outside allowed range [%d-%d]",
 				port, pc.config.MinPort, pc.config.MaxPort)
 		}
 	}
 
+	if pc.config.LocalAddr != "" {
+		if _, err := parseLocalAddr(pc.config.LocalAddr); err != nil {
+			return nil, fmt.Errorf("PortChecker LocalAddr %w", err)
+		}
+	}
+
 	portStr := strconv.Itoa(port)
 
 	// Build network address based on protocol and IP version
 	network, address := pc.buildNetworkAddress(host, portStr, protocol, pc.config.IPVersion)
 
-	start := time.Now()
+	start := pc.now()
 	attempts := 0
 	var lastError error
+	var lastResult *ConnectionResult
+
+	// The per-check budget bounds dials and backoff together, independent of
+	// DialTimeout. checkCtx is used for both so cancellation interrupts either.
+	checkCtx := ctx
+	if pc.config.PerCheckTimeout > 0 {
+		var cancel context.CancelFunc
+		checkCtx, cancel = context.WithTimeout(ctx, pc.config.PerCheckTimeout)
+		defer cancel()
+	}
 
 	pc.logger.Debug("attempting connection", map[string]any{
 		"address":    address,
@@ -263,63 +569,77 @@ func (pc *PortChecker) IsPortOpen(
 
 	// Retry logic
 	for attempt := 0; attempt <= pc.config.MaxRetries; attempt++ {
+		if checkCtx.Err() != nil {
+			break
+		}
 		attempts++
-		select {
-		case <-ctx.Done():
-			result := &ConnectionResult{
-				Host:      host,
-				Port:      port,
-				Protocol:  protocol,
-				Address:   address,
-				Open:      false,
-				Latency:   time.Since(start),
-				Error:     ctx.Err().Error(),
-				ErrorType: "context_cancelled",
-				Attempts:  attempts,
-				IPVersion: pc.config.IPVersion,
-			}
-			pc.stats.Record(result)
-			return result, ctx.Err()
-		default:
-			// Try connection
-			result, err := pc.tryConnect(ctx, network, address, host, port, protocol, start)
-			if err == nil && result.Open {
-				result.Attempts = attempts
-				pc.stats.Record(result)
-				return result, nil
-			}
 
-			lastError = err
-			if result != nil {
-				result.Attempts = attempts
-			}
+		result, err := pc.tryConnect(checkCtx, network, address, host, port, protocol, start)
+		if err == nil && result.Open {
+			result.Attempts = attempts
+			result.StopReason = StopConnected
+			pc.stats.Record(result)
+			return result, nil
+		}
+		lastError = err
+		if result != nil {
+			lastResult = result
+		}
 
-			// Apply backoff before retry
-			if attempt < pc.config.MaxRetries {
-				delay := pc.calculateRetryDelay(attempt)
-				pc.logger.Debug("connection failed, retrying", map[string]any{
-					"address": address,
-					"attempt": attempt + 1,
-					"delay":   delay,
-					"error":   err,
-				})
-				time.Sleep(delay)
-			}
+		// Apply backoff before retry
+		if attempt < pc.config.MaxRetries {
+			delay := pc.calculateRetryDelay(port, attempt)
+			pc.logger.Debug("connection failed, retrying", map[string]any{
+				"address": address,
+				"attempt": attempt + 1,
+				"delay":   delay,
+				"error":   err,
+			})
+			pc.sleep(checkCtx, delay)
 		}
 	}
 
-	// All retries failed
 	result := &ConnectionResult{
-		Host:      host,
-		Port:      port,
-		Protocol:  protocol,
-		Address:   address,
-		Open:      false,
-		Latency:   time.Since(start),
-		Error:     lastError.Error(),
-		ErrorType: "connection_failed",
-		Attempts:  attempts,
-		IPVersion: pc.config.IPVersion,
+		Host:          host,
+		Port:          port,
+		Protocol:      protocol,
+		Address:       address,
+		Open:          false,
+		Latency:       pc.now().Sub(start),
+		Attempts:      attempts,
+		Deterministic: pc.config.Deterministic,
+		IPVersion:     pc.config.IPVersion,
+		ErrorType:     "connection_failed",
+		StopReason:    StopRetriesExhausted,
+	}
+	if lastResult != nil {
+		result.ResolvedIP = lastResult.ResolvedIP
+		result.LocalAddr = lastResult.LocalAddr
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		lastError = ctx.Err()
+		result.ErrorType = "context_cancelled"
+		result.StopReason = StopCancelled
+	case checkCtx.Err() != nil:
+		lastError = fmt.Errorf("per-check budget of %v exhausted after %d attempt(s) to %s: %w",
+			pc.config.PerCheckTimeout, attempts, address, context.DeadlineExceeded)
+		result.ErrorType = "budget_exhausted"
+		result.StopReason = StopBudgetExhausted
+	default:
+		// Every attempt failed; whatever the last error was, the port
+		// is not reachable. Name resolution failures are told apart from
+		// refused connections by ErrorType.
+		if IsDNSError(lastError) {
+			result.ErrorType = pc.classifyError(lastError)
+		}
+		lastError = withKind(ErrUnavailable, lastError)
+	}
+	if lastError != nil {
+		lastError = tagKind(lastError)
+		result.Error = lastError.Error()
+		result.ErrorKind = Kind(lastError)
 	}
 	pc.stats.Record(result)
 
@@ -341,15 +661,45 @@ func (pc *PortChecker) tryConnect(
 	var conn net.Conn
 	var err error
 
+	dial := pc.dial
+	if dial == nil {
+		d, err := pc.dialer(network)
+		if err != nil {
+			return nil, err
+		}
+		dial = d.DialContext
+	}
+
+	var resolvedIP string
+	family := networkFamily(network)
+	connect := func() {
+		if pc.resolver == nil {
+			conn, err = dial(dialCtx, network, address)
+			return
+		}
+		conn, resolvedIP, err = resolvingDialer(pc.resolver, dial)(dialCtx, network, address)
+	}
+
 	switch protocol {
 	case TCP, TCP4, TCP6:
-		var d net.Dialer
-		conn, err = d.DialContext(dialCtx, network, address)
+		if !pc.dualStack(host, protocol) {
+			connect()
+			break
+		}
+		resolved, single, lookupErr := pc.resolvedDial(dialCtx, dial, network, address)
+		switch {
+		case lookupErr != nil:
+			err = lookupErr
+		case single:
+			conn, resolvedIP, err = resolved(dialCtx, network, address)
+		default:
+			conn, resolvedIP, family, err = pc.dialDualStack(dialCtx, resolved, address)
+		}
 	case UDP, UDP4, UDP6:
 		// For UDP, we try to establish a "connection" (sets default remote address)
-		conn, err = net.DialTimeout(network, address, pc.config.DialTimeout)
+		connect()
 	default:
-		return nil, fmt.Errorf("unsupported protocol: %s", protocol)
+		return nil, kindErrorf(ErrValidation, "unsupported protocol: %s", protocol)
 	}
 
 	result := &ConnectionResult{
@@ -358,14 +708,17 @@ func (pc *PortChecker) tryConnect(
 		Protocol:      protocol,
 		Address:       address,
 		Open:          err == nil,
-		Latency:       time.Since(start),
+		Latency:       pc.now().Sub(start),
 		IPVersion:     pc.config.IPVersion,
-		Deterministic: true,
+		Deterministic: pc.config.Deterministic,
+		ResolvedIP:    resolvedIP,
 	}
 
 	if err != nil {
 		result.Error = pc.wrapError(address, protocol, err).Error()
 		result.ErrorType = pc.classifyError(err)
+		result.ErrorKind = Kind(err)
+		result.LocalAddr = boundLocalAddr(err)
 		return result, err
 	}
 	defer conn.Close()
@@ -381,9 +734,22 @@ func (pc *PortChecker) tryConnect(
 		}
 	}
 
-	result.ConnectedAt = time.Now()
-	result.LocalAddr = conn.LocalAddr().String()
+	result.ConnectedAt = pc.now()
 	result.RemoteAddr = conn.RemoteAddr().String()
+	if result.ResolvedIP == "" {
+		if ip, _, err := net.SplitHostPort(result.RemoteAddr); err == nil {
+			result.ResolvedIP = ip
+		}
+	}
+	if f := addrFamily(result.ResolvedIP); f != AnyIP {
+		family = f
+	}
+	result.Family = family
+	// The local address is an ephemeral port, so it is left out when results
+	// must be reproducible.
+	if !pc.config.Deterministic {
+		result.LocalAddr = conn.LocalAddr().String()
+	}
 
 	pc.logger.Debug("connection successful", map[string]any{
 		"address":  address,
@@ -394,6 +760,9 @@ func (pc *PortChecker) tryConnect(
 	return result, nil
 }
 
+// buildNetworkAddress returns the network and address to dial. The generic
+// "tcp" and "udp" protocols are narrowed to the preferred IP version; host
+// is normalized by dialHost, so literals are bracketed exactly once.
 func (pc *PortChecker) buildNetworkAddress(host, port string, protocol Protocol, ipVersion IPVersion) (string, string) {
 	network := string(protocol)
 
@@ -413,55 +782,50 @@ func (pc *PortChecker) buildNetworkAddress(host, port string, protocol Protocol,
 		}
 	}
 
-	// Ensure proper formatting for IPv6
-	if ipVersion == IPv6 && !isIPv6(host) {
-		// Host might be a hostname, let net package resolve it
-		address := net.JoinHostPort(host, port)
-		return network, address
-	}
-
-	// For IPv6 addresses, wrap in brackets
-	if isIPv6(host) {
-		address := "[" + host + "]:" + port
-		return network, address
-	}
-
-	address := net.JoinHostPort(host, port)
-	return network, address
-}
-
-func isIPv6(host string) bool {
-	// Check if it looks like an IPv6 address
-	return net.ParseIP(host) != nil && strings.Contains(host, ":")
+	host, _ = dialHost(host)
+	return network, net.JoinHostPort(host, port)
 }
 
 func (pc *PortChecker) wrapError(address string, protocol Protocol, err error) error {
 	switch {
 	case errors.Is(err, context.DeadlineExceeded):
-		return fmt.Errorf("connection to %s (%s) timed out after %v: %w",
+		return kindErrorf(ErrTimeout, "connection to %s (%s) timed out after %v: %w",
 			address, protocol, pc.config.DialTimeout, err)
 	case errors.Is(err, context.Canceled):
-		return fmt.Errorf("connection to %s (%s) canceled: %w", address, protocol, err)
+		return kindErrorf(ErrCancelled, "connection to %s (%s) canceled: %w", address, protocol, err)
 	default:
-		return fmt.Errorf("failed to connect to %s (%s): %w", address, protocol, err)
+		return tagKind(fmt.Errorf("failed to connect to %s (%s): %w", address, protocol, err))
 	}
 }
 
+// classifyError returns the ErrorType string for a dial error. It is
+// derived from Kind; the strings predate the taxonomy and are kept for
+// consumers of ConnectionResult JSON. Resolution failures, which Kind
+// files under unavailable with refused connections, get their own types.
 func (pc *PortChecker) classifyError(err error) string {
-	switch {
-	case errors.Is(err, context.DeadlineExceeded):
-		return "timeout"
-	case errors.Is(err, context.Canceled):
+	switch Kind(err) {
+	case KindTimeout:
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "timeout"
+		}
+		return "network_timeout"
+	case KindCancelled:
 		return "cancelled"
+	}
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return "dns_not_found"
+	case errors.As(err, &dnsErr):
+		return "dns_error"
 	default:
-		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return "network_timeout"
-		}
 		return "connection_error"
 	}
 }
 
-func (pc *PortChecker) calculateRetryDelay(attempt int) time.Duration {
+// calculateRetryDelay returns the backoff before retrying port; port only
+// keys the jitter.
+func (pc *PortChecker) calculateRetryDelay(port, attempt int) time.Duration {
 	delay := pc.config.RetryInterval
 
 	// Apply exponential backoff
@@ -474,7 +838,7 @@ func (pc *PortChecker) calculateRetryDelay(attempt int) time.Duration {
 	// Apply jitter if enabled
 	if pc.config.JitterEnabled {
 		jitter := time.Duration(float64(delay) * 0.25) // ±25% jitter
-		delay += time.Duration(float64(jitter) * (2*float64(time.Now().UnixNano()%100)/100 - 1))
+		delay += time.Duration(float64(jitter) * pc.portJitterFraction(port, attempt))
 	}
 
 	// Cap maximum delay
@@ -502,7 +866,7 @@ func (pc *PortChecker) CheckPortRange(
 		startPort, endPort = endPort, startPort
 	}
 
-	startTime := time.Now()
+	startTime := pc.now()
 	result := &PortRangeResult{
 		Host:       host,
 		StartPort:  startPort,
@@ -535,7 +899,8 @@ func (pc *PortChecker) CheckPortRange(
 	ports := make(chan job, result.TotalPorts)
 	results := make(chan resultWithIdx, result.TotalPorts)
 
-	// Start workers
+	// Start workers. Deterministic mode keeps the pool: retry jitter is
+	// keyed by port, not drawn in scan order.
 	var wg sync.WaitGroup
 	for i := 0; i < pc.config.Workers; i++ {
 		wg.Add(1)
@@ -577,29 +942,37 @@ func (pc *PortChecker) CheckPortRange(
 		close(results)
 	}()
 
-	// Process results
+	// Process results. They arrive in completion order, so collect them by
+	// index first and build the lists in port order.
 	perPortStats := make([]*ConnectionResult, result.TotalPorts)
+	perPortErrs := make([]error, result.TotalPorts)
 	for res := range results {
-		if res.err != nil {
-			result.Errors = append(result.Errors, res.err.Error())
-		}
+		perPortErrs[res.idx] = res.err
 		if res.result != nil {
 			perPortStats[res.idx] = res.result
-			if res.result.Open {
-				result.OpenPorts = append(result.OpenPorts, res.result.Port)
-				result.SuccessCount++
-			} else {
-				result.ClosedPorts = append(result.ClosedPorts, res.result.Port)
-				result.FailureCount++
-			}
+		}
+	}
+	for idx, res := range perPortStats {
+		if err := perPortErrs[idx]; err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		if res == nil {
+			continue
+		}
+		if res.Open {
+			result.OpenPorts = append(result.OpenPorts, res.Port)
+			result.SuccessCount++
+		} else {
+			result.ClosedPorts = append(result.ClosedPorts, res.Port)
+			result.FailureCount++
 		}
 	}
 
-	if pc.config.ValidatePorts {
+	if pc.config.ValidatePorts || pc.config.Deterministic {
 		result.PerPortStats = perPortStats
 	}
 
-	result.Duration = time.Since(startTime)
+	result.Duration = pc.now().Sub(startTime)
 
 	pc.logger.Info("port range check completed", map[string]any{
 		"host":         host,
@@ -610,6 +983,10 @@ func (pc *PortChecker) CheckPortRange(
 		"duration":     result.Duration,
 	})
 
+	// Partial results are still returned so callers can see what was scanned.
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
 	return result, nil
 }
 
@@ -618,6 +995,8 @@ func (pc *PortChecker) CheckPortRange(
 //
 
 // WaitForPort blocks until a port becomes available or timeout expires.
+// With StabilityChecks above 1 the port must then pass that many checks in
+// a row, RetryInterval apart; a failed one starts the wait over.
 func (pc *PortChecker) WaitForPort(
 	ctx context.Context,
 	host string,
@@ -628,9 +1007,9 @@ func (pc *PortChecker) WaitForPort(
 	timeoutCtx, cancel := context.WithTimeout(ctx, pc.config.WaitTimeout)
 	defer cancel()
 
-	startTime := time.Now()
+	w := pc.newPortWait()
 	attempts := 0
-	var errors []string
+	sawClosed := false
 
 	pc.logger.Info("waiting for port", map[string]any{
 		"host":     host,
@@ -643,55 +1022,65 @@ func (pc *PortChecker) WaitForPort(
 		attempts++
 		select {
 		case <-timeoutCtx.Done():
-			result := &WaitResult{
-				Host:     host,
-				Port:     port,
-				Protocol: protocol,
-				Success:  false,
-				Duration: time.Since(startTime),
-				Attempts: attempts,
-				Errors:   errors,
-			}
-			return result, timeoutCtx.Err()
+			return w.result(host, port, protocol, attempts, nil), timeoutCtx.Err()
 		default:
 			connResult, err := pc.IsPortOpen(timeoutCtx, host, port, protocol)
-			if err == nil && connResult.Open {
-				result := &WaitResult{
-					Host:      host,
-					Port:      port,
-					Protocol:  protocol,
-					Success:   true,
-					Duration:  time.Since(startTime),
-					Attempts:  attempts,
-					Errors:    errors,
-					FoundPort: connResult,
+			open := err == nil && connResult.Open
+			consecutive := 0
+			if open {
+				consecutive = 1
+			}
+			if aerr := w.observe(port, connResult, err, consecutive); aerr != nil {
+				return w.result(host, port, protocol, attempts, nil), aerr
+			}
+			if open {
+				connResult, open, err = pc.confirmStable(timeoutCtx, w, host, port, protocol, connResult)
+				if err != nil {
+					return w.result(host, port, protocol, attempts, nil), err
 				}
+			}
+			if open {
+				if sawClosed {
+					// The port opened while we waited, so results cached
+					// for it under other protocols are stale.
+					pc.cache.drop(host, port, protocol)
+				}
+				result := w.result(host, port, protocol, attempts, connResult)
 				pc.logger.Info("port became available", map[string]any{
 					"host":     host,
 					"port":     port,
 					"attempts": attempts,
 					"duration": result.Duration,
 				})
+				pc.emitPortOpen(result)
 				return result, nil
 			}
 
 			if err != nil {
-				errors = append(errors, err.Error())
+				w.errors = append(w.errors, err.Error())
 			}
+			sawClosed = sawClosed || connResult != nil
 
 			// Wait before retry with jitter
-			delay := pc.calculateRetryDelay(attempts)
-			select {
-			case <-timeoutCtx.Done():
-				continue
-			case <-time.After(delay):
-				// Continue loop
-			}
+			delay := pc.calculateRetryDelay(port, attempts)
+			pc.sleep(timeoutCtx, delay)
 		}
 	}
 }
 
-// WaitForAnyPort waits for any port in a range to become available.
+// emitPortOpen publishes the port_open event for a successful wait.
+func (pc *PortChecker) emitPortOpen(result *WaitResult) {
+	pc.events.Emit(EventPortOpen, "port_checker", map[string]any{
+		"host":     result.Host,
+		"port":     result.Port,
+		"protocol": string(result.Protocol),
+		"attempts": result.Attempts,
+	})
+}
+
+// WaitForAnyPort waits for any port in a range to become available. With
+// StabilityChecks above 1 the first open port found must stay open as
+// WaitForPort requires, or the range is scanned again.
 func (pc *PortChecker) WaitForAnyPort(
 	ctx context.Context,
 	host string,
@@ -702,9 +1091,8 @@ func (pc *PortChecker) WaitForAnyPort(
 	timeoutCtx, cancel := context.WithTimeout(ctx, pc.config.WaitTimeout)
 	defer cancel()
 
-	startTime := time.Now()
+	w := pc.newPortWait()
 	attempts := 0
-	var errors []string
 
 	pc.logger.Info("waiting for any port in range", map[string]any{
 		"host":       host,
@@ -714,64 +1102,66 @@ func (pc *PortChecker) WaitForAnyPort(
 		"timeout":    pc.config.WaitTimeout,
 	})
 
-	// Try ports in random order for better distribution
+	if startPort > endPort {
+		startPort, endPort = endPort, startPort
+	}
+
+	// Ports are tried in random order for better distribution, or in
+	// ascending order in deterministic mode.
 	ports := make([]int, endPort-startPort+1)
 	for i := range ports {
 		ports[i] = startPort + i
 	}
-	// Shuffle for deterministic but random order
-	// Note: For true randomness, seed with time; for tests, use fixed seed
+	if !pc.config.Deterministic {
+		pc.rngMu.Lock()
+		pc.rng.Shuffle(len(ports), func(i, j int) { ports[i], ports[j] = ports[j], ports[i] })
+		pc.rngMu.Unlock()
+	}
 
 	for {
 		attempts++
 		select {
 		case <-timeoutCtx.Done():
-			result := &WaitResult{
-				Host:     host,
-				Port:     -1,
-				Protocol: protocol,
-				Success:  false,
-				Duration: time.Since(startTime),
-				Attempts: attempts,
-				Errors:   errors,
-			}
-			return result, timeoutCtx.Err()
+			return w.result(host, -1, protocol, attempts, nil), timeoutCtx.Err()
 		default:
 			for _, port := range ports {
 				connResult, err := pc.IsPortOpen(timeoutCtx, host, port, protocol)
-				if err == nil && connResult.Open {
-					result := &WaitResult{
-						Host:      host,
-						Port:      port,
-						Protocol:  protocol,
-						Success:   true,
-						Duration:  time.Since(startTime),
-						Attempts:  attempts,
-						Errors:    errors,
-						FoundPort: connResult,
+				open := err == nil && connResult.Open
+				consecutive := 0
+				if open {
+					consecutive = 1
+				}
+				if aerr := w.observe(port, connResult, err, consecutive); aerr != nil {
+					return w.result(host, -1, protocol, attempts, nil), aerr
+				}
+				if !open {
+					if err != nil {
+						w.errors = append(w.errors, fmt.Sprintf("port %d: %v", port, err))
 					}
-					pc.logger.Info("found available port", map[string]any{
-						"host":     host,
-						"port":     port,
-						"attempts": attempts,
-						"duration": result.Duration,
-					})
-					return result, nil
+					continue
 				}
 
+				connResult, open, err = pc.confirmStable(timeoutCtx, w, host, port, protocol, connResult)
 				if err != nil {
-					errors = append(errors, fmt.Sprintf("port %d: %v", port, err))
+					return w.result(host, -1, protocol, attempts, nil), err
 				}
+				if !open {
+					break // scan the range again after the delay
+				}
+				result := w.result(host, port, protocol, attempts, connResult)
+				pc.logger.Info("found available port", map[string]any{
+					"host":     host,
+					"port":     port,
+					"attempts": attempts,
+					"duration": result.Duration,
+				})
+				pc.emitPortOpen(result)
+				return result, nil
 			}
 
 			// Wait before retrying the entire range
-			delay := pc.calculateRetryDelay(attempts)
-			select {
-			case <-timeoutCtx.Done():
-				continue
-			case <-time.After(delay):
-				// Continue loop
-			}
+			delay := pc.calculateRetryDelay(startPort, attempts)
+			pc.sleep(timeoutCtx, delay)
 		}
 	}
 }
@@ -822,6 +1212,7 @@ func (pc *PortChecker) CheckMultiplePorts(
 		if err != nil {
 			if compositeErr == nil {
 				compositeErr = NewCompositeError("port check errors")
+				compositeErr.GroupSimilar = true
 			}
 			compositeErr.Add(err)
 		}
@@ -843,6 +1234,17 @@ func (pc *PortChecker) GetStats() *PortCheckerStats {
 	return pc.stats
 }
 
+// PoolStats describes the concurrency pool CheckMultiplePorts draws from.
+type PoolStats struct {
+	Capacity int `json:"capacity"` // PortCheckerConfig.MaxConcurrency
+	InUse    int `json:"in_use"`
+}
+
+// PoolStats returns how many of the concurrent check slots are taken.
+func (pc *PortChecker) PoolStats() PoolStats {
+	return PoolStats{Capacity: cap(pc.sem), InUse: len(pc.sem)}
+}
+
 // ResetStats resets all statistics.
 func (pc *PortChecker) ResetStats() {
 	pc.stats.Reset()
@@ -875,7 +1277,7 @@ func StringToProtocol(s string) Protocol {
 // ValidatePort validates a port number.
 func ValidatePort(port int) error {
 	if port < 1 || port > 65535 {
-		return fmt.Errorf("port %d outside valid range [1-65535]", port)
+		return kindErrorf(ErrValidation, "port %d outside valid range [1-65535]", port)
 	}
 	return nil
 }
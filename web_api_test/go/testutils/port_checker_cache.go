@@ -0,0 +1,108 @@
+package testutils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//
+// Result cache
+//
+
+// portCacheKey identifies a cached IsPortOpen result.
+type portCacheKey struct {
+	host     string
+	port     int
+	protocol Protocol
+}
+
+type portCacheEntry struct {
+	result  ConnectionResult
+	err     error
+	expires time.Time
+}
+
+// portCache holds recent IsPortOpen results so that components polling the
+// same port (health watchers, wait loops, tests) share one dial per TTL.
+type portCache struct {
+	mu      sync.Mutex
+	entries map[portCacheKey]portCacheEntry
+}
+
+type portCacheBypassKey struct{}
+
+// WithPortCacheBypass returns a context under which IsPortOpen always dials,
+// ignoring cached results. The fresh result still refreshes the cache.
+func WithPortCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, portCacheBypassKey{}, true)
+}
+
+func portCacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(portCacheBypassKey{}).(bool)
+	return bypass
+}
+
+// cacheEnabled reports whether either CacheTTL is set.
+func (pc *PortChecker) cacheEnabled() bool {
+	return pc.config.CacheTTL > 0 || pc.config.NegativeCacheTTL > 0
+}
+
+// cachedResult returns a copy of the unexpired result for key, marked
+// Cached, and the error it was returned with.
+func (pc *PortChecker) cachedResult(key portCacheKey) (*ConnectionResult, error, bool) {
+	pc.cache.mu.Lock()
+	defer pc.cache.mu.Unlock()
+	entry, ok := pc.cache.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	if !pc.now().Before(entry.expires) {
+		delete(pc.cache.entries, key)
+		return nil, nil, false
+	}
+	result := entry.result
+	result.Cached = true
+	return &result, entry.err, true
+}
+
+// storeResult caches result under the TTL for its outcome. Only outcomes
+// that say something about the port are kept: a check cut short by the
+// caller's context or by PerCheckTimeout is not.
+func (pc *PortChecker) storeResult(key portCacheKey, result *ConnectionResult, err error) {
+	ttl := pc.config.NegativeCacheTTL
+	switch {
+	case result == nil:
+		return
+	case result.StopReason == StopConnected:
+		ttl = pc.config.CacheTTL
+	case result.StopReason != StopRetriesExhausted:
+		return
+	}
+	if ttl <= 0 {
+		return
+	}
+	pc.cache.mu.Lock()
+	defer pc.cache.mu.Unlock()
+	if pc.cache.entries == nil {
+		pc.cache.entries = make(map[portCacheKey]portCacheEntry)
+	}
+	pc.cache.entries[key] = portCacheEntry{result: *result, err: err, expires: pc.now().Add(ttl)}
+}
+
+// InvalidatePortCache drops the cached results for host:port under every
+// protocol, e.g. after stopping or restarting the service behind it.
+func (pc *PortChecker) InvalidatePortCache(host string, port int) {
+	pc.cache.drop(host, port, "")
+}
+
+// drop removes the entries for host:port, except the one for keep.
+func (c *portCache) drop(host string, port int, keep Protocol) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.host == host && key.port == port && key.protocol != keep {
+			delete(c.entries, key)
+		}
+	}
+}
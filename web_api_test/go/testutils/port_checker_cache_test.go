@@ -0,0 +1,145 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// switchDialer dials successfully while open is set and counts dials.
+type switchDialer struct {
+	open  atomic.Bool
+	dials atomic.Int64
+}
+
+func (d *switchDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dials.Add(1)
+	if !d.open.Load() {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func newCachingChecker(d *switchDialer, fc *FakeClock) *PortChecker {
+	return NewPortChecker(nil, PortCheckerConfig{
+		RetryInterval:    10 * time.Millisecond,
+		BackoffFactor:    1,
+		MaxRetries:       1,
+		CacheTTL:         time.Second,
+		NegativeCacheTTL: 50 * time.Millisecond,
+		WaitTimeout:      10 * time.Second,
+	}, WithPortCheckerDialer(d.dial), WithPortCheckerClock(fc))
+}
+
+// checkClosed runs IsPortOpen against a closed port, advancing fc past the
+// single retry backoff.
+func checkClosed(t *testing.T, pc *PortChecker, fc *FakeClock, protocol Protocol) {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() {
+		_, err := pc.IsPortOpen(context.Background(), "127.0.0.1", 9000, protocol)
+		done <- err
+	}()
+	fc.BlockUntil(1)
+	fc.Advance(10 * time.Millisecond)
+	if err := <-done; !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected the port to be unavailable, got %v", err)
+	}
+}
+
+func TestPortChecker_CacheTTL(t *testing.T) {
+	d := &switchDialer{}
+	d.open.Store(true)
+	fc := NewFakeClock(time.Time{})
+	pc := newCachingChecker(d, fc)
+	ctx := context.Background()
+
+	check := func(ctx context.Context) *ConnectionResult {
+		t.Helper()
+		result, err := pc.IsPortOpen(ctx, "127.0.0.1", 9000, TCP)
+		if err != nil || !result.Open {
+			t.Fatalf("expected an open port, got %+v, %v", result, err)
+		}
+		return result
+	}
+	if check(ctx).Cached {
+		t.Error("the first check cannot be cached")
+	}
+	if !check(ctx).Cached || d.dials.Load() != 1 {
+		t.Errorf("expected a cache hit, %d dials", d.dials.Load())
+	}
+	if check(WithPortCacheBypass(ctx)).Cached || d.dials.Load() != 2 {
+		t.Error("WithPortCacheBypass must dial")
+	}
+	fc.Advance(time.Second)
+	if check(ctx).Cached || d.dials.Load() != 3 {
+		t.Error("an open result must expire after CacheTTL")
+	}
+
+	// Closed results are kept for the shorter NegativeCacheTTL.
+	d.open.Store(false)
+	checkClosed(t, pc, fc, TCP6)
+	dials := d.dials.Load()
+	result, err := pc.IsPortOpen(ctx, "127.0.0.1", 9000, TCP6)
+	if !result.Cached || result.Open || !errors.Is(err, ErrUnavailable) || d.dials.Load() != dials {
+		t.Errorf("expected the cached closed result and its error, got %+v, %v", result, err)
+	}
+	fc.Advance(50 * time.Millisecond)
+	checkClosed(t, pc, fc, TCP6)
+	if d.dials.Load() != dials+2 {
+		t.Errorf("a closed result must expire after NegativeCacheTTL")
+	}
+
+	stats := pc.GetStats()
+	if stats.CacheHits != 2 || stats.CacheMisses != 4 {
+		t.Errorf("expected 2 hits and 4 misses, got %d and %d", stats.CacheHits, stats.CacheMisses)
+	}
+}
+
+func TestPortChecker_WaitForPortInvalidatesCache(t *testing.T) {
+	d := &switchDialer{}
+	fc := NewFakeClock(time.Time{})
+	pc := newCachingChecker(d, fc)
+
+	checkClosed(t, pc, fc, TCP)  // cached until 60ms
+	checkClosed(t, pc, fc, TCP4) // cached until 70ms
+	d.open.Store(true)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := pc.WaitForPort(context.Background(), "127.0.0.1", 9000, TCP)
+		done <- err
+	}()
+	// The first poll is answered from the cache; the next one, after the
+	// TCP entry expired, dials and sees the port open.
+	fc.BlockUntil(1)
+	fc.Advance(40 * time.Millisecond)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", 9000, TCP4)
+	if err != nil || result.Cached {
+		t.Errorf("the closed TCP4 result should have been dropped when the port opened, got %+v, %v", result, err)
+	}
+}
+
+func TestPortChecker_InvalidatePortCache(t *testing.T) {
+	d := &switchDialer{}
+	d.open.Store(true)
+	pc := newCachingChecker(d, NewFakeClock(time.Time{}))
+	for _, p := range []Protocol{TCP, TCP4, UDP} {
+		pc.IsPortOpen(context.Background(), "127.0.0.1", 9000, p)
+	}
+	pc.InvalidatePortCache("127.0.0.1", 9000)
+	for _, p := range []Protocol{TCP, TCP4, UDP} {
+		if result, _ := pc.IsPortOpen(context.Background(), "127.0.0.1", 9000, p); result.Cached {
+			t.Errorf("%s result still cached", p)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+//
+// Host literals and dual-stack dialling
+//
+
+// dialHost normalizes a PortChecker host for net.JoinHostPort and reports
+// whether it is an IP literal. Brackets are removed, a URL-escaped zone
+// ("fe80::1%25eth0") is unescaped and an IPv4-mapped IPv6 address
+// ("::ffff:10.0.0.1") becomes the IPv4 address it maps, since that is
+// what the dialer connects to. Host names only lose their brackets.
+func dialHost(host string) (string, bool) {
+	bare := host
+	if strings.HasPrefix(bare, "[") && strings.HasSuffix(bare, "]") {
+		bare = bare[1 : len(bare)-1]
+	}
+	if before, zone, ok := strings.Cut(bare, "%25"); ok && zone != "" {
+		bare = before + "%" + zone
+	}
+	addr, err := netip.ParseAddr(bare)
+	if err != nil {
+		return bare, false
+	}
+	if addr.Is4In6() {
+		addr = addr.Unmap()
+	}
+	return addr.String(), true
+}
+
+// addrFamily returns the IP version of ip, an address or host:port, or
+// AnyIP if it is neither.
+func addrFamily(ip string) IPVersion {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		ap, err := netip.ParseAddrPort(ip)
+		if err != nil {
+			return AnyIP
+		}
+		addr = ap.Addr()
+	}
+	if addr.Unmap().Is4() {
+		return IPv4
+	}
+	return IPv6
+}
+
+// networkFamily returns the IP version a network such as "tcp6" is
+// restricted to, or AnyIP.
+func networkFamily(network string) IPVersion {
+	switch {
+	case strings.HasSuffix(network, "4"):
+		return IPv4
+	case strings.HasSuffix(network, "6"):
+		return IPv6
+	}
+	return AnyIP
+}
+
+// dualStack reports whether host is dialled over IPv6 and IPv4 in
+// parallel: a TCP check of a host name with no IP version preference and
+// no LocalAddr, which would pin the family.
+func (pc *PortChecker) dualStack(host string, protocol Protocol) bool {
+	if protocol != TCP || pc.config.IPVersion != AnyIP || pc.config.LocalAddr != "" {
+		return false
+	}
+	_, literal := dialHost(host)
+	return !literal
+}
+
+type dualStackResult struct {
+	conn   net.Conn
+	ip     string
+	family IPVersion
+	err    error
+}
+
+// dialDualStack dials address over "tcp6" and, once FallbackDelay has
+// passed or the IPv6 attempt has failed, over "tcp4" as well, returning
+// the first connection made (a simplified RFC 8305 "Happy Eyeballs"). The
+// losing attempt is cancelled and its connection closed. If both fail the
+// IPv6 error is returned, as net.Dialer does for its primary address,
+// unless the host simply has no IPv6 address.
+func (pc *PortChecker) dialDualStack(
+	ctx context.Context,
+	dial func(ctx context.Context, network, address string) (net.Conn, string, error),
+	address string,
+) (net.Conn, string, IPVersion, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dualStackResult, 2)
+	start := func(network string, family IPVersion) {
+		go func() {
+			conn, ip, err := dial(ctx, network, address)
+			results <- dualStackResult{conn, ip, family, err}
+		}()
+	}
+
+	start("tcp6", IPv6)
+	pending, fallbackStarted := 1, false
+	timer := pc.clock.NewTimer(pc.config.FallbackDelay)
+	defer timer.Stop()
+	fallback := timer.C()
+	var primaryErr error
+	for pending > 0 {
+		select {
+		case <-fallback:
+			fallback = nil
+			if !fallbackStarted {
+				start("tcp4", IPv4)
+				pending++
+				fallbackStarted = true
+			}
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				go closeDualStackLosers(results, pending)
+				return r.conn, r.ip, r.family, nil
+			}
+			if primaryErr == nil || wrongFamily(primaryErr) {
+				primaryErr = r.err
+			}
+			if !fallbackStarted {
+				start("tcp4", IPv4)
+				pending++
+				fallbackStarted = true
+			}
+		}
+	}
+	return nil, "", AnyIP, primaryErr
+}
+
+// closeDualStackLosers closes the connections of the n attempts still
+// running when another one won.
+func closeDualStackLosers(results <-chan dualStackResult, n int) {
+	for ; n > 0; n-- {
+		if r := <-results; r.conn != nil {
+			r.conn.Close()
+		}
+	}
+}
+
+// wrongFamily reports whether err only says that the host has no address
+// of the family dialled.
+func wrongFamily(err error) bool {
+	var addrErr *net.AddrError
+	var dnsErr *net.DNSError
+	return errors.As(err, &addrErr) || errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// resolvedDial returns the dial function for a dual-stack check. With a
+// Resolver, the host of address is looked up once and both families dial
+// from that answer; single reports that it holds one family only, so
+// there is nothing to race.
+func (pc *PortChecker) resolvedDial(
+	ctx context.Context,
+	dial func(ctx context.Context, network, address string) (net.Conn, error),
+	network, address string,
+) (fn func(ctx context.Context, network, address string) (net.Conn, string, error), single bool, err error) {
+	if pc.resolver == nil {
+		return func(ctx context.Context, network, address string) (net.Conn, string, error) {
+			conn, err := dial(ctx, network, address)
+			return conn, "", err
+		}, false, nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, false, err
+	}
+	ips, err := pc.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, false, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	single = len(filterIPs(ips, "tcp4")) == 0 || len(filterIPs(ips, "tcp6")) == 0
+	return resolvingDialer(staticResolver(ips), dial), single, nil
+}
+
+// staticResolver answers every lookup with the same addresses.
+type staticResolver []string
+
+func (r staticResolver) LookupHost(context.Context, string) ([]string, error) {
+	return r, nil
+}
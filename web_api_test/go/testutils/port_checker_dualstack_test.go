@@ -0,0 +1,157 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialHost(t *testing.T) {
+	for _, tc := range []struct {
+		host, want string
+		literal    bool
+	}{
+		{"127.0.0.1", "127.0.0.1", true},
+		{"::1", "::1", true},
+		{"[::1]", "::1", true},
+		{"fe80::1%eth0", "fe80::1%eth0", true},
+		{"[fe80::1%eth0]", "fe80::1%eth0", true},
+		{"fe80::1%25eth0", "fe80::1%eth0", true},
+		{"::ffff:192.0.2.7", "192.0.2.7", true},
+		{"[::ffff:c000:207]", "192.0.2.7", true},
+		{"2001:0DB8::0001", "2001:db8::1", true},
+		{"localhost", "localhost", false},
+		{"[db.internal]", "db.internal", false},
+		{"db.internal.", "db.internal.", false},
+		{"1.2.3", "1.2.3", false},
+		{"", "", false},
+	} {
+		got, literal := dialHost(tc.host)
+		if got != tc.want || literal != tc.literal {
+			t.Errorf("dialHost(%q) = %q, %v; want %q, %v", tc.host, got, literal, tc.want, tc.literal)
+		}
+	}
+}
+
+// listenLoopback listens on addr ("127.0.0.1:0", "[::1]:1234"), skipping
+// the test if the address family is unavailable.
+func listenLoopback(t *testing.T, addr string) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestPortChecker_FamilyOfLiterals(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{MaxRetries: 1})
+	for _, tc := range []struct {
+		listen, host string
+		want         IPVersion
+	}{
+		{"127.0.0.1:0", "127.0.0.1", IPv4},
+		{"127.0.0.1:0", "::ffff:127.0.0.1", IPv4},
+		{"[::1]:0", "[::1]", IPv6},
+	} {
+		ln := listenLoopback(t, tc.listen)
+		port := ln.Addr().(*net.TCPAddr).Port
+		result, err := pc.IsPortOpen(context.Background(), tc.host, port, TCP)
+		if err != nil || result.Family != tc.want {
+			t.Errorf("%s: family %v, err %v; want %v", tc.host, result.Family, err, tc.want)
+		}
+	}
+}
+
+// TestPortChecker_DualStackLoopback resolves one name to ::1 and 127.0.0.1
+// and checks which family answers.
+func TestPortChecker_DualStackLoopback(t *testing.T) {
+	v4 := listenLoopback(t, "127.0.0.1:0")
+	port := v4.Addr().(*net.TCPAddr).Port
+	resolver := NewMapResolver(map[string][]string{"svc.local": {"::1", "127.0.0.1"}})
+	pc := NewPortChecker(nil, PortCheckerConfig{MaxRetries: 1}, WithPortCheckerResolver(resolver))
+
+	// Only IPv4 listens: the IPv6 attempt is refused and IPv4 takes over
+	// without waiting for FallbackDelay.
+	result, err := pc.IsPortOpen(context.Background(), "svc.local", port, TCP)
+	if err != nil || result.Family != IPv4 || result.ResolvedIP != "127.0.0.1" {
+		t.Fatalf("expected IPv4 after the IPv6 refusal, got %+v, %v", result, err)
+	}
+	if result.Latency >= 300*time.Millisecond {
+		t.Errorf("IPv4 waited for the fallback delay: %v", result.Latency)
+	}
+
+	listenLoopback(t, "[::1]:"+strconv.Itoa(port))
+	result, err = pc.IsPortOpen(context.Background(), "svc.local", port, TCP)
+	if err != nil || result.Family != IPv6 || result.ResolvedIP != "::1" {
+		t.Errorf("expected IPv6 to be preferred, got %+v, %v", result, err)
+	}
+}
+
+func TestPortChecker_DualStackFallbackDelay(t *testing.T) {
+	var v4Dials atomic.Int64
+	v6Cancelled := make(chan struct{})
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		if network == "tcp6" {
+			<-ctx.Done() // a blackholed IPv6 route
+			close(v6Cancelled)
+			return nil, ctx.Err()
+		}
+		v4Dials.Add(1)
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+	fc := NewFakeClock(time.Time{})
+	pc := NewPortChecker(nil, PortCheckerConfig{FallbackDelay: 250 * time.Millisecond},
+		WithPortCheckerDialer(dial), WithPortCheckerClock(fc))
+
+	done := make(chan *ConnectionResult, 1)
+	go func() {
+		result, err := pc.IsPortOpen(context.Background(), "svc.local", 8080, TCP)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- result
+	}()
+	fc.BlockUntil(1)
+	if n := v4Dials.Load(); n != 0 {
+		t.Fatalf("IPv4 dialled %d times before the fallback delay", n)
+	}
+	fc.Advance(250 * time.Millisecond)
+	if result := <-done; result == nil || result.Family != IPv4 {
+		t.Errorf("expected an IPv4 connection, got %+v", result)
+	}
+	select {
+	case <-v6Cancelled:
+	case <-time.After(2 * time.Second):
+		t.Error("the IPv6 attempt was not cancelled")
+	}
+}
+
+func TestPortChecker_DualStackBothFail(t *testing.T) {
+	refused := func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	}
+	pc := NewPortChecker(nil, PortCheckerConfig{MaxRetries: 1, RetryInterval: time.Millisecond},
+		WithPortCheckerDialer(refused))
+	result, err := pc.IsPortOpen(context.Background(), "svc.local", 8080, TCP)
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Net != "tcp6" || result.Open {
+		t.Errorf("expected the IPv6 error, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//
+// Socket options
+//
+
+// parseLocalAddr parses PortCheckerConfig.LocalAddr: an IP address, or an
+// IP and port ("10.0.0.5", "10.0.0.5:0", "[fe80::1%eth0]:4000"). Host
+// names are rejected so the source interface is never resolved by DNS.
+func parseLocalAddr(s string) (*net.TCPAddr, error) {
+	host, portStr := s, ""
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		host, portStr = h, p
+	}
+	host, zone, _ := strings.Cut(host, "%")
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, kindErrorf(ErrValidation, "must be an IP address or IP:port, got %q", s)
+	}
+	port := 0
+	if portStr != "" {
+		p, err := strconv.Atoi(portStr)
+		if err != nil || p < 0 || p > 65535 {
+			return nil, kindErrorf(ErrValidation, "has an invalid port in %q", s)
+		}
+		port = p
+	}
+	return &net.TCPAddr{IP: ip, Port: port, Zone: zone}, nil
+}
+
+// dialer returns the net.Dialer for network with LocalAddr, KeepAlive
+// and Linger applied. Linger only applies to TCP; on a platform without
+// SO_LINGER the dial fails with an ErrUnavailable error naming it.
+func (pc *PortChecker) dialer(network string) (*net.Dialer, error) {
+	d := &net.Dialer{KeepAlive: pc.config.KeepAlive}
+	if pc.config.LocalAddr != "" {
+		addr, err := parseLocalAddr(pc.config.LocalAddr)
+		if err != nil {
+			return nil, fmt.Errorf("PortChecker LocalAddr %w", err)
+		}
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr = &net.UDPAddr{IP: addr.IP, Port: addr.Port, Zone: addr.Zone}
+		} else {
+			d.LocalAddr = addr
+		}
+	}
+	if linger := pc.config.Linger; linger != 0 && strings.HasPrefix(network, "tcp") {
+		if linger < 0 {
+			linger = 0 // SO_LINGER with a zero timeout: reset on close
+		}
+		d.Control = func(_, _ string, c syscall.RawConn) error {
+			var sockErr error
+			if err := c.Control(func(fd uintptr) { sockErr = setLinger(fd, linger) }); err != nil {
+				return err
+			}
+			if sockErr != nil {
+				return withKind(ErrUnavailable, fmt.Errorf("set SO_LINGER: %w", sockErr))
+			}
+			return nil
+		}
+	}
+	return d, nil
+}
+
+// boundLocalAddr returns the local address of a failed dial whose bind
+// succeeded, as reported by the dialer. It is empty when no LocalAddr was
+// configured or the bind itself failed.
+func boundLocalAddr(err error) string {
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Source == nil {
+		return ""
+	}
+	var sysErr *os.SyscallError
+	if errors.As(err, &sysErr) && sysErr.Syscall == "bind" {
+		return ""
+	}
+	return opErr.Source.String()
+}
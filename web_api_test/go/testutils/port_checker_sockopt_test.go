@@ -0,0 +1,128 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// secondLoopback skips the test unless 127.0.0.2 can be bound, as on
+// Linux where the whole 127/8 block is local.
+func secondLoopback(t *testing.T) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skipf("127.0.0.2 not usable as a source address: %v", err)
+	}
+	ln.Close()
+}
+
+func TestPortChecker_LocalAddr(t *testing.T) {
+	secondLoopback(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peers := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		peers <- conn.RemoteAddr().String()
+		conn.Close()
+	}()
+
+	pc := NewPortChecker(nil, PortCheckerConfig{LocalAddr: "127.0.0.2", KeepAlive: 5 * time.Second, MaxRetries: 1, RetryInterval: time.Millisecond})
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", ln.Addr().(*net.TCPAddr).Port, TCP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result.LocalAddr, "127.0.0.2:") {
+		t.Errorf("result.LocalAddr = %q, want 127.0.0.2:<port>", result.LocalAddr)
+	}
+	if peer := <-peers; peer != result.LocalAddr {
+		t.Errorf("server saw %s, result records %s", peer, result.LocalAddr)
+	}
+
+	// A refused connect still reports the bound source address.
+	result, err = pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP)
+	if err == nil {
+		t.Fatal("expected an error for a closed port")
+	}
+	if !strings.HasPrefix(result.LocalAddr, "127.0.0.2:") {
+		t.Errorf("failed result.LocalAddr = %q, want 127.0.0.2:<port>", result.LocalAddr)
+	}
+}
+
+func TestPortChecker_LocalAddrErrors(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{LocalAddr: "ci-runner.local"})
+	if _, err := pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP); !errors.Is(err, ErrValidation) {
+		t.Errorf("expected a validation error for a host name, got %v", err)
+	}
+
+	// 192.0.2.0/24 is reserved for documentation and never local, so the
+	// bind fails and no source address is recorded.
+	pc = NewPortChecker(nil, PortCheckerConfig{LocalAddr: "192.0.2.1", MaxRetries: 1, RetryInterval: time.Millisecond})
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP)
+	if err == nil || !strings.Contains(err.Error(), "bind") {
+		t.Fatalf("expected a bind error, got %v", err)
+	}
+	if result.LocalAddr != "" {
+		t.Errorf("no address was bound, got LocalAddr %q", result.LocalAddr)
+	}
+}
+
+func TestPortChecker_LingerResetsOnClose(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("SO_LINGER is only set on linux and darwin")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	readErr := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, err = io.ReadAll(conn)
+		readErr <- err
+	}()
+
+	pc := NewPortChecker(nil, PortCheckerConfig{Linger: -1, MaxRetries: 1})
+	if _, err := pc.IsPortOpen(context.Background(), "127.0.0.1", ln.Addr().(*net.TCPAddr).Port, TCP); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-readErr; !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("expected the checker's close to reset the connection, got %v", err)
+	}
+}
+
+func TestParseLocalAddr(t *testing.T) {
+	for addr, ok := range map[string]bool{
+		"10.0.0.5":            true,
+		"10.0.0.5:0":          true,
+		"[fe80::1%eth0]:4000": true,
+		"::1":                 true,
+		"runner.local":        false,
+		"10.0.0.5:http":       false,
+	} {
+		_, err := parseLocalAddr(addr)
+		if (err == nil) != ok {
+			t.Errorf("parseLocalAddr(%q) = %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//
+// Stable waits
+//
+
+// PortWaitAttempt is one check made by WaitForPort or WaitForAnyPort.
+type PortWaitAttempt struct {
+	Port        int               `json:"port"`
+	Open        bool              `json:"open"`
+	Consecutive int               `json:"consecutive"` // successful checks in a row, this one included
+	At          time.Duration     `json:"at"`          // since the wait began
+	Latency     time.Duration     `json:"latency"`
+	Error       string            `json:"error,omitempty"`
+	Result      *ConnectionResult `json:"-"`
+}
+
+// WithPortCheckerOnAttempt calls fn after every check a wait makes, e.g.
+// to log progress. A non-nil error from fn ends the wait, which returns
+// that error.
+func WithPortCheckerOnAttempt(fn func(PortWaitAttempt) error) PortCheckerOption {
+	return func(pc *PortChecker) {
+		pc.onAttempt = fn
+	}
+}
+
+// portWait is the state shared by the checks of one wait.
+type portWait struct {
+	pc        *PortChecker
+	start     time.Time
+	errors    []string
+	stability []PortWaitAttempt
+}
+
+func (pc *PortChecker) newPortWait() *portWait {
+	return &portWait{pc: pc, start: pc.now()}
+}
+
+// observe records a check of port. Checks from the first success of a run
+// on, including the failure that breaks it, are kept as stability
+// attempts.
+func (w *portWait) observe(port int, result *ConnectionResult, err error, consecutive int) error {
+	a := PortWaitAttempt{
+		Port:        port,
+		Open:        consecutive > 0,
+		Consecutive: consecutive,
+		At:          w.pc.now().Sub(w.start),
+		Result:      result,
+	}
+	if result != nil {
+		a.Latency = result.Latency
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	if consecutive > 0 || len(w.stability) > 0 && w.stability[len(w.stability)-1].Open {
+		w.stability = append(w.stability, a)
+	}
+	if w.pc.onAttempt == nil {
+		return nil
+	}
+	return w.pc.onAttempt(a)
+}
+
+// confirmStable re-checks a port that was just found open until
+// StabilityChecks checks in a row have succeeded, spacing them by
+// RetryInterval and bypassing the result cache. It returns the last
+// result and whether the port stayed open. An error ends the wait: ctx is
+// done or OnAttempt aborted it.
+func (pc *PortChecker) confirmStable(
+	ctx context.Context,
+	w *portWait,
+	host string,
+	port int,
+	protocol Protocol,
+	first *ConnectionResult,
+) (*ConnectionResult, bool, error) {
+	result := first
+	for n := 2; n <= pc.config.StabilityChecks; n++ {
+		if err := pc.sleep(ctx, pc.config.RetryInterval); err != nil {
+			return result, false, err
+		}
+		next, err := pc.IsPortOpen(WithPortCacheBypass(ctx), host, port, protocol)
+		if err != nil || !next.Open {
+			if err != nil {
+				w.errors = append(w.errors, fmt.Sprintf("port %d: unstable after %d check(s): %v", port, n-1, err))
+			}
+			return next, false, w.observe(port, next, err, 0)
+		}
+		result = next
+		if err := w.observe(port, next, nil, n); err != nil {
+			return result, false, err
+		}
+	}
+	if pc.config.StabilityChecks > 1 {
+		pc.logger.Debug("port stable", map[string]any{
+			"host":   host,
+			"port":   port,
+			"checks": pc.config.StabilityChecks,
+		})
+	}
+	return result, true, nil
+}
+
+// result builds the WaitResult of a wait ending now.
+func (w *portWait) result(host string, port int, protocol Protocol, attempts int, found *ConnectionResult) *WaitResult {
+	return &WaitResult{
+		Host:              host,
+		Port:              port,
+		Protocol:          protocol,
+		Success:           found != nil,
+		Duration:          w.pc.now().Sub(w.start),
+		Attempts:          attempts,
+		Errors:            w.errors,
+		FoundPort:         found,
+		StabilityAttempts: w.stability,
+	}
+}
@@ -0,0 +1,147 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flappingDialer answers dial n with script[n], repeating the last entry.
+type flappingDialer struct {
+	script []bool
+	dials  atomic.Int64
+}
+
+func (d *flappingDialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	n := int(d.dials.Add(1)) - 1
+	if n >= len(d.script) {
+		n = len(d.script) - 1
+	}
+	if !d.script[n] {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+// runWait runs wait, advancing fc whenever it sleeps, and returns its
+// result once it ends.
+func runWait(t *testing.T, fc *FakeClock, wait func() (*WaitResult, error)) (*WaitResult, error) {
+	t.Helper()
+	type outcome struct {
+		result *WaitResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := wait()
+		done <- outcome{result, err}
+	}()
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case o := <-done:
+			return o.result, o.err
+		case <-deadline:
+			t.Fatal("wait did not return")
+		default:
+		}
+		if fc.Waiters() > 0 {
+			fc.Advance(10 * time.Millisecond)
+			continue
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newStabilityChecker(d *flappingDialer, fc *FakeClock, checks int, opts ...PortCheckerOption) *PortChecker {
+	opts = append(opts, WithPortCheckerDialer(d.dial), WithPortCheckerClock(fc))
+	return NewPortChecker(nil, PortCheckerConfig{
+		RetryInterval:   10 * time.Millisecond,
+		BackoffFactor:   1,
+		MaxRetries:      1, // a closed check dials twice
+		WaitTimeout:     10 * time.Second,
+		StabilityChecks: checks,
+		Deterministic:   true,
+	}, opts...)
+}
+
+func TestPortChecker_WaitForPortStability(t *testing.T) {
+	// open, closed (two dials), then open for good
+	d := &flappingDialer{script: []bool{true, false, false, true}}
+	fc := NewFakeClock(time.Time{})
+	var seen []PortWaitAttempt
+	pc := newStabilityChecker(d, fc, 3, WithPortCheckerOnAttempt(func(a PortWaitAttempt) error {
+		seen = append(seen, a)
+		return nil
+	}))
+
+	result, err := runWait(t, fc, func() (*WaitResult, error) {
+		return pc.WaitForPort(context.Background(), "127.0.0.1", 9000, TCP)
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("expected success, got %+v, %v", result, err)
+	}
+	if n := d.dials.Load(); n != 6 {
+		t.Errorf("the wait returned after %d dials, want 6: open, flap, then three in a row", n)
+	}
+	var runs []int
+	for _, a := range result.StabilityAttempts {
+		runs = append(runs, a.Consecutive)
+	}
+	if len(runs) != 5 || runs[0] != 1 || runs[1] != 0 || runs[2] != 1 || runs[4] != 3 {
+		t.Errorf("stability attempts %v, want [1 0 1 2 3]", runs)
+	}
+	if len(seen) != 5 || result.FoundPort == nil || result.FoundPort.Cached {
+		t.Errorf("OnAttempt saw %d checks, found %+v", len(seen), result.FoundPort)
+	}
+
+	// With the default of one check the first open dial wins.
+	d = &flappingDialer{script: []bool{true, false}}
+	pc = newStabilityChecker(d, fc, 0)
+	result, err = runWait(t, fc, func() (*WaitResult, error) {
+		return pc.WaitForPort(context.Background(), "127.0.0.1", 9000, TCP)
+	})
+	if err != nil || !result.Success || d.dials.Load() != 1 || len(result.StabilityAttempts) != 1 {
+		t.Errorf("expected success on the first dial, got %+v, %v after %d dials", result, err, d.dials.Load())
+	}
+}
+
+func TestPortChecker_WaitForAnyPortStability(t *testing.T) {
+	d := &flappingDialer{script: []bool{true, false, false, true}}
+	fc := NewFakeClock(time.Time{})
+	pc := newStabilityChecker(d, fc, 2)
+	result, err := runWait(t, fc, func() (*WaitResult, error) {
+		return pc.WaitForAnyPort(context.Background(), "127.0.0.1", 9000, 9000, TCP)
+	})
+	if err != nil || result.Port != 9000 || d.dials.Load() != 5 || result.Attempts != 2 {
+		t.Errorf("expected 9000 after two rounds and 5 dials, got %+v, %v, %d dials", result, err, d.dials.Load())
+	}
+}
+
+func TestPortChecker_WaitOnAttemptAborts(t *testing.T) {
+	d := &flappingDialer{script: []bool{false}}
+	fc := NewFakeClock(time.Time{})
+	stop := errors.New("server exited")
+	calls := 0
+	pc := newStabilityChecker(d, fc, 1, WithPortCheckerOnAttempt(func(a PortWaitAttempt) error {
+		calls++
+		if a.Open || a.Error == "" {
+			t.Errorf("unexpected attempt %+v", a)
+		}
+		if calls == 2 {
+			return stop
+		}
+		return nil
+	}))
+	result, err := runWait(t, fc, func() (*WaitResult, error) {
+		return pc.WaitForPort(context.Background(), "127.0.0.1", 9000, TCP)
+	})
+	if !errors.Is(err, stop) || result.Success || result.Attempts != 2 || len(result.StabilityAttempts) != 0 {
+		t.Errorf("expected the callback error after 2 attempts, got %+v, %v", result, err)
+	}
+}
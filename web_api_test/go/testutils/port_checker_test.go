@@ -0,0 +1,352 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closedPort returns a local TCP port that nothing is listening on.
+func closedPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve port: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+func TestPortChecker_CancelledRangeScanReturnsPromptly(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:   time.Second,
+		RetryInterval: 500 * time.Millisecond,
+		MaxRetries:    3,
+		BackoffFactor: 2,
+		Workers:       16,
+		MinPort:       1,
+		MaxPort:       65535,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// Ports on a loopback address that refuse immediately, so every worker
+	// is parked in backoff when the cancel lands.
+	done := make(chan error, 1)
+	go func() {
+		_, err := pc.CheckPortRange(ctx, "127.0.0.1", 40000, 40999, TCP)
+		done <- err
+	}()
+
+	<-ctx.Done()
+	cancelledAt := time.Now()
+	select {
+	case err := <-done:
+		if elapsed := time.Since(cancelledAt); elapsed > 100*time.Millisecond {
+			t.Errorf("scan returned %v after cancel, want < 100ms", elapsed)
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("scan did not return after cancel")
+	}
+}
+
+func TestPortChecker_PerCheckBudget(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:     time.Second,
+		RetryInterval:   time.Second,
+		MaxRetries:      5,
+		PerCheckTimeout: 50 * time.Millisecond,
+		MinPort:         1,
+		MaxPort:         65535,
+	})
+
+	start := time.Now()
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP)
+	if err == nil {
+		t.Fatal("expected an error for a closed port")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("check took %v, want it capped near the 50ms budget", elapsed)
+	}
+	if result.StopReason != StopBudgetExhausted {
+		t.Errorf("expected stop reason %q, got %q", StopBudgetExhausted, result.StopReason)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestPortChecker_RetriesExhausted(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		DialTimeout:     time.Second,
+		RetryInterval:   time.Millisecond,
+		MaxRetries:      2,
+		PerCheckTimeout: 5 * time.Second,
+		MinPort:         1,
+		MaxPort:         65535,
+	})
+
+	result, err := pc.IsPortOpen(context.Background(), "127.0.0.1", closedPort(t), TCP)
+	if err == nil {
+		t.Fatal("expected an error for a closed port")
+	}
+	if result.StopReason != StopRetriesExhausted {
+		t.Errorf("expected stop reason %q, got %q", StopRetriesExhausted, result.StopReason)
+	}
+	if result.Attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", result.Attempts)
+	}
+}
+
+// steppingClock is a MockClock whose timers advance time immediately, so
+// backoff completes instantly while timings stay reproducible.
+type steppingClock struct {
+	*MockClock
+}
+
+func (c steppingClock) NewTimer(d time.Duration) Timer {
+	t := c.MockClock.NewTimer(d)
+	c.MockClock.Advance(d)
+	return t
+}
+
+func (c steppingClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+// fakeTargets dials successfully only for the given addresses.
+func fakeTargets(open ...string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	set := make(map[string]bool, len(open))
+	for _, a := range open {
+		set[a] = true
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !set[address] {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+		}
+		client, server := net.Pipe()
+		server.Close()
+		return client, nil
+	}
+}
+
+// frozenClock is a MockClock whose timers fire at once without moving time,
+// so results do not depend on how concurrent workers interleave.
+type frozenClock struct {
+	*MockClock
+}
+
+func (c frozenClock) NewTimer(time.Duration) Timer {
+	t := c.MockClock.NewTimer(0)
+	c.MockClock.Advance(0)
+	return t
+}
+
+// retryLog records the retry delays PortChecker logs, per address.
+type retryLog struct {
+	noopLogger
+	mu     sync.Mutex
+	delays map[string][]time.Duration
+}
+
+func (l *retryLog) Debug(msg string, fields map[string]any) {
+	if msg != "connection failed, retrying" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	addr := fields["address"].(string)
+	l.delays[addr] = append(l.delays[addr], fields["delay"].(time.Duration))
+}
+
+func TestPortChecker_DeterministicRunsAreIdentical(t *testing.T) {
+	run := func(seed int64) ([]byte, map[string][]time.Duration) {
+		log := &retryLog{delays: map[string][]time.Duration{}}
+		pc := NewPortChecker(log, PortCheckerConfig{
+			DialTimeout:   time.Second,
+			RetryInterval: 100 * time.Millisecond,
+			MaxRetries:    2,
+			BackoffFactor: 2,
+			JitterEnabled: true,
+			Workers:       8,
+			MinPort:       1,
+			MaxPort:       65535,
+			Deterministic: true,
+		},
+			WithPortCheckerClock(frozenClock{NewMockClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))}),
+			WithPortCheckerSeed(seed),
+			WithPortCheckerDialer(fakeTargets("127.0.0.1:9001", "127.0.0.1:9004")),
+		)
+		result, err := pc.CheckPortRange(context.Background(), "127.0.0.1", 9000, 9015, TCP)
+		if err != nil {
+			t.Fatalf("range check failed: %v", err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("marshal failed: %v", err)
+		}
+		return data, log.delays
+	}
+
+	first, firstDelays := run(42)
+	for i := 0; i < 5; i++ {
+		again, delays := run(42)
+		if !bytes.Equal(first, again) || !reflect.DeepEqual(firstDelays, delays) {
+			t.Fatalf("same seed produced different results:\n%s %v\n%s %v", first, firstDelays, again, delays)
+		}
+	}
+	if _, other := run(7); reflect.DeepEqual(firstDelays, other) {
+		t.Error("different seeds produced identical jitter")
+	}
+	if d := firstDelays["127.0.0.1:9000"]; len(d) != 2 || reflect.DeepEqual(d, firstDelays["127.0.0.1:9002"]) {
+		t.Errorf("retry delays are not keyed by port: %v", firstDelays)
+	}
+
+	var decoded PortRangeResult
+	if err := json.Unmarshal(first, &decoded); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+	if want := []int{9001, 9004}; len(decoded.OpenPorts) != 2 || decoded.OpenPorts[0] != want[0] || decoded.OpenPorts[1] != want[1] {
+		t.Errorf("expected open ports %v, got %v", want, decoded.OpenPorts)
+	}
+	for i, stat := range decoded.PerPortStats {
+		if stat.Port != 9000+i {
+			t.Errorf("PerPortStats[%d] is port %d, want %d", i, stat.Port, 9000+i)
+		}
+	}
+}
+
+func TestPortChecker_WaitForPortOnFakeClock(t *testing.T) {
+	fc := NewFakeClock(time.Time{})
+	start := fc.Now()
+	newChecker := func(d *flappingDialer) *PortChecker {
+		return NewPortChecker(nil, PortCheckerConfig{
+			RetryInterval: time.Hour,
+			BackoffFactor: 2,
+			MaxRetries:    1,
+			WaitTimeout:   time.Minute,
+		}, WithPortCheckerDialer(d.dial), WithPortCheckerClock(fc))
+	}
+	wait := func(ctx context.Context, pc *PortChecker) <-chan *WaitResult {
+		done := make(chan *WaitResult, 1)
+		go func() {
+			result, _ := pc.WaitForPort(ctx, "127.0.0.1", 8080, TCP)
+			done <- result
+		}()
+		return done
+	}
+
+	// Closed for one IsPortOpen call (two dials), then open.
+	d := &flappingDialer{script: []bool{false, false, true}}
+	done := wait(context.Background(), newChecker(d))
+	fc.BlockUntil(1)
+	fc.Advance(time.Hour) // IsPortOpen backoff
+	fc.BlockUntil(1)
+	fc.Advance(2 * time.Hour) // WaitForPort retry delay
+	result := <-done
+	if !result.Success || result.Attempts != 2 || d.dials.Load() != 3 {
+		t.Errorf("got %+v after %d dials", result, d.dials.Load())
+	}
+	if elapsed := fc.Now().Sub(start); elapsed != 3*time.Hour {
+		t.Errorf("waited %v of fake time, want 3h", elapsed)
+	}
+
+	// Cancelling mid-wait stops the pending timer.
+	ctx, cancel := context.WithCancel(context.Background())
+	done = wait(ctx, newChecker(&flappingDialer{script: []bool{false}}))
+	fc.BlockUntil(1)
+	cancel()
+	if result := <-done; result.Success {
+		t.Errorf("cancelled wait succeeded: %+v", result)
+	}
+	if n := fc.Waiters(); n != 0 {
+		t.Errorf("%d timer(s) left pending after the wait returned", n)
+	}
+}
+
+func TestPortChecker_WaitPublishesPortOpen(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	open := ln.Addr().(*net.TCPAddr).Port
+	closed := closedPort(t)
+
+	bus := NewEventBus()
+	sub := bus.Subscribe(EventPortOpen)
+	pc := NewPortChecker(nil, PortCheckerConfig{
+		RetryInterval: 10 * time.Millisecond,
+		MaxRetries:    1,
+		WaitTimeout:   200 * time.Millisecond,
+	}, WithPortCheckerEventBus(bus))
+
+	if _, err := pc.WaitForPort(context.Background(), "127.0.0.1", closed, TCP); err == nil {
+		t.Fatal("waiting on a closed port succeeded")
+	}
+	if len(bus.History()) != 0 {
+		t.Fatalf("a failed wait published %v", bus.History())
+	}
+
+	if _, err := pc.WaitForPort(context.Background(), "127.0.0.1", open, TCP); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pc.WaitForAnyPort(context.Background(), "127.0.0.1", open, open, TCP); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		e := <-sub
+		if e.Source != "port_checker" || e.Fields["port"] != open || e.Fields["host"] != "127.0.0.1" || e.Fields["protocol"] != "tcp" {
+			t.Errorf("event %d: %+v", i, e)
+		}
+	}
+}
+
+func TestPortChecker_BuildNetworkAddress(t *testing.T) {
+	pc := NewPortChecker(nil, PortCheckerConfig{})
+
+	tests := []struct {
+		host        string
+		protocol    Protocol
+		ipVersion   IPVersion
+		wantNetwork string
+		wantAddress string
+	}{
+		{"127.0.0.1", TCP, AnyIP, "tcp", "127.0.0.1:8080"},
+		{"127.0.0.1", TCP, IPv4, "tcp4", "127.0.0.1:8080"},
+		{"::1", TCP, AnyIP, "tcp", "[::1]:8080"},
+		{"::1", UDP, IPv6, "udp6", "[::1]:8080"},
+		{"[::1]", TCP, IPv6, "tcp6", "[::1]:8080"},
+		{"fe80::1%eth0", TCP, IPv6, "tcp6", "[fe80::1%eth0]:8080"},
+		{"localhost", TCP, IPv4, "tcp4", "localhost:8080"},
+		{"db.internal", TCP, IPv6, "tcp6", "db.internal:8080"},
+		{"db.internal", UDP4, AnyIP, "udp4", "db.internal:8080"},
+		{"[fe80::1%eth0]", TCP, AnyIP, "tcp", "[fe80::1%eth0]:8080"},
+		{"[fe80::1%25eth0]", TCP, IPv6, "tcp6", "[fe80::1%eth0]:8080"},
+		{"::ffff:127.0.0.1", TCP, IPv4, "tcp4", "127.0.0.1:8080"},
+		{"[::ffff:10.0.0.1]", UDP, AnyIP, "udp", "10.0.0.1:8080"},
+		{"2001:DB8:0:0::1", TCP6, AnyIP, "tcp6", "[2001:db8::1]:8080"},
+		{"::", TCP, AnyIP, "tcp", "[::]:8080"},
+		{"[db.internal]", TCP, AnyIP, "tcp", "db.internal:8080"},
+		{"", TCP, IPv4, "tcp4", ":8080"},
+	}
+
+	for _, tt := range tests {
+		network, address := pc.buildNetworkAddress(tt.host, "8080", tt.protocol, tt.ipVersion)
+		if network != tt.wantNetwork || address != tt.wantAddress {
+			t.Errorf("buildNetworkAddress(%q, %s, %s) = (%q, %q), want (%q, %q)",
+				tt.host, tt.protocol, tt.ipVersion, network, address, tt.wantNetwork, tt.wantAddress)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Preflight – host prerequisites checked before any suite setup
+// --------------------------------------------------------------------
+
+// PreflightStatus is the outcome of one check.
+type PreflightStatus int
+
+const (
+	PreflightPass PreflightStatus = iota
+	PreflightWarn
+	PreflightFail
+	PreflightSkipped
+)
+
+func (s PreflightStatus) String() string {
+	switch s {
+	case PreflightPass:
+		return "PASS"
+	case PreflightWarn:
+		return "WARN"
+	case PreflightFail:
+		return "FAIL"
+	case PreflightSkipped:
+		return "SKIP"
+	}
+	return "PreflightStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// PreflightResult is what one check found. Hint tells a person how to fix a
+// warning or failure.
+type PreflightResult struct {
+	Name     string
+	Status   PreflightStatus
+	Message  string
+	Hint     string
+	Duration time.Duration
+}
+
+// PreflightCheck is one named prerequisite. Name is what
+// PreflightConfig.Skip matches against.
+type PreflightCheck struct {
+	Name string
+	Run  func(ctx context.Context, cfg *Config) PreflightResult
+}
+
+// PreflightReport lists the results in check order.
+type PreflightReport struct {
+	Results []PreflightResult
+}
+
+// Failed reports whether any check failed. Warnings do not fail.
+func (r *PreflightReport) Failed() bool {
+	for _, res := range r.Results {
+		if res.Status == PreflightFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the failed checks as one error, or nil.
+func (r *PreflightReport) Err() error {
+	errs := NewCompositeError("preflight")
+	for _, res := range r.Results {
+		if res.Status == PreflightFail {
+			errs.Add(withKind(ErrUnavailable, fmt.Errorf("%s: %s", res.Name, res.Message)))
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// String renders one line per check, with the remediation hint under each
+// warning and failure:
+//
+//	FAIL exec:docker   docker not found in PATH
+//	     -> install Docker (https://docs.docker.com/get-docker/) or add it to PATH
+func (r *PreflightReport) String() string {
+	width := 0
+	for _, res := range r.Results {
+		width = max(width, len(res.Name))
+	}
+	var b strings.Builder
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "%s %-*s  %s\n", res.Status, width, res.Name, res.Message)
+		if res.Hint != "" && (res.Status == PreflightWarn || res.Status == PreflightFail) {
+			fmt.Fprintf(&b, "     -> %s\n", res.Hint)
+		}
+	}
+	return b.String()
+}
+
+// Preflight runs checks in order and reports on each; with no checks it
+// runs CheckDiskSpace for cfg's temp directory. Checks named in
+// cfg.Preflight.Skip are reported as skipped. A nil cfg uses DefaultConfig.
+// Call it from TestMain to fail fast with a readable summary:
+//
+//	func TestMain(m *testing.M) {
+//		report := testutils.Preflight(cfg,
+//			testutils.CheckExecutable("docker"),
+//			testutils.CheckComposeFile("docker-compose.yml"),
+//			testutils.CheckPortsFree("localhost", 3000, 5432),
+//		)
+//		if report.Failed() {
+//			fmt.Fprint(os.Stderr, report)
+//			os.Exit(1)
+//		}
+//		os.Exit(m.Run())
+//	}
+func Preflight(cfg *Config, checks ...PreflightCheck) *PreflightReport {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	if len(checks) == 0 {
+		checks = []PreflightCheck{CheckDiskSpace("", 0)}
+	}
+	report := &PreflightReport{}
+	for _, check := range checks {
+		if preflightSkipped(cfg.Preflight.Skip, check.Name) {
+			report.Results = append(report.Results, PreflightResult{
+				Name: check.Name, Status: PreflightSkipped, Message: "skipped by config",
+			})
+			continue
+		}
+		start := time.Now()
+		res := runPreflightCheck(check, cfg)
+		res.Name = check.Name
+		res.Duration = time.Since(start)
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// preflightCheckTimeout bounds each check, so an unreachable clock
+// reference cannot stall TestMain.
+const preflightCheckTimeout = 10 * time.Second
+
+func runPreflightCheck(check PreflightCheck, cfg *Config) (res PreflightResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			res = PreflightResult{Status: PreflightFail, Message: fmt.Sprintf("check panicked: %v", p)}
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), preflightCheckTimeout)
+	defer cancel()
+	return check.Run(ctx, cfg)
+}
+
+func preflightSkipped(skip []string, name string) bool {
+	for _, pattern := range skip {
+		if ok, _ := path.Match(pattern, name); ok || pattern == name {
+			return true
+		}
+	}
+	return false
+}
+
+// installHints are remediation hints for executables the suites commonly
+// need.
+var installHints = map[string]string{
+	"docker": "install Docker (https://docs.docker.com/get-docker/) or add it to PATH",
+	"npm":    "install Node.js, which ships npm (https://nodejs.org/), or add it to PATH",
+	"node":   "install Node.js (https://nodejs.org/) or add it to PATH",
+}
+
+// CheckExecutable fails when name cannot be found in PATH. It runs the
+// CommandInPath capability, so a test calling Requires with it reuses the
+// result. The check is named "exec:<name>".
+func CheckExecutable(name string) PreflightCheck {
+	return CheckCapability(CommandInPath(name))
+}
+
+// CheckServerCommand checks that the binary a ServerManager will run, such
+// as npm, is available. A relative Command with a directory part is looked
+// up under sc.Path, where the server runs. The check is named
+// "exec:<Command>".
+func CheckServerCommand(sc ServerConfig) PreflightCheck {
+	name := sc.Command
+	if strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) && sc.Path != "" {
+		name = filepath.Join(sc.Path, name)
+	}
+	check := CheckExecutable(name)
+	check.Name = "exec:" + sc.Command
+	return check
+}
+
+// CheckComposeFile fails when the compose file at p is missing. The check
+// is named "compose-file".
+func CheckComposeFile(p string) PreflightCheck {
+	return PreflightCheck{
+		Name: "compose-file",
+		Run: func(ctx context.Context, cfg *Config) PreflightResult {
+			info, err := os.Stat(p)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				return PreflightResult{Status: PreflightFail, Message: p + " does not exist",
+					Hint: "run the suite from the directory holding the compose file or fix its path"}
+			case err != nil:
+				return PreflightResult{Status: PreflightFail, Message: err.Error(), Hint: "check the permissions of " + p}
+			case info.IsDir():
+				return PreflightResult{Status: PreflightFail, Message: p + " is a directory", Hint: "point at the compose file itself"}
+			}
+			return PreflightResult{Status: PreflightPass, Message: p + " found"}
+		},
+	}
+}
+
+// CheckDiskSpace fails when dir has less than minBytes free. An empty dir
+// uses cfg.Paths.TempDir (or os.TempDir), and minBytes <= 0 uses
+// cfg.Preflight.MinFreeDisk. Platforms without a free-space query warn.
+// The check is named "disk-space".
+func CheckDiskSpace(dir string, minBytes int64) PreflightCheck {
+	return PreflightCheck{
+		Name: "disk-space",
+		Run: func(ctx context.Context, cfg *Config) PreflightResult {
+			d, need := dir, minBytes
+			if d == "" {
+				d = cfg.Paths.TempDir
+			}
+			if d == "" {
+				d = os.TempDir()
+			}
+			if need <= 0 {
+				need = cfg.Preflight.MinFreeDisk
+			}
+			free, err := freeDiskBytes(d)
+			if err != nil {
+				return PreflightResult{Status: PreflightWarn, Message: fmt.Sprintf("cannot measure free space in %s: %v", d, err)}
+			}
+			msg := fmt.Sprintf("%s free in %s", Bytes(int64(free)), d)
+			if need > 0 && free < uint64(need) {
+				return PreflightResult{Status: PreflightFail, Message: msg + ", need " + Bytes(need),
+					Hint: "free up space or point paths.temp_dir at a larger volume"}
+			}
+			return PreflightResult{Status: PreflightPass, Message: msg}
+		},
+	}
+}
+
+// CheckPortsFree fails when any of ports is already bound on host, which
+// would make a service fail to start. The check is named "ports".
+func CheckPortsFree(host string, ports ...int) PreflightCheck {
+	return PreflightCheck{
+		Name: "ports",
+		Run: func(ctx context.Context, cfg *Config) PreflightResult {
+			var taken []string
+			for _, port := range ports {
+				ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+				if err != nil {
+					taken = append(taken, strconv.Itoa(port))
+					continue
+				}
+				ln.Close()
+			}
+			if len(taken) > 0 {
+				return PreflightResult{Status: PreflightFail,
+					Message: fmt.Sprintf("port(s) %s already in use on %s", strings.Join(taken, ", "), host),
+					Hint:    "stop the process holding them (lsof -i :" + taken[0] + ") or configure other ports"}
+			}
+			return PreflightResult{Status: PreflightPass, Message: fmt.Sprintf("%d port(s) free on %s", len(ports), host)}
+		},
+	}
+}
+
+// CheckClockSkew compares the local clock with reference and fails when
+// they differ by more than cfg.Preflight.MaxClockSkew; an unreachable
+// reference only warns. The check is named "clock-skew".
+func CheckClockSkew(reference func(ctx context.Context) (time.Time, error)) PreflightCheck {
+	return PreflightCheck{
+		Name: "clock-skew",
+		Run: func(ctx context.Context, cfg *Config) PreflightResult {
+			before := time.Now()
+			ref, err := reference(ctx)
+			if err != nil {
+				return PreflightResult{Status: PreflightWarn, Message: "reference clock unavailable: " + err.Error()}
+			}
+			// Compare against the midpoint of the round trip.
+			local := before.Add(time.Since(before) / 2)
+			skew := local.Sub(ref)
+			if skew < 0 {
+				skew = -skew
+			}
+			msg := "clock differs from reference by " + HumanDuration(skew)
+			if limit := cfg.Preflight.MaxClockSkew; limit > 0 && skew > limit {
+				return PreflightResult{Status: PreflightFail, Message: msg + ", limit " + HumanDuration(limit),
+					Hint: "sync the system clock (e.g. enable NTP); skew breaks token expiry and TLS checks"}
+			}
+			return PreflightResult{Status: PreflightPass, Message: msg}
+		},
+	}
+}
+
+// HTTPDateReference reads the reference time from the Date header of a HEAD
+// request to url, for CheckClockSkew. The header has one-second
+// resolution, so tolerate at least that much skew.
+func HTTPDateReference(url string) func(ctx context.Context) (time.Time, error) {
+	return func(ctx context.Context) (time.Time, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return time.Time{}, err
+		}
+		resp.Body.Close()
+		return http.ParseTime(resp.Header.Get("Date"))
+	}
+}
@@ -0,0 +1,112 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resultNamed(t *testing.T, r *PreflightReport, name string) PreflightResult {
+	t.Helper()
+	for _, res := range r.Results {
+		if res.Name == name {
+			return res
+		}
+	}
+	t.Fatalf("no result %q in:\n%s", name, r)
+	return PreflightResult{}
+}
+
+func TestPreflight_BuiltinChecks(t *testing.T) {
+	dir := t.TempDir()
+	compose := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(compose, []byte("services: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	busy := ln.Addr().(*net.TCPAddr).Port
+
+	cfg := DefaultConfig()
+	cfg.Paths.TempDir = dir
+	report := Preflight(cfg,
+		CheckExecutable("sh"),
+		CheckServerCommand(ServerConfig{Command: "no-such-binary-for-preflight"}),
+		CheckComposeFile(compose),
+		CheckComposeFile(filepath.Join(dir, "missing.yml")),
+		CheckDiskSpace("", 1),
+		CheckPortsFree("127.0.0.1", busy),
+	)
+
+	want := map[string]PreflightStatus{
+		"exec:sh":                           PreflightPass,
+		"exec:no-such-binary-for-preflight": PreflightFail,
+		"disk-space":                        PreflightPass,
+		"ports":                             PreflightFail,
+	}
+	for name, status := range want {
+		if got := resultNamed(t, report, name); got.Status != status {
+			t.Errorf("%s: %s %q, want %s", name, got.Status, got.Message, status)
+		}
+	}
+	if report.Results[2].Status != PreflightPass || report.Results[3].Status != PreflightFail {
+		t.Errorf("compose checks: %+v, %+v", report.Results[2], report.Results[3])
+	}
+	if !report.Failed() || !errors.Is(report.Err(), ErrUnavailable) {
+		t.Errorf("Failed = %v, Err = %v", report.Failed(), report.Err())
+	}
+	out := report.String()
+	if !strings.Contains(out, "FAIL ports") || !strings.Contains(out, "-> stop the process holding them") {
+		t.Errorf("report:\n%s", out)
+	}
+
+	if res := resultNamed(t, Preflight(cfg, CheckDiskSpace(dir, 1<<62)), "disk-space"); res.Status != PreflightFail {
+		t.Errorf("disk-space with an impossible minimum: %s %q", res.Status, res.Message)
+	}
+}
+
+func TestPreflight_SkipAndDefaults(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Paths.TempDir = t.TempDir()
+	cfg.Preflight.Skip = []string{"exec:*", "ports"}
+	report := Preflight(cfg, CheckExecutable("no-such-binary-for-preflight"), CheckPortsFree("127.0.0.1", 1), CheckDiskSpace("", 1))
+	if report.Failed() || report.Results[0].Status != PreflightSkipped || report.Results[1].Status != PreflightSkipped {
+		t.Errorf("skip config ignored:\n%s", report)
+	}
+
+	// With no checks, Preflight still verifies the temp directory.
+	cfg.Preflight.MinFreeDisk = 1
+	if report := Preflight(cfg); len(report.Results) != 1 || report.Results[0].Name != "disk-space" {
+		t.Errorf("default checks:\n%s", report)
+	}
+}
+
+func TestPreflight_ClockSkew(t *testing.T) {
+	cfg := DefaultConfig()
+	fixed := func(d time.Duration) func(context.Context) (time.Time, error) {
+		return func(context.Context) (time.Time, error) { return time.Now().Add(d), nil }
+	}
+	report := Preflight(cfg,
+		CheckClockSkew(fixed(-10*time.Second)),
+		CheckClockSkew(func(context.Context) (time.Time, error) { return time.Time{}, errors.New("no route") }),
+	)
+	if report.Results[0].Status != PreflightFail || report.Results[1].Status != PreflightWarn {
+		t.Errorf("clock skew:\n%s", report)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	if res := Preflight(cfg, CheckClockSkew(HTTPDateReference(srv.URL))).Results[0]; res.Status != PreflightPass {
+		t.Errorf("HTTP Date reference: %s %q", res.Status, res.Message)
+	}
+}
@@ -375,17 +375,37 @@ func DefaultRandomConfig() RandomIntConfig {
 	}
 }
 
+// RandomIntOption configures a RandomIntGenerator.
+type RandomIntOption func(*RandomIntGenerator)
+
+// WithRandomIntTestRun seeds the generator from run's "generators" child
+// seed, overriding config.Seed.
+func WithRandomIntTestRun(run *TestRun) RandomIntOption {
+	return func(rg *RandomIntGenerator) {
+		if run == nil {
+			return
+		}
+		rg.seed = run.ChildSeed("generators")
+		rg.config.Seed = rg.seed
+		rg.rand = rand.New(rand.NewSource(rg.seed))
+	}
+}
+
 // NewRandomIntGenerator creates a new random integer generator
-func NewRandomIntGenerator(config RandomIntConfig) *RandomIntGenerator {
+func NewRandomIntGenerator(config RandomIntConfig, opts ...RandomIntOption) *RandomIntGenerator {
 	if config.Seed == 0 {
 		config.Seed = time.Now().UnixNano()
 	}
 
-	return &RandomIntGenerator{
+	rg := &RandomIntGenerator{
 		rand:   rand.New(rand.NewSource(config.Seed)),
 		seed:   config.Seed,
 		config: config,
 	}
+	for _, opt := range opts {
+		opt(rg)
+	}
+	return rg
 }
 
 // Generate generates a random integer within configured bounds
@@ -498,37 +518,63 @@ func (rg *RandomIntGenerator) GenerateUnique(count int) ([]int, error) {
 	return rg.GenerateUniqueWithBounds(count, rg.config.Min, rg.config.Max)
 }
 
-// GenerateUniqueWithBounds generates unique integers with custom bounds
+// GenerateUniqueWithBounds generates count distinct integers in [min, max]
+// that satisfy AllowZero and AllowNeg. It draws with a partial
+// Fisher–Yates shuffle over the valid candidates, so it succeeds whenever
+// there are at least count of them, and it records only the swapped
+// positions, so memory is O(count) however wide the range. A given seed
+// always yields the same values in the same order.
 func (rg *RandomIntGenerator) GenerateUniqueWithBounds(count, min, max int) ([]int, error) {
-	if count > (max - min + 1) {
-		return nil, fmt.Errorf("cannot generate %d unique values in range [%d, %d]", count, min, max)
+	if count < 0 {
+		return nil, fmt.Errorf("count must not be negative, got %d", count)
+	}
+	if min > max {
+		min, max = max, min
+	}
+	lo := min
+	if !rg.config.AllowNeg && lo < 0 {
+		lo = 0
+	}
+	skipZero := !rg.config.AllowZero && lo <= 0 && max >= 0
+	valid := 0
+	if max >= lo {
+		valid = max - lo + 1
+		if skipZero {
+			valid--
+		}
+	}
+	if count > valid {
+		return nil, fmt.Errorf("cannot generate %d unique values in range [%d, %d]: only %d satisfy the constraints", count, min, max, valid)
 	}
 
 	rg.mu.Lock()
 	defer rg.mu.Unlock()
 
-	generated := make(map[int]bool)
-	results := make([]int, 0, count)
-
-	for len(results) < count {
-		value := rg.rand.Intn(max-min+1) + min
-
-		if !generated[value] {
-			// Apply constraints
-			if (!rg.config.AllowZero && value == 0) || (!rg.config.AllowNeg && value < 0) {
-				continue
-			}
-
-			generated[value] = true
-			results = append(results, value)
-			rg.callCount.Add(1)
+	// candidate maps a position in the shuffled sequence of valid values
+	// to its value; positions not in swapped still hold their own.
+	candidate := func(i int) int {
+		v := lo + i
+		if skipZero && v >= 0 {
+			v++
 		}
-
-		// Safety check
-		if len(generated) > (max-min+1)*2 {
-			return nil, fmt.Errorf("failed to generate %d unique values after excessive attempts", count)
+		return v
+	}
+	swapped := make(map[int]int, count)
+	at := func(i int) int {
+		if v, ok := swapped[i]; ok {
+			return v
 		}
+		return candidate(i)
+	}
+
+	results := make([]int, 0, count)
+	for i := 0; i < count; i++ {
+		j := i + rg.rand.Intn(valid-i)
+		vi, vj := at(i), at(j)
+		swapped[j] = vi
+		results = append(results, vj)
 	}
+	rg.callCount.Add(int64(len(results)))
 
 	return results, nil
 }
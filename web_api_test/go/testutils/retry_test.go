@@ -0,0 +1,104 @@
+package testutils
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func uniqueGenerator(seed int64, allowZero, allowNeg bool) *RandomIntGenerator {
+	return NewRandomIntGenerator(RandomIntConfig{Seed: seed, AllowZero: allowZero, AllowNeg: allowNeg, RetryMax: 10})
+}
+
+func assertUniqueInRange(t *testing.T, values []int, min, max int, allowZero bool) {
+	t.Helper()
+	seen := make(map[int]bool, len(values))
+	for _, v := range values {
+		if v < min || v > max {
+			t.Errorf("value %d outside [%d, %d]", v, min, max)
+		}
+		if v == 0 && !allowZero {
+			t.Errorf("zero generated with AllowZero=false")
+		}
+		if seen[v] {
+			t.Errorf("value %d generated twice", v)
+		}
+		seen[v] = true
+	}
+}
+
+func TestGenerateUniqueWithBounds_FullRangeIsPermutation(t *testing.T) {
+	rg := uniqueGenerator(7, true, false)
+	values, err := rg.GenerateUniqueWithBounds(50, 1, 50)
+	if err != nil {
+		t.Fatalf("count == range size should succeed: %v", err)
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+	for i, v := range sorted {
+		if v != i+1 {
+			t.Fatalf("expected a permutation of 1..50, got %v", values)
+		}
+	}
+	if rg.CallCount() != 50 {
+		t.Errorf("expected 50 calls counted, got %d", rg.CallCount())
+	}
+}
+
+func TestGenerateUniqueWithBounds_ExcludesZero(t *testing.T) {
+	rg := uniqueGenerator(3, false, true)
+	values, err := rg.GenerateUniqueWithBounds(6, -3, 3)
+	if err != nil {
+		t.Fatalf("six non-zero values exist in [-3, 3]: %v", err)
+	}
+	assertUniqueInRange(t, values, -3, 3, false)
+	if len(values) != 6 {
+		t.Errorf("expected 6 values, got %v", values)
+	}
+
+	if _, err := rg.GenerateUniqueWithBounds(7, -3, 3); err == nil {
+		t.Error("expected an error asking for 7 non-zero values in [-3, 3]")
+	}
+	if _, err := uniqueGenerator(3, false, false).GenerateUniqueWithBounds(1, 0, 0); err == nil {
+		t.Error("expected an error when zero is the only candidate")
+	}
+}
+
+func TestGenerateUniqueWithBounds_NegativeBounds(t *testing.T) {
+	values, err := uniqueGenerator(11, true, true).GenerateUniqueWithBounds(5, -10, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUniqueInRange(t, values, -10, -1, true)
+
+	swapped, err := uniqueGenerator(11, true, true).GenerateUniqueWithBounds(5, -1, -10)
+	if err != nil || !reflect.DeepEqual(swapped, values) {
+		t.Errorf("expected reversed bounds to behave the same, got %v, %v", swapped, err)
+	}
+
+	// Without AllowNeg only [0, 4] of [-5, 4] is usable.
+	values, err = uniqueGenerator(11, true, false).GenerateUniqueWithBounds(5, -5, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertUniqueInRange(t, values, 0, 4, true)
+	if _, err := uniqueGenerator(11, true, false).GenerateUniqueWithBounds(1, -5, -1); err == nil {
+		t.Error("expected an error for an all-negative range without AllowNeg")
+	}
+}
+
+func TestGenerateUniqueWithBounds_DeterministicAndSparse(t *testing.T) {
+	a, err := uniqueGenerator(42, false, true).GenerateUniqueWithBounds(1000, -1<<40, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := uniqueGenerator(42, false, true).GenerateUniqueWithBounds(1000, -1<<40, 1<<40)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("same seed produced different values")
+	}
+	assertUniqueInRange(t, a, -1<<40, 1<<40, false)
+
+	if values, err := uniqueGenerator(42, true, true).GenerateUniqueWithBounds(0, 1, 10); err != nil || len(values) != 0 {
+		t.Errorf("expected no values for count 0, got %v, %v", values, err)
+	}
+}
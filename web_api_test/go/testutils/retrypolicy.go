@@ -75,8 +75,9 @@ func UnaryRetryInterceptor(policy *RetryPolicy) grpc.UnaryClientInterceptor {
                 case <-time.After(backoff):
                     // Proceed with retry
                 case <-ctx.Done():
-                    // Context cancelled while waiting for backoff
-                    return status.Errorf(codes.DeadlineExceeded, "context cancelled before retry: %v", ctx.Err())
+                    // Context cancelled while waiting for backoff. The status
+                    // keeps the gRPC code; errors.Is still finds ctx.Err().
+                    return ctxKindError(ctx, status.Errorf(codes.DeadlineExceeded, "context cancelled before retry: %v", ctx.Err()))
                 }
             }
 
@@ -104,10 +105,23 @@ func UnaryRetryInterceptor(policy *RetryPolicy) grpc.UnaryClientInterceptor {
         }
 
         // We exhausted all retries
-        return fmt.Errorf("retry policy exhausted: last error: %w", lastErr)
+        err := fmt.Errorf("retry policy exhausted: last error: %w", lastErr)
+        if ctx.Err() != nil {
+            return ctxKindError(ctx, err)
+        }
+        if status.Code(lastErr) == codes.DeadlineExceeded {
+            return withKind(ErrTimeout, err)
+        }
+        return tagKind(err)
     }
 }
 
+// ctxKindError tags err, returned because ctx is done, with ctx.Err() and
+// its kind sentinel (ErrCancelled or ErrTimeout), keeping err's message.
+func ctxKindError(ctx context.Context, err error) error {
+    return withKind(Kind(ctx.Err()).Sentinel(), withKind(ctx.Err(), err))
+}
+
 // calculateBackoff computes the wait duration using Exponential Backoff with Jitter.
 // Formula: min(cap, base * multiplier^(attempt-1)) + random_jitter
 func calculateBackoff(policy *RetryPolicy, attempt int) time.Duration {
@@ -0,0 +1,235 @@
+package testutils
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Rolling windows and moving averages for streaming metrics
+// ------------------------------------------------------------------------
+
+// WindowStats summarises the samples in a RollingWindow.
+type WindowStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean"`
+	P95   float64 `json:"p95"`
+	Max   float64 `json:"max"`
+}
+
+// RollingWindowOption configures NewRollingWindow.
+type RollingWindowOption func(*RollingWindow)
+
+// WithWindowAge keeps samples observed within d. Observe evicts against the
+// time of the sample it adds and Stats against the window's clock.
+func WithWindowAge(d time.Duration) RollingWindowOption {
+	return func(w *RollingWindow) { w.maxAge = d }
+}
+
+// WithWindowSize keeps at most the n most recent samples.
+func WithWindowSize(n int) RollingWindowOption {
+	return func(w *RollingWindow) { w.maxCount = n }
+}
+
+// WithWindowClock sets the clock Stats measures sample ages against.
+func WithWindowClock(c Clock) RollingWindowOption {
+	return func(w *RollingWindow) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// defaultWindowSize bounds a window configured by age alone, so a hot path
+// cannot grow it without limit.
+const defaultWindowSize = 10000
+
+type windowSample struct {
+	value float64
+	at    time.Time
+}
+
+// RollingWindow holds the most recent samples of a metric, bounded by age,
+// by count, or both. Expired samples are dropped lazily: Observe trims the
+// front of the window and Stats skips anything older than its cutoff, so
+// neither walks the whole window under the write lock. Samples are assumed
+// to arrive in roughly increasing time order.
+type RollingWindow struct {
+	mu       sync.RWMutex
+	clock    Clock
+	maxAge   time.Duration
+	maxCount int
+	samples  []windowSample
+	start    int // index of the oldest live sample
+}
+
+// NewRollingWindow returns a window holding the last defaultWindowSize
+// samples unless configured otherwise.
+func NewRollingWindow(opts ...RollingWindowOption) *RollingWindow {
+	w := &RollingWindow{clock: RealClock{}}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.maxCount <= 0 {
+		w.maxCount = defaultWindowSize
+	}
+	return w
+}
+
+// Observe adds value at time at. A zero at means now.
+func (w *RollingWindow) Observe(value float64, at time.Time) {
+	if at.IsZero() {
+		at = w.clock.Now()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples = append(w.samples, windowSample{value: value, at: at})
+	if live := len(w.samples) - w.start; live > w.maxCount {
+		w.start += live - w.maxCount
+	}
+	if w.maxAge > 0 {
+		cutoff := at.Add(-w.maxAge)
+		for w.start < len(w.samples) && w.samples[w.start].at.Before(cutoff) {
+			w.start++
+		}
+	}
+	// Compact once the dead prefix outweighs the live samples, which keeps
+	// Observe amortised O(1).
+	if w.start > 0 && w.start >= len(w.samples)-w.start {
+		n := copy(w.samples, w.samples[w.start:])
+		clear(w.samples[n:])
+		w.samples = w.samples[:n]
+		w.start = 0
+	}
+}
+
+// ObserveDuration adds d as nanoseconds.
+func (w *RollingWindow) ObserveDuration(d time.Duration, at time.Time) {
+	w.Observe(float64(d), at)
+}
+
+// Stats summarises the samples currently in the window.
+func (w *RollingWindow) Stats() WindowStats {
+	return windowStatsOf(w.Values(w.maxAge))
+}
+
+// StatsOver summarises the samples observed within d of now. d is capped
+// by the window's own age limit, and d <= 0 means the whole window.
+func (w *RollingWindow) StatsOver(d time.Duration) WindowStats {
+	return windowStatsOf(w.Values(d))
+}
+
+// Values returns a copy of the samples observed within d of now, oldest
+// first; d <= 0 means the whole window.
+func (w *RollingWindow) Values(d time.Duration) []float64 {
+	if w.maxAge > 0 && (d <= 0 || d > w.maxAge) {
+		d = w.maxAge
+	}
+	var cutoff time.Time
+	if d > 0 {
+		cutoff = w.clock.Now().Add(-d)
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	live := w.samples[w.start:]
+	values := make([]float64, 0, len(live))
+	for _, s := range live {
+		if d <= 0 || !s.at.Before(cutoff) {
+			values = append(values, s.value)
+		}
+	}
+	return values
+}
+
+// Len returns the number of samples held, including any not yet evicted.
+func (w *RollingWindow) Len() int {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return len(w.samples) - w.start
+}
+
+// Reset drops every sample.
+func (w *RollingWindow) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples = nil
+	w.start = 0
+}
+
+// windowStatsOf computes the summary outside any lock. P95 interpolates
+// like NumberCollection.Percentile.
+func windowStatsOf(values []float64) WindowStats {
+	if len(values) == 0 {
+		return WindowStats{}
+	}
+	sort.Float64s(values)
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	index := 0.95 * float64(len(values)-1)
+	lower := int(math.Floor(index))
+	p95 := values[lower]
+	if upper := int(math.Ceil(index)); upper != lower {
+		p95 += (values[upper] - p95) * (index - float64(lower))
+	}
+	return WindowStats{
+		Count: len(values),
+		Mean:  sum / float64(len(values)),
+		P95:   p95,
+		Max:   values[len(values)-1],
+	}
+}
+
+// EWMA is an exponentially weighted moving average. Each observation moves
+// the average alpha of the way towards it, so a larger alpha reacts faster
+// and a smaller one smooths more. It is lock-free and safe for concurrent
+// use.
+type EWMA struct {
+	alpha float64
+	bits  atomic.Uint64 // math.Float64bits of the average; NaN until seeded
+}
+
+// NewEWMA returns an average with the given smoothing factor, which is
+// clamped to (0, 1]. The first observation seeds the average.
+func NewEWMA(alpha float64) *EWMA {
+	if alpha <= 0 || math.IsNaN(alpha) {
+		alpha = math.SmallestNonzeroFloat64
+	}
+	if alpha > 1 {
+		alpha = 1
+	}
+	e := &EWMA{alpha: alpha}
+	e.Reset()
+	return e
+}
+
+// Observe folds v into the average and returns the new value.
+func (e *EWMA) Observe(v float64) float64 {
+	for {
+		old := e.bits.Load()
+		next := v
+		if cur := math.Float64frombits(old); !math.IsNaN(cur) {
+			next = cur + e.alpha*(v-cur)
+		}
+		if e.bits.CompareAndSwap(old, math.Float64bits(next)) {
+			return next
+		}
+	}
+}
+
+// Value returns the current average, or 0 before the first observation.
+func (e *EWMA) Value() float64 {
+	if v := math.Float64frombits(e.bits.Load()); !math.IsNaN(v) {
+		return v
+	}
+	return 0
+}
+
+// Reset forgets every observation.
+func (e *EWMA) Reset() {
+	e.bits.Store(math.Float64bits(math.NaN()))
+}
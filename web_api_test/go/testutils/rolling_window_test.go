@@ -0,0 +1,131 @@
+package testutils
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRollingWindow_EvictsByAge(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	w := NewRollingWindow(WithWindowAge(time.Minute), WithWindowClock(clock))
+
+	for i := 1; i <= 100; i++ {
+		w.Observe(float64(i), time.Time{})
+		clock.Advance(time.Second)
+	}
+	// Now 100s after the first sample: only the last 60 are in range.
+	stats := w.Stats()
+	if stats.Count != 60 || stats.Max != 100 || stats.Mean != 70.5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if want := 41 + 0.95*59; math.Abs(stats.P95-want) > 1e-9 {
+		t.Errorf("expected p95 %v, got %v", want, stats.P95)
+	}
+	if got := w.StatsOver(10 * time.Second); got.Count != 10 || got.Mean != 95.5 {
+		t.Errorf("expected the last 10 samples, got %+v", got)
+	}
+
+	// Nothing is observed while the clock moves on, so eviction is lazy:
+	// the samples are still held but no longer counted.
+	clock.Advance(2 * time.Minute)
+	if got := w.Stats(); got.Count != 0 {
+		t.Errorf("expected an empty window, got %+v", got)
+	}
+	if w.Len() == 0 {
+		t.Error("expected expired samples to be held until the next Observe")
+	}
+	w.Observe(1, time.Time{})
+	if w.Len() != 1 {
+		t.Errorf("expected Observe to evict, %d samples held", w.Len())
+	}
+}
+
+func TestRollingWindow_EvictsByCount(t *testing.T) {
+	w := NewRollingWindow(WithWindowSize(5))
+	now := time.Now()
+	for i := 1; i <= 1000; i++ {
+		w.Observe(float64(i), now)
+	}
+	stats := w.Stats()
+	if stats.Count != 5 || stats.Mean != 998 || stats.Max != 1000 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if got := w.Values(0); len(got) != 5 || got[0] != 996 {
+		t.Errorf("expected the newest samples oldest first, got %v", got)
+	}
+}
+
+func TestRollingWindow_ConcurrentUse(t *testing.T) {
+	w := NewRollingWindow(WithWindowAge(time.Second), WithWindowSize(100))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				w.Observe(float64(i), time.Time{})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if s := w.Stats(); s.Count > 100 {
+					t.Errorf("window exceeded its size: %d", s.Count)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestEWMA(t *testing.T) {
+	e := NewEWMA(0.5)
+	if e.Value() != 0 {
+		t.Errorf("expected 0 before any observation, got %v", e.Value())
+	}
+	for _, step := range []struct{ in, want float64 }{{10, 10}, {20, 15}, {20, 17.5}, {0, 8.75}} {
+		if got := e.Observe(step.in); got != step.want {
+			t.Errorf("Observe(%v) = %v, want %v", step.in, got, step.want)
+		}
+	}
+	e.Reset()
+	if got := e.Observe(3); got != 3 {
+		t.Errorf("expected the first observation after Reset to seed, got %v", got)
+	}
+
+	if got := NewEWMA(7).Observe(1); got != 1 {
+		t.Errorf("expected alpha to be clamped to 1, got %v", got)
+	}
+}
+
+func TestPortCheckerStats_RecentLatency(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	pc := NewPortChecker(nil, PortCheckerConfig{}, WithPortCheckerClock(clock))
+	stats := pc.GetStats()
+
+	for i := 0; i < 10; i++ {
+		stats.Record(&ConnectionResult{Latency: 100 * time.Millisecond})
+	}
+	clock.Advance(5 * time.Minute)
+	for i := 0; i < 4; i++ {
+		stats.Record(&ConnectionResult{Latency: 10 * time.Millisecond})
+	}
+
+	recent := stats.RecentLatency(time.Minute)
+	if recent.Count != 4 || recent.Max != 10*time.Millisecond {
+		t.Errorf("expected only the last 4 checks, got %+v", recent)
+	}
+	if all := stats.RecentLatency(time.Hour); all.Count != 14 {
+		t.Errorf("expected all 14 checks within the horizon, got %+v", all)
+	}
+	if total := stats.LatencyStats(); total.Count != 14 {
+		t.Errorf("expected LatencyStats to be unaffected, got %+v", total)
+	}
+
+	stats.Reset()
+	if recent := stats.RecentLatency(time.Minute); recent.Count != 0 {
+		t.Errorf("expected Reset to clear recent latencies, got %+v", recent)
+	}
+}
@@ -0,0 +1,379 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// ScenarioRunner – declarative chaos/golden-path scenarios
+// ------------------------------------------------------------------------
+
+// ScenarioContext is passed to every step. Logger writes into the step's
+// captured log.
+type ScenarioContext struct {
+	Registry *ComponentRegistry
+	Modes    ModeManager
+	Events   *EventBus
+	Clock    Clock
+	Logger   *TestLogger
+	// Values lets steps hand data to later steps.
+	Values map[string]any
+}
+
+// Component returns the named component or an error if it is not registered.
+func (sc *ScenarioContext) Component(name string) (Component, error) {
+	if sc.Registry == nil {
+		return nil, errors.New("scenario has no component registry")
+	}
+	c, ok := sc.Registry.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("component %q not registered", name)
+	}
+	return c, nil
+}
+
+// ScenarioStep is one declarative step. Build steps with the Step*
+// constructors and adjust them with the With*/Expect* modifiers.
+type ScenarioStep struct {
+	Name              string
+	Action            func(ctx context.Context, sc *ScenarioContext) error
+	Retry             *RetryConfig
+	ContinueOnFailure bool
+	// ExpectErr, when set, inverts the outcome: the step passes only if the
+	// action fails with an error whose message contains ExpectErr.
+	ExpectErr string
+}
+
+// WithRetry retries the step according to cfg (Attempts and backoff).
+func (s ScenarioStep) WithRetry(cfg RetryConfig) ScenarioStep {
+	s.Retry = &cfg
+	return s
+}
+
+// AllowFailure lets the scenario continue after this step fails.
+func (s ScenarioStep) AllowFailure() ScenarioStep {
+	s.ContinueOnFailure = true
+	return s
+}
+
+// ExpectError makes the step pass only when its action fails with an error
+// containing substr.
+func (s ScenarioStep) ExpectError(substr string) ScenarioStep {
+	s.ExpectErr = substr
+	return s
+}
+
+// Named overrides the step name shown in the report.
+func (s ScenarioStep) Named(name string) ScenarioStep {
+	s.Name = name
+	return s
+}
+
+// StepFunc wraps an arbitrary action.
+func StepFunc(name string, fn func(ctx context.Context, sc *ScenarioContext) error) ScenarioStep {
+	return ScenarioStep{Name: name, Action: fn}
+}
+
+// StepSetMode switches the scenario's ModeManager to mode.
+func StepSetMode(mode Mode) ScenarioStep {
+	return StepFunc("set mode "+string(mode), func(_ context.Context, sc *ScenarioContext) error {
+		if sc.Modes == nil {
+			return errors.New("scenario has no mode manager")
+		}
+		sc.Modes.SetMode(mode)
+		return nil
+	})
+}
+
+// StepCallComponent invokes Start, Stop, Status, Health or Stats on the
+// named component and fails if the call returns an error.
+func StepCallComponent(name, method string) ScenarioStep {
+	return StepFunc(fmt.Sprintf("call %s.%s", name, method), func(_ context.Context, sc *ScenarioContext) error {
+		c, err := sc.Component(name)
+		if err != nil {
+			return err
+		}
+		switch method {
+		case "Start":
+			return c.Start()
+		case "Stop":
+			return c.Stop()
+		case "Status":
+			status, err := c.Status()
+			sc.Logger.Debug("component status", map[string]any{"component": name, "status": status})
+			return err
+		case "Health":
+			_, err := c.Health()
+			return err
+		case "Stats":
+			_, err := c.Stats()
+			return err
+		default:
+			return fmt.Errorf("unknown component method %q", method)
+		}
+	})
+}
+
+// StepAssertHealth fails unless the named component reports the wanted
+// health without error.
+func StepAssertHealth(name string, healthy bool) ScenarioStep {
+	return StepFunc(fmt.Sprintf("assert %s healthy=%v", name, healthy), func(_ context.Context, sc *ScenarioContext) error {
+		c, err := sc.Component(name)
+		if err != nil {
+			return err
+		}
+		ok, err := c.Health()
+		if err != nil {
+			return fmt.Errorf("health check of %s failed: %w", name, err)
+		}
+		if ok != healthy {
+			return fmt.Errorf("expected %s healthy=%v, got %v", name, healthy, ok)
+		}
+		return nil
+	})
+}
+
+// StepWaitForEvent waits until an event of the given type has been
+// published on the scenario's bus.
+func StepWaitForEvent(eventType string, timeout time.Duration) ScenarioStep {
+	return StepFunc("wait for event "+eventType, func(ctx context.Context, sc *ScenarioContext) error {
+		if sc.Events == nil {
+			return errors.New("scenario has no event bus")
+		}
+		sub := sc.Events.Subscribe(eventType)
+		defer sc.Events.Unsubscribe(sub)
+		if _, ok := sc.Events.find(eventType); ok {
+			return nil
+		}
+		select {
+		case _, ok := <-sub:
+			if !ok {
+				return fmt.Errorf("event bus closed while waiting for %q", eventType)
+			}
+			return nil
+		case <-afterWith(sc.Clock, timeout):
+			return fmt.Errorf("timed out after %v waiting for event %q", timeout, eventType)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// StepSleep waits d on the scenario clock.
+func StepSleep(d time.Duration) ScenarioStep {
+	return StepFunc(fmt.Sprintf("sleep %v", d), func(ctx context.Context, sc *ScenarioContext) error {
+		select {
+		case <-afterWith(sc.Clock, d):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// StepAdvance moves the scenario's FakeClock forward by d. It fails when the
+// runner uses a real clock.
+func StepAdvance(d time.Duration) ScenarioStep {
+	return StepFunc(fmt.Sprintf("advance clock %v", d), func(_ context.Context, sc *ScenarioContext) error {
+		fc, ok := sc.Clock.(*FakeClock)
+		if !ok {
+			return errors.New("StepAdvance requires a FakeClock")
+		}
+		fc.Advance(d)
+		return nil
+	})
+}
+
+// StepOutcome is the result of a step.
+type StepOutcome string
+
+const (
+	StepPassed  StepOutcome = "passed"
+	StepFailed  StepOutcome = "failed"
+	StepSkipped StepOutcome = "skipped"
+)
+
+// StepResult records how one step went.
+type StepResult struct {
+	Name     string        `json:"name"`
+	Outcome  StepOutcome   `json:"outcome"`
+	Attempts int           `json:"attempts"`
+	Duration time.Duration `json:"duration_ns"`
+	Error    string        `json:"error,omitempty"`
+	Logs     string        `json:"logs,omitempty"`
+}
+
+// ScenarioReport summarises a scenario run.
+type ScenarioReport struct {
+	Scenario  string        `json:"scenario"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration_ns"`
+	Passed    bool          `json:"passed"`
+	Steps     []StepResult  `json:"steps"`
+	// Snapshots holds the state after each executed step when the runner
+	// checkpoints; see ScenarioRunner.ResumeFrom.
+	Snapshots []ScenarioSnapshot `json:"snapshots,omitempty"`
+}
+
+// Failed returns the steps that failed.
+func (r *ScenarioReport) Failed() []StepResult {
+	var out []StepResult
+	for _, s := range r.Steps {
+		if s.Outcome == StepFailed {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// JSON encodes the report with indentation.
+func (r *ScenarioReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// WriteFile writes the report as JSON so CI can archive it.
+func (r *ScenarioReport) WriteFile(path string) error {
+	data, err := r.JSON()
+	if err != nil {
+		return fmt.Errorf("failed to marshal scenario report: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ScenarioRunner executes steps in order against a registry and mode manager.
+type ScenarioRunner struct {
+	Name     string
+	Registry *ComponentRegistry
+	Modes    ModeManager
+	Events   *EventBus
+	Clock    Clock
+	// LogOutput additionally receives every step's log lines (optional).
+	LogOutput io.Writer
+	// Checkpoint snapshots the registry's InMemoryComponents, the mode
+	// and the step values after every executed step into the report.
+	Checkpoint bool
+
+	steps []ScenarioStep
+}
+
+// NewScenarioRunner creates a runner using the real clock.
+func NewScenarioRunner(name string, registry *ComponentRegistry, modes ModeManager) *ScenarioRunner {
+	return &ScenarioRunner{Name: name, Registry: registry, Modes: modes, Clock: RealClock{}}
+}
+
+// AddSteps appends steps to the scenario.
+func (r *ScenarioRunner) AddSteps(steps ...ScenarioStep) *ScenarioRunner {
+	r.steps = append(r.steps, steps...)
+	return r
+}
+
+// Run executes the scenario. A failed step stops the run unless it allows
+// failure; remaining steps are reported as skipped.
+func (r *ScenarioRunner) Run(ctx context.Context) *ScenarioReport {
+	return r.run(ctx, 0, make(map[string]any))
+}
+
+// run executes the steps from index from on, reporting earlier ones as
+// skipped.
+func (r *ScenarioRunner) run(ctx context.Context, from int, values map[string]any) *ScenarioReport {
+	clock := r.Clock
+	if clock == nil {
+		clock = RealClock{}
+	}
+	report := &ScenarioReport{Scenario: r.Name, StartedAt: clock.Now(), Passed: true}
+
+	aborted := false
+	for i, step := range r.steps {
+		if i < from || aborted || ctx.Err() != nil {
+			report.Steps = append(report.Steps, StepResult{Name: step.Name, Outcome: StepSkipped})
+			continue
+		}
+
+		var logBuf bytes.Buffer
+		var out io.Writer = &logBuf
+		if r.LogOutput != nil {
+			out = io.MultiWriter(&logBuf, r.LogOutput)
+		}
+		sc := &ScenarioContext{
+			Registry: r.Registry,
+			Modes:    r.Modes,
+			Events:   r.Events,
+			Clock:    clock,
+			Logger:   NewTestLogger(r.Name+"/"+step.Name, out, WithLevel(DEBUG)),
+			Values:   values,
+		}
+
+		start := clock.Now()
+		attempts, err := r.runStep(ctx, step, sc)
+		result := StepResult{
+			Name:     step.Name,
+			Outcome:  StepPassed,
+			Attempts: attempts,
+			Duration: clock.Now().Sub(start),
+		}
+		if err != nil {
+			result.Outcome = StepFailed
+			result.Error = err.Error()
+			sc.Logger.Error("step failed", map[string]any{"error": err.Error(), "attempts": attempts})
+			report.Passed = false
+			if !step.ContinueOnFailure {
+				aborted = true
+			}
+		}
+		result.Logs = logBuf.String()
+		report.Steps = append(report.Steps, result)
+		if r.Checkpoint {
+			report.Snapshots = append(report.Snapshots, r.snapshot(i+1, step.Name, values))
+		}
+	}
+
+	report.Duration = clock.Now().Sub(report.StartedAt)
+	return report
+}
+
+// runStep runs one step with its retry policy and returns the attempt count.
+func (r *ScenarioRunner) runStep(ctx context.Context, step ScenarioStep, sc *ScenarioContext) (int, error) {
+	attempts := 1
+	if step.Retry != nil && step.Retry.Attempts > 1 {
+		attempts = step.Retry.Attempts
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			sc.Logger.Debug("retrying step", map[string]any{"attempt": attempt, "error": err.Error()})
+			select {
+			case <-afterWith(sc.Clock, step.Retry.Backoff(attempt-1)):
+			case <-ctx.Done():
+				return attempt - 1, ctx.Err()
+			}
+		}
+		err = checkExpectation(step, step.Action(ctx, sc))
+		if err == nil {
+			return attempt, nil
+		}
+	}
+	return attempts, err
+}
+
+// checkExpectation applies ExpectErr to the action's result.
+func checkExpectation(step ScenarioStep, err error) error {
+	if step.ExpectErr == "" {
+		return err
+	}
+	if err == nil {
+		return fmt.Errorf("expected error containing %q, got none", step.ExpectErr)
+	}
+	if !strings.Contains(err.Error(), step.ExpectErr) {
+		return fmt.Errorf("expected error containing %q, got: %w", step.ExpectErr, err)
+	}
+	return nil
+}
@@ -0,0 +1,599 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------------------------------------------------
+// Scenario files – ScenarioRunner steps authored in YAML or JSON
+// ------------------------------------------------------------------------
+
+// ScenarioFile is a scenario document. It lets scenarios be written without
+// Go:
+//
+//	name: readonly-failover
+//	steps:
+//	  - type: component_action
+//	    target: storage
+//	    params: {action: start}
+//	  - type: set_mode
+//	    params: {mode: readonly}
+//	  - type: http_request
+//	    target: ${API_URL}/orders
+//	    params: {method: POST, body: {sku: A1}}
+//	    expect: {status: 503}
+//
+// Targets have environment variables expanded when the steps are built, so
+// one file can run against whichever address a test allocated.
+type ScenarioFile struct {
+	Name        string             `json:"name" yaml:"name"`
+	Description string             `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []ScenarioFileStep `json:"steps" yaml:"steps"`
+}
+
+// ScenarioFileStep is one step of a ScenarioFile. Type selects the step
+// from the library (see ScenarioStepTypes); Target and Params are
+// interpreted by that type.
+type ScenarioFileStep struct {
+	Type   string         `json:"type" yaml:"type"`
+	Name   string         `json:"name,omitempty" yaml:"name,omitempty"`
+	Target string         `json:"target,omitempty" yaml:"target,omitempty"`
+	Params map[string]any `json:"params,omitempty" yaml:"params,omitempty"`
+	// Timeout bounds the step's action; wait_port waits this long for the
+	// port (30s when unset).
+	Timeout      time.Duration   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Expect       *ScenarioExpect `json:"expect,omitempty" yaml:"expect,omitempty"`
+	AllowFailure bool            `json:"allow_failure,omitempty" yaml:"allow_failure,omitempty"`
+}
+
+// MarshalJSON writes Timeout as a duration string, the form scenario files
+// use.
+func (s ScenarioFileStep) MarshalJSON() ([]byte, error) {
+	type plain ScenarioFileStep
+	out := struct {
+		plain
+		Timeout string `json:"timeout,omitempty"`
+	}{plain: plain(s)}
+	if s.Timeout != 0 {
+		out.Timeout = s.Timeout.String()
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads Timeout as a duration string.
+func (s *ScenarioFileStep) UnmarshalJSON(data []byte) error {
+	type plain ScenarioFileStep
+	in := struct {
+		*plain
+		Timeout string `json:"timeout,omitempty"`
+	}{plain: (*plain)(s)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	s.Timeout = 0
+	if in.Timeout != "" {
+		d, err := time.ParseDuration(in.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout %q: %w", in.Timeout, err)
+		}
+		s.Timeout = d
+	}
+	return nil
+}
+
+// ScenarioExpect holds a step's expectations. Status applies to
+// http_request (any 2xx when unset), Healthy to component_action with the
+// health action, and Error to every type, as ScenarioStep.ExpectError.
+type ScenarioExpect struct {
+	Status  int    `json:"status,omitempty" yaml:"status,omitempty"`
+	Healthy *bool  `json:"healthy,omitempty" yaml:"healthy,omitempty"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// defaultScenarioWaitTimeout is how long wait_port waits without a Timeout.
+const defaultScenarioWaitTimeout = 30 * time.Second
+
+// scenarioStepType is an entry in the step library.
+type scenarioStepType struct {
+	description string
+	target      string // what Target means; "" when the type takes none
+	params      *jsonSchema
+	build       func(s ScenarioFileStep) (ScenarioStep, error)
+}
+
+var componentActions = map[string]string{
+	"start":  "Start",
+	"stop":   "Stop",
+	"status": "Status",
+	"health": "Health",
+	"stats":  "Stats",
+}
+
+var scenarioStepTypes = map[string]scenarioStepType{
+	"set_mode": {
+		description: "switch the scenario's mode manager",
+		params: paramsSchema([]string{"mode"}, map[string]*jsonSchema{
+			"mode": {Type: "string", Enum: []any{
+				string(ModeNormal), string(ModeDegraded), string(ModeReadOnly),
+				string(ModeOffline), string(ModeFlaky), string(ModeMaintenance),
+			}},
+		}),
+		build: func(s ScenarioFileStep) (ScenarioStep, error) {
+			return StepSetMode(Mode(paramString(s.Params, "mode"))), nil
+		},
+	},
+	"wait_port": {
+		description: "wait until a TCP port accepts connections",
+		target:      "host:port",
+		params: paramsSchema(nil, map[string]*jsonSchema{
+			"interval": schemaFor(durationType, ""),
+		}),
+		build: buildWaitPortStep,
+	},
+	"http_request": {
+		description: "send an HTTP request and check the response status",
+		target:      "URL",
+		params: paramsSchema(nil, map[string]*jsonSchema{
+			"method":  {Type: "string"},
+			"headers": {Type: "object", AdditionalProperties: &jsonSchema{Type: "string"}},
+			"body":    {},
+		}),
+		build: buildHTTPRequestStep,
+	},
+	"component_action": {
+		description: "call start, stop, status, health or stats on a component",
+		target:      "component name",
+		params: paramsSchema([]string{"action"}, map[string]*jsonSchema{
+			"action": {Type: "string", Enum: []any{"start", "stop", "status", "health", "stats"}},
+		}),
+		build: func(s ScenarioFileStep) (ScenarioStep, error) {
+			action := componentActions[paramString(s.Params, "action")]
+			if s.Expect != nil && s.Expect.Healthy != nil {
+				if action != "Health" {
+					return ScenarioStep{}, errors.New("expect.healthy requires the health action")
+				}
+				return StepAssertHealth(s.Target, *s.Expect.Healthy), nil
+			}
+			return StepCallComponent(s.Target, action), nil
+		},
+	},
+	"sleep": {
+		description: "wait on the scenario clock",
+		params: paramsSchema([]string{"duration"}, map[string]*jsonSchema{
+			"duration": schemaFor(durationType, ""),
+		}),
+		build: func(s ScenarioFileStep) (ScenarioStep, error) {
+			d, err := paramDuration(s.Params, "duration", 0)
+			if err != nil {
+				return ScenarioStep{}, err
+			}
+			return StepSleep(d), nil
+		},
+	},
+}
+
+func init() {
+	for name, st := range scenarioStepTypes {
+		if err := st.params.prepare("$.params"); err != nil {
+			panic(fmt.Sprintf("step type %s: %v", name, err))
+		}
+	}
+}
+
+// ScenarioStepTypes returns the names of the step types scenario files can
+// use, sorted.
+func ScenarioStepTypes() []string {
+	names := make([]string, 0, len(scenarioStepTypes))
+	for name := range scenarioStepTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func paramsSchema(required []string, props map[string]*jsonSchema) *jsonSchema {
+	return &jsonSchema{Type: "object", Properties: props, Required: required, AdditionalProperties: false}
+}
+
+// scenarioSchema builds the document schema from the ScenarioFile structs.
+// Step types are left open so unknown ones get a suggestion from
+// validateScenarioStep rather than a bare enum violation.
+func scenarioSchema() *jsonSchema {
+	root := schemaFor(reflect.TypeOf(ScenarioFile{}), "")
+	root.Required = []string{"steps"}
+	step := root.Properties["steps"].Items
+	step.Required = []string{"type"}
+	step.Properties["params"].Type = []string{"object", "null"}
+	step.Properties["timeout"] = &jsonSchema{Type: "string", Pattern: durationPattern, Description: `a duration string such as "1.5s"`}
+	expect := schemaFor(reflect.TypeOf(ScenarioExpect{}), "")
+	expect.Properties["healthy"].Type = "boolean"
+	step.Properties["expect"] = expect
+	return root
+}
+
+// ScenarioSchema returns a draft-07 JSON Schema for scenario files, for
+// editors and CI linting. Per-type params are described but not enforced
+// by the schema; LoadScenario checks them.
+func ScenarioSchema() ([]byte, error) {
+	root := scenarioSchema()
+	root.Schema = configSchemaURI
+	root.Title = "testutils scenario"
+	step := root.Properties["steps"].Items
+	var docs []string
+	for _, name := range ScenarioStepTypes() {
+		st := scenarioStepTypes[name]
+		step.Properties["type"].Enum = append(step.Properties["type"].Enum, name)
+		doc := name + ": " + st.description
+		if st.target != "" {
+			doc += "; target is the " + st.target
+		}
+		if len(st.params.Required) > 0 {
+			doc += "; requires params " + strings.Join(st.params.Required, ", ")
+		}
+		docs = append(docs, doc)
+	}
+	step.Properties["type"].Description = strings.Join(docs, "\n")
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scenario schema: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+// LoadScenario reads and validates a scenario file. YAML and JSON are both
+// accepted. Problems are returned together as a *ValidationReport whose
+// messages carry the file and line, e.g.
+//
+//	chaos.yaml:14: steps[2].type: unknown step type "wait_prot" (did you mean "wait_port"?)
+func LoadScenario(path string) (*ScenarioFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+	return parseScenario(filepath.Base(path), data)
+}
+
+// ParseScenario is LoadScenario for a document already in memory.
+func ParseScenario(data []byte) (*ScenarioFile, error) {
+	return parseScenario("scenario", data)
+}
+
+func parseScenario(source string, data []byte) (*ScenarioFile, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", source, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("scenario %s is empty", source)
+	}
+	doc, err := jsonValue(root.Content[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", source, err)
+	}
+
+	lines := make(map[string]int)
+	nodeLines(root.Content[0], "", lines)
+	report := &ValidationReport{}
+	add := func(path, msg string) {
+		report.addError(path, fmt.Sprintf("%s:%d: %s: %s", source, lineFor(lines, path), displayPath(path), msg))
+	}
+
+	for _, v := range (&ContractSchema{root: prepared(scenarioSchema())}).Validate(doc) {
+		path, msg, _ := strings.Cut(v, ": ")
+		add(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), msg)
+	}
+	if report.HasErrors() {
+		return nil, report
+	}
+
+	var file ScenarioFile
+	if err := root.Content[0].Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario %s: %w", source, err)
+	}
+	for i, s := range file.Steps {
+		for _, issue := range validateScenarioStep(s) {
+			path := fmt.Sprintf("steps[%d]", i)
+			if issue.path != "" {
+				path = joinFieldPath(path, issue.path)
+			}
+			add(path, issue.msg)
+		}
+	}
+	if report.HasErrors() {
+		return nil, report
+	}
+	return &file, nil
+}
+
+// prepared compiles s for validation.
+func prepared(s *jsonSchema) *jsonSchema {
+	if err := s.prepare("$"); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+type stepIssue struct {
+	path, msg string // path is relative to the step
+}
+
+// validateScenarioStep checks s against its type: the type exists, the
+// params match the type's schema, and the step builds.
+func validateScenarioStep(s ScenarioFileStep) []stepIssue {
+	st, ok := scenarioStepTypes[s.Type]
+	if !ok {
+		msg := fmt.Sprintf("unknown step type %q", s.Type)
+		if hint := closestKey(s.Type, ScenarioStepTypes()); hint != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", hint)
+		}
+		return []stepIssue{{"type", msg}}
+	}
+
+	var issues []stepIssue
+	switch {
+	case st.target != "" && s.Target == "":
+		issues = append(issues, stepIssue{"", fmt.Sprintf("%s requires a target (%s)", s.Type, st.target)})
+	case st.target == "" && s.Target != "":
+		issues = append(issues, stepIssue{"target", fmt.Sprintf("%s takes no target", s.Type)})
+	}
+	params := map[string]any{}
+	if s.Params != nil {
+		// Round-trip through JSON so numbers have the types the schema
+		// validator expects.
+		raw, _ := json.Marshal(s.Params)
+		_ = json.Unmarshal(raw, &params)
+	}
+	for _, v := range (&ContractSchema{root: st.params}).Validate(params) {
+		path, msg, _ := strings.Cut(v, ": ")
+		issues = append(issues, stepIssue{"params" + strings.TrimPrefix(path, "$"), msg})
+	}
+	if len(issues) > 0 {
+		return issues
+	}
+	if _, err := buildScenarioStep(s); err != nil {
+		issues = append(issues, stepIssue{"", err.Error()})
+	}
+	return issues
+}
+
+// ScenarioSteps builds the runner steps. It fails on the first step that
+// does not validate, which only happens for files built or modified in Go.
+func (f *ScenarioFile) ScenarioSteps() ([]ScenarioStep, error) {
+	steps := make([]ScenarioStep, 0, len(f.Steps))
+	for i, s := range f.Steps {
+		if issues := validateScenarioStep(s); len(issues) > 0 {
+			return nil, fmt.Errorf("step %d (%s): %s", i, s.Type, issues[0].msg)
+		}
+		step, err := buildScenarioStep(s)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i, s.Type, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Runner returns a ScenarioRunner named after the file with its steps
+// added.
+func (f *ScenarioFile) Runner(registry *ComponentRegistry, modes ModeManager) (*ScenarioRunner, error) {
+	steps, err := f.ScenarioSteps()
+	if err != nil {
+		return nil, err
+	}
+	return NewScenarioRunner(f.Name, registry, modes).AddSteps(steps...), nil
+}
+
+// buildScenarioStep builds s and applies the modifiers common to all
+// types.
+func buildScenarioStep(s ScenarioFileStep) (ScenarioStep, error) {
+	s.Target = os.ExpandEnv(s.Target)
+	step, err := scenarioStepTypes[s.Type].build(s)
+	if err != nil {
+		return ScenarioStep{}, err
+	}
+	if s.Timeout > 0 {
+		action, timeout := step.Action, s.Timeout
+		step.Action = func(ctx context.Context, sc *ScenarioContext) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return action(ctx, sc)
+		}
+	}
+	if s.Name != "" {
+		step = step.Named(s.Name)
+	}
+	if s.Expect != nil && s.Expect.Error != "" {
+		step = step.ExpectError(s.Expect.Error)
+	}
+	if s.AllowFailure {
+		step = step.AllowFailure()
+	}
+	return step, nil
+}
+
+func buildWaitPortStep(s ScenarioFileStep) (ScenarioStep, error) {
+	host, portStr, err := net.SplitHostPort(s.Target)
+	if err != nil {
+		return ScenarioStep{}, fmt.Errorf("invalid target %q: %w", s.Target, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return ScenarioStep{}, fmt.Errorf("invalid port in target %q", s.Target)
+	}
+	interval, err := paramDuration(s.Params, "interval", 100*time.Millisecond)
+	if err != nil {
+		return ScenarioStep{}, err
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultScenarioWaitTimeout
+	}
+	return StepFunc("wait for port "+s.Target, func(ctx context.Context, sc *ScenarioContext) error {
+		return WaitFor(ctx, "port "+s.Target, interval, func(context.Context) (bool, string, error) {
+			if IsPortOpen(host, port) {
+				return true, "", nil
+			}
+			return false, "closed", nil
+		}, WithWaitTimeout(timeout), WithWaitClock(sc.Clock))
+	}), nil
+}
+
+func buildHTTPRequestStep(s ScenarioFileStep) (ScenarioStep, error) {
+	method := strings.ToUpper(paramString(s.Params, "method"))
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body []byte
+	var contentType string
+	switch b := s.Params["body"].(type) {
+	case nil:
+	case string:
+		body = []byte(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return ScenarioStep{}, fmt.Errorf("invalid body: %w", err)
+		}
+		body, contentType = data, "application/json"
+	}
+	headers := http.Header{}
+	if h, ok := s.Params["headers"].(map[string]any); ok {
+		for k, v := range h {
+			headers.Set(k, fmt.Sprint(v))
+		}
+	}
+	if contentType != "" && headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", contentType)
+	}
+	wantStatus := 0
+	if s.Expect != nil {
+		wantStatus = s.Expect.Status
+	}
+
+	return StepFunc(method+" "+s.Target, func(ctx context.Context, sc *ScenarioContext) error {
+		req, err := http.NewRequestWithContext(ctx, method, s.Target, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to build request: %w", err)
+		}
+		req.Header = headers.Clone()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		sc.Logger.Debug("http response", map[string]any{"method": method, "url": s.Target, "status": resp.StatusCode})
+		switch {
+		case wantStatus != 0 && resp.StatusCode != wantStatus:
+			return fmt.Errorf("expected status %d, got %d: %s", wantStatus, resp.StatusCode, bytes.TrimSpace(snippet))
+		case wantStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299):
+			return fmt.Errorf("expected a 2xx status, got %d: %s", resp.StatusCode, bytes.TrimSpace(snippet))
+		}
+		return nil
+	}), nil
+}
+
+func paramString(params map[string]any, key string) string {
+	s, _ := params[key].(string)
+	return s
+}
+
+// paramDuration reads a duration param given as a string or nanoseconds.
+func paramDuration(params map[string]any, key string, def time.Duration) (time.Duration, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case string:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s %q: %w", key, v, err)
+		}
+		return d, nil
+	case int:
+		return time.Duration(v), nil
+	case float64:
+		return time.Duration(v), nil
+	default:
+		return 0, fmt.Errorf("invalid %s %v", key, v)
+	}
+}
+
+// jsonValue decodes n into the shapes encoding/json produces, so it can be
+// checked by a ContractSchema.
+func jsonValue(n *yaml.Node) (any, error) {
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// nodeLines records the line of every value in the document under its
+// path ("steps[1].params.mode"). Mapping values are recorded at their
+// key's line.
+func nodeLines(n *yaml.Node, path string, lines map[string]int) {
+	if _, ok := lines[path]; !ok {
+		lines[path] = n.Line
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			child := joinFieldPath(path, n.Content[i].Value)
+			lines[child] = n.Content[i].Line
+			nodeLines(n.Content[i+1], child, lines)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			nodeLines(item, fmt.Sprintf("%s[%d]", path, i), lines)
+		}
+	case yaml.AliasNode:
+		if n.Alias != nil {
+			nodeLines(n.Alias, path, lines)
+		}
+	}
+}
+
+// lineFor returns the line of path or of its nearest recorded ancestor.
+func lineFor(lines map[string]int, path string) int {
+	for {
+		if line, ok := lines[path]; ok {
+			return line
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return lines[""]
+		}
+		path = path[:i]
+	}
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "(document)"
+	}
+	return path
+}
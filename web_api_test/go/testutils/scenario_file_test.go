@@ -0,0 +1,195 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLoadScenario_ExampleRunsEndToEnd(t *testing.T) {
+	modes := NewInMemoryModeManager(ModeNormal)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusOK)
+		case modes.CurrentMode() == ModeReadOnly:
+			http.Error(w, "storage is read-only", http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("API_ADDR", strings.TrimPrefix(srv.URL, "http://"))
+
+	file, err := LoadScenario("testdata/scenarios/readonly_failover.yaml")
+	if err != nil {
+		t.Fatalf("LoadScenario: %v", err)
+	}
+	registry := NewComponentRegistry()
+	storage := NewInMemoryComponent("storage")
+	registry.MustRegister(storage)
+	runner, err := file.Runner(registry, modes)
+	if err != nil {
+		t.Fatalf("Runner: %v", err)
+	}
+
+	report := runner.Run(context.Background())
+	if !report.Passed {
+		data, _ := report.JSON()
+		t.Fatalf("scenario failed:\n%s", data)
+	}
+	if report.Scenario != "readonly-failover" || len(report.Steps) != len(file.Steps) {
+		t.Errorf("expected %d steps of readonly-failover, got %d of %q", len(file.Steps), len(report.Steps), report.Scenario)
+	}
+	if report.Steps[6].Name != "write is rejected" {
+		t.Errorf("expected step names from the file, got %q", report.Steps[6].Name)
+	}
+	if state, _ := storage.Status(); state != "stopped" {
+		t.Errorf("expected storage stopped by the last step, got %q", state)
+	}
+}
+
+func TestScenarioFile_RoundTrip(t *testing.T) {
+	healthy := true
+	want := &ScenarioFile{
+		Name: "round-trip",
+		Steps: []ScenarioFileStep{
+			{Type: "component_action", Target: "db", Params: map[string]any{"action": "health"}, Expect: &ScenarioExpect{Healthy: &healthy}},
+			{Type: "wait_port", Target: "localhost:5432", Timeout: 5 * time.Second, Params: map[string]any{"interval": "250ms"}},
+			{Type: "http_request", Name: "create", Target: "http://localhost/items",
+				Params: map[string]any{"method": "POST", "body": map[string]any{"qty": 2}},
+				Expect: &ScenarioExpect{Status: 201}, AllowFailure: true},
+			{Type: "set_mode", Params: map[string]any{"mode": "offline"}, Expect: &ScenarioExpect{Error: "denied"}},
+			{Type: "sleep", Params: map[string]any{"duration": "1s"}},
+		},
+	}
+
+	encoders := map[string]func(any) ([]byte, error){"yaml": yaml.Marshal, "json": json.Marshal}
+	for format, encode := range encoders {
+		t.Run(format, func(t *testing.T) {
+			data, err := encode(want)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			got, err := ParseScenario(data)
+			if err != nil {
+				t.Fatalf("ParseScenario:\n%s\n%v", data, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("round trip changed the scenario:\n got %+v\nwant %+v", got, want)
+			}
+			steps, err := got.ScenarioSteps()
+			if err != nil {
+				t.Fatalf("ScenarioSteps: %v", err)
+			}
+			if len(steps) != 5 || steps[2].Name != "create" || !steps[2].ContinueOnFailure || steps[3].ExpectErr != "denied" {
+				t.Errorf("modifiers not applied: %+v", steps)
+			}
+		})
+	}
+}
+
+func TestParseScenario_ReportsStepProblemsWithLines(t *testing.T) {
+	doc := `name: broken
+steps:
+  - type: set_mode
+    params:
+      mode: normal
+  - type: wait_prot
+    target: localhost:80
+  - type: set_mode
+    params:
+      mood: readonly
+  - type: component_action
+    params:
+      action: restart
+  - type: wait_port
+    target: localhost
+  - type: sleep
+    target: nowhere
+    params:
+      duration: 1s
+`
+	_, err := ParseScenario([]byte(doc))
+	var report *ValidationReport
+	if !errors.As(err, &report) {
+		t.Fatalf("expected a *ValidationReport, got %v", err)
+	}
+
+	want := []string{
+		`scenario:6: steps[1].type: unknown step type "wait_prot" (did you mean "wait_port"?)`,
+		`scenario:10: steps[2].params.mood: additional property not allowed`,
+		`scenario:9: steps[2].params: missing required property "mode"`,
+		`scenario:11: steps[3]: component_action requires a target (component name)`,
+		`scenario:13: steps[3].params.action: value restart is not one of [start stop status health stats]`,
+		`scenario:14: steps[4]: invalid target "localhost"`,
+		`scenario:17: steps[5].target: sleep takes no target`,
+	}
+	var got []string
+	for _, issue := range report.Errors() {
+		got = append(got, issue.Message)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d issues, got %d:\n%s", len(want), len(got), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("issue %d:\n got %s\nwant %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadScenario_ReportsSchemaViolations(t *testing.T) {
+	path := t.TempDir() + "/bad.json"
+	doc := `{
+  "name": "bad",
+  "steps": [
+    {"type": "sleep", "params": {"duration": "1s"}, "expct": {"status": 200}},
+    {"target": "x", "timeout": "soon"}
+  ]
+}`
+	if err := os.WriteFile(path, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadScenario(path)
+	if err == nil {
+		t.Fatal("expected schema violations")
+	}
+	for _, want := range []string{
+		`bad.json:4: steps[0].expct: additional property not allowed`,
+		`bad.json:5: steps[1]: missing required property "type"`,
+		`bad.json:5: steps[1].timeout: "soon" does not match pattern`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in:\n%v", want, err)
+		}
+	}
+}
+
+func TestScenarioSchema_ListsStepTypes(t *testing.T) {
+	data, err := ScenarioSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := ParseContractSchema(data)
+	if err != nil {
+		t.Fatalf("generated schema does not parse: %v", err)
+	}
+	if v := schema.ValidateJSON([]byte(`{"steps": [{"type": "reboot"}]}`)); len(v) != 1 || !strings.Contains(v[0], "reboot") {
+		t.Errorf("expected the schema to reject an unknown step type, got %v", v)
+	}
+	for _, name := range ScenarioStepTypes() {
+		if !strings.Contains(string(data), `"`+name+`"`) {
+			t.Errorf("schema does not list step type %q", name)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ------------------------------------------------------------------------
+// Snapshots – checkpointing component and mode state for scenario bisection
+// ------------------------------------------------------------------------
+
+// ComponentSnapshot is the restorable state of an InMemoryComponent.
+// Transition history, rejected transitions and programmed errors are not
+// part of it.
+type ComponentSnapshot struct {
+	Name    string         `json:"name"`
+	State   string         `json:"state"`
+	Healthy bool           `json:"healthy"`
+	Stats   map[string]any `json:"stats,omitempty"`
+}
+
+// Snapshot captures the component's state, health and a deep copy of its
+// stats.
+func (c *InMemoryComponent) Snapshot() ComponentSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ComponentSnapshot{
+		Name:    c.name,
+		State:   c.state,
+		Healthy: c.healthOK,
+		Stats:   deepCopyMap(c.stats),
+	}
+}
+
+// Restore puts the component back into snap's state. It is not a
+// transition: strict mode does not validate it and TransitionHistory does
+// not record it.
+func (c *InMemoryComponent) Restore(snap ComponentSnapshot) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if snap.Name != c.name {
+		return fmt.Errorf("snapshot of component %q cannot restore %q", snap.Name, c.name)
+	}
+	c.state = snap.State
+	c.healthOK = snap.Healthy
+	c.stats = deepCopyMap(snap.Stats)
+	if c.stats == nil {
+		c.stats = make(map[string]interface{})
+	}
+	return nil
+}
+
+// ModeSnapshot is the restorable state of an InMemoryModeManager.
+type ModeSnapshot struct {
+	Mode Mode `json:"mode"`
+}
+
+// Snapshot captures the current mode.
+func (m *InMemoryModeManager) Snapshot() ModeSnapshot {
+	return ModeSnapshot{Mode: m.CurrentMode()}
+}
+
+// Restore sets the mode from snap. Watchers are notified once, and only if
+// the mode actually changes; no mode_changed event is published, since
+// restoring is not a change the scenario made.
+func (m *InMemoryModeManager) Restore(snap ModeSnapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed || m.mode == snap.Mode {
+		return
+	}
+	m.mode = snap.Mode
+	for _, ch := range m.watchers {
+		select {
+		case ch <- snap.Mode:
+		default:
+		}
+	}
+}
+
+// snapshotComponent and snapshotModes are the snapshot halves of
+// InMemoryComponent and InMemoryModeManager, so wrappers can take part.
+type snapshotComponent interface {
+	Snapshot() ComponentSnapshot
+	Restore(ComponentSnapshot) error
+}
+
+type snapshotModes interface {
+	Snapshot() ModeSnapshot
+	Restore(ModeSnapshot)
+}
+
+// ScenarioSnapshot is the state of a scenario after a step: every
+// snapshottable component in the registry, the mode and the step values.
+// Values that went through JSON come back as JSON types (float64 for
+// numbers, map[string]any for objects).
+type ScenarioSnapshot struct {
+	Step       int                          `json:"step"` // 1-based step just completed
+	StepName   string                       `json:"step_name"`
+	Components map[string]ComponentSnapshot `json:"components,omitempty"`
+	Mode       *ModeSnapshot                `json:"mode,omitempty"`
+	Values     map[string]any               `json:"values,omitempty"`
+}
+
+// snapshot captures the runner's state after step. Components that cannot
+// be snapshotted are left out.
+func (r *ScenarioRunner) snapshot(step int, name string, values map[string]any) ScenarioSnapshot {
+	snap := ScenarioSnapshot{Step: step, StepName: name, Values: deepCopyMap(values)}
+	if r.Registry != nil {
+		for _, c := range r.Registry.All() {
+			if s, ok := c.(snapshotComponent); ok {
+				if snap.Components == nil {
+					snap.Components = make(map[string]ComponentSnapshot)
+				}
+				snap.Components[c.Name()] = s.Snapshot()
+			}
+		}
+	}
+	if m, ok := r.Modes.(snapshotModes); ok {
+		ms := m.Snapshot()
+		snap.Mode = &ms
+	}
+	return snap
+}
+
+// restore applies snap to the runner's registry and mode manager.
+func (r *ScenarioRunner) restore(snap ScenarioSnapshot) error {
+	names := make([]string, 0, len(snap.Components))
+	for name := range snap.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if r.Registry == nil {
+			return fmt.Errorf("snapshot has component %q but scenario has no component registry", name)
+		}
+		c, ok := r.Registry.Get(name)
+		if !ok {
+			return fmt.Errorf("snapshot component %q not registered", name)
+		}
+		s, ok := c.(snapshotComponent)
+		if !ok {
+			return fmt.Errorf("component %q does not support Restore", name)
+		}
+		if err := s.Restore(snap.Components[name]); err != nil {
+			return err
+		}
+	}
+	if snap.Mode != nil {
+		m, ok := r.Modes.(snapshotModes)
+		if !ok {
+			return fmt.Errorf("snapshot has mode %q but the mode manager does not support Restore", snap.Mode.Mode)
+		}
+		m.Restore(*snap.Mode)
+	}
+	return nil
+}
+
+// ResumeFrom restores the snapshot taken after step-1 and runs the
+// scenario from step (1-based), so a failure at step 37 can be bisected
+// by rerunning from step 30 with the snapshots of the failed run. Earlier
+// steps are reported as skipped.
+//
+//	snaps, _ := LoadScenarioSnapshots("artifacts/snapshots.json")
+//	report, err := runner.ResumeFrom(ctx, 30, snaps)
+func (r *ScenarioRunner) ResumeFrom(ctx context.Context, step int, snapshots []ScenarioSnapshot) (*ScenarioReport, error) {
+	if step < 1 || step > len(r.steps) {
+		return nil, fmt.Errorf("cannot resume from step %d of %d", step, len(r.steps))
+	}
+	values := make(map[string]any)
+	if step > 1 {
+		var snap *ScenarioSnapshot
+		for i := range snapshots {
+			if snapshots[i].Step == step-1 {
+				snap = &snapshots[i]
+			}
+		}
+		if snap == nil {
+			return nil, fmt.Errorf("no snapshot taken after step %d", step-1)
+		}
+		if err := r.restore(*snap); err != nil {
+			return nil, fmt.Errorf("failed to restore snapshot of step %d: %w", step-1, err)
+		}
+		values = deepCopyMap(snap.Values)
+		if values == nil {
+			values = make(map[string]any)
+		}
+	}
+	return r.run(ctx, step-1, values), nil
+}
+
+// LoadScenarioSnapshots reads snapshots written as JSON, for instance by
+// TestDataManager.CreateJSONFile(name, report.Snapshots).
+func LoadScenarioSnapshots(path string) ([]ScenarioSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario snapshots: %w", err)
+	}
+	var snaps []ScenarioSnapshot
+	if err := json.Unmarshal(data, &snaps); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario snapshots %q: %w", path, err)
+	}
+	return snaps, nil
+}
+
+// deepCopyMap copies m, recursing into nested maps and slices so the copy
+// shares no mutable containers with m. Other values are copied as is.
+func deepCopyMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = deepCopyValue(v)
+	}
+	return out
+}
+
+func deepCopyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return deepCopyMap(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = deepCopyValue(e)
+		}
+		return out
+	case []string:
+		return append([]string(nil), v...)
+	case []int:
+		return append([]int(nil), v...)
+	case []byte:
+		return append([]byte(nil), v...)
+	default:
+		return v
+	}
+}
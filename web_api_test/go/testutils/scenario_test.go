@@ -0,0 +1,215 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScenarioRunner_ReadOnlyMidWrite(t *testing.T) {
+	modes := NewInMemoryModeManager(ModeNormal)
+	disk := NewModeAwareDisk(NewInMemoryDisk(), modes)
+
+	registry := NewComponentRegistry()
+	registry.MustRegister(NewInMemoryComponent("storage"))
+
+	var file File
+	runner := NewScenarioRunner("readonly-mid-write", registry, modes).AddSteps(
+		StepCallComponent("storage", "Start"),
+		StepAssertHealth("storage", true),
+		StepFunc("open file", func(_ context.Context, sc *ScenarioContext) error {
+			f, err := disk.Create("data.bin")
+			file = f
+			return err
+		}),
+		StepFunc("first write", func(_ context.Context, sc *ScenarioContext) error {
+			_, err := file.Write([]byte("part one;"))
+			return err
+		}),
+		StepSetMode(ModeReadOnly),
+		StepFunc("second write", func(_ context.Context, sc *ScenarioContext) error {
+			_, err := file.Write([]byte("part two;"))
+			return err
+		}).ExpectError("write denied"),
+	)
+
+	report := runner.Run(context.Background())
+
+	if !report.Passed {
+		data, _ := report.JSON()
+		t.Fatalf("scenario failed:\n%s", data)
+	}
+	if len(report.Steps) != 6 {
+		t.Fatalf("expected 6 step results, got %d", len(report.Steps))
+	}
+	for _, step := range report.Steps {
+		if step.Outcome != StepPassed {
+			t.Errorf("step %q: expected passed, got %s (%s)", step.Name, step.Outcome, step.Error)
+		}
+	}
+
+	data, err := report.JSON()
+	if err != nil {
+		t.Fatalf("report did not serialize: %v", err)
+	}
+	var decoded ScenarioReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report did not round-trip: %v", err)
+	}
+	if decoded.Scenario != "readonly-mid-write" {
+		t.Errorf("expected scenario name to round-trip, got %q", decoded.Scenario)
+	}
+}
+
+func TestScenarioRunner_StopsAfterFailure(t *testing.T) {
+	modes := NewInMemoryModeManager(ModeNormal)
+	registry := NewComponentRegistry()
+
+	report := NewScenarioRunner("abort", registry, modes).AddSteps(
+		StepCallComponent("missing", "Start").AllowFailure(),
+		StepCallComponent("missing", "Stop"),
+		StepSetMode(ModeOffline),
+	).Run(context.Background())
+
+	want := []StepOutcome{StepFailed, StepFailed, StepSkipped}
+	if len(report.Steps) != len(want) {
+		t.Fatalf("expected %d step results, got %d", len(want), len(report.Steps))
+	}
+	for i, w := range want {
+		if report.Steps[i].Outcome != w {
+			t.Errorf("step %d: expected %s, got %s", i, w, report.Steps[i].Outcome)
+		}
+	}
+	if modes.CurrentMode() != ModeNormal {
+		t.Errorf("skipped step changed the mode to %s", modes.CurrentMode())
+	}
+}
+
+func TestInMemoryComponent_SnapshotIsDeepCopy(t *testing.T) {
+	c := NewInMemoryComponent("cache")
+	c.SetStrict(true)
+	c.Start()
+	c.SetStat("shards", map[string]any{"a": 1})
+	snap := c.Snapshot()
+
+	c.Stats()
+	c.SetStat("shards", nil)
+	c.SetHealth(false)
+	c.Stop()
+	if shards := snap.Stats["shards"].(map[string]any); shards["a"] != 1 {
+		t.Fatalf("snapshot changed with the component: %+v", snap)
+	}
+
+	history := len(c.TransitionHistory())
+	if err := c.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := c.Status(); status != "running" {
+		t.Errorf("status after restore = %s", status)
+	}
+	if ok, _ := c.Health(); !ok {
+		t.Error("health not restored")
+	}
+	if len(c.TransitionHistory()) != history || len(c.TransitionErrors()) != 0 {
+		t.Error("restore was recorded as a transition")
+	}
+	if err := NewInMemoryComponent("db").Restore(snap); err == nil {
+		t.Error("restored a snapshot of another component")
+	}
+}
+
+func TestInMemoryModeManager_RestoreNotifiesOnlyOnChange(t *testing.T) {
+	bus := NewEventBus()
+	modes := NewInMemoryModeManager(ModeNormal)
+	modes.SetEventBus(bus)
+	watch := modes.Watch()
+	<-watch // current mode
+
+	modes.Restore(ModeSnapshot{Mode: ModeNormal})
+	modes.Restore(ModeSnapshot{Mode: ModeOffline})
+	if got := len(watch); got != 1 {
+		t.Fatalf("watcher received %d notifications, want 1", got)
+	}
+	if mode := <-watch; mode != ModeOffline || modes.CurrentMode() != ModeOffline {
+		t.Errorf("restored mode = %s", mode)
+	}
+	if _, ok := bus.find(EventModeChanged); ok {
+		t.Error("restore published a mode_changed event")
+	}
+}
+
+func TestScenarioRunner_ResumeFromSnapshot(t *testing.T) {
+	build := func(failAtEnd bool) (*ScenarioRunner, *InMemoryModeManager, *InMemoryComponent) {
+		modes := NewInMemoryModeManager(ModeNormal)
+		storage := NewInMemoryComponent("storage")
+		registry := NewComponentRegistry()
+		registry.MustRegister(storage)
+		runner := NewScenarioRunner("bisect", registry, modes).AddSteps(
+			StepCallComponent("storage", "Start"),
+			StepFunc("record writes", func(_ context.Context, sc *ScenarioContext) error {
+				sc.Values["writes"] = 3
+				storage.SetStat("writes", 3)
+				return nil
+			}),
+			StepSetMode(ModeDegraded),
+			StepFunc("verify", func(_ context.Context, sc *ScenarioContext) error {
+				if _, ok := sc.Values["writes"]; !ok {
+					return errors.New("writes value lost")
+				}
+				if sc.Modes.CurrentMode() != ModeDegraded {
+					return errors.New("mode lost")
+				}
+				if failAtEnd {
+					return errors.New("boom")
+				}
+				return nil
+			}),
+		)
+		runner.Checkpoint = true
+		return runner, modes, storage
+	}
+
+	runner, _, _ := build(true)
+	report := runner.Run(context.Background())
+	if report.Passed || len(report.Snapshots) != 4 {
+		t.Fatalf("passed=%v with %d snapshots", report.Passed, len(report.Snapshots))
+	}
+
+	// Round-trip through a file as a CI artifact would.
+	path := filepath.Join(t.TempDir(), "snapshots.json")
+	data, err := json.Marshal(report.Snapshots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	snaps, err := LoadScenarioSnapshots(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, modes, storage := build(false)
+	resumed, err := fresh.ResumeFrom(context.Background(), 4, snaps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed.Passed {
+		t.Fatalf("resumed run failed: %+v", resumed.Steps)
+	}
+	for i, want := range []StepOutcome{StepSkipped, StepSkipped, StepSkipped, StepPassed} {
+		if resumed.Steps[i].Outcome != want {
+			t.Errorf("step %d: %s, want %s", i+1, resumed.Steps[i].Outcome, want)
+		}
+	}
+	if status, _ := storage.Status(); status != "running" || modes.CurrentMode() != ModeDegraded {
+		t.Errorf("restored state: storage %s, mode %s", status, modes.CurrentMode())
+	}
+
+	if _, err := fresh.ResumeFrom(context.Background(), 4, snaps[:2]); err == nil {
+		t.Error("resumed without the snapshot of step 3")
+	}
+}
@@ -0,0 +1,228 @@
+package testutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ------------------------------------------------------------------------
+// JSON Schema validation
+// ------------------------------------------------------------------------
+
+// ContractSchema is a parsed JSON Schema that documents can be validated
+// against. It understands the draft-07 subset jsonSchema models plus
+// "required" and the OpenAPI-style "nullable": type (a name or a list of
+// names, "integer" and "null" included), enum, pattern, minimum,
+// exclusiveMinimum, maximum, items, properties and additionalProperties
+// (false, true or a schema). Other keywords are ignored.
+type ContractSchema struct {
+	root *jsonSchema
+}
+
+// ParseContractSchema parses a JSON Schema document.
+func ParseContractSchema(data []byte) (*ContractSchema, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON schema: %w", err)
+	}
+	if err := root.prepare("$"); err != nil {
+		return nil, err
+	}
+	return &ContractSchema{root: &root}, nil
+}
+
+// MustParseContractSchema is like ParseContractSchema but panics on error.
+func MustParseContractSchema(data string) *ContractSchema {
+	s, err := ParseContractSchema([]byte(data))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate checks v, a value decoded from JSON into any, against the
+// schema and returns one message per violation, sorted, each prefixed with
+// the JSONPath of the offending value. It returns nil when v conforms.
+func (s *ContractSchema) Validate(v any) []string {
+	var violations []string
+	s.root.validate(v, "$", &violations)
+	sort.Strings(violations)
+	return violations
+}
+
+// ValidateJSON is Validate for an encoded document.
+func (s *ContractSchema) ValidateJSON(data []byte) []string {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return []string{fmt.Sprintf("$: invalid JSON: %v", err)}
+	}
+	return s.Validate(v)
+}
+
+// prepare compiles patterns and resolves additionalProperties into a
+// schema, false, or nil for "anything goes".
+func (s *jsonSchema) prepare(path string) error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern %q: %w", path, s.Pattern, err)
+		}
+		s.re = re
+	}
+	switch ap := s.AdditionalProperties.(type) {
+	case bool:
+		if ap {
+			s.AdditionalProperties = nil
+		}
+	case map[string]any:
+		raw, _ := json.Marshal(ap)
+		var extra jsonSchema
+		if err := json.Unmarshal(raw, &extra); err != nil {
+			return fmt.Errorf("%s: invalid additionalProperties: %w", path, err)
+		}
+		s.AdditionalProperties = &extra
+	}
+	if extra, ok := s.AdditionalProperties.(*jsonSchema); ok {
+		if err := extra.prepare(path + ".*"); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		if err := s.Items.prepare(path + "[]"); err != nil {
+			return err
+		}
+	}
+	for name, prop := range s.Properties {
+		if err := prop.prepare(path + "." + name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// types returns the allowed type names, with "null" added for nullable.
+func (s *jsonSchema) types() []string {
+	var out []string
+	switch t := s.Type.(type) {
+	case string:
+		out = []string{t}
+	case []any:
+		for _, name := range t {
+			if str, ok := name.(string); ok {
+				out = append(out, str)
+			}
+		}
+	case []string:
+		out = append(out, t...)
+	}
+	if s.Nullable && len(out) > 0 {
+		out = append(out, "null")
+	}
+	return out
+}
+
+// jsonTypeOf names the JSON type of a decoded value.
+func jsonTypeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func typeAllowed(allowed []string, actual string) bool {
+	for _, t := range allowed {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *jsonSchema) validate(v any, path string, out *[]string) {
+	fail := func(format string, args ...any) {
+		*out = append(*out, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	actual := jsonTypeOf(v)
+	if allowed := s.types(); len(allowed) > 0 && !typeAllowed(allowed, actual) {
+		fail("expected %s, got %s", strings.Join(allowed, " or "), actual)
+		return
+	}
+	if v == nil {
+		return
+	}
+	if len(s.Enum) > 0 && !enumContains(s.Enum, v) {
+		fail("value %v is not one of %v", v, s.Enum)
+	}
+
+	switch v := v.(type) {
+	case string:
+		if s.re != nil && !s.re.MatchString(v) {
+			fail("%q does not match pattern %q", v, s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			fail("%v is less than the minimum %v", v, *s.Minimum)
+		}
+		if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+			fail("%v must be greater than %v", v, *s.ExclusiveMinimum)
+		}
+		if s.Maximum != nil && v > *s.Maximum {
+			fail("%v is greater than the maximum %v", v, *s.Maximum)
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), out)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			child := path + "." + name
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(v[name], child, out)
+				continue
+			}
+			switch extra := s.AdditionalProperties.(type) {
+			case bool: // false; true was cleared by prepare
+				*out = append(*out, child+": additional property not allowed")
+			case *jsonSchema:
+				extra.validate(v[name], child, out)
+			}
+		}
+	}
+}
+
+func enumContains(enum []any, v any) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
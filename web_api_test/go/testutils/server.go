@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -20,14 +20,17 @@ type ServerConfig struct {
 	Path    string            // Working directory
 	Command string            // Binary to execute
 	Args    []string          // Command arguments
-	EnvVars map[string]string // Custom environment variables (overrides system)
+	EnvVars map[string]string `sensitive:"true"` // Custom environment variables (overrides system); masked in reports
 
 	// Health checking
-	HealthEndpoint      string         // e.g., "/health"
-	HealthCheckInterval time.Duration  // Polling frequency (default: 500ms)
-	HealthCheckTimeout  time.Duration  // Per-attempt HTTP timeout (default: 2s)
-	HealthCheckSuccess  func(int) bool // Custom status code validator
-	StartupTimeout      time.Duration  // Max time to wait for healthy (default: 30s)
+	HealthEndpoint         string         // e.g., "/health"
+	HealthCheckInterval    time.Duration  // First retry delay (default: 500ms)
+	HealthCheckMaxInterval time.Duration  // Retry delay cap as it backs off (default: 2s)
+	HealthCheckTimeout     time.Duration  // Per-attempt HTTP timeout (default: 2s)
+	HealthCheckSuccess     func(int) bool // Custom status code validator; overrides HealthExpectedStatuses
+	HealthExpectedStatuses []int          // Healthy status codes (default: any 2xx)
+	HealthRequiredFields   []string       // Dotted JSON paths the health body must contain
+	StartupTimeout         time.Duration  // Max time to wait for healthy (default: 30s)
 
 	// Shutdown configuration
 	ShutdownTimeout time.Duration // Max time to wait for graceful stop (default: 10s)
@@ -45,9 +48,12 @@ type ServerManager struct {
 	mu      sync.Mutex
 	config  ServerConfig
 	logger  Logger
-	cmd     *exec.Cmd
+	runner  CommandRunner
+	proc    Process // nil when not running
 	baseURL string
 	done    chan error // signals process exit
+	events  *EventBus
+	report  *StartupReport // of the last Start
 }
 
 // Logger defines the minimal logging interface required by ServerManager.
@@ -67,18 +73,26 @@ func DefaultConfig() ServerConfig {
 		StartupTimeout:       30 * time.Second,
 		ShutdownTimeout:      10 * time.Second,
 		ShutdownSignal:       os.Interrupt,
-		HealthCheckSuccess:   defaultHealthCheckSuccess,
 		CaptureStderrOnError: true,
 	}
 }
 
-// defaultHealthCheckSuccess accepts 2xx and 3xx status codes.
-func defaultHealthCheckSuccess(statusCode int) bool {
-	return statusCode >= 200 && statusCode < 400
+// ServerOption configures a ServerManager.
+type ServerOption func(*ServerManager)
+
+// WithServerRunner starts the server through runner instead of RealRunner,
+// e.g. a FakeRunner in unit tests. The command is then not looked up in
+// PATH.
+func WithServerRunner(runner CommandRunner) ServerOption {
+	return func(sm *ServerManager) {
+		if runner != nil {
+			sm.runner = runner
+		}
+	}
 }
 
 // NewServerManager creates a new server manager instance with validation.
-func NewServerManager(cfg ServerConfig, baseURL string, logger Logger) (*ServerManager, error) {
+func NewServerManager(cfg ServerConfig, baseURL string, logger Logger, opts ...ServerOption) (*ServerManager, error) {
 	// Apply defaults for zero values
 	if cfg.HealthCheckInterval <= 0 {
 		cfg.HealthCheckInterval = 500 * time.Millisecond
@@ -95,33 +109,45 @@ func NewServerManager(cfg ServerConfig, baseURL string, logger Logger) (*ServerM
 	if cfg.ShutdownSignal == nil {
 		cfg.ShutdownSignal = os.Interrupt
 	}
-	if cfg.HealthCheckSuccess == nil {
-		cfg.HealthCheckSuccess = defaultHealthCheckSuccess
+	if cfg.HealthCheckMaxInterval <= 0 {
+		cfg.HealthCheckMaxInterval = 2 * time.Second
 	}
 
 	// Validate required fields
 	if cfg.Path == "" {
-		return nil, fmt.Errorf("server path cannot be empty")
+		return nil, kindErrorf(ErrValidation, "server path cannot be empty")
 	}
 	if cfg.Command == "" {
-		return nil, fmt.Errorf("server command cannot be empty")
+		return nil, kindErrorf(ErrValidation, "server command cannot be empty")
 	}
 	if baseURL == "" {
-		return nil, fmt.Errorf("base URL cannot be empty")
+		return nil, kindErrorf(ErrValidation, "base URL cannot be empty")
 	}
 
 	// Verify working directory exists
 	info, err := os.Stat(cfg.Path)
 	if err != nil {
-		return nil, fmt.Errorf("server path '%s' invalid: %w", cfg.Path, err)
+		return nil, kindErrorf(ErrValidation, "server path '%s' invalid: %w", cfg.Path, err)
 	}
 	if !info.IsDir() {
-		return nil, fmt.Errorf("server path '%s' is not a directory", cfg.Path)
+		return nil, kindErrorf(ErrValidation, "server path '%s' is not a directory", cfg.Path)
+	}
+
+	sm := &ServerManager{
+		config:  cfg,
+		logger:  logger,
+		baseURL: baseURL,
+		runner:  RealRunner{},
+	}
+	for _, opt := range opts {
+		opt(sm)
 	}
 
 	// Verify command exists
-	if _, err := exec.LookPath(cfg.Command); err != nil {
-		return nil, fmt.Errorf("command '%s' not found in PATH: %w", cfg.Command, err)
+	if _, real := sm.runner.(RealRunner); real {
+		if _, err := exec.LookPath(cfg.Command); err != nil {
+			return nil, kindErrorf(ErrValidation, "command '%s' not found in PATH: %w", cfg.Command, err)
+		}
 	}
 
 	// Optional: pre-flight port check
@@ -131,11 +157,14 @@ func NewServerManager(cfg ServerConfig, baseURL string, logger Logger) (*ServerM
 		}
 	}
 
-	return &ServerManager{
-		config:  cfg,
-		logger:  logger,
-		baseURL: baseURL,
-	}, nil
+	return sm, nil
+}
+
+// SetEventBus makes the manager publish server_* lifecycle events to bus.
+func (sm *ServerManager) SetEventBus(bus *EventBus) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.events = bus
 }
 
 // Start launches the application server and waits for it to become healthy.
@@ -145,8 +174,8 @@ func (sm *ServerManager) Start(ctx context.Context) error {
 	defer sm.mu.Unlock()
 
 	// Prevent double-start
-	if sm.cmd != nil && sm.cmd.Process != nil {
-		return fmt.Errorf("server already running with PID %d", sm.cmd.Process.Pid)
+	if sm.proc != nil {
+		return fmt.Errorf("server already running with PID %d", sm.proc.Pid())
 	}
 
 	sm.logger.Info("Starting server",
@@ -154,50 +183,71 @@ func (sm *ServerManager) Start(ctx context.Context) error {
 		"args", sm.config.Args,
 		"dir", sm.config.Path)
 
-	// Prepare command
-	sm.cmd = exec.CommandContext(ctx, sm.config.Command, sm.config.Args...)
-	sm.cmd.Dir = sm.config.Path
-	sm.cmd.Env = sm.getEnvironmentVariables()
+	report := &StartupReport{
+		Command:   sm.config.Command,
+		Args:      sm.config.Args,
+		Dir:       sm.config.Path,
+		Env:       redactEnv(sm.config.EnvVars),
+		StartedAt: time.Now(),
+	}
+	sm.report = report
+
+	// The report keeps the last lines of output whatever else is configured
+	output := newOutputRing(startupOutputLines)
+	stdoutRing, stderrRing := output.stream("stdout"), output.stream("stderr")
+	stdout := []io.Writer{stdoutRing}
+	stderr := []io.Writer{stderrRing}
 
 	// Capture stderr for debugging if requested
 	var stderrBuf bytes.Buffer
 	if sm.config.CaptureStderrOnError {
-		sm.cmd.Stderr = &stderrBuf
+		stderr = append(stderr, &stderrBuf)
 	}
 
 	// Redirect stdout/stderr to logger if configured
 	if sm.config.LogOutput {
-		if sm.cmd.Stdout == nil {
-			sm.cmd.Stdout = sm.logger.Writer()
-		}
-		if sm.cmd.Stderr == nil {
-			sm.cmd.Stderr = io.MultiWriter(sm.logger.Writer(), &stderrBuf)
-		}
+		stdout = append(stdout, sm.logger.Writer())
+		stderr = append(stderr, sm.logger.Writer())
 	}
 
 	// Start the process
-	if err := sm.cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start server process: %w", err)
+	proc, err := sm.runner.Start(ctx, CommandSpec{
+		Name:   sm.config.Command,
+		Args:   sm.config.Args,
+		Dir:    sm.config.Path,
+		Env:    sm.getEnvironmentVariables(),
+		Stdout: io.MultiWriter(stdout...),
+		Stderr: io.MultiWriter(stderr...),
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to start server process: %w", err)
+		report.finish(StartupSpawnFailed, err, output)
+		return &StartupError{Err: err, Report: report}
 	}
+	report.SpawnLatency = time.Since(report.StartedAt)
+	report.PID = proc.Pid()
+	sm.proc = proc
+	output.start(time.Now())
 
-	sm.logger.Info("Server process started", "pid", sm.cmd.Process.Pid)
+	sm.logger.Info("Server process started", "pid", proc.Pid())
+	sm.events.Emit(EventServerStarted, "server", map[string]any{"pid": proc.Pid()})
 
-	// Start process reaper
+	// Start process reaper; sm.proc is nil once a kill gives up waiting.
+	// It must not take sm.mu: Stop holds it while waiting on done.
 	sm.done = make(chan error, 1)
+	done := sm.done
 	go func() {
-		err := sm.cmd.Wait()
-		sm.mu.Lock()
-		defer sm.mu.Unlock()
+		err := proc.Wait()
 		select {
-		case sm.done <- err:
+		case done <- err:
 		default:
 		}
-		sm.logger.Debug("Process exited", "pid", sm.cmd.Process.Pid, "err", err)
+		sm.logger.Debug("Process exited", "pid", proc.Pid(), "err", err)
 	}()
 
 	// Wait for health check
 	healthURL := sm.baseURL + sm.config.HealthEndpoint
-	if err := sm.waitForHealth(ctx, healthURL); err != nil {
+	if err := sm.waitForHealth(ctx, healthURL, report); err != nil {
 		// Capture stderr before killing
 		var stderrMsg string
 		if sm.config.CaptureStderrOnError && stderrBuf.Len() > 0 {
@@ -206,27 +256,47 @@ func (sm *ServerManager) Start(ctx context.Context) error {
 
 		// Clean up the process
 		_ = sm.killProcessLocked()
-		return fmt.Errorf("server health check failed: %w%s", err, stderrMsg)
+		stdoutRing.flush()
+		stderrRing.flush()
+
+		outcome := StartupUnhealthy
+		if ctx.Err() != nil {
+			outcome = StartupCancelled
+		}
+		err = fmt.Errorf("server health check failed: %w%s", err, stderrMsg)
+		report.finish(outcome, err, output)
+		return &StartupError{Err: err, Report: report}
 	}
+	report.finish(StartupHealthy, nil, output)
 
 	sm.logger.Info("Server started successfully", "url", sm.baseURL)
+	sm.events.Emit(EventServerReady, "server", map[string]any{"url": sm.baseURL})
 	return nil
 }
 
+// GetStartupReport returns the report of the last Start, successful or
+// not, or nil before the first. A failed Start also returns it inside its
+// *StartupError.
+func (sm *ServerManager) GetStartupReport() *StartupReport {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.report
+}
+
 // Stop gracefully terminates the server with configurable signal and timeout.
 func (sm *ServerManager) Stop(ctx context.Context) error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.cmd == nil || sm.cmd.Process == nil {
+	if sm.proc == nil {
 		sm.logger.Info("Server process not running, skipping stop")
 		return nil
 	}
 
-	sm.logger.Info("Stopping server gracefully...", "pid", sm.cmd.Process.Pid)
+	sm.logger.Info("Stopping server gracefully...", "pid", sm.proc.Pid())
 
 	// Send configured shutdown signal
-	if err := sm.cmd.Process.Signal(sm.config.ShutdownSignal); err != nil {
+	if err := sm.proc.Signal(sm.config.ShutdownSignal); err != nil {
 		sm.logger.Error("Failed to send shutdown signal", "error", err)
 		return fmt.Errorf("failed to signal process: %w", err)
 	}
@@ -235,7 +305,8 @@ func (sm *ServerManager) Stop(ctx context.Context) error {
 	select {
 	case err := <-sm.done:
 		sm.logger.Info("Server terminated gracefully")
-		sm.cmd = nil
+		sm.proc = nil
+		sm.events.Emit(EventServerStopped, "server", nil)
 		return err
 	case <-time.After(sm.config.ShutdownTimeout):
 		sm.logger.Warn("Server shutdown timeout exceeded, forcing termination")
@@ -249,14 +320,14 @@ func (sm *ServerManager) Stop(ctx context.Context) error {
 // killProcessLocked forces the server process to stop (SIGKILL).
 // Must be called with sm.mu held.
 func (sm *ServerManager) killProcessLocked() error {
-	if sm.cmd == nil || sm.cmd.Process == nil {
+	if sm.proc == nil {
 		return nil
 	}
 
-	pid := sm.cmd.Process.Pid
+	pid := sm.proc.Pid()
 	sm.logger.Warn("Force-killing server process", "pid", pid)
 
-	if err := sm.cmd.Process.Kill(); err != nil {
+	if err := sm.proc.Signal(os.Kill); err != nil {
 		return fmt.Errorf("failed to force kill process %d: %w", pid, err)
 	}
 
@@ -270,7 +341,7 @@ func (sm *ServerManager) killProcessLocked() error {
 		}
 	}
 
-	sm.cmd = nil
+	sm.proc = nil
 	return nil
 }
 
@@ -290,57 +361,37 @@ func (sm *ServerManager) getEnvironmentVariables() []string {
 		envMap[key] = val
 	}
 
-	// Convert back to slice
+	// Convert back to slice, sorted so the spec is stable
 	env := make([]string, 0, len(envMap))
 	for key, val := range envMap {
 		env = append(env, fmt.Sprintf("%s=%s", key, val))
 	}
+	sort.Strings(env)
 	return env
 }
 
-// waitForHealth polls the health endpoint until success or timeout.
-func (sm *ServerManager) waitForHealth(ctx context.Context, url string) error {
-	// Reusable HTTP client with per-attempt timeout
-	client := &http.Client{
-		Timeout: sm.config.HealthCheckTimeout,
-	}
-
-	deadline := time.Now().Add(sm.config.StartupTimeout)
-	ticker := time.NewTicker(sm.config.HealthCheckInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("health check cancelled: %w", ctx.Err())
-		case <-ticker.C:
-			if time.Now().After(deadline) {
-				return fmt.Errorf("health check timed out after %v", sm.config.StartupTimeout)
-			}
-
-			// Create request with context
-			req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-			if err != nil {
-				sm.logger.Debug("Failed to create health request", "error", err)
-				continue
-			}
-
-			resp, err := client.Do(req)
-			if err != nil {
-				// Connection refused/reset is normal during startup
-				sm.logger.Debug("Health check failed (connection error)", "error", err)
-				continue
-			}
-			resp.Body.Close()
-
-			if sm.config.HealthCheckSuccess(resp.StatusCode) {
-				sm.logger.Debug("Health check succeeded", "status", resp.StatusCode)
-				return nil
+// waitForHealth polls the health endpoint with a HealthCheck until it is
+// healthy or StartupTimeout passes.
+func (sm *ServerManager) waitForHealth(ctx context.Context, url string, report *StartupReport) error {
+	check := &HealthCheck{
+		URL:              url,
+		ExpectedStatuses: sm.config.HealthExpectedStatuses,
+		AcceptStatus:     sm.config.HealthCheckSuccess,
+		RequiredFields:   sm.config.HealthRequiredFields,
+		Timeout:          sm.config.HealthCheckTimeout,
+		Interval:         sm.config.HealthCheckInterval,
+		MaxInterval:      sm.config.HealthCheckMaxInterval,
+		OnAttempt: func(r HealthResult) {
+			report.HealthAttempts = append(report.HealthAttempts, r)
+			if r.Healthy {
+				sm.logger.Debug("Health check succeeded", "status", r.Status, "latency", r.Latency)
+				return
 			}
-
-			sm.logger.Debug("Health check failed (status code)", "status", resp.StatusCode)
-		}
+			sm.logger.Debug("Health check failed", "attempt", r.Attempt, "status", r.Status, "reason", r.Reason)
+		},
 	}
+	_, err := check.WaitHealthy(ctx, sm.config.StartupTimeout)
+	return err
 }
 
 // checkPortAvailable verifies a TCP port is free on the given host.
@@ -351,7 +402,7 @@ func checkPortAvailable(host string, port int) error {
 	addr := fmt.Sprintf("%s:%d", host, port)
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
-		return fmt.Errorf("port %d on %s is already in use: %w", port, host, err)
+		return kindErrorf(ErrUnavailable, "port %d on %s is already in use: %w", port, host, err)
 	}
 	ln.Close()
 	return nil
@@ -362,8 +413,8 @@ func (sm *ServerManager) Pid() int {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.cmd != nil && sm.cmd.Process != nil {
-		return sm.cmd.Process.Pid
+	if sm.proc != nil {
+		return sm.proc.Pid()
 	}
 	return 0
 }
@@ -373,10 +424,10 @@ func (sm *ServerManager) IsRunning() bool {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	if sm.cmd == nil || sm.cmd.Process == nil {
+	if sm.proc == nil {
 		return false
 	}
 
 	// Signal with signal 0 tests process existence
-	return sm.cmd.Process.Signal(os.Signal(nil)) == nil
+	return sm.proc.Signal(os.Signal(nil)) == nil
 }
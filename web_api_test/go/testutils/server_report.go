@@ -0,0 +1,190 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// StartupReport – what happened while ServerManager.Start ran
+// ------------------------------------------------------------------------
+
+// StartupOutcome says how ServerManager.Start ended.
+type StartupOutcome string
+
+const (
+	StartupHealthy     StartupOutcome = "healthy"
+	StartupSpawnFailed StartupOutcome = "spawn_failed" // the process could not be started
+	StartupUnhealthy   StartupOutcome = "unhealthy"    // no healthy answer before StartupTimeout
+	StartupCancelled   StartupOutcome = "cancelled"    // Start's context ended first
+)
+
+// startupOutputLines is how many output lines a StartupReport keeps.
+const startupOutputLines = 200
+
+// StartupReport records a ServerManager.Start: how long the process took
+// to spawn and to print, every health attempt, and the last lines of its
+// output whether or not LogOutput is set. It marshals to JSON for the
+// artifact bundle.
+type StartupReport struct {
+	Command            string            `json:"command"`
+	Args               []string          `json:"args,omitempty"`
+	Dir                string            `json:"dir"`
+	Env                map[string]string `json:"env,omitempty"` // ServerConfig.EnvVars, values masked
+	StartedAt          time.Time         `json:"started_at"`
+	PID                int               `json:"pid,omitempty"`
+	SpawnLatency       time.Duration     `json:"spawn_latency"`                  // Start until the process ran
+	FirstOutputLatency time.Duration     `json:"first_output_latency,omitempty"` // spawn until the first output line; 0 if none
+	HealthAttempts     []HealthResult    `json:"health_attempts,omitempty"`
+	Outcome            StartupOutcome    `json:"outcome"`
+	Error              string            `json:"error,omitempty"`
+	Duration           time.Duration     `json:"duration"`
+	Output             []OutputLine      `json:"output,omitempty"`         // last lines of stdout and stderr
+	OutputDropped      int               `json:"output_dropped,omitempty"` // earlier lines no longer held
+}
+
+// OutputLine is a line the server printed.
+type OutputLine struct {
+	Stream string        `json:"stream"` // "stdout" or "stderr"
+	At     time.Duration `json:"at"`     // since the process was spawned
+	Text   string        `json:"text"`
+}
+
+// StartupError is returned by ServerManager.Start when the server does not
+// come up. It carries the StartupReport; use errors.As to reach it.
+type StartupError struct {
+	Err    error
+	Report *StartupReport
+}
+
+func (e *StartupError) Error() string { return e.Err.Error() }
+
+func (e *StartupError) Unwrap() error { return e.Err }
+
+// redactEnv copies env for display. ServerConfig.EnvVars is tagged
+// sensitive, so only the names are kept; empty values stay empty.
+func redactEnv(env map[string]string) map[string]string {
+	if len(env) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(env))
+	for k, v := range env {
+		if v != "" {
+			v = redactedValue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// outputRing keeps the last lines written to its stream writers.
+type outputRing struct {
+	mu      sync.Mutex
+	lines   []OutputLine // circular once full
+	next    int
+	dropped int
+	spawned time.Time
+	first   time.Duration // At of the first line, -1 before it
+}
+
+func newOutputRing(size int) *outputRing {
+	return &outputRing{lines: make([]OutputLine, 0, size), first: -1}
+}
+
+// start sets the time lines are stamped relative to.
+func (r *outputRing) start(spawned time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spawned = spawned
+}
+
+// stream returns a writer for one of the process's outputs. Each stream
+// buffers its own partial line, so stdout and stderr written at the same
+// time do not mix within a line.
+func (r *outputRing) stream(name string) *ringStream {
+	return &ringStream{ring: r, name: name}
+}
+
+func (r *outputRing) addLocked(stream, text string) {
+	at := time.Duration(0)
+	if !r.spawned.IsZero() {
+		at = time.Since(r.spawned)
+	}
+	if r.first < 0 {
+		r.first = at
+	}
+	line := OutputLine{Stream: stream, At: at, Text: text}
+	if len(r.lines) < cap(r.lines) {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	r.dropped++
+}
+
+// snapshot returns the held lines oldest first, how many were dropped and
+// the latency of the first line (0 if there was none).
+func (r *outputRing) snapshot() ([]OutputLine, int, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lines := make([]OutputLine, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	lines = append(lines, r.lines[:r.next]...)
+	first := r.first
+	if first < 0 {
+		first = 0
+	}
+	return lines, r.dropped, first
+}
+
+type ringStream struct {
+	ring    *outputRing
+	name    string
+	partial []byte // guarded by ring.mu
+}
+
+func (s *ringStream) Write(p []byte) (int, error) {
+	s.ring.mu.Lock()
+	defer s.ring.mu.Unlock()
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.ring.addLocked(s.name, string(bytes.TrimSuffix(s.partial[:i], []byte("\r"))))
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+// flush records an unterminated last line.
+func (s *ringStream) flush() {
+	s.ring.mu.Lock()
+	defer s.ring.mu.Unlock()
+	if len(s.partial) > 0 {
+		s.ring.addLocked(s.name, string(s.partial))
+		s.partial = nil
+	}
+}
+
+// finish completes r from the output ring and err, the error Start returns.
+func (r *StartupReport) finish(outcome StartupOutcome, err error, ring *outputRing) {
+	r.Outcome = outcome
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.Duration = time.Since(r.StartedAt)
+	if ring != nil {
+		r.Output, r.OutputDropped, r.FirstOutputLatency = ring.snapshot()
+	}
+}
+
+// String summarises the report on one line.
+func (r *StartupReport) String() string {
+	return fmt.Sprintf("%s after %v: %d health attempt(s), %d output line(s)",
+		r.Outcome, r.Duration.Round(time.Millisecond), len(r.HealthAttempts), len(r.Output)+r.OutputDropped)
+}
@@ -0,0 +1,86 @@
+package testutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutputRing_KeepsLastLines(t *testing.T) {
+	ring := newOutputRing(3)
+	ring.start(time.Now())
+	stdout, stderr := ring.stream("stdout"), ring.stream("stderr")
+
+	fmt.Fprint(stdout, "one\ntw")
+	fmt.Fprint(stderr, "boom\r\n")
+	fmt.Fprint(stdout, "o\nthree\nfour\nfi")
+	stdout.flush()
+
+	lines, dropped, first := ring.snapshot()
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.Stream+":"+l.Text)
+	}
+	want := []string{"stdout:three", "stdout:four", "stdout:fi"}
+	if !reflect.DeepEqual(got, want) || dropped != 3 {
+		t.Errorf("got %q, %d dropped; want %q, 3 dropped", got, dropped, want)
+	}
+	if first < 0 || first > lines[0].At {
+		t.Errorf("first output latency %v out of range", first)
+	}
+
+	empty := newOutputRing(3)
+	if lines, dropped, first := empty.snapshot(); len(lines) != 0 || dropped != 0 || first != 0 {
+		t.Errorf("empty ring: %v, %d, %v", lines, dropped, first)
+	}
+}
+
+func TestStartupReport_JSON(t *testing.T) {
+	ring := newOutputRing(startupOutputLines)
+	fmt.Fprint(ring.stream("stderr"), "listen tcp :8080: address already in use\n")
+
+	report := &StartupReport{
+		Command:   "./server",
+		Args:      []string{"-port", "8080"},
+		Env:       redactEnv(map[string]string{"PORT": "8080", "DB_PASSWORD": "hunter2", "DEBUG": ""}),
+		StartedAt: time.Now().Add(-time.Second),
+		PID:       4242,
+		HealthAttempts: []HealthResult{
+			{Reason: "connection refused", Latency: time.Millisecond, Attempt: 1},
+			{Status: 503, Reason: "status 503", Latency: 2 * time.Millisecond, Attempt: 2},
+		},
+	}
+	err := &StartupError{Err: errors.New("server health check failed: timeout"), Report: report}
+	report.finish(StartupUnhealthy, err, ring)
+
+	// Every env value may be a secret; only empty ones are shown as such.
+	want := map[string]string{"PORT": redactedValue, "DB_PASSWORD": redactedValue, "DEBUG": ""}
+	if !reflect.DeepEqual(report.Env, want) {
+		t.Errorf("env %v, want %v", report.Env, want)
+	}
+	if report.Duration < time.Second || report.Error != err.Error() || len(report.Output) != 1 {
+		t.Errorf("finish did not complete the report: %+v", report)
+	}
+
+	var se *StartupError
+	if !errors.As(fmt.Errorf("setup: %w", err), &se) || se.Report != report {
+		t.Error("errors.As must reach the report")
+	}
+
+	data, jerr := json.Marshal(report)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	var back StartupReport
+	if jerr := json.Unmarshal(data, &back); jerr != nil {
+		t.Fatal(jerr)
+	}
+	if back.Outcome != StartupUnhealthy || back.PID != 4242 || len(back.HealthAttempts) != 2 ||
+		back.HealthAttempts[1].Status != 503 || back.Output[0].Stream != "stderr" ||
+		back.Env["DB_PASSWORD"] != redactedValue {
+		t.Errorf("round trip lost data: %s", data)
+	}
+}
@@ -0,0 +1,141 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// discardServerLogger is a ServerManager Logger that drops everything.
+type discardServerLogger struct{}
+
+func (discardServerLogger) Info(string, ...interface{})  {}
+func (discardServerLogger) Debug(string, ...interface{}) {}
+func (discardServerLogger) Warn(string, ...interface{})  {}
+func (discardServerLogger) Error(string, ...interface{}) {}
+func (discardServerLogger) Writer() io.Writer            { return io.Discard }
+
+// newFakeServerManager returns a manager for "npm run start" whose health
+// endpoint answers status.
+func newFakeServerManager(t *testing.T, status int, cfg ServerConfig) (*ServerManager, *FakeRunner) {
+	t.Helper()
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(health.Close)
+
+	cfg.Path, cfg.Command, cfg.Args = t.TempDir(), "npm", []string{"run", "start"}
+	cfg.HealthEndpoint = "/health"
+	cfg.HealthCheckInterval = 5 * time.Millisecond
+	runner := NewFakeRunner()
+	sm, err := NewServerManager(cfg, health.URL, discardServerLogger{}, WithServerRunner(runner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm, runner
+}
+
+func TestServerManager_StartStopWithFakeRunner(t *testing.T) {
+	t.Setenv("TESTUTILS_SERVER_MODE", "system")
+	sm, runner := newFakeServerManager(t, http.StatusOK, ServerConfig{
+		EnvVars: map[string]string{"TESTUTILS_SERVER_MODE": "test", "PORT": "8080"},
+	})
+	runner.On("npm", "start").Stdout("listening on 8080\n").UntilSignal()
+
+	if err := sm.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	spec := runner.Calls()[0]
+	if spec.Name != "npm" || !reflect.DeepEqual(spec.Args, []string{"run", "start"}) || spec.Dir != sm.config.Path {
+		t.Errorf("started %s %v in %s", spec.Name, spec.Args, spec.Dir)
+	}
+
+	// Custom variables override the system ones, each key once.
+	var mode []string
+	for _, kv := range spec.Env {
+		if strings.HasPrefix(kv, "TESTUTILS_SERVER_MODE=") {
+			mode = append(mode, kv)
+		}
+	}
+	if !reflect.DeepEqual(mode, []string{"TESTUTILS_SERVER_MODE=test"}) {
+		t.Errorf("merged env has %q", mode)
+	}
+	if !slices.Contains(spec.Env, "PORT=8080") || !sort.StringsAreSorted(spec.Env) {
+		t.Errorf("env missing PORT or unsorted: %q", spec.Env)
+	}
+
+	report := sm.GetStartupReport()
+	if report.Outcome != StartupHealthy || report.PID != sm.Pid() || len(report.Output) != 1 ||
+		report.Output[0].Text != "listening on 8080" {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if err := sm.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("double start: %v", err)
+	}
+
+	if err := sm.Stop(context.Background()); err == nil || err.Error() != "signal: interrupt" {
+		t.Errorf("stop returned %v", err)
+	}
+	proc := runner.Processes()[0]
+	if !reflect.DeepEqual(proc.Signals(), []os.Signal{os.Interrupt}) || sm.Pid() != 0 {
+		t.Errorf("signals %v, pid %d", proc.Signals(), sm.Pid())
+	}
+}
+
+func TestServerManager_StopEscalatesToKill(t *testing.T) {
+	sm, runner := newFakeServerManager(t, http.StatusOK, ServerConfig{ShutdownTimeout: 20 * time.Millisecond})
+	runner.On("npm").UntilSignal(os.Kill) // ignores the interrupt
+
+	if err := sm.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := sm.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	proc := runner.Processes()[0]
+	if !reflect.DeepEqual(proc.Signals(), []os.Signal{os.Interrupt, os.Kill}) || !proc.Exited() {
+		t.Errorf("signals %v, exited %v", proc.Signals(), proc.Exited())
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("killed after %v, before the shutdown timeout", waited)
+	}
+	if sm.IsRunning() {
+		t.Error("server still running after the kill")
+	}
+}
+
+func TestServerManager_StartFailures(t *testing.T) {
+	sm, runner := newFakeServerManager(t, http.StatusServiceUnavailable, ServerConfig{
+		StartupTimeout:       50 * time.Millisecond,
+		CaptureStderrOnError: true,
+	})
+	runner.On("npm").Fail(errors.New(`exec: "npm": executable file not found in $PATH`)).Times(1)
+	runner.On("npm").Stderr("Error: Cannot find module 'express'\n").UntilSignal()
+
+	var se *StartupError
+	err := sm.Start(context.Background())
+	if !errors.As(err, &se) || se.Report.Outcome != StartupSpawnFailed || sm.Pid() != 0 {
+		t.Fatalf("expected a spawn failure, got %v", err)
+	}
+
+	err = sm.Start(context.Background())
+	if !errors.As(err, &se) || se.Report.Outcome != StartupUnhealthy {
+		t.Fatalf("expected an unhealthy start, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "stderr:\nError: Cannot find module 'express'") {
+		t.Errorf("stderr missing from %v", err)
+	}
+	if proc := runner.Processes()[0]; !proc.Exited() || sm.Pid() != 0 {
+		t.Error("the unhealthy server was not killed")
+	}
+}
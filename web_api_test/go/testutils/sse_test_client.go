@@ -0,0 +1,166 @@
+package testutils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// ------------------------------------------------------------------------
+// SSE streams for HTTPTestClient
+// ------------------------------------------------------------------------
+
+// SSEEvent is one event received from a Server-Sent Events stream.
+type SSEEvent struct {
+	ID    string
+	Event string // "message" when the server sent no event name
+	Data  string
+}
+
+// Decode unmarshals the event's JSON data into v.
+func (e SSEEvent) Decode(v any) error {
+	if err := json.Unmarshal([]byte(e.Data), v); err != nil {
+		return fmt.Errorf("decode %q event: %w", e.Event, err)
+	}
+	return nil
+}
+
+// SSEStream is an open event stream. Events is closed when the server ends
+// the stream, the connection fails or Close is called; Err then tells which.
+type SSEStream struct {
+	Events <-chan SSEEvent
+
+	// Response is the server's response; its Body belongs to the stream.
+	Response *http.Response
+
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+	comments atomic.Int64
+}
+
+// StreamSSE opens an event stream at path and decodes events into the
+// returned stream's channel. It fails unless the server answers 200 with
+// Content-Type text/event-stream. The client's timeout does not apply; the
+// stream lasts until ctx ends or Close is called.
+func (c *HTTPTestClient) StreamSSE(ctx context.Context, path string, opts ...RequestOption) (*SSEStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, _, err := c.newRequest(ctx, http.MethodGet, path, nil, "", opts)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	hc := *c.client
+	hc.Timeout = 0
+	resp, err := hc.Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("GET %s: %w", path, err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusOK || mediaType != "text/event-stream" {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		cancel()
+		return nil, fmt.Errorf("GET %s: not an event stream: %s %s: %s",
+			path, resp.Status, resp.Header.Get("Content-Type"), strings.TrimSpace(string(body)))
+	}
+
+	events := make(chan SSEEvent, 64)
+	s := &SSEStream{Events: events, Response: resp, cancel: cancel, done: make(chan struct{})}
+	go s.read(ctx, events)
+	return s, nil
+}
+
+// read parses the stream as described by the HTML Living Standard,
+// "Interpreting an event stream".
+func (s *SSEStream) read(ctx context.Context, events chan<- SSEEvent) {
+	defer close(s.done)
+	defer close(events)
+	defer s.Response.Body.Close()
+
+	br := bufio.NewReader(s.Response.Body)
+	var ev SSEEvent
+	var data strings.Builder
+	hasData := false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			// An incomplete event at end of stream is discarded.
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.err = fmt.Errorf("read event stream: %w", err)
+			}
+			return
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if hasData {
+				ev.Data = data.String()
+				if ev.Event == "" {
+					ev.Event = "message"
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+			ev = SSEEvent{ID: ev.ID}
+			data.Reset()
+			hasData = false
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			s.comments.Add(1)
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Event = value
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				ev.ID = value
+			}
+		}
+	}
+}
+
+// Comments returns how many comment lines, such as heartbeats, have been
+// received so far.
+func (s *SSEStream) Comments() int64 { return s.comments.Load() }
+
+// Err returns the error that ended the stream, or nil if the server closed
+// it or Close was called. It is only meaningful once Events is closed.
+func (s *SSEStream) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close disconnects from the server and waits for Events to be closed.
+func (s *SSEStream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
@@ -0,0 +1,461 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ------------------------------------------------------------------------
+// StateSeeder – API state declared in YAML, created before a test
+// ------------------------------------------------------------------------
+
+// SeedResource describes how a kind of resource in a seed file is created
+// and deleted through the API.
+type SeedResource struct {
+	Path       string // POST target, e.g. "/users"
+	IDField    string // dotted path of the ID in the response; "id" when empty
+	DeletePath string // DELETE target with an {id} placeholder; empty skips teardown
+}
+
+// SeededResource is a resource created by a StateSeeder.
+type SeededResource struct {
+	Kind     string
+	Key      string
+	ID       string
+	Response map[string]any // decoded response body, nil unless an object
+}
+
+// SeedError reports the resource that stopped seeding.
+type SeedError struct {
+	Kind   string
+	Index  int
+	Key    string
+	Path   string // POST target
+	Status int    // 0 when no response was received
+	Body   string // response body
+	Err    error
+}
+
+func (e *SeedError) Error() string {
+	what := fmt.Sprintf("seed %s[%d]", e.Kind, e.Index)
+	if e.Key != "" {
+		what += fmt.Sprintf(" (%s)", e.Key)
+	}
+	if e.Err != nil {
+		return what + ": " + e.Err.Error()
+	}
+	return fmt.Sprintf("%s: POST %s returned %d: %s", what, e.Path, e.Status, e.Body)
+}
+
+func (e *SeedError) Unwrap() error { return e.Err }
+
+// SeederOption configures a StateSeeder.
+type SeederOption func(*StateSeeder)
+
+// WithSeedTestID sets the value of {{.TestID}} in seed files.
+func WithSeedTestID(id string) SeederOption {
+	return func(s *StateSeeder) { s.testID = id }
+}
+
+// WithSeedGenerator sets the generator behind the rand* template
+// functions. Use a fixed seed for reproducible values.
+func WithSeedGenerator(g *FixtureGenerator) SeederOption {
+	return func(s *StateSeeder) { s.gen = g }
+}
+
+// StateSeeder creates API state declared in a YAML file through an
+// HTTPTestClient, instead of POSTing in each test's setup code. Each top
+// level key is a kind registered with Register, listing the resources to
+// create in order:
+//
+//	users:
+//	  - key: alice
+//	    body:
+//	      name: alice-{{.TestID}}
+//	      email: "{{randEmail}}"
+//	uploads:
+//	  - key: avatar
+//	    body:
+//	      owner_id: '{{ref "users" "alice"}}'
+//	      filename: avatar.png
+//
+// Kinds are seeded in the order they appear in the file, and resources in
+// list order, so a resource can refer to those above it with ref. String
+// values in a body are Go templates with .TestID and the functions
+// randEmail, randString n, randInt min max, uuid and ref kind key. The
+// created IDs are available from Lookup; Teardown deletes the resources in
+// reverse order.
+type StateSeeder struct {
+	client    *HTTPTestClient
+	testID    string
+	gen       *FixtureGenerator
+	resources map[string]SeedResource
+
+	mu      sync.Mutex
+	created []SeededResource
+	byKey   map[string]SeededResource // "kind/key"
+}
+
+// NewStateSeeder returns a seeder sending requests through client.
+func NewStateSeeder(client *HTTPTestClient, opts ...SeederOption) *StateSeeder {
+	s := &StateSeeder{
+		client:    client,
+		resources: make(map[string]SeedResource),
+		byKey:     make(map[string]SeededResource),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.gen == nil {
+		s.gen = NewFixtureGenerator(RandomIntConfig{})
+	}
+	return s
+}
+
+// Register declares the resource kind used as a top-level key in seed
+// files.
+func (s *StateSeeder) Register(kind string, r SeedResource) {
+	if r.IDField == "" {
+		r.IDField = "id"
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[kind] = r
+}
+
+// seedItem is a resource entry of a seed file.
+type seedItem struct {
+	kind  string
+	index int
+	key   string
+	body  any
+}
+
+// SeedFile creates the resources declared in the YAML file at path. The
+// file is checked against the registered kinds before anything is sent;
+// problems are returned as a *ValidationReport with file and line. A
+// failing request stops seeding with a *SeedError; the resources created
+// before it are kept for Teardown.
+func (s *StateSeeder) SeedFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read seed file: %w", err)
+	}
+	return s.seed(ctx, filepath.Base(path), data)
+}
+
+// Seed is SeedFile for a document already in memory.
+func (s *StateSeeder) Seed(ctx context.Context, data []byte) error {
+	return s.seed(ctx, "seed", data)
+}
+
+// SeedTest seeds the file at path for t, failing it on error, and tears
+// the state down when t finishes. {{.TestID}} is t.Name() unless set with
+// WithSeedTestID.
+func (s *StateSeeder) SeedTest(t testing.TB, path string) {
+	t.Helper()
+	s.mu.Lock()
+	if s.testID == "" {
+		s.testID = t.Name()
+	}
+	s.mu.Unlock()
+	t.Cleanup(func() {
+		if err := s.Teardown(context.Background()); err != nil {
+			t.Errorf("StateSeeder.Teardown: %v", err)
+		}
+	})
+	if err := s.SeedFile(context.Background(), path); err != nil {
+		t.Fatalf("StateSeeder.SeedTest: %v", err)
+	}
+}
+
+func (s *StateSeeder) seed(ctx context.Context, source string, data []byte) error {
+	items, err := s.parse(source, data)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := s.create(ctx, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parse reads the items of a seed document in order and checks them.
+func (s *StateSeeder) parse(source string, data []byte) ([]seedItem, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse seed file %s: %w", source, err)
+	}
+	if len(root.Content) == 0 {
+		return nil, fmt.Errorf("seed file %s is empty", source)
+	}
+	doc := root.Content[0]
+	lines := make(map[string]int)
+	nodeLines(doc, "", lines)
+	report := &ValidationReport{}
+	add := func(path, msg string) {
+		report.addError(path, fmt.Sprintf("%s:%d: %s: %s", source, lineFor(lines, path), displayPath(path), msg))
+	}
+	if doc.Kind != yaml.MappingNode {
+		add("", "must map resource kinds to lists of resources")
+		return nil, report
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var items []seedItem
+	keys := make(map[string]bool)
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		kind, list := doc.Content[i].Value, doc.Content[i+1]
+		if _, ok := s.resources[kind]; !ok {
+			add(kind, "unknown resource kind")
+			continue
+		}
+		if list.Kind != yaml.SequenceNode {
+			add(kind, "must be a list of resources")
+			continue
+		}
+		for j, n := range list.Content {
+			path := fmt.Sprintf("%s[%d]", kind, j)
+			var entry struct {
+				Key  string    `yaml:"key"`
+				Body yaml.Node `yaml:"body"`
+			}
+			if err := n.Decode(&entry); err != nil {
+				add(path, err.Error())
+				continue
+			}
+			if entry.Body.Kind == 0 {
+				add(path, "missing body")
+				continue
+			}
+			body, err := jsonValue(&entry.Body)
+			if err != nil {
+				add(path+".body", err.Error())
+				continue
+			}
+			if entry.Key != "" {
+				id := kind + "/" + entry.Key
+				if _, dup := s.byKey[id]; dup || keys[id] {
+					add(path+".key", fmt.Sprintf("duplicate key %q", entry.Key))
+				}
+				keys[id] = true
+			}
+			walkSeedStrings(body, func(at, v string) {
+				if _, err := s.newTemplate().Parse(v); err != nil {
+					add(joinFieldPath(path+".body", at), err.Error())
+				}
+			}, "")
+			items = append(items, seedItem{kind: kind, index: j, key: entry.Key, body: body})
+		}
+	}
+	if report.HasErrors() {
+		return nil, report
+	}
+	return items, nil
+}
+
+// create renders and POSTs one item and records what was created.
+func (s *StateSeeder) create(ctx context.Context, item seedItem) error {
+	s.mu.Lock()
+	res := s.resources[item.kind]
+	s.mu.Unlock()
+	fail := func(err error) error {
+		return &SeedError{Kind: item.kind, Index: item.index, Key: item.key, Path: res.Path, Err: err}
+	}
+
+	body, err := s.render(item.body)
+	if err != nil {
+		return fail(err)
+	}
+	resp, err := s.client.Post(ctx, res.Path, body)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fail(fmt.Errorf("read response: %w", err))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &SeedError{Kind: item.kind, Index: item.index, Key: item.key, Path: res.Path,
+			Status: resp.StatusCode, Body: string(bytes.TrimSpace(data))}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep large numeric IDs exact
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return fail(fmt.Errorf("decode response: %w", err))
+	}
+	id, ok := lookupJSONPath(doc, res.IDField)
+	if !ok || id == nil {
+		return fail(fmt.Errorf("no ID at %q in response: %s", res.IDField, bytes.TrimSpace(data)))
+	}
+	created := SeededResource{Kind: item.kind, Key: item.key, ID: fmt.Sprint(id)}
+	created.Response, _ = doc.(map[string]any)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = append(s.created, created)
+	if item.key != "" {
+		s.byKey[item.kind+"/"+item.key] = created
+	}
+	return nil
+}
+
+// render executes the templates in a copy of body.
+func (s *StateSeeder) render(body any) (any, error) {
+	switch v := body.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		tmpl, err := s.newTemplate().Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, struct{ TestID string }{s.testID}); err != nil {
+			return nil, err
+		}
+		return out.String(), nil
+	case map[string]any:
+		// Sorted, so random values are drawn in the same order every run.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make(map[string]any, len(v))
+		for _, k := range keys {
+			r, err := s.render(v[k])
+			if err != nil {
+				return nil, err
+			}
+			out[k] = r
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			r, err := s.render(e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = r
+		}
+		return out, nil
+	default:
+		return v, nil
+	}
+}
+
+func (s *StateSeeder) newTemplate() *template.Template {
+	return template.New("seed").Option("missingkey=error").Funcs(template.FuncMap{
+		"randEmail":  func() string { return s.gen.Email("") },
+		"randString": func(n int) string { return s.gen.String(n, CharsetLower) },
+		"randInt":    func(min, max int) int { return s.gen.Int(min, max) },
+		"uuid":       s.gen.ID,
+		"ref": func(kind, key string) (string, error) {
+			r, ok := s.lookup(kind, key)
+			if !ok {
+				return "", fmt.Errorf("no seeded %s %q", kind, key)
+			}
+			return r.ID, nil
+		},
+	})
+}
+
+// walkSeedStrings calls fn with the path and value of every string in v
+// that contains a template action.
+func walkSeedStrings(v any, fn func(path, s string), path string) {
+	switch v := v.(type) {
+	case string:
+		if strings.Contains(v, "{{") {
+			fn(path, v)
+		}
+	case map[string]any:
+		for k, e := range v {
+			walkSeedStrings(e, fn, joinFieldPath(path, k))
+		}
+	case []any:
+		for i, e := range v {
+			walkSeedStrings(e, fn, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// Lookup returns the resource seeded under key, or a zero SeededResource
+// if there is none.
+func (s *StateSeeder) Lookup(kind, key string) SeededResource {
+	r, _ := s.lookup(kind, key)
+	return r
+}
+
+func (s *StateSeeder) lookup(kind, key string) (SeededResource, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.byKey[kind+"/"+key]
+	return r, ok
+}
+
+// Created returns the seeded resources in creation order.
+func (s *StateSeeder) Created() []SeededResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SeededResource(nil), s.created...)
+}
+
+// Teardown deletes the seeded resources in reverse creation order. Kinds
+// without a DeletePath are skipped, and 404 counts as already deleted.
+// Every resource is attempted; failures are returned together.
+func (s *StateSeeder) Teardown(ctx context.Context) error {
+	s.mu.Lock()
+	created := s.created
+	s.created = nil
+	s.byKey = make(map[string]SeededResource)
+	s.mu.Unlock()
+
+	errs := NewCompositeError("teardown seeded state")
+	for i := len(created) - 1; i >= 0; i-- {
+		r := created[i]
+		s.mu.Lock()
+		res := s.resources[r.Kind]
+		s.mu.Unlock()
+		if res.DeletePath == "" {
+			continue
+		}
+		path := strings.ReplaceAll(res.DeletePath, "{id}", url.PathEscape(r.ID))
+		resp, err := s.client.Delete(ctx, path)
+		if err != nil {
+			errs.Add(fmt.Errorf("%s %q: %w", r.Kind, r.ID, err))
+			continue
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if (resp.StatusCode < 200 || resp.StatusCode >= 300) && resp.StatusCode != http.StatusNotFound {
+			errs.Add(fmt.Errorf("%s %q: DELETE %s returned %d: %s", r.Kind, r.ID, path, resp.StatusCode, bytes.TrimSpace(data)))
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package testutils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// seedAPI is an API that numbers created resources per path and logs
+// every request.
+type seedAPI struct {
+	mu     sync.Mutex
+	nextID map[string]int
+	log    []string
+	reject string // POST path answered 409
+}
+
+func (a *seedAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.log = append(a.log, strings.TrimSpace(fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body)))
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == a.reject:
+		http.Error(w, `{"error":"email already taken"}`, http.StatusConflict)
+	case r.Method == http.MethodPost:
+		a.nextID[r.URL.Path]++
+		fmt.Fprintf(w, `{"id": %d}`, a.nextID[r.URL.Path])
+	case r.Method == http.MethodDelete:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func newSeedTest(t *testing.T) (*StateSeeder, *seedAPI) {
+	t.Helper()
+	api := &seedAPI{nextID: make(map[string]int)}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	s := NewStateSeeder(NewHTTPTestClient(srv.URL),
+		WithSeedTestID("TestSeed"),
+		WithSeedGenerator(NewFixtureGenerator(RandomIntConfig{Seed: 42})))
+	s.Register("users", SeedResource{Path: "/users", DeletePath: "/users/{id}"})
+	s.Register("uploads", SeedResource{Path: "/uploads", DeletePath: "/uploads/{id}"})
+	s.Register("audit", SeedResource{Path: "/audit"}) // append-only: no teardown
+	return s, api
+}
+
+// TestStateSeeder_Golden checks the requests sent for testdata/seed/state.yaml:
+// rendered templates, file order for creation and reverse order for
+// teardown.
+func TestStateSeeder_Golden(t *testing.T) {
+	s, api := newSeedTest(t)
+	if err := s.SeedFile(context.Background(), "testdata/seed/state.yaml"); err != nil {
+		t.Fatal(err)
+	}
+	if id := s.Lookup("users", "bob").ID; id != "2" {
+		t.Errorf(`Lookup("users", "bob").ID = %q`, id)
+	}
+	if got := s.Lookup("uploads", "avatar"); got.ID != "1" || got.Response["id"] != json.Number("1") {
+		t.Errorf("unexpected upload %+v", got)
+	}
+	if got := s.Lookup("users", "carol"); got.ID != "" {
+		t.Errorf("expected a zero resource for an unknown key, got %+v", got)
+	}
+	if err := s.Teardown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := os.ReadFile("testdata/seed/state.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(api.log, "\n") + "\n"; got != string(want) {
+		t.Errorf("requests differ from testdata/seed/state.golden:\n%s", got)
+	}
+}
+
+func TestStateSeeder_PartialFailure(t *testing.T) {
+	s, api := newSeedTest(t)
+	api.reject = "/uploads"
+	err := s.SeedFile(context.Background(), "testdata/seed/state.yaml")
+	var se *SeedError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected a *SeedError, got %v", err)
+	}
+	if se.Kind != "uploads" || se.Key != "avatar" || se.Status != http.StatusConflict ||
+		!strings.Contains(err.Error(), "email already taken") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if n := len(s.Created()); n != 2 {
+		t.Errorf("expected the 2 users seeded before the failure, got %d", n)
+	}
+	if got := api.log[len(api.log)-1]; !strings.HasPrefix(got, "POST /uploads") {
+		t.Errorf("seeding went on after the failure: %s", got)
+	}
+
+	api.log = nil
+	if err := s.Teardown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(api.log, ",") != "DELETE /users/2,DELETE /users/1" {
+		t.Errorf("unexpected teardown %q", api.log)
+	}
+}
+
+func TestStateSeeder_Validation(t *testing.T) {
+	s, api := newSeedTest(t)
+	err := s.Seed(context.Background(), []byte(`users:
+  - key: alice
+    body: {name: "{{.TestID"}
+  - key: alice
+    body: {name: a}
+groups:
+  - body: {name: admins}
+uploads:
+  - key: x
+`))
+	var report *ValidationReport
+	if !errors.As(err, &report) {
+		t.Fatalf("expected a *ValidationReport, got %v", err)
+	}
+	for _, want := range []string{
+		"seed:3: users[0].body.name: template",
+		`seed:4: users[1].key: duplicate key "alice"`,
+		"seed:6: groups: unknown resource kind",
+		"seed:9: uploads[0]: missing body",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+	if len(api.log) != 0 {
+		t.Errorf("nothing should be sent for an invalid file, got %q", api.log)
+	}
+}
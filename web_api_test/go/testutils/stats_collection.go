@@ -0,0 +1,453 @@
+package testutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Number is the set of types NumberCollection can aggregate. time.Duration is
+// covered by ~int64.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// NumberCollection manages a collection of numbers with statistical operations
+type NumberCollection[T Number] struct {
+	mu     sync.RWMutex
+	values []T
+	sorted bool
+}
+
+// IntCollection is the int collection used throughout the package.
+type IntCollection = NumberCollection[int]
+
+// FloatCollection collects float64 samples such as ratios or scores.
+type FloatCollection = NumberCollection[float64]
+
+// NewNumberCollection creates a new collection holding a copy of values
+func NewNumberCollection[T Number](values ...T) *NumberCollection[T] {
+	// Create a copy to avoid external mutation
+	cpy := make([]T, len(values))
+	copy(cpy, values)
+	return &NumberCollection[T]{
+		values: cpy,
+		sorted: false,
+	}
+}
+
+// NewIntCollection creates a new integer collection
+func NewIntCollection(values ...int) *IntCollection {
+	return NewNumberCollection(values...)
+}
+
+// NewFloatCollection creates a new float64 collection
+func NewFloatCollection(values ...float64) *FloatCollection {
+	return NewNumberCollection(values...)
+}
+
+// Add adds values to the collection
+func (c *NumberCollection[T]) Add(values ...T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values = append(c.values, values...)
+	c.sorted = false
+}
+
+// Len returns the number of values
+func (c *NumberCollection[T]) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.values)
+}
+
+// Values returns a copy of the values
+func (c *NumberCollection[T]) Values() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	values := make([]T, len(c.values))
+	copy(values, c.values)
+	return values
+}
+
+// ensureSorted safely sorts the collection if it is not already sorted.
+// It handles upgrading the lock from read to write without race conditions.
+func (c *NumberCollection[T]) ensureSorted() {
+	c.mu.RLock()
+	if c.sorted {
+		c.mu.RUnlock()
+		return
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Double-check pattern
+	if !c.sorted {
+		sort.Slice(c.values, func(i, j int) bool { return c.values[i] < c.values[j] })
+		c.sorted = true
+	}
+}
+
+// Remove deletes every occurrence of the given values and returns how many
+// elements were removed.
+func (c *NumberCollection[T]) Remove(values ...T) int {
+	drop := make(map[T]struct{}, len(values))
+	for _, v := range values {
+		drop[v] = struct{}{}
+	}
+	return c.RemoveIf(func(v T) bool {
+		_, ok := drop[v]
+		return ok
+	})
+}
+
+// RemoveIf deletes the values matching predicate and returns how many were
+// removed. The predicate runs under the collection's lock and must not call
+// back into it.
+func (c *NumberCollection[T]) RemoveIf(predicate func(T) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Filtering in place keeps the relative order, so sorted stays valid.
+	kept := c.values[:0]
+	for _, v := range c.values {
+		if !predicate(v) {
+			kept = append(kept, v)
+		}
+	}
+	removed := len(c.values) - len(kept)
+	c.values = kept
+	return removed
+}
+
+// Dedupe keeps the first occurrence of each value and returns how many
+// duplicates were removed.
+func (c *NumberCollection[T]) Dedupe() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	seen := make(map[T]struct{}, len(c.values))
+	kept := c.values[:0]
+	for _, v := range c.values {
+		if _, dup := seen[v]; dup {
+			continue
+		}
+		seen[v] = struct{}{}
+		kept = append(kept, v)
+	}
+	removed := len(c.values) - len(kept)
+	c.values = kept
+	return removed
+}
+
+// Intersect returns a new collection of the distinct values present in both
+// collections, in this collection's order.
+func (c *NumberCollection[T]) Intersect(other *NumberCollection[T]) *NumberCollection[T] {
+	in := other.set()
+	return c.distinct(func(v T) bool {
+		_, ok := in[v]
+		return ok
+	})
+}
+
+// Union returns a new collection of the distinct values present in either
+// collection: this collection's values first, then the other's.
+func (c *NumberCollection[T]) Union(other *NumberCollection[T]) *NumberCollection[T] {
+	var extra []T
+	if other != nil {
+		extra = other.Values()
+	}
+	union := c.distinct(func(T) bool { return true })
+	seen := union.set()
+	for _, v := range extra {
+		if _, ok := seen[v]; !ok {
+			seen[v] = struct{}{}
+			union.values = append(union.values, v)
+		}
+	}
+	return union
+}
+
+// Difference returns a new collection of the distinct values in this
+// collection that are not in other.
+func (c *NumberCollection[T]) Difference(other *NumberCollection[T]) *NumberCollection[T] {
+	exclude := other.set()
+	return c.distinct(func(v T) bool {
+		_, ok := exclude[v]
+		return !ok
+	})
+}
+
+// set returns the distinct values as a lookup map. A nil collection is empty.
+func (c *NumberCollection[T]) set() map[T]struct{} {
+	if c == nil {
+		return map[T]struct{}{}
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	s := make(map[T]struct{}, len(c.values))
+	for _, v := range c.values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// distinct returns the first occurrence of each value accepted by keep.
+// Callers take the other collection's snapshot before calling, so two
+// collection locks are never held at once.
+func (c *NumberCollection[T]) distinct(keep func(T) bool) *NumberCollection[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	seen := make(map[T]struct{}, len(c.values))
+	var out []T
+	for _, v := range c.values {
+		if _, dup := seen[v]; dup || !keep(v) {
+			continue
+		}
+		seen[v] = struct{}{}
+		out = append(out, v)
+	}
+	return NewNumberCollection(out...)
+}
+
+// Sum calculates the sum of all values
+func (c *NumberCollection[T]) Sum() T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sumLocked()
+}
+
+// Average calculates the average of all values
+func (c *NumberCollection[T]) Average() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.meanLocked()
+}
+
+// Mean is an alias for Average.
+func (c *NumberCollection[T]) Mean() float64 {
+	return c.Average()
+}
+
+// The *Locked helpers expect the caller to hold c.mu. Exported methods must
+// not call each other while holding the read lock: a second RLock blocks
+// behind a queued writer, which in turn waits for the first RLock.
+
+func (c *NumberCollection[T]) sumLocked() T {
+	var sum T
+	for _, v := range c.values {
+		sum += v
+	}
+	return sum
+}
+
+func (c *NumberCollection[T]) meanLocked() float64 {
+	if len(c.values) == 0 {
+		return 0
+	}
+	// Sum in float64 so small integer types cannot overflow.
+	var sum float64
+	for _, v := range c.values {
+		sum += float64(v)
+	}
+	return sum / float64(len(c.values))
+}
+
+func (c *NumberCollection[T]) varianceLocked() float64 {
+	if len(c.values) < 2 {
+		return 0
+	}
+	mean := c.meanLocked()
+	var sumSquares float64
+	for _, v := range c.values {
+		diff := float64(v) - mean
+		sumSquares += diff * diff
+	}
+	return sumSquares / float64(len(c.values))
+}
+
+// Median calculates the median value
+func (c *NumberCollection[T]) Median() float64 {
+	c.ensureSorted()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.values) == 0 {
+		return 0
+	}
+
+	if len(c.values)%2 == 1 {
+		return float64(c.values[len(c.values)/2])
+	}
+
+	middle := len(c.values) / 2
+	return (float64(c.values[middle-1]) + float64(c.values[middle])) / 2.0
+}
+
+// Mode calculates the mode (most frequent value)
+func (c *NumberCollection[T]) Mode() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.values) == 0 {
+		return nil
+	}
+
+	freq := make(map[T]int)
+	maxFreq := 0
+
+	for _, v := range c.values {
+		freq[v]++
+		if freq[v] > maxFreq {
+			maxFreq = freq[v]
+		}
+	}
+
+	var modes []T
+	for v, f := range freq {
+		if f == maxFreq {
+			modes = append(modes, v)
+		}
+	}
+
+	return modes
+}
+
+// Min returns the minimum value
+func (c *NumberCollection[T]) Min() (T, bool) {
+	c.ensureSorted()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return c.values[0], true
+}
+
+// Max returns the maximum value
+func (c *NumberCollection[T]) Max() (T, bool) {
+	c.ensureSorted()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return c.values[len(c.values)-1], true
+}
+
+// Range returns the range (max - min)
+func (c *NumberCollection[T]) Range() (T, bool) {
+	c.ensureSorted()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.values) == 0 {
+		var zero T
+		return zero, false
+	}
+	return c.values[len(c.values)-1] - c.values[0], true
+}
+
+// StandardDeviation calculates the population standard deviation
+func (c *NumberCollection[T]) StandardDeviation() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return math.Sqrt(c.varianceLocked())
+}
+
+// StdDev is an alias for StandardDeviation.
+func (c *NumberCollection[T]) StdDev() float64 {
+	return c.StandardDeviation()
+}
+
+// Variance calculates the population variance
+func (c *NumberCollection[T]) Variance() float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.varianceLocked()
+}
+
+// Percentile calculates the value at the given percentile (0-100)
+func (c *NumberCollection[T]) Percentile(p float64) (float64, error) {
+	if p < 0 || p > 100 {
+		return 0, fmt.Errorf("percentile must be between 0 and 100, got %f", p)
+	}
+
+	c.ensureSorted()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if len(c.values) == 0 {
+		return 0, errors.New("no values in collection")
+	}
+
+	if p == 0 {
+		return float64(c.values[0]), nil
+	}
+	if p == 100 {
+		return float64(c.values[len(c.values)-1]), nil
+	}
+
+	index := (p / 100) * float64(len(c.values)-1)
+	lower := int(math.Floor(index))
+	upper := int(math.Ceil(index))
+
+	if lower == upper {
+		return float64(c.values[lower]), nil
+	}
+
+	// Linear interpolation
+	lowerValue := float64(c.values[lower])
+	upperValue := float64(c.values[upper])
+	weight := index - float64(lower)
+
+	return lowerValue + (upperValue-lowerValue)*weight, nil
+}
+
+// Filter returns a new collection with values that match the predicate
+func (c *NumberCollection[T]) Filter(predicate func(T) bool) *NumberCollection[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var filtered []T
+	for _, v := range c.values {
+		if predicate(v) {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return NewNumberCollection(filtered...)
+}
+
+// Map applies a function to each value and returns a new collection
+func (c *NumberCollection[T]) Map(mapper func(T) T) *NumberCollection[T] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	mapped := make([]T, len(c.values))
+	for i, v := range c.values {
+		mapped[i] = mapper(v)
+	}
+
+	return NewNumberCollection(mapped...)
+}
+
+// JSON returns the collection as a JSON array
+func (c *NumberCollection[T]) JSON() ([]byte, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return json.Marshal(c.values)
+}
@@ -0,0 +1,115 @@
+package testutils
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestIntCollection_StatsAfterRemoval(t *testing.T) {
+	ic := NewIntCollection(4, 1000, 2, 8, -500, 6, 2)
+
+	// Sort first so removal has to keep the cached order valid.
+	if min, _ := ic.Min(); min != -500 {
+		t.Fatalf("expected min -500, got %d", min)
+	}
+	if n := ic.Remove(1000, -500, 42); n != 2 {
+		t.Fatalf("expected 2 values removed, got %d", n)
+	}
+	if n := ic.Dedupe(); n != 1 {
+		t.Fatalf("expected 1 duplicate removed, got %d", n)
+	}
+
+	if got := ic.Values(); !reflect.DeepEqual(got, []int{2, 4, 6, 8}) {
+		t.Errorf("unexpected values %v", got)
+	}
+	if ic.Sum() != 20 || ic.Average() != 5 || ic.Median() != 5 {
+		t.Errorf("sum/avg/median = %d/%v/%v, want 20/5/5", ic.Sum(), ic.Average(), ic.Median())
+	}
+	if v := ic.Variance(); v != 5 {
+		t.Errorf("expected variance 5, got %v", v)
+	}
+	if r, _ := ic.Range(); r != 6 {
+		t.Errorf("expected range 6, got %d", r)
+	}
+	if p, err := ic.Percentile(100); err != nil || p != 8 {
+		t.Errorf("expected p100 = 8, got %v (%v)", p, err)
+	}
+
+	if n := ic.RemoveIf(func(v int) bool { return v > 0 }); n != 4 {
+		t.Errorf("expected RemoveIf to drop 4 values, got %d", n)
+	}
+	if _, err := ic.Percentile(50); err == nil {
+		t.Error("expected an error for an empty collection")
+	}
+}
+
+func TestIntCollection_SetOperations(t *testing.T) {
+	a := NewIntCollection(1, 2, 2, 3, 4)
+	b := NewIntCollection(4, 3, 5, 5)
+
+	tests := []struct {
+		name string
+		got  *IntCollection
+		want []int
+	}{
+		{"intersect", a.Intersect(b), []int{3, 4}},
+		{"union", a.Union(b), []int{1, 2, 3, 4, 5}},
+		{"difference", a.Difference(b), []int{1, 2}},
+		{"self intersect", a.Intersect(a), []int{1, 2, 3, 4}},
+		{"nil other", a.Difference(nil), []int{1, 2, 3, 4}},
+	}
+	for _, tt := range tests {
+		if got := tt.got.Values(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if a.Len() != 5 || b.Len() != 4 {
+		t.Error("set operations modified their inputs")
+	}
+}
+
+func TestIntCollection_ConcurrentReadersAndWriters(t *testing.T) {
+	ic := NewIntCollection()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				ic.Add(i*1000 + j)
+				if j%50 == 0 {
+					ic.Remove(i*1000 + j)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				_ = ic.Average()
+				_ = ic.StandardDeviation()
+				_, _ = ic.Range()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := ic.Len(); got != 8*196 {
+		t.Errorf("expected %d values, got %d", 8*196, got)
+	}
+}
+
+func TestFloatCollection_Statistics(t *testing.T) {
+	fc := NewFloatCollection(0.5, 1.5, 2.5, 3.5)
+	if fc.Sum() != 8 || fc.Mean() != 2 || fc.Median() != 2 {
+		t.Errorf("sum/mean/median = %v/%v/%v, want 8/2/2", fc.Sum(), fc.Mean(), fc.Median())
+	}
+	if p, _ := fc.Percentile(50); p != 2 {
+		t.Errorf("expected p50 = 2, got %v", p)
+	}
+	if math.Abs(fc.StdDev()-math.Sqrt(1.25)) > 1e-9 {
+		t.Errorf("unexpected std dev %v", fc.StdDev())
+	}
+}
@@ -0,0 +1,85 @@
+package testutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Stopwatch measures laps against a Clock and summarises them.
+type Stopwatch struct {
+	mu       sync.Mutex
+	name     string
+	clock    Clock
+	started  time.Time
+	lapStart time.Time
+	laps     *DurationCollection
+}
+
+// NewStopwatch creates a stopwatch that starts immediately. A nil clock uses
+// the real clock.
+func NewStopwatch(name string, clock Clock) *Stopwatch {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	now := clock.Now()
+	return &Stopwatch{
+		name:     name,
+		clock:    clock,
+		started:  now,
+		lapStart: now,
+		laps:     NewDurationCollection(),
+	}
+}
+
+// Lap records the time since the previous lap (or the start) and returns it.
+func (s *Stopwatch) Lap() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	d := now.Sub(s.lapStart)
+	s.lapStart = now
+	s.laps.Add(d)
+	return d
+}
+
+// Time runs fn and records its duration as a lap.
+func (s *Stopwatch) Time(fn func()) time.Duration {
+	s.mu.Lock()
+	s.lapStart = s.clock.Now()
+	s.mu.Unlock()
+	fn()
+	return s.Lap()
+}
+
+// Reset discards the laps and restarts the stopwatch.
+func (s *Stopwatch) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started = s.clock.Now()
+	s.lapStart = s.started
+	s.laps = NewDurationCollection()
+}
+
+// StopwatchReport summarises a stopwatch's laps.
+type StopwatchReport struct {
+	Name    string        `json:"name"`
+	Elapsed time.Duration `json:"elapsed_ns"`
+	Laps    DurationStats `json:"laps"`
+}
+
+// String renders the report on one line.
+func (r StopwatchReport) String() string {
+	return fmt.Sprintf("%s: elapsed=%s %s", r.Name, HumanDuration(r.Elapsed), r.Laps)
+}
+
+// Report returns the elapsed time and lap statistics so far.
+func (s *Stopwatch) Report() StopwatchReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return StopwatchReport{
+		Name:    s.name,
+		Elapsed: s.clock.Now().Sub(s.started),
+		Laps:    NewDurationStats(s.laps),
+	}
+}
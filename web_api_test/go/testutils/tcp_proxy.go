@@ -0,0 +1,448 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// TCPProxy – a fault-injecting TCP forwarder
+// ------------------------------------------------------------------------
+
+// TCPProxy forwards TCP connections to an upstream address and lets a test
+// pause, sever, slow down or throttle the traffic while connections are
+// live. Put it between the code under test and a dependency to exercise
+// reconnect and retry logic:
+//
+//	proxy := NewTCPProxy("127.0.0.1:0", "127.0.0.1:5432")
+//	proxy.Start()
+//	defer proxy.Stop()
+//	dsn := "postgres://app@" + proxy.Addr() + "/app"
+type TCPProxy struct {
+	listenAddr string
+	upstream   string
+	dialer     net.Dialer
+
+	degradedLatency time.Duration
+	modeMgr         ModeManager
+
+	ln       net.Listener
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	done     chan struct{}
+
+	mu          sync.Mutex
+	conns       map[uint64]*proxyConn
+	history     []*proxyConn // every connection, including closed ones
+	nextID      uint64
+	paused      bool
+	resume      chan struct{} // closed by ResumeTraffic
+	offline     bool
+	latency     time.Duration
+	modeLatency time.Duration
+	bandwidth   int64
+}
+
+// TCPProxyOption configures a TCPProxy.
+type TCPProxyOption func(*TCPProxy)
+
+// WithProxyModeManager makes the proxy follow mgr: ModeOffline and
+// ModeMaintenance sever and refuse connections, ModeDegraded adds the
+// degraded latency on top of SetLatency, and any other mode clears both.
+func WithProxyModeManager(mgr ModeManager) TCPProxyOption {
+	return func(p *TCPProxy) { p.modeMgr = mgr }
+}
+
+// WithProxyDegradedLatency sets the latency ModeDegraded adds to each
+// chunk forwarded (default 50ms, as for the mode-aware wrappers).
+func WithProxyDegradedLatency(d time.Duration) TCPProxyOption {
+	return func(p *TCPProxy) { p.degradedLatency = d }
+}
+
+// WithProxyDialTimeout bounds each upstream dial (default 5s).
+func WithProxyDialTimeout(d time.Duration) TCPProxyOption {
+	return func(p *TCPProxy) { p.dialer.Timeout = d }
+}
+
+// NewTCPProxy creates a proxy that will listen on listenAddr, which may
+// use port 0, and forward to upstreamAddr once started.
+func NewTCPProxy(listenAddr, upstreamAddr string, opts ...TCPProxyOption) *TCPProxy {
+	p := &TCPProxy{
+		listenAddr:      listenAddr,
+		upstream:        upstreamAddr,
+		dialer:          net.Dialer{Timeout: 5 * time.Second},
+		degradedLatency: degradedDelay,
+		done:            make(chan struct{}),
+		conns:           make(map[uint64]*proxyConn),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Start begins listening and forwarding.
+func (p *TCPProxy) Start() error {
+	ln, err := net.Listen("tcp", p.listenAddr)
+	if err != nil {
+		return fmt.Errorf("proxy listen on %s: %w", p.listenAddr, err)
+	}
+	p.ln = ln
+
+	p.wg.Add(1)
+	go p.acceptLoop()
+	if p.modeMgr != nil {
+		watch := p.modeMgr.Watch()
+		p.wg.Add(1)
+		go p.followMode(watch)
+	}
+	return nil
+}
+
+// Addr returns the address clients should connect to.
+func (p *TCPProxy) Addr() string {
+	if p.ln == nil {
+		return p.listenAddr
+	}
+	return p.ln.Addr().String()
+}
+
+// Stop closes the listener and every connection and waits for the
+// forwarding goroutines to exit. It is idempotent.
+func (p *TCPProxy) Stop() error {
+	var err error
+	p.stopOnce.Do(func() {
+		close(p.done)
+		if p.ln != nil {
+			err = p.ln.Close()
+		}
+		p.DropConnections()
+		p.wg.Wait()
+	})
+	return err
+}
+
+// PauseTraffic stops forwarding in both directions without closing
+// anything. New connections are accepted but not connected upstream until
+// ResumeTraffic.
+func (p *TCPProxy) PauseTraffic() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		p.paused = true
+		p.resume = make(chan struct{})
+	}
+}
+
+// ResumeTraffic releases traffic held by PauseTraffic.
+func (p *TCPProxy) ResumeTraffic() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		p.paused = false
+		close(p.resume)
+	}
+}
+
+// DropConnections closes every active connection on both sides. The proxy
+// keeps accepting new ones.
+func (p *TCPProxy) DropConnections() {
+	p.mu.Lock()
+	conns := make([]*proxyConn, 0, len(p.conns))
+	for _, c := range p.conns {
+		conns = append(conns, c)
+	}
+	p.mu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// SetLatency delays each forwarded chunk by d in either direction.
+func (p *TCPProxy) SetLatency(d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.latency = d
+}
+
+// SetBandwidth limits each direction of each connection to bytesPerSec;
+// 0 removes the limit.
+func (p *TCPProxy) SetBandwidth(bytesPerSec int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.bandwidth = bytesPerSec
+}
+
+// ProxyConnStats is the traffic of one proxied connection.
+type ProxyConnStats struct {
+	ID        uint64
+	Client    string
+	BytesUp   int64 // client to upstream
+	BytesDown int64 // upstream to client
+	Opened    time.Time
+	Closed    bool
+}
+
+// Connections returns the stats of every connection accepted so far,
+// oldest first.
+func (p *TCPProxy) Connections() []ProxyConnStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ProxyConnStats, 0, len(p.history))
+	for _, c := range p.history {
+		out = append(out, c.stats())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// ActiveConnections returns the number of open connections.
+func (p *TCPProxy) ActiveConnections() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.conns)
+}
+
+func (p *TCPProxy) acceptLoop() {
+	defer p.wg.Done()
+	for {
+		client, err := p.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		p.mu.Lock()
+		if p.offline {
+			p.mu.Unlock()
+			client.Close()
+			continue
+		}
+		p.nextID++
+		c := &proxyConn{id: p.nextID, client: client, opened: time.Now(), closed: make(chan struct{})}
+		p.conns[c.id] = c
+		p.history = append(p.history, c)
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go p.serve(c)
+	}
+}
+
+func (p *TCPProxy) serve(c *proxyConn) {
+	defer p.wg.Done()
+	defer func() {
+		c.close()
+		p.mu.Lock()
+		delete(p.conns, c.id)
+		p.mu.Unlock()
+	}()
+
+	if !p.gate(c) {
+		return
+	}
+	upstream, err := p.dialer.Dial("tcp", p.upstream)
+	if err != nil {
+		return
+	}
+	if !c.setUpstream(upstream) {
+		upstream.Close()
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.pipe(c, upstream, c.client, &c.bytesUp)
+	}()
+	go func() {
+		defer wg.Done()
+		p.pipe(c, c.client, upstream, &c.bytesDown)
+	}()
+	wg.Wait()
+}
+
+// pipe copies src to dst, applying pause, latency and bandwidth to each
+// chunk. A clean EOF is passed on as a half-close; any other failure
+// closes the whole connection.
+func (p *TCPProxy) pipe(c *proxyConn, dst, src net.Conn, counter *atomic.Int64) {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := src.Read(p.chunk(buf))
+		if n > 0 {
+			if !p.gate(c) {
+				return
+			}
+			p.delay(c, n)
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				c.close()
+				return
+			}
+			counter.Add(int64(n))
+		}
+		if err == io.EOF {
+			if tc, ok := dst.(*net.TCPConn); ok {
+				tc.CloseWrite()
+				return
+			}
+		}
+		if err != nil {
+			c.close()
+			return
+		}
+	}
+}
+
+// chunk shrinks reads under a bandwidth limit so throttling stays smooth.
+func (p *TCPProxy) chunk(buf []byte) []byte {
+	p.mu.Lock()
+	bw := p.bandwidth
+	p.mu.Unlock()
+	if bw > 0 && int64(len(buf)) > bw/10+1 {
+		return buf[:bw/10+1]
+	}
+	return buf
+}
+
+// gate blocks while traffic is paused. It returns false once the
+// connection or the proxy is closed.
+func (p *TCPProxy) gate(c *proxyConn) bool {
+	for {
+		p.mu.Lock()
+		paused, resume := p.paused, p.resume
+		p.mu.Unlock()
+		if !paused {
+			return !c.isClosed()
+		}
+		select {
+		case <-resume:
+		case <-c.closed:
+			return false
+		case <-p.done:
+			return false
+		}
+	}
+}
+
+// delay sleeps for the configured latency plus the time n bytes take at
+// the bandwidth limit.
+func (p *TCPProxy) delay(c *proxyConn, n int) {
+	p.mu.Lock()
+	d := p.latency + p.modeLatency
+	if p.bandwidth > 0 {
+		d += time.Duration(int64(n) * int64(time.Second) / p.bandwidth)
+	}
+	p.mu.Unlock()
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-c.closed:
+	}
+}
+
+func (p *TCPProxy) followMode(watch <-chan Mode) {
+	defer p.wg.Done()
+	for {
+		select {
+		case mode, ok := <-watch:
+			if !ok {
+				return
+			}
+			p.applyMode(mode)
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *TCPProxy) applyMode(mode Mode) {
+	offline := mode == ModeOffline || mode == ModeMaintenance
+	p.mu.Lock()
+	p.offline = offline
+	p.modeLatency = 0
+	if mode == ModeDegraded {
+		p.modeLatency = p.degradedLatency
+	}
+	p.mu.Unlock()
+	if offline {
+		p.DropConnections()
+	}
+}
+
+// proxyConn is one client connection and its upstream.
+type proxyConn struct {
+	id     uint64
+	client net.Conn
+	opened time.Time
+
+	bytesUp   atomic.Int64
+	bytesDown atomic.Int64
+
+	mu        sync.Mutex
+	upstream  net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// setUpstream attaches the upstream connection, or reports false when the
+// connection was closed while dialing.
+func (c *proxyConn) setUpstream(u net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.isClosedLocked() {
+		return false
+	}
+	c.upstream = u
+	return true
+}
+
+func (c *proxyConn) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.isClosedLocked()
+}
+
+func (c *proxyConn) isClosedLocked() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *proxyConn) close() {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		close(c.closed)
+		upstream := c.upstream
+		c.mu.Unlock()
+		c.client.Close()
+		if upstream != nil {
+			upstream.Close()
+		}
+	})
+}
+
+func (c *proxyConn) stats() ProxyConnStats {
+	return ProxyConnStats{
+		ID:        c.id,
+		Client:    c.client.RemoteAddr().String(),
+		BytesUp:   c.bytesUp.Load(),
+		BytesDown: c.bytesDown.Load(),
+		Opened:    c.opened,
+		Closed:    c.isClosed(),
+	}
+}
@@ -0,0 +1,183 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startEchoServer returns the address of a TCP server that echoes input.
+func startEchoServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func startProxy(t *testing.T, upstream string, opts ...TCPProxyOption) *TCPProxy {
+	t.Helper()
+	p := NewTCPProxy("127.0.0.1:0", upstream, opts...)
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Stop() })
+	return p
+}
+
+func echoRoundTrip(conn net.Conn, msg string, timeout time.Duration) (string, error) {
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return "", err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, len(msg))
+	_, err := io.ReadFull(conn, buf)
+	return string(buf), err
+}
+
+func TestTCPProxy_ForwardsAndCounts(t *testing.T) {
+	p := startProxy(t, startEchoServer(t))
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if got, err := echoRoundTrip(conn, "hello", time.Second); err != nil || got != "hello" {
+		t.Fatalf("echo %q, %v", got, err)
+	}
+	stats := p.Connections()
+	if len(stats) != 1 || stats[0].BytesUp != 5 || stats[0].BytesDown != 5 || stats[0].Closed {
+		t.Errorf("stats %+v", stats)
+	}
+	if p.ActiveConnections() != 1 {
+		t.Errorf("active %d", p.ActiveConnections())
+	}
+}
+
+func TestTCPProxy_PauseResumeAndDrop(t *testing.T) {
+	p := startProxy(t, startEchoServer(t))
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	p.PauseTraffic()
+	var nerr net.Error
+	if _, err := echoRoundTrip(conn, "ping", 50*time.Millisecond); !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("traffic flowed while paused: %v", err)
+	}
+	p.ResumeTraffic()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("held data not delivered after resume: %q, %v", buf, err)
+	}
+
+	p.DropConnections()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("connection survived DropConnections")
+	}
+	if s := p.Connections(); !s[0].Closed {
+		t.Errorf("dropped connection not marked closed: %+v", s)
+	}
+}
+
+func TestTCPProxy_LatencyAndBandwidth(t *testing.T) {
+	p := startProxy(t, startEchoServer(t))
+	conn, err := net.Dial("tcp", p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	p.SetLatency(30 * time.Millisecond)
+	start := time.Now()
+	if _, err := echoRoundTrip(conn, "x", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 60*time.Millisecond {
+		t.Errorf("round trip %v, want at least 2x30ms latency", d)
+	}
+
+	p.SetLatency(0)
+	p.SetBandwidth(10000)
+	start = time.Now()
+	if _, err := echoRoundTrip(conn, strings.Repeat("y", 1000), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 150*time.Millisecond {
+		t.Errorf("1000 bytes each way at 10kB/s took %v", d)
+	}
+}
+
+// TestTCPProxy_OutageFollowsMode puts the proxy between an HTTPTestClient and
+// a MockServer and checks that a client retrying through an offline window
+// recovers once the mode returns to normal.
+func TestTCPProxy_OutageFollowsMode(t *testing.T) {
+	outage := 2 * time.Second
+	if testing.Short() {
+		outage = 200 * time.Millisecond
+	}
+	ms := NewMockServerT(t)
+	ms.On("GET", "/ping").Respond(MockResponse{Status: 200, Body: []byte("pong")})
+	mgr := NewInMemoryModeManager(ModeNormal)
+	defer mgr.Close()
+	p := startProxy(t, strings.TrimPrefix(ms.URL, "http://"), WithProxyModeManager(mgr))
+	client := NewHTTPTestClient("http://" + p.Addr())
+	ctx := context.Background()
+
+	get := func(ctx context.Context) (bool, string, error) {
+		resp, err := client.Get(ctx, "/ping")
+		if err != nil {
+			return false, err.Error(), nil
+		}
+		resp.Body.Close()
+		return resp.StatusCode == 200, resp.Status, nil
+	}
+	if err := WaitFor(ctx, "first request", 10*time.Millisecond, get, WithWaitTimeout(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr.SetMode(ModeOffline)
+	down := time.Now()
+	time.AfterFunc(outage, func() { mgr.SetMode(ModeNormal) })
+
+	failures := 0
+	err := WaitFor(ctx, "recovery", 50*time.Millisecond, func(ctx context.Context) (bool, string, error) {
+		ok, detail, err := get(ctx)
+		if !ok {
+			failures++
+		}
+		return ok, detail, err
+	}, WithWaitTimeout(outage+5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(down); elapsed < outage-50*time.Millisecond {
+		t.Errorf("recovered after %v, before the %v outage ended", elapsed, outage)
+	}
+	if failures == 0 {
+		t.Error("no request failed during the outage")
+	}
+}
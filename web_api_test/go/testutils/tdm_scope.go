@@ -0,0 +1,235 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ------------------------------------------------------------------------
+// Per-test scoping for TestDataManager
+// ------------------------------------------------------------------------
+
+// Scope returns a manager for t rooted at a subdirectory named after
+// t.Name(), so subtests sharing a manager stop trampling each other's
+// files. Scoping a subtest from its parent test's scope nests the
+// directories ("TestUpload/large/gzip" becomes TestUpload/large/gzip);
+// scoping it from an unscoped manager creates the whole path at once.
+//
+// The child inherits the configuration, logger, disk and registered
+// fixtures, and its files count against this manager's MaxFiles. It is
+// removed by t.Cleanup, unless the test failed and KeepOnFailure is set:
+// then the directory is kept, logged, and left alone by later Cleanup
+// calls. Scope fails the test if the directory cannot be created.
+//
+//	func TestUpload(t *testing.T) {
+//		for _, tc := range cases {
+//			t.Run(tc.name, func(t *testing.T) {
+//				data := suiteData.Scope(t)
+//				path, _ := data.CreateTestFile("input.bin", tc.body)
+//				...
+//			})
+//		}
+//	}
+func (tdm *TestDataManager) Scope(t testing.TB) *TestDataManager {
+	t.Helper()
+	child, err := tdm.newScope(t.Name())
+	if err != nil {
+		t.Fatalf("TestDataManager.Scope: %v", err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() && child.config.KeepOnFailure {
+			child.retain()
+			t.Logf("test data of failed test kept in %s", child.testDir)
+			return
+		}
+		if err := child.Cleanup(); err != nil {
+			t.Errorf("TestDataManager.Scope: %v", err)
+		}
+	})
+	return child
+}
+
+// newScope creates the child manager for the test called name.
+func (tdm *TestDataManager) newScope(name string) (*TestDataManager, error) {
+	rel := name
+	if tdm.scopeName != "" {
+		rest, ok := strings.CutPrefix(name, tdm.scopeName+"/")
+		if name == tdm.scopeName {
+			return nil, kindErrorf(ErrValidation, "manager is already scoped to %q", name)
+		}
+		if ok {
+			rel = rest
+		}
+	}
+	parts := strings.Split(rel, "/")
+	for i, p := range parts {
+		parts[i] = sanitizeTestID(p)
+	}
+
+	tdm.mu.RLock()
+	child := &TestDataManager{
+		testDir:   filepath.Join(append([]string{tdm.testDir}, parts...)...),
+		logger:    tdm.logger,
+		config:    tdm.config,
+		events:    tdm.events,
+		fileOps:   tdm.fileOps,
+		disk:      tdm.disk,
+		parent:    tdm,
+		scopeName: name,
+	}
+	tdm.mu.RUnlock()
+
+	tdm.fixturesMu.Lock()
+	if len(tdm.fixtures) > 0 {
+		child.fixtures = make(map[string]fixtureDef, len(tdm.fixtures))
+		for k, v := range tdm.fixtures {
+			child.fixtures[k] = v
+		}
+	}
+	tdm.fixturesMu.Unlock()
+
+	tdm.scopeMu.Lock()
+	defer tdm.scopeMu.Unlock()
+	if tdm.cleaned {
+		return nil, errors.New("manager has been cleaned up")
+	}
+	if err := os.MkdirAll(child.testDir, child.config.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create test directory %q: %w", child.testDir, err)
+	}
+	if tdm.children == nil {
+		tdm.children = make(map[*TestDataManager]struct{})
+	}
+	tdm.children[child] = struct{}{}
+
+	tdm.logger.Debug("scoped test data directory created", map[string]any{
+		"test":      name,
+		"directory": child.testDir,
+	})
+	return child, nil
+}
+
+// root returns the manager the scope chain started from.
+func (tdm *TestDataManager) root() *TestDataManager {
+	for tdm.parent != nil {
+		tdm = tdm.parent
+	}
+	return tdm
+}
+
+// cleanupChildren cleans the scoped children that have not cleaned up
+// themselves, stopping their watchers before their directories go.
+func (tdm *TestDataManager) cleanupChildren() {
+	tdm.scopeMu.Lock()
+	children := make([]*TestDataManager, 0, len(tdm.children))
+	for c := range tdm.children {
+		children = append(children, c)
+	}
+	tdm.scopeMu.Unlock()
+
+	for _, c := range children {
+		if err := c.Cleanup(); err != nil {
+			tdm.logger.Warn("scoped test data cleanup failed", map[string]any{
+				"directory": c.testDir,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// detach marks tdm cleaned and forgets it in its parent.
+func (tdm *TestDataManager) detach() {
+	tdm.scopeMu.Lock()
+	tdm.cleaned = true
+	tdm.scopeMu.Unlock()
+	if p := tdm.parent; p != nil {
+		p.scopeMu.Lock()
+		delete(p.children, tdm)
+		p.scopeMu.Unlock()
+	}
+}
+
+// retain keeps tdm's directory for a failed test: its watchers stop and
+// Cleanup on any manager above it leaves the directory in place.
+func (tdm *TestDataManager) retain() {
+	tdm.cleanupChildren()
+	tdm.stopWatchers()
+	tdm.invalidateFixtures()
+
+	root := tdm.root()
+	root.scopeMu.Lock()
+	root.kept = append(root.kept, tdm.testDir)
+	root.scopeMu.Unlock()
+	tdm.detach()
+
+	tdm.logger.Warn("keeping test data of failed test", map[string]any{
+		"test":      tdm.scopeName,
+		"directory": tdm.testDir,
+	})
+}
+
+// removeTestDir removes the test directory except for directories kept by
+// retain.
+func (tdm *TestDataManager) removeTestDir() error {
+	root := tdm.root()
+	root.scopeMu.Lock()
+	var keep []string
+	for _, dir := range root.kept {
+		if dir == tdm.testDir || isWithinDir(tdm.testDir, dir) {
+			keep = append(keep, dir)
+		}
+	}
+	root.scopeMu.Unlock()
+	if len(keep) == 0 {
+		return os.RemoveAll(tdm.testDir)
+	}
+	return removeAllExcept(tdm.testDir, keep)
+}
+
+// removeAllExcept removes everything under dir except the keep
+// directories and their ancestors.
+func removeAllExcept(dir string, keep []string) error {
+	for _, k := range keep {
+		if k == dir {
+			return nil
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	errs := NewCompositeError("remove test data")
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		holdsKept := false
+		for _, k := range keep {
+			if k == path || isWithinDir(path, k) {
+				holdsKept = true
+				break
+			}
+		}
+		if holdsKept {
+			err = removeAllExcept(path, keep)
+		} else {
+			err = os.RemoveAll(path)
+		}
+		if err != nil {
+			errs.Add(err)
+		}
+	}
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// isWithinDir reports whether path is strictly inside dir.
+func isWithinDir(dir, path string) bool {
+	return strings.HasPrefix(filepath.Clean(path), filepath.Clean(dir)+string(os.PathSeparator))
+}
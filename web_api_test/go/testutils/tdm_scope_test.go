@@ -0,0 +1,127 @@
+package testutils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScope_NestsSubtestDirectories(t *testing.T) {
+	tdm := newTestManager(t, "scope", nil, nil)
+	var outer, inner string
+
+	t.Run("upload cases", func(t *testing.T) {
+		data := tdm.Scope(t)
+		outer = data.GetTestDir()
+		t.Run("large/gzip", func(t *testing.T) {
+			data := data.Scope(t)
+			inner = data.GetTestDir()
+			if _, err := data.CreateTestFile("input.bin", "x"); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if _, err := os.Stat(inner); !os.IsNotExist(err) {
+			t.Errorf("subtest directory %s not removed by its cleanup", inner)
+		}
+	})
+
+	want := filepath.Join(tdm.GetTestDir(), "TestScope_NestsSubtestDirectories", "upload_cases")
+	if outer != want {
+		t.Errorf("scope directory %s, want %s", outer, want)
+	}
+	if want = filepath.Join(outer, "large", "gzip"); inner != want {
+		t.Errorf("nested scope directory %s, want %s", inner, want)
+	}
+	if _, err := os.Stat(outer); !os.IsNotExist(err) {
+		t.Errorf("scope directory %s not removed", outer)
+	}
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScope_QuotaCountsAgainstParent(t *testing.T) {
+	tdm := newTestManager(t, "scope", nil, &TestDataManagerConfig{MaxFiles: 2})
+	if _, err := tdm.CreateTestFile("shared.txt", "x"); err != nil {
+		t.Fatal(err)
+	}
+	child, err := tdm.newScope("TestQuota/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := child.CreateTestFile("one.txt", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := child.CreateTestFile("two.txt", "x"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected the parent's file limit to apply, got %v", err)
+	}
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScope_KeepOnFailure(t *testing.T) {
+	tdm := newTestManager(t, "scope", nil, &TestDataManagerConfig{KeepOnFailure: true})
+	failed, err := tdm.newScope("TestUpload/failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passed, err := tdm.newScope("TestUpload/passed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keptFile, _ := failed.CreateTestFile("response.json", "{}")
+	if _, err := passed.CreateTestFile("response.json", "{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	// What Scope's t.Cleanup does for a failed test.
+	failed.retain()
+
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(keptFile); err != nil {
+		t.Errorf("data of the failed test was removed: %v", err)
+	}
+	if _, err := os.Stat(passed.GetTestDir()); !os.IsNotExist(err) {
+		t.Errorf("data of the passing test was kept")
+	}
+	if err := failed.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(keptFile); err != nil {
+		t.Errorf("Cleanup of a retained scope removed it: %v", err)
+	}
+}
+
+func TestScope_ParentCleanupAfterChildren(t *testing.T) {
+	tdm := newTestManager(t, "scope", nil, nil)
+	a, err := tdm.newScope("TestOrder/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := a.newScope("TestOrder/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tdm.Cleanup(); err != nil {
+		t.Fatalf("parent Cleanup after its children: %v", err)
+	}
+	if len(tdm.children) != 0 {
+		t.Errorf("cleaned children still registered: %d", len(tdm.children))
+	}
+	if _, err := tdm.newScope("TestOrder/late"); err == nil {
+		t.Error("expected an error scoping a cleaned manager")
+	}
+	if _, err := a.newScope("TestOrder/a"); err == nil {
+		t.Error("expected an error scoping a test to itself")
+	}
+}
@@ -596,6 +596,28 @@ type TestDataManager struct {
 	testDir string
 	logger  Logger
 	config  TestDataManagerConfig
+	events  *EventBus
+	fileOps FileOperationsConfig // used for snapshot and restore copies
+	disk    Disk                 // nil writes test files with package os; see SetDisk
+
+	// Fixture registry (see fixture_registry.go)
+	fixturesMu    sync.Mutex
+	fixtures      map[string]fixtureDef
+	localFixtures *fixtureCache // used unless config.EnableCache selects the shared cache
+
+	// File watching (see file_watch.go)
+	watchMu   sync.Mutex
+	watchers  map[*dirWatcher]struct{}
+	ownMu     sync.Mutex
+	ownWrites map[string]fileStamp // files as the manager last wrote them
+
+	// Per-test scoping (see tdm_scope.go)
+	parent    *TestDataManager // set on managers returned by Scope
+	scopeName string           // t.Name() of the scoping test
+	scopeMu   sync.Mutex
+	children  map[*TestDataManager]struct{}
+	kept      []string // directories retained by KeepOnFailure; root only
+	cleaned   bool
 }
 
 // CleanupTransaction represents a snapshot state that can be restored.
@@ -608,20 +630,10 @@ type CleanupTransaction struct {
 // NewTestDataManager creates a new test data manager with atomic directory creation.
 func NewTestDataManager(testID string, logger Logger, config *TestDataManagerConfig) (*TestDataManager, error) {
 	if testID == "" {
-		return nil, errors.New("testID cannot be empty")
+		return nil, withKind(ErrValidation, errors.New("testID cannot be empty"))
 	}
 
-	// strict sanitization
-	cleanID := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
-			return r
-		}
-		return -1 // Drop invalid characters
-	}, testID)
-
-	if cleanID == "" {
-		cleanID = "unnamed-test"
-	}
+	cleanID := sanitizeTestID(testID)
 
 	cfg := TestDataManagerConfig{
 		TempDir:  os.TempDir(),
@@ -638,6 +650,10 @@ func NewTestDataManager(testID string, logger Logger, config *TestDataManagerCon
 		if config.DirMode != 0 {
 			cfg.DirMode = config.DirMode
 		}
+		cfg.EnableCache = config.EnableCache
+		cfg.MaxFileSize = config.MaxFileSize
+		cfg.MaxFiles = config.MaxFiles
+		cfg.KeepOnFailure = config.KeepOnFailure
 	}
 
 	testDir := filepath.Join(cfg.TempDir, "tests", cleanID)
@@ -671,9 +687,25 @@ func NewTestDataManager(testID string, logger Logger, config *TestDataManagerCon
 		testDir: testDir,
 		logger:  logger,
 		config:  cfg,
+		fileOps: FileOperationsConfig{PreservePermissions: true},
 	}, nil
 }
 
+// sanitizeTestID keeps the letters, digits, '-' and '_' of id, so it is
+// safe as a single directory name.
+func sanitizeTestID(id string) string {
+	clean := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			return r
+		}
+		return -1 // Drop invalid characters
+	}, id)
+	if clean == "" {
+		clean = "unnamed-test"
+	}
+	return clean
+}
+
 // Enhanced methods using integer utilities
 
 // CreateIntegerTestFiles creates test files with integer data using the logger's integer utilities
@@ -717,65 +749,6 @@ func (tdm *TestDataManager) GenerateAndCreateTestFiles(baseName string, count in
 	return nil, nil, errors.New("logger does not support integer utilities")
 }
 
-// AnalyzeTestFiles analyzes integer data in test files
-func (tdm *TestDataManager) AnalyzeTestFiles(pattern string) (*IntStats, error) {
-	files, err := filepath.Glob(filepath.Join(tdm.testDir, pattern))
-	if err != nil {
-		return nil, fmt.Errorf("failed to find files with pattern %q: %w", pattern, err)
-	}
-
-	var allInts []int
-	for _, file := range files {
-		content, err := os.ReadFile(file)
-		if err != nil {
-			continue // Skip unreadable files
-		}
-
-		// Simple integer extraction - could be enhanced
-		lines := strings.Split(string(content), "\n")
-		for _, line := range lines {
-			if strings.Contains(line, ":") {
-				parts := strings.Split(line, ":")
-				if len(parts) > 1 {
-					if val, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
-						allInts = append(allInts, val)
-					}
-				}
-			}
-		}
-	}
-
-	if logger, ok := tdm.logger.(*TestLogger); ok {
-		return logger.intUtils.Analyze(allInts), nil
-	}
-
-	// Fallback
-	if len(allInts) == 0 {
-		return &IntStats{}, nil
-	}
-
-	// Simple analysis
-	stats := &IntStats{
-		Count: len(allInts),
-	}
-
-	for _, v := range allInts {
-		stats.Sum += v
-		if v < stats.Min || stats.Count == 0 {
-			stats.Min = v
-		}
-		if v > stats.Max {
-			stats.Max = v
-		}
-	}
-
-	if stats.Count > 0 {
-		stats.Mean = float64(stats.Sum) / float64(stats.Count)
-	}
-
-	return stats, nil
-}
-
 // Original TestDataManager methods
 
 // CreateTestFile creates a test file with atomic writes.
@@ -788,14 +761,12 @@ func (tdm *TestDataManager) CreateTestFileWithMode(filename, content string, mod
 	tdm.mu.RLock()
 	defer tdm.mu.RUnlock()
 
-	if filename == "" {
-		return "", errors.New("filename cannot be empty")
+	fullPath, err := tdm.resolvePath(filename)
+	if err != nil {
+		return "", err
 	}
-
-	// Secure path resolution (Zip Slip protection)
-	fullPath := filepath.Join(tdm.testDir, filename)
-	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(tdm.testDir)+string(os.PathSeparator)) {
-		return "", fmt.Errorf("invalid filename %q: path traversal out of test root attempted", filename)
+	if err := tdm.checkQuota(fullPath, int64(len(content)), 0); err != nil {
+		return "", err
 	}
 
 	tdm.logger.Debug("creating test file", map[string]any{
@@ -805,6 +776,13 @@ func (tdm *TestDataManager) CreateTestFileWithMode(filename, content string, mod
 		"mode":     mode,
 	})
 
+	if tdm.disk != nil {
+		if err := tdm.writeDiskFile(fullPath, []byte(content), mode); err != nil {
+			return "", err
+		}
+		return fullPath, nil
+	}
+
 	// Ensure parent directory exists
 	parentDir := filepath.Dir(fullPath)
 	if err := os.MkdirAll(parentDir, tdm.config.DirMode); err != nil {
@@ -821,10 +799,104 @@ func (tdm *TestDataManager) CreateTestFileWithMode(filename, content string, mod
 		os.Remove(tmpFile) // Best effort cleanup
 		return "", fmt.Errorf("failed to rename temporary file to %q: %w", fullPath, err)
 	}
+	tdm.noteOwnWrites(fullPath)
 
 	return fullPath, nil
 }
 
+// writeDiskFile is the temp-file-and-rename write of CreateTestFileWithMode
+// against tdm.disk. Callers hold tdm.mu.
+func (tdm *TestDataManager) writeDiskFile(fullPath string, data []byte, mode os.FileMode) error {
+	parentDir := filepath.Dir(fullPath)
+	if err := tdm.disk.MkdirAll(parentDir, tdm.config.DirMode); err != nil {
+		return fmt.Errorf("failed to create parent directory %q: %w", parentDir, err)
+	}
+
+	tmpFile := fullPath + ".tmp." + randomString()
+	f, err := tdm.disk.OpenFile(tmpFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	n, err := f.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		tdm.disk.Remove(tmpFile) // Best effort cleanup
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := tdm.disk.Rename(tmpFile, fullPath); err != nil {
+		tdm.disk.Remove(tmpFile) // Best effort cleanup
+		return fmt.Errorf("failed to rename temporary file to %q: %w", fullPath, err)
+	}
+	return nil
+}
+
+// resolvePath maps filename to a path inside the test directory, rejecting
+// names that would escape it (Zip Slip protection).
+func (tdm *TestDataManager) resolvePath(filename string) (string, error) {
+	if filename == "" {
+		return "", withKind(ErrValidation, errors.New("filename cannot be empty"))
+	}
+	fullPath := filepath.Join(tdm.testDir, filename)
+	if !strings.HasPrefix(filepath.Clean(fullPath), filepath.Clean(tdm.testDir)+string(os.PathSeparator)) {
+		return "", kindErrorf(ErrPathTraversal, "invalid filename %q: path traversal out of test root attempted", filename)
+	}
+	return fullPath, nil
+}
+
+// checkQuota enforces MaxFileSize and MaxFiles for writing size bytes to
+// fullPath. pending counts new files already promised elsewhere (a staged
+// batch) that are not yet on disk. Zero limits are unlimited. MaxFiles
+// counts every file under the root manager, scoped children included.
+func (tdm *TestDataManager) checkQuota(fullPath string, size int64, pending int) error {
+	if max := tdm.config.MaxFileSize; max > 0 && size > max {
+		return kindErrorf(ErrQuotaExceeded, "file %q is %d bytes, exceeding the %d byte limit", fullPath, size, max)
+	}
+	// Scoped managers share the quota of the manager they came from.
+	root := tdm.root()
+	if max := root.config.MaxFiles; max > 0 && tdm.disk == nil {
+		if _, err := os.Stat(fullPath); err == nil {
+			return nil // overwriting does not add a file
+		}
+		count, err := root.countFiles()
+		if err != nil {
+			return fmt.Errorf("failed to count test files: %w", err)
+		}
+		if count+pending+1 > max {
+			return kindErrorf(ErrQuotaExceeded, "file limit of %d reached in %q", max, root.testDir)
+		}
+	}
+	return nil
+}
+
+// countFiles counts the files in the test directory, ignoring staging
+// directories of open write batches.
+func (tdm *TestDataManager) countFiles() (int, error) {
+	count := 0
+	err := filepath.WalkDir(tdm.testDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if strings.HasPrefix(d.Name(), writeBatchPrefix) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
 // CreateJSONFile creates a test file with JSON content.
 func (tdm *TestDataManager) CreateJSONFile(filename string, data any) (string, error) {
 	jsonBytes, err := json.MarshalIndent(data, "", "  ")
@@ -865,20 +937,69 @@ func (tdm *TestDataManager) GetTestDir() string {
 	return tdm.testDir
 }
 
-// Cleanup removes the entire test directory.
+// SetEventBus makes the manager publish cleanup_done events to bus.
+func (tdm *TestDataManager) SetEventBus(bus *EventBus) {
+	tdm.mu.Lock()
+	defer tdm.mu.Unlock()
+	tdm.events = bus
+}
+
+// SetDisk routes CreateTestFile and the helpers built on it through d, so
+// failures such as a full MemDisk can be simulated without touching the
+// real file system. Paths keep the test directory as their prefix. MaxFiles
+// is not checked against d; give d its own limit (MemDisk.SetMaxFiles).
+func (tdm *TestDataManager) SetDisk(d Disk) {
+	tdm.mu.Lock()
+	defer tdm.mu.Unlock()
+	tdm.disk = d
+}
+
+// SetFileOperations sets how TransactionalCleanup snapshots and restores
+// the test directory. Skip rules apply to both the backup and the restore.
+func (tdm *TestDataManager) SetFileOperations(config FileOperationsConfig) {
+	tdm.mu.Lock()
+	defer tdm.mu.Unlock()
+	tdm.fileOps = config
+}
+
+// copyTree copies src to dst with the manager's file operations settings.
+func (tdm *TestDataManager) copyTree(src, dst string) error {
+	report, err := NewFileOps(tdm.fileOps, tdm.logger).CopyTree(context.Background(), src, dst)
+	if err != nil {
+		return err
+	}
+	if len(report.Skipped) > 0 {
+		tdm.logger.Warn("entries skipped while copying", map[string]any{
+			"source":  src,
+			"skipped": len(report.Skipped),
+		})
+	}
+	return nil
+}
+
+// Cleanup stops any WatchDir watchers and removes the entire test directory,
+// including those of scoped children that have not cleaned up themselves.
+// Directories retained by KeepOnFailure are left in place.
 func (tdm *TestDataManager) Cleanup() error {
+	tdm.cleanupChildren()
+	tdm.stopWatchers()
+
 	tdm.mu.Lock()
 	defer tdm.mu.Unlock()
 
+	tdm.invalidateFixtures()
+
 	tdm.logger.Info("cleaning up test data directory", map[string]any{
 		"directory": tdm.testDir,
 	})
 
 	// os.RemoveAll is sufficient. Iterating files individually is slower and unnecessary
 	// unless specific file locks prevent deletion, in which case RemoveAll returns the error anyway.
-	if err := os.RemoveAll(tdm.testDir); err != nil {
+	if err := tdm.removeTestDir(); err != nil {
 		// If it's already gone, that's fine
 		if os.IsNotExist(err) {
+			tdm.detach()
+			tdm.events.Emit(EventCleanupDone, "test_data", map[string]any{"directory": tdm.testDir})
 			return nil
 		}
 		tdm.logger.Error("cleanup failed", map[string]any{
@@ -891,6 +1012,8 @@ func (tdm *TestDataManager) Cleanup() error {
 	tdm.logger.Info("test data directory cleaned up successfully", map[string]any{
 		"directory": tdm.testDir,
 	})
+	tdm.detach()
+	tdm.events.Emit(EventCleanupDone, "test_data", map[string]any{"directory": tdm.testDir})
 
 	return nil
 }
@@ -912,7 +1035,7 @@ func (tdm *TestDataManager) TransactionalCleanup() (*CleanupTransaction, error)
 	// Ensure backup dir is clean
 	os.RemoveAll(backupDir)
 
-	if err := copyDir(tdm.testDir, backupDir); err != nil {
+	if err := tdm.copyTree(tdm.testDir, backupDir); err != nil {
 		return nil, fmt.Errorf("failed to create backup: %w", err)
 	}
 
@@ -961,7 +1084,7 @@ func (ct *CleanupTransaction) Rollback() error {
 	}
 
 	// 2. Restore from backup
-	if err := copyDir(ct.backupDir, ct.manager.testDir); err != nil {
+	if err := ct.manager.copyTree(ct.backupDir, ct.manager.testDir); err != nil {
 		return fmt.Errorf("failed to restore from backup: %w", err)
 	}
 
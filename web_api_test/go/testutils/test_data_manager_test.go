@@ -0,0 +1,21 @@
+package testutils
+
+import "testing"
+
+// newTestManager returns a TestDataManager rooted in t.TempDir(). A nil
+// logger discards, and cfg may be nil; its TempDir is always replaced.
+func newTestManager(t *testing.T, id string, logger Logger, cfg *TestDataManagerConfig) *TestDataManager {
+	t.Helper()
+	if logger == nil {
+		logger = noopLogger{}
+	}
+	if cfg == nil {
+		cfg = &TestDataManagerConfig{}
+	}
+	cfg.TempDir = t.TempDir()
+	tdm, err := NewTestDataManager(id, logger, cfg)
+	if err != nil {
+		t.Fatalf("NewTestDataManager failed: %v", err)
+	}
+	return tdm
+}
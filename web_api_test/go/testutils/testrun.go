@@ -0,0 +1,122 @@
+package testutils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"os"
+	"strconv"
+)
+
+// ------------------------------------------------------------------------
+// TestRun – run ID and master seed for reproducible randomness
+// ------------------------------------------------------------------------
+
+// TestSeedEnv names the environment variable that fixes the master seed.
+const TestSeedEnv = "TESTUTILS_SEED"
+
+// TestRun identifies one test run and owns its master seed. Components
+// never share a random stream: each derives its own seed from the master
+// seed and a component name, so adding randomness in one place does not
+// shift the values another component sees.
+//
+// Constructors accept a run through their WithXTestRun option (WithTestRun
+// for the logger). A nil *TestRun leaves them on their default seeding.
+type TestRun struct {
+	ID   string
+	Seed int64
+
+	// fixed is set when the seed came from the caller or TESTUTILS_SEED
+	// rather than from crypto/rand.
+	fixed bool
+}
+
+// NewTestRun creates a run with a fixed master seed. Two runs with the same
+// seed derive the same ID and child seeds.
+func NewTestRun(seed int64) *TestRun {
+	r := &TestRun{Seed: seed, fixed: true}
+	r.ID = fmt.Sprintf("%016x", uint64(r.ChildSeed("run-id")))
+	return r
+}
+
+// TestRunFromEnv creates a run seeded from TESTUTILS_SEED, or from
+// crypto/rand when it is unset. Either way the seed is recorded, so logging
+// it with LogStart is enough to replay a failing run.
+func TestRunFromEnv() (*TestRun, error) {
+	v := os.Getenv(TestSeedEnv)
+	if v == "" {
+		r := NewTestRun(cryptoSeed())
+		r.fixed = false
+		return r, nil
+	}
+	seed, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", TestSeedEnv, v, err)
+	}
+	return NewTestRun(seed), nil
+}
+
+// Reproducible reports whether the seed was chosen by the caller rather
+// than drawn at random.
+func (r *TestRun) Reproducible() bool {
+	return r != nil && r.fixed
+}
+
+// ChildSeed derives the seed for the named component. It is a pure
+// function of the master seed and name, and never returns 0 because 0
+// means "time-based" to the generator configs.
+func (r *TestRun) ChildSeed(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	seed := int64(splitMix64(uint64(r.Seed) ^ h.Sum64()))
+	if seed == 0 {
+		seed = 1
+	}
+	return seed
+}
+
+// Rand returns a new random source for the named component. The source is
+// not safe for concurrent use.
+func (r *TestRun) Rand(name string) *rand.Rand {
+	return rand.New(rand.NewSource(r.ChildSeed(name)))
+}
+
+// RerunHint returns the environment setting that replays this run.
+func (r *TestRun) RerunHint() string {
+	return fmt.Sprintf("%s=%d", TestSeedEnv, r.Seed)
+}
+
+// LogStart logs the run ID and seed at startup so a failure can be replayed.
+func (r *TestRun) LogStart(l *TestLogger) {
+	if r == nil || l == nil {
+		return
+	}
+	l.Info(fmt.Sprintf("test run %s seed %d (rerun with %s)", r.ID, r.Seed, r.RerunHint()), map[string]any{
+		"run_id":       r.ID,
+		"seed":         r.Seed,
+		"reproducible": r.fixed,
+	})
+}
+
+func (r *TestRun) String() string {
+	return fmt.Sprintf("run %s (seed %d)", r.ID, r.Seed)
+}
+
+// splitMix64 scrambles x so nearby master seeds give unrelated child seeds.
+func splitMix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// WithTestRun tags every entry with the run ID and seed.
+func WithTestRun(run *TestRun) LoggerOption {
+	return func(l *TestLogger) {
+		if run == nil {
+			return
+		}
+		l.fields["run_id"] = run.ID
+		l.fields["seed"] = run.Seed
+	}
+}
@@ -0,0 +1,96 @@
+package testutils
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seededScenario is what a test would generate from one run: fixtures, the
+// pass/fail pattern of a flaky component, and port-check jitter.
+type seededScenario struct {
+	RunID  string
+	Emails []string
+	Ints   []int
+	Flaky  []bool
+	Jitter []float64
+}
+
+func runSeededScenario(run *TestRun) seededScenario {
+	s := seededScenario{RunID: run.ID}
+
+	gen := NewFixtureGenerator(RandomIntConfig{}, WithFixtureTestRun(run),
+		WithFixtureClock(NewMockClock(time.Unix(0, 0))))
+	for i := 0; i < 5; i++ {
+		s.Emails = append(s.Emails, gen.Email("example.com"))
+		s.Ints = append(s.Ints, gen.Int(1, 1000))
+	}
+
+	cond := NewComponentConditioner(NewMockComponent("db"))
+	cond.SetErrorRate("Health", 0.5)
+	cond.SetTestRun(run)
+	for i := 0; i < 20; i++ {
+		_, err := cond.Health()
+		s.Flaky = append(s.Flaky, err != nil)
+	}
+
+	pc := NewPortChecker(nil, PortCheckerConfig{}, WithPortCheckerTestRun(run))
+	for i := 0; i < 5; i++ {
+		s.Jitter = append(s.Jitter, pc.jitterFraction())
+	}
+	return s
+}
+
+func TestTestRun_SameSeedReproduces(t *testing.T) {
+	first := runSeededScenario(NewTestRun(42))
+	second := runSeededScenario(NewTestRun(42))
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("same seed, different scenarios:\n%+v\n%+v", first, second)
+	}
+
+	other := runSeededScenario(NewTestRun(43))
+	if reflect.DeepEqual(first.Emails, other.Emails) || reflect.DeepEqual(first.Flaky, other.Flaky) || first.RunID == other.RunID {
+		t.Errorf("different seeds produced the same scenario: %+v", other)
+	}
+}
+
+func TestTestRun_ChildSeedsIndependent(t *testing.T) {
+	run := NewTestRun(7)
+	seen := map[int64]string{}
+	for _, name := range []string{"fixtures", "generators", "jitter", "flaky-modes", "run-id"} {
+		seed := run.ChildSeed(name)
+		if prev, dup := seen[seed]; dup || seed == 0 {
+			t.Errorf("child seed %d for %q collides with %q", seed, name, prev)
+		}
+		seen[seed] = name
+	}
+	if NewTestRun(8).ChildSeed("fixtures") == run.ChildSeed("fixtures") {
+		t.Error("adjacent master seeds gave the same child seed")
+	}
+}
+
+func TestTestRunFromEnv(t *testing.T) {
+	t.Setenv(TestSeedEnv, "12345")
+	run, err := TestRunFromEnv()
+	if err != nil || run.Seed != 12345 || !run.Reproducible() || run.ID != NewTestRun(12345).ID {
+		t.Fatalf("run %v, err %v", run, err)
+	}
+
+	var logs bytes.Buffer
+	run.LogStart(NewTestLogger("suite", &logs, WithTestRun(run)))
+	if out := logs.String(); !strings.Contains(out, "TESTUTILS_SEED=12345") || !strings.Contains(out, run.ID) {
+		t.Errorf("seed not logged: %s", out)
+	}
+
+	t.Setenv(TestSeedEnv, "")
+	if run, err := TestRunFromEnv(); err != nil || run.Reproducible() {
+		t.Errorf("unset seed: run %v, err %v", run, err)
+	}
+
+	t.Setenv(TestSeedEnv, "abc")
+	if _, err := TestRunFromEnv(); err == nil {
+		t.Error("invalid seed accepted")
+	}
+}
@@ -5,7 +5,10 @@ package testutils
 
 import (
     "context"
+    "errors"
+    "fmt"
     "sync"
+    "sync/atomic"
     "time"
 )
 
@@ -18,6 +21,9 @@ type SpanContext struct {
     TraceID string
     SpanID  string
     ParentID string
+    // Unsampled marks spans of a trace the sampler chose not to record.
+    // Children inherit it, and it travels in the traceparent flags.
+    Unsampled bool
 }
 
 // Span represents an individual operation within a trace.
@@ -140,6 +146,8 @@ func WithEndTime(t time.Time) SpanOption {
 type MockTracer struct {
     mu          sync.Mutex
     spans       []Span
+    index       map[string]int // SpanID -> first position in spans
+    orphans     []Span         // ended but never started through this tracer
     startFunc   func(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span)
     endFunc     func(span Span, opts ...SpanOption)
     flushFunc   func() error
@@ -213,12 +221,19 @@ func (m *MockTracer) StartSpan(ctx context.Context, name string, opts ...SpanOpt
     if !cfg.startTime.IsZero() {
         span.StartTime = cfg.startTime
     }
+    if m.index == nil {
+        m.index = make(map[string]int)
+    }
+    if _, ok := m.index[span.Context.SpanID]; !ok {
+        m.index[span.Context.SpanID] = len(m.spans)
+    }
     m.spans = append(m.spans, span)
     m.mu.Unlock()
     return ctx, span
 }
 
-// EndSpan records the call and delegates.
+// EndSpan records the call and delegates. Spans this tracer did not store
+// are kept in Orphans.
 func (m *MockTracer) EndSpan(span Span, opts ...SpanOption) {
     m.mu.Lock()
     m.endCalls++
@@ -229,35 +244,34 @@ func (m *MockTracer) EndSpan(span Span, opts ...SpanOption) {
         return
     }
     // Update the stored span with end time and options.
-    for i, s := range m.spans {
-        if s.Context.SpanID == span.Context.SpanID {
-            cfg := defaultSpanConfig()
-            for _, opt := range opts {
-                opt(cfg)
-            }
-            if !cfg.endTime.IsZero() {
-                span.EndTime = cfg.endTime
-            } else if span.EndTime.IsZero() {
-                span.EndTime = time.Now()
-            }
-            if cfg.status.Code != 0 || cfg.status.Message != "" {
-                span.Status = cfg.status
-            }
-            if len(cfg.tags) > 0 {
-                if span.Tags == nil {
-                    span.Tags = make(map[string]interface{})
-                }
-                for k, v := range cfg.tags {
-                    span.Tags[k] = v
-                }
-            }
-            if len(cfg.logs) > 0 {
-                span.Logs = append(span.Logs, cfg.logs...)
-            }
-            m.spans[i] = span
-            break
+    cfg := defaultSpanConfig()
+    for _, opt := range opts {
+        opt(cfg)
+    }
+    if !cfg.endTime.IsZero() {
+        span.EndTime = cfg.endTime
+    } else if span.EndTime.IsZero() {
+        span.EndTime = time.Now()
+    }
+    if cfg.status.Code != 0 || cfg.status.Message != "" {
+        span.Status = cfg.status
+    }
+    if len(cfg.tags) > 0 {
+        if span.Tags == nil {
+            span.Tags = make(map[string]interface{})
+        }
+        for k, v := range cfg.tags {
+            span.Tags[k] = v
         }
     }
+    if len(cfg.logs) > 0 {
+        span.Logs = append(span.Logs, cfg.logs...)
+    }
+    if i, ok := m.index[span.Context.SpanID]; ok {
+        m.spans[i] = span
+    } else {
+        m.orphans = append(m.orphans, span)
+    }
     m.mu.Unlock()
 }
 
@@ -296,6 +310,16 @@ func (m *MockTracer) Spans() []Span {
     return cp
 }
 
+// Orphans returns a copy of the spans passed to EndSpan that were never
+// recorded by StartSpan, such as spans from another tracer.
+func (m *MockTracer) Orphans() []Span {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    cp := make([]Span, len(m.orphans))
+    copy(cp, m.orphans)
+    return cp
+}
+
 // CallCounts returns the number of calls to each method.
 func (m *MockTracer) CallCounts() (start, end, flush, close int) {
     m.mu.Lock()
@@ -308,6 +332,8 @@ func (m *MockTracer) Reset() {
     m.mu.Lock()
     defer m.mu.Unlock()
     m.spans = nil
+    m.index = nil
+    m.orphans = nil
     m.startCalls = 0
     m.endCalls = 0
     m.flushCalls = 0
@@ -322,100 +348,151 @@ func (m *MockTracer) Reset() {
 // InMemoryTracer – a simple tracer that stores spans in memory.
 // --------------------------------------------------------------------
 
-// InMemoryTracer implements Tracer with in‑memory span storage.
+// InMemoryTracer implements Tracer with in‑memory span storage. Options
+// add sampling (WithSampler), a bound on retained spans (WithMaxSpans) and
+// per-span tag and log caps (WithSpanLimits) for high-volume tests.
 type InMemoryTracer struct {
-    mu       sync.Mutex
-    spans    []Span
-    idGen    func() string // for generating trace/span IDs
-}
-
-// NewInMemoryTracer creates a new tracer with a simple ID generator.
-func NewInMemoryTracer() *InMemoryTracer {
-    return &InMemoryTracer{
-        idGen: generateSimpleID,
+    mu         sync.Mutex
+    spans      []Span         // ring buffer once maxSpans is reached
+    head       int            // index of the oldest span when the ring is full
+    index      map[string]int // SpanID -> position in spans
+    orphans    []Span         // ended but not retained; capped at maxSpans
+    idGen      func() string  // for generating span IDs
+    traceIDGen func() string  // for generating trace IDs of new roots
+
+    sampler  TraceSampler
+    maxSpans int
+    maxTags  int
+    maxLogs  int
+    stats    TracerStats
+}
+
+// NewInMemoryTracer creates a new tracer with a simple ID generator. The
+// default IDs are W3C-sized hex (16 digits for spans, 32 for traces), so
+// they survive traceparent propagation unchanged.
+func NewInMemoryTracer(opts ...InMemoryTracerOption) *InMemoryTracer {
+    t := &InMemoryTracer{
+        idGen:      generateSimpleID,
+        traceIDGen: generateTraceID,
+    }
+    for _, opt := range opts {
+        opt(t)
     }
+    return t
 }
 
-// SetIDGen allows overriding the ID generator (useful for deterministic tests).
+// SetIDGen allows overriding the ID generator (useful for deterministic
+// tests). It is used for both trace and span IDs.
 func (t *InMemoryTracer) SetIDGen(fn func() string) {
     t.mu.Lock()
     defer t.mu.Unlock()
     t.idGen = fn
+    t.traceIDGen = fn
 }
 
-// StartSpan begins a new span.
+// StartSpan begins a new span. Root spans ask the sampler whether to record
+// the trace; children inherit their parent's decision. Unsampled spans are
+// returned with Context.Unsampled set but not stored.
 func (t *InMemoryTracer) StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
     t.mu.Lock()
     defer t.mu.Unlock()
-    traceID := t.idGen()
+    t.stats.Started++
     spanID := t.idGen()
-    parentID := ""
+    var traceID, parentID string
+    var unsampled bool
     // Extract parent from context if present (simplified; real tracer would use context propagation)
     if parent := spanFromContext(ctx); parent != nil {
         traceID = parent.Context.TraceID
         parentID = parent.Context.SpanID
+        unsampled = parent.Context.Unsampled
+    } else {
+        traceID = t.traceIDGen()
+        unsampled = t.sampler != nil && !t.sampler.ShouldSample(name)
     }
     span := Span{
         Context: SpanContext{
-            TraceID:  traceID,
-            SpanID:   spanID,
-            ParentID: parentID,
+            TraceID:   traceID,
+            SpanID:    spanID,
+            ParentID:  parentID,
+            Unsampled: unsampled,
         },
         Name:      name,
         StartTime: time.Now(),
         Tags:      make(map[string]interface{}),
     }
+    if unsampled {
+        return context.WithValue(ctx, spanContextKey{}, &span), span
+    }
     cfg := defaultSpanConfig()
     for _, opt := range opts {
         opt(cfg)
     }
-    for k, v := range cfg.tags {
-        span.Tags[k] = v
-    }
+    addTags(&span, cfg.tags, t.maxTags)
     if !cfg.startTime.IsZero() {
         span.StartTime = cfg.startTime
     }
-    if len(cfg.logs) > 0 {
-        span.Logs = append(span.Logs, cfg.logs...)
-    }
-    t.spans = append(t.spans, span)
+    addLogs(&span, cfg.logs, t.maxLogs)
+    t.store(span)
     // Return a new context containing the span.
     return context.WithValue(ctx, spanContextKey{}, &span), span
 }
 
-// EndSpan finishes a span.
+// store records span, evicting the oldest once maxSpans are held.
+func (t *InMemoryTracer) store(span Span) {
+    t.stats.Sampled++
+    if t.index == nil {
+        t.index = make(map[string]int)
+    }
+    if t.maxSpans <= 0 || len(t.spans) < t.maxSpans {
+        t.index[span.Context.SpanID] = len(t.spans)
+        t.spans = append(t.spans, span)
+        return
+    }
+    if old := t.spans[t.head].Context.SpanID; t.index[old] == t.head {
+        delete(t.index, old)
+    }
+    t.index[span.Context.SpanID] = t.head
+    t.spans[t.head] = span
+    t.head = (t.head + 1) % len(t.spans)
+    t.stats.Dropped++
+}
+
+// EndSpan finishes a span. Sampled spans that are not retained – started
+// by another tracer, or already evicted by WithMaxSpans – are recorded in
+// Orphans instead.
 func (t *InMemoryTracer) EndSpan(span Span, opts ...SpanOption) {
+    if span.Context.Unsampled {
+        return
+    }
     t.mu.Lock()
     defer t.mu.Unlock()
-    for i, s := range t.spans {
-        if s.Context.SpanID == span.Context.SpanID {
-            cfg := defaultSpanConfig()
-            for _, opt := range opts {
-                opt(cfg)
-            }
-            if !cfg.endTime.IsZero() {
-                span.EndTime = cfg.endTime
-            } else if span.EndTime.IsZero() {
-                span.EndTime = time.Now()
-            }
-            if cfg.status.Code != 0 || cfg.status.Message != "" {
-                span.Status = cfg.status
-            }
-            if len(cfg.tags) > 0 {
-                if span.Tags == nil {
-                    span.Tags = make(map[string]interface{})
-                }
-                for k, v := range cfg.tags {
-                    span.Tags[k] = v
-                }
-            }
-            if len(cfg.logs) > 0 {
-                span.Logs = append(span.Logs, cfg.logs...)
-            }
-            t.spans[i] = span
-            return
+    cfg := defaultSpanConfig()
+    for _, opt := range opts {
+        opt(cfg)
+    }
+    i, ok := t.index[span.Context.SpanID]
+    if ok {
+        // Start from the stored span so limits see what StartSpan kept.
+        span.Tags, span.Logs = t.spans[i].Tags, t.spans[i].Logs
+    }
+    if !cfg.endTime.IsZero() {
+        span.EndTime = cfg.endTime
+    } else if span.EndTime.IsZero() {
+        span.EndTime = time.Now()
+    }
+    if cfg.status.Code != 0 || cfg.status.Message != "" {
+        span.Status = cfg.status
+    }
+    if !ok {
+        t.stats.Orphaned++
+        if t.maxSpans <= 0 || len(t.orphans) < t.maxSpans {
+            t.orphans = append(t.orphans, span)
         }
+        return
     }
+    addTags(&span, cfg.tags, t.maxTags)
+    addLogs(&span, cfg.logs, t.maxLogs)
+    t.spans[i] = span
 }
 
 // Flush is a no‑op for in‑memory tracer.
@@ -424,28 +501,54 @@ func (t *InMemoryTracer) Flush() error { return nil }
 // Close is a no‑op.
 func (t *InMemoryTracer) Close() error { return nil }
 
-// Spans returns a copy of all spans.
+// Spans returns a copy of all retained spans, oldest first.
 func (t *InMemoryTracer) Spans() []Span {
     t.mu.Lock()
     defer t.mu.Unlock()
-    cp := make([]Span, len(t.spans))
-    copy(cp, t.spans)
+    cp := make([]Span, 0, len(t.spans))
+    cp = append(cp, t.spans[t.head:]...)
+    return append(cp, t.spans[:t.head]...)
+}
+
+// Orphans returns a copy of the spans ended on this tracer that it was not
+// holding. With WithMaxSpans only the first maxSpans are kept; all are
+// counted in TracerStats.Orphaned.
+func (t *InMemoryTracer) Orphans() []Span {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    cp := make([]Span, len(t.orphans))
+    copy(cp, t.orphans)
     return cp
 }
 
-// Clear removes all spans.
+// Stats returns how many spans were started, recorded, evicted and orphaned.
+func (t *InMemoryTracer) Stats() TracerStats {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    return t.stats
+}
+
+// Clear removes all spans and orphans. Stats are kept.
 func (t *InMemoryTracer) Clear() {
     t.mu.Lock()
     defer t.mu.Unlock()
     t.spans = nil
+    t.index = nil
+    t.orphans = nil
+    t.head = 0
 }
 
-// generateSimpleID returns a simple string ID (for tests).
-var idCounter int64
+// idCounter numbers the IDs handed out by the default generators.
+var idCounter atomic.Uint64
 
+// generateSimpleID returns a sequential 16-digit hex span ID (for tests).
 func generateSimpleID() string {
-    idCounter++
-    return string(rune(idCounter)) // simplified; use strconv in real code
+    return fmt.Sprintf("%016x", idCounter.Add(1))
+}
+
+// generateTraceID returns a sequential 32-digit hex trace ID.
+func generateTraceID() string {
+    return fmt.Sprintf("%032x", idCounter.Add(1))
 }
 
 type spanContextKey struct{}
@@ -465,108 +568,56 @@ func spanFromContext(ctx context.Context) *Span {
 // TracerConditioner – wraps a Tracer to inject delays and errors.
 // --------------------------------------------------------------------
 
+// ErrTracerConditionerInjected is returned (wrapped) by probabilistic and
+// mode-driven Flush failures injected by a TracerConditioner.
+var ErrTracerConditionerInjected = errors.New("tracer conditioner: injected failure")
+
 // TracerConditioner adds configurable delays and error injection to a Tracer.
+// It is a typed front end to a CallConditioner keyed by the Tracer method
+// names; Conditions exposes the rest of its settings. StartSpan and EndSpan
+// cannot return errors, so failures injected into them are only recorded.
+// Its Tracer methods are generated into tracer_conditioner_gen.go.
+//
+//go:generate go run conditioner_gen.go -iface Tracer -type TracerConditioner -field tracer -passthrough Close
 type TracerConditioner struct {
-    mu            sync.Mutex
-    tracer        Tracer
-    startDelay    time.Duration
-    endDelay      time.Duration
-    flushDelay    time.Duration
-    startErrors   map[int]error
-    endErrors     map[int]error
-    flushErrors   map[int]error
-    startCalls    int
-    endCalls      int
-    flushCalls    int
+    tracer Tracer
+    cond   *CallConditioner
 }
 
 // NewTracerConditioner creates a conditioner around an existing Tracer.
 func NewTracerConditioner(tracer Tracer) *TracerConditioner {
     return &TracerConditioner{
-        tracer:      tracer,
-        startErrors: make(map[int]error),
-        endErrors:   make(map[int]error),
-        flushErrors: make(map[int]error),
+        tracer: tracer,
+        cond:   NewCallConditioner(ErrTracerConditionerInjected),
     }
 }
 
-// SetStartDelay adds a fixed delay before StartSpan.
-func (c *TracerConditioner) SetStartDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.startDelay = d
+// Conditions returns the underlying CallConditioner.
+func (c *TracerConditioner) Conditions() *CallConditioner {
+    return c.cond
 }
 
+// SetStartDelay adds a fixed delay before StartSpan.
+func (c *TracerConditioner) SetStartDelay(d time.Duration) { c.cond.SetDelay("StartSpan", d) }
+
 // SetEndDelay adds a fixed delay before EndSpan.
-func (c *TracerConditioner) SetEndDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.endDelay = d
-}
+func (c *TracerConditioner) SetEndDelay(d time.Duration) { c.cond.SetDelay("EndSpan", d) }
 
 // SetFlushDelay adds a fixed delay before Flush.
-func (c *TracerConditioner) SetFlushDelay(d time.Duration) {
-    c.mu.Lock()
-    defer c.mu.Unlock()
-    c.flushDelay = d
-}
-
-// InjectStartError makes the nth call to StartSpan return the given error.
-// Note: StartSpan doesn't return error normally; we'll simulate by returning
-// a zero span and passing error via a panic or return value? Better to not do that.
-// Instead, we'll make the conditioner return a sentinel error via a custom function.
-// Simpler: we'll not inject errors on StartSpan; we'll just add delay.
-func (c *TracerConditioner) InjectStartError(callNumber int, err error) {
-    // Not implemented – StartSpan doesn't return error.
-}
-
-// InjectEndError makes the nth call to EndSpan return an error (but EndSpan doesn't return error).
-// So we'll not implement either.
-
-// StartSpan adds delay then delegates.
-func (c *TracerConditioner) StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, Span) {
-    c.mu.Lock()
-    c.startCalls++
-    delay := c.startDelay
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.tracer.StartSpan(ctx, name, opts...)
-}
+func (c *TracerConditioner) SetFlushDelay(d time.Duration) { c.cond.SetDelay("Flush", d) }
 
-// EndSpan adds delay then delegates.
-func (c *TracerConditioner) EndSpan(span Span, opts ...SpanOption) {
-    c.mu.Lock()
-    c.endCalls++
-    delay := c.endDelay
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    c.tracer.EndSpan(span, opts...)
-}
+// SetClock replaces the clock used for delays (e.g. with a FakeClock).
+func (c *TracerConditioner) SetClock(clock Clock) { c.cond.SetClock(clock) }
 
-// Flush adds delay then delegates.
-func (c *TracerConditioner) Flush() error {
-    c.mu.Lock()
-    c.flushCalls++
-    delay := c.flushDelay
-    if err, ok := c.flushErrors[c.flushCalls]; ok {
-        delete(c.flushErrors, c.flushCalls)
-        c.mu.Unlock()
-        return err
-    }
-    c.mu.Unlock()
-    if delay > 0 {
-        time.Sleep(delay)
-    }
-    return c.tracer.Flush()
+// InjectStartError records an error for the nth call to StartSpan. StartSpan
+// cannot return it, so it only appears in Conditions().History().
+func (c *TracerConditioner) InjectStartError(callNumber int, err error) {
+    c.cond.InjectError("StartSpan", callNumber, err)
 }
 
-// Close delegates.
-func (c *TracerConditioner) Close() error {
-    return c.tracer.Close()
+// InjectFlushError makes the nth call to Flush return the given error.
+func (c *TracerConditioner) InjectFlushError(callNumber int, err error) {
+    c.cond.InjectError("Flush", callNumber, err)
 }
 
 // --------------------------------------------------------------------
@@ -624,4 +675,18 @@ func (a *TraceAssertions) AssertSpanHasTag(tracer interface{ Spans() []Span }, s
         }
     }
     a.t.Errorf("span with name %q not found", spanName)
-}
\ No newline at end of file
+}
+
+// AssertSpanChildOf asserts that child was started under parent: same
+// trace, and child's parent ID is parent's span ID. The spans may come from
+// different tracers, e.g. a client span and the server span it propagated to.
+func (a *TraceAssertions) AssertSpanChildOf(child, parent Span) {
+    if child.Context.TraceID != parent.Context.TraceID {
+        a.t.Errorf("span %q is in trace %q, expected trace %q of parent %q",
+            child.Name, child.Context.TraceID, parent.Context.TraceID, parent.Name)
+    }
+    if child.Context.ParentID != parent.Context.SpanID {
+        a.t.Errorf("span %q has parent %q, expected %q (%s)",
+            child.Name, child.Context.ParentID, parent.Context.SpanID, parent.Name)
+    }
+}
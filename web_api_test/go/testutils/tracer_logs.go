@@ -0,0 +1,156 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// --------------------------------------------------------------------
+// Span-to-log correlation
+// --------------------------------------------------------------------
+
+// SpanLogAdder is implemented by tracers that can attach a log to a span
+// that is still open. It reports false when the span is not held.
+type SpanLogAdder interface {
+	AddSpanLog(sc SpanContext, fields map[string]interface{}) bool
+}
+
+// LogCorrelationOptions configures CorrelateLogs. Each direction has its own
+// token bucket, so a burst of span errors cannot flood the log and a noisy
+// handler cannot bloat its span.
+type LogCorrelationOptions struct {
+	// Rate is the number of correlated entries allowed per second in each
+	// direction. Default 10.
+	Rate float64
+	// Burst is how many may be sent at once. Default 20.
+	Burst int
+	// Clock is the time source for the rate cap. Defaults to RealClock.
+	Clock Clock
+}
+
+// logCorrelation is the logger side of CorrelateLogs.
+type logCorrelation struct {
+	tracer  Tracer
+	limiter *KeyedRateLimiter
+}
+
+const (
+	spanLogsKey  = "span-logs"  // logger -> span
+	errorLogsKey = "error-logs" // span -> logger
+)
+
+// CorrelateLogs wires logger and tracer together; it is off unless called.
+// Afterwards WarnCtx and ErrorCtx also append a SpanLog to the span in ctx
+// (when tracer implements SpanLogAdder) and tag the entry with trace_id and
+// span_id. Spans ended through the returned Tracer with StatusError emit an
+// ERROR entry carrying the same IDs.
+//
+//	tracer := NewInMemoryTracer()
+//	app.Use(Tracing(CorrelateLogs(logger, tracer, LogCorrelationOptions{})))
+func CorrelateLogs(logger *TestLogger, tracer Tracer, opts LogCorrelationOptions) Tracer {
+	if opts.Rate <= 0 {
+		opts.Rate = 10
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = 20
+	}
+	c := &logCorrelation{
+		tracer:  tracer,
+		limiter: NewKeyedRateLimiter(RateLimitOptions{Rate: opts.Rate, Burst: opts.Burst, Clock: opts.Clock}),
+	}
+	logger.mu.Lock()
+	logger.correlation = c
+	logger.mu.Unlock()
+	return &correlatedTracer{Tracer: tracer, logger: logger, correlation: c}
+}
+
+// WarnCtx logs at WARN and, when correlated, records the entry on the span
+// in ctx.
+func (l *TestLogger) WarnCtx(ctx context.Context, msg string, fields map[string]any) {
+	l.log(WARN, msg, l.correlate(ctx, WARN, msg, fields))
+}
+
+// ErrorCtx logs at ERROR and, when correlated, records the entry on the
+// span in ctx.
+func (l *TestLogger) ErrorCtx(ctx context.Context, msg string, fields map[string]any) {
+	l.log(ERROR, msg, l.correlate(ctx, ERROR, msg, fields))
+}
+
+// correlate appends the entry to the active span and returns fields with the
+// span's IDs added.
+func (l *TestLogger) correlate(ctx context.Context, level LogLevel, msg string, fields map[string]any) map[string]any {
+	l.mu.RLock()
+	c := l.correlation
+	l.mu.RUnlock()
+	span := spanFromContext(ctx)
+	if c == nil || span == nil || level < l.Level() {
+		return fields
+	}
+	if adder, ok := c.tracer.(SpanLogAdder); ok && !span.Context.Unsampled && c.limiter.Allow(spanLogsKey).Allowed {
+		spanFields := map[string]interface{}{"level": level.String(), "message": msg}
+		for k, v := range fields {
+			spanFields[k] = v
+		}
+		adder.AddSpanLog(span.Context, spanFields)
+	}
+	out := make(map[string]any, len(fields)+2)
+	for k, v := range fields {
+		out[k] = v
+	}
+	out["trace_id"] = span.Context.TraceID
+	out["span_id"] = span.Context.SpanID
+	return out
+}
+
+// correlatedTracer logs spans that end with StatusError.
+type correlatedTracer struct {
+	Tracer
+	logger      *TestLogger
+	correlation *logCorrelation
+}
+
+func (t *correlatedTracer) EndSpan(span Span, opts ...SpanOption) {
+	t.Tracer.EndSpan(span, opts...)
+	cfg := defaultSpanConfig()
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	status := span.Status
+	if cfg.status.Code != 0 || cfg.status.Message != "" {
+		status = cfg.status
+	}
+	if status.Code != StatusError || !t.correlation.limiter.Allow(errorLogsKey).Allowed {
+		return
+	}
+	t.logger.Error(fmt.Sprintf("span %q failed: %s", span.Name, status.Message), map[string]any{
+		"trace_id": span.Context.TraceID,
+		"span_id":  span.Context.SpanID,
+		"span":     span.Name,
+	})
+}
+
+// AddSpanLog implements SpanLogAdder for spans this tracer retains, subject
+// to WithSpanLimits.
+func (t *InMemoryTracer) AddSpanLog(sc SpanContext, fields map[string]interface{}) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	i, ok := t.index[sc.SpanID]
+	if !ok {
+		return false
+	}
+	addLogs(&t.spans[i], []SpanLog{{Timestamp: time.Now(), Fields: fields}}, t.maxLogs)
+	return true
+}
+
+// AddSpanLog implements SpanLogAdder for spans recorded by StartSpan.
+func (m *MockTracer) AddSpanLog(sc SpanContext, fields map[string]interface{}) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.index[sc.SpanID]
+	if !ok {
+		return false
+	}
+	m.spans[i].Logs = append(m.spans[i].Logs, SpanLog{Timestamp: time.Now(), Fields: fields})
+	return true
+}
@@ -0,0 +1,84 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCorrelateLogs_WarningsBecomeSpanLogs(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewTestLogger("corr", &out)
+	tracer := NewInMemoryTracer()
+
+	app := NewApp()
+	app.Use(Tracing(CorrelateLogs(logger, tracer, LogCorrelationOptions{})))
+	app.Get("/work", func(ctx context.Context, req *Request) (*Response, error) {
+		for _, msg := range []string{"slow disk", "retrying", "cache miss"} {
+			logger.WarnCtx(ctx, msg, map[string]any{"attempt": 1})
+		}
+		return Text(http.StatusOK, "done")
+	})
+	app.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/work", nil))
+
+	span := spanNamed(t, tracer.Spans(), "GET /work")
+	if len(span.Logs) != 3 {
+		t.Fatalf("span has %d logs, want 3: %+v", len(span.Logs), span.Logs)
+	}
+	if f := span.Logs[1].Fields; f["message"] != "retrying" || f["level"] != "WARN" || f["attempt"] != 1 {
+		t.Errorf("span log fields = %v", f)
+	}
+	if n := strings.Count(out.String(), span.Context.SpanID); n != 3 {
+		t.Errorf("span ID appears in %d log lines, want 3:\n%s", n, out.String())
+	}
+}
+
+func TestCorrelateLogs_ErrorSpansAreLogged(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewTestLogger("corr", &out)
+	tracer := CorrelateLogs(logger, NewInMemoryTracer(), LogCorrelationOptions{})
+
+	_, ok := tracer.StartSpan(context.Background(), "ok")
+	tracer.EndSpan(ok)
+	_, bad := tracer.StartSpan(context.Background(), "charge")
+	tracer.EndSpan(bad, WithStatus(StatusError, "card declined"))
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "ERROR") || !strings.Contains(lines[0], "card declined") ||
+		!strings.Contains(lines[0], bad.Context.TraceID) || !strings.Contains(lines[0], bad.Context.SpanID) {
+		t.Errorf("log output:\n%s", out.String())
+	}
+}
+
+func TestCorrelateLogs_RateCappedAndOffByDefault(t *testing.T) {
+	clock := NewMockClock(time.Time{})
+	tracer := NewInMemoryTracer()
+	plain := NewTestLogger("plain", &bytes.Buffer{})
+	ctx, span := tracer.StartSpan(context.Background(), "op")
+	plain.WarnCtx(ctx, "not correlated", nil)
+	if got := spanNamed(t, tracer.Spans(), "op"); len(got.Logs) != 0 {
+		t.Fatalf("uncorrelated logger wrote %d span logs", len(got.Logs))
+	}
+
+	var out bytes.Buffer
+	logger := NewTestLogger("capped", &out)
+	wrapped := CorrelateLogs(logger, tracer, LogCorrelationOptions{Rate: 1, Burst: 2, Clock: clock})
+	for i := 0; i < 5; i++ {
+		logger.ErrorCtx(ctx, "loop", nil)
+	}
+	clock.Advance(time.Second)
+	logger.ErrorCtx(ctx, "later", nil)
+	wrapped.EndSpan(span)
+
+	got := spanNamed(t, tracer.Spans(), "op")
+	if len(got.Logs) != 3 || got.Logs[2].Fields["message"] != "later" {
+		t.Errorf("span logs = %+v, want 2 burst + 1 after refill", got.Logs)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 6 {
+		t.Errorf("logger wrote %d lines, want all 6", n)
+	}
+}
@@ -0,0 +1,167 @@
+package testutils
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// --------------------------------------------------------------------
+// Sampling and limits for InMemoryTracer
+// --------------------------------------------------------------------
+
+// TraceSampler decides whether a new trace is recorded. It is consulted only for
+// root spans; children follow their parent's decision, so a trace is either
+// recorded completely or not at all.
+type TraceSampler interface {
+	ShouldSample(name string) bool
+}
+
+// SampleRule overrides the sampling ratio for root spans named Name, or
+// starting with Name's prefix when it ends in "*".
+type SampleRule struct {
+	Name  string
+	Ratio float64
+}
+
+func (r SampleRule) matches(name string) bool {
+	if prefix, ok := strings.CutSuffix(r.Name, "*"); ok {
+		return strings.HasPrefix(name, prefix)
+	}
+	return r.Name == name
+}
+
+// RatioSampler records a fixed fraction of traces, drawing from a seeded
+// source so runs are reproducible. The first matching rule wins over the
+// default ratio.
+type RatioSampler struct {
+	mu    sync.Mutex
+	ratio float64
+	rules []SampleRule
+	rng   *rand.Rand
+}
+
+// NewRatioSampler samples ratio (0..1) of traces, except where a rule
+// matches the root span name.
+func NewRatioSampler(ratio float64, seed int64, rules ...SampleRule) *RatioSampler {
+	return &RatioSampler{ratio: ratio, rules: rules, rng: rand.New(rand.NewSource(seed))}
+}
+
+// ShouldSample implements TraceSampler.
+func (s *RatioSampler) ShouldSample(name string) bool {
+	ratio := s.ratio
+	for _, r := range s.rules {
+		if r.matches(name) {
+			ratio = r.Ratio
+			break
+		}
+	}
+	switch {
+	case ratio >= 1:
+		return true
+	case ratio <= 0:
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rng.Float64() < ratio
+}
+
+// TracerStats counts what an InMemoryTracer did with the spans it was asked
+// to start.
+type TracerStats struct {
+	Started  int64 `json:"started"`  // StartSpan calls
+	Sampled  int64 `json:"sampled"`  // spans recorded
+	Dropped  int64 `json:"dropped"`  // recorded spans evicted by WithMaxSpans
+	Orphaned int64 `json:"orphaned"` // EndSpan calls for spans not held
+}
+
+// InMemoryTracerOption configures an InMemoryTracer.
+type InMemoryTracerOption func(*InMemoryTracer)
+
+// WithSampler records only the traces s selects.
+func WithSampler(s TraceSampler) InMemoryTracerOption {
+	return func(t *InMemoryTracer) { t.sampler = s }
+}
+
+// WithMaxSpans keeps at most n spans, evicting the oldest first; evictions
+// are counted in TracerStats.Dropped.
+func WithMaxSpans(n int) InMemoryTracerOption {
+	return func(t *InMemoryTracer) { t.maxSpans = n }
+}
+
+// WithSpanLimits caps the tags and logs kept per span. Extra tags and logs
+// are discarded and counted in the span's DroppedTagsKey and DroppedLogsKey
+// tags; zero means unlimited.
+func WithSpanLimits(maxTags, maxLogs int) InMemoryTracerOption {
+	return func(t *InMemoryTracer) {
+		t.maxTags = maxTags
+		t.maxLogs = maxLogs
+	}
+}
+
+// Truncation markers set on spans that hit WithSpanLimits. They do not
+// count towards the tag limit.
+const (
+	DroppedTagsKey = "tracer.dropped_tags"
+	DroppedLogsKey = "tracer.dropped_logs"
+)
+
+// addTags merges tags into span, keeping at most maxTags keys. Existing keys
+// are always updated; new keys are admitted in sorted order so truncation is
+// deterministic.
+func addTags(span *Span, tags map[string]interface{}, maxTags int) {
+	if len(tags) == 0 {
+		return
+	}
+	if span.Tags == nil {
+		span.Tags = make(map[string]interface{})
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	dropped := 0
+	for _, k := range keys {
+		if _, exists := span.Tags[k]; !exists && maxTags > 0 && userTagCount(span.Tags) >= maxTags {
+			dropped++
+			continue
+		}
+		span.Tags[k] = tags[k]
+	}
+	if dropped > 0 {
+		n, _ := span.Tags[DroppedTagsKey].(int)
+		span.Tags[DroppedTagsKey] = n + dropped
+	}
+}
+
+func userTagCount(tags map[string]interface{}) int {
+	n := len(tags)
+	for _, k := range []string{DroppedTagsKey, DroppedLogsKey} {
+		if _, ok := tags[k]; ok {
+			n--
+		}
+	}
+	return n
+}
+
+// addLogs appends logs to span, keeping at most maxLogs.
+func addLogs(span *Span, logs []SpanLog, maxLogs int) {
+	if len(logs) == 0 {
+		return
+	}
+	keep := len(logs)
+	if maxLogs > 0 && len(span.Logs)+keep > maxLogs {
+		keep = max(maxLogs-len(span.Logs), 0)
+	}
+	span.Logs = append(span.Logs, logs[:keep]...)
+	if dropped := len(logs) - keep; dropped > 0 {
+		if span.Tags == nil {
+			span.Tags = make(map[string]interface{})
+		}
+		n, _ := span.Tags[DroppedLogsKey].(int)
+		span.Tags[DroppedLogsKey] = n + dropped
+	}
+}
@@ -0,0 +1,145 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestRatioSampler_RatioAccuracy(t *testing.T) {
+	const n, ratio = 20000, 0.25
+	tracer := NewInMemoryTracer(WithSampler(NewRatioSampler(ratio, 42)))
+	for i := 0; i < n; i++ {
+		_, span := tracer.StartSpan(context.Background(), "request")
+		tracer.EndSpan(span)
+	}
+	stats := tracer.Stats()
+	if stats.Started != n {
+		t.Fatalf("started %d, want %d", stats.Started, n)
+	}
+	// Binomial(n, ratio): allow four standard deviations.
+	sd := math.Sqrt(n * ratio * (1 - ratio))
+	if got := float64(stats.Sampled); math.Abs(got-n*ratio) > 4*sd {
+		t.Errorf("sampled %d of %d at ratio %.2f, want %.0f±%.0f", stats.Sampled, n, ratio, n*ratio, 4*sd)
+	}
+	if len(tracer.Spans()) != int(stats.Sampled) {
+		t.Errorf("retained %d spans, sampled %d", len(tracer.Spans()), stats.Sampled)
+	}
+
+	// The same seed makes the same decisions.
+	a, b := NewRatioSampler(ratio, 7), NewRatioSampler(ratio, 7)
+	for i := 0; i < 1000; i++ {
+		if a.ShouldSample("x") != b.ShouldSample("x") {
+			t.Fatalf("decision %d differs between equally seeded samplers", i)
+		}
+	}
+}
+
+func TestRatioSampler_RulesAndCompleteTraces(t *testing.T) {
+	sampler := NewRatioSampler(0.5, 1,
+		SampleRule{Name: "GET /health", Ratio: 0},
+		SampleRule{Name: "checkout*", Ratio: 1},
+	)
+	tracer := NewInMemoryTracer(WithSampler(sampler))
+	for i := 0; i < 200; i++ {
+		for _, root := range []string{"GET /health", "checkout.pay", "GET /users"} {
+			ctx, span := tracer.StartSpan(context.Background(), root)
+			cctx, child := tracer.StartSpan(ctx, root+"/db")
+			_, grandchild := tracer.StartSpan(cctx, root+"/db/row")
+			tracer.EndSpan(grandchild)
+			tracer.EndSpan(child)
+			tracer.EndSpan(span)
+		}
+	}
+
+	perTrace := make(map[string]int)
+	names := make(map[string]int)
+	for _, s := range tracer.Spans() {
+		perTrace[s.Context.TraceID]++
+		names[s.Name]++
+	}
+	for id, n := range perTrace {
+		if n != 3 {
+			t.Errorf("trace %s recorded %d of 3 spans", id, n)
+		}
+	}
+	if names["GET /health"] != 0 {
+		t.Errorf("health checks sampled %d times despite ratio 0", names["GET /health"])
+	}
+	if names["checkout.pay"] != 200 {
+		t.Errorf("checkout sampled %d of 200 times despite ratio 1", names["checkout.pay"])
+	}
+	if n := names["GET /users"]; n == 0 || n == 200 {
+		t.Errorf("users sampled %d of 200 times at ratio 0.5", n)
+	}
+}
+
+func TestInMemoryTracer_UnsampledPropagatesThroughTraceparent(t *testing.T) {
+	client := NewInMemoryTracer(WithSampler(NewRatioSampler(0, 1)))
+	ctx, span := client.StartSpan(context.Background(), "client")
+	if !span.Context.Unsampled {
+		t.Fatal("span of a ratio-0 sampler is sampled")
+	}
+	h := http.Header{}
+	InjectTraceparent(h, span.Context)
+	parent, ok := ExtractTraceparent(h)
+	if !ok || !parent.Unsampled {
+		t.Fatalf("traceparent %q lost the sampling decision", h.Get(TraceparentHeader))
+	}
+	server := NewInMemoryTracer()
+	_, child := server.StartSpan(ContextWithRemoteParent(ctx, parent), "server")
+	if !child.Context.Unsampled || len(server.Spans()) != 0 {
+		t.Errorf("server recorded a child of an unsampled trace: %+v", server.Spans())
+	}
+}
+
+func TestInMemoryTracer_MaxSpansRing(t *testing.T) {
+	tracer := NewInMemoryTracer(WithMaxSpans(3))
+	var last Span
+	for i := 0; i < 5; i++ {
+		_, last = tracer.StartSpan(context.Background(), fmt.Sprintf("op%d", i))
+	}
+	tracer.EndSpan(last, WithTag("done", true))
+
+	spans := tracer.Spans()
+	if len(spans) != 3 || spans[0].Name != "op2" || spans[2].Name != "op4" {
+		t.Fatalf("retained %v, want op2..op4 oldest first", spanNames(spans))
+	}
+	if spans[2].EndTime.IsZero() || spans[2].Tags["done"] != true {
+		t.Errorf("EndSpan on a ring slot did not update it: %+v", spans[2])
+	}
+	if st := tracer.Stats(); st != (TracerStats{Started: 5, Sampled: 5, Dropped: 2}) {
+		t.Errorf("stats = %+v", st)
+	}
+}
+
+func TestInMemoryTracer_SpanLimits(t *testing.T) {
+	tracer := NewInMemoryTracer(WithSpanLimits(2, 1))
+	_, span := tracer.StartSpan(context.Background(), "op",
+		WithTags(map[string]interface{}{"c": 3, "a": 1, "b": 2}),
+		WithLog(map[string]interface{}{"n": 1}),
+	)
+	tracer.EndSpan(span,
+		WithTag("a", 10), // existing keys may still be updated
+		WithTag("d", 4),
+		WithLog(map[string]interface{}{"n": 2}),
+		WithLog(map[string]interface{}{"n": 3}),
+	)
+	got := tracer.Spans()[0]
+	if got.Tags["a"] != 10 || got.Tags["b"] != 2 || got.Tags["c"] != nil || got.Tags["d"] != nil {
+		t.Errorf("tags = %v, want a and b only", got.Tags)
+	}
+	if got.Tags[DroppedTagsKey] != 2 || got.Tags[DroppedLogsKey] != 2 || len(got.Logs) != 1 {
+		t.Errorf("markers %v/%v with %d logs, want 2/2 with 1 log", got.Tags[DroppedTagsKey], got.Tags[DroppedLogsKey], len(got.Logs))
+	}
+}
+
+func spanNames(spans []Span) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name
+	}
+	return names
+}
@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestInMemoryTracer_EndSpanByID(t *testing.T) {
+	tracer := NewInMemoryTracer()
+	var spans []Span
+	for i := 0; i < 5; i++ {
+		_, s := tracer.StartSpan(context.Background(), fmt.Sprintf("op%d", i))
+		spans = append(spans, s)
+	}
+	// End out of start order; Spans() keeps start order.
+	for _, i := range []int{3, 0, 4, 1, 2} {
+		tracer.EndSpan(spans[i], WithTag("i", i))
+	}
+	for i, s := range tracer.Spans() {
+		if s.Name != fmt.Sprintf("op%d", i) || s.Tags["i"] != i || s.EndTime.IsZero() {
+			t.Errorf("span %d = %s %v, ended %v", i, s.Name, s.Tags, !s.EndTime.IsZero())
+		}
+	}
+	if len(tracer.Orphans()) != 0 {
+		t.Errorf("orphans = %v", tracer.Orphans())
+	}
+}
+
+func TestInMemoryTracer_Orphans(t *testing.T) {
+	other := NewInMemoryTracer()
+	_, foreign := other.StartSpan(context.Background(), "foreign")
+
+	tracer := NewInMemoryTracer(WithMaxSpans(1))
+	_, evicted := tracer.StartSpan(context.Background(), "evicted")
+	_, kept := tracer.StartSpan(context.Background(), "kept")
+	tracer.EndSpan(foreign, WithStatus(StatusError, "late"))
+	tracer.EndSpan(evicted)
+	tracer.EndSpan(kept)
+
+	unsampled := NewInMemoryTracer(WithSampler(NewRatioSampler(0, 1)))
+	_, skipped := unsampled.StartSpan(context.Background(), "skipped")
+	tracer.EndSpan(skipped)
+
+	orphans := tracer.Orphans()
+	if len(orphans) != 1 || orphans[0].Name != "foreign" || orphans[0].Status.Code != StatusError || orphans[0].EndTime.IsZero() {
+		t.Fatalf("orphans = %+v, want the foreign span only (capped at max spans)", orphans)
+	}
+	if got := tracer.Stats().Orphaned; got != 2 {
+		t.Errorf("Orphaned = %d, want 2", got)
+	}
+	if s := tracer.Spans(); len(s) != 1 || s[0].Name != "kept" || s[0].EndTime.IsZero() {
+		t.Errorf("spans = %+v", s)
+	}
+}
+
+func TestMockTracer_Orphans(t *testing.T) {
+	m := NewMockTracer()
+	_, s := m.StartSpan(context.Background(), "op")
+	m.EndSpan(s)
+	m.EndSpan(Span{Name: "stranger", Context: SpanContext{SpanID: "x"}})
+	if spans := m.Spans(); spans[0].EndTime.IsZero() {
+		t.Error("recorded span was not ended")
+	}
+	if o := m.Orphans(); len(o) != 1 || o[0].Name != "stranger" {
+		t.Errorf("orphans = %+v", o)
+	}
+	m.Reset()
+	if len(m.Orphans()) != 0 {
+		t.Error("Reset kept orphans")
+	}
+}
+
+// BenchmarkInMemoryTracer_EndSpan100k ends 100k open spans; with a linear
+// scan this grew quadratically.
+func BenchmarkInMemoryTracer_EndSpan100k(b *testing.B) {
+	const n = 100_000
+	for i := 0; i < b.N; i++ {
+		tracer := NewInMemoryTracer()
+		spans := make([]Span, n)
+		for j := range spans {
+			_, spans[j] = tracer.StartSpan(context.Background(), "op")
+		}
+		for j := len(spans) - 1; j >= 0; j-- {
+			tracer.EndSpan(spans[j])
+		}
+	}
+}
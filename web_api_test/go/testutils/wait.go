@@ -0,0 +1,174 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// WaitFor – condition polling that explains its timeouts
+// ------------------------------------------------------------------------
+
+// WaitCondition is polled by WaitFor. It reports whether the wait is over
+// and a short detail of what it saw ("status=starting", "connection
+// refused"). A non-nil error ends the wait at once; use it for failures
+// that retrying cannot fix.
+type WaitCondition func(ctx context.Context) (done bool, detail string, err error)
+
+// WaitOption configures WaitFor and WaitForValue.
+type WaitOption func(*waitConfig)
+
+type waitConfig struct {
+	clock   Clock
+	timeout time.Duration
+	history int
+}
+
+// WithWaitClock sets the clock used for the poll interval and timeout.
+func WithWaitClock(c Clock) WaitOption {
+	return func(w *waitConfig) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// WithWaitTimeout bounds the wait on the wait clock, in addition to any
+// deadline on the context.
+func WithWaitTimeout(d time.Duration) WaitOption {
+	return func(w *waitConfig) { w.timeout = d }
+}
+
+// WithWaitHistory sets how many distinct recent details a timeout error
+// includes (default 5).
+func WithWaitHistory(n int) WaitOption {
+	return func(w *waitConfig) { w.history = n }
+}
+
+// WaitError is returned when a wait ends before its condition held.
+type WaitError struct {
+	Desc     string
+	Attempts int
+	Elapsed  time.Duration
+	Details  []WaitAttempt // most recent last, repeats collapsed
+	Err      error         // context error, timeout, or the condition's error
+}
+
+// WaitAttempt is a detail reported by the condition, with the attempts
+// that reported it.
+type WaitAttempt struct {
+	First, Last int
+	Detail      string
+}
+
+func (e *WaitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "waiting for %s: %v after %v (%d attempts)",
+		e.Desc, e.Err, e.Elapsed.Round(time.Millisecond), e.Attempts)
+	if len(e.Details) > 0 {
+		b.WriteString("; last seen:")
+		for _, d := range e.Details {
+			if d.First == d.Last {
+				fmt.Fprintf(&b, "\n  #%d: %s", d.First, d.Detail)
+			} else {
+				fmt.Fprintf(&b, "\n  #%d-%d: %s", d.First, d.Last, d.Detail)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (e *WaitError) Unwrap() error { return e.Err }
+
+// errWaitTimeout is the WaitError cause when WithWaitTimeout expires. It
+// matches ErrTimeout.
+var errWaitTimeout = withKind(ErrTimeout, errors.New("timed out"))
+
+// WaitFor polls cond every interval, starting immediately, until it is
+// done, returns an error, or ctx ends. On failure the error lists what the
+// condition reported on its last attempts:
+//
+//	err := WaitFor(ctx, "postgres ready", 200*time.Millisecond,
+//		func(ctx context.Context) (bool, string, error) {
+//			out, err := probe(ctx)
+//			return err == nil, out, nil
+//		})
+func WaitFor(ctx context.Context, desc string, interval time.Duration, cond WaitCondition, opts ...WaitOption) error {
+	_, err := WaitForValue(ctx, desc, interval, func(ctx context.Context) (struct{}, bool, string, error) {
+		done, detail, err := cond(ctx)
+		return struct{}{}, done, detail, err
+	}, opts...)
+	return err
+}
+
+// WaitForValue is WaitFor for conditions that produce a value, such as the
+// response that finally reported healthy. The value from the successful
+// attempt is returned.
+func WaitForValue[T any](ctx context.Context, desc string, interval time.Duration,
+	cond func(ctx context.Context) (T, bool, string, error), opts ...WaitOption) (T, error) {
+	cfg := waitConfig{clock: RealClock{}, history: 5}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	start := cfg.clock.Now()
+	var timeout <-chan time.Time
+	if cfg.timeout > 0 {
+		timer := cfg.clock.NewTimer(cfg.timeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	var details []WaitAttempt
+	fail := func(attempts int, err error) (T, error) {
+		var zero T
+		return zero, &WaitError{
+			Desc:     desc,
+			Attempts: attempts,
+			Elapsed:  cfg.clock.Now().Sub(start),
+			Details:  details,
+			Err:      err,
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		v, done, detail, err := cond(ctx)
+		if done && err == nil {
+			return v, nil
+		}
+		if detail != "" {
+			details = recordWaitDetail(details, attempt, detail, cfg.history)
+		}
+		if err != nil {
+			return fail(attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fail(attempt, ctx.Err())
+		case <-timeout:
+			return fail(attempt, errWaitTimeout)
+		case <-cfg.clock.After(interval):
+		}
+	}
+}
+
+// recordWaitDetail appends detail, merging it into the previous entry when
+// it repeats, and keeps at most max entries.
+func recordWaitDetail(details []WaitAttempt, attempt int, detail string, max int) []WaitAttempt {
+	if n := len(details); n > 0 && details[n-1].Detail == detail {
+		details[n-1].Last = attempt
+		return details
+	}
+	details = append(details, WaitAttempt{First: attempt, Last: attempt, Detail: detail})
+	if max > 0 && len(details) > max {
+		details = details[len(details)-max:]
+	}
+	return details
+}
@@ -0,0 +1,75 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForValue_ReturnsValue(t *testing.T) {
+	calls := 0
+	v, err := WaitForValue(context.Background(), "counter", time.Millisecond,
+		func(context.Context) (int, bool, string, error) {
+			calls++
+			return calls * 10, calls == 3, "not yet", nil
+		})
+	if err != nil || v != 30 {
+		t.Fatalf("value %d, err %v", v, err)
+	}
+}
+
+func TestWaitFor_TimeoutShowsDetails(t *testing.T) {
+	calls := 0
+	err := WaitFor(context.Background(), "api health", time.Millisecond,
+		func(context.Context) (bool, string, error) {
+			calls++
+			if calls <= 3 {
+				return false, "connection refused", nil
+			}
+			return false, "status=starting", nil
+		}, WithWaitTimeout(30*time.Millisecond))
+
+	var werr *WaitError
+	if !errors.As(err, &werr) || !errors.Is(err, errWaitTimeout) {
+		t.Fatalf("err %v", err)
+	}
+	msg := err.Error()
+	for _, want := range []string{"waiting for api health", "#1-3: connection refused", "status=starting"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error missing %q:\n%s", want, msg)
+		}
+	}
+	if werr.Attempts != calls || len(werr.Details) != 2 {
+		t.Errorf("attempts %d (calls %d), details %v", werr.Attempts, calls, werr.Details)
+	}
+}
+
+func TestWaitFor_StopsOnConditionErrorAndContext(t *testing.T) {
+	fatal := errors.New("bad credentials")
+	err := WaitFor(context.Background(), "login", time.Millisecond,
+		func(context.Context) (bool, string, error) { return false, "401", fatal })
+	if !errors.Is(err, fatal) || !strings.Contains(err.Error(), "#1: 401") {
+		t.Errorf("err %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = WaitFor(ctx, "never", time.Millisecond,
+		func(context.Context) (bool, string, error) { return false, "", nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err %v", err)
+	}
+}
+
+func TestRecordWaitDetail_KeepsLastN(t *testing.T) {
+	var d []WaitAttempt
+	for i, s := range []string{"a", "a", "b", "c", "c", "d"} {
+		d = recordWaitDetail(d, i+1, s, 3)
+	}
+	want := []WaitAttempt{{3, 3, "b"}, {4, 5, "c"}, {6, 6, "d"}}
+	if len(d) != 3 || d[0] != want[0] || d[1] != want[1] || d[2] != want[2] {
+		t.Errorf("details %v, want %v", d, want)
+	}
+}
@@ -0,0 +1,302 @@
+package testutils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// Watchdog – deadlock detection for pool jobs and tracked goroutines
+// ------------------------------------------------------------------------
+
+// Watchdog tracks running tasks and reports those that run too long. Past
+// the warn threshold it logs a WARN with a goroutine dump; past the fail
+// threshold it fails the injected test. A nil or disabled Watchdog is a
+// no-op, so callers never need to check before tracking.
+type Watchdog struct {
+	warnAfter time.Duration
+	failAfter time.Duration
+	maxFrames int
+	interval  time.Duration
+	logger    *TestLogger
+	t         TestingT
+	clock     Clock
+
+	mu     sync.Mutex
+	tasks  map[uint64]*watchedTask
+	nextID uint64
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+type watchedTask struct {
+	label  string
+	start  time.Time
+	warned bool
+	failed bool
+}
+
+// WatchdogOption configures a Watchdog.
+type WatchdogOption func(*Watchdog)
+
+// WithWatchdogT fails t when a task exceeds the fail threshold.
+func WithWatchdogT(t TestingT) WatchdogOption {
+	return func(w *Watchdog) { w.t = t }
+}
+
+// WithWatchdogLogger sets the logger for stall warnings (default: stderr).
+func WithWatchdogLogger(l *TestLogger) WatchdogOption {
+	return func(w *Watchdog) { w.logger = l }
+}
+
+// WithWatchdogClock sets the clock used for task ages and the scan ticker.
+func WithWatchdogClock(c Clock) WatchdogOption {
+	return func(w *Watchdog) {
+		if c != nil {
+			w.clock = c
+		}
+	}
+}
+
+// WithWatchdogInterval sets how often running tasks are scanned. The
+// default is a quarter of the warn threshold, clamped to [10ms, 1s].
+func WithWatchdogInterval(d time.Duration) WatchdogOption {
+	return func(w *Watchdog) { w.interval = d }
+}
+
+// NewWatchdog builds a watchdog from cfg. It returns nil, the no-op
+// watchdog, unless cfg.EnableDeadlockDetection is set and MaxTaskDuration
+// is positive.
+func NewWatchdog(cfg ConcurrencyConfig, opts ...WatchdogOption) *Watchdog {
+	if !cfg.EnableDeadlockDetection || cfg.MaxTaskDuration <= 0 {
+		return nil
+	}
+	w := &Watchdog{
+		warnAfter: cfg.MaxTaskDuration,
+		failAfter: cfg.TaskFailDuration,
+		maxFrames: cfg.MaxStackDepth,
+		clock:     RealClock{},
+		tasks:     make(map[uint64]*watchedTask),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.logger == nil {
+		w.logger = NewTestLogger("watchdog", os.Stderr)
+	}
+	if w.interval <= 0 {
+		w.interval = w.warnAfter / 4
+		if w.interval < 10*time.Millisecond {
+			w.interval = 10 * time.Millisecond
+		}
+		if w.interval > time.Second {
+			w.interval = time.Second
+		}
+	}
+	return w
+}
+
+// Enabled reports whether the watchdog tracks anything.
+func (w *Watchdog) Enabled() bool { return w != nil }
+
+// Start launches the scan loop. It is idempotent; Track works without it
+// but nothing is reported until it runs.
+func (w *Watchdog) Start() {
+	if w == nil {
+		return
+	}
+	w.startOnce.Do(func() { go w.loop() })
+}
+
+// Stop ends the scan loop and waits for it to exit. It is idempotent and
+// safe to call without Start.
+func (w *Watchdog) Stop() {
+	if w == nil {
+		return
+	}
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		started := true
+		w.startOnce.Do(func() { started = false })
+		if started {
+			<-w.done
+		}
+	})
+}
+
+var noopDone = func() {}
+
+// Track registers a running task and returns the function that marks it
+// finished.
+func (w *Watchdog) Track(label string) (done func()) {
+	if w == nil {
+		return noopDone
+	}
+	w.mu.Lock()
+	w.nextID++
+	id := w.nextID
+	w.tasks[id] = &watchedTask{label: label, start: w.clock.Now()}
+	w.mu.Unlock()
+	return func() {
+		w.mu.Lock()
+		delete(w.tasks, id)
+		w.mu.Unlock()
+	}
+}
+
+// Wrap returns job tracked under label. With a nil watchdog job is
+// returned unchanged.
+func (w *Watchdog) Wrap(label string, job Job) Job {
+	if w == nil {
+		return job
+	}
+	return func() error {
+		defer w.Track(label)()
+		return job()
+	}
+}
+
+// TrackGoroutine runs fn in a new goroutine tracked under label.
+func (w *Watchdog) TrackGoroutine(label string, fn func()) {
+	done := w.Track(label)
+	go func() {
+		defer done()
+		fn()
+	}()
+}
+
+// Running returns the labels of the tracked tasks, oldest first.
+func (w *Watchdog) Running() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	tasks := make([]*watchedTask, 0, len(w.tasks))
+	for _, t := range w.tasks {
+		tasks = append(tasks, t)
+	}
+	w.mu.Unlock()
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].start.Before(tasks[j].start) })
+	labels := make([]string, len(tasks))
+	for i, t := range tasks {
+		labels[i] = t.label
+	}
+	return labels
+}
+
+func (w *Watchdog) loop() {
+	defer close(w.done)
+	defer markExpectedGoroutine()()
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			w.scan()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// stall is a task that crossed a threshold during one scan.
+type stall struct {
+	label   string
+	elapsed time.Duration
+	fail    bool
+}
+
+// scan reports tasks that crossed a threshold since the previous scan.
+// Each task is warned about and failed at most once.
+func (w *Watchdog) scan() {
+	now := w.clock.Now()
+	var stalls []stall
+	w.mu.Lock()
+	for _, t := range w.tasks {
+		elapsed := now.Sub(t.start)
+		switch {
+		case w.failAfter > 0 && elapsed >= w.failAfter && !t.failed:
+			t.failed, t.warned = true, true
+			stalls = append(stalls, stall{t.label, elapsed, true})
+		case elapsed >= w.warnAfter && !t.warned:
+			t.warned = true
+			stalls = append(stalls, stall{t.label, elapsed, false})
+		}
+	}
+	w.mu.Unlock()
+	if len(stalls) == 0 {
+		return
+	}
+
+	dump := goroutineDump(w.maxFrames)
+	for _, s := range stalls {
+		fields := map[string]any{
+			"label":      s.label,
+			"elapsed":    s.elapsed.String(),
+			"threshold":  w.warnAfter.String(),
+			"goroutines": dump,
+		}
+		if !s.fail {
+			w.logger.Warn("task exceeded max duration", fields)
+			continue
+		}
+		fields["threshold"] = w.failAfter.String()
+		w.logger.Error("task presumed deadlocked", fields)
+		if w.t != nil {
+			w.t.Errorf("watchdog: task %q still running after %v (limit %v)\n%s",
+				s.label, s.elapsed, w.failAfter, dump)
+		}
+	}
+}
+
+// goroutineDump returns the stacks of all goroutines, each cut to
+// maxFrames frames (0 keeps them whole).
+func goroutineDump(maxFrames int) string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		if len(buf) >= 64<<20 {
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return capStackFrames(buf, maxFrames)
+}
+
+// capStackFrames trims each goroutine in a runtime.Stack dump to
+// maxFrames frames. A frame is a function line followed by its file line.
+func capStackFrames(dump []byte, maxFrames int) string {
+	if maxFrames <= 0 {
+		return string(dump)
+	}
+	var out strings.Builder
+	for i, g := range bytes.Split(bytes.TrimSpace(dump), []byte("\n\n")) {
+		if i > 0 {
+			out.WriteString("\n\n")
+		}
+		lines := strings.Split(string(g), "\n")
+		keep := 1 + 2*maxFrames // header plus frames
+		if len(lines) <= keep {
+			out.WriteString(string(g))
+			continue
+		}
+		out.WriteString(strings.Join(lines[:keep], "\n"))
+		fmt.Fprintf(&out, "\n...%d more frames", (len(lines)-1)/2-maxFrames)
+	}
+	return out.String()
+}
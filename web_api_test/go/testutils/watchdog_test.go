@@ -0,0 +1,198 @@
+package testutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingT is a TestingT that keeps failures, logs and skips instead of
+// reporting them. Fatalf is recorded as a failure and also kept in fatal.
+type recordingT struct {
+	mu             sync.Mutex
+	errors         []string
+	logs           []string
+	skipped, fatal string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Error(args ...interface{}) { r.Errorf("%s", fmt.Sprint(args...)) }
+
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fatal = fmt.Sprintf(format, args...)
+	r.errors = append(r.errors, r.fatal)
+}
+
+func (r *recordingT) Logf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingT) Skipf(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.skipped = fmt.Sprintf(format, args...)
+}
+
+func (r *recordingT) failures() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.errors...)
+}
+
+// syncBuffer is a bytes.Buffer safe for the watchdog goroutine to write.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func watchdogConfig(warn, fail time.Duration) ConcurrencyConfig {
+	return ConcurrencyConfig{
+		EnableDeadlockDetection: true,
+		MaxTaskDuration:         warn,
+		TaskFailDuration:        fail,
+		MaxStackDepth:           4,
+	}
+}
+
+func TestWatchdog_DisabledIsNoop(t *testing.T) {
+	w := NewWatchdog(ConcurrencyConfig{MaxTaskDuration: time.Millisecond})
+	if w != nil || w.Enabled() {
+		t.Fatal("watchdog enabled without EnableDeadlockDetection")
+	}
+	w.Start()
+	w.Track("x")()
+	w.TrackGoroutine("y", func() {})
+	w.Stop()
+	if w.Running() != nil {
+		t.Error("disabled watchdog tracks tasks")
+	}
+}
+
+func TestWatchdog_WarnsThenFails(t *testing.T) {
+	var logs syncBuffer
+	rt := &recordingT{}
+	w := NewWatchdog(watchdogConfig(20*time.Millisecond, 60*time.Millisecond),
+		WithWatchdogT(rt),
+		WithWatchdogLogger(NewTestLogger("watchdog", &logs)),
+		WithWatchdogInterval(5*time.Millisecond))
+
+	pool := NewWorkerPool(2, 4)
+	pool.SetWatchdog(w)
+	pool.Start()
+
+	release := make(chan struct{})
+	pool.SubmitLabeled(context.Background(), "stuck-job", func() error {
+		<-release
+		return nil
+	})
+	pool.SubmitLabeled(context.Background(), "quick-job", func() error { return nil })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(rt.failures()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := w.Running(); len(got) != 1 || got[0] != "stuck-job" {
+		t.Errorf("running %v, want [stuck-job]", got)
+	}
+	close(release)
+	pool.Stop()
+
+	out := logs.String()
+	if !strings.Contains(out, "task exceeded max duration") || !strings.Contains(out, "stuck-job") {
+		t.Errorf("no warning for stuck job:\n%s", out)
+	}
+	if strings.Contains(out, "quick-job") {
+		t.Errorf("quick job reported:\n%s", out)
+	}
+	fails := rt.failures()
+	if len(fails) != 1 || !strings.Contains(fails[0], `"stuck-job"`) || !strings.Contains(fails[0], "goroutine") {
+		t.Errorf("failures %v", fails)
+	}
+}
+
+func TestWatchdog_TrackGoroutineAndStop(t *testing.T) {
+	var logs syncBuffer
+	w := NewWatchdog(watchdogConfig(10*time.Millisecond, 0),
+		WithWatchdogLogger(NewTestLogger("watchdog", &logs)),
+		WithWatchdogInterval(5*time.Millisecond))
+	w.Start()
+
+	release := make(chan struct{})
+	w.TrackGoroutine("consumer", func() { <-release })
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(logs.String(), "consumer") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(release)
+	w.Stop()
+	w.Stop()
+
+	if !strings.Contains(logs.String(), "consumer") {
+		t.Error("tracked goroutine never reported")
+	}
+}
+
+func TestCapStackFrames(t *testing.T) {
+	dump := []byte("goroutine 1 [running]:\n" +
+		"main.a()\n\t/a.go:1\nmain.b()\n\t/b.go:2\nmain.c()\n\t/c.go:3\n\n" +
+		"goroutine 2 [chan receive]:\nmain.d()\n\t/d.go:4\n")
+	got := capStackFrames(dump, 2)
+	if strings.Contains(got, "main.c()") || !strings.Contains(got, "...1 more frames") {
+		t.Errorf("goroutine 1 not capped:\n%s", got)
+	}
+	if !strings.Contains(got, "goroutine 2 [chan receive]:\nmain.d()") {
+		t.Errorf("goroutine 2 lost:\n%s", got)
+	}
+}
+
+func BenchmarkWorkerPool_Submit(b *testing.B) {
+	run := func(b *testing.B, w *Watchdog) {
+		pool := NewWorkerPool(4, 1024)
+		pool.SetWatchdog(w)
+		pool.Start()
+		var wg sync.WaitGroup
+		job := func() error { wg.Done(); return nil }
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			wg.Add(1)
+			pool.SubmitCtx(context.Background(), job)
+		}
+		wg.Wait()
+		b.StopTimer()
+		pool.Stop()
+	}
+	b.Run("watchdog=disabled", func(b *testing.B) {
+		run(b, NewWatchdog(ConcurrencyConfig{}))
+	})
+	b.Run("watchdog=enabled", func(b *testing.B) {
+		run(b, NewWatchdog(watchdogConfig(time.Minute, 0)))
+	})
+}
@@ -0,0 +1,558 @@
+package testutils
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// --------------------------------------------------------------------
+// WebSocket connections (RFC 6455)
+// --------------------------------------------------------------------
+
+// Message types accepted by WSConn.WriteMessage and returned by
+// ReadMessage. They are the RFC 6455 data frame opcodes.
+const (
+	WSText   = 1
+	WSBinary = 2
+)
+
+const (
+	wsOpContinuation = 0
+	wsOpClose        = 8
+	wsOpPing         = 9
+	wsOpPong         = 10
+)
+
+// Close codes from RFC 6455 section 7.4.1.
+const (
+	WSCloseNormal         = 1000
+	WSCloseGoingAway      = 1001
+	WSCloseProtocolError  = 1002
+	WSCloseNoStatus       = 1005 // received close frame had no code; never sent
+	WSCloseAbnormal       = 1006 // connection dropped without a close frame; never sent
+	WSCloseInvalidPayload = 1007
+	WSCloseMessageTooBig  = 1009
+	WSCloseInternalError  = 1011
+)
+
+// wsGUID is appended to the client key to derive Sec-WebSocket-Accept.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWSClosed is returned when writing after the close handshake started.
+var ErrWSClosed = errors.New("websocket: connection closed")
+
+// errWSPongTimeout ends a connection whose peer stopped answering pings.
+var errWSPongTimeout = errors.New("websocket: no pong within timeout")
+
+// WSCloseError is returned by reads once the connection is closed. Code is
+// the code from the peer's close frame, or WSCloseAbnormal when the
+// connection dropped without one.
+type WSCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WSCloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket: closed with code %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: closed with code %d: %s", e.Code, e.Reason)
+}
+
+// WSOption configures a WebSocket connection on either side.
+type WSOption func(*wsOptions)
+
+type wsOptions struct {
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	readLimit    int64
+	closeTimeout time.Duration
+	queueSize    int
+	header       map[string]string // client handshake only
+}
+
+func defaultWSOptions() wsOptions {
+	return wsOptions{
+		pingInterval: 30 * time.Second,
+		pongTimeout:  10 * time.Second,
+		readLimit:    1 << 20,
+		closeTimeout: 5 * time.Second,
+		queueSize:    16,
+	}
+}
+
+// WithWSPingInterval sets how often an idle connection is pinged; 0
+// disables keepalive.
+func WithWSPingInterval(d time.Duration) WSOption {
+	return func(o *wsOptions) { o.pingInterval = d }
+}
+
+// WithWSPongTimeout sets how long after a ping the peer may stay silent
+// before the connection is dropped.
+func WithWSPongTimeout(d time.Duration) WSOption {
+	return func(o *wsOptions) { o.pongTimeout = d }
+}
+
+// WithWSReadLimit caps the size of a received message; larger messages
+// close the connection with WSCloseMessageTooBig.
+func WithWSReadLimit(n int64) WSOption {
+	return func(o *wsOptions) { o.readLimit = n }
+}
+
+// WithWSCloseTimeout bounds how long Close waits for the peer to answer
+// the close frame.
+func WithWSCloseTimeout(d time.Duration) WSOption {
+	return func(o *wsOptions) { o.closeTimeout = d }
+}
+
+// WithWSHeader adds a header to the client's opening handshake.
+func WithWSHeader(key, value string) WSOption {
+	return func(o *wsOptions) {
+		if o.header == nil {
+			o.header = make(map[string]string)
+		}
+		o.header[key] = value
+	}
+}
+
+// wsMessage is a reassembled data message.
+type wsMessage struct {
+	typ  int
+	data []byte
+}
+
+// WSConn is an established WebSocket connection. A background reader
+// answers pings and close frames as they arrive, so a handler that only
+// writes still notices the peer going away. Reads and writes are safe for
+// concurrent use; messages are delivered in order to whichever reader
+// takes them.
+type WSConn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // clients mask their frames and expect unmasked ones
+	opts   wsOptions
+
+	messages chan wsMessage
+	done     chan struct{} // closed by shutdown
+	readErr  error         // why the connection ended; set before done closes
+
+	writeMu   sync.Mutex
+	closeSent bool // a close frame went out; guarded by writeMu
+	lastSeen  atomic.Int64
+	closeOnce sync.Once
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader, client bool, opts wsOptions) *WSConn {
+	c := &WSConn{
+		conn:     conn,
+		br:       br,
+		client:   client,
+		opts:     opts,
+		messages: make(chan wsMessage, opts.queueSize),
+		done:     make(chan struct{}),
+	}
+	c.lastSeen.Store(time.Now().UnixNano())
+	go c.readLoop()
+	if opts.pingInterval > 0 {
+		go c.keepalive()
+	}
+	return c
+}
+
+// RemoteAddr returns the peer's network address.
+func (c *WSConn) RemoteAddr() net.Addr { return c.conn.RemoteAddr() }
+
+// Done is closed once the connection has shut down, whichever side closed.
+func (c *WSConn) Done() <-chan struct{} { return c.done }
+
+// ReadMessage returns the next data message. After the connection closes
+// it returns a *WSCloseError, or the error that broke the connection.
+func (c *WSConn) ReadMessage(ctx context.Context) (int, []byte, error) {
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			return 0, nil, c.readErr
+		}
+		return msg.typ, msg.data, nil
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+}
+
+// ReadJSON reads the next message and decodes it into v.
+func (c *WSConn) ReadJSON(ctx context.Context, v any) error {
+	_, data, err := c.ReadMessage(ctx)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("websocket: failed to decode message: %w", err)
+	}
+	return nil
+}
+
+// WriteMessage sends data as a single frame of type typ (WSText or
+// WSBinary). If ctx ends first the connection is broken, since a partly
+// written frame cannot be recovered.
+func (c *WSConn) WriteMessage(ctx context.Context, typ int, data []byte) error {
+	if typ != WSText && typ != WSBinary {
+		return fmt.Errorf("websocket: invalid message type %d", typ)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closing() {
+		return ErrWSClosed
+	}
+	stop := context.AfterFunc(ctx, func() { c.conn.SetWriteDeadline(time.Now()) })
+	err := c.writeFrame(byte(typ), data)
+	if !stop() {
+		// ctx ended mid-write and left a past deadline behind; the frame
+		// may be cut short, so the connection cannot be used again.
+		c.shutdown(&WSCloseError{Code: WSCloseAbnormal, Reason: "write interrupted"})
+		if err != nil {
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// WriteJSON encodes v and sends it as a text message.
+func (c *WSConn) WriteJSON(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("websocket: failed to encode message: %w", err)
+	}
+	return c.WriteMessage(ctx, WSText, data)
+}
+
+// Ping sends a ping frame. The pong is handled by the read loop.
+func (c *WSConn) Ping(payload []byte) error {
+	return c.writeControl(wsOpPing, payload)
+}
+
+// Close performs a normal close handshake.
+func (c *WSConn) Close() error {
+	return c.CloseWithCode(WSCloseNormal, "")
+}
+
+// CloseWithCode sends a close frame and waits, up to the close timeout, for
+// the peer to answer before dropping the connection. Calling it on a
+// closed connection is a no-op.
+func (c *WSConn) CloseWithCode(code int, reason string) error {
+	if err := c.startClose(code, reason); err != nil {
+		return err
+	}
+	t := time.NewTimer(c.opts.closeTimeout)
+	defer t.Stop()
+	select {
+	case <-c.done:
+	case <-t.C:
+		c.shutdown(&WSCloseError{Code: WSCloseAbnormal, Reason: "close handshake timed out"})
+	}
+	return nil
+}
+
+// startClose sends the close frame without waiting for the answer. A
+// close already in progress is not an error.
+func (c *WSConn) startClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, truncateCloseReason(reason)...)
+
+	err := c.writeControl(wsOpClose, payload)
+	if err != nil && !errors.Is(err, ErrWSClosed) {
+		c.shutdown(err)
+		return err
+	}
+	return nil
+}
+
+// truncateCloseReason fits reason into a control frame (125 bytes, two of
+// them taken by the code) without splitting a rune.
+func truncateCloseReason(reason string) string {
+	if len(reason) <= 123 {
+		return reason
+	}
+	cut := 123
+	for cut > 0 && !utf8.RuneStart(reason[cut]) {
+		cut--
+	}
+	return reason[:cut]
+}
+
+func (c *WSConn) writeControl(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closing() {
+		return ErrWSClosed
+	}
+	if op == wsOpClose {
+		c.closeSent = true
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.closeTimeout))
+	defer c.conn.SetWriteDeadline(time.Time{})
+	return c.writeFrame(op, payload)
+}
+
+// closing reports whether a close frame was sent or the connection is
+// gone; the caller holds writeMu.
+func (c *WSConn) closing() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return c.closeSent
+	}
+}
+
+// writeFrame writes one final frame; the caller holds writeMu.
+func (c *WSConn) writeFrame(op byte, payload []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | op
+	n := len(payload)
+	switch {
+	case n <= 125:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+	if c.client {
+		header[1] |= 0x80
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		header = append(header, key[:]...)
+		masked := make([]byte, n)
+		for i := range payload {
+			masked[i] = payload[i] ^ key[i%4]
+		}
+		payload = masked
+	}
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readLoop reads frames until the connection ends, queueing data
+// messages and answering control frames.
+func (c *WSConn) readLoop() {
+	defer close(c.messages)
+	var msg *wsMessage
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			c.fail(err)
+			return
+		}
+		c.lastSeen.Store(time.Now().UnixNano())
+
+		switch op {
+		case wsOpPing:
+			c.writeControl(wsOpPong, payload)
+		case wsOpPong:
+		case wsOpClose:
+			c.handleClose(payload)
+			return
+		case WSText, WSBinary:
+			if msg != nil {
+				c.failProtocol("new message before previous one finished")
+				return
+			}
+			msg = &wsMessage{typ: int(op), data: payload}
+		case wsOpContinuation:
+			if msg == nil {
+				c.failProtocol("continuation frame without a message")
+				return
+			}
+			msg.data = append(msg.data, payload...)
+		default:
+			c.failProtocol(fmt.Sprintf("unknown opcode %d", op))
+			return
+		}
+
+		if msg != nil && int64(len(msg.data)) > c.opts.readLimit {
+			c.failWith(WSCloseMessageTooBig, "message too big")
+			return
+		}
+		if msg != nil && fin {
+			if msg.typ == WSText && !utf8.Valid(msg.data) {
+				c.failWith(WSCloseInvalidPayload, "invalid UTF-8")
+				return
+			}
+			select {
+			case c.messages <- *msg:
+			case <-c.done:
+				return
+			}
+			msg = nil
+		}
+	}
+}
+
+// readFrame reads one frame and unmasks its payload.
+func (c *WSConn) readFrame() (fin bool, op byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	op = head[0] & 0x0F
+	if head[0]&0x70 != 0 {
+		return fin, op, nil, errWSProtocol("reserved bits set")
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.client {
+		return fin, op, nil, errWSProtocol("unexpected masking")
+	}
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if op >= wsOpClose && (n > 125 || !fin) {
+		return fin, op, nil, errWSProtocol("invalid control frame")
+	}
+	if n > uint64(c.opts.readLimit) {
+		return fin, op, nil, &wsFailure{code: WSCloseMessageTooBig, reason: "message too big"}
+	}
+
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+// wsFailure is a protocol violation that ends the connection with code.
+type wsFailure struct {
+	code   int
+	reason string
+}
+
+func (e *wsFailure) Error() string { return "websocket: " + e.reason }
+
+func errWSProtocol(reason string) error {
+	return &wsFailure{code: WSCloseProtocolError, reason: reason}
+}
+
+// handleClose answers the peer's close frame, if we have not sent one, and
+// ends the connection with the peer's code.
+func (c *WSConn) handleClose(payload []byte) {
+	closeErr := &WSCloseError{Code: WSCloseNoStatus}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+	}
+	reply := payload
+	if len(reply) > 2 {
+		reply = reply[:2]
+	}
+	c.writeControl(wsOpClose, reply)
+	c.shutdown(closeErr)
+}
+
+func (c *WSConn) failProtocol(reason string) {
+	c.failWith(WSCloseProtocolError, reason)
+}
+
+func (c *WSConn) failWith(code int, reason string) {
+	c.fail(&wsFailure{code: code, reason: reason})
+}
+
+// fail ends the connection after a read error, sending a close frame with
+// the failure's code when the error was a protocol violation.
+func (c *WSConn) fail(err error) {
+	var f *wsFailure
+	if errors.As(err, &f) {
+		payload := binary.BigEndian.AppendUint16(nil, uint16(f.code))
+		c.writeControl(wsOpClose, append(payload, truncateCloseReason(f.reason)...))
+		c.shutdown(&WSCloseError{Code: f.code, Reason: f.reason})
+		return
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		err = &WSCloseError{Code: WSCloseAbnormal}
+	}
+	c.shutdown(err)
+}
+
+// shutdown records why the connection ended and closes it. The first
+// reason wins.
+func (c *WSConn) shutdown(reason error) {
+	c.closeOnce.Do(func() {
+		c.readErr = reason
+		c.conn.Close()
+		close(c.done)
+	})
+}
+
+// keepalive pings the peer every ping interval and drops the connection
+// when nothing, pong or otherwise, arrives within the pong timeout.
+func (c *WSConn) keepalive() {
+	t := time.NewTicker(c.opts.pingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case now := <-t.C:
+			idle := now.Sub(time.Unix(0, c.lastSeen.Load()))
+			if idle > c.opts.pingInterval+c.opts.pongTimeout {
+				c.shutdown(errWSPongTimeout)
+				return
+			}
+			if err := c.Ping(nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsAcceptKey derives Sec-WebSocket-Accept from Sec-WebSocket-Key.
+func wsAcceptKey(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
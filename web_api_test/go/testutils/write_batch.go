@@ -0,0 +1,242 @@
+package testutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// writeBatchPrefix names the staging directories of open write batches.
+const writeBatchPrefix = ".batch-"
+
+// stagedFile is one file waiting in a batch's staging directory.
+type stagedFile struct {
+	filename string // as given by the caller
+	target   string // final path in the test directory
+	staged   string // path inside the staging directory
+	isNew    bool   // target did not exist when staged
+}
+
+// WriteBatch stages files and writes all of them or none. Create a batch
+// with TestDataManager.BeginWrite, then call Commit or Abort exactly once.
+type WriteBatch struct {
+	mu     sync.Mutex
+	tdm    *TestDataManager
+	dir    string
+	files  []stagedFile
+	index  map[string]int // target -> position in files
+	done   bool
+	rename func(oldpath, newpath string) error // os.Rename; replaced in tests
+}
+
+// BeginWrite opens a write batch staged in a hidden directory under the
+// test directory, so the final renames stay on one filesystem.
+func (tdm *TestDataManager) BeginWrite() (*WriteBatch, error) {
+	tdm.mu.RLock()
+	defer tdm.mu.RUnlock()
+
+	dir, err := os.MkdirTemp(tdm.testDir, writeBatchPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+
+	tdm.logger.Debug("write batch started", map[string]any{"staging": dir})
+	return &WriteBatch{
+		tdm:    tdm,
+		dir:    dir,
+		index:  make(map[string]int),
+		rename: os.Rename,
+	}, nil
+}
+
+// CreateTestFile stages a file with the manager's default mode and returns
+// the path it will have once committed.
+func (b *WriteBatch) CreateTestFile(filename, content string) (string, error) {
+	return b.CreateTestFileWithMode(filename, content, b.tdm.config.FileMode)
+}
+
+// CreateJSONFile stages a file with indented JSON content.
+func (b *WriteBatch) CreateJSONFile(filename string, data any) (string, error) {
+	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON for file %q: %w", filename, err)
+	}
+	return b.CreateTestFile(filename, string(jsonBytes))
+}
+
+// CreateTestFileWithMode stages a file. The name is checked against path
+// traversal and the manager's quotas now, counting files already staged,
+// so Commit fails only on I/O errors. Staging the same name again replaces
+// the earlier content.
+func (b *WriteBatch) CreateTestFileWithMode(filename, content string, mode os.FileMode) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return "", errors.New("write batch already committed or aborted")
+	}
+
+	b.tdm.mu.RLock()
+	defer b.tdm.mu.RUnlock()
+
+	target, err := b.tdm.resolvePath(filename)
+	if err != nil {
+		return "", err
+	}
+	i, restaged := b.index[target]
+	pending := b.pendingNew()
+	if restaged && b.files[i].isNew {
+		pending--
+	}
+	if err := b.tdm.checkQuota(target, int64(len(content)), pending); err != nil {
+		return "", err
+	}
+
+	if !restaged {
+		i = len(b.files)
+		_, statErr := os.Stat(target)
+		b.files = append(b.files, stagedFile{
+			filename: filename,
+			target:   target,
+			staged:   filepath.Join(b.dir, fmt.Sprintf("%04d", i)),
+			isNew:    os.IsNotExist(statErr),
+		})
+		b.index[target] = i
+	}
+	if err := os.WriteFile(b.files[i].staged, []byte(content), mode); err != nil {
+		return "", fmt.Errorf("failed to stage file %q: %w", filename, err)
+	}
+	// WriteFile leaves the mode of an existing file alone.
+	if err := os.Chmod(b.files[i].staged, mode); err != nil {
+		return "", fmt.Errorf("failed to stage file %q: %w", filename, err)
+	}
+
+	b.tdm.logger.Debug("file staged", map[string]any{
+		"filename": filename,
+		"path":     target,
+		"size":     len(content),
+	})
+	return target, nil
+}
+
+// pendingNew counts staged files that will be new to the test directory.
+func (b *WriteBatch) pendingNew() int {
+	n := 0
+	for _, f := range b.files {
+		if f.isNew {
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of staged files.
+func (b *WriteBatch) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.files)
+}
+
+// Commit moves every staged file into place. Files being replaced are set
+// aside first; if any rename fails, files already moved are taken back,
+// the originals restored, and the test directory is left as it was.
+func (b *WriteBatch) Commit() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return nil, errors.New("write batch already committed or aborted")
+	}
+	b.done = true
+	defer os.RemoveAll(b.dir)
+
+	b.tdm.mu.Lock()
+	defer b.tdm.mu.Unlock()
+
+	var (
+		undo    []func() error
+		created []string
+		paths   = make([]string, 0, len(b.files))
+	)
+	rollback := func(cause error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if err := undo[i](); err != nil {
+				b.tdm.logger.Error("write batch rollback step failed", map[string]any{"error": err.Error()})
+			}
+		}
+		for i := len(created) - 1; i >= 0; i-- {
+			os.Remove(created[i]) // only succeeds while empty
+		}
+		b.tdm.logger.Warn("write batch rolled back", map[string]any{
+			"files": len(b.files),
+			"error": cause.Error(),
+		})
+		return cause
+	}
+
+	for i, f := range b.files {
+		dirs, err := b.mkdirParents(filepath.Dir(f.target))
+		created = append(created, dirs...)
+		if err != nil {
+			return nil, rollback(fmt.Errorf("failed to create parent directory for %q: %w", f.filename, err))
+		}
+
+		if _, err := os.Lstat(f.target); err == nil {
+			backup := filepath.Join(b.dir, fmt.Sprintf("%04d.orig", i))
+			if err := b.rename(f.target, backup); err != nil {
+				return nil, rollback(fmt.Errorf("failed to set aside %q: %w", f.filename, err))
+			}
+			target := f.target
+			undo = append(undo, func() error { return os.Rename(backup, target) })
+		}
+
+		if err := b.rename(f.staged, f.target); err != nil {
+			return nil, rollback(fmt.Errorf("failed to commit %q: %w", f.filename, err))
+		}
+		target := f.target
+		undo = append(undo, func() error { return os.Remove(target) })
+		paths = append(paths, f.target)
+	}
+
+	b.tdm.noteOwnWrites(paths...)
+	b.tdm.logger.Info("write batch committed", map[string]any{"files": len(paths)})
+	return paths, nil
+}
+
+// mkdirParents creates dir and any missing parents, returning the ones it
+// created, outermost first.
+func (b *WriteBatch) mkdirParents(dir string) ([]string, error) {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		missing = append([]string{d}, missing...)
+		if d == filepath.Dir(d) {
+			break
+		}
+	}
+	for i, d := range missing {
+		if err := os.Mkdir(d, b.tdm.config.DirMode); err != nil && !os.IsExist(err) {
+			return missing[:i], err
+		}
+	}
+	return missing, nil
+}
+
+// Abort discards every staged file. Aborting after Commit is a no-op.
+func (b *WriteBatch) Abort() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return nil
+	}
+	b.done = true
+
+	b.tdm.logger.Debug("write batch aborted", map[string]any{"files": len(b.files)})
+	if err := os.RemoveAll(b.dir); err != nil {
+		return fmt.Errorf("failed to remove staging directory %q: %w", b.dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package testutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// treeContents maps every file under root to its content.
+func treeContents(t *testing.T, root string) map[string]string {
+	t.Helper()
+	out := make(map[string]string)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		if d.IsDir() {
+			out[rel+"/"] = ""
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		out[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWriteBatch_FailedCommitLeavesDirectoryUnchanged(t *testing.T) {
+	tdm := newTestManager(t, "batch", nil, nil)
+	if _, err := tdm.CreateTestFile("existing.txt", "original"); err != nil {
+		t.Fatal(err)
+	}
+	before := treeContents(t, tdm.GetTestDir())
+
+	batch, err := tdm.BeginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := batch.CreateTestFile("existing.txt", "replaced"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 11; i++ {
+		if _, err := batch.CreateJSONFile(fmt.Sprintf("fixtures/f%02d.json", i), map[string]int{"n": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls := 0
+	batch.rename = func(oldpath, newpath string) error {
+		calls++
+		if calls == 8 {
+			return errors.New("injected rename failure")
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	if _, err := batch.Commit(); err == nil || !strings.Contains(err.Error(), "injected") {
+		t.Fatalf("expected the injected failure, got %v", err)
+	}
+
+	after := treeContents(t, tdm.GetTestDir())
+	if fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Errorf("directory changed by failed commit:\nbefore %v\nafter  %v", before, after)
+	}
+}
+
+func TestWriteBatch_CommitAndAbort(t *testing.T) {
+	tdm := newTestManager(t, "batch", nil, nil)
+
+	batch, _ := tdm.BeginWrite()
+	batch.CreateTestFile("a.txt", "a")
+	batch.CreateTestFile("sub/b.txt", "b")
+	batch.CreateTestFile("a.txt", "a2")
+	if batch.Len() != 2 {
+		t.Errorf("restaging should replace, got %d files", batch.Len())
+	}
+	paths, err := batch.Commit()
+	if err != nil || len(paths) != 2 {
+		t.Fatalf("commit returned %v, %v", paths, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tdm.GetTestDir(), "a.txt")); string(data) != "a2" {
+		t.Errorf("a.txt = %q, want a2", data)
+	}
+	if _, err := batch.CreateTestFile("late.txt", ""); err == nil {
+		t.Error("staging after commit should fail")
+	}
+
+	batch, _ = tdm.BeginWrite()
+	batch.CreateTestFile("discarded.txt", "x")
+	if err := batch.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	got := treeContents(t, tdm.GetTestDir())
+	if len(got) != 4 { // ./, a.txt, sub/, sub/b.txt
+		t.Errorf("abort left extra entries: %v", got)
+	}
+}
+
+func TestWriteBatch_StagingValidation(t *testing.T) {
+	tdm := newTestManager(t, "batch", nil, &TestDataManagerConfig{MaxFiles: 2, MaxFileSize: 8})
+	batch, _ := tdm.BeginWrite()
+	defer batch.Abort()
+
+	if _, err := batch.CreateTestFile("../escape.txt", "x"); err == nil {
+		t.Error("expected traversal to be rejected at staging")
+	}
+	if _, err := batch.CreateTestFile("big.txt", "123456789"); err == nil {
+		t.Error("expected MaxFileSize to be enforced at staging")
+	}
+	batch.CreateTestFile("one.txt", "1")
+	batch.CreateTestFile("two.txt", "2")
+	if _, err := batch.CreateTestFile("three.txt", "3"); err == nil {
+		t.Error("expected MaxFiles to count staged files")
+	}
+	if _, err := batch.CreateTestFile("two.txt", "22"); err != nil {
+		t.Errorf("restaging within quota failed: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package testutils
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ------------------------------------------------------------------------
+// WSTestClient – WebSocket client for endpoint tests
+// ------------------------------------------------------------------------
+
+// WSMatcher reports whether a decoded JSON message is the one expected.
+type WSMatcher func(msg map[string]any) bool
+
+// JSONHas matches messages whose key holds want, compared after a JSON
+// round trip so JSONHas("step", 3) matches {"step": 3}.
+func JSONHas(key string, want any) WSMatcher {
+	norm := want
+	if raw, err := json.Marshal(want); err == nil {
+		json.Unmarshal(raw, &norm)
+	}
+	return func(msg map[string]any) bool {
+		got, ok := msg[key]
+		return ok && reflect.DeepEqual(got, norm)
+	}
+}
+
+// WSTestClient is the client side of a WebSocket under test. Messages that
+// ExpectJSON skips are not replayed.
+type WSTestClient struct {
+	conn *WSConn
+
+	// Response is the server's answer to the opening handshake.
+	Response *http.Response
+}
+
+// DialWebSocket performs the opening handshake against rawURL, which may
+// use the ws, wss, http or https scheme. WithWSHeader adds handshake
+// headers such as Authorization.
+func DialWebSocket(ctx context.Context, rawURL string, opts ...WSOption) (*WSTestClient, error) {
+	o := defaultWSOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial: %w", err)
+	}
+	secure := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme, secure = "https", true
+	default:
+		return nil, fmt.Errorf("websocket dial: unsupported scheme %q", u.Scheme)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), map[bool]string{false: "80", true: "443"}[secure])
+	}
+
+	var d net.Dialer
+	netConn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("websocket dial %s: %w", addr, err)
+	}
+	if secure {
+		tlsConn := tls.Client(netConn, &tls.Config{ServerName: u.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			netConn.Close()
+			return nil, fmt.Errorf("websocket dial %s: %w", addr, err)
+		}
+		netConn = tlsConn
+	}
+
+	client, err := wsHandshake(ctx, netConn, u, o)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+func wsHandshake(ctx context.Context, netConn net.Conn, u *url.URL, o wsOptions) (*WSTestClient, error) {
+	stop := context.AfterFunc(ctx, func() { netConn.SetDeadline(time.Now()) })
+	defer stop()
+
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Host:       u.Host,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Upgrade":               {"websocket"},
+			"Connection":            {"Upgrade"},
+			"Sec-Websocket-Key":     {key},
+			"Sec-Websocket-Version": {"13"},
+		},
+	}
+	for k, v := range o.header {
+		req.Header.Set(k, v)
+	}
+	if err := req.Write(netConn); err != nil {
+		return nil, fmt.Errorf("websocket handshake: %w", err)
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("websocket handshake: %w", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("websocket handshake: unexpected status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != wsAcceptKey(key) {
+		return nil, fmt.Errorf("websocket handshake: bad Sec-WebSocket-Accept %q", got)
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("websocket handshake: %w", ctx.Err())
+	}
+	netConn.SetDeadline(time.Time{})
+	return &WSTestClient{conn: newWSConn(netConn, br, true, o), Response: resp}, nil
+}
+
+// Conn returns the underlying connection for raw reads and writes.
+func (c *WSTestClient) Conn() *WSConn { return c.conn }
+
+// SendJSON encodes v and sends it as a text message.
+func (c *WSTestClient) SendJSON(ctx context.Context, v any) error {
+	return c.conn.WriteJSON(ctx, v)
+}
+
+// ExpectJSON reads messages until one satisfies matcher and returns it.
+// Messages that do not match are skipped. On timeout, or if the connection
+// closes first, the error lists the most recent messages seen.
+func (c *WSTestClient) ExpectJSON(matcher WSMatcher, timeout time.Duration) (map[string]any, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var seen []string
+	for {
+		_, data, err := c.conn.ReadMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("no matching message within %v", timeout)
+			}
+			if len(seen) > 0 {
+				return nil, fmt.Errorf("%w; last seen:\n  %s", err, strings.Join(seen, "\n  "))
+			}
+			return nil, err
+		}
+		var msg map[string]any
+		if json.Unmarshal(data, &msg) == nil && matcher(msg) {
+			return msg, nil
+		}
+		seen = append(seen, truncateField(string(data), 200))
+		if len(seen) > 5 {
+			seen = seen[1:]
+		}
+	}
+}
+
+// Close performs the close handshake.
+func (c *WSTestClient) Close() error {
+	return c.conn.Close()
+}